@@ -0,0 +1,2012 @@
+// PanMatrix gRPC接口
+//
+// serve 或交互式命令行运行时，配置了api.listen就在该地址提供本服务，其他程序可以通过它
+// 上传下载文件、查询元数据和控制后台任务。Go程序可以直接使用 panmatrix/api 包中的客户端。
+// 配置了api.web_listen时还在该地址提供Web界面，其JSON接口与本服务使用同样的实现。
+//
+// 请求的metadata：
+//   authorization: Bearer <令牌>   服务端设置了令牌时必须携带
+//   x-panmatrix-user: <用户>         以该用户身份操作，未携带时使用服务端的默认用户
+//
+// 文件可以用文件ID、文件名或虚拟路径指定，同名文件取最新一次上传。时间均为Unix纳秒，0表示未知。
+//
+// 重新生成Go代码：
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative panmatrix.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: panmatrix.proto
+
+package apipb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type FileInfo struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	FileId    string                 `protobuf:"bytes,1,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	Path      string                 `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Name      string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Dir       string                 `protobuf:"bytes,4,opt,name=dir,proto3" json:"dir,omitempty"`
+	Size      int64                  `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`
+	RaidLevel int32                  `protobuf:"varint,6,opt,name=raid_level,json=raidLevel,proto3" json:"raid_level,omitempty"`
+	Tags      []string               `protobuf:"bytes,7,rep,name=tags,proto3" json:"tags,omitempty"`
+	Owner     string                 `protobuf:"bytes,8,opt,name=owner,proto3" json:"owner,omitempty"`
+	// 整个文件的SHA-256（十六进制）
+	Hash     string `protobuf:"bytes,9,opt,name=hash,proto3" json:"hash,omitempty"`
+	MimeType string `protobuf:"bytes,10,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	ModTime  int64  `protobuf:"varint,11,opt,name=mod_time,json=modTime,proto3" json:"mod_time,omitempty"`
+	// Unix权限位，0表示未记录
+	Mode    uint32 `protobuf:"varint,12,opt,name=mode,proto3" json:"mode,omitempty"`
+	Created int64  `protobuf:"varint,13,opt,name=created,proto3" json:"created,omitempty"`
+	Updated int64  `protobuf:"varint,14,opt,name=updated,proto3" json:"updated,omitempty"`
+	Version int32  `protobuf:"varint,15,opt,name=version,proto3" json:"version,omitempty"`
+	// 被同一路径的新版本替换的时间，当前版本为0
+	Superseded int64 `protobuf:"varint,16,opt,name=superseded,proto3" json:"superseded,omitempty"`
+	// pending、committed、review 或 trashed
+	State         string `protobuf:"bytes,17,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileInfo) Reset() {
+	*x = FileInfo{}
+	mi := &file_panmatrix_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileInfo) ProtoMessage() {}
+
+func (x *FileInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileInfo.ProtoReflect.Descriptor instead.
+func (*FileInfo) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *FileInfo) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *FileInfo) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *FileInfo) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+func (x *FileInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileInfo) GetRaidLevel() int32 {
+	if x != nil {
+		return x.RaidLevel
+	}
+	return 0
+}
+
+func (x *FileInfo) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *FileInfo) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *FileInfo) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *FileInfo) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *FileInfo) GetModTime() int64 {
+	if x != nil {
+		return x.ModTime
+	}
+	return 0
+}
+
+func (x *FileInfo) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *FileInfo) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *FileInfo) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *FileInfo) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *FileInfo) GetSuperseded() int64 {
+	if x != nil {
+		return x.Superseded
+	}
+	return 0
+}
+
+func (x *FileInfo) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+type UploadHeader struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// 所在的虚拟目录，为空表示根目录
+	Dir  string   `protobuf:"bytes,2,opt,name=dir,proto3" json:"dir,omitempty"`
+	Tags []string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	// 文件大小，之后发送的内容必须正好这么多
+	Size          int64  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	ModTime       int64  `protobuf:"varint,5,opt,name=mod_time,json=modTime,proto3" json:"mod_time,omitempty"`
+	Mode          uint32 `protobuf:"varint,6,opt,name=mode,proto3" json:"mode,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadHeader) Reset() {
+	*x = UploadHeader{}
+	mi := &file_panmatrix_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadHeader) ProtoMessage() {}
+
+func (x *UploadHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadHeader.ProtoReflect.Descriptor instead.
+func (*UploadHeader) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *UploadHeader) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UploadHeader) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+func (x *UploadHeader) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *UploadHeader) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadHeader) GetModTime() int64 {
+	if x != nil {
+		return x.ModTime
+	}
+	return 0
+}
+
+func (x *UploadHeader) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+type UploadRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Message:
+	//
+	//	*UploadRequest_Header
+	//	*UploadRequest_Data
+	Message       isUploadRequest_Message `protobuf_oneof:"message"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadRequest) Reset() {
+	*x = UploadRequest{}
+	mi := &file_panmatrix_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadRequest) ProtoMessage() {}
+
+func (x *UploadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadRequest.ProtoReflect.Descriptor instead.
+func (*UploadRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UploadRequest) GetMessage() isUploadRequest_Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *UploadRequest) GetHeader() *UploadHeader {
+	if x != nil {
+		if x, ok := x.Message.(*UploadRequest_Header); ok {
+			return x.Header
+		}
+	}
+	return nil
+}
+
+func (x *UploadRequest) GetData() []byte {
+	if x != nil {
+		if x, ok := x.Message.(*UploadRequest_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+type isUploadRequest_Message interface {
+	isUploadRequest_Message()
+}
+
+type UploadRequest_Header struct {
+	Header *UploadHeader `protobuf:"bytes,1,opt,name=header,proto3,oneof"`
+}
+
+type UploadRequest_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*UploadRequest_Header) isUploadRequest_Message() {}
+
+func (*UploadRequest_Data) isUploadRequest_Message() {}
+
+type UploadResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	File  *FileInfo              `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	// 空间规划的警告
+	Warnings []string `protobuf:"bytes,2,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	// 预计每月增加的费用，未配置费用时为0
+	MonthlyCost   float64 `protobuf:"fixed64,3,opt,name=monthly_cost,json=monthlyCost,proto3" json:"monthly_cost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadResponse) Reset() {
+	*x = UploadResponse{}
+	mi := &file_panmatrix_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadResponse) ProtoMessage() {}
+
+func (x *UploadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadResponse.ProtoReflect.Descriptor instead.
+func (*UploadResponse) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UploadResponse) GetFile() *FileInfo {
+	if x != nil {
+		return x.File
+	}
+	return nil
+}
+
+func (x *UploadResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *UploadResponse) GetMonthlyCost() float64 {
+	if x != nil {
+		return x.MonthlyCost
+	}
+	return 0
+}
+
+type DownloadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	File          string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadRequest) Reset() {
+	*x = DownloadRequest{}
+	mi := &file_panmatrix_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadRequest) ProtoMessage() {}
+
+func (x *DownloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadRequest.ProtoReflect.Descriptor instead.
+func (*DownloadRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DownloadRequest) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+type DownloadResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Message:
+	//
+	//	*DownloadResponse_File
+	//	*DownloadResponse_Data
+	Message       isDownloadResponse_Message `protobuf_oneof:"message"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadResponse) Reset() {
+	*x = DownloadResponse{}
+	mi := &file_panmatrix_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadResponse) ProtoMessage() {}
+
+func (x *DownloadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadResponse.ProtoReflect.Descriptor instead.
+func (*DownloadResponse) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DownloadResponse) GetMessage() isDownloadResponse_Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *DownloadResponse) GetFile() *FileInfo {
+	if x != nil {
+		if x, ok := x.Message.(*DownloadResponse_File); ok {
+			return x.File
+		}
+	}
+	return nil
+}
+
+func (x *DownloadResponse) GetData() []byte {
+	if x != nil {
+		if x, ok := x.Message.(*DownloadResponse_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+type isDownloadResponse_Message interface {
+	isDownloadResponse_Message()
+}
+
+type DownloadResponse_File struct {
+	File *FileInfo `protobuf:"bytes,1,opt,name=file,proto3,oneof"`
+}
+
+type DownloadResponse_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*DownloadResponse_File) isDownloadResponse_Message() {}
+
+func (*DownloadResponse_Data) isDownloadResponse_Message() {}
+
+type StatRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	File          string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatRequest) Reset() {
+	*x = StatRequest{}
+	mi := &file_panmatrix_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatRequest) ProtoMessage() {}
+
+func (x *StatRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatRequest.ProtoReflect.Descriptor instead.
+func (*StatRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StatRequest) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+type ListRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 只列出该虚拟目录中的文件，为空时列出所有文件
+	Dir       string `protobuf:"bytes,1,opt,name=dir,proto3" json:"dir,omitempty"`
+	Recursive bool   `protobuf:"varint,2,opt,name=recursive,proto3" json:"recursive,omitempty"`
+	// 文件名通配符（如 *.iso）
+	Pattern    string  `protobuf:"bytes,3,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	RaidLevels []int32 `protobuf:"varint,4,rep,packed,name=raid_levels,json=raidLevels,proto3" json:"raid_levels,omitempty"`
+	// name、size、created 或 updated，默认name
+	SortBy string `protobuf:"bytes,5,opt,name=sort_by,json=sortBy,proto3" json:"sort_by,omitempty"`
+	Desc   bool   `protobuf:"varint,6,opt,name=desc,proto3" json:"desc,omitempty"`
+	Offset int32  `protobuf:"varint,7,opt,name=offset,proto3" json:"offset,omitempty"`
+	// 0表示不限
+	Limit         int32 `protobuf:"varint,8,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_panmatrix_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListRequest) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+func (x *ListRequest) GetRecursive() bool {
+	if x != nil {
+		return x.Recursive
+	}
+	return false
+}
+
+func (x *ListRequest) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *ListRequest) GetRaidLevels() []int32 {
+	if x != nil {
+		return x.RaidLevels
+	}
+	return nil
+}
+
+func (x *ListRequest) GetSortBy() string {
+	if x != nil {
+		return x.SortBy
+	}
+	return ""
+}
+
+func (x *ListRequest) GetDesc() bool {
+	if x != nil {
+		return x.Desc
+	}
+	return false
+}
+
+func (x *ListRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ListRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type SearchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 搜索表达式，如 "report tag:work size:>10M after:2024-01-01"
+	Query         string `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	SortBy        string `protobuf:"bytes,2,opt,name=sort_by,json=sortBy,proto3" json:"sort_by,omitempty"`
+	Desc          bool   `protobuf:"varint,3,opt,name=desc,proto3" json:"desc,omitempty"`
+	Offset        int32  `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	Limit         int32  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_panmatrix_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetSortBy() string {
+	if x != nil {
+		return x.SortBy
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetDesc() bool {
+	if x != nil {
+		return x.Desc
+	}
+	return false
+}
+
+func (x *SearchRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Files []*FileInfo            `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	// 指定dir且offset为0时包括其中的子目录路径
+	Dirs []string `protobuf:"bytes,2,rep,name=dirs,proto3" json:"dirs,omitempty"`
+	// 满足条件的文件总数（分页前）
+	Total int32 `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	// 下一页的offset，没有下一页时为-1
+	NextOffset    int32 `protobuf:"varint,4,opt,name=next_offset,json=nextOffset,proto3" json:"next_offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListResponse) Reset() {
+	*x = ListResponse{}
+	mi := &file_panmatrix_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListResponse) ProtoMessage() {}
+
+func (x *ListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListResponse.ProtoReflect.Descriptor instead.
+func (*ListResponse) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListResponse) GetFiles() []*FileInfo {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *ListResponse) GetDirs() []string {
+	if x != nil {
+		return x.Dirs
+	}
+	return nil
+}
+
+func (x *ListResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ListResponse) GetNextOffset() int32 {
+	if x != nil {
+		return x.NextOffset
+	}
+	return 0
+}
+
+type VersionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VersionsRequest) Reset() {
+	*x = VersionsRequest{}
+	mi := &file_panmatrix_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VersionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VersionsRequest) ProtoMessage() {}
+
+func (x *VersionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VersionsRequest.ProtoReflect.Descriptor instead.
+func (*VersionsRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *VersionsRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type VersionsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 最新的在前，不包括回收站中的版本
+	Versions      []*FileInfo `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VersionsResponse) Reset() {
+	*x = VersionsResponse{}
+	mi := &file_panmatrix_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VersionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VersionsResponse) ProtoMessage() {}
+
+func (x *VersionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VersionsResponse.ProtoReflect.Descriptor instead.
+func (*VersionsResponse) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *VersionsResponse) GetVersions() []*FileInfo {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	File          string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_panmatrix_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DeleteRequest) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	File  *FileInfo              `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	// 移入了回收站，过期前可以恢复
+	Trashed       bool `protobuf:"varint,2,opt,name=trashed,proto3" json:"trashed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_panmatrix_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteResponse) GetFile() *FileInfo {
+	if x != nil {
+		return x.File
+	}
+	return nil
+}
+
+func (x *DeleteResponse) GetTrashed() bool {
+	if x != nil {
+		return x.Trashed
+	}
+	return false
+}
+
+type MkdirRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 不存在的上级目录一并创建
+	Path          string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MkdirRequest) Reset() {
+	*x = MkdirRequest{}
+	mi := &file_panmatrix_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MkdirRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MkdirRequest) ProtoMessage() {}
+
+func (x *MkdirRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MkdirRequest.ProtoReflect.Descriptor instead.
+func (*MkdirRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *MkdirRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type MkdirResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MkdirResponse) Reset() {
+	*x = MkdirResponse{}
+	mi := &file_panmatrix_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MkdirResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MkdirResponse) ProtoMessage() {}
+
+func (x *MkdirResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MkdirResponse.ProtoReflect.Descriptor instead.
+func (*MkdirResponse) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{15}
+}
+
+type DriversRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DriversRequest) Reset() {
+	*x = DriversRequest{}
+	mi := &file_panmatrix_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DriversRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DriversRequest) ProtoMessage() {}
+
+func (x *DriversRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DriversRequest.ProtoReflect.Descriptor instead.
+func (*DriversRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{16}
+}
+
+type DriverStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type  string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	// 健康状态，排空中的驱动器为 draining
+	State string `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	Used  int64  `protobuf:"varint,4,opt,name=used,proto3" json:"used,omitempty"`
+	Total int64  `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	// 读取用量失败时的错误
+	Error string `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	// 以下为调度器统计窗口内的指标
+	SuccessRate float64 `protobuf:"fixed64,7,opt,name=success_rate,json=successRate,proto3" json:"success_rate,omitempty"`
+	// 成功操作耗时的分位数（纳秒）
+	LatencyP50 int64 `protobuf:"varint,8,opt,name=latency_p50,json=latencyP50,proto3" json:"latency_p50,omitempty"`
+	LatencyP95 int64 `protobuf:"varint,9,opt,name=latency_p95,json=latencyP95,proto3" json:"latency_p95,omitempty"`
+	// 进行中的传输数和上限
+	CurrentLoad int32 `protobuf:"varint,10,opt,name=current_load,json=currentLoad,proto3" json:"current_load,omitempty"`
+	MaxLoad     int32 `protobuf:"varint,11,opt,name=max_load,json=maxLoad,proto3" json:"max_load,omitempty"`
+	// 探测测得的上传带宽（字节/秒），0表示尚未探测
+	UploadBandwidth float64 `protobuf:"fixed64,12,opt,name=upload_bandwidth,json=uploadBandwidth,proto3" json:"upload_bandwidth,omitempty"`
+	LastError       int64   `protobuf:"varint,13,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *DriverStatus) Reset() {
+	*x = DriverStatus{}
+	mi := &file_panmatrix_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DriverStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DriverStatus) ProtoMessage() {}
+
+func (x *DriverStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DriverStatus.ProtoReflect.Descriptor instead.
+func (*DriverStatus) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DriverStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DriverStatus) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *DriverStatus) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *DriverStatus) GetUsed() int64 {
+	if x != nil {
+		return x.Used
+	}
+	return 0
+}
+
+func (x *DriverStatus) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *DriverStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *DriverStatus) GetSuccessRate() float64 {
+	if x != nil {
+		return x.SuccessRate
+	}
+	return 0
+}
+
+func (x *DriverStatus) GetLatencyP50() int64 {
+	if x != nil {
+		return x.LatencyP50
+	}
+	return 0
+}
+
+func (x *DriverStatus) GetLatencyP95() int64 {
+	if x != nil {
+		return x.LatencyP95
+	}
+	return 0
+}
+
+func (x *DriverStatus) GetCurrentLoad() int32 {
+	if x != nil {
+		return x.CurrentLoad
+	}
+	return 0
+}
+
+func (x *DriverStatus) GetMaxLoad() int32 {
+	if x != nil {
+		return x.MaxLoad
+	}
+	return 0
+}
+
+func (x *DriverStatus) GetUploadBandwidth() float64 {
+	if x != nil {
+		return x.UploadBandwidth
+	}
+	return 0
+}
+
+func (x *DriverStatus) GetLastError() int64 {
+	if x != nil {
+		return x.LastError
+	}
+	return 0
+}
+
+type DriversResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Drivers       []*DriverStatus        `protobuf:"bytes,1,rep,name=drivers,proto3" json:"drivers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DriversResponse) Reset() {
+	*x = DriversResponse{}
+	mi := &file_panmatrix_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DriversResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DriversResponse) ProtoMessage() {}
+
+func (x *DriversResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DriversResponse.ProtoReflect.Descriptor instead.
+func (*DriversResponse) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DriversResponse) GetDrivers() []*DriverStatus {
+	if x != nil {
+		return x.Drivers
+	}
+	return nil
+}
+
+type Job struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type   string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Params map[string]string      `protobuf:"bytes,3,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// queued、running、paused、succeeded、failed 或 canceled
+	State string `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
+	Done  int64  `protobuf:"varint,5,opt,name=done,proto3" json:"done,omitempty"`
+	// 0表示总量未知
+	Total         int64  `protobuf:"varint,6,opt,name=total,proto3" json:"total,omitempty"`
+	Message       string `protobuf:"bytes,7,opt,name=message,proto3" json:"message,omitempty"`
+	Result        string `protobuf:"bytes,8,opt,name=result,proto3" json:"result,omitempty"`
+	Error         string `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+	Created       int64  `protobuf:"varint,10,opt,name=created,proto3" json:"created,omitempty"`
+	Started       int64  `protobuf:"varint,11,opt,name=started,proto3" json:"started,omitempty"`
+	Finished      int64  `protobuf:"varint,12,opt,name=finished,proto3" json:"finished,omitempty"`
+	Updated       int64  `protobuf:"varint,13,opt,name=updated,proto3" json:"updated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_panmatrix_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Job) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *Job) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Job) GetDone() int64 {
+	if x != nil {
+		return x.Done
+	}
+	return 0
+}
+
+func (x *Job) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Job) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Job) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *Job) GetStarted() int64 {
+	if x != nil {
+		return x.Started
+	}
+	return 0
+}
+
+func (x *Job) GetFinished() int64 {
+	if x != nil {
+		return x.Finished
+	}
+	return 0
+}
+
+func (x *Job) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+type SubmitJobRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// evacuate、restripe、check-meta、rebuild-metadata 或 gc
+	Type string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	// 参数同对应的命令：evacuate的driver，restripe的rate_mb，check-meta的deep和heal
+	Params map[string]string `protobuf:"bytes,2,rep,name=params,proto3" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// 希望完成的时限，0表示不限
+	Deadline      int64 `protobuf:"varint,3,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitJobRequest) Reset() {
+	*x = SubmitJobRequest{}
+	mi := &file_panmatrix_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitJobRequest) ProtoMessage() {}
+
+func (x *SubmitJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitJobRequest.ProtoReflect.Descriptor instead.
+func (*SubmitJobRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *SubmitJobRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *SubmitJobRequest) GetParams() map[string]string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *SubmitJobRequest) GetDeadline() int64 {
+	if x != nil {
+		return x.Deadline
+	}
+	return 0
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_panmatrix_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *GetJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListJobsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsRequest) Reset() {
+	*x = ListJobsRequest{}
+	mi := &file_panmatrix_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsRequest) ProtoMessage() {}
+
+func (x *ListJobsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsRequest.ProtoReflect.Descriptor instead.
+func (*ListJobsRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{22}
+}
+
+type ListJobsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 新提交的在前
+	Jobs          []*Job `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListJobsResponse) Reset() {
+	*x = ListJobsResponse{}
+	mi := &file_panmatrix_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListJobsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListJobsResponse) ProtoMessage() {}
+
+func (x *ListJobsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListJobsResponse.ProtoReflect.Descriptor instead.
+func (*ListJobsResponse) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ListJobsResponse) GetJobs() []*Job {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type JobControlRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobControlRequest) Reset() {
+	*x = JobControlRequest{}
+	mi := &file_panmatrix_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobControlRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobControlRequest) ProtoMessage() {}
+
+func (x *JobControlRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_panmatrix_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobControlRequest.ProtoReflect.Descriptor instead.
+func (*JobControlRequest) Descriptor() ([]byte, []int) {
+	return file_panmatrix_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *JobControlRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+var File_panmatrix_proto protoreflect.FileDescriptor
+
+const file_panmatrix_proto_rawDesc = "" +
+	"\n" +
+	"\x0fpanmatrix.proto\x12\x10panmatrix.api.v1\"\x9e\x03\n" +
+	"\bFileInfo\x12\x17\n" +
+	"\afile_id\x18\x01 \x01(\tR\x06fileId\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x10\n" +
+	"\x03dir\x18\x04 \x01(\tR\x03dir\x12\x12\n" +
+	"\x04size\x18\x05 \x01(\x03R\x04size\x12\x1d\n" +
+	"\n" +
+	"raid_level\x18\x06 \x01(\x05R\traidLevel\x12\x12\n" +
+	"\x04tags\x18\a \x03(\tR\x04tags\x12\x14\n" +
+	"\x05owner\x18\b \x01(\tR\x05owner\x12\x12\n" +
+	"\x04hash\x18\t \x01(\tR\x04hash\x12\x1b\n" +
+	"\tmime_type\x18\n" +
+	" \x01(\tR\bmimeType\x12\x19\n" +
+	"\bmod_time\x18\v \x01(\x03R\amodTime\x12\x12\n" +
+	"\x04mode\x18\f \x01(\rR\x04mode\x12\x18\n" +
+	"\acreated\x18\r \x01(\x03R\acreated\x12\x18\n" +
+	"\aupdated\x18\x0e \x01(\x03R\aupdated\x12\x18\n" +
+	"\aversion\x18\x0f \x01(\x05R\aversion\x12\x1e\n" +
+	"\n" +
+	"superseded\x18\x10 \x01(\x03R\n" +
+	"superseded\x12\x14\n" +
+	"\x05state\x18\x11 \x01(\tR\x05state\"\x8b\x01\n" +
+	"\fUploadHeader\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03dir\x18\x02 \x01(\tR\x03dir\x12\x12\n" +
+	"\x04tags\x18\x03 \x03(\tR\x04tags\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x19\n" +
+	"\bmod_time\x18\x05 \x01(\x03R\amodTime\x12\x12\n" +
+	"\x04mode\x18\x06 \x01(\rR\x04mode\"j\n" +
+	"\rUploadRequest\x128\n" +
+	"\x06header\x18\x01 \x01(\v2\x1e.panmatrix.api.v1.UploadHeaderH\x00R\x06header\x12\x14\n" +
+	"\x04data\x18\x02 \x01(\fH\x00R\x04dataB\t\n" +
+	"\amessage\"\x7f\n" +
+	"\x0eUploadResponse\x12.\n" +
+	"\x04file\x18\x01 \x01(\v2\x1a.panmatrix.api.v1.FileInfoR\x04file\x12\x1a\n" +
+	"\bwarnings\x18\x02 \x03(\tR\bwarnings\x12!\n" +
+	"\fmonthly_cost\x18\x03 \x01(\x01R\vmonthlyCost\"%\n" +
+	"\x0fDownloadRequest\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\"e\n" +
+	"\x10DownloadResponse\x120\n" +
+	"\x04file\x18\x01 \x01(\v2\x1a.panmatrix.api.v1.FileInfoH\x00R\x04file\x12\x14\n" +
+	"\x04data\x18\x02 \x01(\fH\x00R\x04dataB\t\n" +
+	"\amessage\"!\n" +
+	"\vStatRequest\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\"\xd3\x01\n" +
+	"\vListRequest\x12\x10\n" +
+	"\x03dir\x18\x01 \x01(\tR\x03dir\x12\x1c\n" +
+	"\trecursive\x18\x02 \x01(\bR\trecursive\x12\x18\n" +
+	"\apattern\x18\x03 \x01(\tR\apattern\x12\x1f\n" +
+	"\vraid_levels\x18\x04 \x03(\x05R\n" +
+	"raidLevels\x12\x17\n" +
+	"\asort_by\x18\x05 \x01(\tR\x06sortBy\x12\x12\n" +
+	"\x04desc\x18\x06 \x01(\bR\x04desc\x12\x16\n" +
+	"\x06offset\x18\a \x01(\x05R\x06offset\x12\x14\n" +
+	"\x05limit\x18\b \x01(\x05R\x05limit\"\x80\x01\n" +
+	"\rSearchRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x17\n" +
+	"\asort_by\x18\x02 \x01(\tR\x06sortBy\x12\x12\n" +
+	"\x04desc\x18\x03 \x01(\bR\x04desc\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x05R\x06offset\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\"\x8b\x01\n" +
+	"\fListResponse\x120\n" +
+	"\x05files\x18\x01 \x03(\v2\x1a.panmatrix.api.v1.FileInfoR\x05files\x12\x12\n" +
+	"\x04dirs\x18\x02 \x03(\tR\x04dirs\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x05R\x05total\x12\x1f\n" +
+	"\vnext_offset\x18\x04 \x01(\x05R\n" +
+	"nextOffset\"%\n" +
+	"\x0fVersionsRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"J\n" +
+	"\x10VersionsResponse\x126\n" +
+	"\bversions\x18\x01 \x03(\v2\x1a.panmatrix.api.v1.FileInfoR\bversions\"#\n" +
+	"\rDeleteRequest\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\"Z\n" +
+	"\x0eDeleteResponse\x12.\n" +
+	"\x04file\x18\x01 \x01(\v2\x1a.panmatrix.api.v1.FileInfoR\x04file\x12\x18\n" +
+	"\atrashed\x18\x02 \x01(\bR\atrashed\"\"\n" +
+	"\fMkdirRequest\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\"\x0f\n" +
+	"\rMkdirResponse\"\x10\n" +
+	"\x0eDriversRequest\"\xf9\x02\n" +
+	"\fDriverStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x14\n" +
+	"\x05state\x18\x03 \x01(\tR\x05state\x12\x12\n" +
+	"\x04used\x18\x04 \x01(\x03R\x04used\x12\x14\n" +
+	"\x05total\x18\x05 \x01(\x03R\x05total\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12!\n" +
+	"\fsuccess_rate\x18\a \x01(\x01R\vsuccessRate\x12\x1f\n" +
+	"\vlatency_p50\x18\b \x01(\x03R\n" +
+	"latencyP50\x12\x1f\n" +
+	"\vlatency_p95\x18\t \x01(\x03R\n" +
+	"latencyP95\x12!\n" +
+	"\fcurrent_load\x18\n" +
+	" \x01(\x05R\vcurrentLoad\x12\x19\n" +
+	"\bmax_load\x18\v \x01(\x05R\amaxLoad\x12)\n" +
+	"\x10upload_bandwidth\x18\f \x01(\x01R\x0fuploadBandwidth\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\r \x01(\x03R\tlastError\"K\n" +
+	"\x0fDriversResponse\x128\n" +
+	"\adrivers\x18\x01 \x03(\v2\x1e.panmatrix.api.v1.DriverStatusR\adrivers\"\x91\x03\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x129\n" +
+	"\x06params\x18\x03 \x03(\v2!.panmatrix.api.v1.Job.ParamsEntryR\x06params\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\x12\x12\n" +
+	"\x04done\x18\x05 \x01(\x03R\x04done\x12\x14\n" +
+	"\x05total\x18\x06 \x01(\x03R\x05total\x12\x18\n" +
+	"\amessage\x18\a \x01(\tR\amessage\x12\x16\n" +
+	"\x06result\x18\b \x01(\tR\x06result\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\x12\x18\n" +
+	"\acreated\x18\n" +
+	" \x01(\x03R\acreated\x12\x18\n" +
+	"\astarted\x18\v \x01(\x03R\astarted\x12\x1a\n" +
+	"\bfinished\x18\f \x01(\x03R\bfinished\x12\x18\n" +
+	"\aupdated\x18\r \x01(\x03R\aupdated\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xc5\x01\n" +
+	"\x10SubmitJobRequest\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12F\n" +
+	"\x06params\x18\x02 \x03(\v2..panmatrix.api.v1.SubmitJobRequest.ParamsEntryR\x06params\x12\x1a\n" +
+	"\bdeadline\x18\x03 \x01(\x03R\bdeadline\x1a9\n" +
+	"\vParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x1f\n" +
+	"\rGetJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x11\n" +
+	"\x0fListJobsRequest\"=\n" +
+	"\x10ListJobsResponse\x12)\n" +
+	"\x04jobs\x18\x01 \x03(\v2\x15.panmatrix.api.v1.JobR\x04jobs\"#\n" +
+	"\x11JobControlRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id2\xbb\t\n" +
+	"\tPanMatrix\x12M\n" +
+	"\x06Upload\x12\x1f.panmatrix.api.v1.UploadRequest\x1a .panmatrix.api.v1.UploadResponse(\x01\x12S\n" +
+	"\bDownload\x12!.panmatrix.api.v1.DownloadRequest\x1a\".panmatrix.api.v1.DownloadResponse0\x01\x12A\n" +
+	"\x04Stat\x12\x1d.panmatrix.api.v1.StatRequest\x1a\x1a.panmatrix.api.v1.FileInfo\x12E\n" +
+	"\x04List\x12\x1d.panmatrix.api.v1.ListRequest\x1a\x1e.panmatrix.api.v1.ListResponse\x12I\n" +
+	"\x06Search\x12\x1f.panmatrix.api.v1.SearchRequest\x1a\x1e.panmatrix.api.v1.ListResponse\x12Q\n" +
+	"\bVersions\x12!.panmatrix.api.v1.VersionsRequest\x1a\".panmatrix.api.v1.VersionsResponse\x12K\n" +
+	"\x06Delete\x12\x1f.panmatrix.api.v1.DeleteRequest\x1a .panmatrix.api.v1.DeleteResponse\x12H\n" +
+	"\x05Mkdir\x12\x1e.panmatrix.api.v1.MkdirRequest\x1a\x1f.panmatrix.api.v1.MkdirResponse\x12N\n" +
+	"\aDrivers\x12 .panmatrix.api.v1.DriversRequest\x1a!.panmatrix.api.v1.DriversResponse\x12F\n" +
+	"\tSubmitJob\x12\".panmatrix.api.v1.SubmitJobRequest\x1a\x15.panmatrix.api.v1.Job\x12@\n" +
+	"\x06GetJob\x12\x1f.panmatrix.api.v1.GetJobRequest\x1a\x15.panmatrix.api.v1.Job\x12Q\n" +
+	"\bListJobs\x12!.panmatrix.api.v1.ListJobsRequest\x1a\".panmatrix.api.v1.ListJobsResponse\x12G\n" +
+	"\tCancelJob\x12#.panmatrix.api.v1.JobControlRequest\x1a\x15.panmatrix.api.v1.Job\x12F\n" +
+	"\bPauseJob\x12#.panmatrix.api.v1.JobControlRequest\x1a\x15.panmatrix.api.v1.Job\x12G\n" +
+	"\tResumeJob\x12#.panmatrix.api.v1.JobControlRequest\x1a\x15.panmatrix.api.v1.Job\x12D\n" +
+	"\bWatchJob\x12\x1f.panmatrix.api.v1.GetJobRequest\x1a\x15.panmatrix.api.v1.Job0\x01B\x15Z\x13panmatrix/api/apipbb\x06proto3"
+
+var (
+	file_panmatrix_proto_rawDescOnce sync.Once
+	file_panmatrix_proto_rawDescData []byte
+)
+
+func file_panmatrix_proto_rawDescGZIP() []byte {
+	file_panmatrix_proto_rawDescOnce.Do(func() {
+		file_panmatrix_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_panmatrix_proto_rawDesc), len(file_panmatrix_proto_rawDesc)))
+	})
+	return file_panmatrix_proto_rawDescData
+}
+
+var file_panmatrix_proto_msgTypes = make([]protoimpl.MessageInfo, 27)
+var file_panmatrix_proto_goTypes = []any{
+	(*FileInfo)(nil),          // 0: panmatrix.api.v1.FileInfo
+	(*UploadHeader)(nil),      // 1: panmatrix.api.v1.UploadHeader
+	(*UploadRequest)(nil),     // 2: panmatrix.api.v1.UploadRequest
+	(*UploadResponse)(nil),    // 3: panmatrix.api.v1.UploadResponse
+	(*DownloadRequest)(nil),   // 4: panmatrix.api.v1.DownloadRequest
+	(*DownloadResponse)(nil),  // 5: panmatrix.api.v1.DownloadResponse
+	(*StatRequest)(nil),       // 6: panmatrix.api.v1.StatRequest
+	(*ListRequest)(nil),       // 7: panmatrix.api.v1.ListRequest
+	(*SearchRequest)(nil),     // 8: panmatrix.api.v1.SearchRequest
+	(*ListResponse)(nil),      // 9: panmatrix.api.v1.ListResponse
+	(*VersionsRequest)(nil),   // 10: panmatrix.api.v1.VersionsRequest
+	(*VersionsResponse)(nil),  // 11: panmatrix.api.v1.VersionsResponse
+	(*DeleteRequest)(nil),     // 12: panmatrix.api.v1.DeleteRequest
+	(*DeleteResponse)(nil),    // 13: panmatrix.api.v1.DeleteResponse
+	(*MkdirRequest)(nil),      // 14: panmatrix.api.v1.MkdirRequest
+	(*MkdirResponse)(nil),     // 15: panmatrix.api.v1.MkdirResponse
+	(*DriversRequest)(nil),    // 16: panmatrix.api.v1.DriversRequest
+	(*DriverStatus)(nil),      // 17: panmatrix.api.v1.DriverStatus
+	(*DriversResponse)(nil),   // 18: panmatrix.api.v1.DriversResponse
+	(*Job)(nil),               // 19: panmatrix.api.v1.Job
+	(*SubmitJobRequest)(nil),  // 20: panmatrix.api.v1.SubmitJobRequest
+	(*GetJobRequest)(nil),     // 21: panmatrix.api.v1.GetJobRequest
+	(*ListJobsRequest)(nil),   // 22: panmatrix.api.v1.ListJobsRequest
+	(*ListJobsResponse)(nil),  // 23: panmatrix.api.v1.ListJobsResponse
+	(*JobControlRequest)(nil), // 24: panmatrix.api.v1.JobControlRequest
+	nil,                       // 25: panmatrix.api.v1.Job.ParamsEntry
+	nil,                       // 26: panmatrix.api.v1.SubmitJobRequest.ParamsEntry
+}
+var file_panmatrix_proto_depIdxs = []int32{
+	1,  // 0: panmatrix.api.v1.UploadRequest.header:type_name -> panmatrix.api.v1.UploadHeader
+	0,  // 1: panmatrix.api.v1.UploadResponse.file:type_name -> panmatrix.api.v1.FileInfo
+	0,  // 2: panmatrix.api.v1.DownloadResponse.file:type_name -> panmatrix.api.v1.FileInfo
+	0,  // 3: panmatrix.api.v1.ListResponse.files:type_name -> panmatrix.api.v1.FileInfo
+	0,  // 4: panmatrix.api.v1.VersionsResponse.versions:type_name -> panmatrix.api.v1.FileInfo
+	0,  // 5: panmatrix.api.v1.DeleteResponse.file:type_name -> panmatrix.api.v1.FileInfo
+	17, // 6: panmatrix.api.v1.DriversResponse.drivers:type_name -> panmatrix.api.v1.DriverStatus
+	25, // 7: panmatrix.api.v1.Job.params:type_name -> panmatrix.api.v1.Job.ParamsEntry
+	26, // 8: panmatrix.api.v1.SubmitJobRequest.params:type_name -> panmatrix.api.v1.SubmitJobRequest.ParamsEntry
+	19, // 9: panmatrix.api.v1.ListJobsResponse.jobs:type_name -> panmatrix.api.v1.Job
+	2,  // 10: panmatrix.api.v1.PanMatrix.Upload:input_type -> panmatrix.api.v1.UploadRequest
+	4,  // 11: panmatrix.api.v1.PanMatrix.Download:input_type -> panmatrix.api.v1.DownloadRequest
+	6,  // 12: panmatrix.api.v1.PanMatrix.Stat:input_type -> panmatrix.api.v1.StatRequest
+	7,  // 13: panmatrix.api.v1.PanMatrix.List:input_type -> panmatrix.api.v1.ListRequest
+	8,  // 14: panmatrix.api.v1.PanMatrix.Search:input_type -> panmatrix.api.v1.SearchRequest
+	10, // 15: panmatrix.api.v1.PanMatrix.Versions:input_type -> panmatrix.api.v1.VersionsRequest
+	12, // 16: panmatrix.api.v1.PanMatrix.Delete:input_type -> panmatrix.api.v1.DeleteRequest
+	14, // 17: panmatrix.api.v1.PanMatrix.Mkdir:input_type -> panmatrix.api.v1.MkdirRequest
+	16, // 18: panmatrix.api.v1.PanMatrix.Drivers:input_type -> panmatrix.api.v1.DriversRequest
+	20, // 19: panmatrix.api.v1.PanMatrix.SubmitJob:input_type -> panmatrix.api.v1.SubmitJobRequest
+	21, // 20: panmatrix.api.v1.PanMatrix.GetJob:input_type -> panmatrix.api.v1.GetJobRequest
+	22, // 21: panmatrix.api.v1.PanMatrix.ListJobs:input_type -> panmatrix.api.v1.ListJobsRequest
+	24, // 22: panmatrix.api.v1.PanMatrix.CancelJob:input_type -> panmatrix.api.v1.JobControlRequest
+	24, // 23: panmatrix.api.v1.PanMatrix.PauseJob:input_type -> panmatrix.api.v1.JobControlRequest
+	24, // 24: panmatrix.api.v1.PanMatrix.ResumeJob:input_type -> panmatrix.api.v1.JobControlRequest
+	21, // 25: panmatrix.api.v1.PanMatrix.WatchJob:input_type -> panmatrix.api.v1.GetJobRequest
+	3,  // 26: panmatrix.api.v1.PanMatrix.Upload:output_type -> panmatrix.api.v1.UploadResponse
+	5,  // 27: panmatrix.api.v1.PanMatrix.Download:output_type -> panmatrix.api.v1.DownloadResponse
+	0,  // 28: panmatrix.api.v1.PanMatrix.Stat:output_type -> panmatrix.api.v1.FileInfo
+	9,  // 29: panmatrix.api.v1.PanMatrix.List:output_type -> panmatrix.api.v1.ListResponse
+	9,  // 30: panmatrix.api.v1.PanMatrix.Search:output_type -> panmatrix.api.v1.ListResponse
+	11, // 31: panmatrix.api.v1.PanMatrix.Versions:output_type -> panmatrix.api.v1.VersionsResponse
+	13, // 32: panmatrix.api.v1.PanMatrix.Delete:output_type -> panmatrix.api.v1.DeleteResponse
+	15, // 33: panmatrix.api.v1.PanMatrix.Mkdir:output_type -> panmatrix.api.v1.MkdirResponse
+	18, // 34: panmatrix.api.v1.PanMatrix.Drivers:output_type -> panmatrix.api.v1.DriversResponse
+	19, // 35: panmatrix.api.v1.PanMatrix.SubmitJob:output_type -> panmatrix.api.v1.Job
+	19, // 36: panmatrix.api.v1.PanMatrix.GetJob:output_type -> panmatrix.api.v1.Job
+	23, // 37: panmatrix.api.v1.PanMatrix.ListJobs:output_type -> panmatrix.api.v1.ListJobsResponse
+	19, // 38: panmatrix.api.v1.PanMatrix.CancelJob:output_type -> panmatrix.api.v1.Job
+	19, // 39: panmatrix.api.v1.PanMatrix.PauseJob:output_type -> panmatrix.api.v1.Job
+	19, // 40: panmatrix.api.v1.PanMatrix.ResumeJob:output_type -> panmatrix.api.v1.Job
+	19, // 41: panmatrix.api.v1.PanMatrix.WatchJob:output_type -> panmatrix.api.v1.Job
+	26, // [26:42] is the sub-list for method output_type
+	10, // [10:26] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_panmatrix_proto_init() }
+func file_panmatrix_proto_init() {
+	if File_panmatrix_proto != nil {
+		return
+	}
+	file_panmatrix_proto_msgTypes[2].OneofWrappers = []any{
+		(*UploadRequest_Header)(nil),
+		(*UploadRequest_Data)(nil),
+	}
+	file_panmatrix_proto_msgTypes[5].OneofWrappers = []any{
+		(*DownloadResponse_File)(nil),
+		(*DownloadResponse_Data)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_panmatrix_proto_rawDesc), len(file_panmatrix_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   27,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_panmatrix_proto_goTypes,
+		DependencyIndexes: file_panmatrix_proto_depIdxs,
+		MessageInfos:      file_panmatrix_proto_msgTypes,
+	}.Build()
+	File_panmatrix_proto = out.File
+	file_panmatrix_proto_goTypes = nil
+	file_panmatrix_proto_depIdxs = nil
+}
@@ -0,0 +1,717 @@
+// PanMatrix gRPC接口
+//
+// serve 或交互式命令行运行时，配置了api.listen就在该地址提供本服务，其他程序可以通过它
+// 上传下载文件、查询元数据和控制后台任务。Go程序可以直接使用 panmatrix/api 包中的客户端。
+// 配置了api.web_listen时还在该地址提供Web界面，其JSON接口与本服务使用同样的实现。
+//
+// 请求的metadata：
+//   authorization: Bearer <令牌>   服务端设置了令牌时必须携带
+//   x-panmatrix-user: <用户>         以该用户身份操作，未携带时使用服务端的默认用户
+//
+// 文件可以用文件ID、文件名或虚拟路径指定，同名文件取最新一次上传。时间均为Unix纳秒，0表示未知。
+//
+// 重新生成Go代码：
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative panmatrix.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: panmatrix.proto
+
+package apipb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PanMatrix_Upload_FullMethodName    = "/panmatrix.api.v1.PanMatrix/Upload"
+	PanMatrix_Download_FullMethodName  = "/panmatrix.api.v1.PanMatrix/Download"
+	PanMatrix_Stat_FullMethodName      = "/panmatrix.api.v1.PanMatrix/Stat"
+	PanMatrix_List_FullMethodName      = "/panmatrix.api.v1.PanMatrix/List"
+	PanMatrix_Search_FullMethodName    = "/panmatrix.api.v1.PanMatrix/Search"
+	PanMatrix_Versions_FullMethodName  = "/panmatrix.api.v1.PanMatrix/Versions"
+	PanMatrix_Delete_FullMethodName    = "/panmatrix.api.v1.PanMatrix/Delete"
+	PanMatrix_Mkdir_FullMethodName     = "/panmatrix.api.v1.PanMatrix/Mkdir"
+	PanMatrix_Drivers_FullMethodName   = "/panmatrix.api.v1.PanMatrix/Drivers"
+	PanMatrix_SubmitJob_FullMethodName = "/panmatrix.api.v1.PanMatrix/SubmitJob"
+	PanMatrix_GetJob_FullMethodName    = "/panmatrix.api.v1.PanMatrix/GetJob"
+	PanMatrix_ListJobs_FullMethodName  = "/panmatrix.api.v1.PanMatrix/ListJobs"
+	PanMatrix_CancelJob_FullMethodName = "/panmatrix.api.v1.PanMatrix/CancelJob"
+	PanMatrix_PauseJob_FullMethodName  = "/panmatrix.api.v1.PanMatrix/PauseJob"
+	PanMatrix_ResumeJob_FullMethodName = "/panmatrix.api.v1.PanMatrix/ResumeJob"
+	PanMatrix_WatchJob_FullMethodName  = "/panmatrix.api.v1.PanMatrix/WatchJob"
+)
+
+// PanMatrixClient is the client API for PanMatrix service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PanMatrixClient interface {
+	// 上传文件：第一条消息为header，之后为文件内容，发送完后关闭
+	Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadRequest, UploadResponse], error)
+	// 下载文件：第一条消息为file，之后为文件内容。整个文件校验通过后才开始发送
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadResponse], error)
+	Stat(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*FileInfo, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Versions(ctx context.Context, in *VersionsRequest, opts ...grpc.CallOption) (*VersionsResponse, error)
+	// 已提交的文件移入回收站（启用回收站时），否则直接删除
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Mkdir(ctx context.Context, in *MkdirRequest, opts ...grpc.CallOption) (*MkdirResponse, error)
+	Drivers(ctx context.Context, in *DriversRequest, opts ...grpc.CallOption) (*DriversResponse, error)
+	// 提交后台任务，由服务端的任务工作协程执行
+	SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*Job, error)
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error)
+	CancelJob(ctx context.Context, in *JobControlRequest, opts ...grpc.CallOption) (*Job, error)
+	PauseJob(ctx context.Context, in *JobControlRequest, opts ...grpc.CallOption) (*Job, error)
+	ResumeJob(ctx context.Context, in *JobControlRequest, opts ...grpc.CallOption) (*Job, error)
+	// 先发送当前的任务记录，之后每次变化时发送，任务结束后关闭
+	WatchJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Job], error)
+}
+
+type panMatrixClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPanMatrixClient(cc grpc.ClientConnInterface) PanMatrixClient {
+	return &panMatrixClient{cc}
+}
+
+func (c *panMatrixClient) Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadRequest, UploadResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PanMatrix_ServiceDesc.Streams[0], PanMatrix_Upload_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadRequest, UploadResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PanMatrix_UploadClient = grpc.ClientStreamingClient[UploadRequest, UploadResponse]
+
+func (c *panMatrixClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PanMatrix_ServiceDesc.Streams[1], PanMatrix_Download_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DownloadRequest, DownloadResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PanMatrix_DownloadClient = grpc.ServerStreamingClient[DownloadResponse]
+
+func (c *panMatrixClient) Stat(ctx context.Context, in *StatRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FileInfo)
+	err := c.cc.Invoke(ctx, PanMatrix_Stat_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, PanMatrix_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, PanMatrix_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) Versions(ctx context.Context, in *VersionsRequest, opts ...grpc.CallOption) (*VersionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VersionsResponse)
+	err := c.cc.Invoke(ctx, PanMatrix_Versions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, PanMatrix_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) Mkdir(ctx context.Context, in *MkdirRequest, opts ...grpc.CallOption) (*MkdirResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MkdirResponse)
+	err := c.cc.Invoke(ctx, PanMatrix_Mkdir_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) Drivers(ctx context.Context, in *DriversRequest, opts ...grpc.CallOption) (*DriversResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DriversResponse)
+	err := c.cc.Invoke(ctx, PanMatrix_Drivers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, PanMatrix_SubmitJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, PanMatrix_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) ListJobs(ctx context.Context, in *ListJobsRequest, opts ...grpc.CallOption) (*ListJobsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListJobsResponse)
+	err := c.cc.Invoke(ctx, PanMatrix_ListJobs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) CancelJob(ctx context.Context, in *JobControlRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, PanMatrix_CancelJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) PauseJob(ctx context.Context, in *JobControlRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, PanMatrix_PauseJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) ResumeJob(ctx context.Context, in *JobControlRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, PanMatrix_ResumeJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *panMatrixClient) WatchJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Job], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PanMatrix_ServiceDesc.Streams[2], PanMatrix_WatchJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetJobRequest, Job]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PanMatrix_WatchJobClient = grpc.ServerStreamingClient[Job]
+
+// PanMatrixServer is the server API for PanMatrix service.
+// All implementations must embed UnimplementedPanMatrixServer
+// for forward compatibility.
+type PanMatrixServer interface {
+	// 上传文件：第一条消息为header，之后为文件内容，发送完后关闭
+	Upload(grpc.ClientStreamingServer[UploadRequest, UploadResponse]) error
+	// 下载文件：第一条消息为file，之后为文件内容。整个文件校验通过后才开始发送
+	Download(*DownloadRequest, grpc.ServerStreamingServer[DownloadResponse]) error
+	Stat(context.Context, *StatRequest) (*FileInfo, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Search(context.Context, *SearchRequest) (*ListResponse, error)
+	Versions(context.Context, *VersionsRequest) (*VersionsResponse, error)
+	// 已提交的文件移入回收站（启用回收站时），否则直接删除
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Mkdir(context.Context, *MkdirRequest) (*MkdirResponse, error)
+	Drivers(context.Context, *DriversRequest) (*DriversResponse, error)
+	// 提交后台任务，由服务端的任务工作协程执行
+	SubmitJob(context.Context, *SubmitJobRequest) (*Job, error)
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error)
+	CancelJob(context.Context, *JobControlRequest) (*Job, error)
+	PauseJob(context.Context, *JobControlRequest) (*Job, error)
+	ResumeJob(context.Context, *JobControlRequest) (*Job, error)
+	// 先发送当前的任务记录，之后每次变化时发送，任务结束后关闭
+	WatchJob(*GetJobRequest, grpc.ServerStreamingServer[Job]) error
+	mustEmbedUnimplementedPanMatrixServer()
+}
+
+// UnimplementedPanMatrixServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPanMatrixServer struct{}
+
+func (UnimplementedPanMatrixServer) Upload(grpc.ClientStreamingServer[UploadRequest, UploadResponse]) error {
+	return status.Error(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedPanMatrixServer) Download(*DownloadRequest, grpc.ServerStreamingServer[DownloadResponse]) error {
+	return status.Error(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedPanMatrixServer) Stat(context.Context, *StatRequest) (*FileInfo, error) {
+	return nil, status.Error(codes.Unimplemented, "method Stat not implemented")
+}
+func (UnimplementedPanMatrixServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedPanMatrixServer) Search(context.Context, *SearchRequest) (*ListResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedPanMatrixServer) Versions(context.Context, *VersionsRequest) (*VersionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Versions not implemented")
+}
+func (UnimplementedPanMatrixServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedPanMatrixServer) Mkdir(context.Context, *MkdirRequest) (*MkdirResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Mkdir not implemented")
+}
+func (UnimplementedPanMatrixServer) Drivers(context.Context, *DriversRequest) (*DriversResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Drivers not implemented")
+}
+func (UnimplementedPanMatrixServer) SubmitJob(context.Context, *SubmitJobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method SubmitJob not implemented")
+}
+func (UnimplementedPanMatrixServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedPanMatrixServer) ListJobs(context.Context, *ListJobsRequest) (*ListJobsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListJobs not implemented")
+}
+func (UnimplementedPanMatrixServer) CancelJob(context.Context, *JobControlRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelJob not implemented")
+}
+func (UnimplementedPanMatrixServer) PauseJob(context.Context, *JobControlRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method PauseJob not implemented")
+}
+func (UnimplementedPanMatrixServer) ResumeJob(context.Context, *JobControlRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method ResumeJob not implemented")
+}
+func (UnimplementedPanMatrixServer) WatchJob(*GetJobRequest, grpc.ServerStreamingServer[Job]) error {
+	return status.Error(codes.Unimplemented, "method WatchJob not implemented")
+}
+func (UnimplementedPanMatrixServer) mustEmbedUnimplementedPanMatrixServer() {}
+func (UnimplementedPanMatrixServer) testEmbeddedByValue()                   {}
+
+// UnsafePanMatrixServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PanMatrixServer will
+// result in compilation errors.
+type UnsafePanMatrixServer interface {
+	mustEmbedUnimplementedPanMatrixServer()
+}
+
+func RegisterPanMatrixServer(s grpc.ServiceRegistrar, srv PanMatrixServer) {
+	// If the following call panics, it indicates UnimplementedPanMatrixServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PanMatrix_ServiceDesc, srv)
+}
+
+func _PanMatrix_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PanMatrixServer).Upload(&grpc.GenericServerStream[UploadRequest, UploadResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PanMatrix_UploadServer = grpc.ClientStreamingServer[UploadRequest, UploadResponse]
+
+func _PanMatrix_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PanMatrixServer).Download(m, &grpc.GenericServerStream[DownloadRequest, DownloadResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PanMatrix_DownloadServer = grpc.ServerStreamingServer[DownloadResponse]
+
+func _PanMatrix_Stat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).Stat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_Stat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).Stat(ctx, req.(*StatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_Versions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).Versions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_Versions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).Versions(ctx, req.(*VersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_Mkdir_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MkdirRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).Mkdir(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_Mkdir_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).Mkdir(ctx, req.(*MkdirRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_Drivers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DriversRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).Drivers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_Drivers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).Drivers(ctx, req.(*DriversRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_SubmitJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).SubmitJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_SubmitJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).SubmitJob(ctx, req.(*SubmitJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_ListJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).ListJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_ListJobs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).ListJobs(ctx, req.(*ListJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_CancelJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobControlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).CancelJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_CancelJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).CancelJob(ctx, req.(*JobControlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_PauseJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobControlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).PauseJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_PauseJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).PauseJob(ctx, req.(*JobControlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_ResumeJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobControlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PanMatrixServer).ResumeJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PanMatrix_ResumeJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PanMatrixServer).ResumeJob(ctx, req.(*JobControlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PanMatrix_WatchJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetJobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PanMatrixServer).WatchJob(m, &grpc.GenericServerStream[GetJobRequest, Job]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PanMatrix_WatchJobServer = grpc.ServerStreamingServer[Job]
+
+// PanMatrix_ServiceDesc is the grpc.ServiceDesc for PanMatrix service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PanMatrix_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "panmatrix.api.v1.PanMatrix",
+	HandlerType: (*PanMatrixServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Stat",
+			Handler:    _PanMatrix_Stat_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _PanMatrix_List_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _PanMatrix_Search_Handler,
+		},
+		{
+			MethodName: "Versions",
+			Handler:    _PanMatrix_Versions_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _PanMatrix_Delete_Handler,
+		},
+		{
+			MethodName: "Mkdir",
+			Handler:    _PanMatrix_Mkdir_Handler,
+		},
+		{
+			MethodName: "Drivers",
+			Handler:    _PanMatrix_Drivers_Handler,
+		},
+		{
+			MethodName: "SubmitJob",
+			Handler:    _PanMatrix_SubmitJob_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _PanMatrix_GetJob_Handler,
+		},
+		{
+			MethodName: "ListJobs",
+			Handler:    _PanMatrix_ListJobs_Handler,
+		},
+		{
+			MethodName: "CancelJob",
+			Handler:    _PanMatrix_CancelJob_Handler,
+		},
+		{
+			MethodName: "PauseJob",
+			Handler:    _PanMatrix_PauseJob_Handler,
+		},
+		{
+			MethodName: "ResumeJob",
+			Handler:    _PanMatrix_ResumeJob_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Upload",
+			Handler:       _PanMatrix_Upload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Download",
+			Handler:       _PanMatrix_Download_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchJob",
+			Handler:       _PanMatrix_WatchJob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "panmatrix.proto",
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"panmatrix/api/apipb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// 连接选项
+type Options struct {
+	Token string      // 服务端设置了令牌时必须填写
+	User  string      // 以该用户身份操作，为空时使用服务端的默认用户
+	TLS   *tls.Config // 服务端启用TLS时设置，为nil时不加密
+}
+
+// PanMatrix gRPC接口的客户端。除了生成的各个方法，还提供按io.Reader/io.Writer上传下载的封装：
+//
+//	client, err := api.Dial("127.0.0.1:7070", api.Options{Token: token})
+//	if err != nil { ... }
+//	defer client.Close()
+//	file, err := client.UploadFile(ctx, "report.pdf", "/docs", nil)
+type Client struct {
+	apipb.PanMatrixClient
+	conn *grpc.ClientConn
+}
+
+// 连接PanMatrix，address为 host:port 或 unix:/path。连接在首次调用时建立
+func Dial(address string, opts Options) (*Client, error) {
+	creds := insecure.NewCredentials()
+	if opts.TLS != nil {
+		creds = credentials.NewTLS(opts.TLS)
+	}
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithPerRPCCredentials(requestMetadata{token: opts.Token, user: opts.User, secure: opts.TLS != nil}),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("连接PanMatrix失败: %v", err)
+	}
+	return &Client{PanMatrixClient: apipb.NewPanMatrixClient(conn), conn: conn}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// 上传r中的内容，header.Size必须与内容的长度一致
+func (c *Client) Upload(ctx context.Context, header *apipb.UploadHeader, r io.Reader) (*apipb.UploadResponse, error) {
+	stream, err := c.PanMatrixClient.Upload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&apipb.UploadRequest{Message: &apipb.UploadRequest_Header{Header: header}}); err != nil {
+		return nil, uploadError(stream, err)
+	}
+
+	buf := make([]byte, ChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := stream.Send(&apipb.UploadRequest{Message: &apipb.UploadRequest_Data{Data: buf[:n]}}); err != nil {
+				return nil, uploadError(stream, err)
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			stream.CloseSend()
+			return nil, fmt.Errorf("读取上传内容失败: %v", err)
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// 服务端提前结束上传时Send返回io.EOF，真正的错误由CloseAndRecv取得
+func uploadError(stream apipb.PanMatrix_UploadClient, err error) error {
+	if errors.Is(err, io.EOF) {
+		_, err = stream.CloseAndRecv()
+	}
+	return err
+}
+
+// 上传本地文件到虚拟目录dir，保留文件名、修改时间和权限
+func (c *Client) UploadFile(ctx context.Context, localPath, dir string, tags []string) (*apipb.UploadResponse, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	return c.Upload(ctx, &apipb.UploadHeader{
+		Name:    filepath.Base(localPath),
+		Dir:     dir,
+		Tags:    tags,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Mode:    uint32(info.Mode().Perm()),
+	}, f)
+}
+
+// 下载文件写入w，file为文件ID、文件名或虚拟路径
+func (c *Client) Download(ctx context.Context, file string, w io.Writer) (*apipb.FileInfo, error) {
+	stream, err := c.PanMatrixClient.Download(ctx, &apipb.DownloadRequest{File: file})
+	if err != nil {
+		return nil, err
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	info := first.GetFile()
+	if info == nil {
+		return nil, errors.New("下载响应缺少文件信息")
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return info, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(resp.GetData()); err != nil {
+			return nil, fmt.Errorf("写入文件失败: %v", err)
+		}
+	}
+}
+
+// 每个请求携带令牌和用户
+type requestMetadata struct {
+	token, user string
+	secure      bool
+}
+
+func (m requestMetadata) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	md := make(map[string]string)
+	if m.token != "" {
+		md[authorizationKey] = "Bearer " + m.token
+	}
+	if m.user != "" {
+		md[userKey] = m.user
+	}
+	return md, nil
+}
+
+// 不加密的连接也允许携带令牌，用于本机或unix套接字
+func (m requestMetadata) RequireTransportSecurity() bool {
+	return m.secure
+}
@@ -0,0 +1,468 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm  = "AWS4-HMAC-SHA256"
+	s3TimeFormat = "20060102T150405Z"
+	// 请求时间与服务器时间允许的误差
+	s3MaxSkew = 15 * time.Minute
+	// aws-chunked编码中单块的上限，客户端通常使用64KB到8MB
+	s3MaxChunkSize = 16 << 20
+	// 预签名URL的最长有效期（7天），与S3相同
+	s3MaxPresignExpires = 7 * 24 * 60 * 60
+
+	// x-amz-content-sha256的特殊取值，其他取值为请求体的SHA-256
+	s3UnsignedPayload        = "UNSIGNED-PAYLOAD"
+	s3StreamingPayload       = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	s3StreamingTrailer       = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	s3StreamingUnsignedTrail = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	// 空字符串的SHA-256，分块签名中作为每块的“请求头”哈希
+	s3EmptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3服务的访问密钥（api.s3_keys中的一项），客户端用它进行AWS Signature V4签名
+type S3Key struct {
+	AccessKey  string `yaml:"access_key"`
+	SecretEnv  string `yaml:"secret_env"`  // 从环境变量读取私有密钥
+	SecretFile string `yaml:"secret_file"` // 或从文件读取
+	User       string `yaml:"user"`        // 使用该密钥时操作的用户，为空时为启动时的用户
+}
+
+func (k S3Key) secret() (string, error) {
+	if k.SecretFile != "" {
+		data, err := os.ReadFile(k.SecretFile)
+		if err != nil {
+			return "", fmt.Errorf("读取S3密钥%s失败: %v", k.AccessKey, err)
+		}
+		return string(bytes.TrimRight(data, "\r\n")), nil
+	}
+	secret := os.Getenv(k.SecretEnv)
+	if secret == "" {
+		return "", fmt.Errorf("读取S3密钥%s失败: 环境变量%s为空", k.AccessKey, k.SecretEnv)
+	}
+	return secret, nil
+}
+
+func validateS3Keys(keys []S3Key) error {
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.AccessKey == "" {
+			return errors.New("api.s3_keys中的access_key不能为空")
+		}
+		if seen[k.AccessKey] {
+			return fmt.Errorf("api.s3_keys中的access_key重复: %s", k.AccessKey)
+		}
+		seen[k.AccessKey] = true
+		if (k.SecretEnv == "") == (k.SecretFile == "") {
+			return fmt.Errorf("S3密钥%s需要设置secret_env或secret_file中的一个", k.AccessKey)
+		}
+	}
+	return nil
+}
+
+// S3接口的错误，按S3的XML格式返回给客户端
+type S3Error struct {
+	Status  int
+	Code    string // 如 NoSuchKey、AccessDenied
+	Message string
+}
+
+func (e *S3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// 返回S3格式的错误，err不是*S3Error时为500 InternalError
+func WriteS3Error(w http.ResponseWriter, r *http.Request, err error) {
+	var s3err *S3Error
+	if !errors.As(err, &s3err) {
+		s3err = &S3Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(s3err.Status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName  xml.Name `xml:"Error"`
+		Code     string
+		Message  string
+		Resource string
+	}{Code: s3err.Code, Message: s3err.Message, Resource: r.URL.Path})
+}
+
+type s3UserKey struct{}
+
+// 请求使用的访问密钥对应的用户，密钥没有指定用户时ok为false
+func S3User(ctx context.Context) (user string, ok bool) {
+	user, ok = ctx.Value(s3UserKey{}).(string)
+	return user, ok && user != ""
+}
+
+// 在配置的s3_listen地址监听并创建S3兼容服务，由调用方执行Serve（启用TLS时为ServeTLS）。
+// 设置了s3_keys时请求必须用其中的密钥签名（请求头或预签名URL），请求体已按x-amz-content-sha256
+// 校验或解码，handler读取到的是文件内容。没有配置s3_listen时返回nil
+func ListenS3(cfg Config, handler http.Handler) (*http.Server, net.Listener, error) {
+	if cfg.S3Listen == "" {
+		return nil, nil, nil
+	}
+	secrets := make(map[string]s3Secret, len(cfg.S3Keys))
+	for _, k := range cfg.S3Keys {
+		secret, err := k.secret()
+		if err != nil {
+			return nil, nil, err
+		}
+		secrets[k.AccessKey] = s3Secret{secret: secret, user: k.User}
+	}
+	if len(secrets) == 0 && !strings.HasPrefix(cfg.S3Listen, "unix:") && !isLoopback(cfg.S3Listen) {
+		return nil, nil, fmt.Errorf("api监听非本机地址 %s 时必须设置s3_keys", cfg.S3Listen)
+	}
+	return listenHTTP(cfg, cfg.S3Listen, true, s3Auth(secrets, handler))
+}
+
+type s3Secret struct {
+	secret string
+	user   string
+}
+
+func s3Auth(secrets map[string]s3Secret, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signer, user, err := verifyS3Request(r, secrets)
+		if err == nil {
+			err = decodeS3Body(r, signer)
+		}
+		if err != nil {
+			WriteS3Error(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), s3UserKey{}, user)))
+	})
+}
+
+// 请求的签名信息
+type s3Signer struct {
+	key   []byte // 由私有密钥、日期和区域派生的签名密钥
+	date  string // 请求时间，s3TimeFormat格式
+	scope string // 日期/区域/s3/aws4_request
+
+	prevSignature string // 上一块的签名，第一块之前为请求的签名
+}
+
+func newS3Signer(secret, date, region string) *s3Signer {
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{date[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return &s3Signer{key: key, date: date, scope: date[:8] + "/" + region + "/s3/aws4_request"}
+}
+
+func (s *s3Signer) sign(lines ...string) string {
+	return hex.EncodeToString(hmacSHA256(s.key, strings.Join(lines, "\n")))
+}
+
+func (s *s3Signer) requestSignature(canonicalRequest string) string {
+	return s.sign(s3Algorithm, s.date, s.scope, sha256Hex([]byte(canonicalRequest)))
+}
+
+// aws-chunked编码中每块的签名，链接前一块（第一块为请求）的签名
+func (s *s3Signer) chunkSignature(data []byte) string {
+	return s.sign(s3Algorithm+"-PAYLOAD", s.date, s.scope, s.prevSignature, s3EmptyHash, sha256Hex(data))
+}
+
+// 校验请求的签名，返回需要继续校验分块签名的signer（没有设置密钥时为nil）和密钥对应的用户
+func verifyS3Request(r *http.Request, secrets map[string]s3Secret) (*s3Signer, string, error) {
+	if len(secrets) == 0 {
+		return nil, "", nil
+	}
+	query := r.URL.Query()
+	presigned := query.Has("X-Amz-Signature")
+	authorization := r.Header.Get("Authorization")
+	if !presigned && authorization == "" {
+		return nil, "", &S3Error{http.StatusForbidden, "AccessDenied", "请求没有签名"}
+	}
+
+	var credential, signedHeaders, signature, date, payloadHash string
+	if presigned {
+		if query.Get("X-Amz-Algorithm") != s3Algorithm {
+			return nil, "", &S3Error{http.StatusBadRequest, "AuthorizationQueryParametersError", "只支持" + s3Algorithm + "签名"}
+		}
+		credential, signedHeaders, signature = query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders"), query.Get("X-Amz-Signature")
+		date, payloadHash = query.Get("X-Amz-Date"), s3UnsignedPayload
+	} else {
+		fields, ok := strings.CutPrefix(authorization, s3Algorithm+" ")
+		if !ok {
+			return nil, "", &S3Error{http.StatusBadRequest, "AuthorizationHeaderMalformed", "只支持" + s3Algorithm + "签名"}
+		}
+		for _, field := range strings.Split(fields, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+			switch name {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				signature = value
+			}
+		}
+		date, payloadHash = r.Header.Get("X-Amz-Date"), r.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash == "" {
+			payloadHash = s3UnsignedPayload
+		}
+	}
+
+	// 访问密钥/日期/区域/s3/aws4_request
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" || signedHeaders == "" || signature == "" {
+		return nil, "", &S3Error{http.StatusBadRequest, "AuthorizationHeaderMalformed", "签名信息不完整"}
+	}
+	// 不签名host时签名可以用于其他地址上的服务
+	if !slices.Contains(strings.Split(signedHeaders, ";"), "host") {
+		return nil, "", &S3Error{http.StatusBadRequest, "AuthorizationHeaderMalformed", "签名的请求头必须包括host"}
+	}
+	secret, ok := secrets[parts[0]]
+	if !ok {
+		return nil, "", &S3Error{http.StatusForbidden, "InvalidAccessKeyId", "访问密钥不存在: " + parts[0]}
+	}
+	signedAt, err := time.Parse(s3TimeFormat, date)
+	if err != nil || !strings.HasPrefix(date, parts[1]) {
+		return nil, "", &S3Error{http.StatusForbidden, "AccessDenied", "请求时间无效: " + date}
+	}
+	if presigned {
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || expires < 0 || expires > s3MaxPresignExpires {
+			return nil, "", &S3Error{http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Expires无效，应为0到604800秒"}
+		}
+		if time.Now().After(signedAt.Add(time.Duration(expires) * time.Second)) {
+			return nil, "", &S3Error{http.StatusForbidden, "AccessDenied", "预签名URL已过期"}
+		}
+	} else if skew := time.Since(signedAt); skew > s3MaxSkew || skew < -s3MaxSkew {
+		return nil, "", &S3Error{http.StatusForbidden, "RequestTimeTooSkewed", "请求时间与服务器相差过大"}
+	}
+
+	signer := newS3Signer(secret.secret, date, parts[2])
+	expected := signer.requestSignature(canonicalS3Request(r, strings.Split(signedHeaders, ";"), payloadHash, presigned))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return nil, "", &S3Error{http.StatusForbidden, "SignatureDoesNotMatch", "签名不正确，请检查私有密钥"}
+	}
+	// 后续各块的签名以请求的签名为起点
+	signer.prevSignature = signature
+	return signer, secret.user, nil
+}
+
+func canonicalS3Request(r *http.Request, signedHeaders []string, payloadHash string, presigned bool) string {
+	var query []string
+	for name, values := range r.URL.Query() {
+		if presigned && name == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			query = append(query, s3Escape(name, false)+"="+s3Escape(value, false))
+		}
+	}
+	sort.Strings(query)
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		case "transfer-encoding":
+			value = strings.Join(r.TransferEncoding, ",")
+		default:
+			var values []string
+			for _, v := range r.Header.Values(name) {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		s3Escape(r.URL.Path, true),
+		strings.Join(query, "&"),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// SigV4的URI编码：除字母、数字和-_.~外都编码为%XX，keepSlash为true时保留/
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// 按x-amz-content-sha256替换请求体：签名了内容哈希时读完后校验，aws-chunked编码时解码（signer不为nil时校验各块的签名）
+func decodeS3Body(r *http.Request, signer *s3Signer) error {
+	switch payload := r.Header.Get("X-Amz-Content-Sha256"); payload {
+	case "", s3UnsignedPayload:
+	case s3StreamingPayload, s3StreamingTrailer, s3StreamingUnsignedTrail:
+		if payload == s3StreamingUnsignedTrail {
+			signer = nil
+		}
+		r.ContentLength = -1
+		if size := r.Header.Get("X-Amz-Decoded-Content-Length"); size != "" {
+			n, err := strconv.ParseInt(size, 10, 64)
+			if err != nil || n < 0 {
+				return &S3Error{http.StatusBadRequest, "InvalidArgument", "x-amz-decoded-content-length无效"}
+			}
+			r.ContentLength = n
+		}
+		r.Body = &s3ChunkReader{r: bufio.NewReader(r.Body), body: r.Body, signer: signer}
+	default:
+		if _, err := hex.DecodeString(payload); err != nil || len(payload) != sha256.Size*2 {
+			return &S3Error{http.StatusBadRequest, "InvalidArgument", "x-amz-content-sha256无效"}
+		}
+		r.Body = &s3HashReader{r: r.Body, hash: sha256.New(), want: payload}
+	}
+	return nil
+}
+
+// 读完后与请求头中的SHA-256比较
+type s3HashReader struct {
+	r    io.ReadCloser
+	hash hash.Hash
+	want string
+}
+
+func (h *s3HashReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(h.hash.Sum(nil)) != h.want {
+		return n, &S3Error{http.StatusBadRequest, "XAmzContentSHA256Mismatch", "请求体与x-amz-content-sha256不符"}
+	}
+	return n, err
+}
+
+func (h *s3HashReader) Close() error {
+	return h.r.Close()
+}
+
+// aws-chunked编码的请求体，每块为
+//
+//	<十六进制长度>[;chunk-signature=<签名>]\r\n<数据>\r\n
+//
+// 最后是长度为0的块，之后可能有校验和等附加字段，以空行结束
+type s3ChunkReader struct {
+	r      *bufio.Reader
+	body   io.Closer
+	signer *s3Signer // 为nil时不校验各块的签名
+
+	chunk []byte // 当前块中尚未读取的部分
+	err   error
+}
+
+func (c *s3ChunkReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.chunk, c.err = c.next()
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+func (c *s3ChunkReader) Close() error {
+	return c.body.Close()
+}
+
+func (c *s3ChunkReader) next() ([]byte, error) {
+	header, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	sizeField, extension, _ := strings.Cut(header, ";")
+	size, err := strconv.ParseInt(sizeField, 16, 64)
+	if err != nil || size < 0 || size > s3MaxChunkSize {
+		return nil, &S3Error{http.StatusBadRequest, "IncompleteBody", "aws-chunked块长度无效: " + header}
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, &S3Error{http.StatusBadRequest, "IncompleteBody", "请求体不完整"}
+	}
+	if c.signer != nil {
+		signature, _ := strings.CutPrefix(extension, "chunk-signature=")
+		expected := c.signer.chunkSignature(data)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+			return nil, &S3Error{http.StatusForbidden, "SignatureDoesNotMatch", "aws-chunked块的签名不正确"}
+		}
+		c.signer.prevSignature = signature
+	}
+
+	if size == 0 {
+		// 附加字段（如x-amz-checksum-crc32）只用于传输校验，不需要保存
+		for {
+			line, err := c.readLine()
+			if err != nil || line == "" {
+				return nil, io.EOF
+			}
+		}
+	}
+	if line, err := c.readLine(); err != nil || line != "" {
+		return nil, &S3Error{http.StatusBadRequest, "IncompleteBody", "aws-chunked块之后缺少换行"}
+	}
+	return data, nil
+}
+
+func (c *s3ChunkReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		if err == bufio.ErrBufferFull {
+			err = &S3Error{http.StatusBadRequest, "IncompleteBody", "aws-chunked块头过长"}
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"panmatrix/api/apipb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// 请求metadata中的令牌和用户，见 apipb/panmatrix.proto
+	authorizationKey = "authorization"
+	userKey          = "x-panmatrix-user"
+
+	defaultTokenEnv = "PANMATRIX_API_TOKEN"
+
+	// 下载时每条消息携带的文件内容，上传时客户端也按此分段
+	ChunkSize = 1 << 20
+	// 单条消息的上限，留出消息头的余量
+	maxMessageSize = ChunkSize + 64*1024
+)
+
+// gRPC接口配置（config.yaml的api段）：
+//
+//	api:
+//	  listen: 127.0.0.1:7070                  # 或 unix:/run/panmatrix.sock，为空时不提供接口
+//	  token_env: PANMATRIX_API_TOKEN          # 从环境变量读取令牌（默认）
+//	  token_file: /etc/panmatrix/api.token    # 或从文件读取
+//	  cert_file: /etc/panmatrix/api.crt       # 设置后使用TLS
+//	  key_file: /etc/panmatrix/api.key
+//	  web_listen: 127.0.0.1:7080              # Web界面，为空时不提供，令牌和证书与gRPC接口共用
+//	  webdav_listen: 127.0.0.1:7081           # WebDAV服务，同上
+//	  s3_listen: 127.0.0.1:7082               # S3兼容服务，证书与gRPC接口共用，使用自己的访问密钥
+//	  s3_keys:
+//	    - access_key: backup
+//	      secret_env: PANMATRIX_S3_SECRET     # 或 secret_file
+//	      user: alice                         # 使用该密钥时操作的用户，为空时为启动时的用户
+//
+// 监听非本机地址时必须设置令牌
+type Config struct {
+	Listen       string  `yaml:"listen"`
+	WebListen    string  `yaml:"web_listen"`
+	WebDAVListen string  `yaml:"webdav_listen"`
+	S3Listen     string  `yaml:"s3_listen"`
+	S3Keys       []S3Key `yaml:"s3_keys"`
+	TokenEnv     string  `yaml:"token_env"`
+	TokenFile    string  `yaml:"token_file"`
+	CertFile     string  `yaml:"cert_file"`
+	KeyFile      string  `yaml:"key_file"`
+}
+
+// 读取配置文件中的api段，没有该段时不提供接口
+func LoadConfig(path string) (Config, error) {
+	var file struct {
+		API Config `yaml:"api"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file.API, err
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file.API, fmt.Errorf("解析api配置失败: %v", err)
+	}
+	if (file.API.CertFile == "") != (file.API.KeyFile == "") {
+		return file.API, errors.New("api的cert_file和key_file需要同时设置")
+	}
+	if err := validateS3Keys(file.API.S3Keys); err != nil {
+		return file.API, err
+	}
+	if file.API.TokenEnv == "" {
+		file.API.TokenEnv = defaultTokenEnv
+	}
+	return file.API, nil
+}
+
+func (c Config) token() (string, error) {
+	if c.TokenFile != "" {
+		data, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("读取api令牌失败: %v", err)
+		}
+		return string(bytes.TrimRight(data, "\r\n")), nil
+	}
+	return os.Getenv(c.TokenEnv), nil
+}
+
+// 在配置的地址监听并创建注册了service的gRPC服务，由调用方执行Serve。
+// 没有配置listen时返回nil
+func Listen(cfg Config, service apipb.PanMatrixServer) (*grpc.Server, net.Listener, error) {
+	if cfg.Listen == "" {
+		return nil, nil, nil
+	}
+	token, err := cfg.token()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := authenticate(ctx, token); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := authenticate(ss.Context(), token); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("加载api证书失败: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	}
+
+	listener, err := listen(cfg.Listen, token != "")
+	if err != nil {
+		return nil, nil, err
+	}
+	server := grpc.NewServer(opts...)
+	apipb.RegisterPanMatrixServer(server, service)
+	return server, listener, nil
+}
+
+// 监听 host:port 或 unix:/path，secured为false（没有设置令牌）时只允许本机地址
+func listen(address string, secured bool) (net.Listener, error) {
+	network := "tcp"
+	if strings.HasPrefix(address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+		// 上次异常退出留下的套接字文件
+		os.Remove(address)
+	} else if !secured && !isLoopback(address) {
+		return nil, fmt.Errorf("api监听非本机地址 %s 时必须设置令牌", address)
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("api监听失败: %v", err)
+	}
+	return listener, nil
+}
+
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// 检查请求携带的令牌，服务端没有设置令牌时不检查
+func authenticate(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(authorizationKey) {
+		given, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "api令牌无效")
+}
+
+// 请求指定的用户，没有指定时ok为false
+func User(ctx context.Context) (user string, ok bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(userKey); len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
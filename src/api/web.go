@@ -0,0 +1,107 @@
+package api
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Web界面的页面和脚本，随程序一起编译
+//
+//go:embed web
+var webAssets embed.FS
+
+// 修改数据的请求必须携带该请求头。浏览器跨站提交的表单无法设置它，
+// 这样即使浏览器记住了登录信息，其他网站也不能借此删除或上传文件
+const webRequestHeader = "X-PanMatrix-Request"
+
+// 在配置的web_listen地址监听并创建Web界面的HTTP服务，由调用方执行Serve（启用TLS时为ServeTLS，证书已加载）。
+// /api/ 下的请求交给handler，其余路径为界面的静态文件。没有配置web_listen时返回nil
+//
+// 设置了令牌时使用HTTP Basic认证，用户名任意，密码为令牌
+func ListenWeb(cfg Config, handler http.Handler) (*http.Server, net.Listener, error) {
+	if cfg.WebListen == "" {
+		return nil, nil, nil
+	}
+	token, err := cfg.token()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assets, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		return nil, nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/api/", handler)
+	mux.Handle("/", http.FileServer(http.FS(assets)))
+	return listenHTTP(cfg, cfg.WebListen, token != "", webAuth(token, mux))
+}
+
+// 在配置的webdav_listen地址监听并创建WebDAV服务，由调用方执行Serve（启用TLS时为ServeTLS）。
+// 认证方式与Web界面相同，但WebDAV客户端不会携带自定义请求头，不检查X-PanMatrix-Request。
+// 没有配置webdav_listen时返回nil
+func ListenWebDAV(cfg Config, handler http.Handler) (*http.Server, net.Listener, error) {
+	if cfg.WebDAVListen == "" {
+		return nil, nil, nil
+	}
+	token, err := cfg.token()
+	if err != nil {
+		return nil, nil, err
+	}
+	return listenHTTP(cfg, cfg.WebDAVListen, token != "", basicAuth(token, handler))
+}
+
+func listenHTTP(cfg Config, address string, secured bool, handler http.Handler) (*http.Server, net.Listener, error) {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("加载api证书失败: %v", err)
+		}
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	listener, err := listen(address, secured)
+	if err != nil {
+		return nil, nil, err
+	}
+	return server, listener, nil
+}
+
+// 设置了令牌时要求HTTP Basic认证，用户名任意，密码为令牌
+func basicAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			_, given, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="PanMatrix", charset="UTF-8"`)
+				http.Error(w, "api令牌无效", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func webAuth(token string, next http.Handler) http.Handler {
+	return basicAuth(token, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Header.Get(webRequestHeader) == "" {
+			http.Error(w, "缺少请求头 "+webRequestHeader, http.StatusForbidden)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,420 @@
+'use strict';
+
+// 接口说明见 webui.go 中的 newWebHandler。int64字段在JSON中是字符串，时间为Unix纳秒
+
+const PAGE_SIZE = 100;
+const DRIVER_REFRESH = 5000;
+const JOB_REFRESH = 2000;
+
+const state = {
+  dir: '/',
+  query: '',
+  sort: 'name',
+  desc: false,
+  offset: 0,
+  next: -1,
+};
+
+const $ = (id) => document.getElementById(id);
+
+// 创建元素，children可以是字符串或元素
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === 'onclick') {
+      node.addEventListener('click', value);
+    } else if (key === 'class') {
+      node.className = value;
+    } else {
+      node.setAttribute(key, value);
+    }
+  }
+  for (const child of children) {
+    if (child !== null && child !== undefined) {
+      node.append(child);
+    }
+  }
+  return node;
+}
+
+async function api(method, url) {
+  const resp = await fetch(url, { method, headers: { 'X-PanMatrix-Request': '1' } });
+  const body = await resp.json().catch(() => ({ error: resp.statusText }));
+  if (!resp.ok) {
+    throw new Error(body.error || resp.statusText);
+  }
+  return body;
+}
+
+function query(params) {
+  const q = new URLSearchParams();
+  for (const [key, value] of Object.entries(params)) {
+    if (value !== '' && value !== undefined && value !== null) {
+      q.set(key, value);
+    }
+  }
+  return q.toString();
+}
+
+function showError(err) {
+  const box = $('message');
+  box.textContent = err.message || String(err);
+  box.hidden = false;
+}
+
+function formatSize(n) {
+  n = Number(n);
+  const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return (i === 0 ? n : n.toFixed(1)) + ' ' + units[i];
+}
+
+function formatTime(ns) {
+  const ms = Number(ns) / 1e6;
+  return ms ? new Date(ms).toLocaleString() : '';
+}
+
+function formatDuration(ns) {
+  const ms = Number(ns) / 1e6;
+  if (!ms) {
+    return '-';
+  }
+  return ms < 1000 ? ms.toFixed(0) + 'ms' : (ms / 1000).toFixed(1) + 's';
+}
+
+function badge(text) {
+  return el('span', { class: 'state ' + text }, text || '未知');
+}
+
+// done/total的进度条，total为0时显示不确定的进度
+function progressBar(done, total) {
+  done = Number(done);
+  total = Number(total);
+  if (!total) {
+    return el('span', { class: 'bar indeterminate' }, el('span'));
+  }
+  const percent = Math.min(100, (done / total) * 100);
+  return el('span', {}, el('span', { class: 'bar' }, el('span', { style: 'width:' + percent + '%' })), percent.toFixed(0) + '%');
+}
+
+function joinPath(dir, name) {
+  return (dir === '/' ? '' : dir) + '/' + name;
+}
+
+// 文件
+
+async function loadFiles() {
+  const params = { sort: state.sort, desc: state.desc ? 1 : '', offset: state.offset, limit: PAGE_SIZE };
+  let resp;
+  try {
+    if (state.query) {
+      resp = await api('GET', '/api/search?' + query({ q: state.query, ...params }));
+    } else {
+      resp = await api('GET', '/api/files?' + query({ dir: state.dir, ...params }));
+    }
+  } catch (err) {
+    showError(err);
+    return;
+  }
+  state.next = resp.next_offset;
+  renderBreadcrumb();
+
+  const list = $('file-list');
+  list.replaceChildren();
+  for (const dir of resp.dirs) {
+    const name = dir.split('/').pop();
+    list.append(el('tr', {},
+      el('td', {}, el('a', { onclick: () => openDir(dir) }, '📁 ' + name)),
+      el('td'), el('td'), el('td'), el('td'), el('td')));
+  }
+  for (const file of resp.files) {
+    const href = '/api/download?' + query({ file: file.file_id });
+    list.append(el('tr', {},
+      el('td', { title: file.path }, state.query ? file.path : file.name),
+      el('td', { class: 'num' }, formatSize(file.size)),
+      el('td', {}, 'RAID' + file.raid_level),
+      el('td', {}, badge(file.state)),
+      el('td', {}, formatTime(file.updated)),
+      el('td', { class: 'actions' },
+        file.state === 'committed' ? el('a', { href, download: file.name }, '下载') : null,
+        el('a', { onclick: () => deleteFile(file) }, '删除'))));
+  }
+  if (!resp.dirs.length && !resp.files.length) {
+    list.append(el('tr', {}, el('td', { class: 'empty', colspan: 6 }, state.query ? '没有匹配的文件' : '目录为空')));
+  }
+
+  $('file-total').textContent = '共 ' + resp.total + ' 个文件';
+  $('prev-page').disabled = state.offset === 0;
+  $('next-page').disabled = state.next < 0;
+}
+
+function renderBreadcrumb() {
+  const crumb = $('breadcrumb');
+  crumb.replaceChildren();
+  if (state.query) {
+    crumb.append('搜索: ' + state.query + ' ', el('a', { onclick: () => openDir(state.dir) }, '返回目录'));
+    return;
+  }
+  crumb.append(el('a', { onclick: () => openDir('/') }, '根目录'));
+  let current = '';
+  for (const part of state.dir.split('/').filter(Boolean)) {
+    current += '/' + part;
+    const target = current;
+    crumb.append(' / ', el('a', { onclick: () => openDir(target) }, part));
+  }
+}
+
+function openDir(dir) {
+  state.dir = dir;
+  state.query = '';
+  state.offset = 0;
+  $('search').value = '';
+  loadFiles();
+}
+
+async function deleteFile(file) {
+  if (!confirm('删除 ' + file.path + '？')) {
+    return;
+  }
+  try {
+    await api('DELETE', '/api/files?' + query({ file: file.file_id }));
+  } catch (err) {
+    showError(err);
+  }
+  loadFiles();
+}
+
+async function mkdir() {
+  const name = prompt('目录名');
+  if (!name) {
+    return;
+  }
+  try {
+    await api('POST', '/api/mkdir?' + query({ path: joinPath(state.dir, name) }));
+  } catch (err) {
+    showError(err);
+  }
+  loadFiles();
+}
+
+// 上传
+
+function uploadFiles(files) {
+  const dir = state.dir;
+  // 依次上传，服务端按文件大小排队
+  let chain = Promise.resolve();
+  for (const file of Array.from(files)) {
+    chain = chain.then(() => uploadFile(file, dir)).then(() => {
+      if (!state.query && state.dir === dir) {
+        loadFiles();
+      }
+    });
+  }
+}
+
+function uploadFile(file, dir) {
+  const bar = el('span', { class: 'bar' }, el('span', { style: 'width:0' }));
+  const label = el('span', {}, '等待上传');
+  const row = el('div', { class: 'upload' }, el('span', {}, joinPath(dir, file.name)), bar, label);
+  $('uploads').append(row);
+
+  return new Promise((resolve) => {
+    const xhr = new XMLHttpRequest();
+    xhr.open('PUT', '/api/upload?' + query({ dir, name: file.name, mtime: file.lastModified }));
+    xhr.setRequestHeader('X-PanMatrix-Request', '1');
+    xhr.upload.onprogress = (e) => {
+      if (e.lengthComputable) {
+        const percent = (e.loaded / e.total) * 100;
+        bar.firstChild.style.width = percent + '%';
+        label.textContent = percent < 100 ? percent.toFixed(0) + '%' : '写入中…';
+      }
+    };
+    xhr.onload = () => {
+      if (xhr.status === 200) {
+        row.remove();
+      } else {
+        let message = xhr.statusText;
+        try {
+          message = JSON.parse(xhr.responseText).error;
+        } catch (e) {}
+        row.classList.add('error');
+        label.textContent = '上传失败: ' + message;
+        row.addEventListener('click', () => row.remove());
+      }
+      resolve();
+    };
+    xhr.onerror = () => {
+      row.classList.add('error');
+      label.textContent = '上传失败: 连接中断';
+      row.addEventListener('click', () => row.remove());
+      resolve();
+    };
+    xhr.send(file);
+  });
+}
+
+function setupDrop() {
+  const zone = $('drop-zone');
+  let depth = 0;
+  zone.addEventListener('dragenter', (e) => {
+    e.preventDefault();
+    depth++;
+    zone.classList.add('dragging');
+  });
+  zone.addEventListener('dragover', (e) => e.preventDefault());
+  zone.addEventListener('dragleave', () => {
+    if (--depth === 0) {
+      zone.classList.remove('dragging');
+    }
+  });
+  zone.addEventListener('drop', (e) => {
+    e.preventDefault();
+    depth = 0;
+    zone.classList.remove('dragging');
+    uploadFiles(e.dataTransfer.files);
+  });
+}
+
+// 驱动器
+
+async function loadDrivers() {
+  let resp;
+  try {
+    resp = await api('GET', '/api/drivers');
+  } catch (err) {
+    showError(err);
+    return;
+  }
+  const list = $('driver-list');
+  list.replaceChildren();
+  for (const d of resp.drivers) {
+    const total = Number(d.total);
+    const usage = d.error
+      ? el('span', { title: d.error }, '读取失败')
+      : el('span', {}, total ? progressBar(d.used, d.total) : null, ' ' + formatSize(d.used) + ' / ' + (total ? formatSize(total) : '不限'));
+    list.append(el('tr', {},
+      el('td', {}, d.name),
+      el('td', {}, d.type),
+      el('td', {}, badge(d.state)),
+      el('td', {}, usage),
+      el('td', { class: 'num' }, (d.success_rate * 100).toFixed(1) + '%'),
+      el('td', { class: 'num' }, formatDuration(d.latency_p50) + ' / ' + formatDuration(d.latency_p95)),
+      el('td', { class: 'num' }, d.current_load + ' / ' + d.max_load),
+      el('td', { class: 'num' }, d.upload_bandwidth ? formatSize(d.upload_bandwidth) + '/s' : '-'),
+      el('td', {}, formatTime(d.last_error))));
+  }
+}
+
+// 任务
+
+async function loadJobs() {
+  let resp;
+  try {
+    resp = await api('GET', '/api/jobs');
+  } catch (err) {
+    showError(err);
+    return;
+  }
+  const list = $('job-list');
+  list.replaceChildren();
+  for (const job of resp.jobs) {
+    const finished = ['succeeded', 'failed', 'canceled'].includes(job.state);
+    const actions = el('td', { class: 'actions' });
+    if (job.state === 'running') {
+      actions.append(el('a', { onclick: () => controlJob(job.id, 'pause') }, '暂停'));
+    } else if (job.state === 'paused') {
+      actions.append(el('a', { onclick: () => controlJob(job.id, 'resume') }, '继续'));
+    }
+    if (!finished) {
+      actions.append(el('a', { onclick: () => controlJob(job.id, 'cancel') }, '取消'));
+    }
+    list.append(el('tr', {},
+      el('td', {}, job.id),
+      el('td', {}, job.type),
+      el('td', {}, badge(job.state)),
+      el('td', {}, job.state === 'succeeded' ? progressBar(1, 1) : finished ? null : progressBar(job.done, job.total)),
+      el('td', { class: 'message' }, job.error || job.result || job.message),
+      el('td', {}, formatTime(job.updated)),
+      actions));
+  }
+  if (!resp.jobs.length) {
+    list.append(el('tr', {}, el('td', { class: 'empty', colspan: 7 }, '没有任务')));
+  }
+}
+
+async function controlJob(id, action) {
+  try {
+    await api('POST', '/api/jobs/' + encodeURIComponent(id) + '/' + action);
+  } catch (err) {
+    showError(err);
+  }
+  loadJobs();
+}
+
+// 页面
+
+let timer = null;
+
+function showView(view) {
+  for (const tab of document.querySelectorAll('.tab')) {
+    tab.classList.toggle('active', tab.dataset.view === view);
+  }
+  for (const section of document.querySelectorAll('.view')) {
+    section.classList.toggle('active', section.id === view);
+  }
+  clearInterval(timer);
+  timer = null;
+  if (view === 'files') {
+    loadFiles();
+  } else if (view === 'drivers') {
+    loadDrivers();
+    timer = setInterval(loadDrivers, DRIVER_REFRESH);
+  } else if (view === 'jobs') {
+    loadJobs();
+    timer = setInterval(loadJobs, JOB_REFRESH);
+  }
+}
+
+function init() {
+  for (const tab of document.querySelectorAll('.tab')) {
+    tab.addEventListener('click', () => showView(tab.dataset.view));
+  }
+  for (const th of document.querySelectorAll('th[data-sort]')) {
+    th.addEventListener('click', () => {
+      state.desc = state.sort === th.dataset.sort ? !state.desc : false;
+      state.sort = th.dataset.sort;
+      state.offset = 0;
+      loadFiles();
+    });
+  }
+  $('search-form').addEventListener('submit', (e) => {
+    e.preventDefault();
+    state.query = $('search').value.trim();
+    state.offset = 0;
+    loadFiles();
+  });
+  $('mkdir').addEventListener('click', mkdir);
+  $('file-input').addEventListener('change', (e) => {
+    uploadFiles(e.target.files);
+    e.target.value = '';
+  });
+  $('prev-page').addEventListener('click', () => {
+    state.offset = Math.max(0, state.offset - PAGE_SIZE);
+    loadFiles();
+  });
+  $('next-page').addEventListener('click', () => {
+    state.offset = state.next;
+    loadFiles();
+  });
+  $('message').addEventListener('click', () => {
+    $('message').hidden = true;
+  });
+  setupDrop();
+  showView('files');
+}
+
+init();
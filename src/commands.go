@@ -0,0 +1,736 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"panmatrix/api"
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+	"panmatrix/scheduler"
+)
+
+// 命令的位置参数不对，显示该命令的用法
+var errUsage = errors.New("参数错误")
+
+// 命令行的子命令，如 panmatrix-raid upload -raid 5 ./photos
+type command struct {
+	name        string
+	args        string // 位置参数，用于帮助
+	summary     string
+	failure     string // 出错时的提示
+	interactive bool   // 自己处理Ctrl+C，只取消正在执行的操作
+
+	// 注册命令的参数，返回执行函数
+	setup func(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error
+}
+
+// 没有参数的命令
+func noFlags(run func(ctx context.Context, e *env, args []string) error) func(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	return func(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+		return run
+	}
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		// 文件
+		{name: "upload", args: "<本地路径>", summary: "上传文件或目录（目录按原有结构上传）", failure: "上传失败", setup: setupUpload},
+		{name: "download", args: "<文件ID|路径>", summary: "下载文件，也可以使用上传时的文件名", failure: "下载失败", setup: setupDownload},
+		{name: "ls", args: "[虚拟目录]", summary: "列出文件，指定目录时先列出其中的子目录", failure: "列出文件失败", setup: setupList},
+		{name: "search", args: "<查询>", summary: "搜索文件，如 \"report tag:work size:>10M after:2024-01-01 raid:5\"", failure: "搜索文件失败", setup: setupSearch},
+		{name: "rm", args: "<文件ID|路径>", summary: "删除文件（移入回收站；回收站中的文件ID则永久删除）", failure: "删除失败", setup: noFlags(runRemove)},
+		{name: "trash", summary: "列出回收站中的文件", failure: "列出回收站失败", setup: noFlags(runTrash)},
+		{name: "untrash", args: "<文件ID>", summary: "从回收站恢复文件", failure: "恢复文件失败", setup: noFlags(runUntrash)},
+		{name: "empty-trash", summary: "永久删除回收站中的所有文件", failure: "清空回收站失败", setup: noFlags(runEmptyTrash)},
+		{name: "uploads", summary: "列出进行中和中断后可续传的上传", failure: "列出上传失败", setup: noFlags(runUploads)},
+		{name: "mkdir", args: "<虚拟目录>", summary: "创建虚拟目录（包括不存在的上级目录）", failure: "创建目录失败", setup: noFlags(runMkdir)},
+		{name: "rmdir", args: "<虚拟目录>", summary: "删除空的虚拟目录", failure: "删除目录失败", setup: noFlags(runRmdir)},
+		{name: "tag", args: "<文件> <标签>...", summary: "为文件（文件ID、文件名或路径）添加标签", failure: "修改标签失败", setup: noFlags(runTagging(false))},
+		{name: "untag", args: "<文件> <标签>...", summary: "删除文件的标签", failure: "修改标签失败", setup: noFlags(runTagging(true))},
+		{name: "tags", summary: "列出所有标签及其文件数", failure: "列出标签失败", setup: noFlags(runTags)},
+		{name: "versions", args: "<路径>", summary: "列出路径上文件的所有版本", failure: "列出版本失败", setup: noFlags(runVersions)},
+		{name: "restore-version", args: "<路径> <版本号>", summary: "将路径恢复为旧版本", failure: "恢复版本失败", setup: noFlags(runRestoreVersion)},
+		{name: "prune-versions", summary: "按保留策略删除过期的旧版本", failure: "清理旧版本失败", setup: noFlags(runPruneVersions)},
+		{name: "restore", args: "<文件ID|文件名>", summary: "提交文件在归档驱动器上的条带块的恢复请求", failure: "提交恢复请求失败", setup: noFlags(runRestore)},
+
+		// 驱动器和调度
+		{name: "status", summary: "每个驱动器最近的成功率、耗时分位数（p50/p95/p99）、进行中的传输和带宽", failure: "查询状态失败", setup: noFlags(runStatus)},
+		{name: "drivers", summary: "驱动器的类型、状态和容量", failure: "列出驱动器失败", setup: noFlags(runDrivers)},
+		{name: "capacity", summary: "每个驱动器上PanMatrix放置的数据量、容量预算和预计用完的时间", failure: "统计驱动器容量失败", setup: noFlags(runCapacity)},
+		{name: "cost", summary: "按scheduler段的费用参数估算每个驱动器每月的费用", failure: "估算费用失败", setup: noFlags(runCost)},
+		{name: "probe", summary: "立即上传下载探测对象，测量每个驱动器的实际延迟和带宽", failure: "探测失败", setup: noFlags(runProbe)},
+		{name: "explain", args: "<大小MB>", summary: "不上传，显示写入该大小的文件时会选择哪些驱动器以及其余驱动器不被选择的原因", failure: "说明调度决策失败", setup: setupExplain},
+		{name: "drain", args: "<驱动器>", summary: "排空驱动器：不再放置新数据，读取不受影响，之后可用 evacuate 迁空", failure: "设置排空失败", setup: noFlags(runDraining(true))},
+		{name: "undrain", args: "<驱动器>", summary: "取消排空驱动器", failure: "设置排空失败", setup: noFlags(runDraining(false))},
+		{name: "login", args: "<驱动器>", summary: "交互登录驱动器（设备码/扫码），令牌保存到元数据目录", failure: "登录失败", setup: noFlags(runLogin)},
+		{name: "vault", args: "list | set <驱动器> | delete <驱动器>", summary: "管理凭据保险库，set从标准输入读取 字段=值 行", failure: "凭据保险库操作失败", setup: noFlags(runVault)},
+
+		// 后台任务
+		{name: "evacuate", args: "<驱动器>", summary: "迁空驱动器上的所有数据，以便安全移除", failure: "迁空驱动器失败", setup: setupEvacuate},
+		{name: "restripe", summary: "扩容后将已有文件迁移到新的条带宽度", failure: "重新条带化失败", setup: setupRestripe},
+		{name: "check-meta", summary: "检查元数据中的每个条带块是否存在于驱动器上，列出记录已过期或损坏的块", failure: "检查元数据失败", setup: setupCheckMeta},
+		{name: "rebuild-metadata", summary: "扫描所有驱动器上的条带块，重建丢失的文件元数据", failure: "重建元数据失败", setup: setupRebuildMetadata},
+		{name: "gc", summary: "清理过期的回收站文件和旧版本", failure: "清理失败", setup: setupGC},
+		{name: "jobs", args: "[任务ID | run | cancel|pause|resume <任务ID>]", summary: "列出、查看、执行或控制后台任务", failure: "后台任务操作失败", setup: setupJobs},
+
+		// 元数据
+		{name: "metadata", args: "backup | backups | restore <备份名称|latest> | bootstrap", summary: "备份元数据、列出备份、回滚到备份或从副本驱动器恢复", failure: "元数据操作失败", setup: noFlags(runMetadata)},
+		{name: "check-refs", summary: "按存储的元数据重新统计块的引用计数，检查是否与维护的计数一致", failure: "检查引用计数失败", setup: setupCheckRefs},
+		{name: "events", summary: "持续输出元数据变更事件（每行一个JSON对象），直到按Ctrl+C", failure: "订阅事件失败", setup: setupEvents},
+		{name: "audit", summary: "查询操作审计日志（上传、下载、删除、重建和驱动器变更）", failure: "查询审计日志失败", setup: setupAudit},
+		{name: "users", summary: "列出各用户的文件数、用量和配额", failure: "列出用户失败", setup: noFlags(runUsers)},
+
+		// 运行
+		{name: "shell", summary: "交互式命令行（不指定命令时的默认值）", failure: "交互式命令行出错", interactive: true, setup: setupShell},
+		{name: "serve", summary: "常驻运行：清理过期文件、备份元数据、执行提交的后台任务并提供gRPC接口、Web界面、WebDAV和S3兼容服务，直到按Ctrl+C", failure: "运行失败", setup: setupServe},
+		{name: "mount", args: "<挂载点>", summary: "将文件挂载为本地目录（FUSE），普通程序可以直接读写，直到按Ctrl+C或卸载", failure: "挂载失败", setup: setupMount},
+		{name: "config", args: "check", summary: "检查配置文件", failure: "配置无效", setup: noFlags(runConfig)},
+		{name: "help", args: "[命令]", summary: "显示命令列表或命令的参数", setup: noFlags(runHelp)},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// 解析命令的参数，参数可以写在位置参数之后；"--"之后的都是位置参数
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...)
+		}
+		if len(rest) == 0 {
+			return positional
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func printUsage(global *flag.FlagSet) {
+	out := global.Output()
+	fmt.Fprintf(out, "用法: panmatrix-raid [全局参数] <命令> [参数]\n\n命令:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-18s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\n全局参数:\n")
+	global.PrintDefaults()
+	fmt.Fprintf(out, "\n用 \"panmatrix-raid help <命令>\" 查看命令的参数\n")
+}
+
+func printCommandUsage(fs *flag.FlagSet, cmd *command) {
+	out := fs.Output()
+	fmt.Fprintf(out, "用法: panmatrix-raid [全局参数] %s", cmd.name)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprint(out, " [参数]")
+	}
+	if cmd.args != "" {
+		fmt.Fprintf(out, " %s", cmd.args)
+	}
+	fmt.Fprintf(out, "\n\n%s\n", cmd.summary)
+	if hasFlags {
+		fmt.Fprintf(out, "\n参数:\n")
+		fs.PrintDefaults()
+	}
+}
+
+// 显示命令的参数，没有指定命令时由main显示命令列表
+func runHelp(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return fmt.Errorf("未知的命令: %s", args[0])
+	}
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	cmd.setup(fs)
+	printCommandUsage(fs, cmd)
+	return nil
+}
+
+func setupUpload(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	dir := fs.String("dir", "/", "上传到的虚拟目录")
+	raidLevel := fs.Int("raid", 0, "RAID级别 (0, 1, 5, 10)")
+	tags := fs.String("tags", "", "标签（逗号分隔）")
+	sparse := fs.Bool("sparse", true, "全零条带记录为空洞，不上传数据（适合磁盘镜像、虚拟机文件）")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		e.open(openOptions{RAIDLevel: *raidLevel, Dense: !*sparse})
+		if err := handleUploadPath(ctx, e.rc, e.mm, e.ns, e.rs, args[0], *dir, splitTags(*tags), *raidLevel); err != nil {
+			return err
+		}
+		purgeExpired(ctx, e.rc, e.mm)
+		if e.hybrid.Enabled {
+			evictLocalCopies(ctx, e.rc, e.mm)
+		}
+		return nil
+	}
+}
+
+func setupDownload(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	output := fs.String("output", "./download", "下载文件输出路径")
+	version := fs.Int("version", 0, "下载路径上文件的指定旧版本")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		e.open(openOptions{})
+		fileID := args[0]
+		if *version > 0 {
+			old, err := e.ns.GetVersion(fileID, *version)
+			if err != nil {
+				return err
+			}
+			fileID = old.FileID
+		}
+		return handleDownload(ctx, e.rc, e.mm, e.ns, e.rs, fileID, *output)
+	}
+}
+
+// ls和search共用的列表参数
+type listFlags struct {
+	match    *string
+	levels   *string
+	sortBy   *string
+	desc     *bool
+	page     *int
+	pageSize *int
+}
+
+func addListFlags(fs *flag.FlagSet) listFlags {
+	return listFlags{
+		match:    fs.String("match", "", "只列出文件名匹配该通配符的文件（如 *.iso）"),
+		levels:   fs.String("raid", "", "只列出指定RAID级别的文件（逗号分隔，如 1,5）"),
+		sortBy:   fs.String("sort", "name", "排序字段 (name, size, created, updated)"),
+		desc:     fs.Bool("desc", false, "降序排列"),
+		page:     fs.Int("page", 1, "页码"),
+		pageSize: fs.Int("page-size", 50, "每页文件数，0表示不分页"),
+	}
+}
+
+func (f listFlags) list(e *env, dir, search string) error {
+	opts := metadata.ListOptions{
+		Dir:     dir,
+		Pattern: *f.match,
+		SortBy:  *f.sortBy,
+		Desc:    *f.desc,
+		Limit:   *f.pageSize,
+	}
+	return handleList(e.ns, opts, *f.levels, *f.page, search)
+}
+
+func setupList(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	flags := addListFlags(fs)
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) > 1 {
+			return errUsage
+		}
+		var dir string
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		e.open(openOptions{})
+		return flags.list(e, dir, "")
+	}
+}
+
+func setupSearch(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	dir := fs.String("dir", "", "只搜索该虚拟目录")
+	flags := addListFlags(fs)
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) == 0 {
+			return errUsage
+		}
+		e.open(openOptions{})
+		return flags.list(e, *dir, strings.Join(args, " "))
+	}
+}
+
+func runRemove(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	e.open(openOptions{})
+	if err := handleDelete(ctx, e.rc, e.mm, e.ns, args[0]); err != nil {
+		return err
+	}
+	purgeExpired(ctx, e.rc, e.mm)
+	return nil
+}
+
+func runTrash(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	for _, fm := range e.ns.ListTrash() {
+		fmt.Printf("%-46s %12d  删除于 %s  %s\n", fm.FileID, fm.FileSize,
+			fm.TrashedAt.Format("2006-01-02 15:04:05"), fm.Path())
+	}
+	return nil
+}
+
+func runUntrash(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	e.open(openOptions{})
+	restored, err := e.ns.RestoreFromTrash(args[0])
+	entry := metadata.AuditEntry{Op: metadata.AuditUntrash, Target: args[0]}
+	if restored != nil {
+		entry.Target, entry.FileID, entry.Bytes = restored.Path(), restored.FileID, restored.FileSize
+	}
+	recordAudit(e.mm.Audit(), entry, err)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已恢复: %s (%s)\n", restored.Path(), restored.FileID)
+	return nil
+}
+
+func runEmptyTrash(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	return purgeFiles(ctx, e.rc, e.mm, e.ns.ListTrash(), "回收站中的文件")
+}
+
+func runUploads(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	return handleListUploads(e.mm)
+}
+
+func runMkdir(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	e.open(openOptions{})
+	return e.ns.Mkdir(args[0], true)
+}
+
+func runRmdir(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	e.open(openOptions{})
+	return e.ns.Rmdir(args[0])
+}
+
+// remove为false时是tag，为true时是untag
+func runTagging(remove bool) func(ctx context.Context, e *env, args []string) error {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) < 2 {
+			return errUsage
+		}
+		e.open(openOptions{})
+		tags := strings.Join(args[1:], ",")
+		if remove {
+			return handleTags(e.mm, e.ns, "", args[0], tags)
+		}
+		return handleTags(e.mm, e.ns, args[0], "", tags)
+	}
+}
+
+func runTags(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	counts := e.mm.ListTags()
+	names := make([]string, 0, len(counts))
+	for tag := range counts {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	for _, tag := range names {
+		fmt.Printf("%-24s %d\n", tag, counts[tag])
+	}
+	return nil
+}
+
+func runVersions(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	e.open(openOptions{})
+	return handleVersions(e.ns, args[0])
+}
+
+func runRestoreVersion(ctx context.Context, e *env, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	version, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("无效的版本号: %s", args[1])
+	}
+	e.open(openOptions{})
+	restored, err := e.ns.RestoreVersion(args[0], version)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已将 %s 恢复为版本%d (%s)\n", restored.Path(), restored.Version, restored.FileID)
+	return nil
+}
+
+func runPruneVersions(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	return handlePruneVersions(ctx, e.rc, e.mm)
+}
+
+func runRestore(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	e.open(openOptions{})
+	return handleRestore(ctx, e.rc, e.mm, args[0])
+}
+
+func runStatus(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	handleStatus(e.rs)
+	return nil
+}
+
+func runDrivers(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	handleDrivers(e.rs, e.storageDrivers, e.driverTypes)
+	return nil
+}
+
+func runCapacity(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	return handleCapacity(e.mm, e.storageDrivers)
+}
+
+func runCost(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	return handleCostReport(e.mm, e.rs)
+}
+
+func runProbe(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	handleProbe(ctx, e.rs, e.schedulerCfg.ProbeSizeKB*1024)
+	return nil
+}
+
+func setupExplain(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	raidLevel := fs.Int("raid", 0, "RAID级别 (0, 1, 5, 10)")
+	name := fs.String("name", "", "按该文件路径（如 /videos/a.mkv）匹配亲和性中的文件固定规则")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		sizeMB, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || sizeMB <= 0 {
+			return fmt.Errorf("无效的大小: %s", args[0])
+		}
+		e.open(openOptions{RAIDLevel: *raidLevel})
+		handleExplain(e.rs, *name, int64(sizeMB*1024*1024), *raidLevel)
+		return nil
+	}
+}
+
+// drain为true时是drain，为false时是undrain
+func runDraining(drain bool) func(ctx context.Context, e *env, args []string) error {
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		e.open(openOptions{})
+		if drain {
+			return handleDrain(e.rc, e.rs, e.mm, args[0], "")
+		}
+		return handleDrain(e.rc, e.rs, e.mm, "", args[0])
+	}
+}
+
+func runLogin(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return handleLogin(e.auditLog, args[0])
+}
+
+func runVault(ctx context.Context, e *env, args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		return handleVault(e.auditLog, e.vault, "", true, "")
+	case len(args) == 2 && args[0] == "set":
+		return handleVault(e.auditLog, e.vault, args[1], false, "")
+	case len(args) == 2 && args[0] == "delete":
+		return handleVault(e.auditLog, e.vault, "", false, args[1])
+	}
+	return errUsage
+}
+
+// 后台任务的公共参数
+type jobFlags struct {
+	deadline   *time.Duration
+	background *bool
+}
+
+func addJobFlags(fs *flag.FlagSet) jobFlags {
+	return jobFlags{
+		deadline:   fs.Duration("deadline", 0, "希望完成的时限（如 2h），临近时限时不再让位于用户的上传和下载"),
+		background: fs.Bool("background", false, "只提交后台任务，由 jobs run 或常驻进程执行"),
+	}
+}
+
+// 执行或提交后台任务。时限从提交时算起，中断后继续执行时不变
+func (f jobFlags) run(ctx context.Context, e *env, jobType string, params map[string]string) error {
+	e.open(openOptions{})
+	if *f.deadline > 0 {
+		params["deadline"] = time.Now().Add(*f.deadline).Format(time.RFC3339)
+	}
+	return runJob(ctx, e.jobs, jobType, params, *f.background)
+}
+
+func setupEvacuate(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	flags := addJobFlags(fs)
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		return flags.run(ctx, e, "evacuate", map[string]string{"driver": args[0]})
+	}
+}
+
+func setupRestripe(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	rate := fs.Float64("rate", 0, "限速 (MB/s)，0表示不限速")
+	flags := addJobFlags(fs)
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		return flags.run(ctx, e, "restripe", map[string]string{"rate_mb": strconv.FormatFloat(*rate, 'f', -1, 64)})
+	}
+}
+
+func setupCheckMeta(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	deep := fs.Bool("deep", false, "下载每个块校验大小和SHA-256（较慢）")
+	heal := fs.Bool("heal", false, "利用冗余数据重写缺失或损坏的块")
+	flags := addJobFlags(fs)
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		params := map[string]string{"deep": strconv.FormatBool(*deep), "heal": strconv.FormatBool(*heal)}
+		return flags.run(ctx, e, "check-meta", params)
+	}
+}
+
+func setupRebuildMetadata(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	flags := addJobFlags(fs)
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		return flags.run(ctx, e, "rebuild-metadata", map[string]string{})
+	}
+}
+
+func setupGC(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	flags := addJobFlags(fs)
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		return flags.run(ctx, e, "gc", map[string]string{})
+	}
+}
+
+func setupJobs(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	workers := fs.Int("workers", 1, "同时执行的任务数，用于 jobs run")
+	return func(ctx context.Context, e *env, args []string) error {
+		switch {
+		case len(args) == 0:
+			return handleJobs(e.jobs, true, "", "", "", "")
+		case len(args) == 1 && args[0] == "run":
+			// 执行排队的任务（包括上次中断的任务），全部结束后退出
+			e.open(openOptions{})
+			e.jobs.RunPending(ctx, *workers)
+			return nil
+		case len(args) == 1:
+			return handleJobs(e.jobs, false, args[0], "", "", "")
+		case len(args) == 2 && args[0] == "cancel":
+			return handleJobs(e.jobs, false, "", args[1], "", "")
+		case len(args) == 2 && args[0] == "pause":
+			return handleJobs(e.jobs, false, "", "", args[1], "")
+		case len(args) == 2 && args[0] == "resume":
+			return handleJobs(e.jobs, false, "", "", "", args[1])
+		}
+		return errUsage
+	}
+}
+
+func runMetadata(ctx context.Context, e *env, args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "backup":
+		e.open(openOptions{})
+		info, err := e.mm.Backup(ctx)
+		if info == nil {
+			return err
+		}
+		if err != nil {
+			fmt.Printf("警告: %v\n", err)
+		}
+		fmt.Printf("已备份元数据: %s (%d 字节)\n", info.Name, info.Size)
+		return nil
+	case len(args) == 1 && args[0] == "backups":
+		e.open(openOptions{})
+		return handleListBackups(ctx, e.mm)
+	case len(args) == 2 && args[0] == "restore":
+		e.open(openOptions{RestoreBackup: args[1]})
+		return nil
+	case len(args) == 1 && args[0] == "bootstrap":
+		e.open(openOptions{Bootstrap: true})
+		fmt.Println("已从副本驱动器恢复元数据")
+		return nil
+	}
+	return errUsage
+}
+
+func setupCheckRefs(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	repair := fs.Bool("repair", false, "修正有偏差的引用计数")
+	return func(ctx context.Context, e *env, args []string) error {
+		e.open(openOptions{})
+		return handleCheckRefs(e.mm, *repair)
+	}
+}
+
+func setupEvents(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	types := fs.String("types", "", "只输出这些类型的事件（逗号分隔，如 file.created,driver.health）")
+	return func(ctx context.Context, e *env, args []string) error {
+		e.open(openOptions{})
+		handleEvents(ctx, e.mm, *types)
+		return nil
+	}
+}
+
+func setupAudit(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	user := fs.String("user", "", "只显示指定用户的操作")
+	ops := fs.String("op", "", "只显示指定类型的操作（逗号分隔，如 upload,delete）")
+	since := fs.String("since", "", "只显示该时间之后的操作（如 24h、7d、2024-01-01）")
+	limit := fs.Int("limit", 50, "最多显示最近的记录数，0表示不限")
+	return func(ctx context.Context, e *env, args []string) error {
+		return handleAudit(e.auditLog, *user, *ops, *since, *limit)
+	}
+}
+
+func runUsers(ctx context.Context, e *env, args []string) error {
+	e.open(openOptions{})
+	handleListUsers(e.mm)
+	return nil
+}
+
+// 常驻期间在后台清理过期的回收站文件和旧版本、备份元数据，并执行提交的后台任务
+func (e *env) startDaemon(ctx context.Context, workers int) {
+	startPurger(ctx, e.rc, e.mm)
+	startBackups(ctx, e.mm)
+	e.jobs.Start(ctx, workers)
+}
+
+func setupShell(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	raidLevel := fs.Int("raid", 0, "upload未指定RAID级别时使用的级别")
+	output := fs.String("output", "./download", "download未指定输出路径时使用的路径")
+	workers := fs.Int("workers", 1, "同时执行的后台任务数")
+	return func(ctx context.Context, e *env, args []string) error {
+		e.open(openOptions{RAIDLevel: *raidLevel})
+		e.startDaemon(ctx, *workers)
+		stopAPI, err := e.serveAPI(*raidLevel)
+		if err != nil {
+			return err
+		}
+		defer stopAPI()
+		if err := e.shell(*raidLevel, *output).run(ctx); err != nil && ctx.Err() == nil {
+			return err
+		}
+		return nil
+	}
+}
+
+func setupServe(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	raidLevel := fs.Int("raid", 0, "通过gRPC接口、Web界面、WebDAV或S3兼容服务上传时使用的RAID级别")
+	workers := fs.Int("workers", 1, "同时执行的后台任务数")
+	return func(ctx context.Context, e *env, args []string) error {
+		e.open(openOptions{RAIDLevel: *raidLevel})
+		e.startDaemon(ctx, *workers)
+		stopAPI, err := e.serveAPI(*raidLevel)
+		if err != nil {
+			return err
+		}
+		defer stopAPI()
+		fmt.Println("PanMatrix 已启动，按 Ctrl+C 退出")
+		<-ctx.Done()
+		return nil
+	}
+}
+
+// mount 的选项
+type mountOptions struct {
+	RAIDLevel  int // 写入文件时使用的RAID级别
+	ReadOnly   bool
+	AllowOther bool   // 允许其他用户访问挂载点，需要在/etc/fuse.conf中启用user_allow_other
+	SpoolDir   string // 写入中的文件的临时目录，为空时使用系统临时目录
+}
+
+func setupMount(fs *flag.FlagSet) func(ctx context.Context, e *env, args []string) error {
+	var opts mountOptions
+	fs.IntVar(&opts.RAIDLevel, "raid", 0, "写入文件时使用的RAID级别")
+	fs.BoolVar(&opts.ReadOnly, "read-only", false, "只读挂载")
+	fs.BoolVar(&opts.AllowOther, "allow-other", false, "允许其他用户访问挂载点")
+	fs.StringVar(&opts.SpoolDir, "spool", "", "写入中的文件在本地的临时目录（默认为系统临时目录）")
+	return func(ctx context.Context, e *env, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		e.open(openOptions{RAIDLevel: opts.RAIDLevel})
+		return e.mount(ctx, args[0], opts)
+	}
+}
+
+func runConfig(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return errUsage
+	}
+	// 配置、HTTP和带宽段在执行命令前已经加载，这里检查其余各段
+	specs, err := drivers.LoadDriveSpecs(configPath)
+	if err != nil {
+		return err
+	}
+	if _, err := scheduler.LoadSchedulerConfig(configPath); err != nil {
+		return err
+	}
+	if _, err := metadata.LoadStoreConfig(configPath); err != nil {
+		return err
+	}
+	if _, err := api.LoadConfig(configPath); err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		state := "启用"
+		if !spec.Enabled {
+			state = "未启用"
+		}
+		fmt.Printf("%-20s %-12s %s\n", spec.Name, spec.Type, state)
+	}
+	fmt.Printf("%s 有效\n", configPath)
+	return nil
+}
@@ -0,0 +1,242 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Alist配置：通过本地Alist实例接入其支持的任意存储
+type AlistConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	URL        string `yaml:"url"`         // Alist地址，如 http://127.0.0.1:5244
+	Token      string `yaml:"token"`       // 管理员令牌或用户令牌
+	MountPath  string `yaml:"mount_path"`  // Alist中的存储路径，如 /aliyun/PanMatrix
+	QuotaBytes int64  `yaml:"quota_bytes"` // 可用空间上限，0表示不限（Alist不提供容量信息）
+	Proxy      string `yaml:"proxy"`       // 覆盖http段的默认代理
+}
+
+// Alist元驱动
+type AlistDriver struct {
+	cfg    AlistConfig
+	client *http.Client
+}
+
+// Alist接口统一响应
+type alistResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func NewAlistDriver(cfg AlistConfig) (*AlistDriver, error) {
+	if cfg.URL == "" || cfg.MountPath == "" {
+		return nil, errors.New("Alist配置缺少url或mount_path")
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	cfg.MountPath = path.Clean("/" + cfg.MountPath)
+
+	client, err := newHTTPClient(10*time.Minute, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AlistDriver{
+		cfg:    cfg,
+		client: client,
+	}, nil
+}
+
+// 连接Alist：确保存储目录存在
+func (d *AlistDriver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	if err := d.api(ctx, "/api/fs/mkdir", map[string]string{"path": d.cfg.MountPath}, nil); err != nil {
+		return fmt.Errorf("创建Alist存储目录失败: %v", err)
+	}
+	return nil
+}
+
+func (d *AlistDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+func (d *AlistDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error {
+		return d.api(ctx, "/api/fs/get", map[string]string{"path": d.cfg.MountPath}, nil)
+	})
+}
+
+// 统计存储目录下所有文件大小之和
+func (d *AlistDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const perPage = 500
+	var used int64
+	for page := 1; ; page++ {
+		var list struct {
+			Content []struct {
+				Size  int64 `json:"size"`
+				IsDir bool  `json:"is_dir"`
+			} `json:"content"`
+			Total int `json:"total"`
+		}
+		err := d.api(ctx, "/api/fs/list", map[string]interface{}{
+			"path":     d.cfg.MountPath,
+			"page":     page,
+			"per_page": perPage,
+		}, &list)
+		if err != nil {
+			return 0, 0, fmt.Errorf("统计Alist使用量失败: %v", err)
+		}
+		for _, f := range list.Content {
+			if !f.IsDir {
+				used += f.Size
+			}
+		}
+		if page*perPage >= list.Total {
+			break
+		}
+	}
+
+	return used, d.cfg.QuotaBytes, nil
+}
+
+// 通过流式上传接口写入文件
+func (d *AlistDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.cfg.URL+"/api/fs/put", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Authorization", d.cfg.Token)
+	req.Header.Set("File-Path", url.PathEscape(d.remotePath(storageID)))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("As-Task", "false")
+
+	if err := d.decode(req, nil); err != nil {
+		return "", fmt.Errorf("Alist上传失败: %v", err)
+	}
+	return storageID, nil
+}
+
+// 通过fs/get获取直链后下载
+func (d *AlistDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	var file struct {
+		RawURL string `json:"raw_url"`
+	}
+	if err := d.api(ctx, "/api/fs/get", map[string]string{"path": d.remotePath(storageID)}, &file); err != nil {
+		return nil, fmt.Errorf("获取Alist下载地址失败: %v", err)
+	}
+
+	target := file.RawURL
+	if target == "" {
+		// 部分存储不返回直链，经由Alist代理下载
+		target = d.cfg.URL + "/d" + escapePath(d.remotePath(storageID))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(target, d.cfg.URL) {
+		req.Header.Set("Authorization", d.cfg.Token)
+	}
+
+	data, err := doBytes(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("Alist下载失败: %v", err)
+	}
+	return data, nil
+}
+
+func (d *AlistDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	remote := d.remotePath(storageID)
+	err := d.api(ctx, "/api/fs/remove", map[string]interface{}{
+		"dir":   path.Dir(remote),
+		"names": []string{path.Base(remote)},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("Alist删除失败: %v", err)
+	}
+	return nil
+}
+
+func (d *AlistDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	const perPage = 500
+	var chunks []ChunkInfo
+	for page := 1; ; page++ {
+		var list struct {
+			Content []struct {
+				Name     string    `json:"name"`
+				Size     int64     `json:"size"`
+				IsDir    bool      `json:"is_dir"`
+				Modified time.Time `json:"modified"`
+			} `json:"content"`
+			Total int `json:"total"`
+		}
+		err := d.api(ctx, "/api/fs/list", map[string]interface{}{
+			"path":     d.cfg.MountPath,
+			"page":     page,
+			"per_page": perPage,
+			"refresh":  page == 1,
+		}, &list)
+		if err != nil {
+			return nil, fmt.Errorf("列举Alist文件失败: %v", err)
+		}
+
+		for _, f := range list.Content {
+			if !f.IsDir {
+				chunks = appendChunk(chunks, prefix, ChunkInfo{StorageID: f.Name, Size: f.Size, ModTime: f.Modified})
+			}
+		}
+		if page*perPage >= list.Total {
+			return chunks, nil
+		}
+	}
+}
+
+// 调用Alist的JSON接口
+func (d *AlistDriver) api(ctx context.Context, endpoint string, body interface{}, out interface{}) error {
+	req, err := newJSONRequest(ctx, http.MethodPost, d.cfg.URL+endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", d.cfg.Token)
+	return d.decode(req, out)
+}
+
+// Alist的HTTP状态码总是200，业务错误在响应体的code中
+func (d *AlistDriver) decode(req *http.Request, out interface{}) error {
+	var resp alistResponse
+	if err := doJSON(d.client, req, &resp); err != nil {
+		return err
+	}
+
+	if resp.Code != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.Code, Body: resp.Message}
+		if resp.Code == http.StatusNotFound || strings.Contains(resp.Message, "not found") {
+			return fmt.Errorf("%w: %v", ErrChunkNotFound, apiErr)
+		}
+		return apiErr
+	}
+
+	if out != nil && len(resp.Data) > 0 && string(resp.Data) != "null" {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+	return nil
+}
+
+func (d *AlistDriver) remotePath(storageID string) string {
+	return path.Join(d.cfg.MountPath, storageID)
+}
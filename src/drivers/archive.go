@@ -0,0 +1,44 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrRestoreInProgress  = errors.New("数据块已归档，需要先恢复")
+	ErrRestoreUnsupported = errors.New("驱动器不支持归档恢复")
+)
+
+// 读取已归档的数据块时返回，errors.Is(err, ErrRestoreInProgress)为true。
+// 恢复通常需要数分钟到数小时，完成前的读取都返回该错误
+type RestoreError struct {
+	StorageID string
+	Ongoing   bool // 已提交过恢复请求，正在恢复
+}
+
+func (e *RestoreError) Error() string {
+	if e.Ongoing {
+		return fmt.Sprintf("数据块 %s 正在从归档中恢复，请稍后重试", e.StorageID)
+	}
+	return fmt.Sprintf("数据块 %s 已归档，需要先提交恢复请求", e.StorageID)
+}
+
+func (e *RestoreError) Unwrap() error { return ErrRestoreInProgress }
+
+// 归档存储（如S3 Glacier）：数据块需要先恢复到可读状态才能下载。
+// 这类驱动器在Capabilities中报告Archive，RAID只在上面存放校验块
+type Restorer interface {
+	// 提交恢复请求，已在恢复或无需恢复时返回nil
+	RestoreChunk(ctx context.Context, storageID string) error
+}
+
+// 请求恢复已归档的数据块，驱动器不支持时返回ErrRestoreUnsupported
+func RestoreChunk(ctx context.Context, driver StorageDriver, storageID string) error {
+	restorer, ok := driver.(Restorer)
+	if !ok {
+		return ErrRestoreUnsupported
+	}
+	return restorer.RestoreChunk(ctx, storageID)
+}
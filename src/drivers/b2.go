@@ -0,0 +1,514 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	b2AuthorizeURL = "https://api.backblazeb2.com/b2api/v2/b2_authorize_account"
+
+	// 超过该大小使用大文件API分片上传（B2单次上传上限5GB，推荐100MB以上分片）
+	b2LargeFileThreshold = 100 * 1024 * 1024
+)
+
+// Backblaze B2配置
+type B2Config struct {
+	Enabled        bool   `yaml:"enabled"`
+	KeyID          string `yaml:"key_id"`
+	ApplicationKey string `yaml:"application_key"`
+	BucketID       string `yaml:"bucket_id"`
+	BucketName     string `yaml:"bucket_name"`
+	Prefix         string `yaml:"prefix"`      // 文件名前缀，默认 panmatrix
+	QuotaBytes     int64  `yaml:"quota_bytes"` // 可用空间上限，0表示不限
+	Proxy          string `yaml:"proxy"`       // 覆盖http段的默认代理
+}
+
+// B2授权信息
+type b2Auth struct {
+	AccountID           string `json:"accountId"`
+	AuthorizationToken  string `json:"authorizationToken"`
+	APIURL              string `json:"apiUrl"`
+	DownloadURL         string `json:"downloadUrl"`
+	RecommendedPartSize int64  `json:"recommendedPartSize"`
+}
+
+// 上传地址，每个地址同一时间只能被一个上传使用
+type b2UploadURL struct {
+	URL   string `json:"uploadUrl"`
+	Token string `json:"authorizationToken"`
+}
+
+// Backblaze B2原生API驱动
+type B2Driver struct {
+	cfg    B2Config
+	client *http.Client
+
+	auth       *b2Auth
+	uploadURLs []b2UploadURL // 空闲的上传地址池
+	mu         sync.Mutex
+}
+
+func NewB2Driver(cfg B2Config) (*B2Driver, error) {
+	if cfg.KeyID == "" || cfg.ApplicationKey == "" || cfg.BucketID == "" || cfg.BucketName == "" {
+		return nil, errors.New("B2配置缺少key_id、application_key、bucket_id或bucket_name")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "panmatrix"
+	}
+
+	client, err := newHTTPClient(10*time.Minute, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &B2Driver{
+		cfg:    cfg,
+		client: client,
+	}, nil
+}
+
+func (d *B2Driver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	return d.authorize(ctx)
+}
+
+func (d *B2Driver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// 列举一个文件作为探测
+func (d *B2Driver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error {
+		return d.api(ctx, "b2_list_file_names", map[string]interface{}{
+			"bucketId":     d.cfg.BucketID,
+			"maxFileCount": 1,
+			"prefix":       d.cfg.Prefix + "/",
+		}, nil)
+	})
+}
+
+// 统计前缀下所有文件大小之和
+func (d *B2Driver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var used int64
+	startName := ""
+	for {
+		var page struct {
+			Files []struct {
+				ContentLength int64 `json:"contentLength"`
+			} `json:"files"`
+			NextFileName *string `json:"nextFileName"`
+		}
+		req := map[string]interface{}{
+			"bucketId":     d.cfg.BucketID,
+			"maxFileCount": 1000,
+			"prefix":       d.cfg.Prefix + "/",
+		}
+		if startName != "" {
+			req["startFileName"] = startName
+		}
+		if err := d.api(ctx, "b2_list_file_names", req, &page); err != nil {
+			return 0, 0, fmt.Errorf("统计B2使用量失败: %v", err)
+		}
+		for _, f := range page.Files {
+			used += f.ContentLength
+		}
+		if page.NextFileName == nil {
+			break
+		}
+		startName = *page.NextFileName
+	}
+
+	return used, d.cfg.QuotaBytes, nil
+}
+
+// 上传数据块，超过阈值时使用大文件API
+func (d *B2Driver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	if len(data) > b2LargeFileThreshold {
+		return d.uploadLargeFile(ctx, data, storageID)
+	}
+
+	var file struct {
+		FileID string `json:"fileId"`
+	}
+
+	// 上传地址过期或繁忙时丢弃并重新申请
+	var lastErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		upload, err := d.getUploadURL(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		sum := sha1.Sum(data)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, upload.URL, bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		req.ContentLength = int64(len(data))
+		req.Header.Set("Authorization", upload.Token)
+		req.Header.Set("X-Bz-File-Name", url.PathEscape(d.fileName(storageID)))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("X-Bz-Content-Sha1", hex.EncodeToString(sum[:]))
+
+		lastErr = doJSON(d.client, req, &file)
+		if lastErr == nil {
+			d.releaseUploadURL(upload)
+			return storageID, nil
+		}
+		if !b2Retryable(lastErr) {
+			break
+		}
+	}
+
+	return "", fmt.Errorf("B2上传失败: %v", lastErr)
+}
+
+// 大文件API：start_large_file -> get_upload_part_url -> upload_part... -> finish_large_file
+func (d *B2Driver) uploadLargeFile(ctx context.Context, data []byte, storageID string) (string, error) {
+	session, err := d.StartUploadSession(ctx, storageID, int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := UploadWithSession(ctx, d, session, data, nil); err != nil {
+		d.AbortSession(context.Background(), session)
+		return "", err
+	}
+	return storageID, nil
+}
+
+// 开始大文件上传，分片大小使用账户推荐值
+func (d *B2Driver) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	var started struct {
+		FileID string `json:"fileId"`
+	}
+	err := d.api(ctx, "b2_start_large_file", map[string]string{
+		"bucketId":    d.cfg.BucketID,
+		"fileName":    d.fileName(storageID),
+		"contentType": "application/octet-stream",
+	}, &started)
+	if err != nil {
+		return nil, fmt.Errorf("开始B2大文件上传失败: %v", err)
+	}
+
+	partSize := int64(b2LargeFileThreshold)
+	d.mu.Lock()
+	if d.auth != nil && d.auth.RecommendedPartSize > 0 {
+		partSize = d.auth.RecommendedPartSize
+	}
+	d.mu.Unlock()
+
+	return &UploadSession{
+		StorageID: storageID,
+		UploadID:  started.FileID,
+		Size:      size,
+		PartSize:  partSize,
+		State:     make(map[string]string),
+		StartedAt: time.Now(),
+	}, nil
+}
+
+// 上传一个分片，分片上传地址缓存在会话状态中，失效时丢弃并在下次重试时重新获取
+func (d *B2Driver) UploadPart(ctx context.Context, session *UploadSession, partIndex int, data []byte) error {
+	if session.State == nil {
+		session.State = make(map[string]string)
+	}
+	if session.State["part_url"] == "" {
+		var partURL b2UploadURL
+		if err := d.api(ctx, "b2_get_upload_part_url", map[string]string{"fileId": session.UploadID}, &partURL); err != nil {
+			return fmt.Errorf("获取B2分片上传地址失败: %v", err)
+		}
+		session.State["part_url"] = partURL.URL
+		session.State["part_token"] = partURL.Token
+	}
+
+	sum := sha1.Sum(data)
+	partSHA1 := hex.EncodeToString(sum[:])
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, session.State["part_url"], bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Authorization", session.State["part_token"])
+	req.Header.Set("X-Bz-Part-Number", fmt.Sprint(partIndex+1))
+	req.Header.Set("X-Bz-Content-Sha1", partSHA1)
+
+	if err := doJSON(d.client, req, nil); err != nil {
+		if b2Retryable(err) {
+			delete(session.State, "part_url")
+			delete(session.State, "part_token")
+		}
+		return fmt.Errorf("B2分片%d上传失败: %v", partIndex+1, err)
+	}
+
+	session.State[b2PartKey(partIndex)] = partSHA1
+	return nil
+}
+
+func (d *B2Driver) CompleteSession(ctx context.Context, session *UploadSession) (string, error) {
+	sha1s := make([]string, session.PartCount())
+	for i := range sha1s {
+		sha1s[i] = session.State[b2PartKey(i)]
+		if sha1s[i] == "" {
+			return "", fmt.Errorf("B2分片%d未上传", i+1)
+		}
+	}
+
+	err := d.api(ctx, "b2_finish_large_file", map[string]interface{}{
+		"fileId":        session.UploadID,
+		"partSha1Array": sha1s,
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("完成B2大文件上传失败: %v", err)
+	}
+	return session.StorageID, nil
+}
+
+func (d *B2Driver) AbortSession(ctx context.Context, session *UploadSession) error {
+	return d.api(ctx, "b2_cancel_large_file", map[string]string{"fileId": session.UploadID}, nil)
+}
+
+func b2PartKey(partIndex int) string {
+	return fmt.Sprintf("sha1_%d", partIndex)
+}
+
+func (d *B2Driver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	return d.download(ctx, storageID, 0, 0)
+}
+
+func (d *B2Driver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	return d.download(ctx, storageID, offset, length)
+}
+
+// length为0时下载整个文件
+func (d *B2Driver) download(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		auth, err := d.currentAuth(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		target := fmt.Sprintf("%s/file/%s/%s", auth.DownloadURL, url.PathEscape(d.cfg.BucketName), escapePath(d.fileName(storageID)))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", auth.AuthorizationToken)
+
+		var data []byte
+		if length > 0 {
+			data, err = doRange(d.client, req, offset, length)
+		} else {
+			data, err = doBytes(d.client, req)
+		}
+		if err != nil && attempt == 0 && isUnauthorized(err) {
+			d.invalidateAuth()
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("B2下载失败: %v", err)
+		}
+		return data, nil
+	}
+}
+
+func (d *B2Driver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	namePrefix := d.cfg.Prefix + "/"
+	var chunks []ChunkInfo
+	startName := ""
+	for {
+		var page struct {
+			Files []struct {
+				FileName        string `json:"fileName"`
+				ContentLength   int64  `json:"contentLength"`
+				UploadTimestamp int64  `json:"uploadTimestamp"`
+				Action          string `json:"action"`
+			} `json:"files"`
+			NextFileName *string `json:"nextFileName"`
+		}
+		req := map[string]interface{}{
+			"bucketId":     d.cfg.BucketID,
+			"maxFileCount": 1000,
+			"prefix":       namePrefix + prefix,
+		}
+		if startName != "" {
+			req["startFileName"] = startName
+		}
+		if err := d.api(ctx, "b2_list_file_names", req, &page); err != nil {
+			return nil, fmt.Errorf("列举B2文件失败: %v", err)
+		}
+
+		for _, f := range page.Files {
+			// 未完成的大文件不计入
+			if f.Action != "" && f.Action != "upload" {
+				continue
+			}
+			chunks = append(chunks, ChunkInfo{
+				StorageID: strings.TrimPrefix(f.FileName, namePrefix),
+				Size:      f.ContentLength,
+				ModTime:   time.UnixMilli(f.UploadTimestamp),
+			})
+		}
+		if page.NextFileName == nil {
+			return chunks, nil
+		}
+		startName = *page.NextFileName
+	}
+}
+
+// 删除文件的所有版本
+func (d *B2Driver) DeleteChunk(ctx context.Context, storageID string) error {
+	var versions struct {
+		Files []struct {
+			FileID   string `json:"fileId"`
+			FileName string `json:"fileName"`
+		} `json:"files"`
+	}
+	name := d.fileName(storageID)
+	err := d.api(ctx, "b2_list_file_versions", map[string]interface{}{
+		"bucketId":      d.cfg.BucketID,
+		"startFileName": name,
+		"prefix":        name,
+		"maxFileCount":  100,
+	}, &versions)
+	if err != nil {
+		return fmt.Errorf("查询B2文件版本失败: %v", err)
+	}
+
+	for _, f := range versions.Files {
+		if f.FileName != name {
+			continue
+		}
+		err := d.api(ctx, "b2_delete_file_version", map[string]string{
+			"fileId":   f.FileID,
+			"fileName": f.FileName,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("B2删除失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// 调用B2 API，授权令牌过期（401）时重新授权后重试一次
+func (d *B2Driver) api(ctx context.Context, method string, body interface{}, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		auth, err := d.currentAuth(ctx)
+		if err != nil {
+			return err
+		}
+
+		req, err := newJSONRequest(ctx, http.MethodPost, auth.APIURL+"/b2api/v2/"+method, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", auth.AuthorizationToken)
+
+		err = doJSON(d.client, req, out)
+		if err != nil && attempt == 0 && isUnauthorized(err) {
+			d.invalidateAuth()
+			continue
+		}
+		return err
+	}
+}
+
+// 获取上传地址，优先复用空闲地址
+func (d *B2Driver) getUploadURL(ctx context.Context) (b2UploadURL, error) {
+	d.mu.Lock()
+	if n := len(d.uploadURLs); n > 0 {
+		upload := d.uploadURLs[n-1]
+		d.uploadURLs = d.uploadURLs[:n-1]
+		d.mu.Unlock()
+		return upload, nil
+	}
+	d.mu.Unlock()
+
+	var upload b2UploadURL
+	if err := d.api(ctx, "b2_get_upload_url", map[string]string{"bucketId": d.cfg.BucketID}, &upload); err != nil {
+		return upload, fmt.Errorf("获取B2上传地址失败: %v", err)
+	}
+	return upload, nil
+}
+
+func (d *B2Driver) releaseUploadURL(upload b2UploadURL) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.uploadURLs = append(d.uploadURLs, upload)
+}
+
+func (d *B2Driver) currentAuth(ctx context.Context) (*b2Auth, error) {
+	d.mu.Lock()
+	auth := d.auth
+	d.mu.Unlock()
+	if auth != nil {
+		return auth, nil
+	}
+
+	if err := d.authorize(ctx); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.auth, nil
+}
+
+func (d *B2Driver) invalidateAuth() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.auth = nil
+	d.uploadURLs = nil
+}
+
+// b2_authorize_account：获取账户令牌和API地址（令牌24小时有效）
+func (d *B2Driver) authorize(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b2AuthorizeURL, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.cfg.KeyID, d.cfg.ApplicationKey)
+
+	var auth b2Auth
+	if err := doJSON(d.client, req, &auth); err != nil {
+		return fmt.Errorf("B2授权失败: %v", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.auth = &auth
+	d.uploadURLs = nil
+	return nil
+}
+
+func (d *B2Driver) fileName(storageID string) string {
+	return path.Join(d.cfg.Prefix, storageID)
+}
+
+// 上传地址过期（401）或节点繁忙（503/408）时需要换一个上传地址重试
+func b2Retryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true // 连接错误
+	}
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusRequestTimeout, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
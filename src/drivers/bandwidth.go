@@ -0,0 +1,132 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 全局带宽限制（config.yaml顶层的bandwidth段），所有HTTP驱动器的流量合计不超过该速率，
+// 单个驱动器的限制仍由drives中的limits配置
+//
+//	bandwidth:
+//	  upload_mbps: 6      # 合计上传带宽（MB/s），0表示不限
+//	  download_mbps: 12
+type BandwidthConfig struct {
+	UploadMBps   float64 `yaml:"upload_mbps"`
+	DownloadMBps float64 `yaml:"download_mbps"`
+}
+
+// 每次读写最多取用的字节数。并发的传输轮流按该粒度取得令牌，从而平分带宽
+const bandwidthQuantum = 32 * 1024
+
+// 全局上传/下载令牌桶，nil表示不限
+var globalBandwidth struct {
+	upload   atomic.Pointer[tokenBucket]
+	download atomic.Pointer[tokenBucket]
+}
+
+// 设置全局带宽限制，立即作用于所有连接（包括已建立的连接）
+func SetBandwidthLimit(cfg BandwidthConfig) error {
+	if cfg.UploadMBps < 0 || cfg.DownloadMBps < 0 {
+		return fmt.Errorf("带宽限制不能为负数: %+v", cfg)
+	}
+	globalBandwidth.upload.Store(newBandwidthBucket(cfg.UploadMBps))
+	globalBandwidth.download.Store(newBandwidthBucket(cfg.DownloadMBps))
+	return nil
+}
+
+// 读取配置文件中的bandwidth段，没有该段时不限速
+func LoadBandwidthConfig(path string) (BandwidthConfig, error) {
+	var file struct {
+		Bandwidth BandwidthConfig `yaml:"bandwidth"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file.Bandwidth, err
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file.Bandwidth, fmt.Errorf("解析bandwidth配置失败: %v", err)
+	}
+	return file.Bandwidth, nil
+}
+
+// 突发量为0.1秒的流量，避免限速后仍在瞬间占满链路
+func newBandwidthBucket(mbps float64) *tokenBucket {
+	if mbps <= 0 {
+		return nil
+	}
+	rate := mbps * 1024 * 1024
+	return newTokenBucket(rate, max(rate/10, bandwidthQuantum))
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// 包装拨号函数，返回的连接按全局带宽限制收发数据
+func throttledDial(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		connCtx, cancel := context.WithCancel(context.Background())
+		return &throttledConn{Conn: conn, ctx: connCtx, cancel: cancel}, nil
+	}
+}
+
+// 限速连接：写入前等待上传令牌，读取后扣除下载令牌，每次最多bandwidthQuantum字节。
+// 连接关闭时取消等待
+type throttledConn struct {
+	net.Conn
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+func (c *throttledConn) Write(p []byte) (int, error) {
+	bucket := globalBandwidth.upload.Load()
+	if bucket == nil {
+		return c.Conn.Write(p)
+	}
+
+	written := 0
+	for written < len(p) {
+		n := min(len(p)-written, bandwidthQuantum)
+		if err := bucket.wait(c.ctx, float64(n)); err != nil {
+			return written, net.ErrClosed
+		}
+		m, err := c.Conn.Write(p[written : written+n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (c *throttledConn) Read(p []byte) (int, error) {
+	bucket := globalBandwidth.download.Load()
+	if bucket == nil {
+		return c.Conn.Read(p)
+	}
+
+	if len(p) > bandwidthQuantum {
+		p = p[:bandwidthQuantum]
+	}
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		// 已读到的数据不丢弃，等待只推迟之后的读取
+		bucket.wait(c.ctx, float64(n))
+	}
+	return n, err
+}
+
+func (c *throttledConn) Close() error {
+	c.closeOnce.Do(c.cancel)
+	return c.Conn.Close()
+}
@@ -0,0 +1,123 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var ErrRenameUnsupported = errors.New("驱动器不支持重命名")
+
+// 驱动器能力，RAID引擎和调度器据此调整条带拆分和读取方式
+type Capabilities struct {
+	MaxChunkSize     int64 // 单个远程对象的最大字节数，0表示不限
+	RangeDownload    bool  // 支持按字节范围下载
+	Multipart        bool  // 支持分片会话上传
+	MaxParallelParts int   // 分片会话可并发上传的分片数，不支持分片时为0
+	Rename           bool  // 支持服务端重命名
+	Delete           bool
+	List             bool
+	Archive          bool // 归档存储，读取前需要RestoreChunk，只用于存放校验块
+}
+
+// 显式报告能力的驱动器。包装器实现了所有可选接口，需要通过它报告被包装驱动器的真实能力
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// 支持按字节范围下载的驱动器
+type RangeDownloader interface {
+	// 下载[offset, offset+length)，超出对象末尾的部分被截断
+	DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error)
+}
+
+// 支持服务端重命名的驱动器，目标已存在时返回错误
+type ChunkRenamer interface {
+	RenameChunk(ctx context.Context, storageID, newStorageID string) error
+}
+
+// 获取驱动器能力，未实现CapabilityReporter时根据实现的可选接口推断
+func CapabilitiesOf(driver StorageDriver) Capabilities {
+	if reporter, ok := driver.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+
+	caps := Capabilities{MaxChunkSize: max(MaxChunkSizeOf(driver), 0)}
+	_, caps.RangeDownload = driver.(RangeDownloader)
+	_, caps.Rename = driver.(ChunkRenamer)
+	_, caps.Delete = driver.(ChunkDeleter)
+	_, caps.List = driver.(ChunkLister)
+	if _, ok := driver.(SessionUploader); ok {
+		caps.Multipart = true
+		caps.MaxParallelParts = 1
+		if p, ok := driver.(ParallelPartUploader); ok {
+			caps.MaxParallelParts = max(p.MaxParallelParts(), 1)
+		}
+	}
+	return caps
+}
+
+// 按字节范围下载；驱动器不支持时下载整个对象后截取
+func DownloadRange(ctx context.Context, driver StorageDriver, storageID string, offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("无效的下载范围: offset=%d, length=%d", offset, length)
+	}
+	if length == 0 {
+		return []byte{}, nil
+	}
+	if CapabilitiesOf(driver).RangeDownload {
+		if ranger, ok := driver.(RangeDownloader); ok {
+			return ranger.DownloadRange(ctx, storageID, offset, length)
+		}
+	}
+
+	data, err := driver.DownloadChunk(ctx, storageID)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(data, offset, length), nil
+}
+
+// 重命名远程对象，驱动器不支持时返回ErrRenameUnsupported
+func RenameChunk(ctx context.Context, driver StorageDriver, storageID, newStorageID string) error {
+	if renamer, ok := driver.(ChunkRenamer); ok {
+		return renamer.RenameChunk(ctx, storageID, newStorageID)
+	}
+	return ErrRenameUnsupported
+}
+
+// 截取[offset, offset+length)，超出末尾的部分被截断
+func sliceRange(data []byte, offset, length int64) []byte {
+	if offset >= int64(len(data)) {
+		return []byte{}
+	}
+	end := min(offset+length, int64(len(data)))
+	return data[offset:end]
+}
+
+// 发送带Range头的请求。服务端忽略Range返回完整对象时在本地截取，
+// 起点超出对象末尾（416）时返回空数据
+func doRange(client *http.Client, req *http.Request, offset, length int64) ([]byte, error) {
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+
+	resp, err := doRequest(client, req)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			return []byte{}, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return sliceRange(data, offset, length), nil
+	}
+	return data, nil
+}
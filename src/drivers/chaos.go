@@ -0,0 +1,300 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// 注入的故障错误
+var ErrInjectedFault = errors.New("注入的故障")
+
+// 故障注入配置，概率取值0~1。设置seed后故障序列可重现
+//
+//	drives:
+//	  - type: local
+//	    name: flaky
+//	    chaos:
+//	      seed: 42
+//	      error_rate: 0.1
+//	      lose_rate: 0.05
+type ChaosConfig struct {
+	Seed uint64 `yaml:"seed"` // 0表示随机种子
+
+	ErrorRate        float64       `yaml:"error_rate"`         // 请求直接失败
+	LatencyRate      float64       `yaml:"latency_rate"`       // 请求前等待Latency
+	Latency          time.Duration `yaml:"latency"`            // 默认2s
+	TruncateRate     float64       `yaml:"truncate_rate"`      // 下载只返回前半部分
+	CorruptRate      float64       `yaml:"corrupt_rate"`       // 下载数据翻转一个字节
+	LoseRate         float64       `yaml:"lose_rate"`          // 上传报告成功但数据丢失
+	UnavailableRate  float64       `yaml:"unavailable_rate"`   // IsAvailable返回false
+	FailStorageIDs   []string      `yaml:"fail_storage_ids"`   // 这些数据块的所有请求都失败
+	LostStorageIDs   []string      `yaml:"lost_storage_ids"`   // 这些数据块视为已丢失
+	DisableOnConnect bool          `yaml:"disable_on_connect"` // Connect不注入故障，便于先启动阵列
+}
+
+// 故障注入统计
+type ChaosStats struct {
+	Errors      int
+	Delays      int
+	Truncated   int
+	Corrupted   int
+	Lost        int
+	Unavailable int
+}
+
+// 故障注入驱动包装器，用于在集成测试中确定性地触发RAID的降级读取和重建路径
+type ChaosDriver struct {
+	baseWrapper
+
+	cfg   ChaosConfig
+	rng   *rand.Rand
+	lost  map[string]bool
+	fail  map[string]bool
+	stats ChaosStats
+	mu    sync.Mutex
+}
+
+func NewChaosDriver(inner StorageDriver, cfg ChaosConfig) *ChaosDriver {
+	d := &ChaosDriver{baseWrapper: baseWrapper{inner: inner}}
+	d.SetConfig(cfg)
+	return d
+}
+
+// 替换故障配置并按新的种子重置随机序列，已丢失的数据块保持丢失
+func (d *ChaosDriver) SetConfig(cfg ChaosConfig) {
+	if cfg.Latency <= 0 {
+		cfg.Latency = 2 * time.Second
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.cfg = cfg
+	d.rng = rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	if d.lost == nil {
+		d.lost = make(map[string]bool)
+	}
+	for _, id := range cfg.LostStorageIDs {
+		d.lost[id] = true
+	}
+	d.fail = make(map[string]bool)
+	for _, id := range cfg.FailStorageIDs {
+		d.fail[id] = true
+	}
+}
+
+// 将数据块标记为丢失，之后的下载返回ErrChunkNotFound
+func (d *ChaosDriver) LoseChunk(storageID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lost[storageID] = true
+}
+
+func (d *ChaosDriver) Stats() ChaosStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
+
+func (d *ChaosDriver) Connect() error {
+	d.mu.Lock()
+	skip := d.cfg.DisableOnConnect
+	d.mu.Unlock()
+
+	if !skip {
+		if err := d.inject(context.Background(), ""); err != nil {
+			return err
+		}
+	}
+	return d.inner.Connect()
+}
+
+func (d *ChaosDriver) IsAvailable() bool {
+	d.mu.Lock()
+	down := d.roll(d.cfg.UnavailableRate)
+	if down {
+		d.stats.Unavailable++
+	}
+	d.mu.Unlock()
+
+	return !down && d.inner.IsAvailable()
+}
+
+// 注入的延迟计入探测延迟
+func (d *ChaosDriver) Ping(ctx context.Context) (time.Duration, error) {
+	d.mu.Lock()
+	down := d.roll(d.cfg.UnavailableRate)
+	if down {
+		d.stats.Unavailable++
+	}
+	d.mu.Unlock()
+	if down {
+		return 0, fmt.Errorf("%w（注入）", ErrUnavailable)
+	}
+
+	start := time.Now()
+	if err := d.inject(ctx, ""); err != nil {
+		return time.Since(start), err
+	}
+	_, err := Ping(ctx, d.inner)
+	return time.Since(start), err
+}
+
+func (d *ChaosDriver) GetUsage() (int64, int64, error) {
+	if err := d.inject(context.Background(), ""); err != nil {
+		return 0, 0, err
+	}
+	return d.inner.GetUsage()
+}
+
+func (d *ChaosDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	if err := d.inject(ctx, storageID); err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	lose := d.roll(d.cfg.LoseRate)
+	if lose {
+		d.lost[storageID] = true
+		d.stats.Lost++
+	} else {
+		delete(d.lost, storageID)
+	}
+	d.mu.Unlock()
+
+	if lose {
+		return storageID, nil
+	}
+	return d.inner.UploadChunk(ctx, data, storageID)
+}
+
+func (d *ChaosDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	if err := d.inject(ctx, storageID); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	lost := d.lost[storageID]
+	d.mu.Unlock()
+	if lost {
+		return nil, fmt.Errorf("%w: %s（注入）", ErrChunkNotFound, storageID)
+	}
+
+	data, err := d.inner.DownloadChunk(ctx, storageID)
+	if err != nil || len(data) == 0 {
+		return data, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.roll(d.cfg.TruncateRate) {
+		d.stats.Truncated++
+		data = data[:len(data)/2]
+	}
+	if len(data) > 0 && d.roll(d.cfg.CorruptRate) {
+		d.stats.Corrupted++
+		data = append([]byte(nil), data...)
+		data[d.rng.IntN(len(data))] ^= 0xff
+	}
+	return data, nil
+}
+
+// 范围下载只注入错误、延迟和丢失，截断和损坏只作用于整块下载
+func (d *ChaosDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	if err := d.inject(ctx, storageID); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	lost := d.lost[storageID]
+	d.mu.Unlock()
+	if lost {
+		return nil, fmt.Errorf("%w: %s（注入）", ErrChunkNotFound, storageID)
+	}
+	return d.baseWrapper.DownloadRange(ctx, storageID, offset, length)
+}
+
+func (d *ChaosDriver) RenameChunk(ctx context.Context, storageID, newStorageID string) error {
+	if err := d.inject(ctx, storageID); err != nil {
+		return err
+	}
+	if err := d.baseWrapper.RenameChunk(ctx, storageID, newStorageID); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	if d.lost[storageID] {
+		delete(d.lost, storageID)
+		d.lost[newStorageID] = true
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *ChaosDriver) RestoreChunk(ctx context.Context, storageID string) error {
+	if err := d.inject(ctx, storageID); err != nil {
+		return err
+	}
+	return d.baseWrapper.RestoreChunk(ctx, storageID)
+}
+
+func (d *ChaosDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	if err := d.inject(ctx, storageID); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	delete(d.lost, storageID)
+	d.mu.Unlock()
+	return d.baseWrapper.DeleteChunk(ctx, storageID)
+}
+
+// 会话上传不注入丢失，分片请求按普通请求注入错误和延迟
+func (d *ChaosDriver) UploadPart(ctx context.Context, session *UploadSession, partIndex int, data []byte) error {
+	if err := d.inject(ctx, session.StorageID); err != nil {
+		return err
+	}
+	return d.baseWrapper.UploadPart(ctx, session, partIndex, data)
+}
+
+// 按配置注入延迟和错误
+func (d *ChaosDriver) inject(ctx context.Context, storageID string) error {
+	d.mu.Lock()
+	delay := time.Duration(0)
+	if d.roll(d.cfg.LatencyRate) {
+		d.stats.Delays++
+		delay = d.cfg.Latency
+	}
+	fail := d.fail[storageID] || d.roll(d.cfg.ErrorRate)
+	if fail {
+		d.stats.Errors++
+	}
+	d.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if fail {
+		return fmt.Errorf("%w: %s", ErrInjectedFault, storageID)
+	}
+	return nil
+}
+
+// 以概率p返回true，调用方需持有锁
+func (d *ChaosDriver) roll(p float64) bool {
+	return p > 0 && d.rng.Float64() < p
+}
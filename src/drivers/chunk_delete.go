@@ -0,0 +1,22 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrDeleteUnsupported = errors.New("驱动器不支持删除")
+
+// 支持删除远程对象的驱动器
+type ChunkDeleter interface {
+	DeleteChunk(ctx context.Context, storageID string) error
+}
+
+// 删除驱动器上的对象，驱动器未实现删除时返回ErrDeleteUnsupported
+func DeleteChunk(ctx context.Context, driver StorageDriver, storageID string) error {
+	deleter, ok := driver.(ChunkDeleter)
+	if !ok {
+		return ErrDeleteUnsupported
+	}
+	return deleter.DeleteChunk(ctx, storageID)
+}
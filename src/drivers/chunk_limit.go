@@ -0,0 +1,15 @@
+package drivers
+
+// 声明单文件大小上限的驱动器（部分网盘对单个文件大小有限制）
+type ChunkSizeLimiter interface {
+	// 单个远程对象允许的最大字节数，<=0 表示不限制
+	MaxChunkSize() int64
+}
+
+// 获取驱动器声明的单文件大小上限，未声明时返回0（不限制）
+func MaxChunkSizeOf(driver StorageDriver) int64 {
+	if limiter, ok := driver.(ChunkSizeLimiter); ok {
+		return limiter.MaxChunkSize()
+	}
+	return 0
+}
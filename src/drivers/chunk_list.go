@@ -0,0 +1,49 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrListUnsupported = errors.New("驱动器不支持列举")
+
+// 远程存在的数据块
+type ChunkInfo struct {
+	StorageID string // 上传时使用的storageID（远程文件名）
+	RemoteID  string // UploadChunk返回的ID，下载和删除使用；与StorageID相同的驱动器可为空
+	Size      int64
+	ModTime   time.Time // 驱动器不提供时为零值
+}
+
+// 下载和删除时使用的ID
+func (c ChunkInfo) Key() string {
+	if c.RemoteID != "" {
+		return c.RemoteID
+	}
+	return c.StorageID
+}
+
+// 支持列举远程数据块的驱动器，用于垃圾回收和元数据重建
+type ChunkLister interface {
+	// 列出storageID以prefix开头的所有数据块，prefix为空时列出全部
+	ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error)
+}
+
+// 列举驱动器上的数据块，驱动器未实现列举时返回ErrListUnsupported
+func ListChunks(ctx context.Context, driver StorageDriver, prefix string) ([]ChunkInfo, error) {
+	lister, ok := driver.(ChunkLister)
+	if !ok {
+		return nil, ErrListUnsupported
+	}
+	return lister.ListChunks(ctx, prefix)
+}
+
+// 按前缀过滤后追加
+func appendChunk(chunks []ChunkInfo, prefix string, chunk ChunkInfo) []ChunkInfo {
+	if !strings.HasPrefix(chunk.StorageID, prefix) {
+		return chunks
+	}
+	return append(chunks, chunk)
+}
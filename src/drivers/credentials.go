@@ -0,0 +1,101 @@
+package drivers
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 驱动器凭据存储（由元数据层的加密保险库实现）。凭据为字段名到值的映射，
+// 以驱动器名为键时字段名与该驱动器配置中的yaml字段相同
+type CredentialStore interface {
+	GetCredential(name string) (map[string]string, bool)
+	// 更新凭据中的字段，其他字段保留，值为空的字段删除
+	PutCredential(name string, fields map[string]string) error
+}
+
+var credentialStore struct {
+	store CredentialStore
+	mu    sync.RWMutex
+}
+
+// 设置凭据存储，需在创建驱动器之前调用。设置后驱动器配置中的凭据字段可以省略，
+// OAuth令牌也改为保存在凭据存储中
+func SetCredentialStore(store CredentialStore) {
+	credentialStore.mu.Lock()
+	defer credentialStore.mu.Unlock()
+	credentialStore.store = store
+}
+
+// 读取凭据，未设置凭据存储或没有该凭据时返回false
+func GetCredential(name string) (map[string]string, bool) {
+	credentialStore.mu.RLock()
+	store := credentialStore.store
+	credentialStore.mu.RUnlock()
+
+	if store == nil {
+		return nil, false
+	}
+	return store.GetCredential(name)
+}
+
+// 更新凭据（如令牌刷新、Cookie续期后），未设置凭据存储时忽略
+func UpdateCredential(name string, fields map[string]string) error {
+	credentialStore.mu.RLock()
+	store := credentialStore.store
+	credentialStore.mu.RUnlock()
+
+	if store == nil {
+		return nil
+	}
+	return store.PutCredential(name, fields)
+}
+
+// 将凭据存储中该驱动器的字段解码到驱动的配置结构体，覆盖配置文件中的同名字段
+func decodeCredential(name string, out interface{}) error {
+	fields, ok := GetCredential(name)
+	if !ok || len(fields) == 0 {
+		return nil
+	}
+	var node yaml.Node
+	if err := node.Encode(fields); err != nil {
+		return err
+	}
+	return node.Decode(out)
+}
+
+// OAuth令牌在凭据存储中的名称前缀，后接令牌存储键
+const oauthCredentialPrefix = "oauth:"
+
+func credentialToken(key string) (OAuthToken, bool) {
+	fields, ok := GetCredential(oauthCredentialPrefix + key)
+	if !ok {
+		return OAuthToken{}, false
+	}
+	token := OAuthToken{AccessToken: fields["access_token"], RefreshToken: fields["refresh_token"]}
+	if expires, err := time.Parse(time.RFC3339, fields["expires_at"]); err == nil {
+		token.ExpiresAt = expires
+	}
+	return token, token.RefreshToken != ""
+}
+
+// 将令牌写入凭据存储，未设置凭据存储时返回false
+func saveCredentialToken(key string, token OAuthToken) (bool, error) {
+	credentialStore.mu.RLock()
+	store := credentialStore.store
+	credentialStore.mu.RUnlock()
+
+	if store == nil {
+		return false, nil
+	}
+	fields := map[string]string{
+		"access_token":  token.AccessToken,
+		"refresh_token": token.RefreshToken,
+		"expires_at":    "",
+	}
+	if !token.ExpiresAt.IsZero() {
+		fields["expires_at"] = token.ExpiresAt.Format(time.RFC3339)
+	}
+	return true, store.PutCredential(oauthCredentialPrefix+key, fields)
+}
@@ -0,0 +1,339 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	dropboxAPIURL     = "https://api.dropboxapi.com/2"
+	dropboxContentURL = "https://content.dropboxapi.com/2"
+	dropboxTokenURL   = "https://api.dropboxapi.com/oauth2/token"
+
+	// 单次upload请求的上限为150MB，超过后改用上传会话
+	dropboxSimpleUploadLimit = 150 * 1024 * 1024
+	// 上传会话每次追加的大小
+	dropboxSessionChunkSize = 64 * 1024 * 1024
+)
+
+// Dropbox配置
+type DropboxConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	AppKey       string `yaml:"app_key"`
+	AppSecret    string `yaml:"app_secret"`
+	RefreshToken string `yaml:"refresh_token"`
+	AccessToken  string `yaml:"access_token"`
+	RootFolder   string `yaml:"root_folder"` // 存储目录，默认 /PanMatrix
+	Proxy        string `yaml:"proxy"`       // 覆盖http段的默认代理
+}
+
+// Dropbox驱动
+type DropboxDriver struct {
+	cfg    DropboxConfig
+	client *http.Client
+
+	tokens *TokenManager
+}
+
+func NewDropboxDriver(cfg DropboxConfig) (*DropboxDriver, error) {
+	if cfg.AccessToken == "" && cfg.RefreshToken == "" {
+		return nil, errors.New("Dropbox配置缺少access_token或refresh_token")
+	}
+	if cfg.RefreshToken != "" && cfg.AppKey == "" {
+		return nil, errors.New("使用refresh_token时需要配置app_key")
+	}
+	if cfg.RootFolder == "" {
+		cfg.RootFolder = "/PanMatrix"
+	}
+
+	client, err := newHTTPClient(10*time.Minute, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &DropboxDriver{
+		cfg:    cfg,
+		client: client,
+	}
+	d.tokens = NewTokenManager("Dropbox", OAuthToken{
+		AccessToken:  cfg.AccessToken,
+		RefreshToken: cfg.RefreshToken,
+	}, d.exchangeRefreshToken)
+	return d, nil
+}
+
+func (d *DropboxDriver) Connect() error {
+	_, _, err := d.GetUsage()
+	return err
+}
+
+func (d *DropboxDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// 查询账户空间作为探测
+func (d *DropboxDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error {
+		_, _, err := d.spaceUsage(ctx)
+		return err
+	})
+}
+
+func (d *DropboxDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	return d.spaceUsage(ctx)
+}
+
+// 查询账户空间（个人账户和团队账户的配额字段不同）
+func (d *DropboxDriver) spaceUsage(ctx context.Context) (int64, int64, error) {
+	var usage struct {
+		Used       int64 `json:"used"`
+		Allocation struct {
+			Tag       string `json:".tag"`
+			Allocated int64  `json:"allocated"`
+		} `json:"allocation"`
+	}
+
+	err := d.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, dropboxAPIURL+"/users/get_space_usage", nil)
+	}, &usage)
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取Dropbox空间失败: %v", err)
+	}
+
+	return usage.Used, usage.Allocation.Allocated, nil
+}
+
+// 上传数据块，超过150MB时使用上传会话
+func (d *DropboxDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	remotePath := d.remotePath(storageID)
+	commit := map[string]interface{}{
+		"path": remotePath,
+		"mode": "overwrite",
+		"mute": true,
+	}
+
+	if len(data) <= dropboxSimpleUploadLimit {
+		err := d.do(ctx, func() (*http.Request, error) {
+			return d.contentRequest(ctx, "/files/upload", commit, data)
+		}, nil)
+		if err != nil {
+			return "", fmt.Errorf("Dropbox上传失败: %v", err)
+		}
+		return storageID, nil
+	}
+
+	session, err := d.StartUploadSession(ctx, storageID, int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	return UploadWithSession(ctx, d, session, data, nil)
+}
+
+// 上传会话：start -> append_v2 ... -> finish，会话在finish前不占用空间，过期后自动清理
+func (d *DropboxDriver) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	var session struct {
+		SessionID string `json:"session_id"`
+	}
+	err := d.do(ctx, func() (*http.Request, error) {
+		return d.contentRequest(ctx, "/files/upload_session/start", map[string]bool{"close": false}, nil)
+	}, &session)
+	if err != nil {
+		return nil, fmt.Errorf("创建Dropbox上传会话失败: %v", err)
+	}
+
+	return &UploadSession{
+		StorageID: storageID,
+		UploadID:  session.SessionID,
+		Size:      size,
+		PartSize:  dropboxSessionChunkSize,
+		StartedAt: time.Now(),
+	}, nil
+}
+
+// 追加一个分片，偏移量由分片序号决定，因此分片必须按顺序上传
+func (d *DropboxDriver) UploadPart(ctx context.Context, session *UploadSession, partIndex int, data []byte) error {
+	offset, _ := session.PartRange(partIndex)
+	arg := map[string]interface{}{
+		"cursor": map[string]interface{}{"session_id": session.UploadID, "offset": offset},
+		"close":  false,
+	}
+	err := d.do(ctx, func() (*http.Request, error) {
+		return d.contentRequest(ctx, "/files/upload_session/append_v2", arg, data)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("Dropbox会话上传失败[offset=%d]: %v", offset, err)
+	}
+	return nil
+}
+
+func (d *DropboxDriver) CompleteSession(ctx context.Context, session *UploadSession) (string, error) {
+	arg := map[string]interface{}{
+		"cursor": map[string]interface{}{"session_id": session.UploadID, "offset": session.Size},
+		"commit": map[string]interface{}{
+			"path": d.remotePath(session.StorageID),
+			"mode": "overwrite",
+			"mute": true,
+		},
+	}
+
+	err := d.do(ctx, func() (*http.Request, error) {
+		return d.contentRequest(ctx, "/files/upload_session/finish", arg, nil)
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("完成Dropbox上传会话失败: %v", err)
+	}
+	return session.StorageID, nil
+}
+
+// 列出存储目录下的文件：list_folder -> list_folder/continue ...
+func (d *DropboxDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	endpoint := "/files/list_folder"
+	var body interface{} = map[string]interface{}{"path": d.remotePath(""), "limit": 2000}
+
+	var chunks []ChunkInfo
+	for {
+		var page struct {
+			Entries []struct {
+				Tag            string    `json:".tag"`
+				Name           string    `json:"name"`
+				Size           int64     `json:"size"`
+				ServerModified time.Time `json:"server_modified"`
+			} `json:"entries"`
+			Cursor  string `json:"cursor"`
+			HasMore bool   `json:"has_more"`
+		}
+		err := d.do(ctx, func() (*http.Request, error) {
+			return newJSONRequest(ctx, http.MethodPost, dropboxAPIURL+endpoint, body)
+		}, &page)
+		if errors.Is(err, ErrChunkNotFound) {
+			return nil, nil // 存储目录尚未创建
+		}
+		if err != nil {
+			return nil, fmt.Errorf("列举Dropbox文件失败: %v", err)
+		}
+
+		for _, entry := range page.Entries {
+			if entry.Tag == "file" {
+				chunks = appendChunk(chunks, prefix, ChunkInfo{StorageID: entry.Name, Size: entry.Size, ModTime: entry.ServerModified})
+			}
+		}
+		if !page.HasMore {
+			return chunks, nil
+		}
+		endpoint = "/files/list_folder/continue"
+		body = map[string]string{"cursor": page.Cursor}
+	}
+}
+
+func (d *DropboxDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	var data []byte
+	err := d.do(ctx, func() (*http.Request, error) {
+		return d.contentRequest(ctx, "/files/download", map[string]string{"path": d.remotePath(storageID)}, nil)
+	}, &data)
+	if err != nil {
+		return nil, fmt.Errorf("Dropbox下载失败: %v", err)
+	}
+	return data, nil
+}
+
+func (d *DropboxDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	err := d.do(ctx, func() (*http.Request, error) {
+		return newJSONRequest(ctx, http.MethodPost, dropboxAPIURL+"/files/delete_v2",
+			map[string]string{"path": d.remotePath(storageID)})
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("Dropbox删除失败: %v", err)
+	}
+	return nil
+}
+
+func (d *DropboxDriver) RenameChunk(ctx context.Context, storageID, newStorageID string) error {
+	err := d.do(ctx, func() (*http.Request, error) {
+		return newJSONRequest(ctx, http.MethodPost, dropboxAPIURL+"/files/move_v2", map[string]interface{}{
+			"from_path":  d.remotePath(storageID),
+			"to_path":    d.remotePath(newStorageID),
+			"autorename": false,
+		})
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("Dropbox重命名失败: %v", err)
+	}
+	return nil
+}
+
+// 构造content端点请求，参数通过Dropbox-API-Arg头传递
+func (d *DropboxDriver) contentRequest(ctx context.Context, endpoint string, arg interface{}, body []byte) (*http.Request, error) {
+	argJSON, err := json.Marshal(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dropboxContentURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Dropbox-API-Arg", string(argJSON))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	return req, nil
+}
+
+// 发送带访问令牌的请求，401时刷新令牌后重试一次
+func (d *DropboxDriver) do(ctx context.Context, build func() (*http.Request, error), out interface{}) error {
+	return d.tokens.Do(ctx, func(accessToken string) error {
+		req, err := build()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		if raw, ok := out.(*[]byte); ok {
+			*raw, err = doBytes(d.client, req)
+		} else {
+			err = doJSON(d.client, req, out)
+		}
+		return dropboxError(err)
+	})
+}
+
+// Dropbox用409表示路径不存在等业务错误
+func dropboxError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict &&
+		strings.Contains(apiErr.Body, "not_found") {
+		return fmt.Errorf("%w: %v", ErrChunkNotFound, apiErr)
+	}
+	return err
+}
+
+// Dropbox的刷新令牌长期有效，响应中不返回新的刷新令牌
+func (d *DropboxDriver) exchangeRefreshToken(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {d.cfg.AppKey},
+	}
+	if d.cfg.AppSecret != "" {
+		form.Set("client_secret", d.cfg.AppSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dropboxTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return requestOAuthToken(d.client, req)
+}
+
+func (d *DropboxDriver) remotePath(storageID string) string {
+	return path.Join("/", d.cfg.RootFolder, storageID)
+}
@@ -0,0 +1,260 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 加密数据块格式：magic(4) | keyID长度(1) | keyID | nonce(12) | 密文+GCM标签(16)
+// 头部和数据块的存储ID作为附加认证数据，篡改keyID或把数据块换到其他位置都会导致解密失败
+var encryptedMagic = []byte("PME2")
+
+// 附加认证数据只包含头部的旧格式，只用于读取
+var encryptedMagicV1 = []byte("PME1")
+
+type storageIDContextKey struct{}
+
+// 指定下载的数据块上传时的存储ID，按远程ID（如IPFS的CID）下载时由调用方提供。
+// 未指定时按下载使用的ID校验
+func WithStorageID(ctx context.Context, storageID string) context.Context {
+	return context.WithValue(ctx, storageIDContextKey{}, storageID)
+}
+
+func storageIDFrom(ctx context.Context, key string) string {
+	if id, ok := ctx.Value(storageIDContextKey{}).(string); ok && id != "" {
+		return id
+	}
+	return key
+}
+
+var ErrDecrypt = errors.New("数据块解密失败")
+
+// 驱动器加密配置，只对配置了encryption的驱动器生效
+//
+//	drives:
+//	  - type: baidu
+//	    encryption:
+//	      key_id: k2
+//	      key_file: /etc/panmatrix/baidu.key
+//	      old_keys:            # 轮换前的密钥，只用于解密
+//	        k1: "hex或base64编码的32字节密钥"
+type EncryptionConfig struct {
+	KeyID          string            `yaml:"key_id"`   // 写入数据块头部，默认 default
+	Key            string            `yaml:"key"`      // hex或base64编码的32字节AES-256密钥
+	KeyFile        string            `yaml:"key_file"` // 从文件读取密钥（内容同key），与key二选一
+	OldKeys        map[string]string `yaml:"old_keys"`
+	AllowPlaintext bool              `yaml:"allow_plaintext"` // 允许读取启用加密前上传的明文数据块
+}
+
+// 透明加密驱动包装器：上传前以AES-256-GCM加密，下载后解密
+//
+// 分片会话按明文分片上传无法加密整块，因此对调用方报告ErrSessionUnsupported，
+// 由UploadChunk加密整块后交给被包装的驱动器自行处理大文件上传。
+type EncryptedDriver struct {
+	baseWrapper
+
+	keyID          string
+	aeads          map[string]cipher.AEAD
+	allowPlaintext bool
+}
+
+func NewEncryptedDriver(inner StorageDriver, cfg EncryptionConfig) (*EncryptedDriver, error) {
+	if cfg.KeyID == "" {
+		cfg.KeyID = "default"
+	}
+	if len(cfg.KeyID) > 255 {
+		return nil, errors.New("加密key_id过长")
+	}
+
+	keyText := cfg.Key
+	if cfg.KeyFile != "" {
+		if keyText != "" {
+			return nil, errors.New("加密配置中key和key_file只能设置一个")
+		}
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取加密密钥失败: %v", err)
+		}
+		keyText = string(data)
+	}
+	if keyText == "" {
+		return nil, errors.New("加密配置缺少key或key_file")
+	}
+
+	d := &EncryptedDriver{
+		baseWrapper:    baseWrapper{inner: inner},
+		keyID:          cfg.KeyID,
+		aeads:          make(map[string]cipher.AEAD),
+		allowPlaintext: cfg.AllowPlaintext,
+	}
+	keys := map[string]string{cfg.KeyID: keyText}
+	for id, key := range cfg.OldKeys {
+		if id != cfg.KeyID {
+			keys[id] = key
+		}
+	}
+	for id, key := range keys {
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, fmt.Errorf("加密密钥%s无效: %v", id, err)
+		}
+		d.aeads[id] = aead
+	}
+	return d, nil
+}
+
+func (d *EncryptedDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	sealed, err := d.seal(data, storageID)
+	if err != nil {
+		return "", err
+	}
+	return d.inner.UploadChunk(ctx, sealed, storageID)
+}
+
+func (d *EncryptedDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	data, err := d.inner.DownloadChunk(ctx, storageID)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := d.open(data, storageIDFrom(ctx, storageID))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", storageID, err)
+	}
+	return plain, nil
+}
+
+// 单块上限扣除加密头和认证标签
+func (d *EncryptedDriver) MaxChunkSize() int64 {
+	limit := MaxChunkSizeOf(d.inner)
+	if limit <= 0 {
+		return 0
+	}
+	return max(limit-int64(d.overhead()), 1)
+}
+
+// 密文无法按明文偏移截取，也不支持分片会话；密文绑定了存储ID，不能重命名
+func (d *EncryptedDriver) Capabilities() Capabilities {
+	caps := CapabilitiesOf(d.inner)
+	caps.MaxChunkSize = d.MaxChunkSize()
+	caps.Rename = false
+	caps.RangeDownload = false
+	caps.Multipart = false
+	caps.MaxParallelParts = 0
+	return caps
+}
+
+// 下载整块解密后截取
+func (d *EncryptedDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	plain, err := d.DownloadChunk(ctx, storageID)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(plain, offset, length), nil
+}
+
+func (d *EncryptedDriver) RenameChunk(ctx context.Context, storageID, newStorageID string) error {
+	return ErrRenameUnsupported
+}
+
+func (d *EncryptedDriver) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	return nil, ErrSessionUnsupported
+}
+
+func (d *EncryptedDriver) overhead() int {
+	return len(encryptedMagic) + 1 + len(d.keyID) + d.aeads[d.keyID].NonceSize() + d.aeads[d.keyID].Overhead()
+}
+
+func (d *EncryptedDriver) seal(data []byte, storageID string) ([]byte, error) {
+	aead := d.aeads[d.keyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成nonce失败: %v", err)
+	}
+
+	header := make([]byte, 0, len(encryptedMagic)+1+len(d.keyID))
+	header = append(header, encryptedMagic...)
+	header = append(header, byte(len(d.keyID)))
+	header = append(header, d.keyID...)
+
+	out := make([]byte, 0, d.overhead()+len(data))
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, additionalData(header, storageID)), nil
+}
+
+// 附加认证数据：头部之后接存储ID
+func additionalData(header []byte, storageID string) []byte {
+	ad := make([]byte, 0, len(header)+len(storageID))
+	ad = append(ad, header...)
+	return append(ad, storageID...)
+}
+
+func (d *EncryptedDriver) open(data []byte, storageID string) ([]byte, error) {
+	legacy := bytes.HasPrefix(data, encryptedMagicV1)
+	if !legacy && !bytes.HasPrefix(data, encryptedMagic) {
+		if d.allowPlaintext {
+			return data, nil
+		}
+		return nil, fmt.Errorf("%w: 数据块未加密", ErrDecrypt)
+	}
+
+	pos := len(encryptedMagic)
+	if len(data) < pos+1 {
+		return nil, fmt.Errorf("%w: 头部不完整", ErrDecrypt)
+	}
+	idLen := int(data[pos])
+	pos++
+	if len(data) < pos+idLen {
+		return nil, fmt.Errorf("%w: 头部不完整", ErrDecrypt)
+	}
+	keyID := string(data[pos : pos+idLen])
+	pos += idLen
+
+	aead, ok := d.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: 未知的密钥 %s", ErrDecrypt, keyID)
+	}
+	if len(data) < pos+aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: 数据不完整", ErrDecrypt)
+	}
+
+	ad := data[:pos]
+	if !legacy {
+		ad = additionalData(ad, storageID)
+	}
+	nonce := data[pos : pos+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, data[pos+aead.NonceSize():], ad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 数据已损坏、密钥错误或数据块不属于该位置", ErrDecrypt)
+	}
+	return plain, nil
+}
+
+// 解析hex或base64编码的32字节密钥
+func newGCM(text string) (cipher.AEAD, error) {
+	text = strings.TrimSpace(text)
+	key, err := hex.DecodeString(text)
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(text); err != nil {
+			return nil, errors.New("密钥应为hex或base64编码")
+		}
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("密钥应为32字节，实际%d字节", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,89 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedDriverBindsStorageID(t *testing.T) {
+	mem, err := NewMemoryDriver(MemoryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewEncryptedDriver(mem, EncryptionConfig{Key: strings.Repeat("ab", 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		if _, err := d.UploadChunk(ctx, []byte("data-"+id), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 把b的密文放到a的位置
+	swapped, _ := mem.DownloadChunk(ctx, "b")
+	if _, err := mem.UploadChunk(ctx, swapped, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"原位置", ctx, "b", "data-b", false},
+		{"换到其他位置", ctx, "a", "", true},
+		{"按远程ID下载时指定存储ID", WithStorageID(ctx, "b"), "a", "data-b", false},
+		{"指定的存储ID不符", WithStorageID(ctx, "a"), "b", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.DownloadChunk(tt.ctx, tt.key)
+			if tt.wantErr {
+				if !errors.Is(err, ErrDecrypt) {
+					t.Fatalf("期望解密失败，实际 %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("解密结果 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptedDriverReadsLegacyChunks(t *testing.T) {
+	mem, err := NewMemoryDriver(MemoryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewEncryptedDriver(mem, EncryptionConfig{Key: strings.Repeat("cd", 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 按旧格式加密：附加认证数据只有头部
+	aead := d.aeads[d.keyID]
+	header := append(append(append([]byte(nil), encryptedMagicV1...), byte(len(d.keyID))), d.keyID...)
+	nonce := make([]byte, aead.NonceSize())
+	sealed := aead.Seal(append(append([]byte(nil), header...), nonce...), nonce, []byte("legacy"), header)
+	if _, err := mem.UploadChunk(context.Background(), sealed, "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.DownloadChunk(context.Background(), "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte("legacy")) {
+		t.Fatalf("解密结果 %q", got)
+	}
+}
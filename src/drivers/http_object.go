@@ -0,0 +1,224 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 通用HTTP对象存储配置：任何支持 PUT/GET/DELETE <base_url>/<storageID> 的服务
+type HTTPObjectConfig struct {
+	Enabled     bool              `yaml:"enabled"`
+	BaseURL     string            `yaml:"base_url"` // 对象地址前缀，如 https://store.example.com/chunks
+	Headers     map[string]string `yaml:"headers"`  // 每个请求附带的头，如 Authorization: Bearer xxx
+	Username    string            `yaml:"username"` // 可选的Basic认证
+	Password    string            `yaml:"password"`
+	UsageURL    string            `yaml:"usage_url"`    // 可选，返回 {"used":..,"total":..} 的容量接口
+	QuotaBytes  int64             `yaml:"quota_bytes"`  // 未配置usage_url时报告的容量，0表示未知
+	HealthURL   string            `yaml:"health_url"`   // 可选的健康检查地址，默认对base_url发HEAD请求
+	ListURL     string            `yaml:"list_url"`     // 可选，返回 [{"storage_id":..,"size":..}] 的列举接口
+	TimeoutSecs int               `yaml:"timeout_secs"` // 单次请求超时，默认600秒
+	Proxy       string            `yaml:"proxy"`        // 覆盖http段的默认代理
+}
+
+// 通用HTTP对象驱动
+type HTTPObjectDriver struct {
+	cfg    HTTPObjectConfig
+	client *http.Client
+}
+
+func NewHTTPObjectDriver(cfg HTTPObjectConfig) (*HTTPObjectDriver, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("HTTP驱动配置缺少base_url")
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	timeout := 10 * time.Minute
+	if cfg.TimeoutSecs > 0 {
+		timeout = time.Duration(cfg.TimeoutSecs) * time.Second
+	}
+
+	client, err := newHTTPClient(timeout, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTPObjectDriver{
+		cfg:    cfg,
+		client: client,
+	}, nil
+}
+
+func (d *HTTPObjectDriver) Connect() error {
+	if !d.IsAvailable() {
+		return fmt.Errorf("HTTP存储 %s 不可访问", d.cfg.BaseURL)
+	}
+	return nil
+}
+
+func (d *HTTPObjectDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// 对健康检查地址发HEAD请求，任何非5xx响应都视为服务在线
+func (d *HTTPObjectDriver) Ping(ctx context.Context) (time.Duration, error) {
+	target := d.cfg.HealthURL
+	if target == "" {
+		target = d.cfg.BaseURL + "/"
+	}
+	req, err := d.newRequest(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return timePing(func() error {
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return &APIError{StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
+}
+
+func (d *HTTPObjectDriver) GetUsage() (int64, int64, error) {
+	if d.cfg.UsageURL == "" {
+		return 0, d.cfg.QuotaBytes, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	req, err := d.newRequest(ctx, http.MethodGet, d.cfg.UsageURL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	var usage struct {
+		Used  int64 `json:"used"`
+		Total int64 `json:"total"`
+	}
+	if err := doJSON(d.client, req, &usage); err != nil {
+		return 0, 0, fmt.Errorf("获取HTTP存储容量失败: %v", err)
+	}
+	return usage.Used, usage.Total, nil
+}
+
+func (d *HTTPObjectDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	req, err := d.newRequest(ctx, http.MethodPut, d.objectURL(storageID), data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	if err := doJSON(d.client, req, nil); err != nil {
+		return "", fmt.Errorf("HTTP上传失败: %v", err)
+	}
+	return storageID, nil
+}
+
+func (d *HTTPObjectDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, d.objectURL(storageID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := doBytes(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP下载失败: %v", err)
+	}
+	return data, nil
+}
+
+func (d *HTTPObjectDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, d.objectURL(storageID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := doRange(d.client, req, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP下载失败: %v", err)
+	}
+	return data, nil
+}
+
+// 删除对象，对象已不存在时视为成功
+func (d *HTTPObjectDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	req, err := d.newRequest(ctx, http.MethodDelete, d.objectURL(storageID), nil)
+	if err != nil {
+		return err
+	}
+
+	if err := doJSON(d.client, req, nil); err != nil && !errors.Is(err, ErrChunkNotFound) {
+		return fmt.Errorf("HTTP删除失败: %v", err)
+	}
+	return nil
+}
+
+// 通过list_url列举对象，prefix以查询参数传给服务端，未配置时不支持列举
+func (d *HTTPObjectDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	if d.cfg.ListURL == "" {
+		return nil, ErrListUnsupported
+	}
+
+	target := d.cfg.ListURL
+	if prefix != "" {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + url.Values{"prefix": {prefix}}.Encode()
+	}
+	req, err := d.newRequest(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []struct {
+		StorageID string    `json:"storage_id"`
+		Size      int64     `json:"size"`
+		ModTime   time.Time `json:"mod_time"`
+	}
+	if err := doJSON(d.client, req, &objects); err != nil {
+		return nil, fmt.Errorf("列举HTTP存储对象失败: %v", err)
+	}
+
+	var chunks []ChunkInfo
+	for _, obj := range objects {
+		chunks = appendChunk(chunks, prefix, ChunkInfo{StorageID: obj.StorageID, Size: obj.Size, ModTime: obj.ModTime})
+	}
+	return chunks, nil
+}
+
+// 构造附带认证头的请求
+func (d *HTTPObjectDriver) newRequest(ctx context.Context, method, target string, body []byte) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, target, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range d.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if d.cfg.Username != "" {
+		req.SetBasicAuth(d.cfg.Username, d.cfg.Password)
+	}
+	return req, nil
+}
+
+func (d *HTTPObjectDriver) objectURL(storageID string) string {
+	return d.cfg.BaseURL + "/" + escapePath(storageID)
+}
@@ -0,0 +1,104 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// IsAvailable/GetUsage 等无上下文调用的超时时间
+const probeTimeout = 10 * time.Second
+
+// 远程API返回的错误
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// 远程对象不存在
+var ErrChunkNotFound = errors.New("远程对象不存在")
+
+// 执行请求并检查状态码，非2xx时返回APIError（404返回ErrChunkNotFound）
+func doRequest(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %v", ErrChunkNotFound, apiErr)
+		}
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+// 执行请求并将JSON响应解码到out（out为nil时丢弃响应体）
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := doRequest(client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	return nil
+}
+
+// 执行请求并读取完整响应体
+func doBytes(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := doRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// 构造JSON请求体的请求
+func newJSONRequest(ctx context.Context, method, url string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("序列化请求失败: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// 是否为认证失败（令牌过期等）
+func isUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
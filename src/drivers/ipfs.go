@@ -0,0 +1,215 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// IPFS配置：通过Kubo RPC接口（/api/v0）访问本地或远程节点
+type IPFSConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	APIURL     string `yaml:"api_url"`     // 节点RPC地址，默认 http://127.0.0.1:5001
+	AuthHeader string `yaml:"auth_header"` // 远程节点的Authorization头，如 Basic xxx / Bearer xxx
+	CIDVersion int    `yaml:"cid_version"` // 默认1
+	QuotaBytes int64  `yaml:"quota_bytes"` // 覆盖节点报告的StorageMax，0表示使用节点配置
+	Proxy      string `yaml:"proxy"`       // 覆盖http段的默认代理
+}
+
+// IPFS驱动：数据块以固定方式加入节点，存储ID即CID
+type IPFSDriver struct {
+	cfg    IPFSConfig
+	client *http.Client
+}
+
+func NewIPFSDriver(cfg IPFSConfig) (*IPFSDriver, error) {
+	if cfg.APIURL == "" {
+		cfg.APIURL = "http://127.0.0.1:5001"
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+	if cfg.CIDVersion == 0 {
+		cfg.CIDVersion = 1
+	}
+	if cfg.CIDVersion != 1 && cfg.CIDVersion != 0 {
+		return nil, fmt.Errorf("不支持的CID版本: %d", cfg.CIDVersion)
+	}
+
+	client, err := newHTTPClient(10*time.Minute, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IPFSDriver{
+		cfg:    cfg,
+		client: client,
+	}, nil
+}
+
+func (d *IPFSDriver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	var id struct {
+		ID string `json:"ID"`
+	}
+	if err := d.rpc(ctx, "id", nil, nil, &id); err != nil {
+		return fmt.Errorf("连接IPFS节点失败: %v", err)
+	}
+	return nil
+}
+
+func (d *IPFSDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+func (d *IPFSDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error { return d.rpc(ctx, "id", nil, nil, nil) })
+}
+
+// 使用repo/stat获取节点仓库大小和上限
+func (d *IPFSDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var stat struct {
+		RepoSize   int64 `json:"RepoSize"`
+		StorageMax int64 `json:"StorageMax"`
+	}
+	if err := d.rpc(ctx, "repo/stat", url.Values{"size-only": {"true"}}, nil, &stat); err != nil {
+		return 0, 0, fmt.Errorf("获取IPFS仓库状态失败: %v", err)
+	}
+
+	total := stat.StorageMax
+	if d.cfg.QuotaBytes > 0 {
+		total = d.cfg.QuotaBytes
+	}
+	return stat.RepoSize, total, nil
+}
+
+// 加入并固定数据块，返回CID作为存储ID（storageID仅作为文件名提示）
+func (d *IPFSDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", storageID)
+	if err != nil {
+		return "", err
+	}
+	part.Write(data)
+	form.Close()
+
+	query := url.Values{
+		"pin":         {"true"},
+		"pin-name":    {storageID}, // 记录storageID，供列举时还原（Kubo 0.29+）
+		"cid-version": {fmt.Sprint(d.cfg.CIDVersion)},
+		"quieter":     {"true"},
+	}
+	var added struct {
+		Hash string `json:"Hash"`
+	}
+	if err := d.rpc(ctx, "add", query, &rpcBody{form.FormDataContentType(), body.Bytes()}, &added); err != nil {
+		return "", fmt.Errorf("IPFS上传失败: %v", err)
+	}
+	if added.Hash == "" {
+		return "", errors.New("IPFS上传失败: 节点未返回CID")
+	}
+	return added.Hash, nil
+}
+
+func (d *IPFSDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	req, err := d.newRequest(ctx, "cat", url.Values{"arg": {storageID}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := doBytes(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("IPFS下载失败: %v", ipfsError(err))
+	}
+	return data, nil
+}
+
+// 取消固定；数据在节点下次垃圾回收时释放
+func (d *IPFSDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	err := d.rpc(ctx, "pin/rm", url.Values{"arg": {storageID}}, nil, nil)
+	if err != nil && !errors.Is(err, ErrChunkNotFound) {
+		return fmt.Errorf("IPFS取消固定失败: %v", err)
+	}
+	return nil
+}
+
+// 列出带名称的递归固定，名称为上传时的storageID；未命名的固定不属于PanMatrix，跳过
+func (d *IPFSDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	var pins struct {
+		Keys map[string]struct {
+			Name string `json:"Name"`
+		} `json:"Keys"`
+	}
+	query := url.Values{"type": {"recursive"}, "names": {"true"}}
+	if err := d.rpc(ctx, "pin/ls", query, nil, &pins); err != nil {
+		return nil, fmt.Errorf("列举IPFS固定失败: %v", err)
+	}
+
+	var chunks []ChunkInfo
+	for cid, pin := range pins.Keys {
+		if pin.Name != "" {
+			chunks = appendChunk(chunks, prefix, ChunkInfo{StorageID: pin.Name, RemoteID: cid})
+		}
+	}
+	return chunks, nil
+}
+
+// RPC请求体
+type rpcBody struct {
+	contentType string
+	data        []byte
+}
+
+// 调用Kubo RPC接口（所有命令均为POST）
+func (d *IPFSDriver) rpc(ctx context.Context, command string, query url.Values, body *rpcBody, out interface{}) error {
+	req, err := d.newRequest(ctx, command, query, body)
+	if err != nil {
+		return err
+	}
+	return ipfsError(doJSON(d.client, req, out))
+}
+
+func (d *IPFSDriver) newRequest(ctx context.Context, command string, query url.Values, body *rpcBody) (*http.Request, error) {
+	target := d.cfg.APIURL + "/api/v0/" + command
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body.data))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", body.contentType)
+	}
+	if d.cfg.AuthHeader != "" {
+		req.Header.Set("Authorization", d.cfg.AuthHeader)
+	}
+	return req, nil
+}
+
+// Kubo以500返回错误信息，将未固定/无法解析的CID映射为ErrChunkNotFound
+func ipfsError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) &&
+		(strings.Contains(apiErr.Body, "not pinned") || strings.Contains(apiErr.Body, "not found")) {
+		return fmt.Errorf("%w: %v", ErrChunkNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var ErrLoginUnsupported = errors.New("驱动器不支持交互登录")
+
+// 支持在终端交互登录的驱动器（设备码或扫码登录）。登录得到的令牌写入令牌存储，
+// 配置文件中不再需要填写access_token/refresh_token
+type Loginer interface {
+	Login(ctx context.Context, out io.Writer) error
+}
+
+// 执行交互登录，驱动器不支持时返回ErrLoginUnsupported
+func Login(ctx context.Context, driver StorageDriver, out io.Writer) error {
+	loginer, ok := driver.(Loginer)
+	if !ok {
+		return ErrLoginUnsupported
+	}
+	return loginer.Login(ctx, out)
+}
+
+// OAuth设备码（RFC 8628）
+type deviceCode struct {
+	DeviceCode      string
+	UserCode        string
+	VerificationURI string // 用户打开的验证地址
+	CompleteURI     string // 已包含用户码的验证地址，扫码后无需再输入，可为空
+	Interval        time.Duration
+	ExpiresAt       time.Time
+}
+
+// 在终端显示验证地址、用户码和二维码
+func showDeviceCode(out io.Writer, provider string, code *deviceCode) {
+	fmt.Fprintf(out, "请在浏览器中打开 %s 并输入代码 %s 登录%s\n", code.VerificationURI, code.UserCode, provider)
+
+	target := code.CompleteURI
+	if target == "" {
+		target = code.VerificationURI
+	}
+	if qr, err := encodeQR(target); err == nil {
+		fmt.Fprintln(out, "也可以用手机扫描二维码：")
+		qr.render(out)
+	}
+	fmt.Fprintf(out, "等待授权（%s内有效）...\n", time.Until(code.ExpiresAt).Round(time.Second))
+}
+
+// 每隔Interval用设备码换取令牌，直到用户完成授权、拒绝授权或设备码过期
+func pollDeviceToken(ctx context.Context, code *deviceCode, exchange func(ctx context.Context) (*OAuthToken, error)) (*OAuthToken, error) {
+	ctx, cancel := context.WithDeadline(ctx, code.ExpiresAt)
+	defer cancel()
+
+	interval := code.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.New("设备码已过期，请重新登录")
+			}
+			return nil, ctx.Err()
+		}
+
+		token, err := exchange(ctx)
+		if err == nil {
+			return token, nil
+		}
+		switch oauthErrorCode(err) {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "expired_token":
+			return nil, errors.New("设备码已过期，请重新登录")
+		case "access_denied", "authorization_declined":
+			return nil, errors.New("用户拒绝了授权")
+		default:
+			return nil, err
+		}
+	}
+}
+
+// OAuth错误响应中的error字段，如 authorization_pending
+func oauthErrorCode(err error) string {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal([]byte(apiErr.Body), &body)
+	return body.Error
+}
@@ -0,0 +1,173 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrCapacityExceeded = errors.New("驱动器空间不足")
+
+// 内存驱动配置，用于测试和性能基准，不访问网络和磁盘
+type MemoryConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	CapacityBytes int64         `yaml:"capacity_bytes"`  // 容量，0表示不限
+	Latency       time.Duration `yaml:"latency"`         // 每个请求的固定延迟
+	BytesPerSec   int64         `yaml:"bytes_per_sec"`   // 模拟传输速度，0表示不限
+	MaxChunkBytes int64         `yaml:"max_chunk_bytes"` // 模拟单文件大小上限，0表示不限
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+// 内存驱动，进程退出后数据丢失
+type MemoryDriver struct {
+	cfg     MemoryConfig
+	objects map[string]memoryObject
+	used    int64
+	mu      sync.RWMutex
+}
+
+func NewMemoryDriver(cfg MemoryConfig) (*MemoryDriver, error) {
+	if cfg.CapacityBytes < 0 || cfg.BytesPerSec < 0 || cfg.MaxChunkBytes < 0 {
+		return nil, errors.New("内存驱动配置不能为负数")
+	}
+	return &MemoryDriver{cfg: cfg, objects: make(map[string]memoryObject)}, nil
+}
+
+func (d *MemoryDriver) Connect() error    { return nil }
+func (d *MemoryDriver) IsAvailable() bool { return true }
+
+func (d *MemoryDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error { return d.simulate(ctx, 0) })
+}
+
+func (d *MemoryDriver) GetUsage() (int64, int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.used, d.cfg.CapacityBytes, nil
+}
+
+func (d *MemoryDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	if d.cfg.MaxChunkBytes > 0 && int64(len(data)) > d.cfg.MaxChunkBytes {
+		return "", fmt.Errorf("数据块大小%d超过上限%d", len(data), d.cfg.MaxChunkBytes)
+	}
+	if err := d.simulate(ctx, len(data)); err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	used := d.used - int64(len(d.objects[storageID].data)) + int64(len(data))
+	if d.cfg.CapacityBytes > 0 && used > d.cfg.CapacityBytes {
+		return "", fmt.Errorf("%w: 需要%d字节，容量%d字节", ErrCapacityExceeded, used, d.cfg.CapacityBytes)
+	}
+	d.objects[storageID] = memoryObject{data: append([]byte(nil), data...), modTime: time.Now()}
+	d.used = used
+	return storageID, nil
+}
+
+func (d *MemoryDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	d.mu.RLock()
+	obj, ok := d.objects[storageID]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChunkNotFound, storageID)
+	}
+
+	if err := d.simulate(ctx, len(obj.data)); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), obj.data...), nil
+}
+
+func (d *MemoryDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	d.mu.RLock()
+	obj, ok := d.objects[storageID]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChunkNotFound, storageID)
+	}
+
+	data := sliceRange(obj.data, offset, length)
+	if err := d.simulate(ctx, len(data)); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (d *MemoryDriver) RenameChunk(ctx context.Context, storageID, newStorageID string) error {
+	if err := d.simulate(ctx, 0); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	obj, ok := d.objects[storageID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrChunkNotFound, storageID)
+	}
+	if _, ok := d.objects[newStorageID]; ok {
+		return fmt.Errorf("目标已存在: %s", newStorageID)
+	}
+	delete(d.objects, storageID)
+	d.objects[newStorageID] = obj
+	return nil
+}
+
+func (d *MemoryDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	if err := d.simulate(ctx, 0); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if obj, ok := d.objects[storageID]; ok {
+		d.used -= int64(len(obj.data))
+		delete(d.objects, storageID)
+	}
+	return nil
+}
+
+func (d *MemoryDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var chunks []ChunkInfo
+	for id, obj := range d.objects {
+		if strings.HasPrefix(id, prefix) {
+			chunks = append(chunks, ChunkInfo{StorageID: id, Size: int64(len(obj.data)), ModTime: obj.modTime})
+		}
+	}
+	return chunks, nil
+}
+
+func (d *MemoryDriver) MaxChunkSize() int64 {
+	return d.cfg.MaxChunkBytes
+}
+
+// 按配置的延迟和速度等待
+func (d *MemoryDriver) simulate(ctx context.Context, size int) error {
+	delay := d.cfg.Latency
+	if d.cfg.BytesPerSec > 0 {
+		delay += time.Duration(float64(size) / float64(d.cfg.BytesPerSec) * float64(time.Second))
+	}
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
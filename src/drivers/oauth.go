@@ -0,0 +1,290 @@
+package drivers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 访问令牌在过期前多久主动刷新
+const tokenRefreshSkew = 5 * time.Minute
+
+// OAuth令牌
+type OAuthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"` // 零值表示未知，只在401时刷新
+}
+
+// 使用刷新令牌换取新令牌，返回的RefreshToken为空时沿用原刷新令牌
+type TokenRefreshFunc func(ctx context.Context, refreshToken string) (*OAuthToken, error)
+
+// OAuth令牌管理：过期前主动刷新，请求返回401时刷新后重试一次，
+// 刷新得到的新令牌写入令牌存储，重启后无需重新配置
+type TokenManager struct {
+	name    string // 用于错误信息，如 OneDrive
+	key     string // 令牌存储中的键
+	account string // 交互登录的驱动器名，配置中没有刷新令牌时使用
+	refresh TokenRefreshFunc
+
+	token     OAuthToken
+	mu        sync.Mutex
+	refreshMu sync.Mutex // 串行化刷新，避免并发请求同时消耗刷新令牌
+}
+
+// 创建令牌管理器，令牌存储中已有更新的令牌时优先使用
+//
+// 存储键由驱动名称和配置中的刷新令牌派生，配置了新的刷新令牌后旧的存储记录自动失效。
+func NewTokenManager(name string, initial OAuthToken, refresh TokenRefreshFunc) *TokenManager {
+	m := &TokenManager{name: name, refresh: refresh, token: initial}
+	if initial.RefreshToken != "" {
+		sum := sha256.Sum256([]byte(initial.RefreshToken))
+		m.key = name + ":" + hex.EncodeToString(sum[:8])
+		if saved, ok := loadStoredToken(m.key); ok {
+			m.token = saved
+		}
+	}
+	return m
+}
+
+// 配置中没有刷新令牌时改用交互登录保存的令牌，存储键为 驱动名称@驱动器名
+func (m *TokenManager) UseLogin(account string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.account = account
+	if m.key != "" {
+		return
+	}
+	m.key = m.name + "@" + account
+	if saved, ok := loadStoredToken(m.key); ok {
+		m.token = saved
+	}
+}
+
+// 保存交互登录得到的令牌
+func (m *TokenManager) SetToken(token OAuthToken) error {
+	m.mu.Lock()
+	m.token = token
+	key := m.key
+	m.mu.Unlock()
+
+	if key == "" {
+		return fmt.Errorf("%s令牌没有存储位置", m.name)
+	}
+	if err := saveStoredToken(key, token); err != nil {
+		return fmt.Errorf("保存%s令牌失败: %v", m.name, err)
+	}
+	return nil
+}
+
+// 返回有效的访问令牌，即将过期时先刷新
+func (m *TokenManager) AccessToken(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	token := m.token
+	m.mu.Unlock()
+
+	if token.AccessToken != "" && (token.ExpiresAt.IsZero() || time.Until(token.ExpiresAt) > tokenRefreshSkew) {
+		return token.AccessToken, nil
+	}
+	if err := m.Refresh(ctx, token.AccessToken); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token.AccessToken, nil
+}
+
+// 刷新访问令牌；stale为调用方持有的旧令牌，其他请求已完成刷新时直接返回
+func (m *TokenManager) Refresh(ctx context.Context, stale string) error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	m.mu.Lock()
+	current := m.token
+	m.mu.Unlock()
+	if current.AccessToken != stale && current.AccessToken != "" {
+		return nil
+	}
+	if current.RefreshToken == "" && current.AccessToken == "" && m.account != "" {
+		return fmt.Errorf("%s未登录，请先运行 panmatrix-raid login %s", m.name, m.account)
+	}
+	if current.RefreshToken == "" {
+		return fmt.Errorf("%s访问令牌已过期且未配置refresh_token", m.name)
+	}
+
+	token, err := m.refresh(ctx, current.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("刷新%s令牌失败: %v", m.name, err)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = current.RefreshToken
+	}
+
+	m.mu.Lock()
+	m.token = *token
+	m.mu.Unlock()
+
+	if m.key != "" {
+		if err := saveStoredToken(m.key, *token); err != nil {
+			fmt.Printf("警告: 保存%s令牌失败: %v\n", m.name, err)
+		}
+	}
+	return nil
+}
+
+// 是否可以刷新令牌
+func (m *TokenManager) CanRefresh() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token.RefreshToken != ""
+}
+
+// 使用访问令牌执行请求，返回401时刷新令牌后重试一次
+func (m *TokenManager) Do(ctx context.Context, send func(accessToken string) error) error {
+	accessToken, err := m.AccessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = send(accessToken)
+	if err == nil || !isUnauthorized(err) || !m.CanRefresh() {
+		return err
+	}
+
+	if err := m.Refresh(ctx, accessToken); err != nil {
+		return err
+	}
+	if accessToken, err = m.AccessToken(ctx); err != nil {
+		return err
+	}
+	return send(accessToken)
+}
+
+// 发送标准的OAuth2令牌请求并解析响应
+func requestOAuthToken(client *http.Client, req *http.Request) (*OAuthToken, error) {
+	var resp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := doJSON(client, req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" {
+		return nil, errors.New("响应中没有access_token")
+	}
+
+	token := &OAuthToken{AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken}
+	if resp.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// 令牌存储：JSON文件，键为 驱动名称:刷新令牌摘要
+var tokenStore struct {
+	path   string
+	tokens map[string]OAuthToken
+	mu     sync.Mutex
+}
+
+// 设置令牌存储文件并加载已保存的令牌，需在创建驱动器之前调用
+func SetTokenStore(path string) error {
+	tokenStore.mu.Lock()
+	defer tokenStore.mu.Unlock()
+
+	tokenStore.path = path
+	tokenStore.tokens = make(map[string]OAuthToken)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取令牌存储失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &tokenStore.tokens); err != nil {
+		return fmt.Errorf("解析令牌存储失败: %v", err)
+	}
+	return nil
+}
+
+// 将令牌存储文件中的令牌移入凭据存储并删除明文文件，返回移动的令牌数
+func MigrateStoredTokens() (int, error) {
+	tokenStore.mu.Lock()
+	defer tokenStore.mu.Unlock()
+
+	if len(tokenStore.tokens) == 0 {
+		return 0, nil
+	}
+	n := 0
+	for key, token := range tokenStore.tokens {
+		saved, err := saveCredentialToken(key, token)
+		if err != nil {
+			return n, fmt.Errorf("迁移令牌失败: %v", err)
+		}
+		if !saved {
+			return 0, nil
+		}
+		delete(tokenStore.tokens, key)
+		n++
+	}
+	if tokenStore.path != "" {
+		if err := os.Remove(tokenStore.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, fmt.Errorf("删除令牌存储文件失败: %v", err)
+		}
+	}
+	return n, nil
+}
+
+// 读取保存的令牌，凭据存储中的优先
+func loadStoredToken(key string) (OAuthToken, bool) {
+	if token, ok := credentialToken(key); ok {
+		return token, true
+	}
+
+	tokenStore.mu.Lock()
+	defer tokenStore.mu.Unlock()
+
+	token, ok := tokenStore.tokens[key]
+	return token, ok && token.RefreshToken != ""
+}
+
+// 写入令牌。设置了凭据存储时写入凭据存储，否则原子替换存储文件，文件包含凭据，仅所有者可读
+func saveStoredToken(key string, token OAuthToken) error {
+	if saved, err := saveCredentialToken(key, token); saved || err != nil {
+		return err
+	}
+
+	tokenStore.mu.Lock()
+	defer tokenStore.mu.Unlock()
+
+	if tokenStore.path == "" {
+		return nil
+	}
+	tokenStore.tokens[key] = token
+
+	data, err := json.MarshalIndent(tokenStore.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(tokenStore.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := tokenStore.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, tokenStore.path)
+}
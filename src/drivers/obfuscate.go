@@ -0,0 +1,291 @@
+package drivers
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 混淆数据块的随机IV前缀长度
+const obfuscationIVSize = aes.BlockSize
+
+// 默认混淆密钥。混淆只用于避开网盘的内容扫描，不提供保密性，需要保密请使用encryption
+const defaultObfuscationKey = "panmatrix-obfuscation"
+
+// 数据块混淆配置，用于会扫描或拦截特定内容的网盘
+//
+//	drives:
+//	  - type: baidu
+//	    obfuscation:
+//	      scheme: header     # ctr: 混淆整个数据块；header: 只混淆开头的header_bytes字节，开销更小
+//	      header_bytes: 4096
+//	      extension: .dat    # 远程文件名追加的扩展名
+type ObfuscationConfig struct {
+	Scheme      string `yaml:"scheme"`       // ctr 或 header，默认 ctr
+	HeaderBytes int    `yaml:"header_bytes"` // header方案混淆的字节数，默认4096
+	Extension   string `yaml:"extension"`
+	Key         string `yaml:"key"` // 可选，修改后已上传的数据块无法还原
+}
+
+// 混淆方式，以字符串形式记录在条带元数据中，如 "ctr;kid=1a2b3c4d"、"header=4096;kid=1a2b3c4d"
+type obfuscation struct {
+	scheme      string // 为空表示未混淆
+	headerBytes int
+	kid         string // 密钥指纹，用于发现密钥被修改
+}
+
+func (o obfuscation) String() string {
+	switch o.scheme {
+	case "":
+		return ""
+	case "header":
+		return fmt.Sprintf("header=%d;kid=%s", o.headerBytes, o.kid)
+	default:
+		return fmt.Sprintf("%s;kid=%s", o.scheme, o.kid)
+	}
+}
+
+func parseObfuscation(desc string) (obfuscation, error) {
+	var o obfuscation
+	if desc == "" {
+		return o, nil
+	}
+
+	for _, field := range strings.Split(desc, ";") {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "ctr":
+			o.scheme = key
+		case "header":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return o, fmt.Errorf("无效的混淆方式: %s", desc)
+			}
+			o.scheme, o.headerBytes = key, n
+		case "kid":
+			o.kid = value
+		default:
+			return o, fmt.Errorf("未知的混淆方式: %s", desc)
+		}
+	}
+	if o.scheme == "" {
+		return o, fmt.Errorf("无效的混淆方式: %s", desc)
+	}
+	return o, nil
+}
+
+type obfuscationContextKey struct{}
+
+// 指定下载时使用的混淆方式（条带元数据中记录的值），为空表示数据块未混淆。
+// 未指定时按驱动器当前的配置处理
+func WithObfuscation(ctx context.Context, desc string) context.Context {
+	return context.WithValue(ctx, obfuscationContextKey{}, desc)
+}
+
+// 报告上传时使用的混淆方式的驱动器
+type Obfuscator interface {
+	Obfuscation() string
+}
+
+// 驱动器上传数据块时使用的混淆方式，未混淆时返回空
+func ObfuscationOf(driver StorageDriver) string {
+	if o, ok := driver.(Obfuscator); ok {
+		return o.Obfuscation()
+	}
+	return ""
+}
+
+// 数据块混淆包装器：数据块前加随机IV，以AES-CTR密钥流混淆全部或开头部分数据，
+// 使网盘无法按文件头或内容特征识别；远程文件名可追加中性的扩展名
+//
+// 与加密包装器相同，分片会话无法逐片混淆，对调用方报告ErrSessionUnsupported。
+type ObfuscatedDriver struct {
+	baseWrapper
+
+	current   obfuscation
+	block     cipher.Block
+	extension string
+}
+
+func NewObfuscatedDriver(inner StorageDriver, cfg ObfuscationConfig) (*ObfuscatedDriver, error) {
+	if cfg.Scheme == "" {
+		cfg.Scheme = "ctr"
+	}
+	if cfg.Scheme != "ctr" && cfg.Scheme != "header" {
+		return nil, fmt.Errorf("不支持的混淆方式 %q（支持 ctr、header）", cfg.Scheme)
+	}
+	if cfg.HeaderBytes <= 0 {
+		cfg.HeaderBytes = 4096
+	}
+	if cfg.Key == "" {
+		cfg.Key = defaultObfuscationKey
+	}
+	if cfg.Extension != "" && !strings.HasPrefix(cfg.Extension, ".") {
+		cfg.Extension = "." + cfg.Extension
+	}
+	if strings.ContainsAny(cfg.Extension, `/\`) {
+		return nil, fmt.Errorf("无效的混淆扩展名: %q", cfg.Extension)
+	}
+
+	key := sha256.Sum256([]byte(cfg.Key))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(key[:])
+
+	d := &ObfuscatedDriver{
+		baseWrapper: baseWrapper{inner: inner},
+		current:     obfuscation{scheme: cfg.Scheme, kid: hex.EncodeToString(fingerprint[:4])},
+		block:       block,
+		extension:   cfg.Extension,
+	}
+	if cfg.Scheme == "header" {
+		d.current.headerBytes = cfg.HeaderBytes
+	}
+	return d, nil
+}
+
+func (d *ObfuscatedDriver) Obfuscation() string { return d.current.String() }
+
+// 远程文件名追加扩展名，驱动器返回的远程ID由调用方记录，下载和删除时原样使用
+func (d *ObfuscatedDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	out, err := d.obfuscate(data)
+	if err != nil {
+		return "", err
+	}
+	return d.inner.UploadChunk(ctx, out, storageID+d.extension)
+}
+
+func (d *ObfuscatedDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	o, err := d.schemeFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := d.inner.DownloadChunk(ctx, storageID)
+	if err != nil || o.scheme == "" {
+		return data, err
+	}
+	plain, err := d.reveal(o, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", storageID, err)
+	}
+	return plain, nil
+}
+
+// header方案中混淆区之后的数据未经变换，可以直接按范围下载
+func (d *ObfuscatedDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	o, err := d.schemeFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case o.scheme == "":
+		return d.baseWrapper.DownloadRange(ctx, storageID, offset, length)
+	case o.scheme == "header" && offset >= int64(o.headerBytes):
+		return d.baseWrapper.DownloadRange(ctx, storageID, offset+obfuscationIVSize, length)
+	}
+
+	plain, err := d.DownloadChunk(ctx, storageID)
+	if err != nil {
+		return nil, err
+	}
+	return sliceRange(plain, offset, length), nil
+}
+
+// 列举结果去掉扩展名还原storageID，远程文件名作为RemoteID
+func (d *ObfuscatedDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	chunks, err := d.baseWrapper.ListChunks(ctx, prefix)
+	if err != nil || d.extension == "" {
+		return chunks, err
+	}
+
+	for i, chunk := range chunks {
+		if !strings.HasSuffix(chunk.StorageID, d.extension) {
+			continue
+		}
+		if chunk.RemoteID == "" {
+			chunks[i].RemoteID = chunk.StorageID
+		}
+		chunks[i].StorageID = strings.TrimSuffix(chunk.StorageID, d.extension)
+	}
+	return chunks, nil
+}
+
+func (d *ObfuscatedDriver) MaxChunkSize() int64 {
+	limit := MaxChunkSizeOf(d.inner)
+	if limit <= 0 {
+		return 0
+	}
+	return max(limit-obfuscationIVSize, 1)
+}
+
+func (d *ObfuscatedDriver) Capabilities() Capabilities {
+	caps := CapabilitiesOf(d.inner)
+	caps.MaxChunkSize = d.MaxChunkSize()
+	caps.RangeDownload = caps.RangeDownload && d.current.scheme == "header"
+	caps.Multipart = false
+	caps.MaxParallelParts = 0
+	return caps
+}
+
+func (d *ObfuscatedDriver) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	return nil, ErrSessionUnsupported
+}
+
+// 下载使用的混淆方式：上下文中指定的优先（来自条带元数据），否则为当前配置
+func (d *ObfuscatedDriver) schemeFor(ctx context.Context) (obfuscation, error) {
+	desc, ok := ctx.Value(obfuscationContextKey{}).(string)
+	if !ok {
+		return d.current, nil
+	}
+
+	o, err := parseObfuscation(desc)
+	if err != nil {
+		return o, err
+	}
+	if o.scheme != "" && o.kid != d.current.kid {
+		return o, fmt.Errorf("混淆密钥已修改（数据块 %s，当前 %s），无法还原", o.kid, d.current.kid)
+	}
+	return o, nil
+}
+
+func (d *ObfuscatedDriver) obfuscate(data []byte) ([]byte, error) {
+	out := make([]byte, obfuscationIVSize+len(data))
+	iv := out[:obfuscationIVSize]
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("生成IV失败: %v", err)
+	}
+
+	copy(out[obfuscationIVSize:], data)
+	d.xor(d.current, iv, out[obfuscationIVSize:])
+	return out, nil
+}
+
+func (d *ObfuscatedDriver) reveal(o obfuscation, data []byte) ([]byte, error) {
+	if len(data) < obfuscationIVSize {
+		return nil, errors.New("混淆数据块不完整")
+	}
+
+	plain := append([]byte(nil), data[obfuscationIVSize:]...)
+	d.xor(o, data[:obfuscationIVSize], plain)
+	return plain, nil
+}
+
+// 以密钥流异或data（header方案只处理开头部分），混淆和还原是同一操作
+func (d *ObfuscatedDriver) xor(o obfuscation, iv, data []byte) {
+	n := len(data)
+	if o.scheme == "header" {
+		n = min(n, o.headerBytes)
+	}
+	cipher.NewCTR(d.block, iv).XORKeyStream(data[:n], data[:n])
+}
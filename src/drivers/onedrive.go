@@ -0,0 +1,432 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	oneDriveGraphURL = "https://graph.microsoft.com/v1.0"
+	oneDriveTokenURL = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	// 设备码登录，应用注册中需开启"允许公共客户端流"
+	oneDriveDeviceCodeURL = "https://login.microsoftonline.com/%s/oauth2/v2.0/devicecode"
+	oneDriveScope         = "Files.ReadWrite offline_access"
+
+	// 超过该大小使用上传会话（Graph简单上传上限为4MB）
+	oneDriveSimpleUploadLimit = 4 * 1024 * 1024
+	// 上传会话分片大小，必须是320KiB的整数倍
+	oneDriveFragmentSize = 32 * 320 * 1024
+)
+
+// OneDrive配置。未配置令牌时使用 panmatrix-raid login <name> 交互登录保存的令牌
+type OneDriveConfig struct {
+	Name         string `yaml:"name"` // 驱动器名，用作登录令牌的存储键
+	Enabled      bool   `yaml:"enabled"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	Tenant       string `yaml:"tenant"` // 默认 common
+	RefreshToken string `yaml:"refresh_token"`
+	AccessToken  string `yaml:"access_token"`
+	RootFolder   string `yaml:"root_folder"` // 存储目录，默认 PanMatrix
+	Proxy        string `yaml:"proxy"`       // 覆盖http段的默认代理
+}
+
+// OneDrive驱动（Microsoft Graph API）
+type OneDriveDriver struct {
+	cfg    OneDriveConfig
+	client *http.Client
+
+	tokens *TokenManager
+
+	itemIDs map[string]string // storageID -> DriveItem ID
+	mu      sync.Mutex
+}
+
+func NewOneDriveDriver(cfg OneDriveConfig) (*OneDriveDriver, error) {
+	if cfg.ClientID == "" {
+		return nil, errors.New("OneDrive配置缺少client_id")
+	}
+	if cfg.Name == "" {
+		cfg.Name = "onedrive"
+	}
+	if cfg.Tenant == "" {
+		cfg.Tenant = "common"
+	}
+	if cfg.RootFolder == "" {
+		cfg.RootFolder = "PanMatrix"
+	}
+
+	client, err := newHTTPClient(10*time.Minute, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &OneDriveDriver{
+		cfg:     cfg,
+		client:  client,
+		itemIDs: make(map[string]string),
+	}
+	d.tokens = NewTokenManager("OneDrive", OAuthToken{
+		AccessToken:  cfg.AccessToken,
+		RefreshToken: cfg.RefreshToken,
+	}, d.exchangeRefreshToken)
+	d.tokens.UseLogin(cfg.Name)
+	return d, nil
+}
+
+// 连接OneDrive：验证驱动器可访问，没有访问令牌时先刷新
+func (d *OneDriveDriver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	_, _, err := d.quota(ctx)
+	return err
+}
+
+func (d *OneDriveDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// 查询配额作为探测
+func (d *OneDriveDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error {
+		_, _, err := d.quota(ctx)
+		return err
+	})
+}
+
+func (d *OneDriveDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	return d.quota(ctx)
+}
+
+// 上传数据块，大于4MB时使用上传会话分片上传，DriveItem ID缓存后用于按ID下载
+func (d *OneDriveDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	var item struct {
+		ID string `json:"id"`
+	}
+
+	if len(data) <= oneDriveSimpleUploadLimit {
+		err := d.do(ctx, func() (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.itemURL(storageID)+":/content", bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/octet-stream")
+			return req, nil
+		}, &item)
+		if err != nil {
+			return "", fmt.Errorf("OneDrive上传失败: %v", err)
+		}
+	} else {
+		session, err := d.StartUploadSession(ctx, storageID, int64(len(data)))
+		if err != nil {
+			return "", err
+		}
+		if _, err := UploadWithSession(ctx, d, session, data, nil); err != nil {
+			d.AbortSession(context.Background(), session)
+			return "", err
+		}
+		return storageID, nil
+	}
+
+	d.mu.Lock()
+	d.itemIDs[storageID] = item.ID
+	d.mu.Unlock()
+
+	return storageID, nil
+}
+
+// 下载数据块，已知DriveItem ID时按ID下载，否则按路径下载
+func (d *OneDriveDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	target := d.itemURL(storageID) + ":/content"
+	d.mu.Lock()
+	if id, ok := d.itemIDs[storageID]; ok && id != "" {
+		target = oneDriveGraphURL + "/me/drive/items/" + url.PathEscape(id) + "/content"
+	}
+	d.mu.Unlock()
+
+	var data []byte
+	err := d.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	}, &data)
+	if err != nil {
+		return nil, fmt.Errorf("OneDrive下载失败: %v", err)
+	}
+
+	return data, nil
+}
+
+func (d *OneDriveDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	err := d.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodDelete, d.itemURL(storageID), nil)
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("OneDrive删除失败: %v", err)
+	}
+
+	d.mu.Lock()
+	delete(d.itemIDs, storageID)
+	d.mu.Unlock()
+	return nil
+}
+
+// 创建上传会话，会话地址作为UploadID，分片大小为320KiB的整数倍
+func (d *OneDriveDriver) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	var session struct {
+		UploadURL string `json:"uploadUrl"`
+	}
+
+	body := map[string]interface{}{
+		"item": map[string]string{"@microsoft.graph.conflictBehavior": "replace"},
+	}
+	err := d.do(ctx, func() (*http.Request, error) {
+		return newJSONRequest(ctx, http.MethodPost, d.itemURL(storageID)+":/createUploadSession", body)
+	}, &session)
+	if err != nil {
+		return nil, fmt.Errorf("创建OneDrive上传会话失败: %v", err)
+	}
+
+	return &UploadSession{
+		StorageID: storageID,
+		UploadID:  session.UploadURL,
+		Size:      size,
+		PartSize:  oneDriveFragmentSize,
+		State:     make(map[string]string),
+		StartedAt: time.Now(),
+	}, nil
+}
+
+// 上传一个分片到会话地址（会话地址自带授权，不能携带Authorization头），分片必须按顺序上传
+func (d *OneDriveDriver) UploadPart(ctx context.Context, session *UploadSession, partIndex int, data []byte) error {
+	start, end := session.PartRange(partIndex)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, session.UploadID, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, session.Size))
+
+	// 最后一个分片返回DriveItem，中间分片返回下一段期望范围
+	var item struct {
+		ID string `json:"id"`
+	}
+	if err := doJSON(d.client, req, &item); err != nil {
+		return fmt.Errorf("OneDrive分片上传失败[%d-%d]: %v", start, end-1, err)
+	}
+	if end == session.Size {
+		if session.State == nil {
+			session.State = make(map[string]string)
+		}
+		session.State["item_id"] = item.ID
+	}
+	return nil
+}
+
+// 最后一个分片上传后OneDrive自动提交，这里只缓存DriveItem ID
+func (d *OneDriveDriver) CompleteSession(ctx context.Context, session *UploadSession) (string, error) {
+	id := session.State["item_id"]
+	if id == "" {
+		return "", errors.New("OneDrive上传会话未完成")
+	}
+
+	d.mu.Lock()
+	d.itemIDs[session.StorageID] = id
+	d.mu.Unlock()
+	return session.StorageID, nil
+}
+
+// 列出存储目录下的文件，同时刷新DriveItem ID缓存
+func (d *OneDriveDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	var chunks []ChunkInfo
+	next := d.itemURL("") + ":/children?$select=id,name,size,lastModifiedDateTime,file&$top=1000"
+	for next != "" {
+		var page struct {
+			Value []struct {
+				ID           string    `json:"id"`
+				Name         string    `json:"name"`
+				Size         int64     `json:"size"`
+				LastModified time.Time `json:"lastModifiedDateTime"`
+				File         *struct{} `json:"file"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		target := next
+		err := d.do(ctx, func() (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		}, &page)
+		if errors.Is(err, ErrChunkNotFound) {
+			return nil, nil // 存储目录尚未创建
+		}
+		if err != nil {
+			return nil, fmt.Errorf("列举OneDrive文件失败: %v", err)
+		}
+
+		d.mu.Lock()
+		for _, item := range page.Value {
+			if item.File == nil {
+				continue
+			}
+			d.itemIDs[item.Name] = item.ID
+			chunks = appendChunk(chunks, prefix, ChunkInfo{StorageID: item.Name, Size: item.Size, ModTime: item.LastModified})
+		}
+		d.mu.Unlock()
+		next = page.NextLink
+	}
+	return chunks, nil
+}
+
+func (d *OneDriveDriver) AbortSession(ctx context.Context, session *UploadSession) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, session.UploadID, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(d.client, req, nil)
+}
+
+// 查询驱动器配额
+func (d *OneDriveDriver) quota(ctx context.Context) (int64, int64, error) {
+	var drive struct {
+		Quota struct {
+			Used  int64 `json:"used"`
+			Total int64 `json:"total"`
+		} `json:"quota"`
+	}
+
+	err := d.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, oneDriveGraphURL+"/me/drive", nil)
+	}, &drive)
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取OneDrive配额失败: %v", err)
+	}
+
+	return drive.Quota.Used, drive.Quota.Total, nil
+}
+
+// 发送带访问令牌的请求，令牌过期（401）时刷新后重试一次
+// out为*[]byte时返回原始响应体，否则按JSON解码
+func (d *OneDriveDriver) do(ctx context.Context, build func() (*http.Request, error), out interface{}) error {
+	return d.tokens.Do(ctx, func(accessToken string) error {
+		req, err := build()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		if raw, ok := out.(*[]byte); ok {
+			*raw, err = doBytes(d.client, req)
+			return err
+		}
+		return doJSON(d.client, req, out)
+	})
+}
+
+// 使用刷新令牌获取新的访问令牌，Microsoft每次都会轮换刷新令牌
+func (d *OneDriveDriver) exchangeRefreshToken(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	form := url.Values{
+		"client_id":     {d.cfg.ClientID},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"scope":         {oneDriveScope},
+	}
+	if d.cfg.ClientSecret != "" {
+		form.Set("client_secret", d.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf(oneDriveTokenURL, d.cfg.Tenant), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return requestOAuthToken(d.client, req)
+}
+
+// 设备码登录：终端显示验证地址和用户码，用户在任意设备上完成授权后保存令牌
+func (d *OneDriveDriver) Login(ctx context.Context, out io.Writer) error {
+	code, err := d.requestDeviceCode(ctx)
+	if err != nil {
+		return fmt.Errorf("获取OneDrive设备码失败: %v", err)
+	}
+	showDeviceCode(out, "OneDrive", code)
+
+	token, err := pollDeviceToken(ctx, code, func(ctx context.Context) (*OAuthToken, error) {
+		form := url.Values{
+			"client_id":   {d.cfg.ClientID},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {code.DeviceCode},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			fmt.Sprintf(oneDriveTokenURL, d.cfg.Tenant), strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return requestOAuthToken(d.client, req)
+	})
+	if err != nil {
+		return err
+	}
+	return d.tokens.SetToken(*token)
+}
+
+func (d *OneDriveDriver) requestDeviceCode(ctx context.Context) (*deviceCode, error) {
+	form := url.Values{
+		"client_id": {d.cfg.ClientID},
+		"scope":     {oneDriveScope},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf(oneDriveDeviceCodeURL, d.cfg.Tenant), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var resp struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURI string `json:"verification_uri"`
+		ExpiresIn       int64  `json:"expires_in"`
+		Interval        int64  `json:"interval"`
+	}
+	if err := doJSON(d.client, req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.DeviceCode == "" {
+		return nil, errors.New("响应中没有device_code")
+	}
+
+	return &deviceCode{
+		DeviceCode:      resp.DeviceCode,
+		UserCode:        resp.UserCode,
+		VerificationURI: resp.VerificationURI,
+		Interval:        time.Duration(resp.Interval) * time.Second,
+		ExpiresAt:       time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// 数据块在OneDrive中的路径地址
+func (d *OneDriveDriver) itemURL(storageID string) string {
+	p := path.Join(d.cfg.RootFolder, storageID)
+	return oneDriveGraphURL + "/me/drive/root:/" + escapePath(p)
+}
+
+// 按路径段转义
+func escapePath(p string) string {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
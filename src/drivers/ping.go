@@ -0,0 +1,43 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrUnavailable = errors.New("驱动器不可用")
+
+// 支持健康探测的驱动器：执行一次轻量的认证请求，返回往返延迟
+type Pinger interface {
+	Ping(ctx context.Context) (time.Duration, error)
+}
+
+// 探测驱动器，返回远程请求的往返延迟；未实现Pinger的驱动器以IsAvailable的耗时代替
+func Ping(ctx context.Context, driver StorageDriver) (time.Duration, error) {
+	if pinger, ok := driver.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	start := time.Now()
+	if !driver.IsAvailable() {
+		return time.Since(start), ErrUnavailable
+	}
+	return time.Since(start), nil
+}
+
+// 计时执行一次探测请求
+func timePing(probe func() error) (time.Duration, error) {
+	start := time.Now()
+	err := probe()
+	return time.Since(start), err
+}
+
+// 以probeTimeout执行Ping，供驱动器实现IsAvailable
+func pingAvailable(pinger Pinger) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	_, err := pinger.Ping(ctx)
+	return err == nil
+}
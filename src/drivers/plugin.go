@@ -0,0 +1,354 @@
+package drivers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"panmatrix/drivers/pluginpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// 插件握手行前缀与协议版本，见 pluginpb/storage_driver.proto
+	pluginHandshakePrefix = "PANMATRIX_PLUGIN"
+	pluginProtocolVersion = "1"
+	pluginConfigEnv       = "PANMATRIX_PLUGIN_CONFIG"
+
+	// gRPC默认消息上限为4MB，数据块可能远大于此
+	pluginMaxMessageSize = 1 << 30
+)
+
+// 外部进程驱动插件配置
+type PluginConfig struct {
+	Enabled      bool                   `yaml:"enabled"`
+	Command      string                 `yaml:"command"`       // 插件可执行文件
+	Args         []string               `yaml:"args"`          // 命令行参数
+	Address      string                 `yaml:"address"`       // 连接已运行的插件（host:port 或 unix:/path），设置后不启动进程
+	Config       map[string]interface{} `yaml:"config"`        // 传给插件的配置，以JSON放入环境变量
+	StartTimeout int                    `yaml:"start_timeout"` // 等待握手的秒数，默认10
+}
+
+// 通过gRPC调用外部插件进程的驱动
+type PluginDriver struct {
+	cfg PluginConfig
+
+	cmd    *exec.Cmd
+	conn   *grpc.ClientConn
+	client pluginpb.StorageDriverClient
+
+	name         string
+	maxChunkSize int64
+	mu           sync.Mutex
+}
+
+func NewPluginDriver(cfg PluginConfig) (*PluginDriver, error) {
+	if cfg.Command == "" && cfg.Address == "" {
+		return nil, errors.New("插件配置缺少command或address")
+	}
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = 10
+	}
+
+	return &PluginDriver{cfg: cfg, name: cfg.Command}, nil
+}
+
+// 启动插件进程（或连接已运行的插件）并完成握手
+func (d *PluginDriver) Connect() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.client == nil {
+		address := d.cfg.Address
+		if address == "" {
+			var err error
+			if address, err = d.start(); err != nil {
+				return err
+			}
+		}
+
+		conn, err := grpc.NewClient(address,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(
+				grpc.MaxCallRecvMsgSize(pluginMaxMessageSize),
+				grpc.MaxCallSendMsgSize(pluginMaxMessageSize),
+			),
+		)
+		if err != nil {
+			d.stop()
+			return fmt.Errorf("连接插件失败: %v", err)
+		}
+		d.conn = conn
+		d.client = pluginpb.NewStorageDriverClient(conn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, err := d.client.Connect(ctx, &pluginpb.ConnectRequest{}); err != nil {
+		return fmt.Errorf("插件连接存储失败: %v", pluginError(err))
+	}
+
+	// GetInfo为可选操作
+	info, err := d.client.GetInfo(ctx, &pluginpb.GetInfoRequest{})
+	if err == nil {
+		if info.GetName() != "" {
+			d.name = info.GetName()
+		}
+		d.maxChunkSize = info.GetMaxChunkSize()
+	} else if status.Code(err) != codes.Unimplemented {
+		return fmt.Errorf("获取插件信息失败: %v", pluginError(err))
+	}
+
+	return nil
+}
+
+func (d *PluginDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// 延迟包括一次gRPC往返和插件自身的可用性检查
+func (d *PluginDriver) Ping(ctx context.Context) (time.Duration, error) {
+	client := d.rpcClient()
+	if client == nil {
+		return 0, errors.New("插件未连接")
+	}
+
+	return timePing(func() error {
+		resp, err := client.IsAvailable(ctx, &pluginpb.IsAvailableRequest{})
+		if err != nil {
+			return pluginError(err)
+		}
+		if !resp.GetAvailable() {
+			return ErrUnavailable
+		}
+		return nil
+	})
+}
+
+func (d *PluginDriver) GetUsage() (int64, int64, error) {
+	client := d.rpcClient()
+	if client == nil {
+		return 0, 0, errors.New("插件未连接")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	resp, err := client.GetUsage(ctx, &pluginpb.GetUsageRequest{})
+	if err != nil {
+		return 0, 0, pluginError(err)
+	}
+	return resp.GetUsed(), resp.GetTotal(), nil
+}
+
+func (d *PluginDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	client := d.rpcClient()
+	if client == nil {
+		return "", errors.New("插件未连接")
+	}
+
+	resp, err := client.UploadChunk(ctx, &pluginpb.UploadChunkRequest{StorageId: storageID, Data: data})
+	if err != nil {
+		return "", fmt.Errorf("插件上传失败: %v", pluginError(err))
+	}
+	if resp.GetStorageId() != "" {
+		return resp.GetStorageId(), nil
+	}
+	return storageID, nil
+}
+
+func (d *PluginDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	client := d.rpcClient()
+	if client == nil {
+		return nil, errors.New("插件未连接")
+	}
+
+	resp, err := client.DownloadChunk(ctx, &pluginpb.DownloadChunkRequest{StorageId: storageID})
+	if err != nil {
+		return nil, fmt.Errorf("插件下载失败: %w", pluginError(err))
+	}
+	return resp.GetData(), nil
+}
+
+// 插件未实现DeleteChunk时返回ErrDeleteUnsupported
+func (d *PluginDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	client := d.rpcClient()
+	if client == nil {
+		return errors.New("插件未连接")
+	}
+
+	_, err := client.DeleteChunk(ctx, &pluginpb.DeleteChunkRequest{StorageId: storageID})
+	if status.Code(err) == codes.Unimplemented {
+		return ErrDeleteUnsupported
+	}
+	if err != nil {
+		return fmt.Errorf("插件删除失败: %w", pluginError(err))
+	}
+	return nil
+}
+
+// 插件未实现ListChunks时返回ErrListUnsupported
+func (d *PluginDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	client := d.rpcClient()
+	if client == nil {
+		return nil, errors.New("插件未连接")
+	}
+
+	resp, err := client.ListChunks(ctx, &pluginpb.ListChunksRequest{Prefix: prefix})
+	if status.Code(err) == codes.Unimplemented {
+		return nil, ErrListUnsupported
+	}
+	if err != nil {
+		return nil, fmt.Errorf("插件列举失败: %w", pluginError(err))
+	}
+
+	chunks := make([]ChunkInfo, 0, len(resp.GetChunks()))
+	for _, c := range resp.GetChunks() {
+		info := ChunkInfo{StorageID: c.GetStorageId(), RemoteID: c.GetRemoteId(), Size: c.GetSize()}
+		if c.GetModTime() > 0 {
+			info.ModTime = time.Unix(c.GetModTime(), 0)
+		}
+		chunks = append(chunks, info)
+	}
+	return chunks, nil
+}
+
+// 插件通过GetInfo声明的单文件大小上限
+func (d *PluginDriver) MaxChunkSize() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.maxChunkSize
+}
+
+// 断开连接并结束插件进程
+func (d *PluginDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.conn != nil {
+		d.conn.Close()
+		d.conn = nil
+		d.client = nil
+	}
+	d.stop()
+	return nil
+}
+
+func (d *PluginDriver) rpcClient() pluginpb.StorageDriverClient {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.client
+}
+
+// 启动插件进程，等待其在标准输出写出握手行，返回gRPC地址
+func (d *PluginDriver) start() (string, error) {
+	pluginCfg, err := json.Marshal(d.cfg.Config)
+	if err != nil {
+		return "", fmt.Errorf("序列化插件配置失败: %v", err)
+	}
+
+	cmd := exec.Command(d.cfg.Command, d.cfg.Args...)
+	cmd.Env = append(os.Environ(), pluginConfigEnv+"="+string(pluginCfg))
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("启动插件 %s 失败: %v", d.cfg.Command, err)
+	}
+	d.cmd = cmd
+
+	type handshake struct {
+		address string
+		err     error
+	}
+	result := make(chan handshake, 1)
+	go func() {
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				result <- handshake{err: fmt.Errorf("插件在握手前退出: %v", err)}
+				return
+			}
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, pluginHandshakePrefix+"|") {
+				log.Printf("插件 %s: %s", d.cfg.Command, line)
+				continue
+			}
+
+			address, err := parseHandshake(line)
+			result <- handshake{address: address, err: err}
+			// 握手后插件的标准输出不再使用，持续读取以免插件写满管道阻塞
+			io.Copy(io.Discard, reader)
+			return
+		}
+	}()
+
+	select {
+	case h := <-result:
+		if h.err != nil {
+			d.stop()
+			return "", h.err
+		}
+		return h.address, nil
+	case <-time.After(time.Duration(d.cfg.StartTimeout) * time.Second):
+		d.stop()
+		return "", fmt.Errorf("等待插件 %s 握手超时", d.cfg.Command)
+	}
+}
+
+func (d *PluginDriver) stop() {
+	if d.cmd != nil && d.cmd.Process != nil {
+		d.cmd.Process.Kill()
+		d.cmd.Wait()
+	}
+	d.cmd = nil
+}
+
+// 解析握手行 PANMATRIX_PLUGIN|<版本>|<network>|<address>
+func parseHandshake(line string) (string, error) {
+	fields := strings.Split(line, "|")
+	if len(fields) != 4 {
+		return "", fmt.Errorf("插件握手格式错误: %q", line)
+	}
+	if fields[1] != pluginProtocolVersion {
+		return "", fmt.Errorf("插件协议版本 %s 不受支持（需要 %s）", fields[1], pluginProtocolVersion)
+	}
+
+	switch fields[2] {
+	case "tcp":
+		return fields[3], nil
+	case "unix":
+		return "unix:" + fields[3], nil
+	default:
+		return "", fmt.Errorf("插件握手中的网络类型 %s 不受支持", fields[2])
+	}
+}
+
+// 将gRPC状态码映射为驱动错误
+func pluginError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if st.Code() == codes.NotFound {
+		return fmt.Errorf("%w: %s", ErrChunkNotFound, st.Message())
+	}
+	return fmt.Errorf("%s: %s", st.Code(), st.Message())
+}
@@ -0,0 +1,135 @@
+package drivers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"panmatrix/drivers/pluginpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 以插件方式运行一个StorageDriver，供用Go编写的插件在main中调用：
+//
+//	var cfg MyConfig
+//	drivers.LoadPluginConfig(&cfg)
+//	drivers.ServePlugin(NewMyDriver(cfg))
+//
+// 在本地随机端口监听并写出握手行，直到宿主进程结束插件
+func ServePlugin(driver StorageDriver) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(pluginMaxMessageSize),
+		grpc.MaxSendMsgSize(pluginMaxMessageSize),
+	)
+	pluginpb.RegisterStorageDriverServer(server, &pluginServer{driver: driver})
+
+	fmt.Fprintf(os.Stdout, "%s|%s|tcp|%s\n", pluginHandshakePrefix, pluginProtocolVersion, listener.Addr())
+	return server.Serve(listener)
+}
+
+// 读取宿主通过环境变量传入的插件配置
+func LoadPluginConfig(out interface{}) error {
+	raw := os.Getenv(pluginConfigEnv)
+	if raw == "" || raw == "null" {
+		return nil
+	}
+	return json.Unmarshal([]byte(raw), out)
+}
+
+// 将StorageDriver适配为gRPC服务
+type pluginServer struct {
+	pluginpb.UnimplementedStorageDriverServer
+	driver StorageDriver
+}
+
+func (s *pluginServer) Connect(ctx context.Context, _ *pluginpb.ConnectRequest) (*pluginpb.ConnectResponse, error) {
+	if err := s.driver.Connect(); err != nil {
+		return nil, pluginStatus(err)
+	}
+	return &pluginpb.ConnectResponse{}, nil
+}
+
+func (s *pluginServer) IsAvailable(ctx context.Context, _ *pluginpb.IsAvailableRequest) (*pluginpb.IsAvailableResponse, error) {
+	return &pluginpb.IsAvailableResponse{Available: s.driver.IsAvailable()}, nil
+}
+
+func (s *pluginServer) GetUsage(ctx context.Context, _ *pluginpb.GetUsageRequest) (*pluginpb.GetUsageResponse, error) {
+	used, total, err := s.driver.GetUsage()
+	if err != nil {
+		return nil, pluginStatus(err)
+	}
+	return &pluginpb.GetUsageResponse{Used: used, Total: total}, nil
+}
+
+func (s *pluginServer) UploadChunk(ctx context.Context, req *pluginpb.UploadChunkRequest) (*pluginpb.UploadChunkResponse, error) {
+	storageID, err := s.driver.UploadChunk(ctx, req.GetData(), req.GetStorageId())
+	if err != nil {
+		return nil, pluginStatus(err)
+	}
+	return &pluginpb.UploadChunkResponse{StorageId: storageID}, nil
+}
+
+func (s *pluginServer) DownloadChunk(ctx context.Context, req *pluginpb.DownloadChunkRequest) (*pluginpb.DownloadChunkResponse, error) {
+	data, err := s.driver.DownloadChunk(ctx, req.GetStorageId())
+	if err != nil {
+		return nil, pluginStatus(err)
+	}
+	return &pluginpb.DownloadChunkResponse{Data: data}, nil
+}
+
+func (s *pluginServer) DeleteChunk(ctx context.Context, req *pluginpb.DeleteChunkRequest) (*pluginpb.DeleteChunkResponse, error) {
+	if err := DeleteChunk(ctx, s.driver, req.GetStorageId()); err != nil {
+		return nil, pluginStatus(err)
+	}
+	return &pluginpb.DeleteChunkResponse{}, nil
+}
+
+func (s *pluginServer) ListChunks(ctx context.Context, req *pluginpb.ListChunksRequest) (*pluginpb.ListChunksResponse, error) {
+	chunks, err := ListChunks(ctx, s.driver, req.GetPrefix())
+	if err != nil {
+		return nil, pluginStatus(err)
+	}
+
+	resp := &pluginpb.ListChunksResponse{Chunks: make([]*pluginpb.ChunkInfo, 0, len(chunks))}
+	for _, c := range chunks {
+		info := &pluginpb.ChunkInfo{StorageId: c.StorageID, RemoteId: c.RemoteID, Size: c.Size}
+		if !c.ModTime.IsZero() {
+			info.ModTime = c.ModTime.Unix()
+		}
+		resp.Chunks = append(resp.Chunks, info)
+	}
+	return resp, nil
+}
+
+func (s *pluginServer) GetInfo(ctx context.Context, _ *pluginpb.GetInfoRequest) (*pluginpb.GetInfoResponse, error) {
+	return &pluginpb.GetInfoResponse{
+		Name:         fmt.Sprintf("%T", s.driver),
+		MaxChunkSize: MaxChunkSizeOf(s.driver),
+	}, nil
+}
+
+// 将驱动错误映射为gRPC状态码
+func pluginStatus(err error) error {
+	switch {
+	case errors.Is(err, ErrChunkNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrDeleteUnsupported), errors.Is(err, ErrListUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
@@ -0,0 +1,926 @@
+// PanMatrix外部驱动插件协议
+//
+// 插件是一个独立的可执行程序，PanMatrix启动它后通过gRPC调用存储操作，
+// 第三方可以用任何语言实现新的网盘驱动而无需重新编译PanMatrix。
+//
+// 握手：插件启动后在本地监听，并向标准输出写入一行
+//   PANMATRIX_PLUGIN|<协议版本>|<network>|<address>
+// 例如 PANMATRIX_PLUGIN|1|tcp|127.0.0.1:40123 或 PANMATRIX_PLUGIN|1|unix|/tmp/p.sock
+// 插件的配置（config.yaml中plugin.config的内容）通过环境变量 PANMATRIX_PLUGIN_CONFIG 以JSON传入。
+//
+// 重新生成Go代码：
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative storage_driver.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: storage_driver.proto
+
+package pluginpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ConnectRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectRequest) Reset() {
+	*x = ConnectRequest{}
+	mi := &file_storage_driver_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectRequest) ProtoMessage() {}
+
+func (x *ConnectRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectRequest.ProtoReflect.Descriptor instead.
+func (*ConnectRequest) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{0}
+}
+
+type ConnectResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectResponse) Reset() {
+	*x = ConnectResponse{}
+	mi := &file_storage_driver_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectResponse) ProtoMessage() {}
+
+func (x *ConnectResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectResponse.ProtoReflect.Descriptor instead.
+func (*ConnectResponse) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{1}
+}
+
+type IsAvailableRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsAvailableRequest) Reset() {
+	*x = IsAvailableRequest{}
+	mi := &file_storage_driver_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsAvailableRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsAvailableRequest) ProtoMessage() {}
+
+func (x *IsAvailableRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsAvailableRequest.ProtoReflect.Descriptor instead.
+func (*IsAvailableRequest) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{2}
+}
+
+type IsAvailableResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Available     bool                   `protobuf:"varint,1,opt,name=available,proto3" json:"available,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsAvailableResponse) Reset() {
+	*x = IsAvailableResponse{}
+	mi := &file_storage_driver_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsAvailableResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsAvailableResponse) ProtoMessage() {}
+
+func (x *IsAvailableResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsAvailableResponse.ProtoReflect.Descriptor instead.
+func (*IsAvailableResponse) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *IsAvailableResponse) GetAvailable() bool {
+	if x != nil {
+		return x.Available
+	}
+	return false
+}
+
+type GetUsageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUsageRequest) Reset() {
+	*x = GetUsageRequest{}
+	mi := &file_storage_driver_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUsageRequest) ProtoMessage() {}
+
+func (x *GetUsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUsageRequest.ProtoReflect.Descriptor instead.
+func (*GetUsageRequest) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{4}
+}
+
+type GetUsageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Used          int64                  `protobuf:"varint,1,opt,name=used,proto3" json:"used,omitempty"`
+	Total         int64                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetUsageResponse) Reset() {
+	*x = GetUsageResponse{}
+	mi := &file_storage_driver_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetUsageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetUsageResponse) ProtoMessage() {}
+
+func (x *GetUsageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetUsageResponse.ProtoReflect.Descriptor instead.
+func (*GetUsageResponse) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetUsageResponse) GetUsed() int64 {
+	if x != nil {
+		return x.Used
+	}
+	return 0
+}
+
+func (x *GetUsageResponse) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type UploadChunkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StorageId     string                 `protobuf:"bytes,1,opt,name=storage_id,json=storageId,proto3" json:"storage_id,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadChunkRequest) Reset() {
+	*x = UploadChunkRequest{}
+	mi := &file_storage_driver_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadChunkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadChunkRequest) ProtoMessage() {}
+
+func (x *UploadChunkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadChunkRequest.ProtoReflect.Descriptor instead.
+func (*UploadChunkRequest) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *UploadChunkRequest) GetStorageId() string {
+	if x != nil {
+		return x.StorageId
+	}
+	return ""
+}
+
+func (x *UploadChunkRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type UploadChunkResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 插件实际使用的存储ID（可与请求不同，例如内容寻址的存储）
+	StorageId     string `protobuf:"bytes,1,opt,name=storage_id,json=storageId,proto3" json:"storage_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadChunkResponse) Reset() {
+	*x = UploadChunkResponse{}
+	mi := &file_storage_driver_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadChunkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadChunkResponse) ProtoMessage() {}
+
+func (x *UploadChunkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadChunkResponse.ProtoReflect.Descriptor instead.
+func (*UploadChunkResponse) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UploadChunkResponse) GetStorageId() string {
+	if x != nil {
+		return x.StorageId
+	}
+	return ""
+}
+
+type DownloadChunkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StorageId     string                 `protobuf:"bytes,1,opt,name=storage_id,json=storageId,proto3" json:"storage_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadChunkRequest) Reset() {
+	*x = DownloadChunkRequest{}
+	mi := &file_storage_driver_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadChunkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadChunkRequest) ProtoMessage() {}
+
+func (x *DownloadChunkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadChunkRequest.ProtoReflect.Descriptor instead.
+func (*DownloadChunkRequest) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DownloadChunkRequest) GetStorageId() string {
+	if x != nil {
+		return x.StorageId
+	}
+	return ""
+}
+
+type DownloadChunkResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DownloadChunkResponse) Reset() {
+	*x = DownloadChunkResponse{}
+	mi := &file_storage_driver_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DownloadChunkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DownloadChunkResponse) ProtoMessage() {}
+
+func (x *DownloadChunkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DownloadChunkResponse.ProtoReflect.Descriptor instead.
+func (*DownloadChunkResponse) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *DownloadChunkResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type DeleteChunkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StorageId     string                 `protobuf:"bytes,1,opt,name=storage_id,json=storageId,proto3" json:"storage_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteChunkRequest) Reset() {
+	*x = DeleteChunkRequest{}
+	mi := &file_storage_driver_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteChunkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteChunkRequest) ProtoMessage() {}
+
+func (x *DeleteChunkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteChunkRequest.ProtoReflect.Descriptor instead.
+func (*DeleteChunkRequest) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteChunkRequest) GetStorageId() string {
+	if x != nil {
+		return x.StorageId
+	}
+	return ""
+}
+
+type DeleteChunkResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteChunkResponse) Reset() {
+	*x = DeleteChunkResponse{}
+	mi := &file_storage_driver_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteChunkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteChunkResponse) ProtoMessage() {}
+
+func (x *DeleteChunkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteChunkResponse.ProtoReflect.Descriptor instead.
+func (*DeleteChunkResponse) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{11}
+}
+
+type GetInfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetInfoRequest) Reset() {
+	*x = GetInfoRequest{}
+	mi := &file_storage_driver_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetInfoRequest) ProtoMessage() {}
+
+func (x *GetInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetInfoRequest.ProtoReflect.Descriptor instead.
+func (*GetInfoRequest) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{12}
+}
+
+type GetInfoResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 驱动名称，用于日志
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// 单个数据块的最大字节数，0表示不限
+	MaxChunkSize  int64 `protobuf:"varint,2,opt,name=max_chunk_size,json=maxChunkSize,proto3" json:"max_chunk_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetInfoResponse) Reset() {
+	*x = GetInfoResponse{}
+	mi := &file_storage_driver_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetInfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetInfoResponse) ProtoMessage() {}
+
+func (x *GetInfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetInfoResponse.ProtoReflect.Descriptor instead.
+func (*GetInfoResponse) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetInfoResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GetInfoResponse) GetMaxChunkSize() int64 {
+	if x != nil {
+		return x.MaxChunkSize
+	}
+	return 0
+}
+
+type ListChunksRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 只返回storage_id以此开头的数据块，为空时返回全部
+	Prefix        string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChunksRequest) Reset() {
+	*x = ListChunksRequest{}
+	mi := &file_storage_driver_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChunksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChunksRequest) ProtoMessage() {}
+
+func (x *ListChunksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChunksRequest.ProtoReflect.Descriptor instead.
+func (*ListChunksRequest) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ListChunksRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+type ChunkInfo struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	StorageId string                 `protobuf:"bytes,1,opt,name=storage_id,json=storageId,proto3" json:"storage_id,omitempty"`
+	// UploadChunk返回的存储ID，与storage_id相同时可为空
+	RemoteId string `protobuf:"bytes,2,opt,name=remote_id,json=remoteId,proto3" json:"remote_id,omitempty"`
+	Size     int64  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	// 修改时间（Unix秒），未知时为0
+	ModTime       int64 `protobuf:"varint,4,opt,name=mod_time,json=modTime,proto3" json:"mod_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkInfo) Reset() {
+	*x = ChunkInfo{}
+	mi := &file_storage_driver_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkInfo) ProtoMessage() {}
+
+func (x *ChunkInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkInfo.ProtoReflect.Descriptor instead.
+func (*ChunkInfo) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ChunkInfo) GetStorageId() string {
+	if x != nil {
+		return x.StorageId
+	}
+	return ""
+}
+
+func (x *ChunkInfo) GetRemoteId() string {
+	if x != nil {
+		return x.RemoteId
+	}
+	return ""
+}
+
+func (x *ChunkInfo) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *ChunkInfo) GetModTime() int64 {
+	if x != nil {
+		return x.ModTime
+	}
+	return 0
+}
+
+type ListChunksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Chunks        []*ChunkInfo           `protobuf:"bytes,1,rep,name=chunks,proto3" json:"chunks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListChunksResponse) Reset() {
+	*x = ListChunksResponse{}
+	mi := &file_storage_driver_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListChunksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListChunksResponse) ProtoMessage() {}
+
+func (x *ListChunksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_storage_driver_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListChunksResponse.ProtoReflect.Descriptor instead.
+func (*ListChunksResponse) Descriptor() ([]byte, []int) {
+	return file_storage_driver_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ListChunksResponse) GetChunks() []*ChunkInfo {
+	if x != nil {
+		return x.Chunks
+	}
+	return nil
+}
+
+var File_storage_driver_proto protoreflect.FileDescriptor
+
+const file_storage_driver_proto_rawDesc = "" +
+	"\n" +
+	"\x14storage_driver.proto\x12\x13panmatrix.plugin.v1\"\x10\n" +
+	"\x0eConnectRequest\"\x11\n" +
+	"\x0fConnectResponse\"\x14\n" +
+	"\x12IsAvailableRequest\"3\n" +
+	"\x13IsAvailableResponse\x12\x1c\n" +
+	"\tavailable\x18\x01 \x01(\bR\tavailable\"\x11\n" +
+	"\x0fGetUsageRequest\"<\n" +
+	"\x10GetUsageResponse\x12\x12\n" +
+	"\x04used\x18\x01 \x01(\x03R\x04used\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x03R\x05total\"G\n" +
+	"\x12UploadChunkRequest\x12\x1d\n" +
+	"\n" +
+	"storage_id\x18\x01 \x01(\tR\tstorageId\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"4\n" +
+	"\x13UploadChunkResponse\x12\x1d\n" +
+	"\n" +
+	"storage_id\x18\x01 \x01(\tR\tstorageId\"5\n" +
+	"\x14DownloadChunkRequest\x12\x1d\n" +
+	"\n" +
+	"storage_id\x18\x01 \x01(\tR\tstorageId\"+\n" +
+	"\x15DownloadChunkResponse\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"3\n" +
+	"\x12DeleteChunkRequest\x12\x1d\n" +
+	"\n" +
+	"storage_id\x18\x01 \x01(\tR\tstorageId\"\x15\n" +
+	"\x13DeleteChunkResponse\"\x10\n" +
+	"\x0eGetInfoRequest\"K\n" +
+	"\x0fGetInfoResponse\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12$\n" +
+	"\x0emax_chunk_size\x18\x02 \x01(\x03R\fmaxChunkSize\"+\n" +
+	"\x11ListChunksRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\"v\n" +
+	"\tChunkInfo\x12\x1d\n" +
+	"\n" +
+	"storage_id\x18\x01 \x01(\tR\tstorageId\x12\x1b\n" +
+	"\tremote_id\x18\x02 \x01(\tR\bremoteId\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x19\n" +
+	"\bmod_time\x18\x04 \x01(\x03R\amodTime\"L\n" +
+	"\x12ListChunksResponse\x126\n" +
+	"\x06chunks\x18\x01 \x03(\v2\x1e.panmatrix.plugin.v1.ChunkInfoR\x06chunks2\x81\x06\n" +
+	"\rStorageDriver\x12T\n" +
+	"\aConnect\x12#.panmatrix.plugin.v1.ConnectRequest\x1a$.panmatrix.plugin.v1.ConnectResponse\x12`\n" +
+	"\vIsAvailable\x12'.panmatrix.plugin.v1.IsAvailableRequest\x1a(.panmatrix.plugin.v1.IsAvailableResponse\x12W\n" +
+	"\bGetUsage\x12$.panmatrix.plugin.v1.GetUsageRequest\x1a%.panmatrix.plugin.v1.GetUsageResponse\x12`\n" +
+	"\vUploadChunk\x12'.panmatrix.plugin.v1.UploadChunkRequest\x1a(.panmatrix.plugin.v1.UploadChunkResponse\x12f\n" +
+	"\rDownloadChunk\x12).panmatrix.plugin.v1.DownloadChunkRequest\x1a*.panmatrix.plugin.v1.DownloadChunkResponse\x12`\n" +
+	"\vDeleteChunk\x12'.panmatrix.plugin.v1.DeleteChunkRequest\x1a(.panmatrix.plugin.v1.DeleteChunkResponse\x12T\n" +
+	"\aGetInfo\x12#.panmatrix.plugin.v1.GetInfoRequest\x1a$.panmatrix.plugin.v1.GetInfoResponse\x12]\n" +
+	"\n" +
+	"ListChunks\x12&.panmatrix.plugin.v1.ListChunksRequest\x1a'.panmatrix.plugin.v1.ListChunksResponseB\x1cZ\x1apanmatrix/drivers/pluginpbb\x06proto3"
+
+var (
+	file_storage_driver_proto_rawDescOnce sync.Once
+	file_storage_driver_proto_rawDescData []byte
+)
+
+func file_storage_driver_proto_rawDescGZIP() []byte {
+	file_storage_driver_proto_rawDescOnce.Do(func() {
+		file_storage_driver_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_storage_driver_proto_rawDesc), len(file_storage_driver_proto_rawDesc)))
+	})
+	return file_storage_driver_proto_rawDescData
+}
+
+var file_storage_driver_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_storage_driver_proto_goTypes = []any{
+	(*ConnectRequest)(nil),        // 0: panmatrix.plugin.v1.ConnectRequest
+	(*ConnectResponse)(nil),       // 1: panmatrix.plugin.v1.ConnectResponse
+	(*IsAvailableRequest)(nil),    // 2: panmatrix.plugin.v1.IsAvailableRequest
+	(*IsAvailableResponse)(nil),   // 3: panmatrix.plugin.v1.IsAvailableResponse
+	(*GetUsageRequest)(nil),       // 4: panmatrix.plugin.v1.GetUsageRequest
+	(*GetUsageResponse)(nil),      // 5: panmatrix.plugin.v1.GetUsageResponse
+	(*UploadChunkRequest)(nil),    // 6: panmatrix.plugin.v1.UploadChunkRequest
+	(*UploadChunkResponse)(nil),   // 7: panmatrix.plugin.v1.UploadChunkResponse
+	(*DownloadChunkRequest)(nil),  // 8: panmatrix.plugin.v1.DownloadChunkRequest
+	(*DownloadChunkResponse)(nil), // 9: panmatrix.plugin.v1.DownloadChunkResponse
+	(*DeleteChunkRequest)(nil),    // 10: panmatrix.plugin.v1.DeleteChunkRequest
+	(*DeleteChunkResponse)(nil),   // 11: panmatrix.plugin.v1.DeleteChunkResponse
+	(*GetInfoRequest)(nil),        // 12: panmatrix.plugin.v1.GetInfoRequest
+	(*GetInfoResponse)(nil),       // 13: panmatrix.plugin.v1.GetInfoResponse
+	(*ListChunksRequest)(nil),     // 14: panmatrix.plugin.v1.ListChunksRequest
+	(*ChunkInfo)(nil),             // 15: panmatrix.plugin.v1.ChunkInfo
+	(*ListChunksResponse)(nil),    // 16: panmatrix.plugin.v1.ListChunksResponse
+}
+var file_storage_driver_proto_depIdxs = []int32{
+	15, // 0: panmatrix.plugin.v1.ListChunksResponse.chunks:type_name -> panmatrix.plugin.v1.ChunkInfo
+	0,  // 1: panmatrix.plugin.v1.StorageDriver.Connect:input_type -> panmatrix.plugin.v1.ConnectRequest
+	2,  // 2: panmatrix.plugin.v1.StorageDriver.IsAvailable:input_type -> panmatrix.plugin.v1.IsAvailableRequest
+	4,  // 3: panmatrix.plugin.v1.StorageDriver.GetUsage:input_type -> panmatrix.plugin.v1.GetUsageRequest
+	6,  // 4: panmatrix.plugin.v1.StorageDriver.UploadChunk:input_type -> panmatrix.plugin.v1.UploadChunkRequest
+	8,  // 5: panmatrix.plugin.v1.StorageDriver.DownloadChunk:input_type -> panmatrix.plugin.v1.DownloadChunkRequest
+	10, // 6: panmatrix.plugin.v1.StorageDriver.DeleteChunk:input_type -> panmatrix.plugin.v1.DeleteChunkRequest
+	12, // 7: panmatrix.plugin.v1.StorageDriver.GetInfo:input_type -> panmatrix.plugin.v1.GetInfoRequest
+	14, // 8: panmatrix.plugin.v1.StorageDriver.ListChunks:input_type -> panmatrix.plugin.v1.ListChunksRequest
+	1,  // 9: panmatrix.plugin.v1.StorageDriver.Connect:output_type -> panmatrix.plugin.v1.ConnectResponse
+	3,  // 10: panmatrix.plugin.v1.StorageDriver.IsAvailable:output_type -> panmatrix.plugin.v1.IsAvailableResponse
+	5,  // 11: panmatrix.plugin.v1.StorageDriver.GetUsage:output_type -> panmatrix.plugin.v1.GetUsageResponse
+	7,  // 12: panmatrix.plugin.v1.StorageDriver.UploadChunk:output_type -> panmatrix.plugin.v1.UploadChunkResponse
+	9,  // 13: panmatrix.plugin.v1.StorageDriver.DownloadChunk:output_type -> panmatrix.plugin.v1.DownloadChunkResponse
+	11, // 14: panmatrix.plugin.v1.StorageDriver.DeleteChunk:output_type -> panmatrix.plugin.v1.DeleteChunkResponse
+	13, // 15: panmatrix.plugin.v1.StorageDriver.GetInfo:output_type -> panmatrix.plugin.v1.GetInfoResponse
+	16, // 16: panmatrix.plugin.v1.StorageDriver.ListChunks:output_type -> panmatrix.plugin.v1.ListChunksResponse
+	9,  // [9:17] is the sub-list for method output_type
+	1,  // [1:9] is the sub-list for method input_type
+	1,  // [1:1] is the sub-list for extension type_name
+	1,  // [1:1] is the sub-list for extension extendee
+	0,  // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_storage_driver_proto_init() }
+func file_storage_driver_proto_init() {
+	if File_storage_driver_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_storage_driver_proto_rawDesc), len(file_storage_driver_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_storage_driver_proto_goTypes,
+		DependencyIndexes: file_storage_driver_proto_depIdxs,
+		MessageInfos:      file_storage_driver_proto_msgTypes,
+	}.Build()
+	File_storage_driver_proto = out.File
+	file_storage_driver_proto_goTypes = nil
+	file_storage_driver_proto_depIdxs = nil
+}
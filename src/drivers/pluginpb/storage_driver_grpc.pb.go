@@ -0,0 +1,407 @@
+// PanMatrix外部驱动插件协议
+//
+// 插件是一个独立的可执行程序，PanMatrix启动它后通过gRPC调用存储操作，
+// 第三方可以用任何语言实现新的网盘驱动而无需重新编译PanMatrix。
+//
+// 握手：插件启动后在本地监听，并向标准输出写入一行
+//   PANMATRIX_PLUGIN|<协议版本>|<network>|<address>
+// 例如 PANMATRIX_PLUGIN|1|tcp|127.0.0.1:40123 或 PANMATRIX_PLUGIN|1|unix|/tmp/p.sock
+// 插件的配置（config.yaml中plugin.config的内容）通过环境变量 PANMATRIX_PLUGIN_CONFIG 以JSON传入。
+//
+// 重新生成Go代码：
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative storage_driver.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: storage_driver.proto
+
+package pluginpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	StorageDriver_Connect_FullMethodName       = "/panmatrix.plugin.v1.StorageDriver/Connect"
+	StorageDriver_IsAvailable_FullMethodName   = "/panmatrix.plugin.v1.StorageDriver/IsAvailable"
+	StorageDriver_GetUsage_FullMethodName      = "/panmatrix.plugin.v1.StorageDriver/GetUsage"
+	StorageDriver_UploadChunk_FullMethodName   = "/panmatrix.plugin.v1.StorageDriver/UploadChunk"
+	StorageDriver_DownloadChunk_FullMethodName = "/panmatrix.plugin.v1.StorageDriver/DownloadChunk"
+	StorageDriver_DeleteChunk_FullMethodName   = "/panmatrix.plugin.v1.StorageDriver/DeleteChunk"
+	StorageDriver_GetInfo_FullMethodName       = "/panmatrix.plugin.v1.StorageDriver/GetInfo"
+	StorageDriver_ListChunks_FullMethodName    = "/panmatrix.plugin.v1.StorageDriver/ListChunks"
+)
+
+// StorageDriverClient is the client API for StorageDriver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// 与drivers.StorageDriver一一对应
+type StorageDriverClient interface {
+	Connect(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectResponse, error)
+	IsAvailable(ctx context.Context, in *IsAvailableRequest, opts ...grpc.CallOption) (*IsAvailableResponse, error)
+	GetUsage(ctx context.Context, in *GetUsageRequest, opts ...grpc.CallOption) (*GetUsageResponse, error)
+	UploadChunk(ctx context.Context, in *UploadChunkRequest, opts ...grpc.CallOption) (*UploadChunkResponse, error)
+	DownloadChunk(ctx context.Context, in *DownloadChunkRequest, opts ...grpc.CallOption) (*DownloadChunkResponse, error)
+	// 可选操作，不支持时返回 UNIMPLEMENTED
+	DeleteChunk(ctx context.Context, in *DeleteChunkRequest, opts ...grpc.CallOption) (*DeleteChunkResponse, error)
+	GetInfo(ctx context.Context, in *GetInfoRequest, opts ...grpc.CallOption) (*GetInfoResponse, error)
+	ListChunks(ctx context.Context, in *ListChunksRequest, opts ...grpc.CallOption) (*ListChunksResponse, error)
+}
+
+type storageDriverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStorageDriverClient(cc grpc.ClientConnInterface) StorageDriverClient {
+	return &storageDriverClient{cc}
+}
+
+func (c *storageDriverClient) Connect(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConnectResponse)
+	err := c.cc.Invoke(ctx, StorageDriver_Connect_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageDriverClient) IsAvailable(ctx context.Context, in *IsAvailableRequest, opts ...grpc.CallOption) (*IsAvailableResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IsAvailableResponse)
+	err := c.cc.Invoke(ctx, StorageDriver_IsAvailable_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageDriverClient) GetUsage(ctx context.Context, in *GetUsageRequest, opts ...grpc.CallOption) (*GetUsageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUsageResponse)
+	err := c.cc.Invoke(ctx, StorageDriver_GetUsage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageDriverClient) UploadChunk(ctx context.Context, in *UploadChunkRequest, opts ...grpc.CallOption) (*UploadChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UploadChunkResponse)
+	err := c.cc.Invoke(ctx, StorageDriver_UploadChunk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageDriverClient) DownloadChunk(ctx context.Context, in *DownloadChunkRequest, opts ...grpc.CallOption) (*DownloadChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DownloadChunkResponse)
+	err := c.cc.Invoke(ctx, StorageDriver_DownloadChunk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageDriverClient) DeleteChunk(ctx context.Context, in *DeleteChunkRequest, opts ...grpc.CallOption) (*DeleteChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteChunkResponse)
+	err := c.cc.Invoke(ctx, StorageDriver_DeleteChunk_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageDriverClient) GetInfo(ctx context.Context, in *GetInfoRequest, opts ...grpc.CallOption) (*GetInfoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetInfoResponse)
+	err := c.cc.Invoke(ctx, StorageDriver_GetInfo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storageDriverClient) ListChunks(ctx context.Context, in *ListChunksRequest, opts ...grpc.CallOption) (*ListChunksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListChunksResponse)
+	err := c.cc.Invoke(ctx, StorageDriver_ListChunks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StorageDriverServer is the server API for StorageDriver service.
+// All implementations must embed UnimplementedStorageDriverServer
+// for forward compatibility.
+//
+// 与drivers.StorageDriver一一对应
+type StorageDriverServer interface {
+	Connect(context.Context, *ConnectRequest) (*ConnectResponse, error)
+	IsAvailable(context.Context, *IsAvailableRequest) (*IsAvailableResponse, error)
+	GetUsage(context.Context, *GetUsageRequest) (*GetUsageResponse, error)
+	UploadChunk(context.Context, *UploadChunkRequest) (*UploadChunkResponse, error)
+	DownloadChunk(context.Context, *DownloadChunkRequest) (*DownloadChunkResponse, error)
+	// 可选操作，不支持时返回 UNIMPLEMENTED
+	DeleteChunk(context.Context, *DeleteChunkRequest) (*DeleteChunkResponse, error)
+	GetInfo(context.Context, *GetInfoRequest) (*GetInfoResponse, error)
+	ListChunks(context.Context, *ListChunksRequest) (*ListChunksResponse, error)
+	mustEmbedUnimplementedStorageDriverServer()
+}
+
+// UnimplementedStorageDriverServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStorageDriverServer struct{}
+
+func (UnimplementedStorageDriverServer) Connect(context.Context, *ConnectRequest) (*ConnectResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Connect not implemented")
+}
+func (UnimplementedStorageDriverServer) IsAvailable(context.Context, *IsAvailableRequest) (*IsAvailableResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method IsAvailable not implemented")
+}
+func (UnimplementedStorageDriverServer) GetUsage(context.Context, *GetUsageRequest) (*GetUsageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUsage not implemented")
+}
+func (UnimplementedStorageDriverServer) UploadChunk(context.Context, *UploadChunkRequest) (*UploadChunkResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method UploadChunk not implemented")
+}
+func (UnimplementedStorageDriverServer) DownloadChunk(context.Context, *DownloadChunkRequest) (*DownloadChunkResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DownloadChunk not implemented")
+}
+func (UnimplementedStorageDriverServer) DeleteChunk(context.Context, *DeleteChunkRequest) (*DeleteChunkResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteChunk not implemented")
+}
+func (UnimplementedStorageDriverServer) GetInfo(context.Context, *GetInfoRequest) (*GetInfoResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetInfo not implemented")
+}
+func (UnimplementedStorageDriverServer) ListChunks(context.Context, *ListChunksRequest) (*ListChunksResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListChunks not implemented")
+}
+func (UnimplementedStorageDriverServer) mustEmbedUnimplementedStorageDriverServer() {}
+func (UnimplementedStorageDriverServer) testEmbeddedByValue()                       {}
+
+// UnsafeStorageDriverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StorageDriverServer will
+// result in compilation errors.
+type UnsafeStorageDriverServer interface {
+	mustEmbedUnimplementedStorageDriverServer()
+}
+
+func RegisterStorageDriverServer(s grpc.ServiceRegistrar, srv StorageDriverServer) {
+	// If the following call panics, it indicates UnimplementedStorageDriverServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&StorageDriver_ServiceDesc, srv)
+}
+
+func _StorageDriver_Connect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConnectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageDriverServer).Connect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageDriver_Connect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageDriverServer).Connect(ctx, req.(*ConnectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageDriver_IsAvailable_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IsAvailableRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageDriverServer).IsAvailable(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageDriver_IsAvailable_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageDriverServer).IsAvailable(ctx, req.(*IsAvailableRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageDriver_GetUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageDriverServer).GetUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageDriver_GetUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageDriverServer).GetUsage(ctx, req.(*GetUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageDriver_UploadChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageDriverServer).UploadChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageDriver_UploadChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageDriverServer).UploadChunk(ctx, req.(*UploadChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageDriver_DownloadChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DownloadChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageDriverServer).DownloadChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageDriver_DownloadChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageDriverServer).DownloadChunk(ctx, req.(*DownloadChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageDriver_DeleteChunk_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteChunkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageDriverServer).DeleteChunk(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageDriver_DeleteChunk_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageDriverServer).DeleteChunk(ctx, req.(*DeleteChunkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageDriver_GetInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageDriverServer).GetInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageDriver_GetInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageDriverServer).GetInfo(ctx, req.(*GetInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StorageDriver_ListChunks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChunksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StorageDriverServer).ListChunks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StorageDriver_ListChunks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StorageDriverServer).ListChunks(ctx, req.(*ListChunksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// StorageDriver_ServiceDesc is the grpc.ServiceDesc for StorageDriver service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StorageDriver_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "panmatrix.plugin.v1.StorageDriver",
+	HandlerType: (*StorageDriverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Connect",
+			Handler:    _StorageDriver_Connect_Handler,
+		},
+		{
+			MethodName: "IsAvailable",
+			Handler:    _StorageDriver_IsAvailable_Handler,
+		},
+		{
+			MethodName: "GetUsage",
+			Handler:    _StorageDriver_GetUsage_Handler,
+		},
+		{
+			MethodName: "UploadChunk",
+			Handler:    _StorageDriver_UploadChunk_Handler,
+		},
+		{
+			MethodName: "DownloadChunk",
+			Handler:    _StorageDriver_DownloadChunk_Handler,
+		},
+		{
+			MethodName: "DeleteChunk",
+			Handler:    _StorageDriver_DeleteChunk_Handler,
+		},
+		{
+			MethodName: "GetInfo",
+			Handler:    _StorageDriver_GetInfo_Handler,
+		},
+		{
+			MethodName: "ListChunks",
+			Handler:    _StorageDriver_ListChunks_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "storage_driver.proto",
+}
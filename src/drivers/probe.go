@@ -0,0 +1,100 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 探测对象的前缀，与条带块（file_）和元数据备份区分
+const probePrefix = "panmatrix_probe_"
+
+// 删除探测对象的超时，探测本身被取消时仍尝试删除
+const probeCleanupTimeout = 30 * time.Second
+
+// 测量延迟的小探测对象的大小，它的传输时间近似为请求的往返延迟
+const probeSmallSize = 4 * 1024
+
+// 一次传输探测的结果
+type ProbeResult struct {
+	Latency           time.Duration // 下载小探测对象的耗时
+	UploadBandwidth   float64       // 字节/秒，扣除了请求延迟
+	DownloadBandwidth float64
+}
+
+// 先后传输一个4KB和一个size字节的随机对象（上传、下载并核对内容，结束后删除），
+// 以小对象的下载耗时作为延迟，以两者耗时之差计算带宽。与Ping不同，探测经过与条带块相同的上传下载路径，
+// 能反映驱动器的限速和真实带宽
+func Probe(ctx context.Context, driver StorageDriver, size int64) (ProbeResult, error) {
+	var result ProbeResult
+	size = max(size, 2*probeSmallSize)
+
+	smallUp, smallDown, err := probeObject(ctx, driver, probeSmallSize)
+	if err != nil {
+		return result, err
+	}
+	up, down, err := probeObject(ctx, driver, size)
+	if err != nil {
+		return result, err
+	}
+
+	result.Latency = smallDown
+	extra := size - probeSmallSize
+	result.UploadBandwidth = bandwidthOf(extra, up-smallUp, size, up)
+	result.DownloadBandwidth = bandwidthOf(extra, down-smallDown, size, down)
+	return result, nil
+}
+
+// 按两个对象的耗时之差计算带宽；抖动使差值不为正时按大对象的总耗时计算
+func bandwidthOf(extra int64, delta time.Duration, size int64, total time.Duration) float64 {
+	if delta > 0 {
+		return float64(extra) / delta.Seconds()
+	}
+	if total > 0 {
+		return float64(size) / total.Seconds()
+	}
+	return 0
+}
+
+// 上传、下载并删除一个size字节的随机对象，返回上传和下载的耗时
+func probeObject(ctx context.Context, driver StorageDriver, size int64) (time.Duration, time.Duration, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return 0, 0, fmt.Errorf("生成探测数据失败: %v", err)
+	}
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	storageID := probePrefix + hex.EncodeToString(suffix)
+
+	start := time.Now()
+	remoteID, err := driver.UploadChunk(ctx, data, storageID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("上传探测对象失败: %v", err)
+	}
+	upload := time.Since(start)
+	if remoteID == "" {
+		remoteID = storageID
+	}
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), probeCleanupTimeout)
+		defer cancel()
+		if err := DeleteChunk(cleanupCtx, driver, remoteID); err != nil && !errors.Is(err, ErrDeleteUnsupported) && !errors.Is(err, ErrChunkNotFound) {
+			fmt.Printf("警告: 删除探测对象 %s 失败: %v\n", remoteID, err)
+		}
+	}()
+
+	start = time.Now()
+	got, err := driver.DownloadChunk(ctx, remoteID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("下载探测对象失败: %v", err)
+	}
+	download := time.Since(start)
+	if !bytes.Equal(got, data) {
+		return 0, 0, fmt.Errorf("下载的探测对象与上传的内容不符（%d/%d字节）", len(got), len(data))
+	}
+	return upload, download, nil
+}
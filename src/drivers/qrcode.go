@@ -0,0 +1,462 @@
+package drivers
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 终端二维码，交互登录时显示验证地址，用手机扫码打开。
+// 只实现登录地址需要的部分：字节模式、纠错等级M、版本1~10（最多213字节）
+
+type qrVersion struct {
+	ecPerBlock int   // 每个块的纠错码字数
+	blocks     []int // 每个块的数据码字数，短块在前
+	align      []int // 校正图形的中心坐标
+}
+
+var qrVersions = [...]qrVersion{
+	1:  {10, []int{16}, nil},
+	2:  {16, []int{28}, []int{6, 18}},
+	3:  {26, []int{44}, []int{6, 22}},
+	4:  {18, []int{32, 32}, []int{6, 26}},
+	5:  {24, []int{43, 43}, []int{6, 30}},
+	6:  {16, []int{27, 27, 27, 27}, []int{6, 34}},
+	7:  {18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	8:  {22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	9:  {22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	10: {26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+// 纠错等级M的格式信息编码
+const qrFormatLevelM = 0
+
+type qrCode struct {
+	size     int
+	modules  [][]bool // [行][列]，true为深色
+	function [][]bool // 功能图形区域，不放数据也不加掩码
+}
+
+func encodeQR(text string) (*qrCode, error) {
+	data := []byte(text)
+
+	version, countBits := 0, 0
+	for v := 1; v < len(qrVersions); v++ {
+		countBits = 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= qrDataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("二维码内容过长: %d字节", len(data))
+	}
+
+	// 字节模式数据，补齐终止符和填充字节
+	capacity := qrDataCodewords(version) * 8
+	var bits qrBits
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits)
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	q := newQRCode(version)
+	q.drawCodewords(qrInterleave(version, bits.bytes()))
+
+	// 选择惩罚分最低的掩码
+	best, bestScore := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if score := q.penalty(); bestScore < 0 || score < bestScore {
+			best, bestScore = mask, score
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q, nil
+}
+
+func qrDataCodewords(version int) int {
+	n := 0
+	for _, size := range qrVersions[version].blocks {
+		n += size
+	}
+	return n
+}
+
+type qrBits []bool
+
+func (b *qrBits) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>i)&1 == 1)
+	}
+}
+
+func (b qrBits) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// 按块计算纠错码，数据码字和纠错码字分别按列交错排列
+func qrInterleave(version int, data []byte) []byte {
+	ver := qrVersions[version]
+	divisor := rsDivisor(ver.ecPerBlock)
+
+	var blocks, ecBlocks [][]byte
+	offset, longest := 0, 0
+	for _, n := range ver.blocks {
+		block := data[offset : offset+n]
+		offset += n
+		longest = max(longest, n)
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+	}
+
+	var out []byte
+	for i := 0; i < longest; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < ver.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// GF(256)乘法，本原多项式 x^8+x^4+x^3+x^2+1
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x1d
+		z ^= (y >> i & 1) * x
+	}
+	return z
+}
+
+// Reed-Solomon生成多项式，最高次项系数省略
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	var root byte = 1
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// 创建矩阵并绘制定位、定时、校正图形和版本信息，格式信息区域先占位
+func newQRCode(version int) *qrCode {
+	size := 17 + 4*version
+	q := &qrCode{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(size-4, 3)
+	q.drawFinder(3, size-4)
+
+	align := qrVersions[version].align
+	last := len(align) - 1
+	for i, x := range align {
+		for j, y := range align {
+			// 与定位图形重叠的位置不放校正图形
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			q.drawAlignment(x, y)
+		}
+	}
+
+	q.drawFormat(0)
+	q.drawVersion(version)
+	return q
+}
+
+// 设置功能图形模块，x为列，y为行
+func (q *qrCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+// 定位图形及其分隔符
+func (q *qrCode) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= q.size || y >= q.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (q *qrCode) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// 格式信息（纠错等级和掩码，BCH(15,5)编码）的两份拷贝及固定的深色模块
+func (q *qrCode) drawFormat(mask int) {
+	data := qrFormatLevelM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// 版本7及以上的版本信息（BCH(18,6)编码）
+func (q *qrCode) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1f25
+	}
+	bits := version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := q.size-11+i%3, i/3
+		q.set(a, b, dark)
+		q.set(b, a, dark)
+	}
+}
+
+// 按之字形从右下角开始两列一组放置数据，跳过功能图形和定时列
+func (q *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(data)*8 {
+					q.modules[y][x] = data[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// 对数据区域异或掩码，再次调用即可撤销
+func (q *qrCode) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// 掩码惩罚分：连续同色、2x2同色块、类定位图形和深色比例
+func (q *qrCode) penalty() int {
+	score := 0
+	line := make([]bool, q.size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < q.size; i++ {
+			for j := range line {
+				if vertical {
+					line[j] = q.modules[j][i]
+				} else {
+					line[j] = q.modules[i][j]
+				}
+			}
+			score += qrLinePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+
+	total := q.size * q.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return score + k*10
+}
+
+var (
+	qrFinderLike1 = []bool{true, false, true, true, true, false, true, false, false, false, false}
+	qrFinderLike2 = []bool{false, false, false, false, true, false, true, true, true, false, true}
+)
+
+func qrLinePenalty(line []bool) int {
+	score := 0
+	for start := 0; start < len(line); {
+		end := start
+		for end < len(line) && line[end] == line[start] {
+			end++
+		}
+		if n := end - start; n >= 5 {
+			score += 3 + n - 5
+		}
+		start = end
+	}
+
+	for i := 0; i+len(qrFinderLike1) <= len(line); i++ {
+		window := line[i : i+len(qrFinderLike1)]
+		if boolsEqual(window, qrFinderLike1) || boolsEqual(window, qrFinderLike2) {
+			score += 40
+		}
+	}
+	return score
+}
+
+func boolsEqual(a, b []bool) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// 以半高块字符输出（每个字符表示上下两个模块），四周保留2个模块的空白。
+// 浅色模块用前景色绘制，适合深色背景的终端
+func (q *qrCode) render(w io.Writer) error {
+	const quiet = 2
+	light := func(y, x int) bool {
+		y, x = y-quiet, x-quiet
+		if y < 0 || x < 0 || y >= q.size || x >= q.size {
+			return true
+		}
+		return !q.modules[y][x]
+	}
+
+	total := q.size + 2*quiet
+	var b strings.Builder
+	for y := 0; y < total; y += 2 {
+		for x := 0; x < total; x++ {
+			top, bottom := light(y, x), y+1 < total && light(y+1, x)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,539 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	quarkAPIURL  = "https://drive.quark.cn/1/clouddrive"
+	quarkReferer = "https://pan.quark.cn"
+	quarkUA      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) quark-cloud-drive/2.5.20 Chrome/100.0.4896.160 Electron/18.3.5.4-b478491100 Safari/537.36 Channel/pckk_other_ch"
+	quarkOSSUA   = "aliyun-sdk-js/6.6.1 Chrome 98.0.4758.80 on Windows 10 64-bit"
+
+	// 目录已存在
+	quarkCodeFolderExists = 23008
+)
+
+// 夸克网盘配置
+type QuarkConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Cookie     string `yaml:"cookie"`      // 浏览器登录 pan.quark.cn 后的完整Cookie
+	RootFolder string `yaml:"root_folder"` // 存储目录名，默认 PanMatrix
+	Proxy      string `yaml:"proxy"`       // 覆盖http段的默认代理
+}
+
+// 夸克网盘驱动（网页端接口，Cookie认证）
+type QuarkDriver struct {
+	cfg    QuarkConfig
+	client *http.Client
+
+	cookie   string
+	folderID string
+	mu       sync.Mutex
+}
+
+// 夸克接口统一响应
+type quarkResponse struct {
+	Status   int             `json:"status"`
+	Code     int             `json:"code"`
+	Message  string          `json:"message"`
+	Data     json.RawMessage `json:"data"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// 上传预处理结果
+type quarkUploadPre struct {
+	TaskID    string `json:"task_id"`
+	Finish    bool   `json:"finish"`
+	UploadID  string `json:"upload_id"`
+	ObjKey    string `json:"obj_key"`
+	UploadURL string `json:"upload_url"`
+	Fid       string `json:"fid"`
+	Bucket    string `json:"bucket"`
+	Callback  struct {
+		CallbackURL  string `json:"callbackUrl"`
+		CallbackBody string `json:"callbackBody"`
+	} `json:"callback"`
+	AuthInfo string `json:"auth_info"`
+}
+
+func NewQuarkDriver(cfg QuarkConfig) (*QuarkDriver, error) {
+	if cfg.Cookie == "" {
+		return nil, errors.New("夸克网盘配置缺少cookie")
+	}
+	if cfg.RootFolder == "" {
+		cfg.RootFolder = "PanMatrix"
+	}
+
+	client, err := newHTTPClient(10*time.Minute, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuarkDriver{
+		cfg:    cfg,
+		client: client,
+		cookie: cfg.Cookie,
+	}, nil
+}
+
+// 连接夸克网盘：验证Cookie并确保存储目录存在
+func (d *QuarkDriver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if _, _, err := d.capacity(ctx); err != nil {
+		return fmt.Errorf("夸克网盘认证失败: %v", err)
+	}
+
+	folderID, err := d.ensureFolder(ctx, d.cfg.RootFolder)
+	if err != nil {
+		return fmt.Errorf("创建夸克网盘存储目录失败: %v", err)
+	}
+
+	d.mu.Lock()
+	d.folderID = folderID
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *QuarkDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// 查询容量作为探测
+func (d *QuarkDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error {
+		_, _, err := d.capacity(ctx)
+		return err
+	})
+}
+
+func (d *QuarkDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	return d.capacity(ctx)
+}
+
+// 上传流程：upload/pre -> update/hash（秒传检查）-> OSS分片上传 -> 提交分片 -> upload/finish
+// 返回夸克文件fid作为存储ID
+func (d *QuarkDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	d.mu.Lock()
+	folderID := d.folderID
+	d.mu.Unlock()
+	if folderID == "" {
+		return "", errors.New("夸克网盘未连接")
+	}
+
+	now := time.Now().UnixMilli()
+	var pre quarkUploadPre
+	var preMeta struct {
+		PartSize int `json:"part_size"`
+	}
+	resp, err := d.api(ctx, http.MethodPost, "/file/upload/pre", map[string]interface{}{
+		"ccp_hash_update": true,
+		"dir_name":        "",
+		"file_name":       storageID,
+		"format_type":     "application/octet-stream",
+		"l_created_at":    now,
+		"l_updated_at":    now,
+		"pdir_fid":        folderID,
+		"size":            len(data),
+	}, &pre)
+	if err != nil {
+		return "", fmt.Errorf("夸克网盘上传预处理失败: %v", err)
+	}
+	json.Unmarshal(resp.Metadata, &preMeta)
+
+	md5Sum := md5.Sum(data)
+	sha1Sum := sha1.Sum(data)
+	var hashResult struct {
+		Finish bool `json:"finish"`
+	}
+	_, err = d.api(ctx, http.MethodPost, "/file/update/hash", map[string]string{
+		"md5":     hex.EncodeToString(md5Sum[:]),
+		"sha1":    hex.EncodeToString(sha1Sum[:]),
+		"task_id": pre.TaskID,
+	}, &hashResult)
+	if err != nil {
+		return "", fmt.Errorf("夸克网盘提交哈希失败: %v", err)
+	}
+	// 服务端已有相同内容（秒传）
+	if hashResult.Finish {
+		return pre.Fid, nil
+	}
+
+	partSize := preMeta.PartSize
+	if partSize <= 0 {
+		partSize = 4 * 1024 * 1024
+	}
+
+	var etags []string
+	for partNumber, start := 1, 0; start < len(data); partNumber, start = partNumber+1, start+partSize {
+		part := data[start:min(start+partSize, len(data))]
+		etag, err := d.uploadPart(ctx, &pre, partNumber, part)
+		if err != nil {
+			return "", fmt.Errorf("夸克网盘分片%d上传失败: %v", partNumber, err)
+		}
+		etags = append(etags, etag)
+	}
+
+	if err := d.completeParts(ctx, &pre, etags); err != nil {
+		return "", fmt.Errorf("夸克网盘提交分片失败: %v", err)
+	}
+
+	_, err = d.api(ctx, http.MethodPost, "/file/upload/finish", map[string]string{
+		"obj_key": pre.ObjKey,
+		"task_id": pre.TaskID,
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("夸克网盘完成上传失败: %v", err)
+	}
+
+	return pre.Fid, nil
+}
+
+// 解析下载地址后下载（下载地址同样需要Cookie）
+func (d *QuarkDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	var links []struct {
+		DownloadURL string `json:"download_url"`
+	}
+	_, err := d.api(ctx, http.MethodPost, "/file/download", map[string]interface{}{
+		"fids": []string{storageID},
+	}, &links)
+	if err != nil {
+		return nil, fmt.Errorf("获取夸克网盘下载地址失败: %v", err)
+	}
+	if len(links) == 0 || links[0].DownloadURL == "" {
+		return nil, fmt.Errorf("%w: 夸克网盘文件 %s", ErrChunkNotFound, storageID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, links[0].DownloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.setHeaders(req)
+
+	data, err := doBytes(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("夸克网盘下载失败: %v", err)
+	}
+	return data, nil
+}
+
+func (d *QuarkDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	_, err := d.api(ctx, http.MethodPost, "/file/delete", map[string]interface{}{
+		"action_type":  2,
+		"exclude_fids": []string{},
+		"filelist":     []string{storageID},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("夸克网盘删除失败: %v", err)
+	}
+	return nil
+}
+
+// 分页列出存储目录下的文件，远程ID为夸克文件fid
+func (d *QuarkDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	d.mu.Lock()
+	folderID := d.folderID
+	d.mu.Unlock()
+	if folderID == "" {
+		return nil, errors.New("夸克网盘未连接")
+	}
+
+	const pageSize = 100
+	var chunks []ChunkInfo
+	for page := 1; ; page++ {
+		var list struct {
+			List []struct {
+				Fid       string `json:"fid"`
+				FileName  string `json:"file_name"`
+				Size      int64  `json:"size"`
+				Dir       bool   `json:"dir"`
+				UpdatedAt int64  `json:"updated_at"`
+			} `json:"list"`
+		}
+		query := url.Values{
+			"pdir_fid": {folderID},
+			"_page":    {strconv.Itoa(page)},
+			"_size":    {strconv.Itoa(pageSize)},
+		}
+		if _, err := d.api(ctx, http.MethodGet, "/file/sort?"+query.Encode(), nil, &list); err != nil {
+			return nil, fmt.Errorf("列举夸克网盘文件失败: %v", err)
+		}
+
+		for _, f := range list.List {
+			if f.Dir {
+				continue
+			}
+			chunks = appendChunk(chunks, prefix, ChunkInfo{
+				StorageID: f.FileName,
+				RemoteID:  f.Fid,
+				Size:      f.Size,
+				ModTime:   time.UnixMilli(f.UpdatedAt),
+			})
+		}
+		if len(list.List) < pageSize {
+			return chunks, nil
+		}
+	}
+}
+
+// 上传一个OSS分片，返回ETag
+func (d *QuarkDriver) uploadPart(ctx context.Context, pre *quarkUploadPre, partNumber int, part []byte) (string, error) {
+	date := time.Now().UTC().Format(http.TimeFormat)
+	authMeta := fmt.Sprintf("PUT\n\n%s\n%s\nx-oss-date:%s\nx-oss-user-agent:%s\n/%s/%s?partNumber=%d&uploadId=%s",
+		"application/octet-stream", date, date, quarkOSSUA, pre.Bucket, pre.ObjKey, partNumber, pre.UploadID)
+	authKey, err := d.uploadAuth(ctx, pre, authMeta)
+	if err != nil {
+		return "", err
+	}
+
+	target := fmt.Sprintf("%s?partNumber=%d&uploadId=%s", d.objectURL(pre), partNumber, pre.UploadID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(part))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", authKey)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Referer", quarkReferer+"/")
+	req.Header.Set("x-oss-date", date)
+	req.Header.Set("x-oss-user-agent", quarkOSSUA)
+
+	resp, err := doRequest(d.client, req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// 提交OSS分片列表（CompleteMultipartUpload），同时触发夸克回调
+func (d *QuarkDriver) completeParts(ctx context.Context, pre *quarkUploadPre, etags []string) error {
+	var body strings.Builder
+	body.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<CompleteMultipartUpload>\n")
+	for i, etag := range etags {
+		fmt.Fprintf(&body, "<Part>\n<PartNumber>%d</PartNumber>\n<ETag>%s</ETag>\n</Part>\n", i+1, etag)
+	}
+	body.WriteString("</CompleteMultipartUpload>")
+	payload := []byte(body.String())
+
+	sum := md5.Sum(payload)
+	contentMD5 := base64.StdEncoding.EncodeToString(sum[:])
+	callback, _ := json.Marshal(pre.Callback)
+	callbackB64 := base64.StdEncoding.EncodeToString(callback)
+
+	date := time.Now().UTC().Format(http.TimeFormat)
+	authMeta := fmt.Sprintf("POST\n%s\napplication/xml\n%s\nx-oss-callback:%s\nx-oss-date:%s\nx-oss-user-agent:%s\n/%s/%s?uploadId=%s",
+		contentMD5, date, callbackB64, date, quarkOSSUA, pre.Bucket, pre.ObjKey, pre.UploadID)
+	authKey, err := d.uploadAuth(ctx, pre, authMeta)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.objectURL(pre)+"?uploadId="+pre.UploadID, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authKey)
+	req.Header.Set("Content-MD5", contentMD5)
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Referer", quarkReferer+"/")
+	req.Header.Set("x-oss-callback", callbackB64)
+	req.Header.Set("x-oss-date", date)
+	req.Header.Set("x-oss-user-agent", quarkOSSUA)
+
+	_, err = doBytes(d.client, req)
+	return err
+}
+
+// 由夸克服务端为OSS请求签名
+func (d *QuarkDriver) uploadAuth(ctx context.Context, pre *quarkUploadPre, authMeta string) (string, error) {
+	var auth struct {
+		AuthKey string `json:"auth_key"`
+	}
+	_, err := d.api(ctx, http.MethodPost, "/file/upload/auth", map[string]string{
+		"auth_info": pre.AuthInfo,
+		"auth_meta": authMeta,
+		"task_id":   pre.TaskID,
+	}, &auth)
+	if err != nil {
+		return "", fmt.Errorf("获取夸克网盘上传签名失败: %v", err)
+	}
+	return auth.AuthKey, nil
+}
+
+// OSS对象地址：https://<bucket>.<upload_url主机>/<obj_key>
+func (d *QuarkDriver) objectURL(pre *quarkUploadPre) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(pre.UploadURL, "https://"), "http://")
+	return fmt.Sprintf("https://%s.%s/%s", pre.Bucket, host, pre.ObjKey)
+}
+
+func (d *QuarkDriver) capacity(ctx context.Context) (int64, int64, error) {
+	var member struct {
+		TotalCapacity int64 `json:"total_capacity"`
+		UseCapacity   int64 `json:"use_capacity"`
+	}
+	if _, err := d.api(ctx, http.MethodGet, "/member?fetch_subscribe=false&_ch=home&fetch_identity=false", nil, &member); err != nil {
+		return 0, 0, err
+	}
+	return member.UseCapacity, member.TotalCapacity, nil
+}
+
+// 在根目录下查找或创建目录，返回目录fid
+func (d *QuarkDriver) ensureFolder(ctx context.Context, name string) (string, error) {
+	var created struct {
+		Fid string `json:"fid"`
+	}
+	_, err := d.api(ctx, http.MethodPost, "/file", map[string]interface{}{
+		"pdir_fid":      "0",
+		"file_name":     name,
+		"dir_path":      "",
+		"dir_init_lock": false,
+	}, &created)
+	if err == nil {
+		return created.Fid, nil
+	}
+
+	var apiErr *quarkAPIError
+	if !errors.As(err, &apiErr) || apiErr.Code != quarkCodeFolderExists {
+		return "", err
+	}
+
+	// 目录已存在，在根目录列表中查找
+	for page := 1; ; page++ {
+		var list struct {
+			List []struct {
+				Fid      string `json:"fid"`
+				FileName string `json:"file_name"`
+				Dir      bool   `json:"dir"`
+			} `json:"list"`
+		}
+		query := url.Values{
+			"pdir_fid":     {"0"},
+			"_page":        {strconv.Itoa(page)},
+			"_size":        {"100"},
+			"_fetch_total": {"1"},
+		}
+		if _, err := d.api(ctx, http.MethodGet, "/file/sort?"+query.Encode(), nil, &list); err != nil {
+			return "", err
+		}
+		for _, f := range list.List {
+			if f.Dir && f.FileName == name {
+				return f.Fid, nil
+			}
+		}
+		if len(list.List) < 100 {
+			return "", fmt.Errorf("未找到已存在的目录 %s", name)
+		}
+	}
+}
+
+// 调用夸克接口并检查业务状态码，data解码到out
+func (d *QuarkDriver) api(ctx context.Context, method, endpoint string, body interface{}, out interface{}) (*quarkResponse, error) {
+	target := quarkAPIURL + endpoint
+	if strings.Contains(endpoint, "?") {
+		target += "&pr=ucpro&fr=pc"
+	} else {
+		target += "?pr=ucpro&fr=pc"
+	}
+
+	req, err := newJSONRequest(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	d.setHeaders(req)
+
+	httpResp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	d.updateCookie(httpResp)
+
+	var resp quarkResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("解析响应失败: HTTP %d: %v", httpResp.StatusCode, err)
+	}
+	if resp.Code != 0 || httpResp.StatusCode >= 300 {
+		return nil, &quarkAPIError{Status: httpResp.StatusCode, Code: resp.Code, Message: resp.Message}
+	}
+
+	if out != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return nil, fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+	return &resp, nil
+}
+
+func (d *QuarkDriver) setHeaders(req *http.Request) {
+	d.mu.Lock()
+	cookie := d.cookie
+	d.mu.Unlock()
+
+	req.Header.Set("Cookie", cookie)
+	req.Header.Set("Referer", quarkReferer)
+	req.Header.Set("User-Agent", quarkUA)
+	req.Header.Set("Accept", "application/json, text/plain, */*")
+}
+
+// 服务端会通过Set-Cookie续期__puus，需同步更新以免Cookie过期
+func (d *QuarkDriver) updateCookie(resp *http.Response) {
+	updated := resp.Cookies()
+	if len(updated) == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	parts := strings.Split(d.cookie, ";")
+	for _, c := range updated {
+		replaced := false
+		for i, part := range parts {
+			name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == c.Name {
+				parts[i] = " " + c.Name + "=" + c.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			parts = append(parts, " "+c.Name+"="+c.Value)
+		}
+	}
+	d.cookie = strings.TrimSpace(strings.Join(parts, ";"))
+}
+
+// 夸克业务错误
+type quarkAPIError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (e *quarkAPIError) Error() string {
+	return fmt.Sprintf("HTTP %d: code %d: %s", e.Status, e.Code, e.Message)
+}
+
+func (e *quarkAPIError) Unwrap() error {
+	if e.Status == http.StatusNotFound {
+		return ErrChunkNotFound
+	}
+	return nil
+}
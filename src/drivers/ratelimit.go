@@ -0,0 +1,296 @@
+package drivers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 驱动器请求限制，百度、阿里云等网盘会对调用过于频繁的账号限流甚至封禁
+//
+//	drives:
+//	  - type: baidu
+//	    limits:
+//	      max_concurrent: 2
+//	      requests_per_sec: 5
+//	      upload_mbps: 10
+type RateLimitConfig struct {
+	MaxConcurrent  int     `yaml:"max_concurrent"`   // 同时进行的请求数，0表示不限
+	RequestsPerSec float64 `yaml:"requests_per_sec"` // 每秒请求数，0表示不限
+	UploadMBps     float64 `yaml:"upload_mbps"`      // 上传带宽（MB/s），0表示不限
+	DownloadMBps   float64 `yaml:"download_mbps"`    // 下载带宽（MB/s），0表示不限
+}
+
+// 是否配置了任何限制
+func (c RateLimitConfig) Enabled() bool {
+	return c.MaxConcurrent > 0 || c.RequestsPerSec > 0 || c.UploadMBps > 0 || c.DownloadMBps > 0
+}
+
+// 请求限制的并发名额和令牌桶。同一账号的多个驱动器可以共用一个Limiter，使合计的请求不超过账号的限制
+type Limiter struct {
+	sem      chan struct{}
+	requests *tokenBucket
+	upload   *tokenBucket
+	download *tokenBucket
+}
+
+func NewLimiter(cfg RateLimitConfig) *Limiter {
+	l := &Limiter{}
+	if cfg.MaxConcurrent > 0 {
+		l.sem = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	if cfg.RequestsPerSec > 0 {
+		// 允许1秒的突发
+		l.requests = newTokenBucket(cfg.RequestsPerSec, max(cfg.RequestsPerSec, 1))
+	}
+	if cfg.UploadMBps > 0 {
+		rate := cfg.UploadMBps * 1024 * 1024
+		l.upload = newTokenBucket(rate, rate)
+	}
+	if cfg.DownloadMBps > 0 {
+		rate := cfg.DownloadMBps * 1024 * 1024
+		l.download = newTokenBucket(rate, rate)
+	}
+	return l
+}
+
+// 取得并发名额和请求令牌，返回释放函数
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+			release = func() { <-l.sem }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if err := l.requests.wait(ctx, 1); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// 取得一个请求令牌，不占用并发名额
+func (l *Limiter) WaitRequest(ctx context.Context) error {
+	return l.requests.wait(ctx, 1)
+}
+
+// 按数据量等待上传带宽
+func (l *Limiter) WaitUpload(ctx context.Context, bytes int64) error {
+	return l.upload.wait(ctx, float64(bytes))
+}
+
+// 按数据量扣除下载带宽，超出的部分推迟后续的下载
+func (l *Limiter) WaitDownload(ctx context.Context, bytes int64) error {
+	return l.download.wait(ctx, float64(bytes))
+}
+
+// 限速驱动包装器：所有访问远程接口的操作先取得并发名额和请求令牌，
+// 上传在发送前按数据量等待带宽令牌；下载大小事先未知，在收到数据后扣除，
+// 超出的部分推迟后续请求
+type RateLimitedDriver struct {
+	baseWrapper
+
+	limits *Limiter
+}
+
+func NewRateLimitedDriver(inner StorageDriver, cfg RateLimitConfig) *RateLimitedDriver {
+	return &RateLimitedDriver{baseWrapper: baseWrapper{inner: inner}, limits: NewLimiter(cfg)}
+}
+
+// 健康检查不占用并发名额，避免在上传繁忙时被误判为离线
+func (d *RateLimitedDriver) IsAvailable() bool {
+	if d.limits.WaitRequest(context.Background()) != nil {
+		return false
+	}
+	return d.inner.IsAvailable()
+}
+
+// 延迟不包括等待令牌的时间
+func (d *RateLimitedDriver) Ping(ctx context.Context) (time.Duration, error) {
+	if err := d.limits.WaitRequest(ctx); err != nil {
+		return 0, err
+	}
+	return Ping(ctx, d.inner)
+}
+
+func (d *RateLimitedDriver) GetUsage() (int64, int64, error) {
+	if err := d.limits.WaitRequest(context.Background()); err != nil {
+		return 0, 0, err
+	}
+	return d.inner.GetUsage()
+}
+
+func (d *RateLimitedDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	if err := d.limits.WaitUpload(ctx, int64(len(data))); err != nil {
+		return "", err
+	}
+	return d.inner.UploadChunk(ctx, data, storageID)
+}
+
+func (d *RateLimitedDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	data, err := d.inner.DownloadChunk(ctx, storageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.limits.WaitDownload(ctx, int64(len(data))); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (d *RateLimitedDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	data, err := d.baseWrapper.DownloadRange(ctx, storageID, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.limits.WaitDownload(ctx, int64(len(data))); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (d *RateLimitedDriver) RenameChunk(ctx context.Context, storageID, newStorageID string) error {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return d.baseWrapper.RenameChunk(ctx, storageID, newStorageID)
+}
+
+func (d *RateLimitedDriver) RestoreChunk(ctx context.Context, storageID string) error {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return d.baseWrapper.RestoreChunk(ctx, storageID)
+}
+
+func (d *RateLimitedDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return d.baseWrapper.DeleteChunk(ctx, storageID)
+}
+
+func (d *RateLimitedDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return d.baseWrapper.ListChunks(ctx, prefix)
+}
+
+func (d *RateLimitedDriver) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return d.baseWrapper.StartUploadSession(ctx, storageID, size)
+}
+
+func (d *RateLimitedDriver) UploadPart(ctx context.Context, session *UploadSession, partIndex int, data []byte) error {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := d.limits.WaitUpload(ctx, int64(len(data))); err != nil {
+		return err
+	}
+	return d.baseWrapper.UploadPart(ctx, session, partIndex, data)
+}
+
+func (d *RateLimitedDriver) CompleteSession(ctx context.Context, session *UploadSession) (string, error) {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return d.baseWrapper.CompleteSession(ctx, session)
+}
+
+func (d *RateLimitedDriver) AbortSession(ctx context.Context, session *UploadSession) error {
+	release, err := d.limits.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return d.baseWrapper.AbortSession(ctx, session)
+}
+
+// 令牌桶。允许令牌数为负：一次取用超过容量的令牌（如一个大数据块）时，
+// 调用方等待到欠下的令牌补齐，之后的请求继续排队
+type tokenBucket struct {
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 桶容量
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// 取用n个令牌，令牌不足时等待；nil桶表示不限
+func (b *tokenBucket) wait(ctx context.Context, n float64) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= n
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// 未使用的令牌归还
+		b.mu.Lock()
+		b.tokens += n
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
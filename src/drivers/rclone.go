@@ -0,0 +1,210 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// rclone配置：通过rclone远程控制接口（rclone rcd）接入任意已配置的rclone remote
+//
+// rclone需以 rcd --rc-serve 方式启动，下载经由 --rc-serve 提供的对象地址。
+type RcloneConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	URL      string `yaml:"url"`      // rcd地址，默认 http://127.0.0.1:5572
+	User     string `yaml:"user"`     // --rc-user
+	Password string `yaml:"password"` // --rc-pass
+	Remote   string `yaml:"remote"`   // rclone remote名称，如 gdrive 或 gdrive:
+	Path     string `yaml:"path"`     // remote中的存储目录，默认 PanMatrix
+	Proxy    string `yaml:"proxy"`    // 覆盖http段的默认代理
+}
+
+// rclone远程驱动
+type RcloneDriver struct {
+	cfg    RcloneConfig
+	client *http.Client
+	fs     string // remote文件系统，形如 gdrive:
+}
+
+func NewRcloneDriver(cfg RcloneConfig) (*RcloneDriver, error) {
+	if cfg.Remote == "" {
+		return nil, errors.New("rclone配置缺少remote")
+	}
+	if cfg.URL == "" {
+		cfg.URL = "http://127.0.0.1:5572"
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	if cfg.Path == "" {
+		cfg.Path = "PanMatrix"
+	}
+
+	fs := cfg.Remote
+	if !strings.HasSuffix(fs, ":") {
+		fs += ":"
+	}
+
+	client, err := newHTTPClient(10*time.Minute, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RcloneDriver{
+		cfg:    cfg,
+		client: client,
+		fs:     fs,
+	}, nil
+}
+
+// 连接rclone：确保存储目录存在
+func (d *RcloneDriver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := d.rc(ctx, "operations/mkdir", map[string]string{"fs": d.fs, "remote": d.cfg.Path}, nil)
+	if err != nil {
+		return fmt.Errorf("创建rclone存储目录失败: %v", err)
+	}
+	return nil
+}
+
+func (d *RcloneDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// rc/noop只检查rcd在线；remote本身的可用性在实际读写时才能发现
+func (d *RcloneDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error { return d.rc(ctx, "rc/noop", map[string]string{}, nil) })
+}
+
+// 使用operations/about获取remote容量（部分后端不支持，返回0表示未知）
+func (d *RcloneDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var about struct {
+		Total int64 `json:"total"`
+		Used  int64 `json:"used"`
+	}
+	if err := d.rc(ctx, "operations/about", map[string]string{"fs": d.fs}, &about); err != nil {
+		return 0, 0, fmt.Errorf("获取rclone容量失败: %v", err)
+	}
+	return about.Used, about.Total, nil
+}
+
+// 通过operations/uploadfile以multipart表单上传
+func (d *RcloneDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	remote := path.Join(d.cfg.Path, storageID)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", path.Base(remote))
+	if err != nil {
+		return "", err
+	}
+	part.Write(data)
+	form.Close()
+
+	query := url.Values{"fs": {d.fs}, "remote": {path.Dir(remote)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.cfg.URL+"/operations/uploadfile?"+query.Encode(), &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	d.setAuth(req)
+
+	if err := rcloneError(doJSON(d.client, req, nil)); err != nil {
+		return "", fmt.Errorf("rclone上传失败: %v", err)
+	}
+	return storageID, nil
+}
+
+// 通过--rc-serve提供的对象地址下载：/[remote:]path/file
+func (d *RcloneDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	target := fmt.Sprintf("%s/[%s]/%s", d.cfg.URL, d.fs, escapePath(path.Join(d.cfg.Path, storageID)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.setAuth(req)
+
+	data, err := doBytes(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("rclone下载失败: %v", rcloneError(err))
+	}
+	return data, nil
+}
+
+func (d *RcloneDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	err := d.rc(ctx, "operations/deletefile", map[string]string{
+		"fs":     d.fs,
+		"remote": path.Join(d.cfg.Path, storageID),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("rclone删除失败: %v", err)
+	}
+	return nil
+}
+
+// 使用operations/list递归列出存储目录
+func (d *RcloneDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	var list struct {
+		List []struct {
+			Path    string    `json:"Path"`
+			Size    int64     `json:"Size"`
+			ModTime time.Time `json:"ModTime"`
+			IsDir   bool      `json:"IsDir"`
+		} `json:"list"`
+	}
+	err := d.rc(ctx, "operations/list", map[string]interface{}{
+		"fs":     d.fs,
+		"remote": d.cfg.Path,
+		"opt":    map[string]bool{"recurse": true, "filesOnly": true},
+	}, &list)
+	if errors.Is(err, ErrChunkNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("列举rclone文件失败: %v", err)
+	}
+
+	var chunks []ChunkInfo
+	for _, f := range list.List {
+		if !f.IsDir {
+			chunks = appendChunk(chunks, prefix, ChunkInfo{StorageID: f.Path, Size: f.Size, ModTime: f.ModTime})
+		}
+	}
+	return chunks, nil
+}
+
+// 调用rc接口（POST JSON）
+func (d *RcloneDriver) rc(ctx context.Context, command string, params interface{}, out interface{}) error {
+	req, err := newJSONRequest(ctx, http.MethodPost, d.cfg.URL+"/"+command, params)
+	if err != nil {
+		return err
+	}
+	d.setAuth(req)
+	return rcloneError(doJSON(d.client, req, out))
+}
+
+func (d *RcloneDriver) setAuth(req *http.Request) {
+	if d.cfg.User != "" {
+		req.SetBasicAuth(d.cfg.User, d.cfg.Password)
+	}
+}
+
+// rclone对不存在的对象返回500并在错误信息中说明，映射为ErrChunkNotFound
+func rcloneError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) &&
+		(strings.Contains(apiErr.Body, "object not found") || strings.Contains(apiErr.Body, "directory not found")) {
+		return fmt.Errorf("%w: %v", ErrChunkNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,326 @@
+package drivers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 驱动工厂：decode将该驱动器的配置解码到驱动自己的配置结构体
+type Factory func(decode func(out interface{}) error) (StorageDriver, error)
+
+var (
+	registry   = make(map[string]Factory)
+	registryMu sync.RWMutex
+)
+
+// 注册驱动类型，通常在驱动文件的init中调用；重复注册同名类型会panic
+func Register(driverType string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[driverType]; exists {
+		panic(fmt.Sprintf("驱动类型 %s 重复注册", driverType))
+	}
+	registry[driverType] = factory
+}
+
+// 以配置结构体和构造函数注册驱动类型
+func RegisterConfig[C any, D StorageDriver](driverType string, ctor func(C) (D, error)) {
+	Register(driverType, func(decode func(out interface{}) error) (StorageDriver, error) {
+		var cfg C
+		if err := decode(&cfg); err != nil {
+			return nil, fmt.Errorf("解析%s配置失败: %v", driverType, err)
+		}
+		return ctor(cfg)
+	})
+}
+
+// 已注册的驱动类型（排序后）
+func RegisteredTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// 配置文件 drives 段中的一个驱动器实例：
+//
+//	drives:
+//	  - type: s3
+//	    name: minio-home     # 阵列中的驱动器名，默认为type
+//	    endpoint: ...
+//	  - type: onedrive
+//	    enabled: false
+//	    limits:              # 可选的请求限制，见RateLimitConfig
+//	      requests_per_sec: 5
+//	    retry:               # 可选的重试策略，见RetryConfig
+//	      max_attempts: 5
+//	    encryption:          # 可选的透明加密，见EncryptionConfig
+//	      key_file: ...
+//	    obfuscation:         # 可选的数据块混淆，见ObfuscationConfig
+//	      scheme: header
+//	    chaos:               # 测试用的故障注入，见ChaosConfig
+//	      error_rate: 0.1
+//	  - type: baidu          # 同一服务商的多个账号，展开为多个独立的驱动器
+//	    limits: ...          # 账号外的字段为各账号共用的默认值
+//	    accounts:
+//	      - name: baidu-1    # 默认为 <name或type>-<序号>
+//	        access_token: ...
+//	      - name: baidu-2
+//	        access_token: ...
+//
+// 除type/name/enabled和以上包装器配置外的字段交给对应驱动的配置结构体解析
+type DriveSpec struct {
+	Type    string
+	Name    string
+	Enabled bool
+	Limits  RateLimitConfig
+	Retry   RetryConfig
+
+	Encryption  *EncryptionConfig
+	Obfuscation *ObfuscationConfig
+	Chaos       *ChaosConfig
+
+	node yaml.Node
+}
+
+func (s *DriveSpec) UnmarshalYAML(node *yaml.Node) error {
+	var header struct {
+		Type    string          `yaml:"type"`
+		Name    string          `yaml:"name"`
+		Enabled *bool           `yaml:"enabled"`
+		Limits  RateLimitConfig `yaml:"limits"`
+		Retry   RetryConfig     `yaml:"retry"`
+
+		Encryption  *EncryptionConfig  `yaml:"encryption"`
+		Obfuscation *ObfuscationConfig `yaml:"obfuscation"`
+		Chaos       *ChaosConfig       `yaml:"chaos"`
+	}
+	if err := node.Decode(&header); err != nil {
+		return err
+	}
+	if header.Type == "" {
+		return fmt.Errorf("第%d行: 驱动器缺少type", node.Line)
+	}
+
+	s.Type = header.Type
+	s.Name = header.Name
+	if s.Name == "" {
+		s.Name = header.Type
+	}
+	if !driveNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("第%d行: 驱动器名 %q 无效，只能包含字母、数字和 . _ -", node.Line, s.Name)
+	}
+	s.Enabled = header.Enabled == nil || *header.Enabled
+	s.Limits = header.Limits
+	s.Retry = header.Retry
+	s.Encryption = header.Encryption
+	s.Obfuscation = header.Obfuscation
+	s.Chaos = header.Chaos
+	s.node = *node
+	return nil
+}
+
+// 将驱动器配置解码到驱动的配置结构体，凭据存储中该驱动器的字段覆盖配置文件
+func (s *DriveSpec) Decode(out interface{}) error {
+	if s.node.Kind != 0 {
+		if err := s.node.Decode(out); err != nil {
+			return err
+		}
+	}
+	return decodeCredential(s.Name, out)
+}
+
+// 由已有的配置结构体构造DriveSpec（用于兼容顶层的 baidu/aliyun/local 配置段）
+func NewDriveSpec(driverType, name string, cfg interface{}) (DriveSpec, error) {
+	spec := DriveSpec{Type: driverType, Name: name, Enabled: true}
+	if err := spec.node.Encode(cfg); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// 读取配置文件中的 drives 段，检查驱动器名不重复
+func LoadDriveSpecs(path string) ([]DriveSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Drives []yaml.Node `yaml:"drives"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析drives配置失败: %v", err)
+	}
+
+	var specs []DriveSpec
+	for i := range file.Drives {
+		nodes, err := expandAccounts(&file.Drives[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			var spec DriveSpec
+			if err := node.Decode(&spec); err != nil {
+				return nil, fmt.Errorf("解析drives配置失败: %v", err)
+			}
+			specs = append(specs, spec)
+		}
+	}
+
+	seen := make(map[string]int)
+	for _, spec := range specs {
+		if line, ok := seen[spec.Name]; ok {
+			return nil, fmt.Errorf("驱动器名 %s 重复（第%d行和第%d行），同类型的多个实例需要设置不同的name",
+				spec.Name, line, spec.node.Line)
+		}
+		seen[spec.Name] = spec.node.Line
+	}
+	return specs, nil
+}
+
+// 驱动器名会出现在元数据和日志中，限制为不含空白和路径分隔符的字符
+var driveNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// 将带accounts的驱动器配置展开为每个账号一份配置：账号中的字段覆盖外层同名字段
+// （整个字段替换，不做深度合并），未设置name的账号按序号命名
+func expandAccounts(node *yaml.Node) ([]*yaml.Node, error) {
+	if node.Kind != yaml.MappingNode {
+		return []*yaml.Node{node}, nil
+	}
+
+	var accounts *yaml.Node
+	var base []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "accounts" {
+			accounts = node.Content[i+1]
+			continue
+		}
+		base = append(base, node.Content[i], node.Content[i+1])
+	}
+	if accounts == nil {
+		return []*yaml.Node{node}, nil
+	}
+	if accounts.Kind != yaml.SequenceNode || len(accounts.Content) == 0 {
+		return nil, fmt.Errorf("第%d行: accounts应为非空列表", accounts.Line)
+	}
+
+	prefix := mappingValue(base, "name")
+	if prefix == "" {
+		prefix = mappingValue(base, "type")
+	}
+
+	nodes := make([]*yaml.Node, 0, len(accounts.Content))
+	for i, account := range accounts.Content {
+		if account.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("第%d行: accounts的每一项应为映射", account.Line)
+		}
+
+		merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: account.Line, Column: account.Column}
+		for j := 0; j < len(base); j += 2 {
+			// 外层的name只作为账号名的前缀
+			if base[j].Value != "name" && !hasKey(account.Content, base[j].Value) {
+				merged.Content = append(merged.Content, base[j], base[j+1])
+			}
+		}
+		if !hasKey(account.Content, "name") {
+			merged.Content = append(merged.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name"},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprintf("%s-%d", prefix, i+1)})
+		}
+		merged.Content = append(merged.Content, account.Content...)
+		nodes = append(nodes, merged)
+	}
+	return nodes, nil
+}
+
+func hasKey(content []*yaml.Node, key string) bool {
+	for i := 0; i+1 < len(content); i += 2 {
+		if content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// 映射中标量字段的值，不存在时返回空
+func mappingValue(content []*yaml.Node, key string) string {
+	for i := 0; i+1 < len(content); i += 2 {
+		if content[i].Value == key && content[i+1].Kind == yaml.ScalarNode {
+			return content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// 按类型创建驱动器（不连接）。驱动器外层由内到外依次包装：故障注入（chaos，模拟远程故障）、
+// 混淆（obfuscation）、加密（encryption）、限速（limits）、重试（默认开启）。限速按密文计算流量，每次重试都重新加密并经过限速
+func NewDriver(spec DriveSpec) (StorageDriver, error) {
+	registryMu.RLock()
+	factory, ok := registry[spec.Type]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未知的驱动类型 %s（支持: %v）", spec.Type, RegisteredTypes())
+	}
+
+	driver, err := factory(spec.Decode)
+	if err != nil {
+		return nil, err
+	}
+	if driver == nil {
+		return nil, errors.New("驱动工厂返回了空驱动器")
+	}
+	if spec.Chaos != nil {
+		driver = NewChaosDriver(driver, *spec.Chaos)
+	}
+	if spec.Obfuscation != nil {
+		if driver, err = NewObfuscatedDriver(driver, *spec.Obfuscation); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Encryption != nil {
+		if driver, err = NewEncryptedDriver(driver, *spec.Encryption); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Limits.Enabled() {
+		driver = NewRateLimitedDriver(driver, spec.Limits)
+	}
+	if spec.Retry.MaxAttempts != 1 {
+		driver = NewRetryDriver(driver, spec.Retry)
+	}
+	return driver, nil
+}
+
+// 内置驱动类型，配置结构体的yaml字段见各驱动的Config
+func init() {
+	RegisterConfig("baidu", NewBaiduDriver)
+	RegisterConfig("aliyun", NewAliyunDriver)
+	RegisterConfig("local", NewLocalDriver)
+	RegisterConfig("onedrive", NewOneDriveDriver)
+	RegisterConfig("dropbox", NewDropboxDriver)
+	RegisterConfig("s3", NewS3Driver)
+	RegisterConfig("b2", NewB2Driver)
+	RegisterConfig("tianyi", NewTianyiDriver)
+	RegisterConfig("quark", NewQuarkDriver)
+	RegisterConfig("alist", NewAlistDriver)
+	RegisterConfig("rclone", NewRcloneDriver)
+	RegisterConfig("http", NewHTTPObjectDriver)
+	RegisterConfig("ipfs", NewIPFSDriver)
+	RegisterConfig("plugin", NewPluginDriver)
+	RegisterConfig("memory", NewMemoryDriver)
+	RegisterConfig("share", NewShareDriver)
+	RegisterConfig("sia", NewSiaDriver)
+}
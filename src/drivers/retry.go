@@ -0,0 +1,184 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// 重试配置，未配置时使用默认值；max_attempts设为1关闭重试
+//
+//	drives:
+//	  - type: baidu
+//	    retry:
+//	      max_attempts: 5
+//	      base_delay: 500ms
+//	      max_delay: 30s
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"` // 包括首次请求的总次数，默认3
+	BaseDelay   time.Duration `yaml:"base_delay"`   // 首次重试前的等待上限，默认500ms
+	MaxDelay    time.Duration `yaml:"max_delay"`    // 单次等待上限，默认30s
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = 500 * time.Millisecond
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = 30 * time.Second
+	}
+	return c
+}
+
+// 重试驱动包装器：临时性错误按指数退避（全抖动）重试
+//
+// UploadPart不在此重试（UploadWithSession已逐片重试），CompleteSession和RenameChunk不重试，
+// 因为请求可能已在服务端生效，重复请求会失败。
+type RetryDriver struct {
+	baseWrapper
+	cfg RetryConfig
+}
+
+func NewRetryDriver(inner StorageDriver, cfg RetryConfig) *RetryDriver {
+	return &RetryDriver{baseWrapper: baseWrapper{inner: inner}, cfg: cfg.withDefaults()}
+}
+
+func (d *RetryDriver) GetUsage() (int64, int64, error) {
+	var used, total int64
+	err := d.retry(context.Background(), func() error {
+		var err error
+		used, total, err = d.inner.GetUsage()
+		return err
+	})
+	return used, total, err
+}
+
+func (d *RetryDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	var remoteID string
+	err := d.retry(ctx, func() error {
+		var err error
+		remoteID, err = d.inner.UploadChunk(ctx, data, storageID)
+		return err
+	})
+	return remoteID, err
+}
+
+func (d *RetryDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	var data []byte
+	err := d.retry(ctx, func() error {
+		var err error
+		data, err = d.inner.DownloadChunk(ctx, storageID)
+		return err
+	})
+	return data, err
+}
+
+func (d *RetryDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	var data []byte
+	err := d.retry(ctx, func() error {
+		var err error
+		data, err = d.baseWrapper.DownloadRange(ctx, storageID, offset, length)
+		return err
+	})
+	return data, err
+}
+
+func (d *RetryDriver) RestoreChunk(ctx context.Context, storageID string) error {
+	return d.retry(ctx, func() error {
+		return d.baseWrapper.RestoreChunk(ctx, storageID)
+	})
+}
+
+func (d *RetryDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	return d.retry(ctx, func() error {
+		return d.baseWrapper.DeleteChunk(ctx, storageID)
+	})
+}
+
+func (d *RetryDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	var chunks []ChunkInfo
+	err := d.retry(ctx, func() error {
+		var err error
+		chunks, err = d.baseWrapper.ListChunks(ctx, prefix)
+		return err
+	})
+	return chunks, err
+}
+
+func (d *RetryDriver) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	var session *UploadSession
+	err := d.retry(ctx, func() error {
+		var err error
+		session, err = d.baseWrapper.StartUploadSession(ctx, storageID, size)
+		return err
+	})
+	return session, err
+}
+
+// 执行op，临时性错误时退避后重试，返回最后一次的错误
+func (d *RetryDriver) retry(ctx context.Context, op func() error) error {
+	var err error
+	for attempt := 0; attempt < d.cfg.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(d.backoff(attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+
+		if err = op(); err == nil || !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// 第attempt次重试前的等待：在[0, min(MaxDelay, BaseDelay*2^(attempt-1))]中随机选取
+func (d *RetryDriver) backoff(attempt int) time.Duration {
+	ceiling := d.cfg.MaxDelay
+	if shift := attempt - 1; shift < 30 {
+		ceiling = min(ceiling, d.cfg.BaseDelay<<shift)
+	}
+	return rand.N(ceiling + 1)
+}
+
+// 是否为值得重试的临时性错误：网络错误、超时、429和5xx。
+// 对象不存在、不支持的操作、调用方取消以及其他4xx不重试；
+// 无法识别的错误（部分驱动以%v包装错误，丢失了错误链）按临时性错误处理
+func IsTransient(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrChunkNotFound),
+		errors.Is(err, ErrDeleteUnsupported),
+		errors.Is(err, ErrListUnsupported),
+		errors.Is(err, ErrSessionUnsupported),
+		errors.Is(err, ErrRenameUnsupported),
+		errors.Is(err, ErrRestoreInProgress),
+		errors.Is(err, ErrRestoreUnsupported),
+		errors.Is(err, ErrDecrypt),
+		errors.Is(err, ErrCapacityExceeded),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+			return true
+		}
+		return apiErr.StatusCode >= 500
+	}
+
+	// 网络错误、连接中断、客户端超时
+	return true
+}
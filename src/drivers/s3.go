@@ -0,0 +1,366 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// 默认分片大小，超过后自动使用分片上传
+const s3DefaultPartSize = 16 * 1024 * 1024
+
+// 会话上传时同时上传的分片数
+const s3ParallelParts = 4
+
+// 使用量缓存时间，避免每次健康检查都列举整个前缀
+const s3UsageCacheTTL = 5 * time.Minute
+
+// S3兼容存储配置（AWS S3、MinIO及其他兼容服务）
+type S3Config struct {
+	Enabled    bool   `yaml:"enabled"`
+	Endpoint   string `yaml:"endpoint"` // 如 s3.amazonaws.com、minio.local:9000
+	Region     string `yaml:"region"`
+	Bucket     string `yaml:"bucket"`
+	AccessKey  string `yaml:"access_key"`
+	SecretKey  string `yaml:"secret_key"`
+	UseSSL     bool   `yaml:"use_ssl"`
+	PathStyle  bool   `yaml:"path_style"`  // 自建MinIO通常需要路径风格访问
+	Prefix     string `yaml:"prefix"`      // 对象键前缀，默认 panmatrix
+	PartSize   int64  `yaml:"part_size"`   // 分片大小（字节）
+	QuotaBytes int64  `yaml:"quota_bytes"` // S3没有配额概念，可配置可用空间上限，0表示不限
+	Proxy      string `yaml:"proxy"`       // 覆盖http段的默认代理
+
+	// 上传使用的存储类型，如 STANDARD_IA；GLACIER、DEEP_ARCHIVE 为归档存储，
+	// 只能作为RAID5的校验盘，读取前需先恢复
+	StorageClass string `yaml:"storage_class"`
+	RestoreDays  int    `yaml:"restore_days"` // 恢复后保持可读的天数，默认1
+	RestoreTier  string `yaml:"restore_tier"` // Expedited、Standard（默认）或 Bulk
+}
+
+// 读取前需要恢复的S3存储类型
+func isS3ArchiveClass(class string) bool {
+	switch strings.ToUpper(class) {
+	case "GLACIER", "DEEP_ARCHIVE":
+		return true
+	}
+	return false
+}
+
+// S3兼容存储驱动
+type S3Driver struct {
+	cfg    S3Config
+	client *minio.Client
+	core   *minio.Core // 分片上传会话使用的底层接口
+
+	usedBytes int64
+	usageTime time.Time
+	usageMu   sync.Mutex
+}
+
+func NewS3Driver(cfg S3Config) (*S3Driver, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("S3配置缺少endpoint或bucket")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "panmatrix"
+	}
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = s3DefaultPartSize
+	}
+	if cfg.RestoreDays <= 0 {
+		cfg.RestoreDays = 1
+	}
+	if cfg.RestoreTier == "" {
+		cfg.RestoreTier = string(minio.TierStandard)
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	transport, err := httpTransport(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+		Transport:    transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建S3客户端失败: %v", err)
+	}
+
+	return &S3Driver{cfg: cfg, client: client, core: &minio.Core{Client: client}}, nil
+}
+
+// 连接检查：存储桶必须存在
+func (d *S3Driver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	return d.checkBucket(ctx)
+}
+
+func (d *S3Driver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+func (d *S3Driver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error { return d.checkBucket(ctx) })
+}
+
+func (d *S3Driver) checkBucket(ctx context.Context) error {
+	exists, err := d.client.BucketExists(ctx, d.cfg.Bucket)
+	if err != nil {
+		return fmt.Errorf("访问S3存储桶失败: %v", err)
+	}
+	if !exists {
+		return fmt.Errorf("S3存储桶不存在: %s", d.cfg.Bucket)
+	}
+	return nil
+}
+
+// 已用空间为前缀下所有对象的大小之和，总空间为配置的配额
+func (d *S3Driver) GetUsage() (int64, int64, error) {
+	d.usageMu.Lock()
+	defer d.usageMu.Unlock()
+
+	if time.Since(d.usageTime) > s3UsageCacheTTL {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		var used int64
+		for obj := range d.client.ListObjects(ctx, d.cfg.Bucket, minio.ListObjectsOptions{
+			Prefix:    d.cfg.Prefix + "/",
+			Recursive: true,
+		}) {
+			if obj.Err != nil {
+				return 0, 0, fmt.Errorf("统计S3使用量失败: %v", obj.Err)
+			}
+			used += obj.Size
+		}
+		d.usedBytes = used
+		d.usageTime = time.Now()
+	}
+
+	return d.usedBytes, d.cfg.QuotaBytes, nil
+}
+
+// 上传数据块，超过分片大小时自动使用分片上传
+func (d *S3Driver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	key := d.objectKey(storageID)
+	_, err := d.client.PutObject(ctx, d.cfg.Bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{
+			ContentType:  "application/octet-stream",
+			PartSize:     uint64(d.cfg.PartSize),
+			StorageClass: d.cfg.StorageClass,
+		})
+	if err != nil {
+		return "", fmt.Errorf("S3上传失败: %v", err)
+	}
+
+	d.adjustUsage(int64(len(data)))
+	return storageID, nil
+}
+
+func (d *S3Driver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	return d.getObject(ctx, storageID, minio.GetObjectOptions{})
+}
+
+func (d *S3Driver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	var opts minio.GetObjectOptions
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+
+	return d.getObject(ctx, storageID, opts)
+}
+
+func (d *S3Driver) getObject(ctx context.Context, storageID string, opts minio.GetObjectOptions) ([]byte, error) {
+	obj, err := d.client.GetObject(ctx, d.cfg.Bucket, d.objectKey(storageID), opts)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "InvalidRange":
+			// 范围起点超出对象末尾
+			return []byte{}, nil
+		case "InvalidObjectState":
+			return nil, d.archivedError(ctx, storageID)
+		}
+		return nil, s3Error(err)
+	}
+	return data, nil
+}
+
+// 对象处于归档状态，查询是否已在恢复
+func (d *S3Driver) archivedError(ctx context.Context, storageID string) error {
+	restoreErr := &RestoreError{StorageID: storageID}
+	info, err := d.client.StatObject(ctx, d.cfg.Bucket, d.objectKey(storageID), minio.StatObjectOptions{})
+	if err == nil && info.Restore != nil {
+		restoreErr.Ongoing = info.Restore.OngoingRestore
+	}
+	return restoreErr
+}
+
+// 提交归档对象的恢复请求。重复提交和对未归档对象的请求都视为成功
+func (d *S3Driver) RestoreChunk(ctx context.Context, storageID string) error {
+	var req minio.RestoreRequest
+	req.SetDays(d.cfg.RestoreDays)
+	req.SetGlacierJobParameters(minio.GlacierJobParameters{Tier: minio.TierType(d.cfg.RestoreTier)})
+
+	err := d.client.RestoreObject(ctx, d.cfg.Bucket, d.objectKey(storageID), "", req)
+	if err == nil {
+		return nil
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "RestoreAlreadyInProgress", "InvalidObjectState":
+		return nil
+	case "NoSuchKey":
+		return fmt.Errorf("%w: %v", ErrChunkNotFound, err)
+	default:
+		return fmt.Errorf("S3恢复请求失败: %v", err)
+	}
+}
+
+func (d *S3Driver) DeleteChunk(ctx context.Context, storageID string) error {
+	key := d.objectKey(storageID)
+	info, statErr := d.client.StatObject(ctx, d.cfg.Bucket, key, minio.StatObjectOptions{})
+
+	if err := d.client.RemoveObject(ctx, d.cfg.Bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("S3删除失败: %v", err)
+	}
+	if statErr == nil {
+		d.adjustUsage(-info.Size)
+	}
+	return nil
+}
+
+func (d *S3Driver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	keyPrefix := d.cfg.Prefix + "/"
+	var chunks []ChunkInfo
+	for obj := range d.client.ListObjects(ctx, d.cfg.Bucket, minio.ListObjectsOptions{
+		Prefix:    keyPrefix + prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("列举S3对象失败: %v", obj.Err)
+		}
+		chunks = append(chunks, ChunkInfo{
+			StorageID: strings.TrimPrefix(obj.Key, keyPrefix),
+			Size:      obj.Size,
+			ModTime:   obj.LastModified,
+		})
+	}
+	return chunks, nil
+}
+
+// 创建S3分片上传
+func (d *S3Driver) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	uploadID, err := d.core.NewMultipartUpload(ctx, d.cfg.Bucket, d.objectKey(storageID),
+		minio.PutObjectOptions{ContentType: "application/octet-stream", StorageClass: d.cfg.StorageClass})
+	if err != nil {
+		return nil, fmt.Errorf("创建S3分片上传失败: %v", err)
+	}
+
+	return &UploadSession{
+		StorageID: storageID,
+		UploadID:  uploadID,
+		Size:      size,
+		PartSize:  d.cfg.PartSize,
+		State:     make(map[string]string),
+		StartedAt: time.Now(),
+	}, nil
+}
+
+// S3分片号从1开始
+func (d *S3Driver) UploadPart(ctx context.Context, session *UploadSession, partIndex int, data []byte) error {
+	part, err := d.core.PutObjectPart(ctx, d.cfg.Bucket, d.objectKey(session.StorageID), session.UploadID,
+		partIndex+1, bytes.NewReader(data), int64(len(data)), minio.PutObjectPartOptions{})
+	if err != nil {
+		return fmt.Errorf("S3分片上传失败: %v", err)
+	}
+
+	session.SetState(s3PartKey(partIndex), part.ETag)
+	return nil
+}
+
+// S3分片相互独立，可以并行上传
+func (d *S3Driver) MaxParallelParts() int {
+	return s3ParallelParts
+}
+
+func (d *S3Driver) Capabilities() Capabilities {
+	return Capabilities{
+		RangeDownload:    true,
+		Multipart:        true,
+		MaxParallelParts: s3ParallelParts,
+		Delete:           true,
+		List:             true,
+		Archive:          isS3ArchiveClass(d.cfg.StorageClass),
+	}
+}
+
+func (d *S3Driver) CompleteSession(ctx context.Context, session *UploadSession) (string, error) {
+	parts := make([]minio.CompletePart, session.PartCount())
+	for i := range parts {
+		etag, ok := session.GetState(s3PartKey(i))
+		if !ok {
+			return "", fmt.Errorf("S3分片%d未上传", i)
+		}
+		parts[i] = minio.CompletePart{PartNumber: i + 1, ETag: etag}
+	}
+
+	key := d.objectKey(session.StorageID)
+	if _, err := d.core.CompleteMultipartUpload(ctx, d.cfg.Bucket, key, session.UploadID, parts, minio.PutObjectOptions{}); err != nil {
+		return "", fmt.Errorf("完成S3分片上传失败: %v", err)
+	}
+
+	d.adjustUsage(session.Size)
+	return session.StorageID, nil
+}
+
+func (d *S3Driver) AbortSession(ctx context.Context, session *UploadSession) error {
+	return d.core.AbortMultipartUpload(ctx, d.cfg.Bucket, d.objectKey(session.StorageID), session.UploadID)
+}
+
+func s3PartKey(partIndex int) string {
+	return fmt.Sprintf("etag_%d", partIndex)
+}
+
+// 上传/删除后同步调整缓存的使用量
+func (d *S3Driver) adjustUsage(delta int64) {
+	d.usageMu.Lock()
+	defer d.usageMu.Unlock()
+	if !d.usageTime.IsZero() {
+		d.usedBytes += delta
+	}
+}
+
+func (d *S3Driver) objectKey(storageID string) string {
+	return path.Join(d.cfg.Prefix, storageID)
+}
+
+func s3Error(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return fmt.Errorf("%w: %v", ErrChunkNotFound, err)
+	}
+	return fmt.Errorf("S3下载失败: %v", err)
+}
@@ -0,0 +1,404 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 网络共享配置。数据写入mount_path挂载的SMB/NFS共享；配置了server时，
+// 挂载点未挂载或已失效会自动挂载（Linux，需要root权限或fstab中的user选项），
+// 也可以用remount_command自定义挂载命令
+//
+//	drives:
+//	  - type: share
+//	    name: nas-smb
+//	    protocol: smb
+//	    mount_path: /mnt/nas-smb
+//	    server: 192.168.1.10
+//	    share: backup
+//	    username: panmatrix
+//	    password: ...
+//	  - type: share
+//	    name: nas-nfs
+//	    protocol: nfs
+//	    mount_path: /mnt/nas-nfs
+//	    remount_command: ["mount", "/mnt/nas-nfs"]
+type ShareConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Protocol  string `yaml:"protocol"`   // smb 或 nfs
+	MountPath string `yaml:"mount_path"` // 共享的挂载点
+	Dir       string `yaml:"dir"`        // 共享中的存储目录，默认 PanMatrix
+
+	// 自动挂载
+	Server       string `yaml:"server"` // 主机名或IP
+	Share        string `yaml:"share"`  // SMB共享名，或NFS导出路径如 /export/backup
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	Domain       string `yaml:"domain"`
+	MountOptions string `yaml:"mount_options"` // 附加的挂载选项，如 vers=3.0
+
+	RemountCommand []string `yaml:"remount_command"` // 挂载失效时执行的命令，优先于自动挂载
+
+	OpTimeout  time.Duration `yaml:"op_timeout"`  // 单次文件操作超时，默认5分钟；失效的NFS挂载可能无限阻塞
+	QuotaBytes int64         `yaml:"quota_bytes"` // 可用空间上限，0表示使用共享报告的容量
+}
+
+// 共享上的文件操作，路径相对于挂载点，以/分隔
+type shareFS interface {
+	WriteFile(name string, data []byte) error
+	ReadFile(name string) ([]byte, error)
+	ReadAt(name string, offset, length int64) ([]byte, error)
+	Rename(oldName, newName string) error
+	Remove(name string) error
+	ReadDir(dir string) ([]fs.FileInfo, error)
+	MkdirAll(dir string) error
+	Usage(dir string) (used, total int64, err error)
+	Close() error
+}
+
+// SMB/NFS网络共享驱动
+//
+// 挂载失效（NFS句柄失效、服务器断开、操作超时）时重新挂载后重试一次；
+// 挂载丢失时拒绝操作，避免数据静默写入本地磁盘。
+// 写入先写临时文件再重命名，中断的上传不会留下不完整的数据块。
+type ShareDriver struct {
+	cfg ShareConfig
+
+	fs shareFS
+	mu sync.Mutex
+}
+
+func NewShareDriver(cfg ShareConfig) (*ShareDriver, error) {
+	if cfg.Protocol != "smb" && cfg.Protocol != "nfs" {
+		return nil, fmt.Errorf("不支持的共享协议 %q（支持 smb、nfs）", cfg.Protocol)
+	}
+	if cfg.MountPath == "" {
+		return nil, errors.New("共享配置缺少mount_path")
+	}
+	if cfg.Server != "" && cfg.Share == "" {
+		return nil, errors.New("自动挂载共享需要配置share")
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "PanMatrix"
+	}
+	cfg.Dir = strings.Trim(path.Clean("/"+cfg.Dir), "/")
+	if cfg.OpTimeout <= 0 {
+		cfg.OpTimeout = 5 * time.Minute
+	}
+
+	return &ShareDriver{cfg: cfg}, nil
+}
+
+// 连接共享并确保存储目录存在
+func (d *ShareDriver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	return d.withFS(ctx, func(fsys shareFS) error {
+		if err := fsys.MkdirAll(d.cfg.Dir); err != nil {
+			return fmt.Errorf("创建共享存储目录失败: %v", err)
+		}
+		return nil
+	})
+}
+
+func (d *ShareDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// 列举存储目录，失效的挂载和断开的会话在这里被发现
+func (d *ShareDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error {
+		return d.withFS(ctx, func(fsys shareFS) error {
+			_, err := fsys.ReadDir(d.cfg.Dir)
+			return err
+		})
+	})
+}
+
+func (d *ShareDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	// 配置了quota_bytes时已用空间只统计存储目录，共享上的其他数据不计入
+	var used, total int64
+	err := d.withFS(ctx, func(fsys shareFS) error {
+		if d.cfg.QuotaBytes <= 0 {
+			var err error
+			used, total, err = fsys.Usage(d.cfg.Dir)
+			return err
+		}
+
+		entries, err := fsys.ReadDir(d.cfg.Dir)
+		if err != nil {
+			return err
+		}
+		used, total = 0, d.cfg.QuotaBytes
+		for _, entry := range entries {
+			used += entry.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取共享容量失败: %v", err)
+	}
+	return used, total, nil
+}
+
+func (d *ShareDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	name, err := d.chunkPath(storageID)
+	if err != nil {
+		return "", err
+	}
+
+	err = d.withFS(ctx, func(fsys shareFS) error {
+		tmp := name + ".tmp"
+		if err := fsys.WriteFile(tmp, data); err != nil {
+			return err
+		}
+		if err := fsys.Rename(tmp, name); err != nil {
+			fsys.Remove(tmp)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("共享上传失败: %v", err)
+	}
+	return storageID, nil
+}
+
+func (d *ShareDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	name, err := d.chunkPath(storageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = d.withFS(ctx, func(fsys shareFS) error {
+		var err error
+		data, err = fsys.ReadFile(name)
+		return err
+	})
+	if err != nil {
+		return nil, shareError("下载", storageID, err)
+	}
+	return data, nil
+}
+
+func (d *ShareDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	name, err := d.chunkPath(storageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = d.withFS(ctx, func(fsys shareFS) error {
+		var err error
+		data, err = fsys.ReadAt(name, offset, length)
+		return err
+	})
+	if err != nil {
+		return nil, shareError("下载", storageID, err)
+	}
+	return data, nil
+}
+
+// 删除数据块，文件已不存在时视为成功
+func (d *ShareDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	name, err := d.chunkPath(storageID)
+	if err != nil {
+		return err
+	}
+
+	err = d.withFS(ctx, func(fsys shareFS) error {
+		return fsys.Remove(name)
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return shareError("删除", storageID, err)
+	}
+	return nil
+}
+
+func (d *ShareDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	var entries []fs.FileInfo
+	err := d.withFS(ctx, func(fsys shareFS) error {
+		var err error
+		entries, err = fsys.ReadDir(d.cfg.Dir)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("列举共享目录失败: %v", err)
+	}
+
+	var chunks []ChunkInfo
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+		chunks = appendChunk(chunks, prefix, ChunkInfo{
+			StorageID: entry.Name(),
+			Size:      entry.Size(),
+			ModTime:   entry.ModTime(),
+		})
+	}
+	return chunks, nil
+}
+
+func (d *ShareDriver) RenameChunk(ctx context.Context, storageID, newStorageID string) error {
+	oldName, err := d.chunkPath(storageID)
+	if err != nil {
+		return err
+	}
+	newName, err := d.chunkPath(newStorageID)
+	if err != nil {
+		return err
+	}
+
+	// 只在首次尝试时检查目标是否存在，重连后的重试可能是上一次重命名已生效
+	first := true
+	err = d.withFS(ctx, func(fsys shareFS) error {
+		if first {
+			first = false
+			if _, err := fsys.ReadAt(newName, 0, 1); err == nil {
+				return fmt.Errorf("目标已存在: %s", newStorageID)
+			}
+		}
+		return fsys.Rename(oldName, newName)
+	})
+	if err != nil {
+		return shareError("重命名", storageID, err)
+	}
+	return nil
+}
+
+func (d *ShareDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.fs == nil {
+		return nil
+	}
+	err := d.fs.Close()
+	d.fs = nil
+	return err
+}
+
+// 在已连接的共享上执行op（带操作超时）；连接断开或挂载失效时重连并重试一次
+func (d *ShareDriver) withFS(ctx context.Context, op func(shareFS) error) error {
+	for attempt := 0; ; attempt++ {
+		fsys, err := d.connection(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = runWithTimeout(ctx, d.cfg.OpTimeout, func() error { return op(fsys) })
+		if err == nil || attempt > 0 || !isStaleShare(err) || ctx.Err() != nil {
+			return err
+		}
+		d.reset(fsys)
+	}
+}
+
+// 当前连接，未连接时建立连接
+func (d *ShareDriver) connection(ctx context.Context) (shareFS, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.fs != nil {
+		return d.fs, nil
+	}
+
+	fsys, err := openMountFS(ctx, d.cfg)
+	if err != nil {
+		return nil, err
+	}
+	d.fs = fsys
+	return fsys, nil
+}
+
+// 丢弃失效的连接，下次操作时重新连接
+func (d *ShareDriver) reset(fsys shareFS) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.fs == fsys {
+		fsys.Close()
+		d.fs = nil
+	}
+}
+
+// 数据块在共享中的路径，storageID不能包含路径分隔符
+func (d *ShareDriver) chunkPath(storageID string) (string, error) {
+	if storageID == "" || storageID == "." || storageID == ".." || strings.ContainsAny(storageID, `/\`) {
+		return "", fmt.Errorf("无效的数据块ID: %q", storageID)
+	}
+	return d.cfg.Dir + "/" + storageID, nil
+}
+
+// 在超时内执行fn。失效的NFS挂载上系统调用可能无限阻塞，超时后放弃等待（阻塞的goroutine留在后台）
+func runWithTimeout(ctx context.Context, timeout time.Duration, fn func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%w: %v", errShareTimeout, ctx.Err())
+		}
+		return ctx.Err()
+	}
+}
+
+var errShareTimeout = errors.New("共享操作超时")
+
+func shareError(op, storageID string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrChunkNotFound, storageID)
+	}
+	return fmt.Errorf("共享%s失败 %s: %w", op, storageID, err)
+}
+
+// 写入文件，已存在时覆盖
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	// 网络文件系统的写入错误可能推迟到Sync或Close才报告
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 是否为连接断开或挂载失效导致的错误，此类错误重连后重试
+func isStaleShare(err error) bool {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrExist), errors.Is(err, fs.ErrPermission):
+		return false
+	case errors.Is(err, errShareTimeout), errors.Is(err, errMountStale),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+
+	return isStaleErrno(err)
+}
@@ -0,0 +1,186 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+var errMountStale = errors.New("共享未挂载或挂载已失效")
+
+// 系统已挂载的SMB/NFS目录
+type mountFS struct {
+	root string
+}
+
+// 检查挂载点有效，未挂载或已失效时重新挂载后再检查一次
+func openMountFS(ctx context.Context, cfg ShareConfig) (shareFS, error) {
+	root := filepath.Clean(cfg.MountPath)
+	err := runWithTimeout(ctx, probeTimeout, func() error { return checkMounted(root) })
+	if err != nil && (len(cfg.RemountCommand) > 0 || cfg.Server != "") {
+		if err := mountShare(ctx, cfg, root); err != nil {
+			return nil, err
+		}
+		err = runWithTimeout(ctx, probeTimeout, func() error { return checkMounted(root) })
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mountFS{root: root}, nil
+}
+
+// 挂载共享：优先执行remount_command，否则按server/share构造mount命令。
+// 失效的挂载先强制卸载
+func mountShare(ctx context.Context, cfg ShareConfig, root string) error {
+	if len(cfg.RemountCommand) > 0 {
+		return runMount(ctx, cfg.RemountCommand)
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("当前平台不支持自动挂载共享，请配置remount_command")
+	}
+
+	// 未挂载时卸载会失败，忽略
+	exec.CommandContext(ctx, "umount", "-f", "-l", root).Run()
+
+	var args []string
+	options := cfg.MountOptions
+	switch cfg.Protocol {
+	case "smb":
+		// 凭据写入仅所有者可读的临时文件，避免出现在进程列表中
+		credentials, err := os.CreateTemp("", "panmatrix-smb-*")
+		if err != nil {
+			return fmt.Errorf("创建SMB凭据文件失败: %v", err)
+		}
+		defer os.Remove(credentials.Name())
+		fmt.Fprintf(credentials, "username=%s\npassword=%s\n", cfg.Username, cfg.Password)
+		if cfg.Domain != "" {
+			fmt.Fprintf(credentials, "domain=%s\n", cfg.Domain)
+		}
+		if err := credentials.Close(); err != nil {
+			return fmt.Errorf("写入SMB凭据文件失败: %v", err)
+		}
+
+		options = joinMountOptions("credentials="+credentials.Name(), options)
+		args = []string{"-t", "cifs", "//" + cfg.Server + "/" + strings.Trim(cfg.Share, "/"), root}
+	case "nfs":
+		args = []string{"-t", "nfs", cfg.Server + ":" + cfg.Share, root}
+	}
+	if options != "" {
+		args = append(args, "-o", options)
+	}
+	return runMount(ctx, append([]string{"mount"}, args...))
+}
+
+func runMount(ctx context.Context, command []string) error {
+	out, err := exec.CommandContext(ctx, command[0], command[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("挂载共享失败: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func joinMountOptions(options ...string) string {
+	var parts []string
+	for _, opt := range options {
+		if opt != "" {
+			parts = append(parts, opt)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// 挂载点必须与其父目录位于不同的设备上，否则挂载丢失后数据会静默写入本地磁盘
+func checkMounted(root string) error {
+	dev, err := deviceOf(root)
+	if err != nil {
+		if isStaleErrno(err) {
+			return fmt.Errorf("%w: %s: %v", errMountStale, root, err)
+		}
+		return err
+	}
+	parentDev, err := deviceOf(filepath.Dir(root))
+	if err != nil {
+		return err
+	}
+	if dev == parentDev && filepath.Dir(root) != root {
+		return fmt.Errorf("%w: %s", errMountStale, root)
+	}
+	return nil
+}
+
+func (m *mountFS) path(name string) string {
+	return filepath.Join(m.root, filepath.FromSlash(name))
+}
+
+func (m *mountFS) WriteFile(name string, data []byte) error {
+	return writeFileSync(m.path(name), data)
+}
+
+func (m *mountFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(m.path(name))
+}
+
+func (m *mountFS) ReadAt(name string, offset, length int64) ([]byte, error) {
+	f, err := os.Open(m.path(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readRange(f, offset, length)
+}
+
+func (m *mountFS) Rename(oldName, newName string) error {
+	return os.Rename(m.path(oldName), m.path(newName))
+}
+
+func (m *mountFS) Remove(name string) error {
+	return os.Remove(m.path(name))
+}
+
+func (m *mountFS) ReadDir(dir string) ([]fs.FileInfo, error) {
+	entries, err := os.ReadDir(m.path(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]fs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// 列举期间被删除
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (m *mountFS) MkdirAll(dir string) error {
+	return os.MkdirAll(m.path(dir), 0o755)
+}
+
+func (m *mountFS) Usage(dir string) (int64, int64, error) {
+	return diskUsage(m.path(dir))
+}
+
+func (m *mountFS) Close() error { return nil }
+
+// 从ReaderAt读取[offset, offset+length)，超出末尾的部分被截断
+func readRange(r io.ReaderAt, offset, length int64) ([]byte, error) {
+	buf := make([]byte, length)
+	n, err := r.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
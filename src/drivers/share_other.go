@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd)
+
+package drivers
+
+import "errors"
+
+// 无法比较设备号的平台不检查挂载点
+func deviceOf(path string) (uint64, error) {
+	return 0, errors.New("当前平台不支持检查挂载点")
+}
+
+func diskUsage(path string) (int64, int64, error) {
+	return 0, 0, errors.New("当前平台不支持获取共享容量，请配置quota_bytes")
+}
+
+func isStaleErrno(err error) bool { return false }
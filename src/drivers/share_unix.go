@@ -0,0 +1,33 @@
+//go:build linux || darwin || freebsd
+
+package drivers
+
+import (
+	"errors"
+	"syscall"
+)
+
+func deviceOf(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
+
+// 文件系统的已用和总空间
+func diskUsage(path string) (int64, int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	total := int64(st.Blocks) * int64(st.Bsize)
+	free := int64(st.Bavail) * int64(st.Bsize)
+	return total - free, total, nil
+}
+
+// 失效的NFS句柄、断开的挂载
+func isStaleErrno(err error) bool {
+	return errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.ENOTCONN) ||
+		errors.Is(err, syscall.EIO) || errors.Is(err, syscall.EHOSTDOWN)
+}
@@ -0,0 +1,229 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// 每次列举请求返回的对象数
+const siaListLimit = 1000
+
+// Sia去中心化存储配置，通过renterd的HTTP API访问。
+// 数据由renterd按其冗余设置编码后分散到多个存储节点，需先在renterd中完成钱包、合约和存储桶的设置
+type SiaConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Address     string `yaml:"address"`      // renterd地址，默认 http://127.0.0.1:9980
+	Password    string `yaml:"password"`     // renterd API密码
+	Bucket      string `yaml:"bucket"`       // 默认 default
+	Prefix      string `yaml:"prefix"`       // 对象路径前缀，默认 panmatrix
+	QuotaBytes  int64  `yaml:"quota_bytes"`  // Sia按合约付费，没有固定容量，可配置可用空间上限，0表示不限
+	TimeoutSecs int    `yaml:"timeout_secs"` // 单次请求超时，默认900秒（上传需要等待分片写入多个节点）
+	Proxy       string `yaml:"proxy"`        // 覆盖http段的默认代理
+}
+
+// Sia驱动（renterd worker/bus API）
+type SiaDriver struct {
+	cfg    SiaConfig
+	client *http.Client
+}
+
+func NewSiaDriver(cfg SiaConfig) (*SiaDriver, error) {
+	if cfg.Password == "" {
+		return nil, errors.New("Sia配置缺少renterd的API密码")
+	}
+	if cfg.Address == "" {
+		cfg.Address = "http://127.0.0.1:9980"
+	}
+	cfg.Address = strings.TrimRight(cfg.Address, "/")
+	if cfg.Bucket == "" {
+		cfg.Bucket = "default"
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "panmatrix"
+	}
+	timeout := 15 * time.Minute
+	if cfg.TimeoutSecs > 0 {
+		timeout = time.Duration(cfg.TimeoutSecs) * time.Second
+	}
+
+	client, err := newHTTPClient(timeout, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &SiaDriver{cfg: cfg, client: client}, nil
+}
+
+func (d *SiaDriver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	if err := d.state(ctx); err != nil {
+		return fmt.Errorf("连接renterd失败: %v", err)
+	}
+	return nil
+}
+
+func (d *SiaDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+func (d *SiaDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error { return d.state(ctx) })
+}
+
+func (d *SiaDriver) state(ctx context.Context) error {
+	req, err := d.newRequest(ctx, http.MethodGet, d.cfg.Address+"/api/bus/state", nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(d.client, req, nil)
+}
+
+// 已用空间为存储桶中对象的总大小（不含冗余编码的开销），总空间为配置的上限
+func (d *SiaDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	target := d.cfg.Address + "/api/bus/stats/objects?" + url.Values{"bucket": {d.cfg.Bucket}}.Encode()
+	req, err := d.newRequest(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	var stats struct {
+		TotalObjectsSize int64 `json:"totalObjectsSize"`
+	}
+	if err := doJSON(d.client, req, &stats); err != nil {
+		return 0, 0, fmt.Errorf("获取Sia使用量失败: %v", err)
+	}
+	return stats.TotalObjectsSize, d.cfg.QuotaBytes, nil
+}
+
+func (d *SiaDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	req, err := d.newRequest(ctx, http.MethodPut, d.objectURL(storageID), data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	if err := doJSON(d.client, req, nil); err != nil {
+		return "", fmt.Errorf("Sia上传失败: %v", err)
+	}
+	return storageID, nil
+}
+
+func (d *SiaDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, d.objectURL(storageID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := doBytes(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("Sia下载失败: %w", err)
+	}
+	return data, nil
+}
+
+// renterd只从存储节点取回请求范围覆盖的扇区
+func (d *SiaDriver) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, d.objectURL(storageID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := doRange(d.client, req, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("Sia下载失败: %w", err)
+	}
+	return data, nil
+}
+
+// 删除对象，对象已不存在时视为成功
+func (d *SiaDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	req, err := d.newRequest(ctx, http.MethodDelete, d.objectURL(storageID), nil)
+	if err != nil {
+		return err
+	}
+
+	if err := doJSON(d.client, req, nil); err != nil && !errors.Is(err, ErrChunkNotFound) {
+		return fmt.Errorf("Sia删除失败: %v", err)
+	}
+	return nil
+}
+
+// 按前缀分页列举对象
+func (d *SiaDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	keyPrefix := "/" + d.cfg.Prefix + "/"
+	var chunks []ChunkInfo
+	marker := ""
+	for {
+		body, err := json.Marshal(map[string]interface{}{
+			"bucket": d.cfg.Bucket,
+			"prefix": keyPrefix + prefix,
+			"marker": marker,
+			"limit":  siaListLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		req, err := d.newRequest(ctx, http.MethodPost, d.cfg.Address+"/api/bus/objects/list", body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		var page struct {
+			HasMore    bool   `json:"hasMore"`
+			NextMarker string `json:"nextMarker"`
+			Objects    []struct {
+				Name    string    `json:"name"`
+				Size    int64     `json:"size"`
+				ModTime time.Time `json:"modTime"`
+			} `json:"objects"`
+		}
+		if err := doJSON(d.client, req, &page); err != nil {
+			return nil, fmt.Errorf("列举Sia对象失败: %v", err)
+		}
+
+		for _, obj := range page.Objects {
+			chunks = appendChunk(chunks, prefix, ChunkInfo{
+				StorageID: strings.TrimPrefix(obj.Name, keyPrefix),
+				Size:      obj.Size,
+				ModTime:   obj.ModTime,
+			})
+		}
+		if !page.HasMore || page.NextMarker == "" {
+			return chunks, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+// 构造请求，renterd使用用户名为空的Basic认证
+func (d *SiaDriver) newRequest(ctx context.Context, method, target string, body []byte) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, target, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth("", d.cfg.Password)
+	return req, nil
+}
+
+func (d *SiaDriver) objectURL(storageID string) string {
+	key := path.Join(d.cfg.Prefix, storageID)
+	return d.cfg.Address + "/api/worker/objects/" + escapePath(key) + "?" + url.Values{"bucket": {d.cfg.Bucket}}.Encode()
+}
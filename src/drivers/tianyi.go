@@ -0,0 +1,698 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tianyiWebURL    = "https://cloud.189.cn"
+	tianyiAuthURL   = "https://open.e.189.cn"
+	tianyiAPIURL    = "https://api.cloud.189.cn"
+	tianyiUploadURL = "https://upload.cloud.189.cn"
+
+	tianyiAppID = "8025431004"
+
+	// 分片上传的分片大小（天翼云盘固定为10MB）
+	tianyiSliceSize = 10 * 1024 * 1024
+	// 个人云根目录ID
+	tianyiRootFolderID = "-11"
+)
+
+// 天翼云盘配置
+type TianyiConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Username   string `yaml:"username"` // 手机号或邮箱
+	Password   string `yaml:"password"`
+	RootFolder string `yaml:"root_folder"` // 存储目录名，默认 PanMatrix
+	Proxy      string `yaml:"proxy"`       // 覆盖http段的默认代理
+}
+
+// 登录后获得的会话
+type tianyiSession struct {
+	SessionKey    string `json:"sessionKey"`
+	SessionSecret string `json:"sessionSecret"`
+	AccessToken   string `json:"accessToken"`
+}
+
+// 天翼云盘驱动（PC客户端接口）
+type TianyiDriver struct {
+	cfg    TianyiConfig
+	client *http.Client
+
+	session  *tianyiSession
+	folderID string
+	mu       sync.Mutex
+}
+
+func NewTianyiDriver(cfg TianyiConfig) (*TianyiDriver, error) {
+	if cfg.Username == "" || cfg.Password == "" {
+		return nil, errors.New("天翼云盘配置缺少username或password")
+	}
+	if cfg.RootFolder == "" {
+		cfg.RootFolder = "PanMatrix"
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := httpTransport(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TianyiDriver{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Minute, Jar: jar, Transport: transport},
+	}, nil
+}
+
+// 连接天翼云盘：登录并确保存储目录存在
+func (d *TianyiDriver) Connect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := d.login(ctx); err != nil {
+		return err
+	}
+
+	folderID, err := d.ensureFolder(ctx, tianyiRootFolderID, d.cfg.RootFolder)
+	if err != nil {
+		return fmt.Errorf("创建天翼云盘存储目录失败: %v", err)
+	}
+
+	d.mu.Lock()
+	d.folderID = folderID
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *TianyiDriver) IsAvailable() bool {
+	return pingAvailable(d)
+}
+
+// 查询容量作为探测
+func (d *TianyiDriver) Ping(ctx context.Context) (time.Duration, error) {
+	return timePing(func() error {
+		_, _, err := d.capacity(ctx)
+		return err
+	})
+}
+
+func (d *TianyiDriver) GetUsage() (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	return d.capacity(ctx)
+}
+
+func (d *TianyiDriver) capacity(ctx context.Context) (int64, int64, error) {
+	var info struct {
+		CloudCapacityInfo struct {
+			TotalSize int64 `json:"totalSize"`
+			UsedSize  int64 `json:"usedSize"`
+		} `json:"cloudCapacityInfo"`
+	}
+	if err := d.api(ctx, http.MethodGet, tianyiWebURL+"/api/portal/getUserSizeInfo.action", nil, &info); err != nil {
+		return 0, 0, fmt.Errorf("获取天翼云盘容量失败: %v", err)
+	}
+
+	return info.CloudCapacityInfo.UsedSize, info.CloudCapacityInfo.TotalSize, nil
+}
+
+// 分片上传：initMultiUpload -> getMultiUploadUrls -> PUT分片... -> commitMultiUploadFile
+// 返回天翼云盘文件ID作为存储ID
+func (d *TianyiDriver) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	d.mu.Lock()
+	folderID := d.folderID
+	d.mu.Unlock()
+	if folderID == "" {
+		return "", errors.New("天翼云盘未连接")
+	}
+
+	fileMD5 := md5.Sum(data)
+	var sliceMD5s []string
+
+	var initResp struct {
+		Data struct {
+			UploadFileID string `json:"uploadFileId"`
+		} `json:"data"`
+	}
+	err := d.uploadAPI(ctx, "/person/initMultiUpload", url.Values{
+		"parentFolderId": {folderID},
+		"fileName":       {storageID},
+		"fileSize":       {strconv.Itoa(len(data))},
+		"sliceSize":      {strconv.Itoa(tianyiSliceSize)},
+		"lazyCheck":      {"1"},
+	}, &initResp)
+	if err != nil {
+		return "", fmt.Errorf("初始化天翼云盘上传失败: %v", err)
+	}
+	uploadFileID := initResp.Data.UploadFileID
+
+	for partNumber, start := 1, 0; start < len(data); partNumber, start = partNumber+1, start+tianyiSliceSize {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		part := data[start:min(start+tianyiSliceSize, len(data))]
+		sum := md5.Sum(part)
+		sliceMD5s = append(sliceMD5s, strings.ToUpper(hex.EncodeToString(sum[:])))
+
+		var urls struct {
+			UploadURLs map[string]struct {
+				RequestURL    string `json:"requestURL"`
+				RequestHeader string `json:"requestHeader"`
+			} `json:"uploadUrls"`
+		}
+		err := d.uploadAPI(ctx, "/person/getMultiUploadUrls", url.Values{
+			"uploadFileId": {uploadFileID},
+			"partInfo":     {fmt.Sprintf("%d-%s", partNumber, base64.StdEncoding.EncodeToString(sum[:]))},
+		}, &urls)
+		if err != nil {
+			return "", fmt.Errorf("获取天翼云盘分片上传地址失败: %v", err)
+		}
+		target, ok := urls.UploadURLs["partNumber_"+strconv.Itoa(partNumber)]
+		if !ok {
+			return "", fmt.Errorf("天翼云盘未返回分片%d的上传地址", partNumber)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.RequestURL, bytes.NewReader(part))
+		if err != nil {
+			return "", err
+		}
+		headers, _ := url.ParseQuery(target.RequestHeader)
+		for k := range headers {
+			req.Header.Set(k, headers.Get(k))
+		}
+		if err := doJSON(d.client, req, nil); err != nil {
+			return "", fmt.Errorf("天翼云盘分片%d上传失败: %v", partNumber, err)
+		}
+	}
+
+	// 单分片时sliceMd5即文件MD5，多分片时为各分片MD5以换行连接后的MD5
+	fileMD5Hex := hex.EncodeToString(fileMD5[:])
+	sliceMD5 := fileMD5Hex
+	if len(sliceMD5s) > 1 {
+		sum := md5.Sum([]byte(strings.Join(sliceMD5s, "\n")))
+		sliceMD5 = hex.EncodeToString(sum[:])
+	}
+
+	var commit struct {
+		File struct {
+			UserFileID string `json:"userFileId"`
+		} `json:"file"`
+	}
+	err = d.uploadAPI(ctx, "/person/commitMultiUploadFile", url.Values{
+		"uploadFileId": {uploadFileID},
+		"fileMd5":      {fileMD5Hex},
+		"sliceMd5":     {sliceMD5},
+		"lazyCheck":    {"1"},
+		"opertype":     {"3"}, // 同名文件覆盖
+	}, &commit)
+	if err != nil {
+		return "", fmt.Errorf("提交天翼云盘上传失败: %v", err)
+	}
+
+	return commit.File.UserFileID, nil
+}
+
+// 先获取下载地址，再下载文件内容
+func (d *TianyiDriver) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	var link struct {
+		FileDownloadURL string `json:"fileDownloadUrl"`
+	}
+	query := url.Values{"fileId": {storageID}}
+	err := d.api(ctx, http.MethodGet, tianyiWebURL+"/api/open/file/getFileDownloadUrl.action?"+query.Encode(), nil, &link)
+	if err != nil {
+		return nil, fmt.Errorf("获取天翼云盘下载地址失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.Replace(link.FileDownloadURL, "&amp;", "&", -1), nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := doBytes(d.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("天翼云盘下载失败: %v", err)
+	}
+	return data, nil
+}
+
+// 通过批量任务删除文件（进入回收站）
+func (d *TianyiDriver) DeleteChunk(ctx context.Context, storageID string) error {
+	taskInfos, _ := json.Marshal([]map[string]interface{}{
+		{"fileId": storageID, "fileName": storageID, "isFolder": 0},
+	})
+	form := url.Values{
+		"type":      {"DELETE"},
+		"taskInfos": {string(taskInfos)},
+	}
+
+	err := d.api(ctx, http.MethodPost, tianyiWebURL+"/api/open/batch/createBatchTask.action", form, nil)
+	if err != nil {
+		return fmt.Errorf("天翼云盘删除失败: %v", err)
+	}
+	return nil
+}
+
+// 分页列出存储目录下的文件，远程ID为天翼云盘文件ID
+func (d *TianyiDriver) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	d.mu.Lock()
+	folderID := d.folderID
+	d.mu.Unlock()
+	if folderID == "" {
+		return nil, errors.New("天翼云盘未连接")
+	}
+
+	const pageSize = 200
+	var chunks []ChunkInfo
+	for page := 1; ; page++ {
+		var list struct {
+			FileListAO struct {
+				Count    int `json:"count"`
+				FileList []struct {
+					ID         json.Number `json:"id"`
+					Name       string      `json:"name"`
+					Size       int64       `json:"size"`
+					LastOpTime string      `json:"lastOpTime"`
+				} `json:"fileList"`
+			} `json:"fileListAO"`
+		}
+		query := url.Values{
+			"folderId":   {folderID},
+			"pageNum":    {strconv.Itoa(page)},
+			"pageSize":   {strconv.Itoa(pageSize)},
+			"mediaType":  {"0"},
+			"iconOption": {"5"},
+			"orderBy":    {"lastOpTime"},
+			"descending": {"true"},
+		}
+		err := d.api(ctx, http.MethodGet, tianyiWebURL+"/api/open/file/listFiles.action?"+query.Encode(), nil, &list)
+		if err != nil {
+			return nil, fmt.Errorf("列举天翼云盘文件失败: %v", err)
+		}
+
+		for _, f := range list.FileListAO.FileList {
+			modTime, _ := time.ParseInLocation("2006-01-02 15:04:05", f.LastOpTime, time.Local)
+			chunks = appendChunk(chunks, prefix, ChunkInfo{
+				StorageID: f.Name,
+				RemoteID:  f.ID.String(),
+				Size:      f.Size,
+				ModTime:   modTime,
+			})
+		}
+		if len(list.FileListAO.FileList) < pageSize || page*pageSize >= list.FileListAO.Count {
+			return chunks, nil
+		}
+	}
+}
+
+// 在父目录下查找或创建目录，返回目录ID
+func (d *TianyiDriver) ensureFolder(ctx context.Context, parentID, name string) (string, error) {
+	var folder struct {
+		ID json.Number `json:"id"`
+	}
+	form := url.Values{
+		"parentFolderId": {parentID},
+		"folderName":     {name},
+	}
+	// 同名目录已存在时接口返回已有目录
+	if err := d.api(ctx, http.MethodPost, tianyiWebURL+"/api/open/file/createFolder.action", form, &folder); err != nil {
+		return "", err
+	}
+	return folder.ID.String(), nil
+}
+
+// 调用需要签名的网页接口，会话失效时重新登录后重试一次
+func (d *TianyiDriver) api(ctx context.Context, method, target string, form url.Values, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		session, err := d.currentSession(ctx)
+		if err != nil {
+			return err
+		}
+
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, body)
+		if err != nil {
+			return err
+		}
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		d.sign(req, session, "")
+
+		err = d.decode(req, out)
+		if err != nil && attempt == 0 && tianyiSessionExpired(err) {
+			d.invalidateSession()
+			continue
+		}
+		return err
+	}
+}
+
+// 调用上传接口：参数以会话密钥AES加密后放在params中，并参与签名
+func (d *TianyiDriver) uploadAPI(ctx context.Context, uri string, params url.Values, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		session, err := d.currentSession(ctx)
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := tianyiEncryptParams(params, session.SessionSecret)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, tianyiUploadURL+uri+"?params="+encrypted, nil)
+		if err != nil {
+			return err
+		}
+		d.sign(req, session, encrypted)
+
+		err = d.decode(req, out)
+		if err != nil && attempt == 0 && tianyiSessionExpired(err) {
+			d.invalidateSession()
+			continue
+		}
+		return err
+	}
+}
+
+// 天翼云盘接口签名：HMAC-SHA1(sessionSecret, SessionKey&Operate&RequestURI&Date[&params])
+func (d *TianyiDriver) sign(req *http.Request, session *tianyiSession, params string) {
+	date := time.Now().UTC().Format(http.TimeFormat)
+	text := fmt.Sprintf("SessionKey=%s&Operate=%s&RequestURI=%s&Date=%s",
+		session.SessionKey, req.Method, req.URL.Path, date)
+	if params != "" {
+		text += "&params=" + params
+	}
+
+	mac := hmac.New(sha1.New, []byte(session.SessionSecret))
+	mac.Write([]byte(text))
+
+	req.Header.Set("Date", date)
+	req.Header.Set("SessionKey", session.SessionKey)
+	req.Header.Set("Signature", strings.ToUpper(hex.EncodeToString(mac.Sum(nil))))
+	req.Header.Set("X-Request-ID", randomHex(16))
+	req.Header.Set("Accept", "application/json;charset=UTF-8")
+}
+
+// 解码响应并检查业务错误码
+func (d *TianyiDriver) decode(req *http.Request, out interface{}) error {
+	data, err := doBytes(d.client, req)
+	if err != nil {
+		return tianyiError(err)
+	}
+
+	var status struct {
+		ResCode    json.RawMessage `json:"res_code"`
+		ResMessage string          `json:"res_message"`
+		Code       string          `json:"code"`
+		ErrorCode  string          `json:"errorCode"`
+		ErrorMsg   string          `json:"errorMsg"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if status.ErrorCode != "" {
+		return &tianyiAPIError{Code: status.ErrorCode, Message: status.ErrorMsg}
+	}
+	if code := string(status.ResCode); code != "" && code != "0" && code != `"0"` {
+		return &tianyiAPIError{Code: code, Message: status.ResMessage}
+	}
+	if status.Code != "" && status.Code != "SUCCESS" {
+		return &tianyiAPIError{Code: status.Code, Message: string(data)}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	return nil
+}
+
+func (d *TianyiDriver) currentSession(ctx context.Context) (*tianyiSession, error) {
+	d.mu.Lock()
+	session := d.session
+	d.mu.Unlock()
+	if session != nil {
+		return session, nil
+	}
+
+	if err := d.login(ctx); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.session, nil
+}
+
+func (d *TianyiDriver) invalidateSession() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.session = nil
+}
+
+// 账号密码登录：
+// 1. 访问统一登录页获取lt、reqId
+// 2. 获取登录配置（appConf）和RSA公钥（encryptConf）
+// 3. 以RSA加密账号密码提交登录，得到回调地址
+// 4. 以回调地址换取PC客户端会话（sessionKey/sessionSecret）
+func (d *TianyiDriver) login(ctx context.Context) error {
+	query := url.Values{
+		"appId":      {tianyiAppID},
+		"clientType": {"10020"},
+		"returnURL":  {"https://m.cloud.189.cn/zhuanti/2020/loginErrorPc/index.html"},
+		"timeStamp":  {strconv.FormatInt(time.Now().UnixMilli(), 10)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tianyiWebURL+"/api/portal/unifyLoginForPC.action?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := doRequest(d.client, req)
+	if err != nil {
+		return fmt.Errorf("天翼云盘登录失败: %v", err)
+	}
+	resp.Body.Close()
+
+	// 跳转后的登录页地址中带有lt和reqId
+	loginQuery := resp.Request.URL.Query()
+	lt, reqID := loginQuery.Get("lt"), loginQuery.Get("reqId")
+	if lt == "" || reqID == "" {
+		return errors.New("天翼云盘登录失败: 未获取到登录参数")
+	}
+
+	var appConf struct {
+		Data struct {
+			AccountType string `json:"accountType"`
+			ClientType  int    `json:"clientType"`
+			ParamID     string `json:"paramId"`
+			MailSuffix  string `json:"mailSuffix"`
+			ReturnURL   string `json:"returnUrl"`
+		} `json:"data"`
+	}
+	err = d.loginForm(ctx, "/api/logbox/oauth2/appConf.do", lt, reqID, url.Values{
+		"version": {"2.0"},
+		"appKey":  {tianyiAppID},
+	}, &appConf)
+	if err != nil {
+		return fmt.Errorf("获取天翼云盘登录配置失败: %v", err)
+	}
+
+	var encryptConf struct {
+		Data struct {
+			PubKey string `json:"pubKey"`
+			Pre    string `json:"pre"`
+		} `json:"data"`
+	}
+	err = d.loginForm(ctx, "/api/logbox/config/encryptConf.do", lt, reqID, url.Values{
+		"appId": {"cloud"},
+	}, &encryptConf)
+	if err != nil {
+		return fmt.Errorf("获取天翼云盘登录公钥失败: %v", err)
+	}
+
+	username, err := tianyiRSAEncrypt(encryptConf.Data.PubKey, d.cfg.Username)
+	if err != nil {
+		return err
+	}
+	password, err := tianyiRSAEncrypt(encryptConf.Data.PubKey, d.cfg.Password)
+	if err != nil {
+		return err
+	}
+
+	var submit struct {
+		Result int    `json:"result"`
+		Msg    string `json:"msg"`
+		ToURL  string `json:"toUrl"`
+	}
+	err = d.loginForm(ctx, "/api/logbox/oauth2/loginSubmit.do", lt, reqID, url.Values{
+		"version":         {"v2.0"},
+		"apToken":         {""},
+		"appKey":          {tianyiAppID},
+		"accountType":     {appConf.Data.AccountType},
+		"userName":        {encryptConf.Data.Pre + username},
+		"epd":             {encryptConf.Data.Pre + password},
+		"captchaType":     {""},
+		"validateCode":    {""},
+		"smsValidateCode": {""},
+		"captchaToken":    {""},
+		"returnUrl":       {appConf.Data.ReturnURL},
+		"mailSuffix":      {appConf.Data.MailSuffix},
+		"dynamicCheck":    {"FALSE"},
+		"clientType":      {strconv.Itoa(appConf.Data.ClientType)},
+		"cb_SaveName":     {"3"},
+		"isOauth2":        {"false"},
+		"state":           {""},
+		"paramId":         {appConf.Data.ParamID},
+	}, &submit)
+	if err != nil {
+		return fmt.Errorf("天翼云盘登录失败: %v", err)
+	}
+	if submit.Result != 0 {
+		return fmt.Errorf("天翼云盘登录失败: %s", submit.Msg)
+	}
+
+	sessionQuery := url.Values{
+		"redirectURL": {submit.ToURL},
+		"clientType":  {"TELEPC"},
+		"version":     {"6.2"},
+		"channelId":   {"web_cloud.189.cn"},
+		"rand":        {strconv.FormatInt(time.Now().UnixMilli(), 10)},
+	}
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, tianyiAPIURL+"/getSessionForPC.action?"+sessionQuery.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json;charset=UTF-8")
+
+	var session tianyiSession
+	if err := doJSON(d.client, req, &session); err != nil {
+		return fmt.Errorf("获取天翼云盘会话失败: %v", err)
+	}
+	if session.SessionKey == "" {
+		return errors.New("获取天翼云盘会话失败: 未返回sessionKey")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.session = &session
+	return nil
+}
+
+// 向统一认证平台提交表单
+func (d *TianyiDriver) loginForm(ctx context.Context, uri, lt, reqID string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tianyiAuthURL+uri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", tianyiAuthURL)
+	req.Header.Set("lt", lt)
+	req.Header.Set("reqId", reqID)
+	return doJSON(d.client, req, out)
+}
+
+// 天翼云盘业务错误
+type tianyiAPIError struct {
+	Code    string
+	Message string
+}
+
+func (e *tianyiAPIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// 将不存在类错误映射为ErrChunkNotFound
+func tianyiError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && strings.Contains(apiErr.Body, "FileNotFound") {
+		return fmt.Errorf("%w: %v", ErrChunkNotFound, err)
+	}
+	return err
+}
+
+// 会话失效（sessionKey过期或在其他设备登录）
+func tianyiSessionExpired(err error) bool {
+	if isUnauthorized(err) {
+		return true
+	}
+	var apiErr *tianyiAPIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.Code == "InvalidSessionKey" || apiErr.Code == "UserInvalidOpenToken")
+}
+
+// 以公钥RSA加密，输出大写十六进制
+func tianyiRSAEncrypt(pubKey, text string) (string, error) {
+	block, _ := pem.Decode([]byte("-----BEGIN PUBLIC KEY-----\n" + pubKey + "\n-----END PUBLIC KEY-----"))
+	if block == nil {
+		return "", errors.New("天翼云盘登录公钥格式错误")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("解析天翼云盘登录公钥失败: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return "", errors.New("天翼云盘登录公钥不是RSA公钥")
+	}
+
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, rsaKey, []byte(text))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(encrypted)), nil
+}
+
+// 上传接口参数：以sessionSecret前16字节为密钥AES-ECB加密（PKCS7填充），输出十六进制
+func tianyiEncryptParams(params url.Values, secret string) (string, error) {
+	if len(secret) < 16 {
+		return "", errors.New("天翼云盘会话密钥无效")
+	}
+	block, err := aes.NewCipher([]byte(secret[:16]))
+	if err != nil {
+		return "", err
+	}
+
+	plain := []byte(params.Encode())
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	plain = append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	encrypted := make([]byte, len(plain))
+	for i := 0; i < len(plain); i += aes.BlockSize {
+		block.Encrypt(encrypted[i:i+aes.BlockSize], plain[i:i+aes.BlockSize])
+	}
+	return hex.EncodeToString(encrypted), nil
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,181 @@
+package drivers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 所有HTTP驱动共享的连接池配置（config.yaml顶层的http段），零值使用默认值
+//
+//	http:
+//	  max_idle_conns_per_host: 32
+//	  dial_timeout: 10s
+//	  disable_http2: false
+//	  proxy: socks5://127.0.0.1:1080
+//
+// 驱动器配置中的proxy覆盖这里的默认代理，取值与这里相同
+type HTTPTransportConfig struct {
+	// 代理地址，支持 http://、https://、socks5://（可带用户名密码）；
+	// 为空时使用HTTP_PROXY等环境变量，direct表示不使用代理
+	Proxy string `yaml:"proxy"`
+
+	MaxIdleConns          int           `yaml:"max_idle_conns"`          // 默认256
+	MaxIdleConnsPerHost   int           `yaml:"max_idle_conns_per_host"` // 默认32，标准库默认值2在高延迟链路上会频繁重建连接
+	MaxConnsPerHost       int           `yaml:"max_conns_per_host"`      // 默认不限
+	IdleConnTimeout       time.Duration `yaml:"idle_conn_timeout"`       // 默认90s
+	DialTimeout           time.Duration `yaml:"dial_timeout"`            // 默认30s
+	KeepAlive             time.Duration `yaml:"keep_alive"`              // TCP keep-alive间隔，默认30s
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout"`   // 默认10s
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout"` // 默认不限（上传大块时服务端可能很晚才响应）
+	DisableHTTP2          bool          `yaml:"disable_http2"`
+	DisableKeepAlives     bool          `yaml:"disable_keep_alives"`
+}
+
+func (c HTTPTransportConfig) withDefaults() HTTPTransportConfig {
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = 256
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = 32
+	}
+	if c.IdleConnTimeout <= 0 {
+		c.IdleConnTimeout = 90 * time.Second
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = 30 * time.Second
+	}
+	if c.KeepAlive <= 0 {
+		c.KeepAlive = 30 * time.Second
+	}
+	if c.TLSHandshakeTimeout <= 0 {
+		c.TLSHandshakeTimeout = 10 * time.Second
+	}
+	return c
+}
+
+// 每个代理地址一个连接池，使用相同代理的驱动器共享连接
+var sharedTransport struct {
+	cfg        *HTTPTransportConfig
+	transports map[string]*http.Transport
+	mu         sync.Mutex
+}
+
+// 设置共享连接池，需在创建驱动器之前调用；之后创建的驱动器使用新的配置
+func ConfigureHTTPTransport(cfg HTTPTransportConfig) error {
+	cfg = cfg.withDefaults()
+	if _, err := proxyFunc(cfg.Proxy); err != nil {
+		return err
+	}
+
+	sharedTransport.mu.Lock()
+	defer sharedTransport.mu.Unlock()
+	sharedTransport.cfg = &cfg
+	sharedTransport.transports = nil
+	return nil
+}
+
+// 读取配置文件中的http段，没有该段时返回默认配置
+func LoadHTTPTransportConfig(path string) (HTTPTransportConfig, error) {
+	var file struct {
+		HTTP HTTPTransportConfig `yaml:"http"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file.HTTP, err
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file.HTTP, fmt.Errorf("解析http配置失败: %v", err)
+	}
+	return file.HTTP, nil
+}
+
+// 使用指定代理的共享Transport，proxy为空时使用http段的默认代理；未配置时使用默认配置
+func httpTransport(proxy string) (*http.Transport, error) {
+	sharedTransport.mu.Lock()
+	defer sharedTransport.mu.Unlock()
+
+	if sharedTransport.cfg == nil {
+		cfg := HTTPTransportConfig{}.withDefaults()
+		sharedTransport.cfg = &cfg
+	}
+	if proxy == "" {
+		proxy = sharedTransport.cfg.Proxy
+	}
+	if transport, ok := sharedTransport.transports[proxy]; ok {
+		return transport, nil
+	}
+
+	proxyURL, err := proxyFunc(proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport := newHTTPTransport(*sharedTransport.cfg, proxyURL)
+	if sharedTransport.transports == nil {
+		sharedTransport.transports = make(map[string]*http.Transport)
+	}
+	sharedTransport.transports[proxy] = transport
+	return transport, nil
+}
+
+// 使用共享连接池的客户端，timeout为单个请求（含读取响应体）的总超时
+func newHTTPClient(timeout time.Duration, proxy string) (*http.Client, error) {
+	transport, err := httpTransport(proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// 解析代理地址
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	switch proxy {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "direct":
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址无效 %q: %v", proxy, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议 %q（支持 http、https、socks5）", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("代理地址缺少主机: %q", proxy)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+func newHTTPTransport(cfg HTTPTransportConfig, proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: cfg.KeepAlive}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           throttledDial(dialer.DialContext),
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
+	}
+	if cfg.DisableHTTP2 {
+		// 非nil的空映射关闭HTTP/2协商
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return transport
+}
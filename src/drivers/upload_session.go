@@ -0,0 +1,206 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 分片上传会话。可以序列化保存，连接中断后从未完成的分片继续，而不是重新上传整个数据块
+type UploadSession struct {
+	StorageID string            `json:"storage_id"`
+	UploadID  string            `json:"upload_id"` // 驱动器侧的会话标识（上传地址、uploadId等）
+	Size      int64             `json:"size"`
+	PartSize  int64             `json:"part_size"`       // 由驱动器决定，最后一个分片可以更小
+	Completed []int             `json:"completed"`       // 已确认上传的分片序号（递增）
+	State     map[string]string `json:"state,omitempty"` // 驱动私有状态，如各分片的ETag/SHA1
+	StartedAt time.Time         `json:"started_at"`
+
+	mu sync.Mutex // 并行上传分片时保护Completed和State
+}
+
+// 包装器所包装的驱动器不支持会话时由StartUploadSession返回，调用方应改用UploadChunk
+var ErrSessionUnsupported = errors.New("驱动器不支持分片上传会话")
+
+// 支持分片上传会话的驱动器
+type SessionUploader interface {
+	// 创建会话，返回的会话中PartSize必须大于0
+	StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error)
+	// 上传第partIndex个分片（从0开始），成功后驱动器可在session.State中记录所需状态
+	UploadPart(ctx context.Context, session *UploadSession, partIndex int, data []byte) error
+	// 所有分片上传后提交会话，返回驱动器的存储ID
+	CompleteSession(ctx context.Context, session *UploadSession) (string, error)
+}
+
+// 分片之间没有顺序要求、可以并行上传的驱动器（如S3的UploadPart）。
+// 并行上传时UploadPart会被并发调用，必须通过SetState/GetState访问会话状态
+type ParallelPartUploader interface {
+	// 同时上传的最大分片数，<=1表示按顺序上传
+	MaxParallelParts() int
+}
+
+// 支持放弃会话并释放已上传分片的驱动器
+type SessionAborter interface {
+	AbortSession(ctx context.Context, session *UploadSession) error
+}
+
+// 分片总数
+func (s *UploadSession) PartCount() int {
+	if s.PartSize <= 0 {
+		return 0
+	}
+	return int((s.Size + s.PartSize - 1) / s.PartSize)
+}
+
+// 分片在数据中的范围
+func (s *UploadSession) PartRange(partIndex int) (int64, int64) {
+	start := int64(partIndex) * s.PartSize
+	return start, min(start+s.PartSize, s.Size)
+}
+
+// 记录驱动私有状态，可并发调用
+func (s *UploadSession) SetState(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.State == nil {
+		s.State = make(map[string]string)
+	}
+	s.State[key] = value
+}
+
+func (s *UploadSession) GetState(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.State[key]
+	return value, ok
+}
+
+// 会话当前状态的副本，用于保存会话。并行上传时只能在onPart中调用（调用时已持有会话锁）
+func (s *UploadSession) Snapshot() *UploadSession {
+	copied := &UploadSession{
+		StorageID: s.StorageID,
+		UploadID:  s.UploadID,
+		Size:      s.Size,
+		PartSize:  s.PartSize,
+		Completed: append([]int(nil), s.Completed...),
+		StartedAt: s.StartedAt,
+	}
+	if len(s.State) > 0 {
+		copied.State = make(map[string]string, len(s.State))
+		for k, v := range s.State {
+			copied.State[k] = v
+		}
+	}
+	return copied
+}
+
+func (s *UploadSession) IsPartCompleted(partIndex int) bool {
+	i := sort.SearchInts(s.Completed, partIndex)
+	return i < len(s.Completed) && s.Completed[i] == partIndex
+}
+
+func (s *UploadSession) markCompleted(partIndex int) {
+	if s.IsPartCompleted(partIndex) {
+		return
+	}
+	s.Completed = append(s.Completed, partIndex)
+	sort.Ints(s.Completed)
+}
+
+// 单个分片的最大重试次数
+const sessionPartRetries = 3
+
+// 按会话上传所有未完成的分片并提交。驱动器实现ParallelPartUploader时并行上传，否则按顺序上传；
+// 单个分片失败时只重试该分片。onPart在每个分片确认后调用（持有会话锁，调用之间互斥），
+// 可用于持久化会话以便进程重启后续传
+func UploadWithSession(ctx context.Context, uploader SessionUploader, session *UploadSession, data []byte, onPart func(*UploadSession)) (string, error) {
+	if int64(len(data)) != session.Size {
+		return "", fmt.Errorf("会话大小不匹配: 期望%d, 实际%d", session.Size, len(data))
+	}
+
+	var pending []int
+	for i := 0; i < session.PartCount(); i++ {
+		if !session.IsPartCompleted(i) {
+			pending = append(pending, i)
+		}
+	}
+
+	parallel := 1
+	if p, ok := uploader.(ParallelPartUploader); ok {
+		parallel = max(1, min(p.MaxParallelParts(), len(pending)))
+	}
+
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	next := make(chan int)
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				start, end := session.PartRange(i)
+				if err := uploadPart(partCtx, uploader, session, i, data[start:end]); err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("分片%d上传失败: %v", i, err)
+						cancel()
+					})
+					continue
+				}
+
+				session.mu.Lock()
+				session.markCompleted(i)
+				if onPart != nil {
+					onPart(session)
+				}
+				session.mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, i := range pending {
+		select {
+		case next <- i:
+		case <-partCtx.Done():
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return uploader.CompleteSession(ctx, session)
+}
+
+// 上传单个分片，失败时退避重试
+func uploadPart(ctx context.Context, uploader SessionUploader, session *UploadSession, partIndex int, data []byte) error {
+	var err error
+	for attempt := 0; attempt < sessionPartRetries; attempt++ {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err = uploader.UploadPart(ctx, session, partIndex, data); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package drivers
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// 包装另一个驱动器的驱动器（限速、重试等），Unwrap返回被包装的驱动器
+type Wrapper interface {
+	Unwrap() StorageDriver
+}
+
+// 返回最内层的驱动器
+func Unwrap(driver StorageDriver) StorageDriver {
+	for {
+		w, ok := driver.(Wrapper)
+		if !ok {
+			return driver
+		}
+		driver = w.Unwrap()
+	}
+}
+
+// 包装器的公共部分：原样转发所有操作，包括可选接口。
+// 具体包装器内嵌它并只覆盖需要拦截的方法；被包装的驱动器未实现的可选接口
+// 通过ErrDeleteUnsupported等错误告知调用方，Capabilities报告被包装驱动器的真实能力
+type baseWrapper struct {
+	inner StorageDriver
+}
+
+func (w baseWrapper) Unwrap() StorageDriver { return w.inner }
+
+func (w baseWrapper) Connect() error                  { return w.inner.Connect() }
+func (w baseWrapper) IsAvailable() bool               { return w.inner.IsAvailable() }
+func (w baseWrapper) GetUsage() (int64, int64, error) { return w.inner.GetUsage() }
+
+func (w baseWrapper) UploadChunk(ctx context.Context, data []byte, storageID string) (string, error) {
+	return w.inner.UploadChunk(ctx, data, storageID)
+}
+
+func (w baseWrapper) DownloadChunk(ctx context.Context, storageID string) ([]byte, error) {
+	return w.inner.DownloadChunk(ctx, storageID)
+}
+
+func (w baseWrapper) Ping(ctx context.Context) (time.Duration, error) { return Ping(ctx, w.inner) }
+
+func (w baseWrapper) Capabilities() Capabilities { return CapabilitiesOf(w.inner) }
+
+func (w baseWrapper) MaxChunkSize() int64 { return MaxChunkSizeOf(w.inner) }
+
+func (w baseWrapper) Obfuscation() string { return ObfuscationOf(w.inner) }
+
+func (w baseWrapper) DownloadRange(ctx context.Context, storageID string, offset, length int64) ([]byte, error) {
+	return DownloadRange(ctx, w.inner, storageID, offset, length)
+}
+
+func (w baseWrapper) RenameChunk(ctx context.Context, storageID, newStorageID string) error {
+	return RenameChunk(ctx, w.inner, storageID, newStorageID)
+}
+
+func (w baseWrapper) RestoreChunk(ctx context.Context, storageID string) error {
+	return RestoreChunk(ctx, w.inner, storageID)
+}
+
+func (w baseWrapper) DeleteChunk(ctx context.Context, storageID string) error {
+	return DeleteChunk(ctx, w.inner, storageID)
+}
+
+func (w baseWrapper) ListChunks(ctx context.Context, prefix string) ([]ChunkInfo, error) {
+	return ListChunks(ctx, w.inner, prefix)
+}
+
+func (w baseWrapper) StartUploadSession(ctx context.Context, storageID string, size int64) (*UploadSession, error) {
+	uploader, ok := w.inner.(SessionUploader)
+	if !ok {
+		return nil, ErrSessionUnsupported
+	}
+	return uploader.StartUploadSession(ctx, storageID, size)
+}
+
+func (w baseWrapper) UploadPart(ctx context.Context, session *UploadSession, partIndex int, data []byte) error {
+	uploader, ok := w.inner.(SessionUploader)
+	if !ok {
+		return ErrSessionUnsupported
+	}
+	return uploader.UploadPart(ctx, session, partIndex, data)
+}
+
+func (w baseWrapper) CompleteSession(ctx context.Context, session *UploadSession) (string, error) {
+	uploader, ok := w.inner.(SessionUploader)
+	if !ok {
+		return "", ErrSessionUnsupported
+	}
+	return uploader.CompleteSession(ctx, session)
+}
+
+func (w baseWrapper) MaxParallelParts() int {
+	if p, ok := w.inner.(ParallelPartUploader); ok {
+		return p.MaxParallelParts()
+	}
+	return 1
+}
+
+func (w baseWrapper) AbortSession(ctx context.Context, session *UploadSession) error {
+	if aborter, ok := w.inner.(SessionAborter); ok {
+		return aborter.AbortSession(ctx, session)
+	}
+	return nil
+}
+
+func (w baseWrapper) Login(ctx context.Context, out io.Writer) error {
+	return Login(ctx, w.inner, out)
+}
+
+func (w baseWrapper) Close() error {
+	if closer, ok := w.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"panmatrix/metadata"
+	"panmatrix/raid"
+)
+
+// 按需读取时每次至少读取这么多，顺序读取时不必为每个小块下载一次条带
+const fileReadAhead = 4 << 20
+
+// 按虚拟路径读写一个命名空间中的文件，供挂载和WebDAV等不经过上传下载命令的访问方式使用
+type gateway struct {
+	e         *env
+	ns        *metadata.Namespace
+	raidLevel int // 写入的RAID级别，与阵列初始化时一致
+}
+
+// 将data保存到虚拟路径p，已有同名文件时成为它的新版本
+func (g *gateway) store(ctx context.Context, p string, data []byte, mode os.FileMode) (*metadata.FileMetadata, error) {
+	admitted, err := g.admitUpload(ctx, p, int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	defer admitted()
+	return g.storeAdmitted(ctx, p, data, mode)
+}
+
+// 为向虚拟路径p写入size字节取得调度器的准入，失败时记录审计日志。
+// 在接收内容之前调用时，排队的数据过多会先等待而不是先把内容读入内存
+func (g *gateway) admitUpload(ctx context.Context, p string, size int64) (func(), error) {
+	admitted, err := g.e.rs.Admit(ctx, size)
+	if err != nil {
+		recordAudit(g.e.mm.Audit(), g.uploadAudit(p, size), err)
+		return nil, err
+	}
+	return admitted, nil
+}
+
+// 与store相同，调用方已用admitUpload取得准入
+func (g *gateway) storeAdmitted(ctx context.Context, p string, data []byte, mode os.FileMode) (*metadata.FileMetadata, error) {
+	audit := g.uploadAudit(p, int64(len(data)))
+	upload := fileUpload{
+		Name:    path.Base(p),
+		Dir:     path.Dir(p),
+		Data:    data,
+		Hash:    raid.ContentHash(data),
+		ModTime: time.Now(),
+		Mode:    mode,
+	}
+	fm, err := storeFile(ctx, g.e.rc, g.e.mm, g.ns, g.e.rs, upload, g.raidLevel, uploadHooks{})
+	if fm != nil {
+		audit.FileID = fm.FileID
+	}
+	recordAudit(g.e.mm.Audit(), audit, err)
+	return fm, err
+}
+
+func (g *gateway) uploadAudit(p string, size int64) metadata.AuditEntry {
+	return metadata.AuditEntry{User: g.ns.Owner(), Op: metadata.AuditUpload, Target: p, Bytes: size}
+}
+
+// 读取已提交文件的全部内容并与记录的哈希比较
+func (g *gateway) readFile(ctx context.Context, meta *metadata.FileMetadata) ([]byte, error) {
+	admitted, err := g.e.rs.Admit(ctx, meta.FileSize)
+	if err != nil {
+		return nil, err
+	}
+	defer admitted()
+	g.e.rc.LoadLayout(meta.FileID, meta.Stripes)
+	return g.e.rc.ReadFileVerified(ctx, meta.FileID, meta.Hash)
+}
+
+// 将已提交的文件复制到虚拟路径to。元数据没有移动操作，重命名也通过复制实现
+func (g *gateway) copyFile(ctx context.Context, meta *metadata.FileMetadata, to string) error {
+	data, err := g.readFile(ctx, meta)
+	if err != nil {
+		return err
+	}
+	_, err = g.store(ctx, to, data, meta.Mode.Perm())
+	return err
+}
+
+// 删除文件，启用回收站时移入回收站
+func (g *gateway) remove(ctx context.Context, meta *metadata.FileMetadata) error {
+	_, _, err := deleteFile(ctx, g.e.rc, g.e.mm, g.ns, meta.FileID, nil)
+	return err
+}
+
+func (g *gateway) openReader(meta *metadata.FileMetadata) *fileReader {
+	g.e.rc.LoadLayout(meta.FileID, meta.Stripes)
+	return &fileReader{g: g, meta: meta}
+}
+
+// 按需读取已提交的文件，只下载涉及的条带并缓存最近读取的一段。
+// 读取的是部分内容，不与文件的哈希比较
+type fileReader struct {
+	g    *gateway
+	meta *metadata.FileMetadata
+
+	mu    sync.Mutex
+	start int64 // buf在文件中的位置
+	buf   []byte
+	read  int64 // 从阵列读取的字节数，用于审计
+}
+
+// 与io.ReaderAt相同，读到文件末尾时返回io.EOF
+func (r *fileReader) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := r.meta.FileSize
+	if off >= size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), size)
+	if off < r.start || end > r.start+int64(len(r.buf)) {
+		length := max(end-off, fileReadAhead)
+		admitted, err := r.g.e.rs.Admit(ctx, length)
+		if err != nil {
+			return 0, err
+		}
+		data, err := r.g.e.rc.ReadRange(ctx, r.meta.FileID, off, length)
+		admitted()
+		if err != nil {
+			return 0, err
+		}
+		r.start, r.buf = off, data
+		r.read += int64(len(data))
+	}
+
+	lo, hi := off-r.start, min(end-r.start, int64(len(r.buf)))
+	n := copy(p, r.buf[lo:hi])
+	switch {
+	case off+int64(n) >= size:
+		return n, io.EOF
+	case n < len(p):
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, nil
+}
+
+// 绑定ctx的io.ReaderAt，如配合io.NewSectionReader交给http.ServeContent处理Range请求
+func (r *fileReader) readerAt(ctx context.Context) io.ReaderAt {
+	return fileReaderAt{ctx: ctx, r: r}
+}
+
+type fileReaderAt struct {
+	ctx context.Context
+	r   *fileReader
+}
+
+func (a fileReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return a.r.readAt(a.ctx, p, off)
+}
+
+// 结束读取，读取过内容时记录下载的审计日志
+func (r *fileReader) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.read > 0 {
+		recordAudit(r.g.e.mm.Audit(), metadata.AuditEntry{User: r.g.ns.Owner(), Op: metadata.AuditDownload, Target: r.meta.Path(), FileID: r.meta.FileID, Bytes: r.read}, nil)
+	}
+	r.buf, r.read = nil, 0
+}
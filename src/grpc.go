@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"panmatrix/api"
+	"panmatrix/api/apipb"
+	"panmatrix/jobs"
+	"panmatrix/metadata"
+	"panmatrix/raid"
+	"panmatrix/scheduler"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 任务记录变化的检查间隔，与任务进度写入的频率一致
+const watchJobInterval = time.Second
+
+// gRPC接口的实现，见 api/apipb/panmatrix.proto。Web界面的JSON接口也调用这些方法
+type grpcService struct {
+	apipb.UnimplementedPanMatrixServer
+	e         *env
+	raidLevel int // 上传的RAID级别，与阵列初始化时一致
+}
+
+// 配置了api.listen时在后台提供gRPC接口，配置了api.web_listen、api.webdav_listen和api.s3_listen时
+// 提供Web界面、WebDAV服务和S3兼容服务。
+// 返回的函数停止服务并中断进行中的请求
+func (e *env) serveAPI(raidLevel int) (func(), error) {
+	cfg, err := api.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	service := &grpcService{e: e, raidLevel: raidLevel}
+	stopWeb, err := e.serveWeb(cfg, service)
+	if err != nil {
+		return nil, err
+	}
+	stopWebDAV, err := e.serveWebDAV(cfg, raidLevel)
+	if err != nil {
+		stopWeb()
+		return nil, err
+	}
+	stopS3, err := e.serveS3(cfg, raidLevel)
+	if err != nil {
+		stopWeb()
+		stopWebDAV()
+		return nil, err
+	}
+	stopHTTP := func() {
+		stopWeb()
+		stopWebDAV()
+		stopS3()
+	}
+	server, listener, err := api.Listen(cfg, service)
+	if err != nil {
+		stopHTTP()
+		return nil, err
+	}
+	if server == nil {
+		return stopHTTP, nil
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Printf("警告: gRPC接口已停止: %v", err)
+		}
+	}()
+	fmt.Printf("gRPC接口监听 %s\n", cfg.Listen)
+	return func() {
+		server.Stop()
+		stopHTTP()
+	}, nil
+}
+
+// 请求指定了用户时只操作该用户的文件，否则使用启动时的用户
+func (s *grpcService) namespace(ctx context.Context) *metadata.Namespace {
+	if user, ok := api.User(ctx); ok && user != "" {
+		return s.e.mm.Namespace(user)
+	}
+	return s.e.ns
+}
+
+func (s *grpcService) Upload(stream apipb.PanMatrix_UploadServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	header := first.GetHeader()
+	if header == nil {
+		return status.Error(codes.InvalidArgument, "第一条消息必须是header")
+	}
+	resp, err := s.upload(stream.Context(), header, &uploadReader{stream: stream})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(resp)
+}
+
+// 将上传流中header之后的内容作为io.Reader，结束时返回io.EOF
+type uploadReader struct {
+	stream apipb.PanMatrix_UploadServer
+	buf    []byte
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = req.GetData()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// 从r读取header.Size字节的内容并保存，gRPC和Web界面的上传共用
+func (s *grpcService) upload(ctx context.Context, header *apipb.UploadHeader, r io.Reader) (resp *apipb.UploadResponse, err error) {
+	ns := s.namespace(ctx)
+	name := header.GetName()
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return nil, status.Errorf(codes.InvalidArgument, "无效的文件名: %q", name)
+	}
+	if header.GetSize() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "无效的文件大小: %d", header.GetSize())
+	}
+
+	target := path.Join(metadata.CleanPath(header.GetDir()), name)
+	audit := metadata.AuditEntry{User: ns.Owner(), Op: metadata.AuditUpload, Target: target, Bytes: header.GetSize()}
+	defer func() { recordAudit(s.e.mm.Audit(), audit, err) }()
+
+	if ns.DirExists(target) {
+		return nil, status.Errorf(codes.AlreadyExists, "已存在同名目录: %s", target)
+	}
+	// 与命令行上传一样，排队的数据过多时等待
+	admitted, err := s.e.rs.Admit(ctx, header.GetSize())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	defer admitted()
+
+	data := make([]byte, header.GetSize())
+	if n, err := io.ReadFull(r, data); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, status.Errorf(codes.InvalidArgument, "收到 %d 字节，header中的大小为 %d", n, header.GetSize())
+		}
+		return nil, err
+	}
+	var extra [1]byte
+	if n, err := r.Read(extra[:]); n > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "内容超过header中的大小 %d", header.GetSize())
+	} else if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+
+	upload := fileUpload{
+		Name:    name,
+		Dir:     header.GetDir(),
+		Tags:    header.GetTags(),
+		Data:    data,
+		Hash:    hex.EncodeToString(hash[:]),
+		ModTime: fromUnixNano(header.GetModTime()),
+		Mode:    os.FileMode(header.GetMode()).Perm(),
+	}
+	var plan *scheduler.PlacementPlan
+	fm, err := storeFile(ctx, s.e.rc, s.e.mm, ns, s.e.rs, upload, s.raidLevel, uploadHooks{Planned: func(p *scheduler.PlacementPlan) { plan = p }})
+	if fm != nil {
+		audit.FileID = fm.FileID
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &apipb.UploadResponse{File: fileInfo(fm), Warnings: plan.Warnings, MonthlyCost: plan.MonthlyCost}, nil
+}
+
+func (s *grpcService) Download(req *apipb.DownloadRequest, stream apipb.PanMatrix_DownloadServer) error {
+	meta, data, err := s.download(stream.Context(), req.GetFile())
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&apipb.DownloadResponse{Message: &apipb.DownloadResponse_File{File: fileInfo(meta)}}); err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		n := min(len(data), api.ChunkSize)
+		if err := stream.Send(&apipb.DownloadResponse{Message: &apipb.DownloadResponse_Data{Data: data[:n]}}); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// 读取并校验整个文件，gRPC和Web界面的下载共用
+func (s *grpcService) download(ctx context.Context, ref string) (meta *metadata.FileMetadata, data []byte, err error) {
+	ns := s.namespace(ctx)
+	audit := metadata.AuditEntry{User: ns.Owner(), Op: metadata.AuditDownload, Target: ref}
+	defer func() { recordAudit(s.e.mm.Audit(), audit, err) }()
+
+	meta, err = findFile(ns, ref)
+	if err != nil {
+		return nil, nil, grpcError(err)
+	}
+	s.e.rc.LoadLayout(meta.FileID, meta.Stripes)
+	audit.Target, audit.FileID = meta.Path(), meta.FileID
+
+	admitted, err := s.e.rs.Admit(ctx, meta.FileSize)
+	if err != nil {
+		return nil, nil, grpcError(err)
+	}
+	defer admitted()
+	data, err = s.e.rc.ReadFileVerified(ctx, meta.FileID, meta.Hash)
+	if err != nil {
+		return nil, nil, grpcError(err)
+	}
+	audit.Bytes = int64(len(data))
+	return meta, data, nil
+}
+
+func (s *grpcService) Stat(ctx context.Context, req *apipb.StatRequest) (*apipb.FileInfo, error) {
+	meta, err := findFile(s.namespace(ctx), req.GetFile())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return fileInfo(meta), nil
+}
+
+func (s *grpcService) List(ctx context.Context, req *apipb.ListRequest) (*apipb.ListResponse, error) {
+	ns := s.namespace(ctx)
+	opts := metadata.ListOptions{
+		Dir:       req.GetDir(),
+		Recursive: req.GetRecursive(),
+		Pattern:   req.GetPattern(),
+		SortBy:    req.GetSortBy(),
+		Desc:      req.GetDesc(),
+		Offset:    int(req.GetOffset()),
+		Limit:     int(req.GetLimit()),
+	}
+	for _, level := range req.GetRaidLevels() {
+		opts.RAIDLevels = append(opts.RAIDLevels, int(level))
+	}
+	result, err := ns.ListFiles(opts)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := listResponse(result)
+	// 与命令行一样，子目录只在第一页返回
+	if opts.Dir != "" && opts.Offset == 0 {
+		entries, err := ns.ListDir(opts.Dir)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir {
+				resp.Dirs = append(resp.Dirs, entry.Path)
+			}
+		}
+	}
+	return resp, nil
+}
+
+func (s *grpcService) Search(ctx context.Context, req *apipb.SearchRequest) (*apipb.ListResponse, error) {
+	q, err := metadata.ParseSearchQuery(req.GetQuery())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	q.SortBy, q.Desc, q.Offset, q.Limit = req.GetSortBy(), req.GetDesc(), int(req.GetOffset()), int(req.GetLimit())
+	result, err := s.namespace(ctx).Search(q)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return listResponse(result), nil
+}
+
+func (s *grpcService) Versions(ctx context.Context, req *apipb.VersionsRequest) (*apipb.VersionsResponse, error) {
+	versions, err := s.namespace(ctx).ListVersions(req.GetPath())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &apipb.VersionsResponse{Versions: make([]*apipb.FileInfo, 0, len(versions))}
+	for _, v := range versions {
+		resp.Versions = append(resp.Versions, fileInfo(v))
+	}
+	return resp, nil
+}
+
+func (s *grpcService) Delete(ctx context.Context, req *apipb.DeleteRequest) (*apipb.DeleteResponse, error) {
+	meta, report, err := deleteFile(ctx, s.e.rc, s.e.mm, s.namespace(ctx), req.GetFile(), nil)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &apipb.DeleteResponse{File: fileInfo(meta), Trashed: report == nil}, nil
+}
+
+func (s *grpcService) Mkdir(ctx context.Context, req *apipb.MkdirRequest) (*apipb.MkdirResponse, error) {
+	if err := s.namespace(ctx).Mkdir(req.GetPath(), true); err != nil {
+		return nil, grpcError(err)
+	}
+	return &apipb.MkdirResponse{}, nil
+}
+
+func (s *grpcService) Drivers(ctx context.Context, _ *apipb.DriversRequest) (*apipb.DriversResponse, error) {
+	states := driverStates(s.e.rs)
+	names := make([]string, 0, len(s.e.storageDrivers))
+	for name := range s.e.storageDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make(map[string]scheduler.DriverMetrics)
+	for _, m := range s.e.rs.Metrics() {
+		metrics[m.Name] = m
+	}
+
+	resp := &apipb.DriversResponse{Drivers: make([]*apipb.DriverStatus, 0, len(names))}
+	for _, name := range names {
+		m := metrics[name]
+		d := &apipb.DriverStatus{
+			Name:            name,
+			Type:            s.e.driverTypes[name],
+			State:           states[name],
+			SuccessRate:     m.Operations.SuccessRate,
+			LatencyP50:      int64(m.Operations.P50),
+			LatencyP95:      int64(m.Operations.P95),
+			CurrentLoad:     int32(m.CurrentLoad),
+			MaxLoad:         int32(m.MaxLoad),
+			UploadBandwidth: m.UploadBandwidth,
+			LastError:       unixNano(m.LastErrorTime),
+		}
+		if used, total, err := s.e.storageDrivers[name].GetUsage(); err != nil {
+			d.Error = err.Error()
+		} else {
+			d.Used, d.Total = used, total
+		}
+		resp.Drivers = append(resp.Drivers, d)
+	}
+	return resp, nil
+}
+
+func (s *grpcService) SubmitJob(ctx context.Context, req *apipb.SubmitJobRequest) (*apipb.Job, error) {
+	params := make(map[string]string, len(req.GetParams())+1)
+	for k, v := range req.GetParams() {
+		params[k] = v
+	}
+	if req.GetDeadline() > 0 {
+		params["deadline"] = fromUnixNano(req.GetDeadline()).Format(time.RFC3339)
+	}
+	job, err := s.e.jobs.Submit(req.GetType(), params)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return jobInfo(job), nil
+}
+
+func (s *grpcService) GetJob(ctx context.Context, req *apipb.GetJobRequest) (*apipb.Job, error) {
+	job, err := s.e.jobs.Get(req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return jobInfo(job), nil
+}
+
+func (s *grpcService) ListJobs(ctx context.Context, _ *apipb.ListJobsRequest) (*apipb.ListJobsResponse, error) {
+	list := s.e.jobs.List()
+	resp := &apipb.ListJobsResponse{Jobs: make([]*apipb.Job, 0, len(list))}
+	for _, job := range list {
+		resp.Jobs = append(resp.Jobs, jobInfo(job))
+	}
+	return resp, nil
+}
+
+func (s *grpcService) CancelJob(ctx context.Context, req *apipb.JobControlRequest) (*apipb.Job, error) {
+	return s.controlJob(req.GetId(), s.e.jobs.Cancel)
+}
+
+func (s *grpcService) PauseJob(ctx context.Context, req *apipb.JobControlRequest) (*apipb.Job, error) {
+	return s.controlJob(req.GetId(), s.e.jobs.Pause)
+}
+
+func (s *grpcService) ResumeJob(ctx context.Context, req *apipb.JobControlRequest) (*apipb.Job, error) {
+	return s.controlJob(req.GetId(), s.e.jobs.Resume)
+}
+
+// 执行控制操作并返回之后的任务记录
+func (s *grpcService) controlJob(id string, control func(string) error) (*apipb.Job, error) {
+	if err := control(id); err != nil {
+		return nil, grpcError(err)
+	}
+	job, err := s.e.jobs.Get(id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return jobInfo(job), nil
+}
+
+func (s *grpcService) WatchJob(req *apipb.GetJobRequest, stream apipb.PanMatrix_WatchJobServer) error {
+	ticker := time.NewTicker(watchJobInterval)
+	defer ticker.Stop()
+
+	var last jobs.Job
+	for {
+		job, err := s.e.jobs.Get(req.GetId())
+		if err != nil {
+			return grpcError(err)
+		}
+		if job.State != last.State || job.Progress != last.Progress || !job.Updated.Equal(last.Updated) {
+			if err := stream.Send(jobInfo(job)); err != nil {
+				return err
+			}
+			last = job
+		}
+		if job.State.Finished() {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// 将错误映射为gRPC状态码，已经是状态的错误原样返回
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Unknown
+	switch {
+	case errors.Is(err, metadata.ErrNotFound), errors.Is(err, jobs.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, metadata.ErrFileNotCommitted), errors.Is(err, metadata.ErrDirNotEmpty), errors.Is(err, jobs.ErrFinished):
+		code = codes.FailedPrecondition
+	case errors.Is(err, jobs.ErrUnknownType):
+		code = codes.InvalidArgument
+	case errors.Is(err, metadata.ErrDirShared):
+		code = codes.PermissionDenied
+	case errors.Is(err, metadata.ErrDirsUnsupported):
+		code = codes.Unimplemented
+	case errors.Is(err, metadata.ErrQuotaExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, scheduler.ErrBusy):
+		code = codes.Unavailable
+	case errors.Is(err, raid.ErrIntegrity):
+		code = codes.DataLoss
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, err.Error())
+}
+
+func fileInfo(fm *metadata.FileMetadata) *apipb.FileInfo {
+	return &apipb.FileInfo{
+		FileId:     fm.FileID,
+		Path:       fm.Path(),
+		Name:       fm.FileName,
+		Dir:        fm.Dir,
+		Size:       fm.FileSize,
+		RaidLevel:  int32(fm.RAIDLevel),
+		Tags:       fm.Tags,
+		Owner:      fm.Owner,
+		Hash:       fm.Hash,
+		MimeType:   fm.MimeType,
+		ModTime:    unixNano(fm.ModTime),
+		Mode:       uint32(fm.Mode.Perm()),
+		Created:    unixNano(fm.CreatedAt),
+		Updated:    unixNano(fm.UpdatedAt),
+		Version:    int32(fm.Version),
+		Superseded: unixNano(fm.SupersededAt),
+		State:      fm.State,
+	}
+}
+
+func listResponse(result *metadata.ListResult) *apipb.ListResponse {
+	resp := &apipb.ListResponse{
+		Files:      make([]*apipb.FileInfo, 0, len(result.Files)),
+		Total:      int32(result.Total),
+		NextOffset: int32(result.NextOffset()),
+	}
+	for _, fm := range result.Files {
+		resp.Files = append(resp.Files, fileInfo(fm))
+	}
+	return resp
+}
+
+func jobInfo(job jobs.Job) *apipb.Job {
+	return &apipb.Job{
+		Id:       job.ID,
+		Type:     job.Type,
+		Params:   job.Params,
+		State:    string(job.State),
+		Done:     job.Progress.Done,
+		Total:    job.Progress.Total,
+		Message:  job.Progress.Message,
+		Result:   job.Result,
+		Error:    job.Error,
+		Created:  unixNano(job.Created),
+		Started:  unixNano(job.Started),
+		Finished: unixNano(job.Finished),
+		Updated:  unixNano(job.Updated),
+	}
+}
+
+// 零值时间为0
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// 任务状态
+type State string
+
+const (
+	StateQueued    State = "queued"    // 等待执行（包括上次运行中断的任务）
+	StateRunning   State = "running"   // 执行中
+	StatePaused    State = "paused"    // 已暂停，继续后从暂停处接着执行
+	StateSucceeded State = "succeeded" // 已完成
+	StateFailed    State = "failed"    // 出错结束
+	StateCanceled  State = "canceled"  // 已取消
+)
+
+// 任务是否已经结束
+func (s State) Finished() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCanceled
+}
+
+var (
+	ErrNotFound    = errors.New("任务不存在")
+	ErrFinished    = errors.New("任务已结束")
+	ErrUnknownType = errors.New("未知的任务类型")
+)
+
+// 任务进度。Total为0表示总量未知
+type Progress struct {
+	Done    int64  `json:"done"`
+	Total   int64  `json:"total"`
+	Message string `json:"message,omitempty"`
+}
+
+// 任务记录
+type Job struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type"`
+	Params   map[string]string `json:"params,omitempty"`
+	State    State             `json:"state"`
+	Progress Progress          `json:"progress"`
+	Result   string            `json:"result,omitempty"` // 完成时的结果摘要
+	Error    string            `json:"error,omitempty"`
+	Owner    int               `json:"owner,omitempty"` // 执行任务的进程号
+
+	Created  time.Time `json:"created"`
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+	Updated  time.Time `json:"updated"` // 执行中的任务定期更新，超过staleAfter未更新视为进程已退出
+}
+
+// 任务的执行函数。返回的error为nil时任务完成，ctx取消时应尽快返回
+type RunFunc func(ctx context.Context, h *Handle) error
+
+// 执行中的任务，供执行函数报告进度和响应暂停
+type Handle struct {
+	m   *Manager
+	run *runningJob
+}
+
+func (h *Handle) ID() string {
+	return h.run.id
+}
+
+// 任务参数
+func (h *Handle) Params() map[string]string {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	return h.run.job.Params
+}
+
+// 更新进度，写入任务记录的频率有限制
+func (h *Handle) SetProgress(done, total int64, message string) {
+	h.m.mu.Lock()
+	h.run.job.Progress = Progress{Done: done, Total: total, Message: message}
+	h.m.mu.Unlock()
+	h.m.persist(h.run, false)
+}
+
+// 设置完成时的结果摘要
+func (h *Handle) SetResult(result string) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.run.job.Result = result
+}
+
+// 任务被暂停时等待到继续，ctx取消时返回ctx的错误。执行函数应在每个处理单元之间调用
+func (h *Handle) Checkpoint(ctx context.Context) error {
+	return Checkpoint(ctx)
+}
+
+type gateKey struct{}
+
+// 暂停控制，resumed不为nil时任务处于暂停状态，继续时关闭
+type gate struct {
+	mu      sync.Mutex
+	resumed chan struct{}
+}
+
+func (g *gate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		g.resumed = make(chan struct{})
+	}
+}
+
+func (g *gate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+}
+
+// ctx属于一个已暂停的任务时等待到任务继续，ctx取消时返回ctx的错误；不属于任务时立即返回。
+// 调度器在每次传输前调用，暂停的任务不再取得新的传输槽位，已经开始的传输不受影响
+func Checkpoint(ctx context.Context) error {
+	g, ok := ctx.Value(gateKey{}).(*gate)
+	if !ok {
+		return ctx.Err()
+	}
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-resumed:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,587 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 执行中的任务更新记录的间隔
+	heartbeatInterval = 5 * time.Second
+
+	// 执行中的任务超过该时间未更新，视为执行它的进程已退出
+	staleAfter = 30 * time.Second
+
+	// 检查其他进程发来的控制请求的间隔
+	controlInterval = time.Second
+
+	// 进度写入记录的最小间隔
+	progressInterval = time.Second
+
+	// 没有排队的任务时工作协程重新检查的间隔（其他进程可能提交了任务）
+	idlePoll = 5 * time.Second
+)
+
+// 控制请求。任务由其他进程执行时写入<id>.control，由执行它的进程读取
+const (
+	controlCancel = "cancel"
+	controlPause  = "pause"
+	controlResume = "resume"
+)
+
+// 任务已被其他工作协程或进程领取
+var errClaimed = errors.New("任务已在执行")
+
+// 后台任务管理器。每个任务保存为目录中的<id>.json，执行时持有<id>.lock，
+// 多个进程可以共用同一个目录：任一进程都能列出任务、提交任务和发出控制请求，排队的任务只会被执行一次
+type Manager struct {
+	dir string
+	pid int
+
+	mu      sync.Mutex
+	runners map[string]RunFunc
+	running map[string]*runningJob // 本进程执行中的任务
+
+	wake chan struct{} // 有新任务排队时通知工作协程
+}
+
+// 本进程执行中的任务
+type runningJob struct {
+	id       string
+	job      Job // 受Manager.mu保护
+	gate     *gate
+	cancel   context.CancelFunc
+	canceled bool // 用户取消，受Manager.mu保护
+	done     chan struct{}
+
+	saveMu sync.Mutex // 按顺序写入记录，旧的快照不会覆盖新的
+	saved  time.Time
+}
+
+// 打开任务目录，不存在时创建。上次运行中断的任务重新排队
+func NewManager(dir string) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建任务目录失败: %v", err)
+	}
+	m := &Manager{
+		dir:     dir,
+		pid:     os.Getpid(),
+		runners: make(map[string]RunFunc),
+		running: make(map[string]*runningJob),
+		wake:    make(chan struct{}, 1),
+	}
+	m.recoverStale()
+	return m, nil
+}
+
+// 注册任务类型的执行函数
+func (m *Manager) Register(jobType string, run RunFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runners[jobType] = run
+}
+
+// 提交任务，等待工作协程（Start、RunPending）执行
+func (m *Manager) Submit(jobType string, params map[string]string) (Job, error) {
+	m.mu.Lock()
+	_, ok := m.runners[jobType]
+	m.mu.Unlock()
+	if !ok {
+		return Job{}, fmt.Errorf("%w: %s", ErrUnknownType, jobType)
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	now := time.Now()
+	job := Job{ID: id, Type: jobType, Params: params, State: StateQueued, Created: now, Updated: now}
+	if err := m.save(&job); err != nil {
+		return Job{}, err
+	}
+	m.notify()
+	return job, nil
+}
+
+// 提交任务并在当前协程中执行，返回结束时的任务记录和执行函数返回的错误。
+// ctx取消时任务回到排队状态，之后可以继续执行
+func (m *Manager) Run(ctx context.Context, jobType string, params map[string]string) (Job, error) {
+	job, err := m.Submit(jobType, params)
+	if err != nil {
+		return job, err
+	}
+	job, err = m.execute(ctx, job.ID)
+	if errors.Is(err, errClaimed) {
+		// 提交后被工作协程领取
+		return m.Wait(ctx, job.ID)
+	}
+	return job, err
+}
+
+// 启动workers个工作协程，按提交顺序执行排队的任务，ctx取消后停止
+func (m *Manager) Start(ctx context.Context, workers int) {
+	for i := 0; i < max(workers, 1); i++ {
+		go m.work(ctx, false)
+	}
+}
+
+// 用workers个工作协程执行所有排队的任务，没有排队的任务时返回
+func (m *Manager) RunPending(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.work(ctx, true)
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *Manager) work(ctx context.Context, exitWhenIdle bool) {
+	for ctx.Err() == nil {
+		id := m.next()
+		if id == "" {
+			if exitWhenIdle {
+				return
+			}
+			select {
+			case <-m.wake:
+			case <-time.After(idlePoll):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		// 执行结果记录在任务中
+		m.execute(ctx, id)
+	}
+}
+
+// 最早提交的、本进程可以执行的排队任务
+func (m *Manager) next() string {
+	m.recoverStale()
+	jobs := m.List()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, job := range jobs {
+		if job.State != StateQueued || m.runners[job.Type] == nil || m.running[job.ID] != nil {
+			continue
+		}
+		if _, err := os.Stat(m.lockPath(job.ID)); err == nil {
+			continue
+		}
+		return job.ID
+	}
+	return ""
+}
+
+func (m *Manager) execute(ctx context.Context, id string) (Job, error) {
+	if !m.claim(id) {
+		return Job{}, errClaimed
+	}
+	defer m.unclaim(id)
+
+	job, err := m.load(id)
+	if err != nil {
+		return Job{}, err
+	}
+	if job.State != StateQueued {
+		// 领取之前已被取消或暂停
+		return *job, nil
+	}
+	m.mu.Lock()
+	run := m.runners[job.Type]
+	m.mu.Unlock()
+	if run == nil {
+		return *job, fmt.Errorf("未知的任务类型: %s", job.Type)
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r := &runningJob{id: id, job: *job, gate: &gate{}, cancel: cancel, done: make(chan struct{})}
+	jobCtx = context.WithValue(jobCtx, gateKey{}, r.gate)
+	defer close(r.done)
+
+	r.job.State = StateRunning
+	r.job.Owner = m.pid
+	r.job.Error = ""
+	if r.job.Started.IsZero() {
+		r.job.Started = time.Now()
+	}
+	m.mu.Lock()
+	m.running[id] = r
+	m.mu.Unlock()
+	m.persist(r, true)
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.monitor(r, stop)
+	}()
+	runErr := run(jobCtx, &Handle{m: m, run: r})
+	close(stop)
+	<-stopped
+
+	m.mu.Lock()
+	switch {
+	case runErr == nil:
+		r.job.State = StateSucceeded
+	case r.canceled:
+		r.job.State = StateCanceled
+	case ctx.Err() != nil:
+		// 进程退出等原因中断，之后可以继续执行
+		r.job.State = StateQueued
+		r.job.Progress.Message = "已中断，等待继续执行"
+	default:
+		r.job.State = StateFailed
+		r.job.Error = runErr.Error()
+	}
+	if r.job.State.Finished() {
+		r.job.Finished = time.Now()
+	}
+	r.job.Owner = 0
+	delete(m.running, id)
+	final := r.job
+	m.mu.Unlock()
+	m.persist(r, true)
+
+	return final, runErr
+}
+
+// 定期写入任务记录，处理其他进程发来的控制请求
+func (m *Manager) monitor(r *runningJob, stop <-chan struct{}) {
+	control := time.NewTicker(controlInterval)
+	defer control.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-control.C:
+			if action := m.takeControl(r.id); action != "" {
+				m.apply(r, action)
+			}
+		case <-heartbeat.C:
+			m.persist(r, true)
+		}
+	}
+}
+
+// 对本进程执行中的任务执行控制操作
+func (m *Manager) apply(r *runningJob, action string) {
+	m.mu.Lock()
+	switch action {
+	case controlCancel:
+		r.canceled = true
+		r.gate.resume()
+		r.cancel()
+	case controlPause:
+		if r.job.State == StateRunning {
+			r.gate.pause()
+			r.job.State = StatePaused
+		}
+	case controlResume:
+		if r.job.State == StatePaused {
+			r.gate.resume()
+			r.job.State = StateRunning
+		}
+	}
+	m.mu.Unlock()
+	m.persist(r, true)
+}
+
+// 取消任务。执行中的任务在下一次检查ctx时结束
+func (m *Manager) Cancel(id string) error {
+	return m.control(id, controlCancel)
+}
+
+// 暂停任务。执行中的任务在下一次传输或Checkpoint时等待，排队的任务不再被领取
+func (m *Manager) Pause(id string) error {
+	return m.control(id, controlPause)
+}
+
+// 继续已暂停的任务
+func (m *Manager) Resume(id string) error {
+	return m.control(id, controlResume)
+}
+
+func (m *Manager) control(id, action string) error {
+	m.mu.Lock()
+	r := m.running[id]
+	m.mu.Unlock()
+	if r != nil {
+		m.apply(r, action)
+		return nil
+	}
+
+	job, err := m.load(id)
+	if err != nil {
+		return err
+	}
+	if job.State.Finished() {
+		return ErrFinished
+	}
+	if !m.claim(id) {
+		// 其他进程正在执行，由它处理控制请求
+		if err := os.WriteFile(m.controlPath(id), []byte(action), 0644); err != nil {
+			return fmt.Errorf("发送控制请求失败: %v", err)
+		}
+		return nil
+	}
+	defer m.unclaim(id)
+
+	// 没有进程在执行，直接修改记录
+	if job, err = m.load(id); err != nil {
+		return err
+	}
+	switch action {
+	case controlCancel:
+		job.State = StateCanceled
+		job.Finished = time.Now()
+	case controlPause:
+		if job.State == StateQueued {
+			job.State = StatePaused
+		}
+	case controlResume:
+		if job.State == StatePaused {
+			job.State = StateQueued
+			defer m.notify()
+		}
+	}
+	job.Updated = time.Now()
+	return m.save(job)
+}
+
+// 等待任务结束
+func (m *Manager) Wait(ctx context.Context, id string) (Job, error) {
+	m.mu.Lock()
+	r := m.running[id]
+	m.mu.Unlock()
+	if r != nil {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return Job{}, ctx.Err()
+		}
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		job, err := m.load(id)
+		if err != nil {
+			return Job{}, err
+		}
+		if job.State.Finished() {
+			if job.State != StateSucceeded {
+				return *job, fmt.Errorf("任务%s: %s", job.State, strings.TrimSpace(job.Error))
+			}
+			return *job, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return *job, ctx.Err()
+		}
+	}
+}
+
+// 所有任务，最新提交的在前。本进程执行中的任务取内存中的最新状态
+func (m *Manager) List() []Job {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil
+	}
+	var jobs []Job
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		job, err := m.Get(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.After(jobs[j].Created) })
+	return jobs
+}
+
+// 查看单个任务
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	if r := m.running[id]; r != nil {
+		job := r.job
+		m.mu.Unlock()
+		return job, nil
+	}
+	m.mu.Unlock()
+
+	job, err := m.load(id)
+	if err != nil {
+		return Job{}, err
+	}
+	return *job, nil
+}
+
+// 将执行任务的进程已退出的任务重新排队（暂停的任务保持暂停），清除遗留的锁
+func (m *Manager) recoverStale() {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if filepath.Ext(name) != ".json" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		id := strings.TrimSuffix(name, ".json")
+		m.mu.Lock()
+		owned := m.running[id] != nil
+		m.mu.Unlock()
+		if owned {
+			continue
+		}
+		job, err := m.load(id)
+		if err != nil || job.Owner == 0 || time.Since(job.Updated) < staleAfter {
+			continue
+		}
+		os.Remove(m.lockPath(id))
+		os.Remove(m.controlPath(id))
+		if job.State == StateRunning {
+			job.State = StateQueued
+			job.Progress.Message = "上次执行中断，等待继续执行"
+		}
+		job.Owner = 0
+		job.Updated = time.Now()
+		if err := m.save(job); err != nil {
+			fmt.Printf("警告: %v\n", err)
+		}
+	}
+}
+
+// 写入任务记录。force为false时按progressInterval限制频率
+func (m *Manager) persist(r *runningJob, force bool) {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+
+	now := time.Now()
+	m.mu.Lock()
+	if !force && now.Sub(r.saved) < progressInterval {
+		m.mu.Unlock()
+		return
+	}
+	r.job.Updated = now
+	job := r.job
+	m.mu.Unlock()
+
+	r.saved = now
+	if err := m.save(&job); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+}
+
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) jobPath(id string) string     { return filepath.Join(m.dir, id+".json") }
+func (m *Manager) lockPath(id string) string    { return filepath.Join(m.dir, id+".lock") }
+func (m *Manager) controlPath(id string) string { return filepath.Join(m.dir, id+".control") }
+
+// 领取任务，任务已被领取时返回false
+func (m *Manager) claim(id string) bool {
+	f, err := os.OpenFile(m.lockPath(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return false
+	}
+	fmt.Fprintf(f, "%d\n", m.pid)
+	f.Close()
+	return true
+}
+
+func (m *Manager) unclaim(id string) {
+	os.Remove(m.lockPath(id))
+}
+
+// 读取并删除其他进程发来的控制请求
+func (m *Manager) takeControl(id string) string {
+	data, err := os.ReadFile(m.controlPath(id))
+	if err != nil {
+		return ""
+	}
+	os.Remove(m.controlPath(id))
+	return strings.TrimSpace(string(data))
+}
+
+func (m *Manager) load(id string) (*Job, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(m.jobPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("读取任务%s失败: %v", id, err)
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("解析任务%s失败: %v", id, err)
+	}
+	return &job, nil
+}
+
+// 先写入临时文件再重命名，其他进程不会读到写入一半的记录
+func (m *Manager) save(job *Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化任务失败: %v", err)
+	}
+	tmp, err := os.CreateTemp(m.dir, "."+job.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("写入任务%s失败: %v", job.ID, err)
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), m.jobPath(job.ID))
+	}
+	if werr != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("写入任务%s失败: %v", job.ID, werr)
+	}
+	return nil
+}
+
+// 按提交时间排序的任务ID
+func newJobID() (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("生成任务ID失败: %v", err)
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix), nil
+}
@@ -2,219 +2,1966 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"mime"
+	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"panmatrix/config"
 	"panmatrix/drivers"
+	"panmatrix/jobs"
 	"panmatrix/metadata"
 	"panmatrix/raid"
 	"panmatrix/scheduler"
 )
 
+// 配置文件路径，由全局参数 -config 指定
+var configPath = "config.yaml"
+
 func main() {
-	// 命令行参数
-	raidLevel := flag.Int("raid", 0, "RAID级别 (0, 1, 5, 10)")
-	uploadFile := flag.String("upload", "", "要上传的文件路径")
-	downloadFile := flag.String("download", "", "要下载的文件ID")
-	outputPath := flag.String("output", "./download", "下载文件输出路径")
+	// 全局参数写在命令之前，如 panmatrix-raid -user alice ls /
+	global := flag.NewFlagSet("panmatrix-raid", flag.ExitOnError)
+	global.StringVar(&configPath, "config", configPath, "配置文件路径")
+	user := global.String("user", os.Getenv("PANMATRIX_USER"), "以该用户身份操作：上传的文件属于该用户，列表、下载、删除等只涉及该用户的文件（默认取环境变量 PANMATRIX_USER）")
+	var hybrid hybridOptions
+	global.BoolVar(&hybrid.Enabled, "hybrid", false, "混合模式：本地保留完整副本，云端提供冗余")
+	global.Float64Var(&hybrid.MinFree, "local-min-free", 0.1, "混合模式下本地可用空间低于该比例时淘汰本地副本")
+	global.Int64Var(&hybrid.MaxSizeMB, "local-max-size", 0, "混合模式下本地副本总大小上限 (MB)，0表示不限")
+	global.StringVar(&hybrid.Pin, "local-pin", "", "混合模式下本地副本常驻的文件（逗号分隔的文件ID或文件名）")
+	global.Usage = func() { printUsage(global) }
+	global.Parse(os.Args[1:])
+	
+	// 没有指定命令时进入交互式命令行
+	name, args := "shell", global.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "未知的命令: %s\n\n", name)
+		global.Usage()
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	fs.Usage = func() { printCommandUsage(fs, cmd) }
+	run := cmd.setup(fs)
+	args = parseArgs(fs, args)
+	
+	if cmd.name == "help" {
+		if len(args) == 0 {
+			global.SetOutput(os.Stdout)
+			global.Usage()
+		} else if err := run(context.Background(), nil, args); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+	e := newEnv(*user, hybrid)
+	defer e.close()
+	
+	// Ctrl+C 取消当前操作并清理已上传的数据；交互式命令行中只取消正在执行的命令
+	signals := []os.Signal{os.Interrupt, syscall.SIGTERM}
+	if cmd.interactive {
+		signals = signals[1:]
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), signals...)
+	defer stop()
 	
-	flag.Parse()
+	if err := run(ctx, e, args); err != nil {
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			os.Exit(2)
+		}
+		log.Fatalf("%s: %v", cmd.failure, err)
+	}
+}
+
+// 混合模式的全局参数
+type hybridOptions struct {
+	Enabled   bool
+	MinFree   float64
+	MaxSizeMB int64
+	Pin       string
+}
+
+// 命令的运行环境。配置、审计日志、凭据保险库和任务目录在执行命令前打开，阵列由需要的命令用open初始化
+type env struct {
+	cfg      *config.Config
+	user     string
+	hybrid   hybridOptions
+	auditLog *metadata.AuditLog
+	vault    *metadata.CredentialVault
+	jobs     *jobs.Manager
 	
+	// open之后可用
+	storageDrivers map[string]drivers.StorageDriver
+	driverTypes    map[string]string
+	rc             *raid.RAIDController
+	mm             *metadata.MetadataManager
+	ns             *metadata.Namespace
+	rs             *scheduler.RAIDScheduler
+	schedulerCfg   scheduler.SchedulerConfig
+}
+
+// 初始化阵列时的选项
+type openOptions struct {
+	RAIDLevel     int    // 上传的默认RAID级别
+	Dense         bool   // 全零条带也上传，不记录为空洞
+	Bootstrap     bool   // 先从副本驱动器恢复元数据
+	RestoreBackup string // 先将元数据回滚到该备份
+}
+
+func newEnv(user string, hybrid hybridOptions) *env {
 	// 加载配置
-	cfg, err := config.LoadConfig("config.yaml")
+	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
+	e := &env{cfg: cfg, user: user, hybrid: hybrid}
+	
+	// 所有HTTP驱动共享的连接池
+	httpCfg, err := drivers.LoadHTTPTransportConfig(configPath)
+	if err != nil {
+		log.Printf("警告: %v", err)
+	}
+	if err := drivers.ConfigureHTTPTransport(httpCfg); err != nil {
+		log.Fatalf("HTTP配置无效: %v", err)
+	}
+	
+	// 所有驱动器合计的带宽上限
+	bandwidthCfg, err := drivers.LoadBandwidthConfig(configPath)
+	if err != nil {
+		log.Printf("警告: %v", err)
+	}
+	if err := drivers.SetBandwidthLimit(bandwidthCfg); err != nil {
+		log.Fatalf("带宽配置无效: %v", err)
+	}
+	
+	// OAuth驱动刷新后的令牌保存在元数据目录，重启后继续使用
+	if err := drivers.SetTokenStore(filepath.Join(cfg.Core.MetadataPath, "tokens.json")); err != nil {
+		log.Printf("警告: %v", err)
+	}
+	
+	// 谁在什么时候做了什么，记录在元数据目录的audit.log中。打开元数据之前的命令（audit、vault、login）
+	// 直接使用该日志，之后的操作通过元数据管理器记录
+	e.auditLog = metadata.OpenAuditLog(cfg.Core.MetadataPath)
+	if user != "" {
+		e.auditLog.SetUser(user)
+	}
+	
+	// 启用凭据保险库时，驱动器的令牌、Cookie等从保险库读取，刷新后的令牌也写入保险库
+	if e.vault, err = openVault(cfg.Core.MetadataPath); err != nil {
+		log.Fatalf("打开凭据保险库失败: %v", err)
+	}
 	
+	// 重建、巡检、迁移和清理作为后台任务保存在元数据目录的jobs中，查看和控制任务不需要初始化阵列
+	if e.jobs, err = jobs.NewManager(filepath.Join(cfg.Core.MetadataPath, "jobs")); err != nil {
+		log.Fatalf("打开任务目录失败: %v", err)
+	}
+	return e
+}
+
+func (e *env) close() {
+	if e.mm != nil {
+		e.mm.Close()
+	}
+	e.auditLog.Close()
+}
+
+// 初始化驱动器、RAID控制器、元数据管理器和调度器，并注册后台任务
+func (e *env) open(opts openOptions) {
 	// 初始化存储驱动
-	storageDrivers := initializeDrivers(cfg)
+	storageDrivers, driverTypes := initializeDrivers(e.cfg)
 	if len(storageDrivers) < 2 {
 		log.Fatal("至少需要2个存储驱动器")
 	}
 	
 	// 初始化RAID控制器
-	raidController, err := raid.NewRAIDController(
-		raid.RAIDLevel(*raidLevel),
-		storageDrivers,
-		cfg.Core.ChunkSize,
-	)
+	var raidController *raid.RAIDController
+	var err error
+	if e.hybrid.Enabled {
+		raidController, err = raid.NewHybridRAIDController(
+			raid.RAIDLevel(opts.RAIDLevel),
+			storageDrivers,
+			e.cfg.Core.ChunkSize,
+			raid.HybridPolicy{LocalDriver: "local", MinFreeRatio: e.hybrid.MinFree, MaxLocalBytes: e.hybrid.MaxSizeMB * 1024 * 1024},
+		)
+	} else {
+		raidController, err = raid.NewRAIDController(
+			raid.RAIDLevel(opts.RAIDLevel),
+			storageDrivers,
+			e.cfg.Core.ChunkSize,
+		)
+	}
 	if err != nil {
 		log.Fatalf("初始化RAID控制器失败: %v", err)
 	}
 	
-	// 初始化元数据管理器
-	metaManager, err := metadata.NewMetadataManager(cfg.Core.MetadataPath)
+	// 初始化元数据管理器，存储后端由metadata段选择
+	metaManager, err := openMetadata(e.cfg.Core.MetadataPath, storageDrivers, opts.Bootstrap, opts.RestoreBackup, e.auditLog)
 	if err != nil {
 		log.Fatalf("初始化元数据管理器失败: %v", err)
 	}
 	
-	// 初始化调度器
-	raidScheduler := scheduler.NewRAIDScheduler(storageDrivers)
+	// 指定用户时只操作该用户的文件，未指定时不区分用户
+	ns := metaManager.Namespace(e.user)
+	if e.user != "" {
+		metaManager.Audit().SetUser(e.user)
+	}
 	
-	// 根据命令行参数执行操作
-	ctx := context.Background()
+	raidController.SetSparse(!opts.Dense)
 	
-	if *uploadFile != "" {
-		if err := handleUpload(ctx, raidController, metaManager, raidScheduler, *uploadFile, *raidLevel); err != nil {
-			log.Fatalf("上传失败: %v", err)
-		}
-	} else if *downloadFile != "" {
-		if err := handleDownload(ctx, raidController, metaManager, *downloadFile, *outputPath); err != nil {
-			log.Fatalf("下载失败: %v", err)
+	// 上传进度持久化到元数据，中断后重新上传同一文件时续传
+	raidController.SetProgressStore(metaManager)
+	// 每个条带写入完成时记录块的位置，上传中断后已写入的条带块不会成为无人知晓的孤儿数据
+	raidController.SetStripRecorder(metaManager)
+	// 删除文件时保留仍被其他文件引用的块
+	raidController.SetChunkRefCounter(metaManager)
+	
+	if e.hybrid.Enabled {
+		// 预加载所有文件的条带分布，以便按LRU淘汰本地副本
+		loadAllLayouts(raidController, metaManager)
+		pinLocalCopies(raidController, metaManager, e.hybrid.Pin)
+	}
+	
+	// 初始化调度器
+	raidScheduler := scheduler.NewRAIDScheduler(storageDrivers)
+	raidScheduler.SetStripeSize(e.cfg.Core.ChunkSize)
+	if e.hybrid.Enabled {
+		// 本地驱动器只保存完整副本，条带块只放置到云端驱动器
+		raidScheduler.SetReplicaOnly("local")
+	}
+	// 按PanMatrix放置在各驱动器上的数据量限制只使用网盘的一部分空间
+	raidScheduler.SetCapacityBudget(metaManager, metaManager.CapacityConfig().WarnRatio)
+	// 健康监控发现的状态变化写入元数据并发布driver.health事件
+	raidScheduler.SetHealthReporter(metaManager)
+	// 调度模式和各驱动器的费用参数，mode为cost时规划费用最低的放置
+	schedulerCfg, err := scheduler.LoadSchedulerConfig(configPath)
+	if err != nil {
+		log.Printf("警告: %v", err)
+	}
+	if err := raidScheduler.SetCostModel(schedulerCfg); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetTransferLimits(schedulerCfg.TransferSlots, schedulerCfg.DeadlineEscalation); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetDriverConcurrency(schedulerCfg.Concurrency); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetAdaptiveConcurrency(schedulerCfg.Adaptive); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetAdmissionLimit(schedulerCfg.MaxQueuedMB * 1024 * 1024); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetSpaceMargin(schedulerCfg.SpaceMarginMB * 1024 * 1024); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetTimeWindows(schedulerCfg.Windows); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetStrategy(schedulerCfg.Strategy, schedulerCfg.Weights); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetFailureDomains(schedulerCfg.Domains, driverTypes); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetAffinity(schedulerCfg.Affinity); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetAccountLimits(schedulerCfg.AccountLimits); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	if err := raidScheduler.SetHedgeDelay(schedulerCfg.HedgeDelay); err != nil {
+		log.Fatalf("调度配置无效: %v", err)
+	}
+	// 定期用真实的上传下载测量各驱动器的延迟和带宽
+	raidScheduler.StartProbing(schedulerCfg.ProbeInterval, schedulerCfg.ProbeSizeKB*1024)
+	// 每次传输按优先级取得驱动器的槽位，用户的上传和下载优先于重建和巡检
+	raidController.SetTransferScheduler(raidScheduler)
+	// 重新条带化和迁空驱动器与上传一样按调度器的空间规划、文件固定规则和故障域放置
+	raidController.SetPlacementPlanner(placementPlanner{rc: raidController, mm: metaManager, rs: raidScheduler})
+	// 之前用 drain 命令排空的驱动器继续不放置新数据
+	for _, name := range metaManager.DrainingDrivers() {
+		if err := setDraining(raidController, raidScheduler, name, true); err != nil {
+			log.Printf("警告: 排空驱动器 %s 失败: %v", name, err)
 		}
-	} else {
-		// 启动交互式命令行或Web界面
-		startInteractive(raidController, metaManager, raidScheduler)
 	}
+	
+	registerJobs(e.jobs, raidController, metaManager, storageDrivers)
+	
+	e.storageDrivers, e.driverTypes = storageDrivers, driverTypes
+	e.rc, e.mm, e.ns, e.rs = raidController, metaManager, ns, raidScheduler
+	e.schedulerCfg = schedulerCfg
 }
 
-func initializeDrivers(cfg *config.Config) map[string]drivers.StorageDriver {
-	driversMap := make(map[string]drivers.StorageDriver)
+// 打开元数据存储，配置了replicas时修改会复制到副本驱动器；bootstrap时先从副本恢复，
+// restoreBackup不为空时先回滚到该备份
+func openMetadata(basePath string, storageDrivers map[string]drivers.StorageDriver, bootstrap bool,
+	restoreBackup string, audit *metadata.AuditLog) (*metadata.MetadataManager, error) {
 	
-	// 百度网盘驱动
-	if cfg.Baidu.Enabled {
-		baiduDriver, err := drivers.NewBaiduDriver(cfg.Baidu)
-		if err != nil {
-			log.Printf("警告: 初始化百度驱动失败: %v", err)
-		} else {
-			driversMap["baidu"] = baiduDriver
-			if err := baiduDriver.Connect(); err != nil {
-				log.Printf("警告: 连接百度网盘失败: %v", err)
-			}
-		}
+	storeCfg, err := metadata.LoadStoreConfig(configPath)
+	if err != nil {
+		log.Printf("警告: %v", err)
 	}
-	
-	// 阿里云盘驱动
-	if cfg.Aliyun.Enabled {
-		aliyunDriver, err := drivers.NewAliyunDriver(cfg.Aliyun)
+	backupCfg, err := storeCfg.Backups()
+	if err != nil {
+		return nil, err
+	}
+	backups, err := metadata.NewBackups(basePath, backupCfg, storageDrivers)
+	if err != nil {
+		return nil, err
+	}
+	if restoreBackup != "" {
+		if bootstrap {
+			return nil, errors.New("不能同时从副本恢复和回滚元数据")
+		}
+		err := restoreMetadata(basePath, storeCfg, backups, restoreBackup)
+		recordAudit(audit, metadata.AuditEntry{Op: metadata.AuditRollback, Target: restoreBackup}, err)
 		if err != nil {
-			log.Printf("警告: 初始化阿里云驱动失败: %v", err)
-		} else {
-			driversMap["aliyun"] = aliyunDriver
-			if err := aliyunDriver.Connect(); err != nil {
-				log.Printf("警告: 连接阿里云盘失败: %v", err)
-			}
+			return nil, fmt.Errorf("回滚元数据失败: %v", err)
 		}
 	}
 	
-	// OneDrive驱动（示例，需要实现）
-	// if cfg.OneDrive.Enabled {
-	//     oneDriveDriver, err := drivers.NewOneDriveDriver(cfg.OneDrive)
-	//     if err == nil {
-	//         driversMap["onedrive"] = oneDriveDriver
-	//     }
-	// }
-	
-	// 本地缓存驱动（必须）
-	localDriver, err := drivers.NewLocalDriver(cfg.Local)
+	mm, err := openMetadataStore(basePath, storeCfg, storageDrivers, bootstrap)
 	if err != nil {
-		log.Fatalf("初始化本地驱动失败: %v", err)
+		return nil, err
 	}
-	driversMap["local"] = localDriver
-	
-	return driversMap
+	mm.SetBackups(backups)
+	return mm, nil
 }
 
-func handleUpload(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, 
-	rs *scheduler.RAIDScheduler, filePath string, raidLevel int) error {
-	
-	// 读取文件
-	data, err := os.ReadFile(filePath)
+// 将存储回滚到备份，在打开元数据管理器之前执行
+func restoreMetadata(basePath string, storeCfg metadata.StoreConfig, backups *metadata.Backups, name string) error {
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return fmt.Errorf("创建元数据目录失败: %v", err)
+	}
+	store, err := metadata.OpenStore(basePath, storeCfg)
 	if err != nil {
-		return fmt.Errorf("读取文件失败: %v", err)
+		return err
 	}
+	defer store.Close()
 	
-	fmt.Printf("开始上传文件: %s (大小: %.2f MB)\n", 
-		filePath, float64(len(data))/(1024*1024))
-	
-	startTime := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	info, err := backups.Restore(ctx, store, name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已将元数据回滚到 %s 上的备份 %s（备份时间 %s），回滚前的元数据已另外备份\n",
+		info.Location, info.Name, info.CreatedAt.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// 按metadata段打开存储后端并创建元数据管理器
+func openMetadataStore(basePath string, storeCfg metadata.StoreConfig, storageDrivers map[string]drivers.StorageDriver,
+	bootstrap bool) (*metadata.MetadataManager, error) {
 	
-	// 使用RAID控制器写入文件
-	fileID, err := rc.WriteFile(ctx, filePath, data)
+	replicaCfg, err := storeCfg.Replicas()
 	if err != nil {
-		return fmt.Errorf("RAID写入失败: %v", err)
+		return nil, err
+	}
+	if len(replicaCfg.Drives) == 0 {
+		if bootstrap {
+			return nil, errors.New("未配置metadata.replicas，无法从副本恢复")
+		}
+		return metadata.OpenMetadataManager(basePath, storeCfg)
 	}
 	
-	// 创建并保存元数据
-	metadata := &metadata.FileMetadata{
-		FileID:      fileID,
-		FileName:    filePath,
-		FileSize:    int64(len(data)),
-		RAIDLevel:   raidLevel,
-		StripeSize:  rc.StripeSize,
-		StripeCount: int((int64(len(data)) + rc.StripeSize - 1) / rc.StripeSize),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+	replicas := make(map[string]drivers.StorageDriver)
+	for _, name := range replicaCfg.Drives {
+		driver, ok := storageDrivers[name]
+		if !ok {
+			return nil, fmt.Errorf("元数据副本驱动器不存在: %s", name)
+		}
+		replicas[name] = driver
 	}
 	
-	if err := mm.SaveFileMetadata(metadata); err != nil {
-		return fmt.Errorf("保存元数据失败: %v", err)
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("创建元数据目录失败: %v", err)
+	}
+	store, err := metadata.OpenStore(basePath, storeCfg)
+	if err != nil {
+		return nil, err
+	}
+	if bootstrap {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		report, err := metadata.Bootstrap(ctx, store, replicas, replicaCfg)
+		cancel()
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("从副本恢复元数据失败: %v", err)
+		}
+		fmt.Printf("已从 %s 恢复%d个文件的元数据（快照时间 %s）\n",
+			report.Driver, report.Files, report.CreatedAt.Format("2006-01-02 15:04:05"))
 	}
 	
-	duration := time.Since(startTime)
-	speed := float64(len(data)) / duration.Seconds() / (1024 * 1024) // MB/s
+	replicated, err := metadata.NewReplicatedStore(store, replicas, replicaCfg)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	mm, err := metadata.NewMetadataManagerWithStore(basePath, replicated)
+	if err != nil {
+		replicated.Close()
+		return nil, err
+	}
+	if err := mm.ApplyConfig(storeCfg); err != nil {
+		mm.Close()
+		return nil, err
+	}
+	return mm, nil
+}
+
+// 返回初始化成功的驱动器和各驱动器的类型
+func initializeDrivers(cfg *config.Config) (map[string]drivers.StorageDriver, map[string]string) {
+	driversMap := make(map[string]drivers.StorageDriver)
+	types := make(map[string]string)
+
+	// drives 段中的驱动器实例，类型由 drivers.Register 注册
+	specs, err := drivers.LoadDriveSpecs(configPath)
+	if err != nil {
+		log.Fatalf("加载驱动器配置失败: %v", err)
+	}
+
+	// 兼容旧的顶层 baidu/aliyun/local 配置段（drives 中同名实例优先）
+	configured := make(map[string]bool)
+	for _, spec := range specs {
+		configured[spec.Name] = true
+	}
+	legacy := []struct {
+		name    string
+		enabled bool
+		cfg     interface{}
+	}{
+		{"baidu", cfg.Baidu.Enabled, cfg.Baidu},
+		{"aliyun", cfg.Aliyun.Enabled, cfg.Aliyun},
+		{"local", true, cfg.Local}, // 本地缓存驱动（必须）
+	}
+	for _, l := range legacy {
+		if !l.enabled || configured[l.name] {
+			continue
+		}
+		spec, err := drivers.NewDriveSpec(l.name, l.name, l.cfg)
+		if err != nil {
+			log.Fatalf("转换%s配置失败: %v", l.name, err)
+		}
+		specs = append(specs, spec)
+	}
+
+	for _, spec := range specs {
+		if !spec.Enabled {
+			continue
+		}
+
+		driver, err := drivers.NewDriver(spec)
+		if err != nil {
+			if spec.Name == "local" {
+				log.Fatalf("初始化本地驱动失败: %v", err)
+			}
+			log.Printf("警告: 初始化驱动器 %s (%s) 失败: %v", spec.Name, spec.Type, err)
+			continue
+		}
+		driversMap[spec.Name] = driver
+		types[spec.Name] = spec.Type
+		if err := driver.Connect(); err != nil {
+			log.Printf("警告: 连接驱动器 %s (%s) 失败: %v", spec.Name, spec.Type, err)
+		}
+	}
+
+	return driversMap, types
+}
+
+// 按metadata段的vault配置打开凭据保险库并交给驱动器使用，未启用时返回nil
+func openVault(basePath string) (*metadata.CredentialVault, error) {
+	storeCfg, err := metadata.LoadStoreConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	vault, err := metadata.OpenConfiguredVault(basePath, storeCfg)
+	if err != nil || vault == nil {
+		return nil, err
+	}
 	
-	fmt.Printf("上传成功! 文件ID: %s\n", fileID)
-	fmt.Printf("耗时: %.2f秒, 平均速度: %.2f MB/s\n", duration.Seconds(), speed)
-	fmt.Printf("RAID级别: %d, 条带大小: %d字节\n", raidLevel, rc.StripeSize)
+	drivers.SetCredentialStore(vault)
+	if n, err := drivers.MigrateStoredTokens(); err != nil {
+		log.Printf("警告: %v", err)
+	} else if n > 0 {
+		fmt.Printf("已将 %d 个令牌从tokens.json移入凭据保险库\n", n)
+	}
+	return vault, nil
+}
+
+// 写入、列出或删除凭据保险库中的凭据，写入和删除记录到审计日志（不含字段值）
+func handleVault(audit *metadata.AuditLog, vault *metadata.CredentialVault, set string, list bool, del string) error {
+	if vault == nil {
+		return errors.New("未启用凭据保险库，请在config.yaml的metadata段中设置 vault.enabled: true")
+	}
 	
+	switch {
+	case set != "":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("读取标准输入失败: %v", err)
+		}
+		fields, err := metadata.ParseCredentialFields(string(data))
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return errors.New("标准输入中没有 字段=值 行")
+		}
+		err = vault.PutCredential(set, fields)
+		recordAudit(audit, metadata.AuditEntry{Op: metadata.AuditCredential, Target: set}, err)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已更新 %s 的 %d 个字段\n", set, len(fields))
+	case del != "":
+		err := vault.DeleteCredential(del)
+		recordAudit(audit, metadata.AuditEntry{Op: metadata.AuditCredential, Target: del}, err)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已删除 %s 的凭据\n", del)
+	case list:
+		entries := vault.ListCredentials()
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-30s %s\n", name, strings.Join(entries[name], ", "))
+		}
+	}
 	return nil
 }
 
-func handleDownload(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, 
-	fileID, outputPath string) error {
-	
-	fmt.Printf("开始下载文件: %s\n", fileID)
-	
-	startTime := time.Now()
-	
-	// 使用RAID控制器读取文件
-	data, err := rc.ReadFile(ctx, fileID)
+// 记录一条审计日志，写入失败只打印警告，不影响操作本身
+func recordAudit(audit *metadata.AuditLog, entry metadata.AuditEntry, err error) {
+	if recErr := audit.Record(entry, err); recErr != nil {
+		log.Printf("警告: %v", recErr)
+	}
+}
+
+// 列出每个驱动器上PanMatrix放置的数据量（按元数据统计）、驱动器报告的用量和容量预算
+func handleCapacity(mm *metadata.MetadataManager, storageDrivers map[string]drivers.StorageDriver) error {
+	usage, err := mm.DriverCapacity()
 	if err != nil {
-		return fmt.Errorf("RAID读取失败: %v", err)
+		return err
 	}
 	
-	// 获取文件元数据以确定文件名
-	meta, err := mm.GetFileMetadata(fileID)
-	if err != nil {
-		// 如果无法获取元数据，使用文件ID作为文件名
-		outputPath = fmt.Sprintf("%s/%s.download", outputPath, fileID)
-	} else {
-		outputPath = fmt.Sprintf("%s/%s", outputPath, meta.FileName)
+	gb := func(n int64) float64 { return float64(n) / (1 << 30) }
+	fmt.Printf("%-20s %12s %12s %12s %18s  %s\n", "驱动器", "已放置(GB)", "预算(GB)", "近30天(GB)", "驱动器报告(GB)", "预计用完")
+	for _, c := range usage {
+		budget, projection := "-", "-"
+		if c.Budget > 0 {
+			budget = fmt.Sprintf("%.2f", gb(c.Budget))
+			if c.Remaining() == 0 {
+				projection = "已用完"
+			} else if days, ok := c.DaysUntilFull(); ok {
+				projection = fmt.Sprintf("约%.0f天后", days)
+			}
+		}
+		reported := "-"
+		if driver, ok := storageDrivers[c.Driver]; ok {
+			if used, total, err := driver.GetUsage(); err == nil {
+				reported = fmt.Sprintf("%.2f/%.2f", gb(used), gb(total))
+			}
+		}
+		fmt.Printf("%-20s %12.2f %12s %12.2f %18s  %s\n", c.Driver, gb(c.Placed), budget, gb(c.Recent), reported, projection)
+	}
+	return nil
+}
+
+// 探测所有驱动器的实际延迟和带宽
+func handleProbe(ctx context.Context, rs *scheduler.RAIDScheduler, size int64) {
+	mbps := func(bps float64) string { return fmt.Sprintf("%.2f", bps/(1024*1024)) }
+	fmt.Printf("%-20s %12s %14s %14s  %s\n", "驱动器", "延迟", "上传(MB/s)", "下载(MB/s)", "状态")
+	for _, r := range rs.ProbeDrivers(ctx, size) {
+		switch {
+		case r.Skipped != "":
+			fmt.Printf("%-20s %12s %14s %14s  跳过: %s\n", r.Driver, "-", "-", "-", r.Skipped)
+		case r.Err != nil:
+			fmt.Printf("%-20s %12s %14s %14s  失败: %v\n", r.Driver, "-", "-", "-", r.Err)
+		default:
+			fmt.Printf("%-20s %12s %14s %14s  正常\n", r.Driver, r.Result.Latency.Round(time.Millisecond),
+				mbps(r.Result.UploadBandwidth), mbps(r.Result.DownloadBandwidth))
+		}
 	}
+}
+
+// 显示各驱动器的调度指标。统计窗口内的操作只在本进程内累计，先做一次健康探测使每个驱动器至少有一个样本
+func handleStatus(rs *scheduler.RAIDScheduler) {
+	rs.CheckHealth()
 	
-	// 确保输出目录存在
-	if err := os.MkdirAll(outputPath, 0755); err != nil {
-		return fmt.Errorf("创建输出目录失败: %v", err)
+	ms := func(d time.Duration) string { return d.Round(time.Millisecond).String() }
+	fmt.Printf("%-20s %-9s %8s %12s %10s %10s %10s %6s %12s  %s\n", "驱动器", "状态", "成功率", "成功/失败", "p50", "p95", "p99", "传输", "上传(MB/s)", "最近错误")
+	var unhealthy []string
+	for _, m := range rs.Metrics() {
+		ops := m.Operations
+		lastError := "-"
+		if !m.LastErrorTime.IsZero() {
+			lastError = m.LastErrorTime.Format("01-02 15:04:05")
+		}
+		if m.Health != scheduler.HealthHealthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s(%s)", m.Name, m.Health))
+		}
+		fmt.Printf("%-20s %-9s %7.1f%% %12s %10s %10s %10s %6s %12.2f  %s\n", m.Name, m.Health, ops.SuccessRate*100,
+			fmt.Sprintf("%d/%d", ops.Successes, ops.Failures), ms(ops.P50), ms(ops.P95), ms(ops.P99),
+			fmt.Sprintf("%d/%d", m.CurrentLoad, m.MaxLoad), m.UploadBandwidth/(1024*1024), lastError)
+	}
+	fmt.Printf("统计最近 %v 内的上传、下载、健康探测和传输探测\n", scheduler.StatsWindow)
+	if len(unhealthy) > 0 {
+		sort.Strings(unhealthy)
+		fmt.Printf("阵列处于降级状态，不健康的驱动器: %s\n", strings.Join(unhealthy, ", "))
 	}
+	if draining := rs.Draining(); len(draining) > 0 {
+		fmt.Printf("排空中的驱动器（不放置新数据）: %s\n", strings.Join(draining, ", "))
+	}
+}
+
+// 列出驱动器的类型、状态和容量
+func handleDrivers(rs *scheduler.RAIDScheduler, storageDrivers map[string]drivers.StorageDriver, driverTypes map[string]string) {
+	states := driverStates(rs)
+	names := make([]string, 0, len(storageDrivers))
+	for name := range storageDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 	
-	// 写入文件
-	if err := os.WriteFile(outputPath, data, 0644); err != nil {
-		return fmt.Errorf("写入文件失败: %v", err)
+	fmt.Printf("%-20s %-12s %-10s %12s %12s\n", "驱动器", "类型", "状态", "已用(GB)", "总量(GB)")
+	for _, name := range names {
+		state := states[name]
+		if state == "" {
+			state = "-"
+		}
+		used, total, err := storageDrivers[name].GetUsage()
+		if err != nil {
+			fmt.Printf("%-20s %-12s %-10s %12s %12s  %v\n", name, driverTypes[name], state, "-", "-", err)
+			continue
+		}
+		gb := func(n int64) float64 { return float64(n) / (1024 * 1024 * 1024) }
+		fmt.Printf("%-20s %-12s %-10s %12.2f %12.2f\n", name, driverTypes[name], state, gb(used), gb(total))
+	}
+}
+
+// 各驱动器的健康状态，排空中的驱动器为draining
+func driverStates(rs *scheduler.RAIDScheduler) map[string]string {
+	states := make(map[string]string)
+	for _, m := range rs.Metrics() {
+		states[m.Name] = m.Health
+	}
+	for _, name := range rs.Draining() {
+		states[name] = "draining"
+	}
+	return states
+}
+
+// 显示一次假设的写入的调度决策
+func handleExplain(rs *scheduler.RAIDScheduler, fileName string, size int64, raidLevel int) {
+	ex := rs.Explain(fileName, size, raidLevel)
+	strategy := ex.Strategy
+	if strategy == "" {
+		strategy = "默认"
 	}
+	fmt.Printf("写入 %.2f MB 的文件（RAID%d，调度模式 %s，评分策略 %s）\n", float64(ex.FileSize)/(1024*1024), ex.RAIDLevel, ex.Mode, strategy)
 	
-	duration := time.Since(startTime)
-	speed := float64(len(data)) / duration.Seconds() / (1024 * 1024) // MB/s
+	fmt.Printf("%-20s %8s %10s %8s %6s %14s %14s  %s\n", "驱动器", "评分", "延迟", "成功率", "负载", "剩余空间(GB)", "计划放置(MB)", "说明")
+	for _, d := range ex.Drivers {
+		remaining := "未知"
+		if d.Remaining >= 0 {
+			remaining = fmt.Sprintf("%.2f", float64(d.Remaining)/(1<<30))
+		}
+		reason := strings.Join(d.Reasons, "；")
+		if d.Bytes > 0 && reason == "" {
+			reason = "选中"
+		}
+		fmt.Printf("%-20s %8.3f %10s %7.0f%% %6d %14s %14.2f  %s\n", d.Driver, d.Score, d.Metrics.AvgLatency.Round(time.Millisecond),
+			d.Metrics.SuccessRate*100, d.Metrics.CurrentLoad, remaining, float64(d.Bytes)/(1024*1024), reason)
+	}
 	
-	fmt.Printf("下载成功! 保存到: %s\n", outputPath)
-	fmt.Printf("耗时: %.2f秒, 平均速度: %.2f MB/s\n", duration.Seconds(), speed)
+	if ex.Err != nil {
+		fmt.Printf("无法放置: %v\n", ex.Err)
+		return
+	}
+	stripes := 0
+	if ex.Plan.StripeSize > 0 {
+		stripes = int((ex.FileSize + ex.Plan.StripeSize - 1) / ex.Plan.StripeSize)
+	}
+	fmt.Printf("放置计划: %d 个条带, %d 个条带块, 预计每月费用 %.4f\n", stripes, len(ex.Plan.Placements), ex.Plan.MonthlyCost)
+	for _, w := range ex.Plan.Warnings {
+		fmt.Printf("警告: %s\n", w)
+	}
+}
+
+// 按各驱动器上PanMatrix放置的数据量估算每月的费用
+func handleCostReport(mm *metadata.MetadataManager, rs *scheduler.RAIDScheduler) error {
+	usage, err := mm.DriverCapacity()
+	if err != nil {
+		return err
+	}
+	placed := make(map[string]int64, len(usage))
+	for _, c := range usage {
+		placed[c.Driver] = c.Placed
+	}
 	
+	report := rs.CostReport(placed)
+	fmt.Printf("调度模式: %s, 每月下载量按存储量的 %.0f%% 估算\n", report.Mode, report.MonthlyReads*100)
+	fmt.Printf("%-20s %12s %12s %12s %12s %12s\n", "驱动器", "已放置(GB)", "存储", "流量", "请求", "合计")
+	for _, d := range report.Drivers {
+		if !d.Configured {
+			fmt.Printf("%-20s %12.2f %12s %12s %12s %12s\n", d.Driver, float64(d.Placed)/(1<<30), "-", "-", "-", "未配置")
+			continue
+		}
+		fmt.Printf("%-20s %12.2f %12.4f %12.4f %12.4f %12.4f\n", d.Driver, float64(d.Placed)/(1<<30), d.Storage, d.Egress, d.Requests, d.Total())
+	}
+	fmt.Printf("每月合计: %.4f\n", report.Total())
 	return nil
 }
 
-func startInteractive(rc *raid.RAIDController, mm *metadata.MetadataManager, rs *scheduler.RAIDScheduler) {
-	fmt.Println("=== PanMatrix RAID-over-Cloud 系统 ===")
-	fmt.Println("1. 上传文件")
-	fmt.Println("2. 下载文件")
-	fmt.Println("3. 列出文件")
-	fmt.Println("4. 系统状态")
-	fmt.Println("5. 退出")
+// 逐行输出元数据变更事件，ctx取消后返回
+func handleEvents(ctx context.Context, mm *metadata.MetadataManager, types string) {
+	var filter []string
+	for _, t := range strings.Split(types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter = append(filter, t)
+		}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for event := range mm.Subscribe(ctx, filter...) {
+		if err := enc.Encode(event); err != nil {
+			log.Printf("警告: %v", err)
+		}
+	}
+}
+
+// 检查块的引用计数，repair时修正有偏差的计数
+func handleCheckRefs(mm *metadata.MetadataManager, repair bool) error {
+	report, err := mm.CheckChunkRefs(repair)
+	if err != nil {
+		return err
+	}
 	
-	//Todo web 
+	fmt.Printf("共 %d 个文件, 引用 %d 个块, 其中 %d 个块被多个文件共用\n", report.Files, report.Chunks, report.Shared)
+	for _, d := range report.Drift {
+		fmt.Printf("偏差: %s/%s 计数 %d, 实际 %d\n", d.Driver, d.StorageID, d.Counted, d.Actual)
+	}
+	switch {
+	case len(report.Drift) == 0:
+		fmt.Println("引用计数一致")
+	case report.Repaired:
+		fmt.Printf("已修正 %d 个块的引用计数\n", len(report.Drift))
+	default:
+		fmt.Printf("%d 个块的引用计数有偏差，可用 check-refs -repair 修正\n", len(report.Drift))
+	}
+	return nil
+}
+
+// 列出各用户的文件数、用量和配额，不属于任何用户的文件显示为 -
+func handleListUsers(mm *metadata.MetadataManager) {
+	for _, u := range mm.UserUsage() {
+		owner, quota := u.Owner, "不限"
+		if owner == "" {
+			owner = "-"
+		}
+		if u.Quota > 0 {
+			quota = fmt.Sprintf("%.2f MB", float64(u.Quota)/(1024*1024))
+		}
+		fmt.Printf("%-20s %8d 个文件 %12.2f MB  配额 %s\n", owner, u.Files, float64(u.Bytes)/(1024*1024), quota)
+	}
+}
+
+// 按条件列出审计日志，最新的在最后
+func handleAudit(audit *metadata.AuditLog, user, ops, since string, limit int) error {
+	q := metadata.AuditQuery{User: user, Limit: limit}
+	if ops != "" {
+		for _, op := range strings.Split(ops, ",") {
+			if op = strings.TrimSpace(op); op != "" {
+				q.Ops = append(q.Ops, op)
+			}
+		}
+	}
+	if since != "" {
+		t, err := parseSince(since, time.Now())
+		if err != nil {
+			return err
+		}
+		q.Since = t
+	}
 	
-	fmt.Println("交互式界面待实现...")
+	entries, err := audit.Query(q)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		target := e.Target
+		if e.FileID != "" && e.FileID != e.Target {
+			target += " (" + e.FileID + ")"
+		}
+		line := fmt.Sprintf("%s  %-12s %-10s %-5s %12d  %s", e.Time.Format("2006-01-02 15:04:05"),
+			e.User, e.Op, e.Result, e.Bytes, target)
+		if e.Error != "" {
+			line += "  " + e.Error
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	return nil
+}
+
+// 解析 24h、7d 形式的时长（距now之前）或 2006-01-02 形式的日期
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %s（应为 24h、7d 或 2006-01-02）", value)
+}
+
+// 交互登录drives段中的一个驱动器，完成后验证连接
+func handleLogin(audit *metadata.AuditLog, name string) (err error) {
+	defer func() { recordAudit(audit, metadata.AuditEntry{Op: metadata.AuditLogin, Target: name}, err) }()
+	
+	specs, err := drivers.LoadDriveSpecs(configPath)
+	if err != nil {
+		return err
+	}
+	
+	for _, spec := range specs {
+		if spec.Name != name {
+			continue
+		}
+		
+		driver, err := drivers.NewDriver(spec)
+		if err != nil {
+			return err
+		}
+		
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := drivers.Login(ctx, driver, os.Stdout); err != nil {
+			if errors.Is(err, drivers.ErrLoginUnsupported) {
+				return fmt.Errorf("%s 类型的驱动器不支持交互登录，请在配置中填写令牌", spec.Type)
+			}
+			return err
+		}
+		if err := driver.Connect(); err != nil {
+			return fmt.Errorf("登录成功但连接失败: %v", err)
+		}
+		
+		fmt.Printf("登录成功! 驱动器 %s 的令牌已保存\n", name)
+		return nil
+	}
+	
+	return fmt.Errorf("drives中没有名为 %s 的驱动器", name)
+}
+
+// 上传文件或目录。目录上传到 dir/<目录名> 下，保留原有的子目录结构
+func handleUploadPath(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, ns *metadata.Namespace,
+	rs *scheduler.RAIDScheduler, localPath, dir string, tags []string, raidLevel int) error {
+	
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %v", err)
+	}
+	if !info.IsDir() {
+		return handleUpload(ctx, rc, mm, ns, rs, localPath, dir, tags, raidLevel)
+	}
+	
+	root := path.Join(metadata.CleanPath(dir), filepath.Base(filepath.Clean(localPath)))
+	if err := ns.Mkdir(root, true); err != nil && !errors.Is(err, metadata.ErrDirsUnsupported) {
+		return err
+	}
+	return filepath.WalkDir(localPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		target := path.Join(root, filepath.ToSlash(rel))
+		if d.IsDir() {
+			// 空目录也保留下来；后端不支持保存目录时只保留包含文件的目录
+			if err := ns.Mkdir(target, true); err != nil && !errors.Is(err, metadata.ErrDirsUnsupported) {
+				return err
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			fmt.Printf("跳过非普通文件: %s\n", p)
+			return nil
+		}
+		return handleUpload(ctx, rc, mm, ns, rs, p, path.Dir(target), tags, raidLevel)
+	})
+}
+
+func handleUpload(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, ns *metadata.Namespace,
+	rs *scheduler.RAIDScheduler, filePath, dir string, tags []string, raidLevel int) (err error) {
+	
+	fileName := filepath.Base(filePath)
+	audit := metadata.AuditEntry{Op: metadata.AuditUpload, Target: path.Join(metadata.CleanPath(dir), fileName)}
+	defer func() { recordAudit(mm.Audit(), audit, err) }()
+	
+	if ns.DirExists(path.Join(metadata.CleanPath(dir), fileName)) {
+		return fmt.Errorf("已存在同名目录: %s", path.Join(metadata.CleanPath(dir), fileName))
+	}
+	
+	// 读取文件
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %v", err)
+	}
+	// 排队的数据过多时等待，不同时把大量文件读入内存
+	admitted, err := rs.Admit(ctx, info.Size())
+	if err != nil {
+		return err
+	}
+	defer admitted()
+	data, hash, err := readFileHashed(filePath)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %v", err)
+	}
+	audit.Bytes = int64(len(data))
+	
+	fmt.Printf("开始上传文件: %s (大小: %.2f MB)\n", 
+		filePath, float64(len(data))/(1024*1024))
+	
+	startTime := time.Now()
+	
+	upload := fileUpload{Name: fileName, Dir: dir, Tags: tags, Data: data, Hash: hash, ModTime: info.ModTime(), Mode: info.Mode().Perm()}
+	fm, err := storeFile(ctx, rc, mm, ns, rs, upload, raidLevel, uploadHooks{
+		Planned: func(plan *scheduler.PlacementPlan) {
+			for _, warning := range plan.Warnings {
+				fmt.Printf("警告: %s\n", warning)
+			}
+			if plan.MonthlyCost > 0 {
+				fmt.Printf("预计每月费用增加: %.4f\n", plan.MonthlyCost)
+			}
+		},
+		Resumed: func(fileID string, startStripe int) {
+			fmt.Printf("续传文件 %s: 从条带%d继续\n", fileID, startStripe)
+		},
+	})
+	if fm != nil {
+		audit.FileID = fm.FileID
+	}
+	if err != nil {
+		return err
+	}
+	
+	duration := time.Since(startTime)
+	speed := float64(len(data)) / duration.Seconds() / (1024 * 1024) // MB/s
+	
+	fmt.Printf("上传成功! 文件ID: %s\n", fm.FileID)
+	fmt.Printf("耗时: %.2f秒, 平均速度: %.2f MB/s\n", duration.Seconds(), speed)
+	fmt.Printf("RAID级别: %d, 条带大小: %d字节\n", raidLevel, rc.StripeSize)
+	
+	return nil
+}
+
+// 待写入阵列的文件，内容已经读入内存并计算了SHA-256
+type fileUpload struct {
+	Name    string
+	Dir     string
+	Tags    []string
+	Data    []byte
+	Hash    string
+	ModTime time.Time
+	Mode    os.FileMode
+}
+
+// 写入过程中的通知，CLI用来显示进度，不需要的回调可以为nil
+type uploadHooks struct {
+	Planned func(plan *scheduler.PlacementPlan) // 规划完成、开始写入之前
+	Resumed func(fileID string, startStripe int) // 从中断的上传续传时
+}
+
+// 规划条带放置、写入阵列并提交元数据。
+// 写入阵列之后出错时同时返回未提交的文件元数据
+func storeFile(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, ns *metadata.Namespace,
+	rs *scheduler.RAIDScheduler, upload fileUpload, raidLevel int, hooks uploadHooks) (*metadata.FileMetadata, error) {
+	
+	data := upload.Data
+	// 放置计划按raidLevel划分条带块，必须与阵列一致
+	if raid.RAIDLevel(raidLevel) != rc.Level() {
+		return nil, fmt.Errorf("RAID级别%d与阵列的RAID级别%d不一致", raidLevel, rc.Level())
+	}
+	// 配额预留到文件元数据保存为止，之后文件大小已计入用量
+	releaseQuota, err := ns.ReserveQuota(int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	defer releaseQuota()
+	
+	// 上传前按当前配额规划条带放置，空间不足时立即失败而不是上传到一半
+	plan, err := rs.ReserveFile(path.Join(metadata.CleanPath(upload.Dir), upload.Name), int64(len(data)), raidLevel)
+	if err != nil {
+		return nil, fmt.Errorf("空间规划失败: %v", err)
+	}
+	defer rs.ReleaseReservation(plan)
+	if hooks.Planned != nil {
+		hooks.Planned(plan)
+	}
+	
+	// 使用RAID控制器写入文件
+	// 按计划写入，条带块实际放置的驱动器与规划和预留的一致
+	fileID, err := rc.WriteFile(ctx, data, raid.WriteOptions{Placement: plan, OnResume: hooks.Resumed})
+	if err != nil {
+		return nil, fmt.Errorf("RAID写入失败: %v", err)
+	}
+	
+	// 创建并保存元数据
+	fm := &metadata.FileMetadata{
+		FileID:      fileID,
+		FileName:    upload.Name,
+		Dir:         upload.Dir,
+		Tags:        upload.Tags,
+		Owner:       ns.Owner(),
+		FileSize:    int64(len(data)),
+		RAIDLevel:   raidLevel,
+		StripeSize:  rc.StripeSize,
+		StripeCount: int((int64(len(data)) + rc.StripeSize - 1) / rc.StripeSize),
+		Stripes:     rc.StripeLayout(fileID),
+		State:       metadata.FileStatePending,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+		Hash:        upload.Hash,
+		MimeType:    detectMimeType(upload.Name, data),
+		ModTime:     upload.ModTime,
+		Mode:        upload.Mode,
+	}
+	
+	err = mm.SaveFileMetadata(fm)
+	releaseQuota()
+	if err != nil {
+		return fm, fmt.Errorf("保存元数据失败: %v", err)
+	}
+	
+	// 所有条带校验通过后才写入提交标记，文件从此可见
+	if err := rc.VerifyLayout(fileID, int64(len(data))); err != nil {
+		return fm, fmt.Errorf("条带校验失败，文件未提交: %v", err)
+	}
+	if err := mm.CommitFile(fileID); err != nil {
+		return fm, fmt.Errorf("提交文件失败: %v", err)
+	}
+	
+	// 提交时才确定版本号，返回提交后的记录
+	if committed, err := mm.GetFileMetadata(fileID); err == nil {
+		return committed, nil
+	}
+	return fm, nil
+}
+
+// 重新放置条带时按文件路径调用调度器：重新条带化的文件与上传时一样规划放置并预留空间，
+// 迁空驱动器时迁出的块遵守文件固定规则和故障域
+type placementPlanner struct {
+	rc *raid.RAIDController
+	mm *metadata.MetadataManager
+	rs *scheduler.RAIDScheduler
+}
+
+func (p placementPlanner) PlanFile(fileID string, size int64) (raid.Placement, func(), error) {
+	plan, err := p.rs.ReserveFile(p.filePath(fileID), size, int(p.rc.Level()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan, func() { p.rs.ReleaseReservation(plan) }, nil
+}
+
+func (p placementPlanner) SelectReplacement(fileID string, size int64, peers, exclude []string) (string, error) {
+	return p.rs.SelectReplacement(p.filePath(fileID), int(p.rc.Level()), size, peers, exclude)
+}
+
+// 文件的虚拟路径，用于匹配文件固定规则；元数据中没有该文件时不使用固定规则
+func (p placementPlanner) filePath(fileID string) string {
+	if fm, err := p.mm.GetFileMetadataIncludingPending(fileID); err == nil {
+		return fm.Path()
+	}
+	return ""
+}
+
+// 读取文件内容，同时计算整个文件的SHA-256
+func readFileHashed(filePath string) ([]byte, string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	
+	hasher := sha256.New()
+	data, err := io.ReadAll(io.TeeReader(f, hasher))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// 按扩展名确定MIME类型，扩展名未知时按内容判断
+func detectMimeType(fileName string, data []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(fileName)); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+// 恢复上传时记录的权限和修改时间，旧版本没有记录的属性保持不变
+func restoreAttributes(outputPath string, meta *metadata.FileMetadata) error {
+	if meta.Mode != 0 {
+		// 已存在的文件不受WriteFile的权限参数影响
+		if err := os.Chmod(outputPath, meta.Mode); err != nil {
+			return err
+		}
+	}
+	if !meta.ModTime.IsZero() {
+		if err := os.Chtimes(outputPath, time.Now(), meta.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 本地空间不足时淘汰本地副本，并保存变化后的条带分布
+func evictLocalCopies(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager) {
+	affected, err := rc.EvictLocalCopies(ctx)
+	if err != nil {
+		log.Printf("警告: 淘汰本地副本失败: %v", err)
+	}
+	
+	saveLayouts(rc, mm, affected)
+	
+	if len(affected) > 0 {
+		fmt.Printf("已淘汰 %d 个文件的本地副本\n", len(affected))
+	}
+}
+
+// 固定指定文件的本地副本，文件名匹配所有同名文件
+func pinLocalCopies(rc *raid.RAIDController, mm *metadata.MetadataManager, list string) {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		
+		ids := mm.FindFileIDsByName(name)
+		if _, err := mm.GetFileMetadataIncludingPending(name); err == nil {
+			ids = append(ids, name)
+		}
+		if len(ids) == 0 {
+			log.Printf("警告: 要固定的文件不存在: %s", name)
+		}
+		for _, id := range ids {
+			rc.PinLocalCopies(id, true)
+		}
+	}
+}
+
+// 将所有文件的条带分布加载到RAID控制器，返回文件ID列表
+func loadAllLayouts(rc *raid.RAIDController, mm *metadata.MetadataManager) []string {
+	var fileIDs []string
+	for _, fm := range mm.ListAllFileMetadata() {
+		rc.LoadLayout(fm.FileID, fm.Stripes)
+		fileIDs = append(fileIDs, fm.FileID)
+	}
+	return fileIDs
+}
+
+// 列出进行中的上传，中断的上传重新上传同一文件时从已提交的条带继续
+func handleListUploads(mm *metadata.MetadataManager) error {
+	uploads, err := mm.ListUploads()
+	if err != nil {
+		return err
+	}
+	if len(uploads) == 0 {
+		fmt.Println("没有进行中的上传")
+		return nil
+	}
+	
+	for _, p := range uploads {
+		percent := 100.0
+		if p.FileSize > 0 {
+			percent = float64(p.BytesCommitted) * 100 / float64(p.FileSize)
+		}
+		started := "-"
+		if !p.StartedAt.IsZero() {
+			started = p.StartedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-46s %12d/%-12d %5.1f%%  条带 %d  开始于 %s  更新于 %s\n", p.FileID, p.BytesCommitted, p.FileSize,
+			percent, p.CompletedStripes, started, p.UpdatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// 将RAID控制器中变化的条带分布写回元数据
+func saveLayouts(rc *raid.RAIDController, mm *metadata.MetadataManager, fileIDs []string) {
+	var updated []*metadata.FileMetadata
+	seen := make(map[string]bool)
+	for _, fileID := range fileIDs {
+		if seen[fileID] {
+			continue
+		}
+		seen[fileID] = true
+		fm, err := mm.GetFileMetadataIncludingPending(fileID)
+		if err != nil {
+			log.Printf("警告: 获取文件元数据失败 %s: %v", fileID, err)
+			continue
+		}
+		copied := *fm
+		copied.Stripes = rc.StripeLayout(fileID)
+		updated = append(updated, &copied)
+	}
+	if err := mm.SaveFileMetadataBatch(updated); err != nil {
+		log.Printf("警告: 保存文件元数据失败: %v", err)
+	}
+}
+
+// 在RAID控制器和调度器中设置驱动器的排空状态
+func setDraining(rc *raid.RAIDController, rs *scheduler.RAIDScheduler, driverName string, draining bool) error {
+	if draining {
+		if err := rc.Drain(driverName); err != nil {
+			return err
+		}
+	} else if err := rc.Undrain(driverName); err != nil {
+		return err
+	}
+	return rs.SetDraining(driverName, draining)
+}
+
+// 排空或取消排空驱动器，状态保存在元数据中，之后的运行继续生效
+func handleDrain(rc *raid.RAIDController, rs *scheduler.RAIDScheduler, mm *metadata.MetadataManager, drain, undrain string) error {
+	if drain != "" {
+		if err := setDraining(rc, rs, drain, true); err != nil {
+			return err
+		}
+		mm.SetDriverDraining(drain, true)
+		fmt.Printf("驱动器 %s 已排空，不再放置新数据；用 evacuate %s 迁移已有的数据\n", drain, drain)
+	}
+	if undrain != "" {
+		if err := setDraining(rc, rs, undrain, false); err != nil {
+			return err
+		}
+		mm.SetDriverDraining(undrain, false)
+		fmt.Printf("驱动器 %s 已取消排空\n", undrain)
+	}
+	return nil
+}
+
+// 迁空驱动器，完成后在元数据中标记为可移除
+func handleEvacuate(ctx context.Context, h *jobs.Handle, rc *raid.RAIDController, mm *metadata.MetadataManager,
+	storageDrivers map[string]drivers.StorageDriver, driverName string) (err error) {
+	
+	audit := metadata.AuditEntry{Op: metadata.AuditEvacuate, Target: driverName}
+	defer func() { recordAudit(mm.Audit(), audit, err) }()
+	
+	loadAllLayouts(rc, mm)
+	
+	fmt.Printf("开始迁空驱动器: %s\n", driverName)
+	h.SetProgress(0, 0, "迁空驱动器 "+driverName)
+	report, err := rc.Evacuate(ctx, driverName)
+	if report != nil {
+		// 即使中途失败，也保存已迁移部分的条带分布
+		saveLayouts(rc, mm, report.AffectedFiles)
+		audit.Bytes = report.BytesMoved
+	}
+	if err != nil {
+		return err
+	}
+	
+	var used, total int64
+	if driver, ok := storageDrivers[driverName]; ok {
+		used, total, _ = driver.GetUsage()
+	}
+	mm.UpdateDriverHealth(driverName, "removable", used, total)
+	
+	fmt.Printf("迁空完成! 复制 %d 块, 重建 %d 块, 丢弃本地副本 %d 块, 涉及 %d 个文件\n",
+		report.MovedStrips, report.RebuiltStrips, report.DroppedCopies, len(report.AffectedFiles))
+	fmt.Printf("驱动器 %s 现在可以安全移除\n", driverName)
+	h.SetResult(fmt.Sprintf("复制 %d 块, 重建 %d 块, 涉及 %d 个文件, %s 可以安全移除",
+		report.MovedStrips, report.RebuiltStrips, len(report.AffectedFiles), driverName))
+	
+	return nil
+}
+
+func handleDownload(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, ns *metadata.Namespace,
+	rs *scheduler.RAIDScheduler, fileID, outputPath string) (err error) {
+	
+	audit := metadata.AuditEntry{Op: metadata.AuditDownload, Target: fileID}
+	defer func() { recordAudit(mm.Audit(), audit, err) }()
+	
+	fmt.Printf("开始下载文件: %s\n", fileID)
+	
+	startTime := time.Now()
+	
+	// 获取文件元数据以确定文件名和条带分布，也支持按文件名下载（取最新的同名文件）
+	meta, metaErr := findFile(ns, fileID)
+	if errors.Is(metaErr, metadata.ErrFileNotCommitted) {
+		return metaErr
+	}
+	if metaErr == nil {
+		fileID = meta.FileID
+		rc.LoadLayout(fileID, meta.Stripes)
+		audit.Target, audit.FileID = meta.Path(), fileID
+		
+		admitted, err := rs.Admit(ctx, meta.FileSize)
+		if err != nil {
+			return err
+		}
+		defer admitted()
+	}
+	
+	// 使用RAID控制器读取文件，有元数据时校验整个文件的哈希
+	var hash string
+	if metaErr == nil {
+		hash = meta.Hash
+	}
+	data, err := rc.ReadFileVerified(ctx, fileID, hash)
+	if err != nil {
+		if errors.Is(err, drivers.ErrRestoreInProgress) {
+			fmt.Println("提示: 降级读取需要的校验块存放在归档存储中，恢复通常需要数小时，完成后重新下载即可")
+		}
+		if errors.Is(err, raid.ErrIntegrity) {
+			// 不写出内容已损坏的文件
+			fmt.Println("提示: 可以用 check-meta -deep 找出损坏的条带块，加 -heal 通过冗余修复")
+			return err
+		}
+		return fmt.Errorf("RAID读取失败: %w", err)
+	}
+	audit.Bytes = int64(len(data))
+	
+	if metaErr != nil {
+		// 如果无法获取元数据，使用文件ID作为文件名
+		outputPath = filepath.Join(outputPath, fileID+".download")
+	} else {
+		outputPath = filepath.Join(outputPath, filepath.Base(meta.FileName))
+	}
+	
+	// 确保输出目录存在
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	
+	// 写入文件
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	if metaErr == nil {
+		if err := restoreAttributes(outputPath, meta); err != nil {
+			log.Printf("警告: 恢复文件属性失败: %v", err)
+		}
+	}
+	
+	duration := time.Since(startTime)
+	speed := float64(len(data)) / duration.Seconds() / (1024 * 1024) // MB/s
+	
+	fmt.Printf("下载成功! 保存到: %s\n", outputPath)
+	fmt.Printf("耗时: %.2f秒, 平均速度: %.2f MB/s\n", duration.Seconds(), speed)
+	
+	return nil
+}
+
+// 按文件ID或文件名（取最新的同名文件）查找已提交的文件
+func findFile(ns *metadata.Namespace, ref string) (*metadata.FileMetadata, error) {
+	meta, err := ns.GetFileMetadata(ref)
+	if err != nil {
+		byName, nameErr := ns.GetByName(ref)
+		if nameErr != nil {
+			return nil, err
+		}
+		meta = byName
+	}
+	return meta, nil
+}
+
+// 提前提交归档条带块的恢复请求，驱动器故障后可以尽快进行降级读取
+func handleRestore(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, fileID string) error {
+	meta, err := mm.GetFileMetadata(fileID)
+	if err != nil {
+		byName, nameErr := mm.GetByName(fileID)
+		if nameErr != nil {
+			return err
+		}
+		fileID, meta = byName.FileID, byName
+	}
+	rc.LoadLayout(fileID, meta.Stripes)
+	
+	report, err := rc.RestoreFile(ctx, fileID)
+	recordAudit(mm.Audit(), metadata.AuditEntry{Op: metadata.AuditRestore, Target: meta.Path(), FileID: fileID}, err)
+	if err != nil {
+		return err
+	}
+	
+	fmt.Printf("已为 %s 提交 %d 个条带块的恢复请求\n", meta.FileName, report.Requested)
+	for _, failure := range report.Failed {
+		fmt.Printf("警告: %s\n", failure)
+	}
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("%d 个条带块提交失败", len(report.Failed))
+	}
+	return nil
+}
+
+// 删除文件：已提交的文件移入回收站，条带块保留到过期后清理。未提交的文件、回收站中的文件
+// 以及未启用回收站时直接删除
+func handleDelete(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, ns *metadata.Namespace, fileID string) error {
+	meta, report, err := deleteFile(ctx, rc, mm, ns, fileID, func(meta *metadata.FileMetadata) {
+		fmt.Printf("开始删除文件: %s (%s)\n", meta.FileName, meta.FileID)
+	})
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		fmt.Printf("已移入回收站: %s (%s)，%d天后清理，可用 untrash %s 恢复\n", meta.Path(), meta.FileID, mm.TrashConfig().KeepDays, meta.FileID)
+		return nil
+	}
+	
+	fmt.Printf("删除成功! 删除 %d 块, 已不存在 %d 块, 保留其他文件引用的 %d 块\n",
+		report.DeletedStrips, report.MissingStrips, report.SharedStrips)
+	for _, strip := range report.Unsupported {
+		fmt.Printf("警告: 驱动器不支持删除，需手动清理: %s\n", strip)
+	}
+	
+	return nil
+}
+
+// 删除文件ID或文件名指定的文件（同名文件取最新一次上传，未提交的文件也可删除）。移入回收站时
+// 返回的report为nil；直接删除时先调用purging
+func deleteFile(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager, ns *metadata.Namespace,
+	fileID string, purging func(*metadata.FileMetadata)) (*metadata.FileMetadata, *raid.DeleteReport, error) {
+	
+	meta, err := ns.GetFileMetadataIncludingPending(fileID)
+	if err != nil {
+		ids := ns.FindFileIDsByName(fileID)
+		if len(ids) == 0 {
+			return nil, nil, err
+		}
+		fileID = ids[len(ids)-1]
+		if meta, err = ns.GetFileMetadataIncludingPending(fileID); err != nil {
+			return nil, nil, err
+		}
+	}
+	
+	if keepDays := mm.TrashConfig().KeepDays; meta.IsCommitted() && keepDays > 0 {
+		trashed, err := mm.TrashFile(fileID)
+		recordAudit(mm.Audit(), metadata.AuditEntry{User: ns.Owner(), Op: metadata.AuditDelete, Target: meta.Path(), FileID: fileID, Bytes: meta.FileSize}, err)
+		if err != nil {
+			return nil, nil, err
+		}
+		return trashed, nil, nil
+	}
+	
+	if purging != nil {
+		purging(meta)
+	}
+	report, err := purgeFile(ctx, rc, mm, meta)
+	if err != nil {
+		return nil, nil, err
+	}
+	return meta, report, nil
+}
+
+// 永久删除文件：先删除所有驱动器上的条带块，全部成功后再删除元数据
+func purgeFile(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager,
+	fm *metadata.FileMetadata) (*raid.DeleteReport, error) {
+	
+	// 列表返回的元数据可能只有文件头，删除前读取完整的条带分布
+	full, err := mm.GetFileMetadataIncludingPending(fm.FileID)
+	if err != nil {
+		return nil, err
+	}
+	rc.LoadLayout(full.FileID, full.Stripes)
+	report, err := rc.DeleteFile(ctx, full.FileID)
+	if err == nil {
+		err = mm.DeleteFileMetadata(fm.FileID)
+	}
+	recordAudit(mm.Audit(), metadata.AuditEntry{Op: metadata.AuditPurge, Target: full.Path(), FileID: full.FileID, Bytes: full.FileSize}, err)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// 逐个永久删除文件，单个文件失败时继续删除其余文件
+func purgeFiles(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager,
+	files []*metadata.FileMetadata, kind string) error {
+	
+	var failed int
+	for _, fm := range files {
+		report, err := purgeFile(ctx, rc, mm, fm)
+		if err != nil {
+			log.Printf("警告: 删除%s %s (%s) 失败: %v", kind, fm.Path(), fm.FileID, err)
+			failed++
+			continue
+		}
+		fmt.Printf("已删除%s: %s (%s)\n", kind, fm.Path(), fm.FileID)
+		for _, strip := range report.Unsupported {
+			fmt.Printf("警告: 驱动器不支持删除，需手动清理: %s\n", strip)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个%s删除失败", failed, kind)
+	}
+	return nil
+}
+
+// 删除按保留策略过期的旧版本
+func handlePruneVersions(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager) error {
+	return purgeFiles(ctx, rc, mm, mm.ExpiredVersions(time.Now()), "过期的旧版本")
+}
+
+// 清理过期的回收站文件和旧版本，失败时只打印警告
+func purgeExpired(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager) {
+	now := time.Now()
+	if err := purgeFiles(ctx, rc, mm, mm.ExpiredTrash(now), "回收站中过期的文件"); err != nil {
+		log.Printf("警告: 清理回收站失败: %v", err)
+	}
+	if err := purgeFiles(ctx, rc, mm, mm.ExpiredVersions(now), "过期的旧版本"); err != nil {
+		log.Printf("警告: 清理旧版本失败: %v", err)
+	}
+}
+
+// 在后台按metadata.backups.interval定期备份元数据，未配置间隔时不启动
+func startBackups(ctx context.Context, mm *metadata.MetadataManager) {
+	backups := mm.Backups()
+	if backups == nil || backups.Interval() <= 0 {
+		return
+	}
+	ticker := time.NewTicker(backups.Interval())
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := mm.Backup(ctx); err != nil {
+					log.Printf("警告: 定期备份元数据失败: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// 列出元数据备份，最新的在前
+func handleListBackups(ctx context.Context, mm *metadata.MetadataManager) error {
+	backups, err := mm.Backups().List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups {
+		fmt.Printf("%-40s %-12s %s %10.2f KB\n", b.Name, b.Location,
+			b.CreatedAt.Format("2006-01-02 15:04:05"), float64(b.Size)/1024)
+	}
+	if len(backups) == 0 {
+		fmt.Println("没有元数据备份")
+	}
+	return nil
+}
+
+// 在后台按trash.purge_interval定期清理过期的回收站文件和旧版本，ctx取消后停止
+func startPurger(ctx context.Context, rc *raid.RAIDController, mm *metadata.MetadataManager) {
+	ticker := time.NewTicker(mm.TrashConfig().PurgeInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purgeExpired(ctx, rc, mm)
+			}
+		}
+	}()
+}
+
+// 列出路径上的所有版本，最新的在前
+func handleVersions(ns *metadata.Namespace, filePath string) error {
+	versions, err := ns.ListVersions(filePath)
+	if err != nil {
+		return err
+	}
+	
+	for _, v := range versions {
+		status := "当前"
+		if !v.IsCurrent() {
+			status = "替换于 " + v.SupersededAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("v%-4d %-36s %12d  %s  %s\n", v.Version, v.FileID, v.FileSize,
+			v.CreatedAt.Format("2006-01-02 15:04:05"), status)
+	}
+	return nil
+}
+
+func splitTags(list string) []string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// 添加或删除文件的标签，文件可以用文件ID、文件名或路径指定（同名文件取最新一次上传）
+func handleTags(mm *metadata.MetadataManager, ns *metadata.Namespace, tagFile, untagFile, list string) error {
+	tags := splitTags(list)
+	if len(tags) == 0 {
+		return errors.New("需要指定标签")
+	}
+	
+	ref, update := tagFile, mm.AddTags
+	if untagFile != "" {
+		ref, update = untagFile, mm.RemoveTags
+	}
+	fileID := ref
+	if _, err := ns.GetFileMetadata(fileID); err != nil {
+		ids := ns.FindFileIDsByName(ref)
+		if len(ids) == 0 {
+			return err
+		}
+		fileID = ids[len(ids)-1]
+	}
+	if err := update(fileID, tags...); err != nil {
+		return err
+	}
+	
+	fm, err := mm.GetFileMetadata(fileID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s 的标签: %s\n", fm.Path(), strings.Join(fm.Tags, ", "))
+	return nil
+}
+
+// 分页列出文件，search不为空时按搜索表达式查找（见metadata.ParseSearchQuery）
+func handleList(ns *metadata.Namespace, opts metadata.ListOptions, levels string, page int, search string) error {
+	for _, level := range strings.Split(levels, ",") {
+		if level = strings.TrimSpace(level); level == "" {
+			continue
+		}
+		n, err := strconv.Atoi(level)
+		if err != nil {
+			return fmt.Errorf("无效的RAID级别: %s", level)
+		}
+		opts.RAIDLevels = append(opts.RAIDLevels, n)
+	}
+	if page < 1 {
+		return fmt.Errorf("页码从1开始: %d", page)
+	}
+	opts.Offset = (page - 1) * opts.Limit
+	
+	var result *metadata.ListResult
+	var err error
+	if search != "" {
+		q, err := metadata.ParseSearchQuery(search)
+		if err != nil {
+			return err
+		}
+		if q.Dir == "" {
+			q.Dir = opts.Dir
+		}
+		q.Pattern = opts.Pattern
+		q.RAIDLevels = append(q.RAIDLevels, opts.RAIDLevels...)
+		q.SortBy, q.Desc, q.Offset, q.Limit = opts.SortBy, opts.Desc, opts.Offset, opts.Limit
+		result, err = ns.Search(q)
+		if err != nil {
+			return err
+		}
+	} else if result, err = ns.ListFiles(opts); err != nil {
+		return err
+	}
+	
+	// 列出目录时子目录显示在第一页的文件之前
+	if search == "" && opts.Dir != "" && page == 1 {
+		entries, err := ns.ListDir(opts.Dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir {
+				fmt.Printf("%s/\n", entry.Path)
+			}
+		}
+	}
+	
+	fmt.Printf("%-46s %-8s %12s  %-19s  %s\n", "文件ID", "RAID", "大小", "创建时间", "路径")
+	for _, fm := range result.Files {
+		var tags string
+		if len(fm.Tags) > 0 {
+			tags = "  [" + strings.Join(fm.Tags, ", ") + "]"
+		}
+		fmt.Printf("%-46s %-8s %12d  %-19s  %s%s\n", fm.FileID, fmt.Sprintf("RAID%d", fm.RAIDLevel),
+			fm.FileSize, fm.CreatedAt.Format("2006-01-02 15:04:05"), fm.Path(), tags)
+	}
+	if len(result.Files) == 0 {
+		fmt.Printf("第 %d 页没有文件，共 %d 个文件\n", page, result.Total)
+	} else {
+		fmt.Printf("第 %d-%d 个，共 %d 个文件\n", result.Offset+1, result.Offset+len(result.Files), result.Total)
+	}
+	if result.NextOffset() >= 0 {
+		fmt.Printf("下一页: -page %d\n", page+1)
+	}
+	return nil
+}
+
+// 元数据丢失且没有副本时，按条带块名称重建文件元数据。已有元数据的文件不受影响，
+// 上次重建时待确认的文件会重新检查
+func handleRebuildMetadata(ctx context.Context, h *jobs.Handle, rc *raid.RAIDController, mm *metadata.MetadataManager) (err error) {
+	defer func() { recordAudit(mm.Audit(), metadata.AuditEntry{Op: metadata.AuditRebuild}, err) }()
+	
+	known := func(fileID string) bool {
+		fm, err := mm.GetFileMetadataIncludingPending(fileID)
+		return err == nil && fm.State != metadata.FileStateReview
+	}
+	
+	h.SetProgress(0, 0, "扫描驱动器上的条带块")
+	files, report, err := rc.RebuildMetadata(ctx, known)
+	if err != nil {
+		return err
+	}
+	if err := mm.SaveFileMetadataBatch(files); err != nil {
+		return fmt.Errorf("保存文件元数据失败: %v", err)
+	}
+	
+	fmt.Printf("扫描 %d 个条带块, 重建 %d 个文件, 待确认 %d 个\n", report.Scanned, len(report.Rebuilt), len(report.Review))
+	for _, fileID := range report.Rebuilt {
+		fmt.Printf("  已重建: %s\n", fileID)
+	}
+	for _, issue := range report.Review {
+		fmt.Printf("  待确认: %s: %s\n", issue.FileID, issue.Reason)
+	}
+	for _, file := range report.Degraded {
+		fmt.Printf("警告: 通过校验块还原了缺失的数据块，建议修复: %s\n", file)
+	}
+	for _, layout := range report.Leftover {
+		fmt.Printf("警告: 未使用的旧布局，可手动清理: %s\n", layout)
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Printf("警告: 驱动器无法列举，已跳过: %s\n", skipped)
+	}
+	if len(report.Rebuilt) > 0 {
+		fmt.Println("文件名无法从条带块恢复，重建的文件以文件ID命名")
+	}
+	summary := fmt.Sprintf("重建 %d 个文件, 待确认 %d 个", len(report.Rebuilt), len(report.Review))
+	h.SetResult(summary)
+	mm.Publish(metadata.Event{Type: metadata.EventRebuildFinished, Detail: summary})
+	return nil
+}
+
+// 检查元数据与驱动器上的条带块是否一致，heal时通过冗余修复并保存新的条带记录
+func handleCheckMeta(ctx context.Context, h *jobs.Handle, rc *raid.RAIDController, mm *metadata.MetadataManager, deep, heal bool) (err error) {
+	fileIDs := loadAllLayouts(rc, mm)
+	h.SetProgress(0, int64(len(fileIDs)), "检查条带块")
+	
+	report, err := rc.CheckConsistency(ctx, fileIDs, raid.CheckOptions{Deep: deep, Heal: heal})
+	if report != nil && len(report.AffectedFiles) > 0 {
+		saveLayouts(rc, mm, report.AffectedFiles)
+		recordAudit(mm.Audit(), metadata.AuditEntry{Op: metadata.AuditHeal, Target: fmt.Sprintf("%d 个文件", len(report.AffectedFiles))}, err)
+	}
+	if err != nil {
+		return err
+	}
+	
+	if len(report.Listed) > 0 {
+		fmt.Printf("按列举结果检查: %s\n", strings.Join(report.Listed, ", "))
+	}
+	if len(report.Downloaded) > 0 {
+		fmt.Printf("逐块下载检查: %s\n", strings.Join(report.Downloaded, ", "))
+	}
+	printIssues := func(kind string, issues []raid.StripIssue) {
+		for _, issue := range issues {
+			status := ""
+			switch {
+			case issue.Healed:
+				status = " [已修复]"
+			case issue.HealError != "":
+				status = " [修复失败: " + issue.HealError + "]"
+			}
+			fmt.Printf("%s: %s 条带%d %s/%s: %s%s\n", kind, issue.FileID, issue.StripeIndex,
+				issue.Strip.DriverName, issue.Strip.StorageID, issue.Reason, status)
+		}
+	}
+	printIssues("缺失", report.Missing)
+	printIssues("损坏", report.Damaged)
+	printIssues("无法读取", report.Unreadable)
+	
+	summary := fmt.Sprintf("共 %d 个文件, %d 个条带块: 缺失 %d, 损坏 %d, 无法读取 %d",
+		report.Files, report.Strips, len(report.Missing), len(report.Damaged), len(report.Unreadable))
+	fmt.Println(summary)
+	h.SetProgress(int64(report.Files), int64(report.Files), "")
+	h.SetResult(summary)
+	switch {
+	case heal:
+		fmt.Printf("重写 %d 块, 丢弃本地副本记录 %d 条, 更新 %d 个文件的元数据\n",
+			report.Healed, report.DroppedCopies, len(report.AffectedFiles))
+	case len(report.Missing)+len(report.Damaged) > 0:
+		fmt.Println("可加 -heal 通过冗余数据修复")
+	}
+	return nil
+}
+
+// 将使用旧条带宽度的文件迁移到当前阵列宽度
+func handleRestripe(ctx context.Context, h *jobs.Handle, rc *raid.RAIDController, mm *metadata.MetadataManager, rateMB float64) error {
+	fileIDs := loadAllLayouts(rc, mm)
+	
+	job := rc.StartRestripe(ctx, fileIDs, raid.RestripeOptions{
+		MaxBytesPerSecond: int64(rateMB * 1024 * 1024),
+		OnFileDone: func(fileID string, stripes []metadata.StripeMetadata) {
+			fm, err := mm.GetFileMetadataIncludingPending(fileID)
+			if err != nil {
+				log.Printf("警告: 获取文件元数据失败 %s: %v", fileID, err)
+				return
+			}
+			updated := *fm
+			updated.Stripes = stripes
+			if err := mm.SaveFileMetadata(&updated); err != nil {
+				log.Printf("警告: 保存文件元数据失败 %s: %v", fileID, err)
+			}
+		},
+	})
+	
+	done := make(chan error, 1)
+	go func() { done <- job.Wait() }()
+	
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case err := <-done:
+			p := job.Progress()
+			summary := fmt.Sprintf("%d/%d 个文件, 失败 %d, 迁移 %.2f MB",
+				p.DoneFiles, p.TotalFiles, p.FailedFiles, float64(p.BytesMoved)/(1024*1024))
+			fmt.Printf("重新条带化完成: %s\n", summary)
+			h.SetProgress(int64(p.DoneFiles), int64(p.TotalFiles), "")
+			h.SetResult(summary)
+			recordAudit(mm.Audit(), metadata.AuditEntry{Op: metadata.AuditRestripe, Bytes: p.BytesMoved}, err)
+			return err
+		case <-ticker.C:
+			p := job.Progress()
+			fmt.Printf("重新条带化进度: %d/%d, 当前文件: %s\n", p.DoneFiles, p.TotalFiles, p.CurrentFile)
+			h.SetProgress(int64(p.DoneFiles), int64(p.TotalFiles), p.CurrentFile)
+		}
+	}
+}
+
+// 注册后台任务。任务中断后重新执行时从头开始，已完成的部分（已迁移的文件、已修复的块）不会重复处理。
+// 后台任务的优先级低于用户的上传和下载，巡检最低
+func registerJobs(jm *jobs.Manager, rc *raid.RAIDController, mm *metadata.MetadataManager, storageDrivers map[string]drivers.StorageDriver) {
+	jobContext := func(ctx context.Context, h *jobs.Handle, priority scheduler.Priority) context.Context {
+		ctx = scheduler.WithPriority(ctx, priority)
+		if deadline, err := time.Parse(time.RFC3339, h.Params()["deadline"]); err == nil {
+			ctx = scheduler.WithDeadline(ctx, deadline)
+		}
+		return ctx
+	}
+	
+	jm.Register("evacuate", func(ctx context.Context, h *jobs.Handle) error {
+		return handleEvacuate(jobContext(ctx, h, scheduler.PriorityRebuild), h, rc, mm, storageDrivers, h.Params()["driver"])
+	})
+	jm.Register("restripe", func(ctx context.Context, h *jobs.Handle) error {
+		rateMB, _ := strconv.ParseFloat(h.Params()["rate_mb"], 64)
+		return handleRestripe(jobContext(ctx, h, scheduler.PriorityRebuild), h, rc, mm, rateMB)
+	})
+	jm.Register("check-meta", func(ctx context.Context, h *jobs.Handle) error {
+		params := h.Params()
+		return handleCheckMeta(jobContext(ctx, h, scheduler.PriorityScrub), h, rc, mm, params["deep"] == "true", params["heal"] == "true")
+	})
+	jm.Register("rebuild-metadata", func(ctx context.Context, h *jobs.Handle) error {
+		return handleRebuildMetadata(jobContext(ctx, h, scheduler.PriorityRebuild), h, rc, mm)
+	})
+	jm.Register("gc", func(ctx context.Context, h *jobs.Handle) error {
+		purgeExpired(jobContext(ctx, h, scheduler.PriorityRebuild), rc, mm)
+		return ctx.Err()
+	})
+}
+
+// 执行后台任务并等待结束；background时只提交任务，由 jobs run 或常驻进程执行
+func runJob(ctx context.Context, jm *jobs.Manager, jobType string, params map[string]string, background bool) error {
+	if background {
+		job, err := jm.Submit(jobType, params)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已提交任务: %s (%s)\n", job.ID, job.Type)
+		return nil
+	}
+	
+	job, err := jm.Run(ctx, jobType, params)
+	if job.State == jobs.StateQueued {
+		fmt.Printf("任务 %s 已中断，可用 jobs run 继续执行\n", job.ID)
+	}
+	return err
+}
+
+// 列出、查看或控制后台任务
+func handleJobs(jm *jobs.Manager, list bool, show, cancel, pause, resume string) error {
+	switch {
+	case cancel != "":
+		if err := jm.Cancel(cancel); err != nil {
+			return err
+		}
+		fmt.Printf("已取消任务: %s\n", cancel)
+	case pause != "":
+		if err := jm.Pause(pause); err != nil {
+			return err
+		}
+		fmt.Printf("已暂停任务: %s\n", pause)
+	case resume != "":
+		if err := jm.Resume(resume); err != nil {
+			return err
+		}
+		fmt.Printf("已继续任务: %s\n", resume)
+	case show != "":
+		job, err := jm.Get(show)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(job, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case list:
+		fmt.Printf("%-24s %-18s %-10s %12s %-14s  %s\n", "任务", "类型", "状态", "进度", "更新时间", "信息")
+		for _, job := range jm.List() {
+			progress := "-"
+			if job.Progress.Total > 0 {
+				progress = fmt.Sprintf("%d/%d", job.Progress.Done, job.Progress.Total)
+			}
+			info := job.Progress.Message
+			switch {
+			case job.Error != "":
+				info = job.Error
+			case job.Result != "":
+				info = job.Result
+			}
+			fmt.Printf("%-24s %-18s %-10s %12s %-14s  %s\n", job.ID, job.Type, job.State, progress,
+				job.Updated.Format("01-02 15:04:05"), info)
+		}
+	}
+	return nil
 }
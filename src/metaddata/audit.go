@@ -0,0 +1,209 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 审计日志中的操作类型
+const (
+	AuditUpload     = "upload"
+	AuditDownload   = "download"
+	AuditDelete     = "delete"   // 移入回收站
+	AuditPurge      = "purge"    // 永久删除条带块和元数据
+	AuditUntrash    = "untrash"  // 从回收站恢复
+	AuditRestore    = "restore"  // 归档条带块的恢复请求
+	AuditRebuild    = "rebuild"  // 重建元数据
+	AuditRollback   = "rollback" // 元数据回滚到备份
+	AuditEvacuate   = "evacuate"
+	AuditRestripe   = "restripe"
+	AuditHeal       = "heal" // 一致性检查通过冗余修复条带块
+	AuditLogin      = "login"
+	AuditCredential = "credential" // 凭据保险库的修改
+)
+
+// 操作结果
+const (
+	AuditOK     = "ok"
+	AuditFailed = "error"
+)
+
+// 环境变量中的操作者名称，未设置时使用系统用户名
+const auditUserEnv = "PANMATRIX_USER"
+
+// 审计日志中的一条记录，每行一个JSON对象
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Op     string    `json:"op"`
+	Target string    `json:"target,omitempty"` // 文件路径或驱动器名
+	FileID string    `json:"file_id,omitempty"`
+	Result string    `json:"result"`
+	Error  string    `json:"error,omitempty"`
+	Bytes  int64     `json:"bytes,omitempty"`
+}
+
+// 只追加的操作审计日志（元数据目录下的audit.log），记录上传、下载、删除、重建和驱动器变更，
+// 供多人共用时查询谁在什么时候做了什么。多个进程可以同时追加，每条记录一次写入
+type AuditLog struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	user string
+}
+
+// 打开basePath下的审计日志，第一次记录时创建文件
+func OpenAuditLog(basePath string) *AuditLog {
+	return &AuditLog{path: filepath.Join(basePath, "audit.log"), user: defaultAuditUser()}
+}
+
+func defaultAuditUser() string {
+	if name := os.Getenv(auditUserEnv); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// 设置之后记录的操作者
+func (l *AuditLog) SetUser(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.user = name
+}
+
+// 追加一条记录并fsync。时间和操作者未填写时自动填写，结果由opErr决定
+func (l *AuditLog) Record(entry AuditEntry, opErr error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.User == "" {
+		entry.User = l.user
+	}
+	entry.Result = AuditOK
+	if opErr != nil {
+		entry.Result = AuditFailed
+		entry.Error = opErr.Error()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化审计记录失败: %v", err)
+	}
+	if l.file == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+			return fmt.Errorf("创建元数据目录失败: %v", err)
+		}
+		if l.file, err = os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return fmt.Errorf("打开审计日志失败: %v", err)
+		}
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入审计日志失败: %v", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("写入审计日志失败: %v", err)
+	}
+	return nil
+}
+
+// 审计日志的查询条件，为空的条件不限制
+type AuditQuery struct {
+	Since  time.Time
+	Until  time.Time
+	User   string
+	Ops    []string
+	Target string // 目标路径、驱动器名或文件ID中包含该字符串
+	Failed bool   // 只返回失败的操作
+	Limit  int    // 只返回最近的Limit条
+}
+
+func (q AuditQuery) match(e *AuditEntry) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+	if q.User != "" && e.User != q.User {
+		return false
+	}
+	if len(q.Ops) > 0 {
+		found := false
+		for _, op := range q.Ops {
+			if op == e.Op {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Target != "" && !strings.Contains(e.Target, q.Target) && !strings.Contains(e.FileID, q.Target) {
+		return false
+	}
+	return !q.Failed || e.Result != AuditOK
+}
+
+// 按条件查询审计记录，按时间从旧到新排列。写了一半的记录（崩溃所致）忽略
+func (l *AuditLog) Query(q AuditQuery) ([]AuditEntry, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("打开审计日志失败: %v", err)
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || !q.match(&e) {
+			continue
+		}
+		entries = append(entries, e)
+		if q.Limit > 0 && len(entries) > 2*q.Limit {
+			entries = append(entries[:0], entries[len(entries)-q.Limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %v", err)
+	}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries, nil
+}
+
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// 元数据目录下的审计日志
+func (mm *MetadataManager) Audit() *AuditLog {
+	return mm.audit
+}
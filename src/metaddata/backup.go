@@ -0,0 +1,349 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"panmatrix/drivers"
+)
+
+// 元数据备份的名称前缀，后接20位的生成时间（纳秒），按名称排序即按时间排序。
+// 本地备份文件名为 <名称>.json.gz，驱动器上的storageID为名称
+const backupPrefix = "panmatrix-backup-"
+
+const (
+	defaultBackupKeep = 24
+	backupFileExt     = ".json.gz"
+
+	// 恢复时指定最新的备份
+	LatestBackup = "latest"
+
+	// 本地备份的位置（BackupInfo.Location）
+	BackupLocal = "local"
+)
+
+var ErrBackupNotFound = errors.New("元数据备份不存在")
+
+// 元数据定期备份配置（metadata段的backups）。常驻运行时每隔interval将存储中的全部元数据保存为一代备份，
+// 本地保留keep代；配置drive时同时加密上传到该驱动器。元数据损坏或误操作后用 metadata restore 命令回滚
+//
+//	metadata:
+//	  backups:
+//	    interval: 1h                          # 为0时只在执行 metadata backup 时备份
+//	    keep: 24                              # 本地保留的备份数
+//	    dir: ""                               # 默认 <metadata_path>/backups
+//	    drive: onedrive                       # 可选
+//	    key_file: /etc/panmatrix/backup.key   # 配置drive时必须配置，与drives的encryption相同的密钥格式
+//	    keep_remote: 7                        # 驱动器上保留的备份数，默认与keep相同
+type BackupConfig struct {
+	Interval                 time.Duration `yaml:"interval"`
+	Keep                     int           `yaml:"keep"`
+	Dir                      string        `yaml:"dir"`
+	Drive                    string        `yaml:"drive"`
+	drivers.EncryptionConfig `yaml:",inline"`
+	KeepRemote               int `yaml:"keep_remote"`
+}
+
+// 读取metadata段中的backups配置，未配置的字段使用默认值
+func (c StoreConfig) Backups() (BackupConfig, error) {
+	var section struct {
+		Backups BackupConfig `yaml:"backups"`
+	}
+	if err := c.Decode(&section); err != nil {
+		return BackupConfig{}, err
+	}
+	cfg := section.Backups
+	if cfg.Interval < 0 || cfg.Keep < 0 || cfg.KeepRemote < 0 {
+		return BackupConfig{}, errors.New("backups的interval、keep和keep_remote不能为负数")
+	}
+	if cfg.Keep == 0 {
+		cfg.Keep = defaultBackupKeep
+	}
+	if cfg.KeepRemote == 0 {
+		cfg.KeepRemote = cfg.Keep
+	}
+	return cfg, nil
+}
+
+// 一代元数据备份
+type BackupInfo struct {
+	Name      string
+	Location  string // BackupLocal或驱动器名
+	CreatedAt time.Time
+	Size      int64
+}
+
+// 元数据备份：本地目录中的备份文件和可选的驱动器上的加密副本
+type Backups struct {
+	basePath   string
+	dir        string
+	keep       int
+	interval   time.Duration
+	remoteName string
+	remote     drivers.StorageDriver // 已包装加密，未配置drive时为nil
+	keepRemote int
+
+	mu sync.Mutex // 同一时间只生成一份备份
+}
+
+// 按配置创建备份，配置了drive时从storageDrivers中查找该驱动器
+func NewBackups(basePath string, cfg BackupConfig, storageDrivers map[string]drivers.StorageDriver) (*Backups, error) {
+	b := &Backups{
+		basePath:   basePath,
+		dir:        cfg.Dir,
+		keep:       cfg.Keep,
+		interval:   cfg.Interval,
+		keepRemote: cfg.KeepRemote,
+	}
+	if b.dir == "" {
+		b.dir = filepath.Join(basePath, "backups")
+	}
+	if b.keep <= 0 {
+		b.keep = defaultBackupKeep
+	}
+	if b.keepRemote <= 0 {
+		b.keepRemote = b.keep
+	}
+	if cfg.Drive == "" {
+		return b, nil
+	}
+
+	driver, ok := storageDrivers[cfg.Drive]
+	if !ok {
+		return nil, fmt.Errorf("元数据备份驱动器不存在: %s", cfg.Drive)
+	}
+	enc, err := drivers.NewEncryptedDriver(driver, cfg.EncryptionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("元数据备份加密配置无效: %v", err)
+	}
+	b.remoteName, b.remote = cfg.Drive, enc
+	return b, nil
+}
+
+// 常驻运行时的备份间隔，0表示不定期备份
+func (b *Backups) Interval() time.Duration {
+	return b.interval
+}
+
+// 保存存储中全部元数据的一代备份，并删除超出保留数量的旧备份。
+// 本地备份成功而上传驱动器失败时同时返回备份信息和错误
+func (b *Backups) Save(ctx context.Context, st MetadataStore) (*BackupInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, err := encodeSnapshot(st)
+	if err != nil {
+		return nil, fmt.Errorf("生成元数据快照失败: %v", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s%020d", backupPrefix, now.UnixNano())
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %v", err)
+	}
+	path := filepath.Join(b.dir, name+backupFileExt)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return nil, fmt.Errorf("写入元数据备份失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("写入元数据备份失败: %v", err)
+	}
+	info := &BackupInfo{Name: name, Location: BackupLocal, CreatedAt: now, Size: int64(len(data))}
+	b.pruneLocal()
+
+	if b.remote == nil {
+		return info, nil
+	}
+	if limit := drivers.MaxChunkSizeOf(b.remote); limit > 0 && int64(len(data)) > limit {
+		return info, fmt.Errorf("元数据备份大小%d超过驱动器%s的单文件上限%d", len(data), b.remoteName, limit)
+	}
+	if _, err := b.remote.UploadChunk(ctx, data, name); err != nil {
+		return info, fmt.Errorf("上传元数据备份到%s失败: %v", b.remoteName, err)
+	}
+	pruneBackups(ctx, b.remote, b.keepRemote)
+	return info, nil
+}
+
+// 删除超出保留数量的旧的本地备份，失败只影响占用的空间
+func (b *Backups) pruneLocal() {
+	local, err := b.listLocal()
+	if err != nil || len(local) <= b.keep {
+		return
+	}
+	for _, info := range local[b.keep:] {
+		os.Remove(filepath.Join(b.dir, info.Name+backupFileExt))
+	}
+}
+
+// 删除驱动器上超出保留数量的旧备份
+func pruneBackups(ctx context.Context, driver drivers.StorageDriver, keep int) {
+	chunks, err := drivers.ListChunks(ctx, driver, backupPrefix)
+	if err != nil || len(chunks) <= keep {
+		return
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].StorageID > chunks[j].StorageID })
+	for _, chunk := range chunks[keep:] {
+		drivers.DeleteChunk(ctx, driver, chunk.Key())
+	}
+}
+
+// 本地备份，最新的在前
+func (b *Backups) listLocal() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取备份目录失败: %v", err)
+	}
+	var backups []BackupInfo
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), backupFileExt)
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || name == entry.Name() {
+			continue
+		}
+		info := BackupInfo{Name: name, Location: BackupLocal, CreatedAt: backupTime(name)}
+		if fi, err := entry.Info(); err == nil {
+			info.Size = fi.Size()
+		}
+		backups = append(backups, info)
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+// 本地和驱动器上的所有备份，最新的在前。列举驱动器失败时只返回本地备份并打印警告
+func (b *Backups) List(ctx context.Context) ([]BackupInfo, error) {
+	backups, err := b.listLocal()
+	if err != nil {
+		return nil, err
+	}
+	if b.remote != nil {
+		chunks, err := drivers.ListChunks(ctx, b.remote, backupPrefix)
+		if err != nil {
+			fmt.Printf("警告: 列举%s上的元数据备份失败: %v\n", b.remoteName, err)
+		}
+		for _, chunk := range chunks {
+			backups = append(backups, BackupInfo{Name: chunk.StorageID, Location: b.remoteName, CreatedAt: backupTime(chunk.StorageID), Size: chunk.Size})
+		}
+	}
+	sortBackups(backups)
+	return backups, nil
+}
+
+// 按生成时间从新到旧排列，同一代的本地备份在前
+func sortBackups(backups []BackupInfo) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].Name != backups[j].Name {
+			return backups[i].Name > backups[j].Name
+		}
+		return backups[i].Location == BackupLocal && backups[j].Location != BackupLocal
+	})
+}
+
+// 备份名中的生成时间，无法解析时为零值
+func backupTime(name string) time.Time {
+	var ns int64
+	if _, err := fmt.Sscanf(strings.TrimPrefix(name, backupPrefix), "%d", &ns); err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+func (b *Backups) load(ctx context.Context, info BackupInfo) (*replicaSnapshot, error) {
+	if info.Location == BackupLocal {
+		data, err := os.ReadFile(filepath.Join(b.dir, info.Name+backupFileExt))
+		if err != nil {
+			return nil, fmt.Errorf("读取元数据备份失败: %v", err)
+		}
+		return decodeSnapshot(data)
+	}
+	return downloadSnapshot(ctx, b.remote, info.Name)
+}
+
+// 将存储回滚到名为name的备份，name为LatestBackup时使用最新的可读备份。
+// 回滚前先备份当前的元数据，上次遗留的预写日志改名保留，不再重放。
+// 需在打开元数据管理器之前调用，存储不能同时被其他进程使用
+func (b *Backups) Restore(ctx context.Context, st MetadataStore, name string) (*BackupInfo, error) {
+	backups, err := b.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var snap *replicaSnapshot
+	var restored BackupInfo
+	var lastErr error
+	for _, info := range backups {
+		if name != LatestBackup && info.Name != name {
+			continue
+		}
+		if snap, err = b.load(ctx, info); err == nil {
+			restored = info
+			break
+		}
+		fmt.Printf("警告: 读取%s上的备份%s失败: %v\n", info.Location, info.Name, err)
+		lastErr = err
+	}
+	if snap == nil {
+		if lastErr != nil {
+			return nil, fmt.Errorf("所有匹配的元数据备份都无法读取: %v", lastErr)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, name)
+	}
+
+	if current, err := b.Save(ctx, st); current == nil {
+		return nil, fmt.Errorf("回滚前备份当前元数据失败: %v", err)
+	} else if err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+
+	wal := walFile(b.basePath)
+	if _, err := os.Stat(wal); err == nil {
+		if err := os.Rename(wal, fmt.Sprintf("%s.before-restore-%d", wal, time.Now().Unix())); err != nil {
+			return nil, fmt.Errorf("保留预写日志失败: %v", err)
+		}
+	}
+
+	if err := applySnapshot(st, snap); err != nil {
+		return nil, fmt.Errorf("写入备份中的元数据失败: %v", err)
+	}
+	restored.CreatedAt = snap.CreatedAt
+	return &restored, nil
+}
+
+// 设置元数据备份，之后Backup使用该配置
+func (mm *MetadataManager) SetBackups(b *Backups) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.backups = b
+}
+
+// 元数据备份，未设置时为nil
+func (mm *MetadataManager) Backups() *Backups {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.backups
+}
+
+// 立即备份元数据。启用了预写日志时先写回日志中的修改，备份包括内存中的全部状态
+func (mm *MetadataManager) Backup(ctx context.Context) (*BackupInfo, error) {
+	b := mm.Backups()
+	if b == nil {
+		return nil, errors.New("未设置元数据备份")
+	}
+	if wal := mm.currentWAL(); wal != nil {
+		if err := wal.checkpoint(); err != nil {
+			return nil, fmt.Errorf("写回预写日志失败: %v", err)
+		}
+	}
+	return b.Save(ctx, mm.store)
+}
@@ -0,0 +1,118 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 批量写入中的一个文件
+type FileWrite struct {
+	File *FileMetadata
+
+	// 自上次写入后只有这些条带发生变化（上传中写入的条带、替换的块），后端可以只写入这些条带和文件头，
+	// 不重写整个文件的元数据；后端中还没有该文件时整体写入。为空表示整体替换
+	Stripes []int
+}
+
+// 支持批量写入的存储后端：一批修改在一个事务中写入，全部成功或全部不生效。
+// 预写日志的检查点和SaveFileMetadataBatch通过该接口写回，一万个条带的上传每个检查点间隔只写一次
+type BatchStore interface {
+	WriteBatch(writes []FileWrite) error
+}
+
+// 按后端的能力写入一批修改：实现BatchStore时在一个事务中写入，否则逐个整体写入。
+// 包装其他后端的存储（如ReplicatedStore）自己实现BatchStore，这里不展开包装，避免绕过包装层
+func writeBatch(st MetadataStore, writes []FileWrite) error {
+	if len(writes) == 0 {
+		return nil
+	}
+	if bs, ok := st.(BatchStore); ok {
+		return bs.WriteBatch(writes)
+	}
+	for _, w := range writes {
+		if err := st.SaveFile(w.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 批量保存多个文件的完整元数据，存储后端支持时在一个事务中写入。
+// 效果与逐个调用SaveFileMetadata相同，同一批中不能有重复的文件
+func (mm *MetadataManager) SaveFileMetadataBatch(files []*FileMetadata) error {
+	if len(files) == 0 {
+		return nil
+	}
+	ids := make([]string, len(files))
+	seen := make(map[string]bool, len(files))
+	for i, fm := range files {
+		if seen[fm.FileID] {
+			return fmt.Errorf("批量写入中文件重复: %s", fm.FileID)
+		}
+		seen[fm.FileID] = true
+		ids[i] = fm.FileID
+	}
+
+	unlock := mm.fileLocks.lockAll(ids)
+	defer unlock()
+
+	// 由文件头复制而来时先补全条带，避免覆盖后端中的条带
+	for _, fm := range files {
+		cached, partial, ok := mm.cached(fm.FileID)
+		if !ok || !partial {
+			continue
+		}
+		full, err := mm.loadDetail(cached)
+		if err != nil {
+			return err
+		}
+		if fm.Stripes == nil {
+			fm.Stripes = full.Stripes
+		}
+		if fm.DriverMap == nil {
+			fm.DriverMap = full.DriverMap
+		}
+	}
+
+	now := time.Now()
+	olds := make([]*FileMetadata, len(files))
+	writes := make([]FileWrite, len(files))
+	mm.mu.Lock()
+	for i, fm := range files {
+		fm.UpdatedAt = now
+		fm.Dir = normalizeDir(fm.Dir)
+		fm.Tags = normalizeTags(fm.Tags)
+		if olds[i] = mm.file(fm.FileID); olds[i] != nil {
+			mm.unindexFile(olds[i])
+		}
+		mm.publish(fm, false)
+		mm.indexFile(fm)
+		writes[i] = FileWrite{File: fm}
+	}
+	mm.mu.Unlock()
+
+	// 先发布再清除预写日志中的记录，检查点不会用旧的元数据覆盖这次写入
+	if wal := mm.currentWAL(); wal != nil {
+		for _, fm := range files {
+			wal.forget(fm.FileID)
+		}
+	}
+	if err := writeBatch(mm.store, writes); err != nil {
+		return err
+	}
+	for i, fm := range files {
+		mm.publishFileChange(olds[i], fm)
+	}
+	return nil
+}
+
+// 有变化的条带序号（排序后）
+func sortedStripes(stripes map[int]bool) []int {
+	indexes := make([]int, 0, len(stripes))
+	for i := range stripes {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
@@ -0,0 +1,695 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// bbolt格式版本，记录在meta桶中
+const boltSchemaVersion = 1
+
+var (
+	boltMetaBucket    = []byte("meta")
+	boltFilesBucket   = []byte("files")   // file_id -> 不含块的文件元数据
+	boltStripsBucket  = []byte("strips")  // file_id子桶 -> 条带序号/角色/位置 -> 块元数据
+	boltDedupBucket   = []byte("dedup")   // 内容哈希 -> 引用该内容的文件ID列表
+	boltUploadsBucket = []byte("uploads") // 内容哈希 -> 上传进度
+	boltDriversBucket = []byte("drivers") // 驱动器名 -> 驱动器状态
+	boltDirsBucket    = []byte("dirs")    // 目录路径 -> 显式创建的目录
+	boltTagsBucket    = []byte("tags")    // 标签 \x00 file_id -> 空，按标签前缀遍历
+)
+
+func init() {
+	RegisterStore(BackendBolt, func(basePath string, cfg StoreConfig) (MetadataStore, error) {
+		var boltCfg struct {
+			BoltPath string `yaml:"bolt_path"`
+		}
+		if err := cfg.Decode(&boltCfg); err != nil {
+			return nil, err
+		}
+		path := boltCfg.BoltPath
+		if path == "" {
+			path = filepath.Join(basePath, "metadata.bolt")
+		}
+		return OpenBoltStore(path, basePath)
+	})
+}
+
+// bbolt存储：单个文件、事务写入，不需要cgo。每次修改在一个事务中完成，崩溃后不会留下半个文件的记录
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// 打开（必要时创建）数据库，新建的数据库会导入jsonDir中已有的JSON元数据
+func OpenBoltStore(path, jsonDir string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建元数据目录失败: %v", err)
+	}
+
+	// 同一数据库只能被一个进程打开，等待1秒后放弃
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开元数据数据库失败（是否有其他进程正在使用）: %v", err)
+	}
+
+	s := &BoltStore{db: db}
+	created, err := s.init()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if created {
+		if err := s.importJSON(jsonDir); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// 创建所有桶并检查格式版本，返回数据库是否为新建
+func (s *BoltStore) init() (bool, error) {
+	created := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltMetaBucket, boltFilesBucket, boltStripsBucket, boltDedupBucket, boltUploadsBucket, boltDriversBucket, boltDirsBucket, boltTagsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		meta := tx.Bucket(boltMetaBucket)
+		raw := meta.Get([]byte("version"))
+		if raw == nil {
+			created = true
+			return meta.Put([]byte("version"), []byte(strconv.Itoa(boltSchemaVersion)))
+		}
+		version, err := strconv.Atoi(string(raw))
+		if err != nil {
+			return fmt.Errorf("元数据库版本无效: %q", raw)
+		}
+		if version > boltSchemaVersion {
+			return fmt.Errorf("元数据库版本%d高于当前程序支持的版本%d", version, boltSchemaVersion)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("初始化元数据库失败: %v", err)
+	}
+	return created, nil
+}
+
+// 从JSON存储切换到bbolt时导入已有的文件元数据，JSON文件保留不动
+func (s *BoltStore) importJSON(dir string) error {
+	files, err := NewJSONStore(dir).ListFiles()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取JSON元数据失败: %v", err)
+	}
+	dirs, err := NewJSONStore(dir).ListDirs()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(dirs) == 0 {
+		return nil
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for _, fm := range files {
+			if err := putFile(tx, fm); err != nil {
+				return fmt.Errorf("导入元数据 %s 失败: %v", fm.FileID, err)
+			}
+		}
+		for _, d := range dirs {
+			data, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(boltDirsBucket).Put([]byte(d.Path), data); err != nil {
+				return fmt.Errorf("导入目录 %s 失败: %v", d.Path, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("已从JSON导入%d个文件的元数据\n", len(files))
+	return nil
+}
+
+func (s *BoltStore) SaveFile(fm *FileMetadata) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return putFile(tx, fm)
+	})
+	if err != nil {
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	return nil
+}
+
+// 在一个事务中写入一批修改，只有部分条带变化的文件只改写文件头和这些条带的块
+func (s *BoltStore) WriteBatch(writes []FileWrite) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, w := range writes {
+			if err := putFileStripes(tx, w); err != nil {
+				return fmt.Errorf("%s: %v", w.File.FileID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	return nil
+}
+
+func (s *BoltStore) GetFile(fileID string) (*FileMetadata, error) {
+	var fm *FileMetadata
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		fm, err = getFile(tx, []byte(fileID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fm, nil
+}
+
+func (s *BoltStore) ListFiles() ([]*FileMetadata, error) {
+	var files []*FileMetadata
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltFilesBucket).ForEach(func(k, v []byte) error {
+			fm, err := getFile(tx, k)
+			if err != nil {
+				return err
+			}
+			files = append(files, fm)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取元数据失败: %v", err)
+	}
+	return files, nil
+}
+
+// 只读取files桶中的文件头，不遍历strips桶
+func (s *BoltStore) ListFileHeaders() ([]*FileMetadata, error) {
+	var files []*FileMetadata
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltFilesBucket).ForEach(func(k, v []byte) error {
+			var fm FileMetadata
+			if err := json.Unmarshal(v, &fm); err != nil {
+				return fmt.Errorf("解析元数据 %s 失败: %v", k, err)
+			}
+			files = append(files, fileHeader(&fm))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取元数据失败: %v", err)
+	}
+	return files, nil
+}
+
+func (s *BoltStore) DeleteFile(fileID string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return deleteFile(tx, []byte(fileID))
+	})
+	if err != nil {
+		return fmt.Errorf("删除元数据失败: %v", err)
+	}
+	return nil
+}
+
+// 只改写块所在的一个键
+func (s *BoltStore) UpdateStrip(fileID string, stripeIndex int, oldStorageID string, strip StripMetadata) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		strips := tx.Bucket(boltStripsBucket).Bucket([]byte(fileID))
+		if strips == nil {
+			return fmt.Errorf("%w: %s", ErrNotFound, fileID)
+		}
+
+		prefix := []byte(fmt.Sprintf("%08d/", stripeIndex))
+		c := strips.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var old StripMetadata
+			if err := json.Unmarshal(v, &old); err != nil {
+				return fmt.Errorf("解析块元数据失败: %v", err)
+			}
+			if old.StorageID != oldStorageID {
+				continue
+			}
+			data, err := json.Marshal(strip)
+			if err != nil {
+				return err
+			}
+			return strips.Put(k, data)
+		}
+		return fmt.Errorf("%w: 块 %s", ErrNotFound, oldStorageID)
+	})
+}
+
+// 引用该内容哈希的文件ID
+func (s *BoltStore) FilesByHash(hash string) ([]string, error) {
+	var ids []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ids, err = dedupRefs(tx, hash)
+		return err
+	})
+	return ids, err
+}
+
+// 带有该标签的文件ID
+func (s *BoltStore) FilesByTag(tag string) ([]string, error) {
+	var ids []string
+	prefix := tagKey(tag, "")
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltTagsBucket).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			ids = append(ids, string(k[len(prefix):]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取标签索引失败: %v", err)
+	}
+	return ids, nil
+}
+
+func (s *BoltStore) SaveDriver(info *DriverInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDriversBucket).Put([]byte(info.Name), data)
+	})
+	if err != nil {
+		return fmt.Errorf("保存驱动器状态失败: %v", err)
+	}
+	return nil
+}
+
+func (s *BoltStore) ListDrivers() ([]*DriverInfo, error) {
+	var infos []*DriverInfo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDriversBucket).ForEach(func(k, v []byte) error {
+			var info DriverInfo
+			if err := json.Unmarshal(v, &info); err != nil {
+				return err
+			}
+			infos = append(infos, &info)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取驱动器状态失败: %v", err)
+	}
+	return infos, nil
+}
+
+// 上传进度与文件元数据保存在同一个数据库中
+func (s *BoltStore) SaveUploadProgress(p *UploadProgress) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("序列化上传进度失败: %v", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltUploadsBucket).Put([]byte(p.ContentHash), data)
+	})
+	if err != nil {
+		return fmt.Errorf("写入上传进度失败: %v", err)
+	}
+	return nil
+}
+
+func (s *BoltStore) GetUploadProgress(contentHash string) (*UploadProgress, error) {
+	var p *UploadProgress
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltUploadsBucket).Get([]byte(contentHash))
+		if data == nil {
+			return fmt.Errorf("上传进度不存在: %s", contentHash)
+		}
+		p = &UploadProgress{}
+		if err := json.Unmarshal(data, p); err != nil {
+			return fmt.Errorf("解析上传进度失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *BoltStore) ListUploadProgress() ([]*UploadProgress, error) {
+	var uploads []*UploadProgress
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltUploadsBucket).ForEach(func(k, data []byte) error {
+			var p UploadProgress
+			if err := json.Unmarshal(data, &p); err != nil {
+				return fmt.Errorf("解析上传进度失败: %v", err)
+			}
+			uploads = append(uploads, &p)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+func (s *BoltStore) DeleteUploadProgress(contentHash string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltUploadsBucket).Delete([]byte(contentHash))
+	})
+	if err != nil {
+		return fmt.Errorf("删除上传进度失败: %v", err)
+	}
+	return nil
+}
+
+func (s *BoltStore) SaveDir(dir *DirInfo) error {
+	data, err := json.Marshal(dir)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDirsBucket).Put([]byte(dir.Path), data)
+	})
+	if err != nil {
+		return fmt.Errorf("保存目录失败: %v", err)
+	}
+	return nil
+}
+
+func (s *BoltStore) DeleteDir(dirPath string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDirsBucket).Delete([]byte(dirPath))
+	})
+	if err != nil {
+		return fmt.Errorf("删除目录失败: %v", err)
+	}
+	return nil
+}
+
+// 按路径排序（bbolt的键有序）
+func (s *BoltStore) ListDirs() ([]*DirInfo, error) {
+	var dirs []*DirInfo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDirsBucket).ForEach(func(k, v []byte) error {
+			var d DirInfo
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			dirs = append(dirs, &d)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取目录列表失败: %v", err)
+	}
+	return dirs, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// 写入文件记录：文件本身（条带中不含块）写入files桶，每个块单独写入该文件的strips子桶，
+// 方便只改写单个块
+func putFile(tx *bolt.Tx, fm *FileMetadata) error {
+	id := []byte(fm.FileID)
+	if err := deleteFile(tx, id); err != nil {
+		return err
+	}
+
+	strips, err := tx.Bucket(boltStripsBucket).CreateBucket(id)
+	if err != nil {
+		return err
+	}
+	for _, stripe := range fm.Stripes {
+		if err := putStripeStrips(strips, stripe); err != nil {
+			return err
+		}
+	}
+	if err := putHeader(tx, fm); err != nil {
+		return err
+	}
+	for _, tag := range fm.Tags {
+		if err := tx.Bucket(boltTagsBucket).Put(tagKey(tag, fm.FileID), nil); err != nil {
+			return err
+		}
+	}
+	return addDedupRef(tx, fm.Hash, fm.FileID)
+}
+
+// 写入文件本身，条带中不含块
+func putHeader(tx *bolt.Tx, fm *FileMetadata) error {
+	header := *fm
+	header.Stripes = make([]StripeMetadata, len(fm.Stripes))
+	for i, stripe := range fm.Stripes {
+		header.Stripes[i] = StripeMetadata{
+			StripeIndex: stripe.StripeIndex,
+			StripeWidth: stripe.StripeWidth,
+			Hole:        stripe.Hole,
+			HoleSize:    stripe.HoleSize,
+		}
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltFilesBucket).Put([]byte(fm.FileID), data)
+}
+
+// 写入一个条带的所有块
+func putStripeStrips(strips *bolt.Bucket, stripe StripeMetadata) error {
+	for pos, strip := range stripe.Strips {
+		if err := putStrip(strips, stripe.StripeIndex, stripRoleData, pos, strip); err != nil {
+			return err
+		}
+	}
+	if stripe.ParityStrip != nil {
+		if err := putStrip(strips, stripe.StripeIndex, stripRoleParity, 0, *stripe.ParityStrip); err != nil {
+			return err
+		}
+	}
+	if stripe.LocalCopy != nil {
+		if err := putStrip(strips, stripe.StripeIndex, stripRoleLocal, 0, *stripe.LocalCopy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 只改写文件头和变化的条带。数据库中还没有该文件，或标签、内容哈希有变化（需要更新索引）时整体写入
+func putFileStripes(tx *bolt.Tx, w FileWrite) error {
+	fm := w.File
+	id := []byte(fm.FileID)
+	data := tx.Bucket(boltFilesBucket).Get(id)
+	strips := tx.Bucket(boltStripsBucket).Bucket(id)
+	if len(w.Stripes) == 0 || data == nil || strips == nil {
+		return putFile(tx, fm)
+	}
+	var old struct {
+		Hash string   `json:"hash"`
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal(data, &old); err != nil || old.Hash != fm.Hash || strings.Join(old.Tags, "\x00") != strings.Join(fm.Tags, "\x00") {
+		return putFile(tx, fm)
+	}
+
+	for _, index := range w.Stripes {
+		prefix := []byte(fmt.Sprintf("%08d/", index))
+		var keys [][]byte
+		c := strips.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := strips.Delete(k); err != nil {
+				return err
+			}
+		}
+		if stripe := findStripe(fm, index); stripe != nil {
+			if err := putStripeStrips(strips, *stripe); err != nil {
+				return err
+			}
+		}
+	}
+	return putHeader(tx, fm)
+}
+
+// 键为 条带序号/角色/位置，按键序遍历即按条带顺序
+func putStrip(b *bolt.Bucket, stripeIndex int, role string, pos int, strip StripMetadata) error {
+	data, err := json.Marshal(strip)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(fmt.Sprintf("%08d/%s/%04d", stripeIndex, role, pos)), data)
+}
+
+func getFile(tx *bolt.Tx, id []byte) (*FileMetadata, error) {
+	data := tx.Bucket(boltFilesBucket).Get(id)
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var fm FileMetadata
+	if err := json.Unmarshal(data, &fm); err != nil {
+		return nil, fmt.Errorf("解析元数据 %s 失败: %v", id, err)
+	}
+
+	strips := tx.Bucket(boltStripsBucket).Bucket(id)
+	if strips == nil {
+		return &fm, nil
+	}
+	err := strips.ForEach(func(k, v []byte) error {
+		stripeIndex, role, pos, err := parseStripKey(k)
+		if err != nil {
+			return err
+		}
+
+		var strip StripMetadata
+		if err := json.Unmarshal(v, &strip); err != nil {
+			return fmt.Errorf("解析块元数据失败: %v", err)
+		}
+		stripe := findStripe(&fm, stripeIndex)
+		if stripe == nil {
+			return nil
+		}
+		switch role {
+		case stripRoleParity:
+			stripe.ParityStrip = &strip
+		case stripRoleLocal:
+			stripe.LocalCopy = &strip
+		default:
+			// 按位置顺序遍历，位置即下标
+			if pos != len(stripe.Strips) {
+				return fmt.Errorf("%s 的条带%d缺少数据块%d", id, stripeIndex, len(stripe.Strips))
+			}
+			stripe.Strips = append(stripe.Strips, strip)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fm, nil
+}
+
+func deleteFile(tx *bolt.Tx, id []byte) error {
+	files := tx.Bucket(boltFilesBucket)
+	if data := files.Get(id); data != nil {
+		var old struct {
+			Hash string   `json:"hash"`
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(data, &old); err == nil {
+			if err := removeDedupRef(tx, old.Hash, string(id)); err != nil {
+				return err
+			}
+			for _, tag := range old.Tags {
+				if err := tx.Bucket(boltTagsBucket).Delete(tagKey(tag, string(id))); err != nil {
+					return err
+				}
+			}
+		}
+		if err := files.Delete(id); err != nil {
+			return err
+		}
+	}
+
+	strips := tx.Bucket(boltStripsBucket)
+	if strips.Bucket(id) != nil {
+		return strips.DeleteBucket(id)
+	}
+	return nil
+}
+
+func dedupRefs(tx *bolt.Tx, hash string) ([]string, error) {
+	data := tx.Bucket(boltDedupBucket).Get([]byte(hash))
+	if data == nil {
+		return nil, nil
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("解析内容引用失败: %v", err)
+	}
+	return ids, nil
+}
+
+func addDedupRef(tx *bolt.Tx, hash, fileID string) error {
+	if hash == "" {
+		return nil
+	}
+	ids, err := dedupRefs(tx, hash)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id == fileID {
+			return nil
+		}
+	}
+	return putDedupRefs(tx, hash, append(ids, fileID))
+}
+
+func removeDedupRef(tx *bolt.Tx, hash, fileID string) error {
+	if hash == "" {
+		return nil
+	}
+	ids, err := dedupRefs(tx, hash)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		if id == fileID {
+			return putDedupRefs(tx, hash, append(ids[:i], ids[i+1:]...))
+		}
+	}
+	return nil
+}
+
+func putDedupRefs(tx *bolt.Tx, hash string, ids []string) error {
+	b := tx.Bucket(boltDedupBucket)
+	if len(ids) == 0 {
+		return b.Delete([]byte(hash))
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(hash), data)
+}
+
+func tagKey(tag, fileID string) []byte {
+	return []byte(tag + "\x00" + fileID)
+}
+
+func parseStripKey(k []byte) (int, string, int, error) {
+	fields := strings.Split(string(k), "/")
+	if len(fields) != 3 {
+		return 0, "", 0, fmt.Errorf("块键无效: %s", k)
+	}
+	stripeIndex, err1 := strconv.Atoi(fields[0])
+	pos, err2 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil {
+		return 0, "", 0, fmt.Errorf("块键无效: %s", k)
+	}
+	return stripeIndex, fields[1], pos, nil
+}
@@ -0,0 +1,164 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	defaultCapacityWarnRatio = 0.9
+
+	// 按最近多少天的放置速度推算驱动器达到预算的时间
+	capacityProjectionDays = 30
+)
+
+// 驱动器容量预算（metadata段中的capacity）。预算按PanMatrix实际放置在驱动器上的字节数计算，
+// 与驱动器报告的用量无关，用于只把网盘的一部分空间交给PanMatrix。超出预算的驱动器不再参与空间规划，
+// 达到warn_ratio时上传前给出警告
+//
+//	metadata:
+//	  capacity:
+//	    warn_ratio: 0.9
+//	    budgets:
+//	      baidu: 1.5T
+//	      minio-home: 500G
+type CapacityConfig struct {
+	Budgets   map[string]int64 // 驱动器 -> 预算（字节）
+	WarnRatio float64
+}
+
+// 读取metadata段中的capacity配置，预算的单位K/M/G/T按1024计
+func (c StoreConfig) Capacity() (CapacityConfig, error) {
+	section := struct {
+		Capacity struct {
+			Budgets   map[string]string `yaml:"budgets"`
+			WarnRatio float64           `yaml:"warn_ratio"`
+		} `yaml:"capacity"`
+	}{}
+	if err := c.Decode(&section); err != nil {
+		return CapacityConfig{}, err
+	}
+
+	cfg := CapacityConfig{Budgets: make(map[string]int64), WarnRatio: section.Capacity.WarnRatio}
+	if cfg.WarnRatio == 0 {
+		cfg.WarnRatio = defaultCapacityWarnRatio
+	}
+	if cfg.WarnRatio < 0 || cfg.WarnRatio > 1 {
+		return CapacityConfig{}, fmt.Errorf("capacity的warn_ratio应在0到1之间: %v", cfg.WarnRatio)
+	}
+	for name, value := range section.Capacity.Budgets {
+		budget, err := parseSize(value)
+		if err != nil {
+			return CapacityConfig{}, fmt.Errorf("驱动器 %s 的容量预算无效: %v", name, err)
+		}
+		if budget > 0 {
+			cfg.Budgets[name] = budget
+		}
+	}
+	return cfg, nil
+}
+
+// 设置驱动器的容量预算
+func (mm *MetadataManager) SetCapacityConfig(cfg CapacityConfig) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.capacityCfg = cfg
+}
+
+func (mm *MetadataManager) CapacityConfig() CapacityConfig {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.capacityCfg
+}
+
+// 一个驱动器上PanMatrix放置的数据量和预算
+type DriverCapacity struct {
+	Driver string
+	Placed int64 // 所有块（包括校验块、本地副本、旧版本、回收站中和未提交的文件）的字节数
+	Budget int64 // 0表示未设置预算
+	Recent int64 // 最近30天上传的文件在该驱动器上的字节数
+}
+
+// 预算内剩余的字节数，未设置预算时返回-1
+func (c DriverCapacity) Remaining() int64 {
+	if c.Budget == 0 {
+		return -1
+	}
+	return max(c.Budget-c.Placed, 0)
+}
+
+// 按最近30天的放置速度推算多少天后达到预算，未设置预算或最近没有新增数据时返回false
+func (c DriverCapacity) DaysUntilFull() (float64, bool) {
+	if c.Budget == 0 || c.Recent <= 0 {
+		return 0, false
+	}
+	perDay := float64(c.Recent) / capacityProjectionDays
+	return float64(c.Remaining()) / perDay, true
+}
+
+// 每个驱动器（包括设置了预算但还没有数据的驱动器）的放置量，按驱动器名排列。
+// 第一次调用时读取只加载了文件头的文件的条带
+func (mm *MetadataManager) DriverCapacity() ([]DriverCapacity, error) {
+	if err := mm.resolvePlacement(); err != nil {
+		return nil, err
+	}
+	budgets := mm.CapacityConfig().Budgets
+
+	placed, recent := mm.placement.totals(time.Now().AddDate(0, 0, -capacityProjectionDays))
+	names := make(map[string]bool)
+	for name := range placed {
+		names[name] = true
+	}
+	for name := range budgets {
+		names[name] = true
+	}
+
+	result := make([]DriverCapacity, 0, len(names))
+	for name := range names {
+		result = append(result, DriverCapacity{Driver: name, Placed: placed[name], Budget: budgets[name], Recent: recent[name]})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Driver < result[j].Driver })
+	return result, nil
+}
+
+// 驱动器的预算和已放置的字节数，未设置预算时ok为false（供调度器规划空间）
+func (mm *MetadataManager) DriverBudget(driverName string) (budget, placed int64, ok bool) {
+	budget, ok = mm.CapacityConfig().Budgets[driverName]
+	if !ok {
+		return 0, 0, false
+	}
+	if err := mm.resolvePlacement(); err != nil {
+		// 无法统计时按已知的部分计算，不阻止上传
+		fmt.Printf("警告: %v\n", err)
+	}
+	return budget, mm.placement.placed(driverName), true
+}
+
+// 读取放置量未知的文件（只加载了文件头）的条带，结果只用于统计放置量和块的引用计数，
+// 不替换内存中的文件头
+func (mm *MetadataManager) resolvePlacement() error {
+	for _, fileID := range mm.placement.pending() {
+		if err := mm.resolveFilePlacement(fileID); err != nil {
+			return fmt.Errorf("统计驱动器放置量失败: %v", err)
+		}
+	}
+	return nil
+}
+
+func (mm *MetadataManager) resolveFilePlacement(fileID string) error {
+	unlock := mm.fileLocks.lock(fileID)
+	defer unlock()
+
+	fm, partial, ok := mm.cached(fileID)
+	if !ok || !partial {
+		// 已删除或已补全，发布时已经统计
+		return nil
+	}
+	full, err := mm.store.GetFile(fileID)
+	if err != nil {
+		return fmt.Errorf("读取 %s 的元数据失败: %v", fileID, err)
+	}
+	mm.placement.set(fileID, fm.CreatedAt, fileChunks(full))
+	return nil
+}
@@ -0,0 +1,292 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDirNotEmpty     = errors.New("目录不为空")
+	ErrDirsUnsupported = errors.New("存储后端不支持保存目录")
+	ErrDirShared       = errors.New("不能删除各用户共用的目录")
+)
+
+// 显式创建的虚拟目录。包含文件的目录自动存在，只有空目录需要记录。
+// 用户在自己的命名空间中创建的目录，Path为dirKey编码的“~用户名/路径”
+type DirInfo struct {
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 目录记录在存储后端和mm.dirs中的键：不属于任何用户的目录为路径本身，
+// 用户的目录为~加URL编码的用户名再加路径，如 ~alice/docs
+func dirKey(owner, dirPath string) string {
+	if owner == "" {
+		return dirPath
+	}
+	return "~" + url.PathEscape(owner) + dirPath
+}
+
+// dirKey的逆操作
+func parseDirKey(key string) (owner, dirPath string) {
+	if !strings.HasPrefix(key, "~") {
+		return "", key
+	}
+	i := strings.Index(key, "/")
+	if i < 0 {
+		return "", key
+	}
+	owner, err := url.PathUnescape(key[1:i])
+	if err != nil {
+		return "", key
+	}
+	return owner, key[i:]
+}
+
+// 目录记录对owner是否可见：不属于任何用户的目录各用户共用，owner为空时不区分用户
+func dirVisible(owner, dirOwner string) bool {
+	return owner == "" || dirOwner == "" || dirOwner == owner
+}
+
+// 存储后端实现该接口后才能创建空目录；未实现时目录只由文件的Dir隐式构成
+type DirectoryStore interface {
+	SaveDir(dir *DirInfo) error
+	// 删除目录记录，不存在时视为成功
+	DeleteDir(dirPath string) error
+	ListDirs() ([]*DirInfo, error)
+}
+
+// 目录中的一项
+type DirEntry struct {
+	Name  string
+	Path  string
+	IsDir bool
+	File  *FileMetadata // 文件的元数据，目录为nil
+}
+
+// 规范化虚拟路径：以/开头，去掉多余的/、.和..，根目录为/
+func CleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// FileMetadata.Dir中保存的形式，根目录为空
+func normalizeDir(p string) string {
+	if p = CleanPath(p); p == "/" {
+		return ""
+	}
+	return p
+}
+
+// 拆分为目录（FileMetadata.Dir的形式）和名称
+func splitPath(p string) (string, string) {
+	dir, name := path.Split(CleanPath(p))
+	return normalizeDir(dir), name
+}
+
+// 文件的虚拟路径
+func (fm *FileMetadata) Path() string {
+	return path.Join("/", fm.Dir, fm.FileName)
+}
+
+// p是否为dir本身或其下的路径（dir为FileMetadata.Dir的形式）
+func underDir(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// 创建各用户共用的目录，parents为true时同时创建不存在的上级目录，目录已存在时不报错
+func (mm *MetadataManager) Mkdir(dirPath string, parents bool) error {
+	return mm.mkdir(dirPath, parents, "")
+}
+
+// owner不为空时创建只有该用户可见的目录，同名路径上只有该用户的文件算作冲突
+func (mm *MetadataManager) mkdir(dirPath string, parents bool, owner string) error {
+	dirPath = CleanPath(dirPath)
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	var missing []string
+	for p := dirPath; p != "/"; p = path.Dir(p) {
+		if mm.fileAtLocked(p, owner) {
+			return fmt.Errorf("已存在同名文件: %s", p)
+		}
+		if mm.dirExistsLocked(p, owner) {
+			break
+		}
+		missing = append(missing, p)
+	}
+	if len(missing) == 0 {
+		if parents {
+			return nil
+		}
+		return fmt.Errorf("目录已存在: %s", dirPath)
+	}
+	if len(missing) > 1 && !parents {
+		return fmt.Errorf("%w: 上级目录 %s", ErrNotFound, path.Dir(dirPath))
+	}
+
+	ds, ok := mm.store.(DirectoryStore)
+	if !ok {
+		return ErrDirsUnsupported
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		info := &DirInfo{Path: dirKey(owner, missing[i]), CreatedAt: time.Now()}
+		if err := ds.SaveDir(info); err != nil {
+			return err
+		}
+		mm.dirs[info.Path] = info
+	}
+	return nil
+}
+
+// 删除空目录，包括各用户在该路径上创建的目录
+func (mm *MetadataManager) Rmdir(dirPath string) error {
+	return mm.rmdir(dirPath, "")
+}
+
+// owner不为空时只删除该用户创建的目录，只有该用户的文件和目录算作不为空
+func (mm *MetadataManager) rmdir(dirPath string, owner string) error {
+	dirPath = CleanPath(dirPath)
+	if dirPath == "/" {
+		return errors.New("不能删除根目录")
+	}
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if !mm.dirExistsLocked(dirPath, owner) {
+		return fmt.Errorf("%w: 目录 %s", ErrNotFound, dirPath)
+	}
+	// 未提交的文件也算在内，避免删除正在上传的目录；回收站中的文件恢复时目录自动重新出现
+	if mm.dirIndex.hasLive(owner, dirPath) {
+		return fmt.Errorf("%w: %s", ErrDirNotEmpty, dirPath)
+	}
+	var records []string
+	for key := range mm.dirs {
+		dirOwner, p := parseDirKey(key)
+		if !dirVisible(owner, dirOwner) || !underDir(p, dirPath) {
+			continue
+		}
+		if p != dirPath {
+			return fmt.Errorf("%w: %s", ErrDirNotEmpty, dirPath)
+		}
+		if owner == "" || dirOwner == owner {
+			records = append(records, key)
+		}
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%w: %s", ErrDirShared, dirPath)
+	}
+
+	for _, key := range records {
+		if ds, ok := mm.store.(DirectoryStore); ok {
+			if err := ds.DeleteDir(key); err != nil {
+				return err
+			}
+		}
+		delete(mm.dirs, key)
+	}
+	return nil
+}
+
+// 目录是否存在：根目录、显式创建的目录和包含文件或子目录的目录，不区分用户
+func (mm *MetadataManager) DirExists(dirPath string) bool {
+	return mm.dirExists(dirPath, "")
+}
+
+func (mm *MetadataManager) dirExists(dirPath string, owner string) bool {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.dirExistsLocked(CleanPath(dirPath), owner)
+}
+
+// 列出目录下的子目录和已提交的文件，子目录在前，各自按名称排序（同名文件按创建时间）
+func (mm *MetadataManager) ListDir(dirPath string) ([]DirEntry, error) {
+	return mm.listDir(dirPath, "")
+}
+
+// owner不为空时只列出该用户的文件、包含这些文件的子目录和对该用户可见的目录
+func (mm *MetadataManager) listDir(dirPath string, owner string) ([]DirEntry, error) {
+	dirPath = CleanPath(dirPath)
+	dir := normalizeDir(dirPath)
+
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	if !mm.dirExistsLocked(dirPath, owner) {
+		return nil, fmt.Errorf("%w: 目录 %s", ErrNotFound, dirPath)
+	}
+
+	subdirs := make(map[string]bool)
+	addSubdir := func(p string) {
+		if p == dir || !underDir(p, dir) {
+			return
+		}
+		rest := strings.TrimPrefix(p, dir+"/")
+		if i := strings.Index(rest, "/"); i >= 0 {
+			rest = rest[:i]
+		}
+		subdirs[rest] = true
+	}
+	for key := range mm.dirs {
+		if dirOwner, p := parseDirKey(key); dirVisible(owner, dirOwner) {
+			addSubdir(p)
+		}
+	}
+
+	names, ids := mm.dirIndex.list(owner, dir)
+	for _, name := range names {
+		subdirs[name] = true
+	}
+	files := make([]DirEntry, 0, len(ids))
+	for _, id := range ids {
+		// 统计之后文件可能已被删除或移动
+		if fm := mm.file(id); fm != nil && fm.Dir == dir && fm.IsCurrent() {
+			files = append(files, DirEntry{Name: fm.FileName, Path: fm.Path(), File: fm})
+		}
+	}
+
+	entries := make([]DirEntry, 0, len(subdirs)+len(files))
+	for name := range subdirs {
+		entries = append(entries, DirEntry{Name: name, Path: path.Join(dirPath, name), IsDir: true})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Name != files[j].Name {
+			return files[i].Name < files[j].Name
+		}
+		return files[i].File.CreatedAt.Before(files[j].File.CreatedAt)
+	})
+	return append(entries, files...), nil
+}
+
+func (mm *MetadataManager) dirExistsLocked(dirPath string, owner string) bool {
+	if dirPath == "/" {
+		return true
+	}
+	if _, ok := mm.dirs[dirPath]; ok {
+		return true
+	}
+	for key := range mm.dirs {
+		if dirOwner, p := parseDirKey(key); dirVisible(owner, dirOwner) && underDir(p, dirPath) {
+			return true
+		}
+	}
+	return mm.dirIndex.hasCurrent(owner, dirPath)
+}
+
+// 路径上是否有当前可见的文件，owner不为空时只看该用户的文件
+func (mm *MetadataManager) fileAtLocked(p string, owner string) bool {
+	dir, name := splitPath(p)
+	for _, id := range mm.nameIndex[name] {
+		if fm := mm.file(id); fm != nil && fm.Dir == dir && fm.IsCurrent() && (owner == "" || fm.Owner == owner) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func newTestManager(t *testing.T) *MetadataManager {
+	t.Helper()
+	mm, err := NewMetadataManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mm.Close() })
+	return mm
+}
+
+func saveTestFile(t *testing.T, mm *MetadataManager, fm *FileMetadata) {
+	t.Helper()
+	if err := mm.SaveFileMetadata(fm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func entryNames(entries []DirEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestDirIndexFollowsFiles(t *testing.T) {
+	mm := newTestManager(t)
+	saveTestFile(t, mm, &FileMetadata{FileID: "f1", FileName: "a.txt", Dir: "/docs/2024", Owner: "alice"})
+	saveTestFile(t, mm, &FileMetadata{FileID: "f2", FileName: "b.txt", Dir: "/docs", Owner: "bob"})
+	saveTestFile(t, mm, &FileMetadata{FileID: "f3", FileName: "c.txt", Dir: "/upload", State: FileStatePending})
+
+	tests := []struct {
+		dir    string
+		exists bool
+	}{
+		{"/", true},
+		{"/docs", true},
+		{"/docs/2024", true},
+		{"/upload", false}, // 只有未提交的文件
+		{"/doc", false},
+	}
+	for _, tt := range tests {
+		if got := mm.DirExists(tt.dir); got != tt.exists {
+			t.Errorf("DirExists(%s) = %v，期望 %v", tt.dir, got, tt.exists)
+		}
+	}
+
+	entries, err := mm.ListDir("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryNames(entries), []string{"2024", "b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("ListDir(/docs) = %v，期望 %v", got, want)
+	}
+	entries, err = mm.Namespace("bob").ListDir("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryNames(entries), []string{"b.txt"}; !slices.Equal(got, want) {
+		t.Errorf("bob的ListDir(/docs) = %v，期望 %v", got, want)
+	}
+
+	if err := mm.Rmdir("/upload"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除只有未提交文件的目录: %v", err)
+	}
+
+	// 移动文件后原目录不再存在
+	moved, err := mm.GetFileMetadata("f1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := *moved
+	updated.Dir = "/archive"
+	saveTestFile(t, mm, &updated)
+	if mm.DirExists("/docs/2024") {
+		t.Error("文件移走后目录仍存在")
+	}
+	if !mm.DirExists("/archive") {
+		t.Error("文件移入的目录不存在")
+	}
+
+	// 回收站中的文件不构成目录，也不妨碍删除
+	if _, err := mm.TrashFile("f2"); err != nil {
+		t.Fatal(err)
+	}
+	if mm.DirExists("/docs") {
+		t.Error("文件移入回收站后目录仍存在")
+	}
+}
+
+func TestRmdirKeepsDirsWithPendingFiles(t *testing.T) {
+	mm := newTestManager(t)
+	if err := mm.Mkdir("/upload", false); err != nil {
+		t.Fatal(err)
+	}
+	saveTestFile(t, mm, &FileMetadata{FileID: "f1", FileName: "a.bin", Dir: "/upload", State: FileStatePending})
+
+	if err := mm.Rmdir("/upload"); !errors.Is(err, ErrDirNotEmpty) {
+		t.Fatalf("期望目录不为空，实际 %v", err)
+	}
+	if err := mm.DeleteFileMetadata("f1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mm.Rmdir("/upload"); err != nil {
+		t.Fatalf("删除空目录: %v", err)
+	}
+}
+
+func TestMkdirIgnoresUncachedNames(t *testing.T) {
+	mm := newTestManager(t)
+	// 名称索引中的文件已不在内存中
+	mm.nameIndex["a"] = []string{"missing"}
+	if err := mm.Mkdir("/a", false); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+}
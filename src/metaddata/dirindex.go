@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"path"
+	"sync"
+)
+
+// 按目录统计内存中的文件，随元数据的发布和删除更新，判断目录是否存在、是否为空和列出目录时
+// 不必遍历所有文件。按所有者分别统计，""统计所有文件。锁在分片锁之后获取（见locks.go）
+type dirIndex struct {
+	mu     sync.Mutex
+	files  map[string]indexedFile // 文件ID -> 统计时的目录和状态
+	scopes map[string]*dirScope
+}
+
+type indexedFile struct {
+	dir     string
+	owner   string
+	current bool // 当前可见
+	live    bool // 不在回收站中，包括未提交的文件
+}
+
+type dirScope struct {
+	current map[string]int             // 目录 -> 其下（包括子目录）当前可见的文件数
+	live    map[string]int             // 目录 -> 其下不在回收站中的文件数
+	subdirs map[string]map[string]int  // 目录 -> 含当前可见文件的子目录名及其下的文件数
+	files   map[string]map[string]bool // 目录 -> 直接位于其中的当前可见文件
+}
+
+func (d *dirIndex) publish(fm *FileMetadata) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.removeLocked(fm.FileID)
+	f := indexedFile{dir: fm.Dir, owner: fm.Owner, current: fm.IsCurrent(), live: !fm.IsTrashed()}
+	if !f.current && !f.live {
+		return
+	}
+	if d.files == nil {
+		d.files = make(map[string]indexedFile)
+		d.scopes = make(map[string]*dirScope)
+	}
+	d.files[fm.FileID] = f
+	d.scope("").add(fm.FileID, f, 1)
+	if f.owner != "" {
+		d.scope(f.owner).add(fm.FileID, f, 1)
+	}
+}
+
+func (d *dirIndex) remove(fileID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.removeLocked(fileID)
+}
+
+func (d *dirIndex) removeLocked(fileID string) {
+	f, ok := d.files[fileID]
+	if !ok {
+		return
+	}
+	delete(d.files, fileID)
+	d.scopes[""].add(fileID, f, -1)
+	if f.owner != "" {
+		d.scopes[f.owner].add(fileID, f, -1)
+	}
+}
+
+func (d *dirIndex) scope(owner string) *dirScope {
+	s := d.scopes[owner]
+	if s == nil {
+		s = &dirScope{
+			current: make(map[string]int),
+			live:    make(map[string]int),
+			subdirs: make(map[string]map[string]int),
+			files:   make(map[string]map[string]bool),
+		}
+		d.scopes[owner] = s
+	}
+	return s
+}
+
+// 文件及其所在目录的每一级上级目录计数加减delta
+func (s *dirScope) add(fileID string, f indexedFile, delta int) {
+	if f.current {
+		setMember(s.files, f.dir, fileID, delta > 0)
+	}
+	for dir := f.dir; ; {
+		if f.current {
+			addCount(s.current, dir, delta)
+		}
+		if f.live {
+			addCount(s.live, dir, delta)
+		}
+		if dir == "" {
+			return
+		}
+		parent := normalizeDir(path.Dir(dir))
+		if f.current {
+			children := s.subdirs[parent]
+			if children == nil {
+				children = make(map[string]int)
+				s.subdirs[parent] = children
+			}
+			if addCount(children, path.Base(dir), delta); len(children) == 0 {
+				delete(s.subdirs, parent)
+			}
+		}
+		dir = parent
+	}
+}
+
+func addCount(counts map[string]int, key string, delta int) {
+	if counts[key] += delta; counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+func setMember(sets map[string]map[string]bool, key, member string, present bool) {
+	set := sets[key]
+	if !present {
+		if delete(set, member); len(set) == 0 {
+			delete(sets, key)
+		}
+		return
+	}
+	if set == nil {
+		set = make(map[string]bool)
+		sets[key] = set
+	}
+	set[member] = true
+}
+
+// dir（FileMetadata.Dir的形式）下是否有owner当前可见的文件
+func (d *dirIndex) hasCurrent(owner, dir string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.scopes[owner]
+	return s != nil && s.current[dir] > 0
+}
+
+// dir下是否有owner不在回收站中的文件，包括未提交的文件
+func (d *dirIndex) hasLive(owner, dir string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.scopes[owner]
+	return s != nil && s.live[dir] > 0
+}
+
+// dir下含owner当前可见文件的子目录名和直接位于其中的文件ID
+func (d *dirIndex) list(owner, dir string) ([]string, []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.scopes[owner]
+	if s == nil {
+		return nil, nil
+	}
+	subdirs := make([]string, 0, len(s.subdirs[dir]))
+	for name := range s.subdirs[dir] {
+		subdirs = append(subdirs, name)
+	}
+	ids := make([]string, 0, len(s.files[dir]))
+	for id := range s.files[dir] {
+		ids = append(ids, id)
+	}
+	return subdirs, ids
+}
@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 元数据变更事件类型
+const (
+	EventFileCreated     = "file.created"  // 文件上传完成（提交）
+	EventFileUpdated     = "file.updated"  // 已提交文件的元数据修改（重命名、标签、条带迁移等）
+	EventFileTrashed     = "file.trashed"  // 移入回收站
+	EventFileRestored    = "file.restored" // 从回收站恢复
+	EventFileDeleted     = "file.deleted"  // 元数据永久删除
+	EventDriverHealth    = "driver.health" // 驱动器健康状态变化
+	EventRebuildFinished = "rebuild.finished"
+)
+
+// 每个订阅者缓冲的事件数，处理不过来时丢弃新的事件
+const eventBufferSize = 256
+
+// 元数据变更事件
+type Event struct {
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	FileID string    `json:"file_id,omitempty"`
+	Path   string    `json:"path,omitempty"`
+	Owner  string    `json:"owner,omitempty"`
+	Size   int64     `json:"size,omitempty"`
+	Driver string    `json:"driver,omitempty"`
+	Health string    `json:"health,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+
+	// 上一个事件之后因订阅者处理过慢而丢弃的事件数，不为0时订阅者应重新读取完整状态
+	Missed int `json:"missed,omitempty"`
+}
+
+type subscriber struct {
+	ch     chan Event
+	types  map[string]bool // 为空表示所有类型
+	missed int
+}
+
+// 向订阅者分发事件。发送不阻塞，元数据的修改不会因为订阅者而变慢
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*subscriber]bool
+}
+
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if len(sub.types) > 0 && !sub.types[e.Type] {
+			continue
+		}
+		delivered := e
+		delivered.Missed = sub.missed
+		select {
+		case sub.ch <- delivered:
+			sub.missed = 0
+		default:
+			sub.missed++
+		}
+	}
+}
+
+// 订阅元数据变更事件，types为空时接收所有类型。ctx取消后停止订阅并关闭通道。
+// 使用共用的存储后端（如Redis）时也包括其他实例的文件修改
+func (mm *MetadataManager) Subscribe(ctx context.Context, types ...string) <-chan Event {
+	sub := &subscriber{ch: make(chan Event, eventBufferSize), types: make(map[string]bool)}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	b := &mm.events
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[*subscriber]bool)
+	}
+	b.subs[sub] = true
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, sub)
+		close(sub.ch)
+		b.mu.Unlock()
+	}()
+	return sub.ch
+}
+
+// 发布事件（如RAID层完成重建后），时间未填写时自动填写
+func (mm *MetadataManager) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	mm.events.publish(e)
+}
+
+// 按文件保存前后的状态发布事件，未提交和待确认的文件不发布
+func (mm *MetadataManager) publishFileChange(old, fm *FileMetadata) {
+	var eventType string
+	switch {
+	case fm == nil:
+		if old == nil {
+			return
+		}
+		eventType = EventFileDeleted
+		fm = old
+	case fm.State == FileStateTrashed:
+		if old != nil && old.State == FileStateTrashed {
+			return
+		}
+		eventType = EventFileTrashed
+	case fm.State == FileStateCommitted:
+		switch {
+		case old == nil || old.State == FileStatePending || old.State == FileStateReview:
+			eventType = EventFileCreated
+		case old.State == FileStateTrashed:
+			eventType = EventFileRestored
+		default:
+			eventType = EventFileUpdated
+		}
+	default:
+		return
+	}
+	mm.Publish(Event{Type: eventType, FileID: fm.FileID, Path: fm.Path(), Owner: fm.Owner, Size: fm.FileSize})
+}
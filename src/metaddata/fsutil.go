@@ -0,0 +1,85 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 原子写入的临时文件后缀。临时文件与目标文件在同一目录下，以.开头
+const tempFileExt = ".tmp"
+
+// 超过该时间的临时文件视为崩溃遗留，启动时删除；更新的可能属于正在写入的其他进程
+const staleTempAge = 10 * time.Minute
+
+// 原子写入文件：先写入同一目录下的临时文件并fsync，再重命名替换目标文件。
+// 崩溃时目标文件要么是旧内容要么是新内容，不会只写入一半
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filePath)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".*"+tempFileExt)
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return err
+	}
+	renamed = true
+	syncDir(dir)
+	return nil
+}
+
+// 同步目录，确保重命名后的目录项落盘。部分平台不支持对目录fsync，忽略错误
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// 是否为writeFileAtomic崩溃后遗留的临时文件
+func isStaleTempFile(entry os.DirEntry, now time.Time) bool {
+	name := entry.Name()
+	if !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, tempFileExt) {
+		return false
+	}
+	info, err := entry.Info()
+	return err == nil && now.Sub(info.ModTime()) > staleTempAge
+}
+
+// 将无法解析的元数据文件移到basePath/quarantine下，保留原始内容以便人工检查，返回移动后的路径
+func quarantineFile(basePath, filePath string) (string, error) {
+	dir := filepath.Join(basePath, "quarantine")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建隔离目录失败: %v", err)
+	}
+	target := filepath.Join(dir, fmt.Sprintf("%s.%s", filepath.Base(filePath), time.Now().Format("20060102-150405")))
+	if err := os.Rename(filePath, target); err != nil {
+		return "", fmt.Errorf("隔离元数据文件失败: %v", err)
+	}
+	syncDir(dir)
+	syncDir(filepath.Dir(filePath))
+	return target, nil
+}
@@ -0,0 +1,216 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 存储后端实现该接口后，启动时只加载文件头（不含条带和驱动器信息），
+// 文件的条带在首次读取该文件时才从后端加载，文件很多时启动更快
+type HeaderStore interface {
+	ListFileHeaders() ([]*FileMetadata, error)
+}
+
+// 只保留文件头
+func fileHeader(fm *FileMetadata) *FileMetadata {
+	header := *fm
+	header.Stripes = nil
+	header.DriverMap = nil
+	return &header
+}
+
+// 存储后端（或被包装的后端）实现的HeaderStore
+func (mm *MetadataManager) headerStore() (HeaderStore, bool) {
+	st := mm.store
+	for {
+		if hs, ok := st.(HeaderStore); ok {
+			return hs, true
+		}
+		wrapper, ok := st.(interface{ Unwrap() MetadataStore })
+		if !ok {
+			return nil, false
+		}
+		st = wrapper.Unwrap()
+	}
+}
+
+// 补全只加载了文件头的文件的条带，返回完整的元数据并替换内存中的文件头，
+// 已完整时直接返回fm。调用方持有该文件的文件锁
+func (mm *MetadataManager) loadDetail(fm *FileMetadata) (*FileMetadata, error) {
+	if _, partial, _ := mm.cached(fm.FileID); !partial {
+		return fm, nil
+	}
+	full, err := mm.store.GetFile(fm.FileID)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 的元数据失败: %v", fm.FileID, err)
+	}
+	detail := *fm
+	detail.Stripes = full.Stripes
+	detail.DriverMap = full.DriverMap
+	mm.publish(&detail, false)
+	return &detail, nil
+}
+
+// JSON存储的文件头索引 index.jsonl：每行一条记录，后面的记录覆盖前面同一文件的记录。
+// 正常关闭时追加结束标记；启动时最后一条不是结束标记（上次异常退出）则读取所有文档重建索引
+type jsonIndexRecord struct {
+	File    *FileMetadata `json:"file,omitempty"`
+	Deleted string        `json:"deleted,omitempty"`
+	Clean   bool          `json:"clean,omitempty"`
+}
+
+// 过期记录超过有效记录数时在启动时压缩索引
+const indexCompactSlack = 1000
+
+func (s *JSONStore) indexPath() string {
+	return filepath.Join(s.basePath, "index.jsonl")
+}
+
+// 从索引读取所有文件头，索引不完整时读取所有JSON文档并重写索引
+func (s *JSONStore) ListFileHeaders() ([]*FileMetadata, error) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	headers, lines, ok := s.readIndex()
+	if !ok {
+		files, err := s.ListFiles()
+		if err != nil {
+			return nil, err
+		}
+		headers = make(map[string]*FileMetadata, len(files))
+		for _, fm := range files {
+			headers[fm.FileID] = fileHeader(fm)
+		}
+		if len(headers) > 0 {
+			fmt.Printf("已重建元数据索引（%d个文件）\n", len(headers))
+		}
+	}
+	if !ok || lines > 2*len(headers)+indexCompactSlack {
+		if err := s.rewriteIndexLocked(headers); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.openIndexLocked(); err != nil {
+		return nil, err
+	}
+	s.indexComplete = true
+
+	files := make([]*FileMetadata, 0, len(headers))
+	for _, header := range headers {
+		files = append(files, header)
+	}
+	return files, nil
+}
+
+// 读取索引，返回文件头、记录数，以及索引是否完整（以结束标记结尾）
+func (s *JSONStore) readIndex() (map[string]*FileMetadata, int, bool) {
+	f, err := os.Open(s.indexPath())
+	if err != nil {
+		return nil, 0, false
+	}
+	defer f.Close()
+
+	headers := make(map[string]*FileMetadata)
+	lines := 0
+	clean := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec jsonIndexRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, 0, false
+		}
+		lines++
+		clean = rec.Clean
+		switch {
+		case rec.File != nil:
+			headers[rec.File.FileID] = rec.File
+		case rec.Deleted != "":
+			delete(headers, rec.Deleted)
+		}
+	}
+	if scanner.Err() != nil || !clean {
+		return nil, 0, false
+	}
+	return headers, lines, true
+}
+
+// 原子重写只包含当前文件头的索引，调用方持有indexMu
+func (s *JSONStore) rewriteIndexLocked(headers map[string]*FileMetadata) error {
+	if s.index != nil {
+		s.index.Close()
+		s.index = nil
+	}
+
+	var data []byte
+	for _, header := range headers {
+		line, err := json.Marshal(jsonIndexRecord{File: header})
+		if err != nil {
+			return fmt.Errorf("序列化元数据索引失败: %v", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	data = append(data, `{"clean":true}`+"\n"...)
+	if err := writeFileAtomic(s.indexPath(), data, 0644); err != nil {
+		return fmt.Errorf("写入元数据索引失败: %v", err)
+	}
+	return nil
+}
+
+func (s *JSONStore) openIndexLocked() error {
+	if s.index != nil {
+		return nil
+	}
+	f, err := os.OpenFile(s.indexPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开元数据索引失败: %v", err)
+	}
+	s.index = f
+	return nil
+}
+
+// 文档写入或删除后追加索引记录。不单独fsync：异常退出时索引没有结束标记，下次启动会重建
+func (s *JSONStore) appendIndex(rec jsonIndexRecord) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	if err := s.openIndexLocked(); err != nil {
+		return err
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("序列化元数据索引失败: %v", err)
+	}
+	if _, err := s.index.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入元数据索引失败: %v", err)
+	}
+	return nil
+}
+
+// 关闭索引。索引完整时追加结束标记并fsync，下次启动可以直接使用
+func (s *JSONStore) closeIndex() error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	if s.index == nil {
+		return nil
+	}
+	f := s.index
+	s.index = nil
+
+	if s.indexComplete {
+		if _, err := f.Write([]byte(`{"clean":true}` + "\n")); err != nil {
+			f.Close()
+			return fmt.Errorf("写入元数据索引失败: %v", err)
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("写入元数据索引失败: %v", err)
+		}
+	}
+	return f.Close()
+}
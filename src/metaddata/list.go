@@ -0,0 +1,164 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// 文件列表的排序字段
+const (
+	SortByName    = "name"
+	SortBySize    = "size"
+	SortByCreated = "created"
+	SortByUpdated = "updated"
+)
+
+// 文件列表查询条件，零值表示列出所有已提交的文件（按文件名排序，不分页）
+type ListOptions struct {
+	Prefix          string // 文件名前缀
+	Pattern         string // 文件名通配符（path.Match语法，如 *.iso）
+	Dir             string // 只列出该虚拟目录中的文件
+	Owner           string // 只列出该用户的文件，为空时不限
+	Recursive       bool   // 与Dir一起使用时包括所有子目录中的文件
+	RAIDLevels      []int  // 只列出这些RAID级别的文件
+	SortBy          string // 排序字段，默认按文件名
+	Desc            bool   // 降序
+	Offset          int    // 跳过前Offset个结果
+	Limit           int    // 最多返回的结果数，0表示不限
+	IncludePending  bool   // 包括尚未提交的文件
+	IncludeVersions bool   // 包括被替换的旧版本
+}
+
+// 一页文件列表
+type ListResult struct {
+	Files  []*FileMetadata
+	Total  int // 满足条件的文件总数（分页前）
+	Offset int
+}
+
+// 还有下一页时返回下一页的Offset，否则返回-1
+func (r *ListResult) NextOffset() int {
+	next := r.Offset + len(r.Files)
+	if len(r.Files) == 0 || next >= r.Total {
+		return -1
+	}
+	return next
+}
+
+// 按条件筛选、排序并分页列出文件。排序字段相同时按文件ID排序，分页结果稳定
+func (mm *MetadataManager) ListFiles(opts ListOptions) (*ListResult, error) {
+	return mm.queryFiles(opts, nil, nil)
+}
+
+// 在candidates（为nil时为所有文件，调用时已持有mm.mu的读锁）中筛选同时满足opts和match的文件
+func (mm *MetadataManager) queryFiles(opts ListOptions, candidates func() []*FileMetadata, match func(*FileMetadata) bool) (*ListResult, error) {
+	less, err := fileLess(opts.SortBy)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Pattern != "" {
+		if _, err := path.Match(opts.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("无效的文件名通配符 %q: %v", opts.Pattern, err)
+		}
+	}
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return nil, fmt.Errorf("分页参数不能为负数")
+	}
+
+	mm.mu.RLock()
+	var files []*FileMetadata
+	visit := func(fm *FileMetadata) {
+		if opts.matches(fm) && (match == nil || match(fm)) {
+			files = append(files, fm)
+		}
+	}
+	if candidates != nil {
+		for _, fm := range candidates() {
+			visit(fm)
+		}
+	} else {
+		mm.eachFile(visit)
+	}
+	mm.mu.RUnlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if opts.Desc {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.FileID < b.FileID
+	})
+
+	result := &ListResult{Total: len(files), Offset: opts.Offset}
+	if opts.Offset >= len(files) {
+		result.Files = []*FileMetadata{}
+		return result, nil
+	}
+	files = files[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(files) {
+		files = files[:opts.Limit]
+	}
+	result.Files = files
+	return result, nil
+}
+
+func (opts *ListOptions) matches(fm *FileMetadata) bool {
+	if !opts.IncludePending && !fm.IsCommitted() {
+		return false
+	}
+	if !opts.IncludeVersions && !fm.SupersededAt.IsZero() {
+		return false
+	}
+	if opts.Owner != "" && fm.Owner != opts.Owner {
+		return false
+	}
+	if opts.Prefix != "" && !strings.HasPrefix(fm.FileName, opts.Prefix) {
+		return false
+	}
+	if opts.Pattern != "" {
+		if ok, _ := path.Match(opts.Pattern, fm.FileName); !ok {
+			return false
+		}
+	}
+	if opts.Dir != "" {
+		dir := normalizeDir(opts.Dir)
+		if opts.Recursive && !underDir(fm.Dir, dir) || !opts.Recursive && fm.Dir != dir {
+			return false
+		}
+	}
+	if len(opts.RAIDLevels) > 0 {
+		found := false
+		for _, level := range opts.RAIDLevels {
+			if fm.RAIDLevel == level {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func fileLess(sortBy string) (func(a, b *FileMetadata) bool, error) {
+	switch sortBy {
+	case "", SortByName:
+		return func(a, b *FileMetadata) bool { return a.FileName < b.FileName }, nil
+	case SortBySize:
+		return func(a, b *FileMetadata) bool { return a.FileSize < b.FileSize }, nil
+	case SortByCreated:
+		return func(a, b *FileMetadata) bool { return a.CreatedAt.Before(b.CreatedAt) }, nil
+	case SortByUpdated:
+		return func(a, b *FileMetadata) bool { return a.UpdatedAt.Before(b.UpdatedAt) }, nil
+	}
+	return nil, fmt.Errorf("未知的排序字段: %s（可用: %s, %s, %s, %s）", sortBy, SortByName, SortBySize, SortByCreated, SortByUpdated)
+}
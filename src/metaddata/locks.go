@@ -0,0 +1,154 @@
+package metadata
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// 内存中的文件元数据按文件ID分片，每个分片有自己的读写锁，读取单个文件只锁一个分片。
+//
+// 锁的顺序：文件锁 -> 预写日志的锁 -> mm.mu -> 分片锁 -> 放置量和目录统计的锁。mm.mu保护文件名和标签索引、
+// 目录、驱动器状态等，只在修改内存时短暂持有，不在持有时读写存储后端。
+// 分片中的元数据发布后不再原地修改，修改时复制一份再替换，读取方拿到的始终是完整的一份
+const metadataShards = 32
+
+type metadataShard struct {
+	mu      sync.RWMutex
+	files   map[string]*FileMetadata
+	partial map[string]bool // 只加载了文件头的文件
+}
+
+func newMetadataShards() []*metadataShard {
+	shards := make([]*metadataShard, metadataShards)
+	for i := range shards {
+		shards[i] = &metadataShard{
+			files:   make(map[string]*FileMetadata),
+			partial: make(map[string]bool),
+		}
+	}
+	return shards
+}
+
+func (mm *MetadataManager) shard(fileID string) *metadataShard {
+	h := fnv.New32a()
+	h.Write([]byte(fileID))
+	return mm.shards[h.Sum32()%metadataShards]
+}
+
+// 内存中的文件元数据，以及是否只加载了文件头
+func (mm *MetadataManager) cached(fileID string) (*FileMetadata, bool, bool) {
+	s := mm.shard(fileID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fm, ok := s.files[fileID]
+	return fm, s.partial[fileID], ok
+}
+
+// 内存中的文件元数据，不存在时为nil
+func (mm *MetadataManager) file(fileID string) *FileMetadata {
+	fm, _, _ := mm.cached(fileID)
+	return fm
+}
+
+// 替换内存中的文件元数据并更新驱动器放置量、块的引用计数和目录统计。文件名或标签变化时调用方还需持有mm.mu并更新索引
+func (mm *MetadataManager) publish(fm *FileMetadata, partial bool) {
+	s := mm.shard(fm.FileID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[fm.FileID] = fm
+	if partial {
+		s.partial[fm.FileID] = true
+	} else {
+		delete(s.partial, fm.FileID)
+	}
+	mm.placement.publish(fm, partial)
+	mm.dirIndex.publish(fm)
+}
+
+func (mm *MetadataManager) unpublish(fileID string) {
+	s := mm.shard(fileID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, fileID)
+	delete(s.partial, fileID)
+	mm.placement.remove(fileID)
+	mm.dirIndex.remove(fileID)
+}
+
+// 依次锁住每个分片遍历内存中的文件元数据，fn中不能再加锁
+func (mm *MetadataManager) eachFile(fn func(*FileMetadata)) {
+	for _, s := range mm.shards {
+		s.mu.RLock()
+		for _, fm := range s.files {
+			fn(fm)
+		}
+		s.mu.RUnlock()
+	}
+}
+
+// 按文件ID加锁：同一文件的读写存储后端和修改按顺序进行，不同文件互不阻塞。
+// 没有使用者的锁即时删除
+type fileLocks struct {
+	mu    sync.Mutex
+	locks map[string]*fileLock
+}
+
+type fileLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// 锁住文件，返回解锁函数
+func (l *fileLocks) lock(fileID string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*fileLock)
+	}
+	fl := l.locks[fileID]
+	if fl == nil {
+		fl = &fileLock{}
+		l.locks[fileID] = fl
+	}
+	fl.refs++
+	l.mu.Unlock()
+
+	fl.mu.Lock()
+	return func() {
+		fl.mu.Unlock()
+		l.mu.Lock()
+		if fl.refs--; fl.refs == 0 {
+			delete(l.locks, fileID)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// 锁住多个文件，按文件ID排序加锁，同时锁住多个文件的调用方之间不会死锁
+func (l *fileLocks) lockAll(fileIDs []string) func() {
+	sorted := append([]string(nil), fileIDs...)
+	sort.Strings(sorted)
+	unlocks := make([]func(), 0, len(sorted))
+	for i, id := range sorted {
+		if i > 0 && id == sorted[i-1] {
+			continue
+		}
+		unlocks = append(unlocks, l.lock(id))
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// 复制一份元数据用于修改，条带单独复制，修改条带不影响已发布的元数据
+func cloneForUpdate(fm *FileMetadata) *FileMetadata {
+	updated := *fm
+	updated.Stripes = make([]StripeMetadata, len(fm.Stripes))
+	for i, stripe := range fm.Stripes {
+		stripe.Strips = append([]StripMetadata(nil), stripe.Strips...)
+		updated.Stripes[i] = stripe
+	}
+	return &updated
+}
@@ -0,0 +1,69 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 按文件名或虚拟路径查找当前的文件，返回完整的元数据。以/开头时只匹配该目录下的文件，
+// 否则匹配所有目录中的同名文件；有多个时返回最新创建的
+func (mm *MetadataManager) GetByName(filePath string) (*FileMetadata, error) {
+	ids := mm.FindFileIDsByName(filePath)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, filePath)
+	}
+	return mm.GetFileMetadata(ids[len(ids)-1])
+}
+
+// 内容哈希（SHA-256的十六进制）相同的已提交文件，包括旧版本，按创建时间从旧到新排列。
+// 返回的元数据可能不含条带，用于上传前判断内容是否已经存储
+func (mm *MetadataManager) GetByHash(hash string) []*FileMetadata {
+	hash = strings.ToLower(hash)
+
+	mm.mu.RLock()
+	var files []*FileMetadata
+	for id := range mm.hashIndex[hash] {
+		if fm := mm.file(id); fm.IsCommitted() {
+			files = append(files, fm)
+		}
+	}
+	mm.mu.RUnlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].CreatedAt.Equal(files[j].CreatedAt) {
+			return files[i].CreatedAt.Before(files[j].CreatedAt)
+		}
+		return files[i].FileID < files[j].FileID
+	})
+	return files
+}
+
+// 将文件加入文件名、标签和内容哈希索引，调用方持有mm.mu的写锁
+func (mm *MetadataManager) indexFile(fm *FileMetadata) {
+	mm.indexName(fm.FileName, fm.FileID)
+	mm.indexTags(fm)
+	if fm.Hash == "" {
+		return
+	}
+	hash := strings.ToLower(fm.Hash)
+	ids := mm.hashIndex[hash]
+	if ids == nil {
+		ids = make(map[string]bool)
+		mm.hashIndex[hash] = ids
+	}
+	ids[fm.FileID] = true
+}
+
+// 从所有索引中删除文件，调用方持有mm.mu的写锁
+func (mm *MetadataManager) unindexFile(fm *FileMetadata) {
+	mm.unindexName(fm.FileName, fm.FileID)
+	mm.unindexTags(fm)
+	hash := strings.ToLower(fm.Hash)
+	if ids := mm.hashIndex[hash]; ids != nil {
+		delete(ids, fm.FileID)
+		if len(ids) == 0 {
+			delete(mm.hashIndex, hash)
+		}
+	}
+}
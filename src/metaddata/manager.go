@@ -1,10 +1,12 @@
 package metadata
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,6 +15,9 @@ import (
 type FileMetadata struct {
 	FileID      string                 `json:"file_id"`
 	FileName    string                 `json:"file_name"`
+	Dir         string                 `json:"dir,omitempty"` // 所在的虚拟目录（如 /photos/2024），空表示根目录
+	Tags        []string               `json:"tags,omitempty"` // 用户定义的标签（小写，已排序）
+	Owner       string                 `json:"owner,omitempty"` // 所属用户，为空表示不属于任何用户（未启用多用户时上传）
 	FileSize    int64                  `json:"file_size"`
 	RAIDLevel   int                    `json:"raid_level"`
 	StripeSize  int64                  `json:"stripe_size"`
@@ -21,16 +26,51 @@ type FileMetadata struct {
 	UpdatedAt   time.Time              `json:"updated_at"`
 	Hash        string                 `json:"hash"`
 	
+	// 源文件的属性，上传时记录，下载时恢复修改时间和权限
+	MimeType    string                 `json:"mime_type,omitempty"`
+	ModTime     time.Time              `json:"mod_time,omitempty"`
+	Mode        os.FileMode            `json:"mode,omitempty"` // Unix权限位，为0表示未记录
+	
+	// 提交状态：所有条带上传并校验完成后才写入提交标记，之前对列表和读取不可见
+	State       string                 `json:"state,omitempty"`
+	CommittedAt time.Time              `json:"committed_at,omitempty"`
+	TrashedAt   time.Time              `json:"trashed_at,omitempty"` // 移入回收站的时间
+	
+	// 版本：同一路径再次上传后，原来的文件保留为旧版本（SupersededAt不为零），不出现在列表中
+	Version      int                   `json:"version,omitempty"`
+	SupersededAt time.Time             `json:"superseded_at,omitempty"`
+	
 	// RAID特定的元数据
 	Stripes     []StripeMetadata       `json:"stripes"`
 	DriverMap   map[string]DriverInfo  `json:"driver_map"` // 驱动器健康状态
 }
 
+// 文件提交状态
+const (
+	FileStatePending   = "pending"
+	FileStateCommitted = "committed"
+	FileStateReview    = "review" // 元数据重建时无法校验的文件，需要人工确认
+	FileStateTrashed   = "trashed" // 已删除但条带块仍保留的文件，过期前可以恢复
+)
+
+var ErrFileNotCommitted = errors.New("文件尚未提交")
+
+// 文件是否已提交，旧版本没有状态字段的记录视为已提交
+func (fm *FileMetadata) IsCommitted() bool {
+	return fm.State == "" || fm.State == FileStateCommitted
+}
+
 // 条带元数据
 type StripeMetadata struct {
 	StripeIndex int                    `json:"stripe_index"`
+	StripeWidth int                    `json:"stripe_width,omitempty"` // 写入时的条带宽度
 	Strips      []StripMetadata        `json:"strips"`
 	ParityStrip *StripMetadata         `json:"parity_strip,omitempty"` // RAID5
+	LocalCopy   *StripMetadata         `json:"local_copy,omitempty"`   // 混合模式下的本地完整副本
+	
+	// 全零条带作为空洞记录，不上传任何数据，读取时还原为HoleSize个零字节
+	Hole        bool                   `json:"hole,omitempty"`
+	HoleSize    int64                  `json:"hole_size,omitempty"`
 }
 
 // 块元数据
@@ -42,109 +82,501 @@ type StripMetadata struct {
 	IsParity    bool     `json:"is_parity"`
 	Checksum    string   `json:"checksum"`
 	CreatedAt   time.Time `json:"created_at"`
+	
+	// 驱动器返回的远程ID（如文件ID、CID），与StorageID相同时为空
+	RemoteID    string   `json:"remote_id,omitempty"`
+	
+	// 块超过驱动器单文件上限时拆分成的子块，为空表示整块存储在StorageID
+	Parts       []StripPart `json:"parts,omitempty"`
+	
+	// 数据块的混淆方式（见drivers.ObfuscationConfig），为空表示未混淆
+	Obfuscation string   `json:"obfuscation,omitempty"`
+}
+
+// 下载和删除时使用的远程ID
+func (s StripMetadata) RemoteKey() string {
+	if s.RemoteID != "" {
+		return s.RemoteID
+	}
+	return s.StorageID
+}
+
+// 子块元数据
+type StripPart struct {
+	PartIndex   int      `json:"part_index"`
+	StorageID   string   `json:"storage_id"`
+	RemoteID    string   `json:"remote_id,omitempty"`
+	Size        int64    `json:"size"`
+}
+
+func (p StripPart) RemoteKey() string {
+	if p.RemoteID != "" {
+		return p.RemoteID
+	}
+	return p.StorageID
 }
 
 // 驱动器信息
 type DriverInfo struct {
 	Name        string    `json:"name"`
-	Health      string    `json:"health"` // healthy, degraded, failed
+	Health      string    `json:"health"` // healthy, degraded, failed, draining, removable
 	LastCheck   time.Time `json:"last_check"`
 	UsedSpace   int64     `json:"used_space"`
 	TotalSpace  int64     `json:"total_space"`
 }
 
-// 元数据管理器
+// 元数据管理器。存储后端实现HeaderStore时启动只加载文件头，列表、搜索、版本等接口
+// 返回的元数据可能不含条带，需要条带时用GetFileMetadata读取
 type MetadataManager struct {
 	basePath      string
-	metadata      map[string]*FileMetadata
+	store         MetadataStore
+	shards        []*metadataShard    // 按文件ID分片的文件元数据
+	fileLocks     fileLocks
+	nameIndex     map[string][]string // 文件名 -> 文件ID列表
+	tagIndex      map[string]map[string]bool // 标签 -> 文件ID集合
+	hashIndex     map[string]map[string]bool // 内容哈希 -> 文件ID集合
 	driverHealth  map[string]*DriverInfo
-	mu            sync.RWMutex
+	dirs          map[string]*DirInfo // 显式创建的虚拟目录
+	retention     RetentionPolicy     // 旧版本的保留策略
+	trash         TrashConfig         // 回收站配置
+	quotas        map[string]int64    // 用户 -> 配额（字节）
+	reserved      map[string]int64    // 用户 -> 进行中的上传预留的配额（字节）
+	capacityCfg   CapacityConfig      // 驱动器容量预算
+	placement     placementIndex      // 每个驱动器上放置的字节数和块的引用计数
+	dirIndex      dirIndex            // 每个目录下的文件，判断目录是否存在和列出目录
+	wal           *metadataWAL        // 预写日志，未启用时为nil
+	audit         *AuditLog           // 操作审计日志
+	backups       *Backups            // 元数据备份，未设置时为nil
+	stopWatch     context.CancelFunc  // 停止接收其他实例的变更通知，存储后端不支持时为nil
+	events        eventBus            // 变更事件的订阅者
+	mu            sync.RWMutex        // 保护索引、目录和配置，不保护分片中的元数据（见locks.go）
 }
 
+// 使用JSON存储创建元数据管理器
 func NewMetadataManager(basePath string) (*MetadataManager, error) {
+	return OpenMetadataManager(basePath, StoreConfig{})
+}
+
+// 按配置选择存储后端创建元数据管理器，上传进度等辅助数据仍保存在basePath下
+func OpenMetadataManager(basePath string, cfg StoreConfig) (*MetadataManager, error) {
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, fmt.Errorf("创建元数据目录失败: %v", err)
 	}
 	
+	st, err := OpenStore(basePath, cfg)
+	if err != nil {
+		return nil, err
+	}
+	
+	mm, err := NewMetadataManagerWithStore(basePath, st)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+	if err := mm.ApplyConfig(cfg); err != nil {
+		mm.Close()
+		return nil, err
+	}
+	return mm, nil
+}
+
+// 使用指定的存储后端创建元数据管理器，启动时从后端加载所有元数据
+func NewMetadataManagerWithStore(basePath string, st MetadataStore) (*MetadataManager, error) {
 	mm := &MetadataManager{
 		basePath:     basePath,
-		metadata:     make(map[string]*FileMetadata),
+		store:        st,
+		shards:       newMetadataShards(),
+		nameIndex:    make(map[string][]string),
+		tagIndex:     make(map[string]map[string]bool),
+		hashIndex:    make(map[string]map[string]bool),
 		driverHealth: make(map[string]*DriverInfo),
+		dirs:         make(map[string]*DirInfo),
+		reserved:     make(map[string]int64),
+		trash:        defaultTrashConfig(),
+		audit:        OpenAuditLog(basePath),
 	}
 	
 	// 加载已有的元数据
 	if err := mm.loadMetadata(); err != nil {
 		return nil, err
 	}
+	// 多个实例共用存储后端时接收其他实例的修改
+	if err := mm.watchChanges(); err != nil {
+		return nil, err
+	}
 	
 	return mm, nil
 }
 
+// 应用metadata段中与存储后端无关的配置：旧版本的保留策略、回收站、用户配额、容量预算和预写日志。
+// 上次遗留的预写日志在这里重放
+func (mm *MetadataManager) ApplyConfig(cfg StoreConfig) error {
+	retention, err := cfg.Versioning()
+	if err != nil {
+		return err
+	}
+	trash, err := cfg.Trash()
+	if err != nil {
+		return err
+	}
+	quotas, err := cfg.Users()
+	if err != nil {
+		return err
+	}
+	capacity, err := cfg.Capacity()
+	if err != nil {
+		return err
+	}
+	walCfg, err := cfg.WAL()
+	if err != nil {
+		return err
+	}
+	
+	mm.SetRetention(retention)
+	mm.SetTrashConfig(trash)
+	mm.SetUserQuotas(quotas)
+	mm.SetCapacityConfig(capacity)
+	if _, shared := mm.changeNotifier(); shared && walCfg.Enabled {
+		// 日志中的修改在检查点之前对其他实例不可见
+		fmt.Println("多个实例共用存储后端，不启用预写日志")
+		walCfg.Enabled = false
+	}
+	return mm.openWAL(walCfg)
+}
+
+// 当前使用的存储后端
+func (mm *MetadataManager) Store() MetadataStore {
+	return mm.store
+}
+
+// 将预写日志中的修改写回后关闭存储后端
+func (mm *MetadataManager) Close() error {
+	mm.mu.Lock()
+	wal := mm.wal
+	mm.wal = nil
+	mm.mu.Unlock()
+	
+	if mm.stopWatch != nil {
+		mm.stopWatch()
+	}
+	var walErr error
+	if wal != nil {
+		walErr = wal.close()
+	}
+	mm.audit.Close()
+	if err := mm.store.Close(); err != nil {
+		return err
+	}
+	return walErr
+}
+
 // 保存文件元数据
 func (mm *MetadataManager) SaveFileMetadata(fm *FileMetadata) error {
-	mm.mu.Lock()
-	defer mm.mu.Unlock()
+	return mm.SaveFileMetadataBatch([]*FileMetadata{fm})
+}
+
+// 获取已提交文件的元数据
+func (mm *MetadataManager) GetFileMetadata(fileID string) (*FileMetadata, error) {
+	fm, err := mm.GetFileMetadataIncludingPending(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if !fm.IsCommitted() {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotCommitted, fileID)
+	}
+	return fm, nil
+}
+
+// 获取文件元数据，包括尚未提交的文件。内存中没有或只有文件头时只锁住该文件读取存储后端
+func (mm *MetadataManager) GetFileMetadataIncludingPending(fileID string) (*FileMetadata, error) {
+	// 首先从内存缓存查找
+	if fm, partial, ok := mm.cached(fileID); ok && !partial {
+		return fm, nil
+	}
 	
-	fm.UpdatedAt = time.Now()
-	mm.metadata[fm.FileID] = fm
+	unlock := mm.fileLocks.lock(fileID)
+	defer unlock()
+	return mm.getFileLocked(fileID)
+}
+
+// 获取文件元数据，需要时从存储后端读取，调用方持有该文件的文件锁
+func (mm *MetadataManager) getFileLocked(fileID string) (*FileMetadata, error) {
+	// 只加载了文件头时补全条带
+	if fm, _, ok := mm.cached(fileID); ok {
+		return mm.loadDetail(fm)
+	}
 	
-	// 保存到文件
-	filePath := filepath.Join(mm.basePath, fm.FileID+".json")
-	data, err := json.MarshalIndent(fm, "", "  ")
+	// 从存储加载（可能由其他进程写入）
+	fm, err := mm.store.GetFile(fileID)
 	if err != nil {
-		return fmt.Errorf("序列化元数据失败: %v", err)
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, fileID)
+		}
+		return nil, fmt.Errorf("读取元数据失败: %v", err)
 	}
 	
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return fmt.Errorf("写入元数据文件失败: %v", err)
+	// 缓存到内存
+	mm.mu.Lock()
+	mm.publish(fm, false)
+	mm.indexFile(fm)
+	mm.mu.Unlock()
+	
+	return fm, nil
+}
+
+// 写入提交标记，文件从此对列表和读取可见；同一路径上原来的文件变为旧版本
+func (mm *MetadataManager) CommitFile(fileID string) error {
+	fm, err := mm.GetFileMetadataIncludingPending(fileID)
+	if err != nil {
+		return err
 	}
 	
+	committed := *fm
+	now := time.Now()
+	if err := mm.supersede(&committed, now); err != nil {
+		return err
+	}
+	committed.State = FileStateCommitted
+	committed.CommittedAt = now
+	if err := mm.SaveFileMetadata(&committed); err != nil {
+		return err
+	}
+	
+	// 上传到此结束，不再需要续传
+	mm.clearUploads(fileID)
 	return nil
 }
 
-// 获取文件元数据
-func (mm *MetadataManager) GetFileMetadata(fileID string) (*FileMetadata, error) {
+// 删除文件元数据，文件的条带块需先从驱动器上删除
+func (mm *MetadataManager) DeleteFileMetadata(fileID string) error {
+	unlock := mm.fileLocks.lock(fileID)
+	defer unlock()
+	return mm.deleteFileLocked(fileID)
+}
+
+// 调用方持有该文件的文件锁
+func (mm *MetadataManager) deleteFileLocked(fileID string) error {
+	pending := false
+	mm.mu.Lock()
+	old := mm.file(fileID)
+	if old != nil {
+		pending = old.State == FileStatePending
+		mm.unindexFile(old)
+		mm.unpublish(fileID)
+	}
+	mm.mu.Unlock()
+	
+	if wal := mm.currentWAL(); wal != nil {
+		if err := wal.logDelete(fileID); err != nil {
+			return err
+		}
+	}
+	
+	if err := mm.store.DeleteFile(fileID); err != nil {
+		return err
+	}
+	// 未完成的上传被删除后不能再续传
+	if pending {
+		mm.clearUploads(fileID)
+	}
+	mm.publishFileChange(old, nil)
+	return nil
+}
+
+// 替换文件中的一个块（块迁移到其他驱动器或重建后），只写回该块；启用预写日志时只追加到日志
+func (mm *MetadataManager) UpdateStrip(fileID string, stripeIndex int, oldStorageID string, strip StripMetadata) error {
+	unlock := mm.fileLocks.lock(fileID)
+	defer unlock()
+	
+	fm, err := mm.getFileLocked(fileID)
+	if err != nil {
+		return err
+	}
+	
+	updated := cloneForUpdate(fm)
+	if err := replaceStrip(updated, stripeIndex, oldStorageID, strip); err != nil {
+		return err
+	}
+	updated.UpdatedAt = time.Now()
+	mm.publish(updated, false)
+	if wal := mm.currentWAL(); wal != nil {
+		return wal.logStrip(fileID, stripeIndex, oldStorageID, strip)
+	}
+	return mm.store.UpdateStrip(fileID, stripeIndex, oldStorageID, strip)
+}
+
+// 根据文件名查找已提交的文件ID，同名文件按创建时间从旧到新返回。
+// 以/开头时按虚拟路径查找，只匹配该目录下的文件
+func (mm *MetadataManager) FindFileIDsByName(fileName string) []string {
 	mm.mu.RLock()
 	defer mm.mu.RUnlock()
 	
-	// 首先从内存缓存查找
-	if fm, exists := mm.metadata[fileID]; exists {
-		return fm, nil
+	dir, matchDir := "", false
+	if strings.HasPrefix(fileName, "/") {
+		dir, fileName = splitPath(fileName)
+		matchDir = true
 	}
 	
-	// 从文件加载
-	filePath := filepath.Join(mm.basePath, fileID+".json")
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("文件不存在: %s", fileID)
+	var files []*FileMetadata
+	for _, id := range mm.nameIndex[fileName] {
+		fm := mm.file(id)
+		if matchDir && fm.Dir != dir {
+			continue
+		}
+		if fm.IsCurrent() {
+			files = append(files, fm)
 		}
-		return nil, fmt.Errorf("读取元数据失败: %v", err)
 	}
 	
-	var fm FileMetadata
-	if err := json.Unmarshal(data, &fm); err != nil {
-		return nil, fmt.Errorf("解析元数据失败: %v", err)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].CreatedAt.Before(files[j].CreatedAt)
+	})
+	
+	ids := make([]string, 0, len(files))
+	for _, fm := range files {
+		ids = append(ids, fm.FileID)
+	}
+	return ids
+}
+
+func (mm *MetadataManager) indexName(fileName, fileID string) {
+	for _, id := range mm.nameIndex[fileName] {
+		if id == fileID {
+			return
+		}
+	}
+	mm.nameIndex[fileName] = append(mm.nameIndex[fileName], fileID)
+}
+
+func (mm *MetadataManager) unindexName(fileName, fileID string) {
+	ids := mm.nameIndex[fileName]
+	for i, id := range ids {
+		if id == fileID {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(mm.nameIndex, fileName)
+	} else {
+		mm.nameIndex[fileName] = ids
+	}
+}
+
+// 获取所有已提交文件的完整元数据，不包括旧版本
+func (mm *MetadataManager) ListFileMetadata() []*FileMetadata {
+	return mm.listDetails((*FileMetadata).IsCurrent)
+}
+
+// 获取所有文件的完整元数据，包括尚未提交的文件（用于迁移、清理等维护操作）
+func (mm *MetadataManager) ListAllFileMetadata() []*FileMetadata {
+	return mm.listDetails(nil)
+}
+
+// 满足条件的文件，只加载了文件头的先补全条带，读取失败的文件跳过
+func (mm *MetadataManager) listDetails(match func(*FileMetadata) bool) []*FileMetadata {
+	type candidate struct {
+		fm      *FileMetadata
+		partial bool
+	}
+	var candidates []candidate
+	for _, s := range mm.shards {
+		s.mu.RLock()
+		for id, fm := range s.files {
+			if match == nil || match(fm) {
+				candidates = append(candidates, candidate{fm, s.partial[id]})
+			}
+		}
+		s.mu.RUnlock()
 	}
 	
-	// 缓存到内存
-	mm.metadata[fileID] = &fm
+	// 逐个文件读取条带，不阻塞其他文件的读写
+	files := make([]*FileMetadata, 0, len(candidates))
+	for _, c := range candidates {
+		fm := c.fm
+		if c.partial {
+			var err error
+			if fm, err = mm.GetFileMetadataIncludingPending(fm.FileID); err != nil {
+				fmt.Printf("警告: %v\n", err)
+				continue
+			}
+		}
+		files = append(files, fm)
+	}
 	
-	return &fm, nil
+	return files
 }
 
-// 记录驱动器健康状态
+// 运维操作设置的驱动器状态（排空、已迁空），健康监控报告的状态不覆盖它们
+func operatorHealth(health string) bool {
+	return health == "draining" || health == "removable"
+}
+
+// 记录驱动器健康状态，状态变化时发布EventDriverHealth。
+// 排空中（draining）和已迁空（removable）的驱动器是有意移出阵列的，健康监控报告的状态不覆盖它们，只更新空间信息
 func (mm *MetadataManager) UpdateDriverHealth(driverName, health string, usedSpace, totalSpace int64) {
-	mm.mu.Lock()
-	defer mm.mu.Unlock()
-	
-	mm.driverHealth[driverName] = &DriverInfo{
+	info := &DriverInfo{
 		Name:       driverName,
 		Health:     health,
 		LastCheck:  time.Now(),
 		UsedSpace:  usedSpace,
 		TotalSpace: totalSpace,
 	}
+	mm.mu.Lock()
+	old := mm.driverHealth[driverName]
+	if old != nil && operatorHealth(old.Health) && !operatorHealth(health) {
+		info.Health = old.Health
+	}
+	mm.driverHealth[driverName] = info
+	mm.mu.Unlock()
+	
+	mm.saveDriverHealth(info, old)
+}
+
+// 将驱动器标记为排空中，或取消排空恢复为healthy（之后由健康监控更新）。空间信息保持不变
+func (mm *MetadataManager) SetDriverDraining(driverName string, draining bool) {
+	mm.mu.Lock()
+	old := mm.driverHealth[driverName]
+	if !draining && (old == nil || old.Health != "draining") {
+		mm.mu.Unlock()
+		return
+	}
+	info := &DriverInfo{Name: driverName, Health: "healthy", LastCheck: time.Now()}
+	if draining {
+		info.Health = "draining"
+	}
+	if old != nil {
+		info.UsedSpace, info.TotalSpace = old.UsedSpace, old.TotalSpace
+	}
+	mm.driverHealth[driverName] = info
+	mm.mu.Unlock()
+	
+	mm.saveDriverHealth(info, old)
+}
+
+// 保存驱动器状态，与之前的状态不同时发布事件
+func (mm *MetadataManager) saveDriverHealth(info, old *DriverInfo) {
+	if err := mm.store.SaveDriver(info); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+	if old == nil || old.Health != info.Health {
+		mm.Publish(Event{Type: EventDriverHealth, Time: info.LastCheck, Driver: info.Name, Health: info.Health})
+	}
+}
+
+// 排空中的驱动器，按名称排序
+func (mm *MetadataManager) DrainingDrivers() []string {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	
+	var names []string
+	for name, info := range mm.driverHealth {
+		if info.Health == "draining" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 // 获取不健康的驱动器列表
@@ -164,24 +596,22 @@ func (mm *MetadataManager) GetUnhealthyDrivers() []string {
 
 // 为RAID5记录奇偶校验分布
 func (mm *MetadataManager) RecordParityDistribution(fileID string, stripeIndex, parityDriverIndex int) error {
-	fm, err := mm.GetFileMetadata(fileID)
+	fm, err := mm.GetFileMetadataIncludingPending(fileID)
 	if err != nil {
 		return err
 	}
-	
-	mm.mu.Lock()
-	defer mm.mu.Unlock()
+	updated := cloneForUpdate(fm)
 	
 	// 确保有足够的条带
-	for len(fm.Stripes) <= stripeIndex {
-		fm.Stripes = append(fm.Stripes, StripeMetadata{
-			StripeIndex: len(fm.Stripes),
+	for len(updated.Stripes) <= stripeIndex {
+		updated.Stripes = append(updated.Stripes, StripeMetadata{
+			StripeIndex: len(updated.Stripes),
 			Strips:      make([]StripMetadata, 0),
 		})
 	}
 	
 	// 记录奇偶校验位置
-	stripe := &fm.Stripes[stripeIndex]
+	stripe := &updated.Stripes[stripeIndex]
 	if stripe.ParityStrip == nil {
 		stripe.ParityStrip = &StripMetadata{
 			StripIndex: parityDriverIndex,
@@ -190,32 +620,88 @@ func (mm *MetadataManager) RecordParityDistribution(fileID string, stripeIndex,
 		}
 	}
 	
-	return mm.SaveFileMetadata(fm)
+	return mm.SaveFileMetadata(updated)
 }
 
-// 加载所有元数据
+// 记录上传中的文件写入完成的一个条带（RAID控制器在条带的所有块写入成功后调用）。
+// 文件元数据尚不存在时创建未提交的记录，上传中断后可以据此找到已写入的条带块
+func (mm *MetadataManager) RecordStripe(fileID string, stripe StripeMetadata) error {
+	unlock := mm.fileLocks.lock(fileID)
+	defer unlock()
+	
+	var updated *FileMetadata
+	if fm, _, exists := mm.cached(fileID); !exists {
+		updated = &FileMetadata{FileID: fileID, State: FileStatePending, CreatedAt: time.Now()}
+	} else if fm.State != FileStatePending {
+		return fmt.Errorf("文件不在上传中，不能写入条带: %s", fileID)
+	} else if fm, err := mm.loadDetail(fm); err != nil {
+		return err
+	} else {
+		updated = cloneForUpdate(fm)
+	}
+	
+	setStripe(updated, stripe)
+	updated.UpdatedAt = time.Now()
+	mm.publish(updated, false)
+	if wal := mm.currentWAL(); wal != nil {
+		return wal.logStripe(fileID, stripe)
+	}
+	// 未启用预写日志时只写入新的条带，不重写整个文件
+	return writeBatch(mm.store, []FileWrite{{File: updated, Stripes: []int{stripe.StripeIndex}}})
+}
+
+// 删除上传被放弃的文件的未提交记录，已提交的文件不受影响
+func (mm *MetadataManager) DiscardPending(fileID string) error {
+	unlock := mm.fileLocks.lock(fileID)
+	defer unlock()
+	
+	if fm := mm.file(fileID); fm == nil || fm.State != FileStatePending {
+		return nil
+	}
+	return mm.deleteFileLocked(fileID)
+}
+
+// 设置条带，条带不足时补齐
+func setStripe(fm *FileMetadata, stripe StripeMetadata) {
+	for len(fm.Stripes) <= stripe.StripeIndex {
+		fm.Stripes = append(fm.Stripes, StripeMetadata{StripeIndex: len(fm.Stripes)})
+	}
+	fm.Stripes[stripe.StripeIndex] = stripe
+}
+
+// 加载所有元数据，存储后端支持时只加载文件头
 func (mm *MetadataManager) loadMetadata() error {
-	entries, err := os.ReadDir(mm.basePath)
+	var files []*FileMetadata
+	var err error
+	hs, headersOnly := mm.headerStore()
+	if headersOnly {
+		files, err = hs.ListFileHeaders()
+	} else {
+		files, err = mm.store.ListFiles()
+	}
 	if err != nil {
 		return err
 	}
+	for _, fm := range files {
+		mm.publish(fm, headersOnly)
+		mm.indexFile(fm)
+	}
 	
-	for _, entry := range entries {
-		if filepath.Ext(entry.Name()) == ".json" {
-			filePath := filepath.Join(mm.basePath, entry.Name())
-			data, err := os.ReadFile(filePath)
-			if err != nil {
-				fmt.Printf("警告: 无法读取元数据文件 %s: %v\n", filePath, err)
-				continue
-			}
-			
-			var fm FileMetadata
-			if err := json.Unmarshal(data, &fm); err != nil {
-				fmt.Printf("警告: 无法解析元数据文件 %s: %v\n", filePath, err)
-				continue
-			}
-			
-			mm.metadata[fm.FileID] = &fm
+	infos, err := mm.store.ListDrivers()
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		mm.driverHealth[info.Name] = info
+	}
+	
+	if ds, ok := mm.store.(DirectoryStore); ok {
+		dirs, err := ds.ListDirs()
+		if err != nil {
+			return err
+		}
+		for _, dir := range dirs {
+			mm.dirs[dir.Path] = dir
 		}
 	}
 	
@@ -0,0 +1,267 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrQuotaExceeded = errors.New("超出用户配额")
+
+// 用户配置（metadata段中的users）。quota为该用户所有文件（包括旧版本、回收站中和未提交的文件）
+// 的总大小上限，单位K/M/G/T按1024计；不设置或未列出的用户不限
+//
+//	metadata:
+//	  users:
+//	    alice:
+//	      quota: 200G
+//	    bob:
+//	      quota: 50G
+type UserConfig struct {
+	Quota string `yaml:"quota"`
+}
+
+// 读取metadata段中的users配置，返回各用户的配额（字节）
+func (c StoreConfig) Users() (map[string]int64, error) {
+	var section struct {
+		Users map[string]UserConfig `yaml:"users"`
+	}
+	if err := c.Decode(&section); err != nil {
+		return nil, err
+	}
+	quotas := make(map[string]int64)
+	for name, user := range section.Users {
+		if name == "" {
+			return nil, errors.New("users中的用户名不能为空")
+		}
+		if user.Quota == "" {
+			continue
+		}
+		quota, err := parseSize(user.Quota)
+		if err != nil {
+			return nil, fmt.Errorf("用户 %s 的quota无效: %v", name, err)
+		}
+		quotas[name] = quota
+	}
+	return quotas, nil
+}
+
+// 设置各用户的配额（字节），未列出的用户不限
+func (mm *MetadataManager) SetUserQuotas(quotas map[string]int64) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.quotas = quotas
+}
+
+// 用户的文件数和占用的空间
+type UserUsage struct {
+	Owner string
+	Files int
+	Bytes int64
+	Quota int64 // 0表示不限
+}
+
+// 所有用户（包括不属于任何用户的文件，Owner为空）的用量，按用户名排列
+func (mm *MetadataManager) UserUsage() []UserUsage {
+	byOwner := make(map[string]*UserUsage)
+	mm.eachFile(func(fm *FileMetadata) {
+		u := byOwner[fm.Owner]
+		if u == nil {
+			u = &UserUsage{Owner: fm.Owner}
+			byOwner[fm.Owner] = u
+		}
+		u.Files++
+		u.Bytes += fm.FileSize
+	})
+
+	mm.mu.RLock()
+	for owner, quota := range mm.quotas {
+		u := byOwner[owner]
+		if u == nil {
+			u = &UserUsage{Owner: owner}
+			byOwner[owner] = u
+		}
+		u.Quota = quota
+	}
+	mm.mu.RUnlock()
+
+	usage := make([]UserUsage, 0, len(byOwner))
+	for _, u := range byOwner {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Owner < usage[j].Owner })
+	return usage
+}
+
+// 用户命名空间：一个守护进程为多人服务时，每个用户只能看到和修改自己的文件（Owner相同），
+// 不同用户的同名路径是不同的文件，各自有自己的版本。用户创建的目录只有自己可见，
+// 直接用MetadataManager创建的目录各用户共用。
+//
+// owner为空的命名空间不做隔离，与直接使用MetadataManager相同：列表和查找包括所有用户的文件，
+// 按路径管理的版本只包括不属于任何用户的文件
+type Namespace struct {
+	mm    *MetadataManager
+	owner string
+}
+
+// 用户的命名空间，上传时将FileMetadata.Owner设为owner
+func (mm *MetadataManager) Namespace(owner string) *Namespace {
+	return &Namespace{mm: mm, owner: owner}
+}
+
+func (ns *Namespace) Owner() string {
+	return ns.owner
+}
+
+func (ns *Namespace) owns(fm *FileMetadata) bool {
+	return ns.owner == "" || fm.Owner == ns.owner
+}
+
+// 只返回自己的文件，其他用户的文件视为不存在
+func (ns *Namespace) check(fm *FileMetadata, err error) (*FileMetadata, error) {
+	if err != nil {
+		return nil, err
+	}
+	if !ns.owns(fm) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, fm.FileID)
+	}
+	return fm, nil
+}
+
+func (ns *Namespace) GetFileMetadata(fileID string) (*FileMetadata, error) {
+	return ns.check(ns.mm.GetFileMetadata(fileID))
+}
+
+func (ns *Namespace) GetFileMetadataIncludingPending(fileID string) (*FileMetadata, error) {
+	return ns.check(ns.mm.GetFileMetadataIncludingPending(fileID))
+}
+
+// 同MetadataManager.FindFileIDsByName，只包括自己的文件
+func (ns *Namespace) FindFileIDsByName(fileName string) []string {
+	var ids []string
+	for _, id := range ns.mm.FindFileIDsByName(fileName) {
+		if fm := ns.mm.file(id); fm != nil && ns.owns(fm) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// 同MetadataManager.GetByName，只包括自己的文件
+func (ns *Namespace) GetByName(filePath string) (*FileMetadata, error) {
+	ids := ns.FindFileIDsByName(filePath)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, filePath)
+	}
+	return ns.mm.GetFileMetadata(ids[len(ids)-1])
+}
+
+func (ns *Namespace) ListFiles(opts ListOptions) (*ListResult, error) {
+	opts.Owner = ns.owner
+	return ns.mm.ListFiles(opts)
+}
+
+func (ns *Namespace) Search(q SearchQuery) (*ListResult, error) {
+	q.Owner = ns.owner
+	return ns.mm.Search(q)
+}
+
+// 列出目录下的子目录和自己的文件，只包含其他用户的文件或目录的子目录不列出
+func (ns *Namespace) ListDir(dirPath string) ([]DirEntry, error) {
+	return ns.mm.listDir(dirPath, ns.owner)
+}
+
+// 创建只有自己可见的目录，参数与MetadataManager.Mkdir相同
+func (ns *Namespace) Mkdir(dirPath string, parents bool) error {
+	return ns.mm.mkdir(dirPath, parents, ns.owner)
+}
+
+// 删除自己创建的空目录，其他用户的文件和目录不影响；共用的目录返回ErrDirShared
+func (ns *Namespace) Rmdir(dirPath string) error {
+	return ns.mm.rmdir(dirPath, ns.owner)
+}
+
+// 目录是否存在：共用的目录、自己创建的目录和包含自己的文件的目录
+func (ns *Namespace) DirExists(dirPath string) bool {
+	return ns.mm.dirExists(dirPath, ns.owner)
+}
+
+func (ns *Namespace) ListTrash() []*FileMetadata {
+	var files []*FileMetadata
+	for _, fm := range ns.mm.ListTrash() {
+		if ns.owns(fm) {
+			files = append(files, fm)
+		}
+	}
+	return files
+}
+
+func (ns *Namespace) RestoreFromTrash(fileID string) (*FileMetadata, error) {
+	if _, err := ns.GetFileMetadataIncludingPending(fileID); err != nil {
+		return nil, err
+	}
+	return ns.mm.RestoreFromTrash(fileID)
+}
+
+func (ns *Namespace) ListVersions(filePath string) ([]*FileMetadata, error) {
+	return ns.mm.listVersions(ns.owner, filePath)
+}
+
+func (ns *Namespace) GetVersion(filePath string, version int) (*FileMetadata, error) {
+	return ns.mm.getVersion(ns.owner, filePath, version)
+}
+
+func (ns *Namespace) RestoreVersion(filePath string, version int) (*FileMetadata, error) {
+	return ns.mm.restoreVersion(ns.owner, filePath, version)
+}
+
+// 自己的所有文件占用的空间，包括旧版本、回收站中和未提交的文件
+func (ns *Namespace) Usage() int64 {
+	var used int64
+	ns.mm.eachFile(func(fm *FileMetadata) {
+		if fm.Owner == ns.owner {
+			used += fm.FileSize
+		}
+	})
+	return used
+}
+
+// 用户的配额，0表示不限
+func (ns *Namespace) Quota() int64 {
+	ns.mm.mu.RLock()
+	defer ns.mm.mu.RUnlock()
+	return ns.mm.quotas[ns.owner]
+}
+
+// 上传前预留size字节的配额，返回释放预留的函数。检查和预留在mm.mu中一起完成，
+// 同一用户同时进行的上传计入彼此预留的空间，不会各自通过检查后一起超出配额。
+// 文件元数据保存后其大小已计入用量，调用方此时或上传失败时释放预留，释放函数可以多次调用
+func (ns *Namespace) ReserveQuota(size int64) (func(), error) {
+	if ns.owner == "" {
+		return func() {}, nil
+	}
+
+	mm := ns.mm
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if quota := mm.quotas[ns.owner]; quota > 0 {
+		used := ns.Usage() + mm.reserved[ns.owner]
+		if used+size > quota {
+			return nil, fmt.Errorf("%w: %s 已使用 %d 字节（含进行中的上传），配额 %d 字节，本次需要 %d 字节",
+				ErrQuotaExceeded, ns.owner, used, quota, size)
+		}
+	}
+	mm.reserved[ns.owner] += size
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mm.mu.Lock()
+			defer mm.mu.Unlock()
+			if mm.reserved[ns.owner] -= size; mm.reserved[ns.owner] <= 0 {
+				delete(mm.reserved, ns.owner)
+			}
+		})
+	}, nil
+}
@@ -0,0 +1,98 @@
+package metadata
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestReserveQuotaCountsConcurrentUploads(t *testing.T) {
+	mm := newTestManager(t)
+	mm.SetUserQuotas(map[string]int64{"alice": 1000})
+	saveTestFile(t, mm, &FileMetadata{FileID: "f1", FileName: "a", Owner: "alice", FileSize: 300})
+	ns := mm.Namespace("alice")
+
+	release, err := ns.ReserveQuota(500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 已用300，另一个上传预留了500
+	if _, err := ns.ReserveQuota(300); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("期望超出配额，实际 %v", err)
+	}
+	if _, err := mm.Namespace("bob").ReserveQuota(5000); err != nil {
+		t.Fatalf("未设置配额的用户: %v", err)
+	}
+
+	release()
+	release()
+	if _, err := ns.ReserveQuota(700); err != nil {
+		t.Fatalf("释放预留后: %v", err)
+	}
+}
+
+func TestNamespaceDirectoriesAreOwned(t *testing.T) {
+	dir := t.TempDir()
+	mm := openTestManager(t, dir)
+	alice, bob := mm.Namespace("alice"), mm.Namespace("bob")
+	if err := alice.Mkdir("/photos/2024", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := mm.Mkdir("/shared", false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ns     *Namespace
+		dir    string
+		exists bool
+	}{
+		{alice, "/photos", true},
+		{alice, "/photos/2024", true},
+		{alice, "/shared", true},
+		{bob, "/photos", false},
+		{bob, "/photos/2024", false},
+		{bob, "/shared", true},
+		{mm.Namespace(""), "/photos/2024", true},
+	}
+	for _, tt := range tests {
+		if got := tt.ns.DirExists(tt.dir); got != tt.exists {
+			t.Errorf("%q的DirExists(%s) = %v，期望 %v", tt.ns.Owner(), tt.dir, got, tt.exists)
+		}
+	}
+	entries, err := bob.ListDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryNames(entries), []string{"shared"}; !slices.Equal(got, want) {
+		t.Errorf("bob的ListDir(/) = %v，期望 %v", got, want)
+	}
+
+	// 同名目录各自独立
+	if err := bob.Mkdir("/photos", false); err != nil {
+		t.Fatalf("bob创建同名目录: %v", err)
+	}
+	if err := bob.Rmdir("/shared"); !errors.Is(err, ErrDirShared) {
+		t.Errorf("删除共用的目录: %v", err)
+	}
+	if err := alice.Rmdir("/photos"); !errors.Is(err, ErrDirNotEmpty) {
+		t.Errorf("删除含子目录的目录: %v", err)
+	}
+	for _, p := range []string{"/photos/2024", "/photos"} {
+		if err := alice.Rmdir(p); err != nil {
+			t.Fatalf("alice删除%s: %v", p, err)
+		}
+	}
+	if alice.DirExists("/photos") || !bob.DirExists("/photos") {
+		t.Error("删除alice的目录影响了bob的同名目录")
+	}
+
+	// 重新打开后目录仍属于原来的用户
+	if err := mm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened := openTestManager(t, dir)
+	if !reopened.Namespace("bob").DirExists("/photos") || reopened.Namespace("alice").DirExists("/photos") {
+		t.Error("重新打开后目录的所有者不正确")
+	}
+}
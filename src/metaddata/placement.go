@@ -0,0 +1,183 @@
+package metadata
+
+import (
+	"sync"
+	"time"
+)
+
+// 驱动器上的一个条带块
+type chunkKey struct {
+	driver string
+	id     string // 远程ID，拆分成子块时为第一个子块的远程ID
+}
+
+func stripChunk(strip StripMetadata) chunkKey {
+	id := strip.RemoteKey()
+	if id == "" && len(strip.Parts) > 0 {
+		id = strip.Parts[0].RemoteKey()
+	}
+	return chunkKey{driver: strip.DriverName, id: id}
+}
+
+// 文件引用的每个块（数据块、校验块和本地副本）及其字节数，空洞不占用空间
+func fileChunks(fm *FileMetadata) map[chunkKey]int64 {
+	chunks := make(map[chunkKey]int64)
+	add := func(strip StripMetadata) {
+		if key := stripChunk(strip); key.id != "" {
+			chunks[key] = strip.StripSize
+		}
+	}
+	for _, stripe := range fm.Stripes {
+		for _, strip := range stripe.Strips {
+			add(strip)
+		}
+		if stripe.ParityStrip != nil {
+			add(*stripe.ParityStrip)
+		}
+		if stripe.LocalCopy != nil {
+			add(*stripe.LocalCopy)
+		}
+	}
+	return chunks
+}
+
+// 按文件记录引用的块，随内存中元数据的发布和删除更新，用于统计驱动器放置量和块的引用计数。
+// 多个文件引用的块在放置量中只计一次。锁在分片锁之后获取（见locks.go）
+type placementIndex struct {
+	mu      sync.Mutex
+	files   map[string]filePlacement
+	chunks  map[chunkKey]*chunkRef
+	total   map[string]int64 // 驱动器 -> 被引用的块的字节数
+	unknown map[string]bool  // 只加载了文件头、引用的块尚未统计的文件
+}
+
+type filePlacement struct {
+	created time.Time
+	chunks  map[chunkKey]int64
+}
+
+type chunkRef struct {
+	refs int
+	size int64
+}
+
+// 发布元数据时调用。只有文件头时保留之前的统计，没有统计过则记为未知
+func (p *placementIndex) publish(fm *FileMetadata, partial bool) {
+	if partial {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if _, ok := p.files[fm.FileID]; !ok {
+			if p.unknown == nil {
+				p.unknown = make(map[string]bool)
+			}
+			p.unknown[fm.FileID] = true
+		}
+		return
+	}
+	p.set(fm.FileID, fm.CreatedAt, fileChunks(fm))
+}
+
+func (p *placementIndex) set(fileID string, created time.Time, chunks map[chunkKey]int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setLocked(fileID, created, chunks)
+}
+
+func (p *placementIndex) setLocked(fileID string, created time.Time, chunks map[chunkKey]int64) {
+	p.removeLocked(fileID)
+	if p.files == nil {
+		p.files = make(map[string]filePlacement)
+		p.chunks = make(map[chunkKey]*chunkRef)
+		p.total = make(map[string]int64)
+	}
+	p.files[fileID] = filePlacement{created: created, chunks: chunks}
+	for key, size := range chunks {
+		ref := p.chunks[key]
+		if ref == nil {
+			ref = &chunkRef{size: size}
+			p.chunks[key] = ref
+			p.total[key.driver] += size
+		}
+		ref.refs++
+	}
+}
+
+func (p *placementIndex) remove(fileID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeLocked(fileID)
+}
+
+func (p *placementIndex) removeLocked(fileID string) {
+	delete(p.unknown, fileID)
+	old, ok := p.files[fileID]
+	if !ok {
+		return
+	}
+	for key := range old.chunks {
+		ref := p.chunks[key]
+		if ref == nil {
+			continue
+		}
+		if ref.refs--; ref.refs > 0 {
+			continue
+		}
+		delete(p.chunks, key)
+		if p.total[key.driver] -= ref.size; p.total[key.driver] <= 0 {
+			delete(p.total, key.driver)
+		}
+	}
+	delete(p.files, fileID)
+}
+
+func (p *placementIndex) pending() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]string, 0, len(p.unknown))
+	for id := range p.unknown {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (p *placementIndex) placed(driverName string) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total[driverName]
+}
+
+// 每个驱动器的总放置量，以及since之后创建的文件的放置量（按文件统计，共用的块每个文件各计一次）
+func (p *placementIndex) totals(since time.Time) (map[string]int64, map[string]int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	placed := make(map[string]int64, len(p.total))
+	for name, n := range p.total {
+		placed[name] = n
+	}
+	recent := make(map[string]int64)
+	for _, f := range p.files {
+		if f.created.Before(since) {
+			continue
+		}
+		for key, size := range f.chunks {
+			recent[key.driver] += size
+		}
+	}
+	return placed, recent
+}
+
+// 引用该块的文件数，exclude不为空时不计该文件
+func (p *placementIndex) refs(key chunkKey, exclude string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ref := p.chunks[key]
+	if ref == nil {
+		return 0
+	}
+	n := ref.refs
+	if _, ok := p.files[exclude].chunks[key]; ok {
+		n--
+	}
+	return n
+}
@@ -0,0 +1,492 @@
+package metadata
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis中的格式版本，记录在meta哈希中
+const redisSchemaVersion = 1
+
+const (
+	defaultRedisURL    = "redis://127.0.0.1:6379/0"
+	defaultRedisPrefix = "panmatrix"
+
+	// 单个命令的超时
+	redisTimeout = 10 * time.Second
+
+	// 乐观锁冲突（WATCH的键被其他实例修改）时的重试次数
+	redisMaxRetries = 16
+
+	// ListFiles每批读取的文件数
+	redisBatchSize = 500
+)
+
+func init() {
+	RegisterStore(BackendRedis, func(basePath string, cfg StoreConfig) (MetadataStore, error) {
+		var redisCfg struct {
+			RedisURL    string `yaml:"redis_url"`
+			RedisPrefix string `yaml:"redis_prefix"`
+		}
+		if err := cfg.Decode(&redisCfg); err != nil {
+			return nil, err
+		}
+		return OpenRedisStore(redisCfg.RedisURL, redisCfg.RedisPrefix, basePath)
+	})
+}
+
+// Redis存储：多个PanMatrix实例共用同一份元数据。每个文件的完整元数据是一个键，文件头另外记录在
+// headers哈希中，启动时只读取文件头。写入在MULTI事务中完成并发布变更通知，改写单个块用WATCH乐观锁，
+// 被其他实例并发修改时重试
+//
+// 键（prefix默认为panmatrix）：
+//
+//	<prefix>:meta           哈希，格式版本
+//	<prefix>:file:<file_id> 完整的文件元数据
+//	<prefix>:headers        哈希，file_id -> 文件头
+//	<prefix>:drivers        哈希，驱动器名 -> 驱动器状态
+//	<prefix>:dirs           哈希，目录路径 -> 显式创建的目录
+//	<prefix>:uploads        哈希，内容哈希 -> 上传进度
+//	<prefix>:changes        频道，修改或删除的文件ID
+type RedisStore struct {
+	client   *redis.Client
+	prefix   string
+	instance string // 变更通知中的实例标识，收到自己发布的通知时忽略
+}
+
+// 连接Redis，新建的元数据会导入jsonDir中已有的JSON元数据
+func OpenRedisStore(url, prefix, jsonDir string) (*RedisStore, error) {
+	if url == "" {
+		url = defaultRedisURL
+	}
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("redis_url无效: %v", err)
+	}
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("生成实例标识失败: %v", err)
+	}
+	s := &RedisStore{client: redis.NewClient(opts), prefix: strings.TrimSuffix(prefix, ":"), instance: hex.EncodeToString(id[:])}
+
+	ctx, cancel := s.op()
+	defer cancel()
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		s.client.Close()
+		return nil, fmt.Errorf("连接Redis失败: %v", err)
+	}
+
+	created, err := s.init(ctx)
+	if err != nil {
+		s.client.Close()
+		return nil, err
+	}
+	if created {
+		if err := s.importJSON(jsonDir); err != nil {
+			s.client.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *RedisStore) op() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), redisTimeout)
+}
+
+func (s *RedisStore) key(parts ...string) string {
+	return s.prefix + ":" + strings.Join(parts, ":")
+}
+
+func (s *RedisStore) fileKey(fileID string) string {
+	return s.key("file", fileID)
+}
+
+// 检查格式版本，返回元数据是否为新建。多个实例同时启动时只有一个记为新建
+func (s *RedisStore) init(ctx context.Context) (bool, error) {
+	meta := s.key("meta")
+	created, err := s.client.HSetNX(ctx, meta, "version", strconv.Itoa(redisSchemaVersion)).Result()
+	if err != nil {
+		return false, fmt.Errorf("初始化Redis元数据失败: %v", err)
+	}
+	if created {
+		return true, nil
+	}
+
+	raw, err := s.client.HGet(ctx, meta, "version").Result()
+	if err != nil {
+		return false, fmt.Errorf("读取Redis元数据版本失败: %v", err)
+	}
+	version, err := strconv.Atoi(raw)
+	if err != nil {
+		return false, fmt.Errorf("Redis元数据版本无效: %q", raw)
+	}
+	if version > redisSchemaVersion {
+		return false, fmt.Errorf("Redis元数据版本%d高于当前程序支持的版本%d", version, redisSchemaVersion)
+	}
+	return false, nil
+}
+
+// 从JSON存储切换到Redis时导入已有的文件元数据和目录，JSON文件保留不动
+func (s *RedisStore) importJSON(dir string) error {
+	files, err := NewJSONStore(dir).ListFiles()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取JSON元数据失败: %v", err)
+	}
+	dirs, err := NewJSONStore(dir).ListDirs()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(dirs) == 0 {
+		return nil
+	}
+
+	for _, fm := range files {
+		if err := s.SaveFile(fm); err != nil {
+			return fmt.Errorf("导入元数据 %s 失败: %v", fm.FileID, err)
+		}
+	}
+	for _, d := range dirs {
+		if err := s.SaveDir(d); err != nil {
+			return fmt.Errorf("导入目录 %s 失败: %v", d.Path, err)
+		}
+	}
+	fmt.Printf("已从JSON导入%d个文件的元数据\n", len(files))
+	return nil
+}
+
+// 在事务中写入完整元数据和文件头，并通知其他实例
+func (s *RedisStore) putFile(ctx context.Context, pipe redis.Pipeliner, fm *FileMetadata) error {
+	data, err := json.Marshal(fm)
+	if err != nil {
+		return err
+	}
+	header, err := json.Marshal(fileHeader(fm))
+	if err != nil {
+		return err
+	}
+	pipe.Set(ctx, s.fileKey(fm.FileID), data, 0)
+	pipe.HSet(ctx, s.key("headers"), fm.FileID, header)
+	pipe.Publish(ctx, s.key("changes"), s.instance+" "+fm.FileID)
+	return nil
+}
+
+func (s *RedisStore) SaveFile(fm *FileMetadata) error {
+	ctx, cancel := s.op()
+	defer cancel()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return s.putFile(ctx, pipe, fm)
+	})
+	if err != nil {
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	return nil
+}
+
+// 在一个事务中写入一批文件。每个文件是一个JSON值，部分条带变化时也整体写入
+func (s *RedisStore) WriteBatch(writes []FileWrite) error {
+	ctx, cancel := s.op()
+	defer cancel()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, w := range writes {
+			if err := s.putFile(ctx, pipe, w.File); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) GetFile(fileID string) (*FileMetadata, error) {
+	ctx, cancel := s.op()
+	defer cancel()
+	data, err := s.client.Get(ctx, s.fileKey(fileID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取元数据失败: %v", err)
+	}
+	return decodeRedisFile(fileID, data)
+}
+
+func decodeRedisFile(fileID string, data []byte) (*FileMetadata, error) {
+	var fm FileMetadata
+	if err := json.Unmarshal(data, &fm); err != nil {
+		return nil, fmt.Errorf("解析元数据 %s 失败: %v", fileID, err)
+	}
+	return &fm, nil
+}
+
+// 按文件头哈希中的文件ID分批读取完整元数据，读取期间被删除的文件跳过
+func (s *RedisStore) ListFiles() ([]*FileMetadata, error) {
+	ctx, cancel := s.op()
+	defer cancel()
+	ids, err := s.client.HKeys(ctx, s.key("headers")).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取元数据失败: %v", err)
+	}
+
+	files := make([]*FileMetadata, 0, len(ids))
+	for start := 0; start < len(ids); start += redisBatchSize {
+		batch := ids[start:min(start+redisBatchSize, len(ids))]
+		keys := make([]string, len(batch))
+		for i, id := range batch {
+			keys[i] = s.fileKey(id)
+		}
+		values, err := s.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("读取元数据失败: %v", err)
+		}
+		for i, v := range values {
+			data, ok := v.(string)
+			if !ok {
+				continue
+			}
+			fm, err := decodeRedisFile(batch[i], []byte(data))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, fm)
+		}
+	}
+	return files, nil
+}
+
+// 只读取headers哈希
+func (s *RedisStore) ListFileHeaders() ([]*FileMetadata, error) {
+	ctx, cancel := s.op()
+	defer cancel()
+	headers, err := s.client.HGetAll(ctx, s.key("headers")).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取元数据失败: %v", err)
+	}
+	files := make([]*FileMetadata, 0, len(headers))
+	for id, data := range headers {
+		fm, err := decodeRedisFile(id, []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fm)
+	}
+	return files, nil
+}
+
+func (s *RedisStore) DeleteFile(fileID string) error {
+	ctx, cancel := s.op()
+	defer cancel()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.fileKey(fileID))
+		pipe.HDel(ctx, s.key("headers"), fileID)
+		pipe.Publish(ctx, s.key("changes"), s.instance+" "+fileID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("删除元数据失败: %v", err)
+	}
+	return nil
+}
+
+// 读取、修改、写回之间文件被其他实例修改时重试，不会覆盖其他实例的修改
+func (s *RedisStore) UpdateStrip(fileID string, stripeIndex int, oldStorageID string, strip StripMetadata) error {
+	ctx, cancel := s.op()
+	defer cancel()
+	key := s.fileKey(fileID)
+
+	update := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return fmt.Errorf("%w: %s", ErrNotFound, fileID)
+		}
+		if err != nil {
+			return err
+		}
+		fm, err := decodeRedisFile(fileID, data)
+		if err != nil {
+			return err
+		}
+		if err := replaceStrip(fm, stripeIndex, oldStorageID, strip); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return s.putFile(ctx, pipe, fm)
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < redisMaxRetries; attempt++ {
+		err := s.client.Watch(ctx, update, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("更新块 %s 失败: 文件 %s 被并发修改", oldStorageID, fileID)
+}
+
+func (s *RedisStore) hset(hash, field string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := s.op()
+	defer cancel()
+	return s.client.HSet(ctx, s.key(hash), field, data).Err()
+}
+
+func (s *RedisStore) hdel(hash, field string) error {
+	ctx, cancel := s.op()
+	defer cancel()
+	return s.client.HDel(ctx, s.key(hash), field).Err()
+}
+
+// 解码哈希中的所有值
+func redisValues[T any](s *RedisStore, hash string) ([]*T, error) {
+	ctx, cancel := s.op()
+	defer cancel()
+	values, err := s.client.HGetAll(ctx, s.key(hash)).Result()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*T, 0, len(values))
+	for _, data := range values {
+		var v T
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			return nil, err
+		}
+		result = append(result, &v)
+	}
+	return result, nil
+}
+
+func (s *RedisStore) SaveDriver(info *DriverInfo) error {
+	if err := s.hset("drivers", info.Name, info); err != nil {
+		return fmt.Errorf("保存驱动器状态失败: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) ListDrivers() ([]*DriverInfo, error) {
+	infos, err := redisValues[DriverInfo](s, "drivers")
+	if err != nil {
+		return nil, fmt.Errorf("读取驱动器状态失败: %v", err)
+	}
+	return infos, nil
+}
+
+// 上传进度也保存在Redis中，一个实例中断的上传可以由另一个实例续传
+func (s *RedisStore) SaveUploadProgress(p *UploadProgress) error {
+	if err := s.hset("uploads", p.ContentHash, p); err != nil {
+		return fmt.Errorf("写入上传进度失败: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) GetUploadProgress(contentHash string) (*UploadProgress, error) {
+	ctx, cancel := s.op()
+	defer cancel()
+	data, err := s.client.HGet(ctx, s.key("uploads"), contentHash).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("上传进度不存在: %s", contentHash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取上传进度失败: %v", err)
+	}
+	var p UploadProgress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("解析上传进度失败: %v", err)
+	}
+	return &p, nil
+}
+
+func (s *RedisStore) ListUploadProgress() ([]*UploadProgress, error) {
+	uploads, err := redisValues[UploadProgress](s, "uploads")
+	if err != nil {
+		return nil, fmt.Errorf("解析上传进度失败: %v", err)
+	}
+	return uploads, nil
+}
+
+func (s *RedisStore) DeleteUploadProgress(contentHash string) error {
+	if err := s.hdel("uploads", contentHash); err != nil {
+		return fmt.Errorf("删除上传进度失败: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) SaveDir(dir *DirInfo) error {
+	if err := s.hset("dirs", dir.Path, dir); err != nil {
+		return fmt.Errorf("保存目录失败: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) DeleteDir(dirPath string) error {
+	if err := s.hdel("dirs", dirPath); err != nil {
+		return fmt.Errorf("删除目录失败: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) ListDirs() ([]*DirInfo, error) {
+	dirs, err := redisValues[DirInfo](s, "dirs")
+	if err != nil {
+		return nil, fmt.Errorf("读取目录列表失败: %v", err)
+	}
+	return dirs, nil
+}
+
+// 其他实例修改或删除的文件ID，ctx取消后关闭
+func (s *RedisStore) WatchChanges(ctx context.Context) (<-chan string, error) {
+	sub := s.client.Subscribe(ctx, s.key("changes"))
+	changes := make(chan string, 256)
+	go func() {
+		defer close(changes)
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				instance, fileID, found := strings.Cut(msg.Payload, " ")
+				if !found || instance == s.instance {
+					continue
+				}
+				select {
+				case changes <- fileID:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
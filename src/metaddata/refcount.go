@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 块的引用计数：去重或快照使多个文件引用同一个条带块时，删除一个文件只删除计数降为零的块。
+// 计数随内存中元数据的发布和删除增减，CheckChunkRefs按存储后端中的元数据重新统计，检查是否有偏差
+
+// 引用该块的文件数（包括旧版本、回收站中和未提交的文件）
+func (mm *MetadataManager) ChunkRefs(strip StripMetadata) (int, error) {
+	if err := mm.resolvePlacement(); err != nil {
+		return 0, err
+	}
+	return mm.placement.refs(stripChunk(strip), ""), nil
+}
+
+// 除fileID之外引用该块的文件数（供RAID控制器删除块前检查）。
+// 无法统计时按仍被引用处理，宁可遗留块也不删除其他文件的数据
+func (mm *MetadataManager) OtherChunkRefs(fileID string, strip StripMetadata) int {
+	if err := mm.resolvePlacement(); err != nil {
+		fmt.Printf("警告: %v\n", err)
+		return 1
+	}
+	return mm.placement.refs(stripChunk(strip), fileID)
+}
+
+// 一个块的引用计数偏差
+type ChunkRefDrift struct {
+	Driver    string
+	StorageID string
+	Counted   int // 内存中维护的计数
+	Actual    int // 按存储后端中的元数据重新统计的计数
+}
+
+// 引用计数检查结果
+type ChunkRefReport struct {
+	Files    int // 存储后端中的文件数
+	Chunks   int // 被引用的块数
+	Shared   int // 被多个文件引用的块数
+	Drift    []ChunkRefDrift
+	Repaired bool
+}
+
+// 按存储后端中的完整元数据重新统计每个块的引用数，与内存中维护的计数比较。
+// repair为true时用重新统计的结果替换有偏差的文件的记录
+func (mm *MetadataManager) CheckChunkRefs(repair bool) (*ChunkRefReport, error) {
+	if err := mm.resolvePlacement(); err != nil {
+		return nil, err
+	}
+	files, err := mm.store.ListFiles()
+	if err != nil {
+		return nil, fmt.Errorf("读取文件元数据失败: %v", err)
+	}
+
+	actual := make(map[chunkKey]int)
+	for _, fm := range files {
+		for key := range fileChunks(fm) {
+			actual[key]++
+		}
+	}
+
+	report := &ChunkRefReport{Files: len(files), Chunks: len(actual)}
+	for _, n := range actual {
+		if n > 1 {
+			report.Shared++
+		}
+	}
+
+	counted := mm.placement.counts()
+	for key, n := range actual {
+		if counted[key] != n {
+			report.Drift = append(report.Drift, ChunkRefDrift{Driver: key.driver, StorageID: key.id, Counted: counted[key], Actual: n})
+		}
+	}
+	for key, n := range counted {
+		if _, ok := actual[key]; !ok {
+			report.Drift = append(report.Drift, ChunkRefDrift{Driver: key.driver, StorageID: key.id, Counted: n})
+		}
+	}
+	sort.Slice(report.Drift, func(i, j int) bool {
+		a, b := report.Drift[i], report.Drift[j]
+		if a.Driver != b.Driver {
+			return a.Driver < b.Driver
+		}
+		return a.StorageID < b.StorageID
+	})
+
+	if repair && len(report.Drift) > 0 {
+		published := make(map[string]bool)
+		mm.eachFile(func(fm *FileMetadata) { published[fm.FileID] = true })
+		mm.placement.rebuild(files, published)
+		report.Repaired = true
+	}
+	return report, nil
+}
+
+// 每个块的引用数
+func (p *placementIndex) counts() map[chunkKey]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := make(map[chunkKey]int, len(p.chunks))
+	for key, ref := range p.chunks {
+		counts[key] = ref.refs
+	}
+	return counts
+}
+
+// 按存储后端中的元数据替换内存中仍存在的文件的记录，并删除内存中已不存在的文件的记录
+func (p *placementIndex) rebuild(files []*FileMetadata, published map[string]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for fileID := range p.files {
+		if !published[fileID] {
+			p.removeLocked(fileID)
+		}
+	}
+	for _, fm := range files {
+		if published[fm.FileID] {
+			p.setLocked(fm.FileID, fm.CreatedAt, fileChunks(fm))
+		}
+	}
+}
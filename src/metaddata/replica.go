@@ -0,0 +1,459 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"panmatrix/drivers"
+)
+
+// 元数据副本在驱动器上的storageID前缀，后接20位的生成时间（纳秒），按名称排序即按时间排序
+const replicaPrefix = "panmatrix-metadata-"
+
+const replicaFormatVersion = 1
+
+// 元数据复制配置（metadata段的replicas），元数据副本以加密快照的形式保存在这些驱动器上，
+// 本地元数据丢失后可以在新机器上用 metadata bootstrap 命令恢复
+//
+//	metadata:
+//	  replicas:
+//	    drives: [onedrive, minio-home]
+//	    key_file: /etc/panmatrix/metadata.key  # 与drives的encryption相同的密钥格式，必须配置
+//	    keep: 3                                # 每个驱动器上保留的快照数
+//	    delay: 30s                             # 最后一次修改后等待多久上传，合并连续的修改
+type ReplicaConfig struct {
+	Drives                   []string `yaml:"drives"`
+	drivers.EncryptionConfig `yaml:",inline"`
+	Keep                     int           `yaml:"keep"`
+	Delay                    time.Duration `yaml:"delay"`
+}
+
+// 读取metadata段中的replicas配置，未配置时Drives为空
+func (c StoreConfig) Replicas() (ReplicaConfig, error) {
+	var section struct {
+		Replicas ReplicaConfig `yaml:"replicas"`
+	}
+	if err := c.Decode(&section); err != nil {
+		return ReplicaConfig{}, err
+	}
+	return section.Replicas, nil
+}
+
+// 元数据快照
+type replicaSnapshot struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Files     []*FileMetadata `json:"files"`
+	Drivers   []*DriverInfo   `json:"drivers"`
+	Dirs      []*DirInfo      `json:"dirs,omitempty"`
+}
+
+// 复制到驱动器的元数据存储：修改写入被包装的存储后，在delay内没有新的修改时
+// 将全部元数据打包为快照，加密上传到每个副本驱动器
+type ReplicatedStore struct {
+	inner    MetadataStore
+	replicas map[string]drivers.StorageDriver // 已包装加密
+	keep     int
+	delay    time.Duration
+
+	mu    sync.Mutex
+	dirty bool
+	timer *time.Timer
+
+	flushMu sync.Mutex // 同一时间只上传一份快照
+}
+
+// 用加密包装副本驱动器，数据加密与驱动器自身是否配置encryption无关
+func newReplicaDrivers(replicas map[string]drivers.StorageDriver, cfg ReplicaConfig) (map[string]drivers.StorageDriver, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("没有可用的元数据副本驱动器")
+	}
+	wrapped := make(map[string]drivers.StorageDriver, len(replicas))
+	for name, driver := range replicas {
+		enc, err := drivers.NewEncryptedDriver(driver, cfg.EncryptionConfig)
+		if err != nil {
+			return nil, fmt.Errorf("元数据副本加密配置无效: %v", err)
+		}
+		wrapped[name] = enc
+	}
+	return wrapped, nil
+}
+
+func NewReplicatedStore(inner MetadataStore, replicas map[string]drivers.StorageDriver, cfg ReplicaConfig) (*ReplicatedStore, error) {
+	wrapped, err := newReplicaDrivers(replicas, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = 3
+	}
+	if cfg.Delay <= 0 {
+		cfg.Delay = 30 * time.Second
+	}
+	return &ReplicatedStore{inner: inner, replicas: wrapped, keep: cfg.Keep, delay: cfg.Delay}, nil
+}
+
+// 被包装的存储
+func (s *ReplicatedStore) Unwrap() MetadataStore {
+	return s.inner
+}
+
+func (s *ReplicatedStore) SaveFile(fm *FileMetadata) error {
+	return s.changed(s.inner.SaveFile(fm))
+}
+
+func (s *ReplicatedStore) WriteBatch(writes []FileWrite) error {
+	return s.changed(writeBatch(s.inner, writes))
+}
+
+func (s *ReplicatedStore) GetFile(fileID string) (*FileMetadata, error) {
+	return s.inner.GetFile(fileID)
+}
+
+func (s *ReplicatedStore) ListFiles() ([]*FileMetadata, error) {
+	return s.inner.ListFiles()
+}
+
+func (s *ReplicatedStore) DeleteFile(fileID string) error {
+	return s.changed(s.inner.DeleteFile(fileID))
+}
+
+func (s *ReplicatedStore) UpdateStrip(fileID string, stripeIndex int, oldStorageID string, strip StripMetadata) error {
+	return s.changed(s.inner.UpdateStrip(fileID, stripeIndex, oldStorageID, strip))
+}
+
+// 驱动器健康状态频繁变化，只随其他修改一起复制
+func (s *ReplicatedStore) SaveDriver(info *DriverInfo) error {
+	return s.inner.SaveDriver(info)
+}
+
+func (s *ReplicatedStore) ListDrivers() ([]*DriverInfo, error) {
+	return s.inner.ListDrivers()
+}
+
+// 被包装的存储不支持目录时返回ErrDirsUnsupported
+func (s *ReplicatedStore) SaveDir(dir *DirInfo) error {
+	ds, ok := s.inner.(DirectoryStore)
+	if !ok {
+		return ErrDirsUnsupported
+	}
+	return s.changed(ds.SaveDir(dir))
+}
+
+func (s *ReplicatedStore) DeleteDir(dirPath string) error {
+	ds, ok := s.inner.(DirectoryStore)
+	if !ok {
+		return nil
+	}
+	return s.changed(ds.DeleteDir(dirPath))
+}
+
+func (s *ReplicatedStore) ListDirs() ([]*DirInfo, error) {
+	ds, ok := s.inner.(DirectoryStore)
+	if !ok {
+		return nil, nil
+	}
+	return ds.ListDirs()
+}
+
+// 上传尚未复制的修改后关闭被包装的存储
+func (s *ReplicatedStore) Close() error {
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	dirty := s.dirty
+	s.mu.Unlock()
+
+	if dirty {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		if err := s.Flush(ctx); err != nil {
+			fmt.Printf("警告: %v\n", err)
+		}
+		cancel()
+	}
+	return s.inner.Close()
+}
+
+// 修改成功后安排复制
+func (s *ReplicatedStore) changed(err error) error {
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = true
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.delay, s.flushLater)
+	} else {
+		s.timer.Reset(s.delay)
+	}
+	return nil
+}
+
+func (s *ReplicatedStore) flushLater() {
+	s.mu.Lock()
+	s.timer = nil
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	if err := s.Flush(ctx); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+}
+
+// 立即上传当前元数据的快照到所有副本驱动器，并删除超出保留数量的旧快照。
+// 有副本驱动器上传失败时返回错误，之后的修改会再次尝试
+func (s *ReplicatedStore) Flush(ctx context.Context) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	s.dirty = false
+	s.mu.Unlock()
+
+	data, err := s.snapshot()
+	if err != nil {
+		s.markDirty()
+		return fmt.Errorf("生成元数据快照失败: %v", err)
+	}
+
+	storageID := fmt.Sprintf("%s%020d", replicaPrefix, time.Now().UnixNano())
+	var failed []string
+	for name, driver := range s.replicas {
+		if limit := drivers.MaxChunkSizeOf(driver); limit > 0 && int64(len(data)) > limit {
+			failed = append(failed, fmt.Sprintf("%s: 快照大小%d超过驱动器单文件上限%d", name, len(data), limit))
+			continue
+		}
+		if _, err := driver.UploadChunk(ctx, data, storageID); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		pruneReplicas(ctx, driver, s.keep)
+	}
+
+	if len(failed) > 0 {
+		s.markDirty()
+		return fmt.Errorf("元数据复制失败: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (s *ReplicatedStore) markDirty() {
+	s.mu.Lock()
+	s.dirty = true
+	s.mu.Unlock()
+}
+
+// 打包全部元数据，gzip压缩后由加密驱动器加密
+func (s *ReplicatedStore) snapshot() ([]byte, error) {
+	return encodeSnapshot(s)
+}
+
+// 将存储中的全部元数据打包为gzip压缩的快照
+func encodeSnapshot(st MetadataStore) ([]byte, error) {
+	files, err := st.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := st.ListDrivers()
+	if err != nil {
+		return nil, err
+	}
+	var dirs []*DirInfo
+	if ds, ok := st.(DirectoryStore); ok {
+		if dirs, err = ds.ListDirs(); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	snap := replicaSnapshot{Version: replicaFormatVersion, CreatedAt: time.Now(), Files: files, Drivers: infos, Dirs: dirs}
+	if err := json.NewEncoder(gz).Encode(snap); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 删除超出保留数量的旧快照，失败只影响占用的空间
+func pruneReplicas(ctx context.Context, driver drivers.StorageDriver, keep int) {
+	chunks, err := drivers.ListChunks(ctx, driver, replicaPrefix)
+	if err != nil || len(chunks) <= keep {
+		return
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].StorageID > chunks[j].StorageID })
+	for _, chunk := range chunks[keep:] {
+		drivers.DeleteChunk(ctx, driver, chunk.Key())
+	}
+}
+
+// 从副本驱动器恢复的快照信息
+type BootstrapReport struct {
+	Driver    string    // 快照来源
+	CreatedAt time.Time // 快照生成时间
+	Files     int
+}
+
+// 在新机器上从副本驱动器恢复元数据：找到所有驱动器上最新的快照，解密后写入空的存储。
+// 最新的快照损坏或无法解密时依次尝试更早的快照
+func Bootstrap(ctx context.Context, st MetadataStore, replicas map[string]drivers.StorageDriver, cfg ReplicaConfig) (*BootstrapReport, error) {
+	existing, err := st.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("本地已有%d个文件的元数据，拒绝覆盖", len(existing))
+	}
+
+	wrapped, err := newReplicaDrivers(replicas, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		driver string
+		chunk  drivers.ChunkInfo
+	}
+	var candidates []candidate
+	for name, driver := range wrapped {
+		chunks, err := drivers.ListChunks(ctx, driver, replicaPrefix)
+		if err != nil {
+			fmt.Printf("警告: 列举%s上的元数据副本失败: %v\n", name, err)
+			continue
+		}
+		for _, chunk := range chunks {
+			candidates = append(candidates, candidate{driver: name, chunk: chunk})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("副本驱动器上没有元数据快照")
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return replicaGeneration(candidates[i].chunk.StorageID) > replicaGeneration(candidates[j].chunk.StorageID)
+	})
+
+	var lastErr error
+	for _, c := range candidates {
+		snap, err := downloadSnapshot(ctx, wrapped[c.driver], c.chunk.Key())
+		if err != nil {
+			fmt.Printf("警告: 读取%s上的快照%s失败: %v\n", c.driver, c.chunk.StorageID, err)
+			lastErr = err
+			continue
+		}
+
+		if err := applySnapshot(st, snap); err != nil {
+			return nil, err
+		}
+		return &BootstrapReport{Driver: c.driver, CreatedAt: snap.CreatedAt, Files: len(snap.Files)}, nil
+	}
+	return nil, fmt.Errorf("所有元数据快照都无法读取: %v", lastErr)
+}
+
+func downloadSnapshot(ctx context.Context, driver drivers.StorageDriver, key string) (*replicaSnapshot, error) {
+	data, err := driver.DownloadChunk(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(data)
+}
+
+func decodeSnapshot(data []byte) (*replicaSnapshot, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解压快照失败: %v", err)
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("解压快照失败: %v", err)
+	}
+
+	var snap replicaSnapshot
+	if err := json.Unmarshal(raw, &snap); err != nil {
+		return nil, fmt.Errorf("解析快照失败: %v", err)
+	}
+	if snap.Version > replicaFormatVersion {
+		return nil, fmt.Errorf("快照版本%d高于当前程序支持的版本%d", snap.Version, replicaFormatVersion)
+	}
+	return &snap, nil
+}
+
+// 将存储的内容替换为快照：写入快照中的文件、驱动器状态和目录，删除快照中没有的文件和目录
+func applySnapshot(st MetadataStore, snap *replicaSnapshot) error {
+	existing, err := st.ListFiles()
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(snap.Files))
+	for _, fm := range snap.Files {
+		keep[fm.FileID] = true
+	}
+	for _, fm := range existing {
+		if keep[fm.FileID] {
+			continue
+		}
+		if err := st.DeleteFile(fm.FileID); err != nil {
+			return fmt.Errorf("删除元数据 %s 失败: %v", fm.FileID, err)
+		}
+	}
+
+	for _, fm := range snap.Files {
+		if err := st.SaveFile(fm); err != nil {
+			return fmt.Errorf("写入元数据 %s 失败: %v", fm.FileID, err)
+		}
+	}
+	for _, info := range snap.Drivers {
+		if err := st.SaveDriver(info); err != nil {
+			return err
+		}
+	}
+
+	ds, ok := st.(DirectoryStore)
+	if !ok {
+		return nil
+	}
+	dirs, err := ds.ListDirs()
+	if err != nil {
+		return err
+	}
+	keepDirs := make(map[string]bool, len(snap.Dirs))
+	for _, dir := range snap.Dirs {
+		keepDirs[dir.Path] = true
+	}
+	for _, dir := range dirs {
+		if keepDirs[dir.Path] {
+			continue
+		}
+		if err := ds.DeleteDir(dir.Path); err != nil {
+			return err
+		}
+	}
+	for _, dir := range snap.Dirs {
+		if err := ds.SaveDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 快照名中的生成时间，无法解析时为0
+func replicaGeneration(storageID string) int64 {
+	gen, _ := strconv.ParseInt(strings.TrimPrefix(storageID, replicaPrefix), 10, 64)
+	return gen
+}
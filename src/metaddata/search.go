@@ -0,0 +1,258 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 文件搜索条件，各条件同时满足。排序、分页和目录范围使用ListOptions中的字段
+type SearchQuery struct {
+	ListOptions
+
+	NameContains  string    // 文件名包含该字符串（不区分大小写）
+	Tags          []string  // 同时带有所有这些标签
+	MinSize       int64     // 文件大小不小于MinSize
+	MaxSize       int64     // 文件大小小于MaxSize，0表示不限
+	CreatedAfter  time.Time // 创建时间不早于该时间
+	CreatedBefore time.Time // 创建时间早于该时间
+}
+
+// 按条件搜索文件。指定标签时只在标签索引命中的文件中查找
+func (mm *MetadataManager) Search(q SearchQuery) (*ListResult, error) {
+	if q.MinSize < 0 || q.MaxSize < 0 {
+		return nil, fmt.Errorf("文件大小不能为负数")
+	}
+	tags := normalizeTags(q.Tags)
+	name := strings.ToLower(q.NameContains)
+
+	var candidates func() []*FileMetadata
+	if len(tags) > 0 {
+		candidates = func() []*FileMetadata {
+			// 从命中文件最少的标签开始
+			sets := make([]map[string]bool, len(tags))
+			for i, tag := range tags {
+				sets[i] = mm.tagIndex[tag]
+			}
+			sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+			var files []*FileMetadata
+			for id := range sets[0] {
+				all := true
+				for _, set := range sets[1:] {
+					if !set[id] {
+						all = false
+						break
+					}
+				}
+				if all {
+					files = append(files, mm.file(id))
+				}
+			}
+			return files
+		}
+	}
+
+	match := func(fm *FileMetadata) bool {
+		if name != "" && !strings.Contains(strings.ToLower(fm.FileName), name) {
+			return false
+		}
+		if fm.FileSize < q.MinSize || q.MaxSize > 0 && fm.FileSize >= q.MaxSize {
+			return false
+		}
+		if !q.CreatedAfter.IsZero() && fm.CreatedAt.Before(q.CreatedAfter) {
+			return false
+		}
+		if !q.CreatedBefore.IsZero() && !fm.CreatedAt.Before(q.CreatedBefore) {
+			return false
+		}
+		return true
+	}
+	return mm.queryFiles(q.ListOptions, candidates, match)
+}
+
+// 解析搜索表达式，各项以空格分隔：
+//
+//	report            文件名包含report（等同于 name:report）
+//	tag:photos        带有标签photos，可以出现多次
+//	size:>10M         大于10MB，也可以是 <1G、10M-2G（单位K/M/G/T，按1024计）
+//	after:2024-01-01  创建时间不早于该日期（本地时间），before同理（不含当天）
+//	raid:1,5          RAID级别
+//	dir:/photos       /photos及其子目录中的文件
+func ParseSearchQuery(expr string) (SearchQuery, error) {
+	var q SearchQuery
+	var names []string
+	for _, token := range strings.Fields(expr) {
+		key, value, ok := strings.Cut(token, ":")
+		if !ok {
+			names = append(names, token)
+			continue
+		}
+		var err error
+		switch key {
+		case "name":
+			names = append(names, value)
+		case "tag":
+			q.Tags = append(q.Tags, value)
+		case "size":
+			q.MinSize, q.MaxSize, err = parseSizeRange(value)
+		case "after":
+			q.CreatedAfter, err = time.ParseInLocation("2006-01-02", value, time.Local)
+		case "before":
+			q.CreatedBefore, err = time.ParseInLocation("2006-01-02", value, time.Local)
+		case "raid":
+			for _, level := range strings.Split(value, ",") {
+				n, convErr := strconv.Atoi(level)
+				if convErr != nil {
+					err = convErr
+					break
+				}
+				q.RAIDLevels = append(q.RAIDLevels, n)
+			}
+		case "dir":
+			q.Dir, q.Recursive = value, true
+		default:
+			// 不是已知的条件时按文件名处理（文件名可能包含冒号）
+			names = append(names, token)
+		}
+		if err != nil {
+			return q, fmt.Errorf("无效的搜索条件 %s: %v", token, err)
+		}
+	}
+	q.NameContains = strings.Join(names, " ")
+	return q, nil
+}
+
+// 解析 >N、<N、N-M 和 N（精确大小），返回[min, max)
+func parseSizeRange(value string) (int64, int64, error) {
+	switch {
+	case strings.HasPrefix(value, ">"):
+		n, err := parseSize(value[1:])
+		return n + 1, 0, err
+	case strings.HasPrefix(value, "<"):
+		n, err := parseSize(value[1:])
+		return 0, n, err
+	}
+	if lo, hi, ok := strings.Cut(value, "-"); ok {
+		min, err := parseSize(lo)
+		if err != nil {
+			return 0, 0, err
+		}
+		max, err := parseSize(hi)
+		if err != nil {
+			return 0, 0, err
+		}
+		if max < min {
+			return 0, 0, fmt.Errorf("上限小于下限")
+		}
+		return min, max + 1, nil
+	}
+	n, err := parseSize(value)
+	return n, n + 1, err
+}
+
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(s), "B")
+	multiplier := int64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", s[n-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			s = s[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("无效的大小: %s", s)
+	}
+	return int64(f * float64(multiplier)), nil
+}
+
+// 为文件添加标签
+func (mm *MetadataManager) AddTags(fileID string, tags ...string) error {
+	return mm.updateTags(fileID, func(current []string) []string {
+		return append(current, tags...)
+	})
+}
+
+// 删除文件的标签，文件没有的标签忽略
+func (mm *MetadataManager) RemoveTags(fileID string, tags ...string) error {
+	remove := make(map[string]bool)
+	for _, tag := range normalizeTags(tags) {
+		remove[tag] = true
+	}
+	return mm.updateTags(fileID, func(current []string) []string {
+		var kept []string
+		for _, tag := range current {
+			if !remove[tag] {
+				kept = append(kept, tag)
+			}
+		}
+		return kept
+	})
+}
+
+func (mm *MetadataManager) updateTags(fileID string, update func([]string) []string) error {
+	fm, err := mm.GetFileMetadataIncludingPending(fileID)
+	if err != nil {
+		return err
+	}
+
+	updated := *fm
+	updated.Tags = update(append([]string(nil), fm.Tags...))
+	return mm.SaveFileMetadata(&updated)
+}
+
+// 所有标签及带有该标签的文件数（不包括旧版本）
+func (mm *MetadataManager) ListTags() map[string]int {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for tag, ids := range mm.tagIndex {
+		for id := range ids {
+			if mm.file(id).IsCurrent() {
+				counts[tag]++
+			}
+		}
+	}
+	return counts
+}
+
+func (mm *MetadataManager) indexTags(fm *FileMetadata) {
+	for _, tag := range fm.Tags {
+		ids := mm.tagIndex[tag]
+		if ids == nil {
+			ids = make(map[string]bool)
+			mm.tagIndex[tag] = ids
+		}
+		ids[fm.FileID] = true
+	}
+}
+
+func (mm *MetadataManager) unindexTags(fm *FileMetadata) {
+	for _, tag := range fm.Tags {
+		if ids := mm.tagIndex[tag]; ids != nil {
+			delete(ids, fm.FileID)
+			if len(ids) == 0 {
+				delete(mm.tagIndex, tag)
+			}
+		}
+	}
+}
+
+// 标签不区分大小写：去掉首尾空白、转为小写、去重并排序
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
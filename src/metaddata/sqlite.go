@@ -0,0 +1,687 @@
+package metadata
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 数据库结构的迁移脚本，按顺序执行，已执行的版本记录在PRAGMA user_version中。
+// 只能在末尾追加新的迁移，不能修改已发布的迁移
+var sqliteMigrations = []string{
+	// 1: 初始结构
+	`CREATE TABLE files (
+		file_id      TEXT PRIMARY KEY,
+		file_name    TEXT NOT NULL,
+		file_size    INTEGER NOT NULL,
+		raid_level   INTEGER NOT NULL,
+		stripe_size  INTEGER NOT NULL,
+		stripe_count INTEGER NOT NULL,
+		created_at   TEXT NOT NULL,
+		updated_at   TEXT NOT NULL,
+		hash         TEXT NOT NULL,
+		state        TEXT NOT NULL DEFAULT '',
+		committed_at TEXT NOT NULL DEFAULT '',
+		driver_map   TEXT
+	);
+	CREATE INDEX files_by_name ON files(file_name);
+
+	CREATE TABLE stripes (
+		file_id      TEXT NOT NULL REFERENCES files(file_id) ON DELETE CASCADE,
+		stripe_index INTEGER NOT NULL,
+		stripe_width INTEGER NOT NULL DEFAULT 0,
+		hole         INTEGER NOT NULL DEFAULT 0,
+		hole_size    INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (file_id, stripe_index)
+	);
+
+	CREATE TABLE strips (
+		file_id      TEXT NOT NULL,
+		stripe_index INTEGER NOT NULL,
+		role         TEXT NOT NULL,
+		position     INTEGER NOT NULL,
+		strip_index  INTEGER NOT NULL,
+		driver_name  TEXT NOT NULL,
+		storage_id   TEXT NOT NULL,
+		strip_size   INTEGER NOT NULL,
+		is_parity    INTEGER NOT NULL,
+		checksum     TEXT NOT NULL,
+		created_at   TEXT NOT NULL,
+		remote_id    TEXT NOT NULL DEFAULT '',
+		obfuscation  TEXT NOT NULL DEFAULT '',
+		parts        TEXT,
+		PRIMARY KEY (file_id, stripe_index, role, position),
+		FOREIGN KEY (file_id, stripe_index) REFERENCES stripes(file_id, stripe_index) ON DELETE CASCADE
+	);
+	CREATE INDEX strips_by_driver ON strips(driver_name);
+
+	CREATE TABLE drivers (
+		name        TEXT PRIMARY KEY,
+		health      TEXT NOT NULL,
+		last_check  TEXT NOT NULL,
+		used_space  INTEGER NOT NULL,
+		total_space INTEGER NOT NULL
+	);`,
+	// 2: 虚拟目录
+	`ALTER TABLE files ADD COLUMN dir TEXT NOT NULL DEFAULT '';
+	CREATE INDEX files_by_dir ON files(dir);
+
+	CREATE TABLE directories (
+		path       TEXT PRIMARY KEY,
+		created_at TEXT NOT NULL
+	);`,
+	// 3: 标签
+	`CREATE TABLE file_tags (
+		file_id TEXT NOT NULL REFERENCES files(file_id) ON DELETE CASCADE,
+		tag     TEXT NOT NULL,
+		PRIMARY KEY (file_id, tag)
+	);
+	CREATE INDEX file_tags_by_tag ON file_tags(tag);`,
+	// 4: 文件版本
+	`ALTER TABLE files ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE files ADD COLUMN superseded_at TEXT NOT NULL DEFAULT '';`,
+	// 5: 回收站
+	`ALTER TABLE files ADD COLUMN trashed_at TEXT NOT NULL DEFAULT '';`,
+	// 6: 多用户
+	`ALTER TABLE files ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+	CREATE INDEX files_by_owner ON files(owner);`,
+	// 7: 源文件属性
+	`ALTER TABLE files ADD COLUMN mime_type TEXT NOT NULL DEFAULT '';
+	ALTER TABLE files ADD COLUMN mod_time TEXT NOT NULL DEFAULT '';
+	ALTER TABLE files ADD COLUMN mode INTEGER NOT NULL DEFAULT 0;`,
+}
+
+func init() {
+	RegisterStore(BackendSQLite, func(basePath string, cfg StoreConfig) (MetadataStore, error) {
+		var sqliteCfg struct {
+			SQLitePath string `yaml:"sqlite_path"`
+		}
+		if err := cfg.Decode(&sqliteCfg); err != nil {
+			return nil, err
+		}
+		path := sqliteCfg.SQLitePath
+		if path == "" {
+			path = filepath.Join(basePath, "metadata.db")
+		}
+		return OpenSQLiteStore(path, basePath)
+	})
+}
+
+// SQLite存储：files、stripes、strips、file_tags、drivers、directories六张表，可以直接用SQL查询块的分布和标签
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// 打开（必要时创建）数据库并执行迁移。新建的数据库会导入jsonDir中已有的JSON元数据
+func OpenSQLiteStore(path, jsonDir string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建元数据目录失败: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on")
+	if err != nil {
+		return nil, fmt.Errorf("打开元数据数据库失败: %v", err)
+	}
+	// 写入串行执行，避免SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{db: db}
+	version, err := s.migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version == 0 {
+		if err := s.importJSON(jsonDir); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// 执行尚未执行的迁移，返回迁移前的版本
+func (s *SQLiteStore) migrate() (int, error) {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("读取元数据库版本失败: %v", err)
+	}
+	if version > len(sqliteMigrations) {
+		return 0, fmt.Errorf("元数据库版本%d高于当前程序支持的版本%d", version, len(sqliteMigrations))
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("元数据库迁移到版本%d失败: %v", i+1, err)
+		}
+		// PRAGMA不支持参数绑定
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("元数据库迁移到版本%d失败: %v", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("元数据库迁移到版本%d失败: %v", i+1, err)
+		}
+	}
+	return version, nil
+}
+
+// 从JSON存储切换到SQLite时导入已有的文件元数据，JSON文件保留不动
+func (s *SQLiteStore) importJSON(dir string) error {
+	files, err := NewJSONStore(dir).ListFiles()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取JSON元数据失败: %v", err)
+	}
+	dirs, err := NewJSONStore(dir).ListDirs()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(dirs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, fm := range files {
+		if err := insertFile(tx, fm); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("导入元数据 %s 失败: %v", fm.FileID, err)
+		}
+	}
+	for _, d := range dirs {
+		if _, err := tx.Exec("INSERT OR REPLACE INTO directories (path, created_at) VALUES (?, ?)", d.Path, formatTime(d.CreatedAt)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("导入目录 %s 失败: %v", d.Path, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("导入JSON元数据失败: %v", err)
+	}
+	fmt.Printf("已从JSON导入%d个文件的元数据\n", len(files))
+	return nil
+}
+
+func (s *SQLiteStore) ListFiles() ([]*FileMetadata, error) {
+	return s.queryFiles("", nil)
+}
+
+func (s *SQLiteStore) GetFile(fileID string) (*FileMetadata, error) {
+	files, err := s.queryFiles("WHERE file_id = ?", []interface{}{fileID})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNotFound
+	}
+	return files[0], nil
+}
+
+// 整个文件的记录在一个事务中替换，条带和块先删除再重新写入
+func (s *SQLiteStore) SaveFile(fm *FileMetadata) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	if err := deleteFileRows(tx, fm.FileID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	if err := insertFile(tx, fm); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	return nil
+}
+
+// 在一个事务中写入一批修改，只有部分条带变化的文件只改写files表中的一行和这些条带的记录
+func (s *SQLiteStore) WriteBatch(writes []FileWrite) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	for _, w := range writes {
+		if err := writeFileRows(tx, w); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("写入 %s 的元数据失败: %v", w.File.FileID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("写入元数据失败: %v", err)
+	}
+	return nil
+}
+
+func writeFileRows(tx *sql.Tx, w FileWrite) error {
+	fm := w.File
+	if len(w.Stripes) > 0 {
+		result, err := tx.Exec(`UPDATE files SET stripe_count = ?, updated_at = ?, state = ? WHERE file_id = ?`,
+			fm.StripeCount, formatTime(fm.UpdatedAt), fm.State, fm.FileID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			for _, index := range w.Stripes {
+				if err := replaceStripeRows(tx, fm, index); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		// 数据库中还没有该文件，整体写入
+	}
+	if err := deleteFileRows(tx, fm.FileID); err != nil {
+		return err
+	}
+	return insertFile(tx, fm)
+}
+
+// 替换一个条带及其块的记录
+func replaceStripeRows(tx *sql.Tx, fm *FileMetadata, stripeIndex int) error {
+	for _, table := range []string{"strips", "stripes"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE file_id = ? AND stripe_index = ?", fm.FileID, stripeIndex); err != nil {
+			return err
+		}
+	}
+	if stripe := findStripe(fm, stripeIndex); stripe != nil {
+		return insertStripe(tx, fm.FileID, *stripe)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeleteFile(fileID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("删除元数据失败: %v", err)
+	}
+	if err := deleteFileRows(tx, fileID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("删除元数据失败: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("删除元数据失败: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListDrivers() ([]*DriverInfo, error) {
+	rows, err := s.db.Query("SELECT name, health, last_check, used_space, total_space FROM drivers")
+	if err != nil {
+		return nil, fmt.Errorf("读取驱动器状态失败: %v", err)
+	}
+	defer rows.Close()
+
+	var infos []*DriverInfo
+	for rows.Next() {
+		var info DriverInfo
+		var lastCheck string
+		if err := rows.Scan(&info.Name, &info.Health, &lastCheck, &info.UsedSpace, &info.TotalSpace); err != nil {
+			return nil, fmt.Errorf("读取驱动器状态失败: %v", err)
+		}
+		info.LastCheck = parseTime(lastCheck)
+		infos = append(infos, &info)
+	}
+	return infos, rows.Err()
+}
+
+func (s *SQLiteStore) SaveDriver(info *DriverInfo) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO drivers (name, health, last_check, used_space, total_space) VALUES (?, ?, ?, ?, ?)`,
+		info.Name, info.Health, formatTime(info.LastCheck), info.UsedSpace, info.TotalSpace)
+	if err != nil {
+		return fmt.Errorf("保存驱动器状态失败: %v", err)
+	}
+	return nil
+}
+
+// 只更新块所在的一行，不重写整个文件
+func (s *SQLiteStore) UpdateStrip(fileID string, stripeIndex int, oldStorageID string, strip StripMetadata) error {
+	var parts interface{}
+	if len(strip.Parts) > 0 {
+		data, err := json.Marshal(strip.Parts)
+		if err != nil {
+			return err
+		}
+		parts = string(data)
+	}
+
+	result, err := s.db.Exec(`UPDATE strips SET strip_index = ?, driver_name = ?, storage_id = ?, strip_size = ?, is_parity = ?,
+		checksum = ?, created_at = ?, remote_id = ?, obfuscation = ?, parts = ?
+		WHERE file_id = ? AND stripe_index = ? AND storage_id = ?`,
+		strip.StripIndex, strip.DriverName, strip.StorageID, strip.StripSize, strip.IsParity,
+		strip.Checksum, formatTime(strip.CreatedAt), strip.RemoteID, strip.Obfuscation, parts,
+		fileID, stripeIndex, oldStorageID)
+	if err != nil {
+		return fmt.Errorf("更新块元数据失败: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: 块 %s", ErrNotFound, oldStorageID)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListDirs() ([]*DirInfo, error) {
+	rows, err := s.db.Query("SELECT path, created_at FROM directories ORDER BY path")
+	if err != nil {
+		return nil, fmt.Errorf("读取目录列表失败: %v", err)
+	}
+	defer rows.Close()
+
+	var dirs []*DirInfo
+	for rows.Next() {
+		var d DirInfo
+		var createdAt string
+		if err := rows.Scan(&d.Path, &createdAt); err != nil {
+			return nil, fmt.Errorf("读取目录列表失败: %v", err)
+		}
+		d.CreatedAt = parseTime(createdAt)
+		dirs = append(dirs, &d)
+	}
+	return dirs, rows.Err()
+}
+
+func (s *SQLiteStore) SaveDir(dir *DirInfo) error {
+	if _, err := s.db.Exec("INSERT OR REPLACE INTO directories (path, created_at) VALUES (?, ?)", dir.Path, formatTime(dir.CreatedAt)); err != nil {
+		return fmt.Errorf("保存目录失败: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) DeleteDir(dirPath string) error {
+	if _, err := s.db.Exec("DELETE FROM directories WHERE path = ?", dirPath); err != nil {
+		return fmt.Errorf("删除目录失败: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// 查询文件及其条带和块，where为空时返回所有文件
+func (s *SQLiteStore) queryFiles(where string, args []interface{}) ([]*FileMetadata, error) {
+	files, byID, err := s.queryFileRows(where, args)
+	if err != nil || len(files) == 0 {
+		return files, err
+	}
+
+	if err := s.loadStripes(where, args, byID); err != nil {
+		return nil, err
+	}
+	if err := s.loadStrips(where, args, byID); err != nil {
+		return nil, err
+	}
+	if err := s.loadTags(where, args, byID); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// 只读取files表和标签，不读取条带和块
+func (s *SQLiteStore) ListFileHeaders() ([]*FileMetadata, error) {
+	files, byID, err := s.queryFileRows("", nil)
+	if err != nil || len(files) == 0 {
+		return files, err
+	}
+	if err := s.loadTags("", nil, byID); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// files表中的记录，按文件ID索引
+func (s *SQLiteStore) queryFileRows(where string, args []interface{}) ([]*FileMetadata, map[string]*FileMetadata, error) {
+	rows, err := s.db.Query(`SELECT file_id, file_name, dir, file_size, raid_level, stripe_size, stripe_count,
+		created_at, updated_at, hash, state, committed_at, trashed_at, version, superseded_at, owner, mime_type, mod_time, mode, driver_map FROM files `+where, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取元数据失败: %v", err)
+	}
+
+	var files []*FileMetadata
+	byID := make(map[string]*FileMetadata)
+	for rows.Next() {
+		var fm FileMetadata
+		var createdAt, updatedAt, committedAt, trashedAt, supersededAt, modTime string
+		var driverMap sql.NullString
+		if err := rows.Scan(&fm.FileID, &fm.FileName, &fm.Dir, &fm.FileSize, &fm.RAIDLevel, &fm.StripeSize, &fm.StripeCount,
+			&createdAt, &updatedAt, &fm.Hash, &fm.State, &committedAt, &trashedAt, &fm.Version, &supersededAt, &fm.Owner,
+			&fm.MimeType, &modTime, &fm.Mode, &driverMap); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("读取元数据失败: %v", err)
+		}
+		fm.CreatedAt = parseTime(createdAt)
+		fm.UpdatedAt = parseTime(updatedAt)
+		fm.CommittedAt = parseTime(committedAt)
+		fm.TrashedAt = parseTime(trashedAt)
+		fm.SupersededAt = parseTime(supersededAt)
+		fm.ModTime = parseTime(modTime)
+		if driverMap.Valid && driverMap.String != "" {
+			if err := json.Unmarshal([]byte(driverMap.String), &fm.DriverMap); err != nil {
+				rows.Close()
+				return nil, nil, fmt.Errorf("解析 %s 的驱动器信息失败: %v", fm.FileID, err)
+			}
+		}
+		files = append(files, &fm)
+		byID[fm.FileID] = &fm
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("读取元数据失败: %v", err)
+	}
+	return files, byID, nil
+}
+
+func (s *SQLiteStore) loadStripes(where string, args []interface{}, byID map[string]*FileMetadata) error {
+	rows, err := s.db.Query(`SELECT file_id, stripe_index, stripe_width, hole, hole_size FROM stripes `+where+
+		` ORDER BY file_id, stripe_index`, args...)
+	if err != nil {
+		return fmt.Errorf("读取条带元数据失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID string
+		var stripe StripeMetadata
+		if err := rows.Scan(&fileID, &stripe.StripeIndex, &stripe.StripeWidth, &stripe.Hole, &stripe.HoleSize); err != nil {
+			return fmt.Errorf("读取条带元数据失败: %v", err)
+		}
+		if fm := byID[fileID]; fm != nil {
+			fm.Stripes = append(fm.Stripes, stripe)
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLiteStore) loadStrips(where string, args []interface{}, byID map[string]*FileMetadata) error {
+	rows, err := s.db.Query(`SELECT file_id, stripe_index, role, strip_index, driver_name, storage_id, strip_size,
+		is_parity, checksum, created_at, remote_id, obfuscation, parts FROM strips `+where+
+		` ORDER BY file_id, stripe_index, role, position`, args...)
+	if err != nil {
+		return fmt.Errorf("读取块元数据失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID, role, createdAt string
+		var parts sql.NullString
+		var strip StripMetadata
+		var stripeIndex int
+		if err := rows.Scan(&fileID, &stripeIndex, &role, &strip.StripIndex, &strip.DriverName, &strip.StorageID, &strip.StripSize,
+			&strip.IsParity, &strip.Checksum, &createdAt, &strip.RemoteID, &strip.Obfuscation, &parts); err != nil {
+			return fmt.Errorf("读取块元数据失败: %v", err)
+		}
+		strip.CreatedAt = parseTime(createdAt)
+		if parts.Valid && parts.String != "" {
+			if err := json.Unmarshal([]byte(parts.String), &strip.Parts); err != nil {
+				return fmt.Errorf("解析 %s 的子块失败: %v", strip.StorageID, err)
+			}
+		}
+
+		stripe := findStripe(byID[fileID], stripeIndex)
+		if stripe == nil {
+			continue
+		}
+		switch role {
+		case stripRoleParity:
+			stripe.ParityStrip = &strip
+		case stripRoleLocal:
+			stripe.LocalCopy = &strip
+		default:
+			stripe.Strips = append(stripe.Strips, strip)
+		}
+	}
+	return rows.Err()
+}
+
+func (s *SQLiteStore) loadTags(where string, args []interface{}, byID map[string]*FileMetadata) error {
+	rows, err := s.db.Query(`SELECT file_id, tag FROM file_tags `+where+` ORDER BY file_id, tag`, args...)
+	if err != nil {
+		return fmt.Errorf("读取标签失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fileID, tag string
+		if err := rows.Scan(&fileID, &tag); err != nil {
+			return fmt.Errorf("读取标签失败: %v", err)
+		}
+		if fm := byID[fileID]; fm != nil {
+			fm.Tags = append(fm.Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+// 带有该标签的文件ID
+func (s *SQLiteStore) FilesByTag(tag string) ([]string, error) {
+	rows, err := s.db.Query("SELECT file_id FROM file_tags WHERE tag = ? ORDER BY file_id", tag)
+	if err != nil {
+		return nil, fmt.Errorf("读取标签索引失败: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("读取标签索引失败: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func deleteFileRows(tx *sql.Tx, fileID string) error {
+	for _, table := range []string{"file_tags", "strips", "stripes", "files"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE file_id = ?", fileID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertFile(tx *sql.Tx, fm *FileMetadata) error {
+	var driverMap interface{}
+	if len(fm.DriverMap) > 0 {
+		data, err := json.Marshal(fm.DriverMap)
+		if err != nil {
+			return err
+		}
+		driverMap = string(data)
+	}
+	if _, err := tx.Exec(`INSERT INTO files (file_id, file_name, dir, file_size, raid_level, stripe_size, stripe_count,
+		created_at, updated_at, hash, state, committed_at, trashed_at, version, superseded_at, owner,
+		mime_type, mod_time, mode, driver_map) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		fm.FileID, fm.FileName, fm.Dir, fm.FileSize, fm.RAIDLevel, fm.StripeSize, fm.StripeCount,
+		formatTime(fm.CreatedAt), formatTime(fm.UpdatedAt), fm.Hash, fm.State, formatTime(fm.CommittedAt), formatTime(fm.TrashedAt),
+		fm.Version, formatTime(fm.SupersededAt), fm.Owner, fm.MimeType, formatTime(fm.ModTime), fm.Mode, driverMap); err != nil {
+		return err
+	}
+
+	for _, tag := range fm.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO file_tags (file_id, tag) VALUES (?, ?)`, fm.FileID, tag); err != nil {
+			return err
+		}
+	}
+
+	for _, stripe := range fm.Stripes {
+		if err := insertStripe(tx, fm.FileID, stripe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertStripe(tx *sql.Tx, fileID string, stripe StripeMetadata) error {
+	if _, err := tx.Exec(`INSERT INTO stripes (file_id, stripe_index, stripe_width, hole, hole_size) VALUES (?, ?, ?, ?, ?)`,
+		fileID, stripe.StripeIndex, stripe.StripeWidth, stripe.Hole, stripe.HoleSize); err != nil {
+		return err
+	}
+	for i, strip := range stripe.Strips {
+		if err := insertStrip(tx, fileID, stripe.StripeIndex, stripRoleData, i, strip); err != nil {
+			return err
+		}
+	}
+	if stripe.ParityStrip != nil {
+		if err := insertStrip(tx, fileID, stripe.StripeIndex, stripRoleParity, 0, *stripe.ParityStrip); err != nil {
+			return err
+		}
+	}
+	if stripe.LocalCopy != nil {
+		if err := insertStrip(tx, fileID, stripe.StripeIndex, stripRoleLocal, 0, *stripe.LocalCopy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertStrip(tx *sql.Tx, fileID string, stripeIndex int, role string, position int, strip StripMetadata) error {
+	var parts interface{}
+	if len(strip.Parts) > 0 {
+		data, err := json.Marshal(strip.Parts)
+		if err != nil {
+			return err
+		}
+		parts = string(data)
+	}
+	_, err := tx.Exec(`INSERT INTO strips (file_id, stripe_index, role, position, strip_index, driver_name, storage_id, strip_size,
+		is_parity, checksum, created_at, remote_id, obfuscation, parts) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		fileID, stripeIndex, role, position, strip.StripIndex, strip.DriverName, strip.StorageID, strip.StripSize,
+		strip.IsParity, strip.Checksum, formatTime(strip.CreatedAt), strip.RemoteID, strip.Obfuscation, parts)
+	return err
+}
+
+// 时间以RFC3339文本保存，零值保存为空字符串
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.Local()
+}
@@ -0,0 +1,421 @@
+package metadata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 元数据存储后端
+const (
+	BackendJSON   = "json"   // 每个文件一个JSON文档（默认）
+	BackendSQLite = "sqlite" // 单个SQLite数据库
+	BackendBolt   = "bolt"   // 单个bbolt数据库，不需要cgo
+	BackendRedis  = "redis"  // Redis，多个实例共用
+)
+
+var ErrNotFound = errors.New("元数据不存在")
+
+// 元数据文件内容无法解析
+var errCorrupted = errors.New("元数据文件已损坏")
+
+// 条带中块的角色
+const (
+	stripRoleData   = "data"
+	stripRoleParity = "parity"
+	stripRoleLocal  = "local"
+)
+
+// 元数据的持久化后端。MetadataManager在内存中维护索引和缓存，所有修改都通过该接口写回，
+// RAID引擎和命令行只使用MetadataManager，更换后端不影响它们
+type MetadataStore interface {
+	// 写入文件的完整元数据，已存在时整体替换
+	SaveFile(fm *FileMetadata) error
+	// 读取文件元数据，不存在时返回ErrNotFound
+	GetFile(fileID string) (*FileMetadata, error)
+	// 所有文件的元数据，包括尚未提交的文件
+	ListFiles() ([]*FileMetadata, error)
+	// 删除文件元数据，不存在时视为成功
+	DeleteFile(fileID string) error
+	// 替换条带中StorageID为oldStorageID的块（迁移、重建单个块时使用），块不存在时返回ErrNotFound
+	UpdateStrip(fileID string, stripeIndex int, oldStorageID string, strip StripMetadata) error
+
+	SaveDriver(info *DriverInfo) error
+	ListDrivers() ([]*DriverInfo, error)
+
+	Close() error
+}
+
+// 存储后端工厂：basePath为core.metadata_path，cfg为metadata段
+type StoreFactory func(basePath string, cfg StoreConfig) (MetadataStore, error)
+
+var (
+	storeRegistry   = make(map[string]StoreFactory)
+	storeRegistryMu sync.RWMutex
+)
+
+// 注册存储后端，通常在后端文件的init中调用；重复注册同名后端会panic
+func RegisterStore(backend string, factory StoreFactory) {
+	storeRegistryMu.Lock()
+	defer storeRegistryMu.Unlock()
+
+	if _, exists := storeRegistry[backend]; exists {
+		panic(fmt.Sprintf("元数据存储后端 %s 重复注册", backend))
+	}
+	storeRegistry[backend] = factory
+}
+
+// 已注册的存储后端（排序后）
+func RegisteredStores() []string {
+	storeRegistryMu.RLock()
+	defer storeRegistryMu.RUnlock()
+
+	backends := make([]string, 0, len(storeRegistry))
+	for b := range storeRegistry {
+		backends = append(backends, b)
+	}
+	sort.Strings(backends)
+	return backends
+}
+
+// 按配置打开存储后端，未指定时使用JSON
+func OpenStore(basePath string, cfg StoreConfig) (MetadataStore, error) {
+	backend := cfg.Backend
+	if backend == "" {
+		backend = BackendJSON
+	}
+
+	storeRegistryMu.RLock()
+	factory, ok := storeRegistry[backend]
+	storeRegistryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未知的元数据存储后端: %s（可用: %v）", backend, RegisteredStores())
+	}
+	return factory(basePath, cfg)
+}
+
+// 元数据存储配置（config.yaml顶层的metadata段），backend之外的字段由各后端自行解析
+//
+//	metadata:
+//	  backend: sqlite        # json（默认）、sqlite、bolt 或 redis
+//	  sqlite_path: ""        # 默认 <metadata_path>/metadata.db
+//	  bolt_path: ""          # 默认 <metadata_path>/metadata.bolt
+//	  redis_url: ""          # 默认 redis://127.0.0.1:6379/0
+//	  redis_prefix: ""       # 键的前缀，默认 panmatrix
+type StoreConfig struct {
+	Backend string
+
+	raw *yaml.Node
+}
+
+func (c *StoreConfig) UnmarshalYAML(node *yaml.Node) error {
+	var head struct {
+		Backend string `yaml:"backend"`
+	}
+	if err := node.Decode(&head); err != nil {
+		return err
+	}
+	c.Backend = head.Backend
+	c.raw = node
+	return nil
+}
+
+// 将metadata段解码到后端自己的配置结构体，没有配置时不修改out
+func (c StoreConfig) Decode(out interface{}) error {
+	if c.raw == nil {
+		return nil
+	}
+	if err := c.raw.Decode(out); err != nil {
+		return fmt.Errorf("解析%s配置失败: %v", c.Backend, err)
+	}
+	return nil
+}
+
+// 读取配置文件中的metadata段，没有该段时使用JSON存储
+func LoadStoreConfig(path string) (StoreConfig, error) {
+	var file struct {
+		Metadata StoreConfig `yaml:"metadata"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file.Metadata, err
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file.Metadata, fmt.Errorf("解析metadata配置失败: %v", err)
+	}
+	return file.Metadata, nil
+}
+
+func init() {
+	RegisterStore(BackendJSON, func(basePath string, cfg StoreConfig) (MetadataStore, error) {
+		return NewJSONStore(basePath), nil
+	})
+}
+
+// JSON存储：每个文件一个 <file_id>.json，显式创建的目录保存在directories.json，驱动器状态只保存在内存中。
+// 文件头另外记录在索引index.jsonl中，启动时只读取索引
+type JSONStore struct {
+	basePath string
+
+	indexMu       sync.Mutex
+	index         *os.File // 追加写入的索引，首次写入或读取索引时打开
+	indexComplete bool     // 索引包含所有文件，关闭时可以写入结束标记
+
+	driversMu sync.Mutex // drivers.json整体重写，串行化并发的更新
+}
+
+func NewJSONStore(basePath string) *JSONStore {
+	return &JSONStore{basePath: basePath}
+}
+
+// 列出所有文件元数据。无法解析的文档（如写入一半的旧版本文件）移到quarantine目录，
+// 崩溃遗留的临时文件直接删除
+func (s *JSONStore) ListFiles() ([]*FileMetadata, error) {
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var files []*FileMetadata
+	for _, entry := range entries {
+		filePath := filepath.Join(s.basePath, entry.Name())
+		if isStaleTempFile(entry, now) {
+			os.Remove(filePath)
+			continue
+		}
+		if filepath.Ext(entry.Name()) != ".json" || entry.IsDir() {
+			continue
+		}
+		fm, err := readFileMetadata(filePath)
+		if errors.Is(err, errCorrupted) {
+			target, qerr := quarantineFile(s.basePath, filePath)
+			if qerr != nil {
+				fmt.Printf("警告: %v; %v\n", err, qerr)
+			} else {
+				fmt.Printf("警告: %v，已移至 %s\n", err, target)
+			}
+			continue
+		}
+		if err != nil {
+			fmt.Printf("警告: %v\n", err)
+			continue
+		}
+		if fm.FileID == "" {
+			// 同一目录下的其他JSON文件（如tokens.json）
+			continue
+		}
+		files = append(files, fm)
+	}
+	return files, nil
+}
+
+func (s *JSONStore) GetFile(fileID string) (*FileMetadata, error) {
+	return readFileMetadata(s.path(fileID))
+}
+
+func (s *JSONStore) SaveFile(fm *FileMetadata) error {
+	data, err := json.MarshalIndent(fm, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化元数据失败: %v", err)
+	}
+
+	if err := writeFileAtomic(s.path(fm.FileID), data, 0644); err != nil {
+		return fmt.Errorf("写入元数据文件失败: %v", err)
+	}
+	return s.appendIndex(jsonIndexRecord{File: fileHeader(fm)})
+}
+
+func (s *JSONStore) DeleteFile(fileID string) error {
+	if err := os.Remove(s.path(fileID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除元数据文件失败: %v", err)
+	}
+	return s.appendIndex(jsonIndexRecord{Deleted: fileID})
+}
+
+// 文档只能整体重写
+func (s *JSONStore) UpdateStrip(fileID string, stripeIndex int, oldStorageID string, strip StripMetadata) error {
+	fm, err := s.GetFile(fileID)
+	if err != nil {
+		return err
+	}
+	if err := replaceStrip(fm, stripeIndex, oldStorageID, strip); err != nil {
+		return err
+	}
+	return s.SaveFile(fm)
+}
+
+// drivers.json的内容，与directories.json一样没有file_id字段
+type jsonDrivers struct {
+	Drivers []*DriverInfo `json:"drivers"`
+}
+
+func (s *JSONStore) SaveDriver(info *DriverInfo) error {
+	s.driversMu.Lock()
+	defer s.driversMu.Unlock()
+
+	infos, err := s.ListDrivers()
+	if err != nil {
+		return err
+	}
+	kept := []*DriverInfo{info}
+	for _, other := range infos {
+		if other.Name != info.Name {
+			kept = append(kept, other)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Name < kept[j].Name })
+	data, err := json.MarshalIndent(jsonDrivers{Drivers: kept}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化驱动器状态失败: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.basePath, "drivers.json"), data, 0644); err != nil {
+		return fmt.Errorf("写入驱动器状态失败: %v", err)
+	}
+	return nil
+}
+
+func (s *JSONStore) ListDrivers() ([]*DriverInfo, error) {
+	data, err := os.ReadFile(filepath.Join(s.basePath, "drivers.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取驱动器状态失败: %v", err)
+	}
+	var doc jsonDrivers
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析驱动器状态失败: %v", err)
+	}
+	return doc.Drivers, nil
+}
+
+func (s *JSONStore) Close() error { return s.closeIndex() }
+
+// directories.json的内容。与文件元数据在同一目录下，没有file_id字段，ListFiles会跳过它
+type jsonDirectories struct {
+	Directories []*DirInfo `json:"directories"`
+}
+
+func (s *JSONStore) ListDirs() ([]*DirInfo, error) {
+	data, err := os.ReadFile(filepath.Join(s.basePath, "directories.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取目录列表失败: %v", err)
+	}
+	var doc jsonDirectories
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("解析目录列表失败: %v", err)
+	}
+	return doc.Directories, nil
+}
+
+func (s *JSONStore) SaveDir(dir *DirInfo) error {
+	dirs, err := s.ListDirs()
+	if err != nil {
+		return err
+	}
+	kept := []*DirInfo{dir}
+	for _, d := range dirs {
+		if d.Path != dir.Path {
+			kept = append(kept, d)
+		}
+	}
+	return s.writeDirs(kept)
+}
+
+func (s *JSONStore) DeleteDir(dirPath string) error {
+	dirs, err := s.ListDirs()
+	if err != nil {
+		return err
+	}
+	var kept []*DirInfo
+	for _, d := range dirs {
+		if d.Path != dirPath {
+			kept = append(kept, d)
+		}
+	}
+	return s.writeDirs(kept)
+}
+
+func (s *JSONStore) writeDirs(dirs []*DirInfo) error {
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Path < dirs[j].Path })
+	data, err := json.MarshalIndent(jsonDirectories{Directories: dirs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化目录列表失败: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.basePath, "directories.json"), data, 0644); err != nil {
+		return fmt.Errorf("写入目录列表失败: %v", err)
+	}
+	return nil
+}
+
+func (s *JSONStore) path(fileID string) string {
+	return filepath.Join(s.basePath, fileID+".json")
+}
+
+// 读取一个JSON元数据文件，文件不存在时返回ErrNotFound
+func readFileMetadata(filePath string) (*FileMetadata, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("无法读取元数据文件 %s: %v", filePath, err)
+	}
+
+	var fm FileMetadata
+	if err := json.Unmarshal(data, &fm); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errCorrupted, filePath, err)
+	}
+	return &fm, nil
+}
+
+// 在文件元数据中替换一个块（数据块、校验块或本地副本）
+func replaceStrip(fm *FileMetadata, stripeIndex int, oldStorageID string, strip StripMetadata) error {
+	stripe := findStripe(fm, stripeIndex)
+	if stripe == nil {
+		return fmt.Errorf("%w: %s 的条带%d", ErrNotFound, fm.FileID, stripeIndex)
+	}
+
+	for i := range stripe.Strips {
+		if stripe.Strips[i].StorageID == oldStorageID {
+			stripe.Strips[i] = strip
+			return nil
+		}
+	}
+	if stripe.ParityStrip != nil && stripe.ParityStrip.StorageID == oldStorageID {
+		stripe.ParityStrip = &strip
+		return nil
+	}
+	if stripe.LocalCopy != nil && stripe.LocalCopy.StorageID == oldStorageID {
+		stripe.LocalCopy = &strip
+		return nil
+	}
+	return fmt.Errorf("%w: 块 %s", ErrNotFound, oldStorageID)
+}
+
+func findStripe(fm *FileMetadata, stripeIndex int) *StripeMetadata {
+	if fm == nil {
+		return nil
+	}
+	// 条带按序号排列，通常位置即序号
+	if stripeIndex >= 0 && stripeIndex < len(fm.Stripes) && fm.Stripes[stripeIndex].StripeIndex == stripeIndex {
+		return &fm.Stripes[stripeIndex]
+	}
+	for i := range fm.Stripes {
+		if fm.Stripes[i].StripeIndex == stripeIndex {
+			return &fm.Stripes[i]
+		}
+	}
+	return nil
+}
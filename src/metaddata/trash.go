@@ -0,0 +1,153 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	defaultTrashKeepDays      = 30
+	defaultTrashPurgeInterval = time.Hour
+)
+
+// 回收站配置（metadata段的trash）。删除的文件先移入回收站，条带块保留keep_days天后才清理，
+// 期间可以恢复；keep_days为0时直接删除
+//
+//	metadata:
+//	  trash:
+//	    keep_days: 30        # 默认30天
+//	    purge_interval: 1h   # 常驻运行时后台清理过期文件的间隔
+type TrashConfig struct {
+	KeepDays      int           `yaml:"keep_days"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+var ErrNotInTrash = errors.New("文件不在回收站中")
+
+func defaultTrashConfig() TrashConfig {
+	return TrashConfig{KeepDays: defaultTrashKeepDays, PurgeInterval: defaultTrashPurgeInterval}
+}
+
+// 读取metadata段中的trash配置，未配置的字段使用默认值
+func (c StoreConfig) Trash() (TrashConfig, error) {
+	section := struct {
+		Trash TrashConfig `yaml:"trash"`
+	}{Trash: defaultTrashConfig()}
+	if err := c.Decode(&section); err != nil {
+		return TrashConfig{}, err
+	}
+	if section.Trash.KeepDays < 0 {
+		return TrashConfig{}, fmt.Errorf("trash的keep_days不能为负数")
+	}
+	if section.Trash.PurgeInterval <= 0 {
+		section.Trash.PurgeInterval = defaultTrashPurgeInterval
+	}
+	return section.Trash, nil
+}
+
+// 文件是否在回收站中
+func (fm *FileMetadata) IsTrashed() bool {
+	return fm.State == FileStateTrashed
+}
+
+// 设置回收站配置
+func (mm *MetadataManager) SetTrashConfig(cfg TrashConfig) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.trash = cfg
+}
+
+// 当前的回收站配置
+func (mm *MetadataManager) TrashConfig() TrashConfig {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.trash
+}
+
+// 将已提交的文件移入回收站，之后对列表和读取不可见，条带块保留到过期后由调用方清理
+func (mm *MetadataManager) TrashFile(fileID string) (*FileMetadata, error) {
+	fm, err := mm.GetFileMetadataIncludingPending(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if fm.IsTrashed() {
+		return fm, nil
+	}
+	if !fm.IsCommitted() {
+		return nil, fmt.Errorf("%w: %s", ErrFileNotCommitted, fileID)
+	}
+
+	trashed := *fm
+	trashed.State = FileStateTrashed
+	trashed.TrashedAt = time.Now()
+	if err := mm.SaveFileMetadata(&trashed); err != nil {
+		return nil, err
+	}
+	return &trashed, nil
+}
+
+// 从回收站恢复文件。删除时是路径上的当前版本的，恢复后仍为当前版本，期间上传的同名文件变为旧版本
+func (mm *MetadataManager) RestoreFromTrash(fileID string) (*FileMetadata, error) {
+	fm, err := mm.GetFileMetadataIncludingPending(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if !fm.IsTrashed() {
+		return nil, fmt.Errorf("%w: %s", ErrNotInTrash, fileID)
+	}
+
+	restored := *fm
+	restored.State = FileStateCommitted
+	restored.TrashedAt = time.Time{}
+	if restored.SupersededAt.IsZero() {
+		if mm.DirExists(restored.Path()) {
+			return nil, fmt.Errorf("已存在同名目录: %s", restored.Path())
+		}
+		now := time.Now()
+		for _, v := range mm.pathVersions(restored.Owner, restored.Dir, restored.FileName, restored.FileID) {
+			if v.IsCurrent() {
+				old := *v
+				old.SupersededAt = now
+				if err := mm.SaveFileMetadata(&old); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if err := mm.SaveFileMetadata(&restored); err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// 回收站中的所有文件，最近删除的在前
+func (mm *MetadataManager) ListTrash() []*FileMetadata {
+	var files []*FileMetadata
+	mm.eachFile(func(fm *FileMetadata) {
+		if fm.IsTrashed() {
+			files = append(files, fm)
+		}
+	})
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].TrashedAt.Equal(files[j].TrashedAt) {
+			return files[i].TrashedAt.After(files[j].TrashedAt)
+		}
+		return files[i].FileID < files[j].FileID
+	})
+	return files
+}
+
+// 回收站中已过期的文件。条带块需由调用方删除后再删除元数据
+func (mm *MetadataManager) ExpiredTrash(now time.Time) []*FileMetadata {
+	cutoff := now.AddDate(0, 0, -mm.TrashConfig().KeepDays)
+
+	var expired []*FileMetadata
+	for _, fm := range mm.ListTrash() {
+		if !fm.TrashedAt.After(cutoff) {
+			expired = append(expired, fm)
+		}
+	}
+	return expired
+}
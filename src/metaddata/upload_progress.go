@@ -0,0 +1,231 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"panmatrix/drivers"
+)
+
+// 上传进度（上传会话）：RAID控制器开始写入时创建，记录已提交的条带及其分布，
+// 文件提交后删除。用于中断后续传和列出进行中的上传
+type UploadProgress struct {
+	ContentHash      string           `json:"content_hash"` // 文件内容的SHA-256，用于识别同一文件
+	FileID           string           `json:"file_id"`
+	FileSize         int64            `json:"file_size"`
+	RAIDLevel        int              `json:"raid_level"`
+	StripeSize       int64            `json:"stripe_size"`
+	StripeWidth      int              `json:"stripe_width"`
+	CompletedStripes int              `json:"completed_stripes"`
+	BytesCommitted   int64            `json:"bytes_committed"` // 已提交的条带包含的文件数据量
+	Stripes          []StripeMetadata `json:"stripes"`
+	StartedAt        time.Time        `json:"started_at,omitempty"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+
+	// 未提交条带中进行中的分片上传会话，键为"驱动器/存储ID"。
+	// 续传时这些条带块从已确认的分片继续，而不是重新上传整块；更新时整体替换，不修改已保存的map
+	Sessions map[string]*drivers.UploadSession `json:"sessions,omitempty"`
+}
+
+// 存储后端可以实现该接口，将上传进度与文件元数据保存在一起；
+// 未实现时上传进度保存在元数据目录的uploads下
+type UploadProgressStore interface {
+	SaveUploadProgress(p *UploadProgress) error
+	GetUploadProgress(contentHash string) (*UploadProgress, error)
+	DeleteUploadProgress(contentHash string) error
+	ListUploadProgress() ([]*UploadProgress, error)
+}
+
+// 存储后端（或被包装的后端）实现的UploadProgressStore
+func (mm *MetadataManager) progressStore() (UploadProgressStore, bool) {
+	st := mm.store
+	for {
+		if ps, ok := st.(UploadProgressStore); ok {
+			return ps, true
+		}
+		wrapper, ok := st.(interface{ Unwrap() MetadataStore })
+		if !ok {
+			return nil, false
+		}
+		st = wrapper.Unwrap()
+	}
+}
+
+// 保存上传进度。每提交一个条带保存一次，启用预写日志时只追加新提交的条带，检查点时再写回
+func (mm *MetadataManager) SaveUploadProgress(p *UploadProgress) error {
+	p.UpdatedAt = time.Now()
+	if wal := mm.currentWAL(); wal != nil {
+		return wal.logProgress(p)
+	}
+	return mm.writeUploadProgress(p)
+}
+
+// 将完整的上传进度写入存储后端或uploads目录
+func (mm *MetadataManager) writeUploadProgress(p *UploadProgress) error {
+	if ps, ok := mm.progressStore(); ok {
+		return ps.SaveUploadProgress(p)
+	}
+
+	dir := filepath.Join(mm.basePath, "uploads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建上传进度目录失败: %v", err)
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化上传进度失败: %v", err)
+	}
+
+	if err := writeFileAtomic(mm.uploadProgressPath(p.ContentHash), data, 0644); err != nil {
+		return fmt.Errorf("写入上传进度失败: %v", err)
+	}
+
+	return nil
+}
+
+// 获取上传进度
+func (mm *MetadataManager) GetUploadProgress(contentHash string) (*UploadProgress, error) {
+	if wal := mm.currentWAL(); wal != nil {
+		if p, ok := wal.pendingProgress(contentHash); ok {
+			return p, nil
+		}
+	}
+	if ps, ok := mm.progressStore(); ok {
+		return ps.GetUploadProgress(contentHash)
+	}
+
+	data, err := os.ReadFile(mm.uploadProgressPath(contentHash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("上传进度不存在: %s", contentHash)
+		}
+		return nil, fmt.Errorf("读取上传进度失败: %v", err)
+	}
+
+	var p UploadProgress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("解析上传进度失败: %v", err)
+	}
+
+	return &p, nil
+}
+
+// 删除上传进度（上传完成或放弃时）
+func (mm *MetadataManager) DeleteUploadProgress(contentHash string) error {
+	if wal := mm.currentWAL(); wal != nil {
+		if err := wal.logDeleteProgress(contentHash); err != nil {
+			return err
+		}
+	}
+	if ps, ok := mm.progressStore(); ok {
+		return ps.DeleteUploadProgress(contentHash)
+	}
+
+	err := os.Remove(mm.uploadProgressPath(contentHash))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除上传进度失败: %v", err)
+	}
+	return nil
+}
+
+// 进行中（包括中断后可续传）的上传，最早开始的在前
+func (mm *MetadataManager) ListUploads() ([]*UploadProgress, error) {
+	var uploads []*UploadProgress
+	if ps, ok := mm.progressStore(); ok {
+		var err error
+		if uploads, err = ps.ListUploadProgress(); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if uploads, err = mm.readUploadProgressDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 预写日志中的进度比存储后端中的新
+	if wal := mm.currentWAL(); wal != nil {
+		byHash := make(map[string]int, len(uploads))
+		for i, p := range uploads {
+			byHash[p.ContentHash] = i
+		}
+		for _, p := range wal.allProgress() {
+			if i, ok := byHash[p.ContentHash]; ok {
+				uploads[i] = p
+			} else {
+				uploads = append(uploads, p)
+			}
+		}
+	}
+
+	sort.Slice(uploads, func(i, j int) bool {
+		if !uploads[i].StartedAt.Equal(uploads[j].StartedAt) {
+			return uploads[i].StartedAt.Before(uploads[j].StartedAt)
+		}
+		return uploads[i].FileID < uploads[j].FileID
+	})
+	return uploads, nil
+}
+
+// 读取uploads目录中的所有上传进度，无法解析的跳过
+func (mm *MetadataManager) readUploadProgressDir() ([]*UploadProgress, error) {
+	dir := filepath.Join(mm.basePath, "uploads")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取上传进度目录失败: %v", err)
+	}
+
+	var uploads []*UploadProgress
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("读取上传进度失败: %v", err)
+		}
+		var p UploadProgress
+		if err := json.Unmarshal(data, &p); err != nil {
+			fmt.Printf("警告: 解析上传进度失败 %s: %v\n", name, err)
+			continue
+		}
+		uploads = append(uploads, &p)
+	}
+	return uploads, nil
+}
+
+// 删除文件的上传进度（文件提交或未提交的文件被删除时）
+func (mm *MetadataManager) clearUploads(fileID string) {
+	uploads, err := mm.ListUploads()
+	if err != nil {
+		fmt.Printf("警告: %v\n", err)
+		return
+	}
+	for _, p := range uploads {
+		if p.FileID != fileID {
+			continue
+		}
+		if err := mm.DeleteUploadProgress(p.ContentHash); err != nil {
+			fmt.Printf("警告: %v\n", err)
+		}
+	}
+}
+
+func (mm *MetadataManager) uploadProgressPath(contentHash string) string {
+	return filepath.Join(mm.basePath, "uploads", contentHash+".json")
+}
+
+func (mm *MetadataManager) currentWAL() *metadataWAL {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.wal
+}
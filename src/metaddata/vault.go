@@ -0,0 +1,330 @@
+package metadata
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+var ErrVaultPassphrase = errors.New("凭据保险库口令错误")
+
+const (
+	defaultVaultPassphraseEnv = "PANMATRIX_VAULT_PASSPHRASE"
+	defaultVaultKeyring       = "panmatrix-vault"
+
+	// scrypt参数（N=2^15），派生一次约需0.1秒
+	vaultScryptN = 1 << 15
+	vaultScryptR = 8
+	vaultScryptP = 1
+)
+
+// 凭据保险库配置（metadata段中的vault）。启用后驱动器的令牌、Cookie等保存在元数据目录的
+// credentials.vault中，以主口令派生的密钥加密，配置文件中不再需要填写
+//
+//	metadata:
+//	  vault:
+//	    enabled: true
+//	    passphrase_env: PANMATRIX_VAULT_PASSPHRASE  # 从环境变量读取主口令（默认）
+//	    passphrase_file: /etc/panmatrix/vault.pass  # 或从文件读取
+//	    keyring: true                               # 或从系统钥匙串读取（macOS security、Linux secret-tool）
+//	    keyring_service: panmatrix-vault
+type VaultConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	PassphraseEnv  string `yaml:"passphrase_env"`
+	PassphraseFile string `yaml:"passphrase_file"`
+	Keyring        bool   `yaml:"keyring"`
+	KeyringService string `yaml:"keyring_service"`
+}
+
+// 读取metadata段中的vault配置
+func (c StoreConfig) Vault() (VaultConfig, error) {
+	var section struct {
+		Vault VaultConfig `yaml:"vault"`
+	}
+	if err := c.Decode(&section); err != nil {
+		return VaultConfig{}, err
+	}
+	if section.Vault.PassphraseFile != "" && section.Vault.Keyring {
+		return VaultConfig{}, fmt.Errorf("vault的passphrase_file和keyring只能设置一个")
+	}
+	if section.Vault.PassphraseEnv == "" {
+		section.Vault.PassphraseEnv = defaultVaultPassphraseEnv
+	}
+	if section.Vault.KeyringService == "" {
+		section.Vault.KeyringService = defaultVaultKeyring
+	}
+	return section.Vault, nil
+}
+
+// 按配置读取主口令
+func (c VaultConfig) passphrase() ([]byte, error) {
+	var passphrase []byte
+	switch {
+	case c.PassphraseFile != "":
+		data, err := os.ReadFile(c.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取保险库口令失败: %v", err)
+		}
+		passphrase = bytes.TrimRight(data, "\r\n")
+	case c.Keyring:
+		data, err := keyringPassphrase(c.KeyringService)
+		if err != nil {
+			return nil, err
+		}
+		passphrase = data
+	default:
+		passphrase = []byte(os.Getenv(c.PassphraseEnv))
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("未设置保险库口令，请设置环境变量 %s", c.PassphraseEnv)
+		}
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("保险库口令为空")
+	}
+	return passphrase, nil
+}
+
+// 从系统钥匙串读取口令：macOS为登录钥匙串中的通用密码，其他系统使用libsecret的secret-tool
+func keyringPassphrase(service string) ([]byte, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-w")
+	case "windows":
+		return nil, errors.New("Windows不支持从钥匙串读取保险库口令，请使用passphrase_env或passphrase_file")
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", service)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("从系统钥匙串读取保险库口令失败（%s）: %v", service, err)
+	}
+	return bytes.TrimRight(out, "\r\n"), nil
+}
+
+// 凭据保险库：驱动器名（或令牌存储键）-> 字段名 -> 值。字段名与驱动器配置中的yaml字段相同，
+// 如 access_token、refresh_token、cookie。每次修改后整体重新加密并原子替换文件
+type CredentialVault struct {
+	path string
+	key  []byte
+	salt []byte
+
+	mu      sync.Mutex
+	entries map[string]map[string]string
+}
+
+// 保险库文件格式，二进制字段以base64编码
+type vaultFile struct {
+	Version int    `json:"version"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// 按metadata段的配置打开basePath下的保险库，未启用时返回nil
+func OpenConfiguredVault(basePath string, cfg StoreConfig) (*CredentialVault, error) {
+	vaultCfg, err := cfg.Vault()
+	if err != nil {
+		return nil, err
+	}
+	if !vaultCfg.Enabled {
+		return nil, nil
+	}
+	passphrase, err := vaultCfg.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	return OpenVault(filepath.Join(basePath, "credentials.vault"), passphrase)
+}
+
+// 打开保险库，文件不存在时创建空的保险库（第一次写入时保存）
+func OpenVault(path string, passphrase []byte) (*CredentialVault, error) {
+	v := &CredentialVault{path: path, entries: make(map[string]map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		v.salt = make([]byte, 16)
+		if _, err := rand.Read(v.salt); err != nil {
+			return nil, fmt.Errorf("生成保险库盐值失败: %v", err)
+		}
+		if v.key, err = scrypt.Key(passphrase, v.salt, vaultScryptN, vaultScryptR, vaultScryptP, 32); err != nil {
+			return nil, fmt.Errorf("派生保险库密钥失败: %v", err)
+		}
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取凭据保险库失败: %v", err)
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析凭据保险库失败: %v", err)
+	}
+	if file.Version != 1 {
+		return nil, fmt.Errorf("不支持的凭据保险库版本: %d", file.Version)
+	}
+	v.salt = file.Salt
+	if v.key, err = scrypt.Key(passphrase, file.Salt, file.N, file.R, file.P, 32); err != nil {
+		return nil, fmt.Errorf("派生保险库密钥失败: %v", err)
+	}
+	aead, err := v.aead()
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, file.Nonce, file.Data, []byte(filepath.Base(path)))
+	if err != nil {
+		return nil, ErrVaultPassphrase
+	}
+	if err := json.Unmarshal(plain, &v.entries); err != nil {
+		return nil, fmt.Errorf("解析凭据保险库失败: %v", err)
+	}
+	return v, nil
+}
+
+func (v *CredentialVault) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(v.key)
+	if err != nil {
+		return nil, fmt.Errorf("初始化保险库加密失败: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// 读取凭据，返回副本
+func (v *CredentialVault) GetCredential(name string) (map[string]string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	fields, ok := v.entries[name]
+	if !ok {
+		return nil, false
+	}
+	copied := make(map[string]string, len(fields))
+	for k, value := range fields {
+		copied[k] = value
+	}
+	return copied, true
+}
+
+// 更新凭据中的字段，其他字段保留；值为空的字段删除，没有字段的凭据整体删除
+func (v *CredentialVault) PutCredential(name string, fields map[string]string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry := v.entries[name]
+	if entry == nil {
+		entry = make(map[string]string)
+	}
+	for k, value := range fields {
+		if value == "" {
+			delete(entry, k)
+		} else {
+			entry[k] = value
+		}
+	}
+	if len(entry) == 0 {
+		delete(v.entries, name)
+	} else {
+		v.entries[name] = entry
+	}
+	return v.saveLocked()
+}
+
+// 删除凭据，不存在时视为成功
+func (v *CredentialVault) DeleteCredential(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.entries[name]; !ok {
+		return nil
+	}
+	delete(v.entries, name)
+	return v.saveLocked()
+}
+
+// 所有凭据的名称及其字段名（不含值），按名称排序
+func (v *CredentialVault) ListCredentials() map[string][]string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	names := make(map[string][]string, len(v.entries))
+	for name, fields := range v.entries {
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		names[name] = keys
+	}
+	return names
+}
+
+// 重新加密全部凭据并原子替换文件，文件仅所有者可读
+func (v *CredentialVault) saveLocked() error {
+	plain, err := json.Marshal(v.entries)
+	if err != nil {
+		return fmt.Errorf("序列化凭据失败: %v", err)
+	}
+	aead, err := v.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("生成随机数失败: %v", err)
+	}
+
+	file := vaultFile{
+		Version: 1,
+		N:       vaultScryptN,
+		R:       vaultScryptR,
+		P:       vaultScryptP,
+		Salt:    v.salt,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plain, []byte(filepath.Base(v.path))),
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化凭据保险库失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return fmt.Errorf("创建保险库目录失败: %v", err)
+	}
+	if err := writeFileAtomic(v.path, data, 0600); err != nil {
+		return fmt.Errorf("写入凭据保险库失败: %v", err)
+	}
+	return nil
+}
+
+// 解析 字段=值 形式的行（用于从标准输入写入凭据），空行和#开头的行忽略
+func ParseCredentialFields(text string) (map[string]string, error) {
+	fields := make(map[string]string)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("第%d行格式错误，应为 字段=值", i+1)
+		}
+		fields[key] = strings.TrimSpace(value)
+	}
+	return fields, nil
+}
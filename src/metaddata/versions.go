@@ -0,0 +1,221 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 旧版本的保留策略（metadata段中的versioning），两项都为0时保留所有版本。
+// 旧版本在超出最近keep_last个、并且被替换超过keep_days天后过期；只设置一项时只按该项判断
+//
+//	metadata:
+//	  versioning:
+//	    keep_last: 5
+//	    keep_days: 30
+type RetentionPolicy struct {
+	KeepLast int `yaml:"keep_last"`
+	KeepDays int `yaml:"keep_days"`
+}
+
+// 读取metadata段中的versioning配置
+func (c StoreConfig) Versioning() (RetentionPolicy, error) {
+	var section struct {
+		Versioning RetentionPolicy `yaml:"versioning"`
+	}
+	if err := c.Decode(&section); err != nil {
+		return RetentionPolicy{}, err
+	}
+	if section.Versioning.KeepLast < 0 || section.Versioning.KeepDays < 0 {
+		return RetentionPolicy{}, fmt.Errorf("versioning的keep_last和keep_days不能为负数")
+	}
+	return section.Versioning, nil
+}
+
+// 已提交且不是被替换的旧版本，即路径上当前可见的文件
+func (fm *FileMetadata) IsCurrent() bool {
+	return fm.IsCommitted() && fm.SupersededAt.IsZero()
+}
+
+// 设置旧版本的保留策略
+func (mm *MetadataManager) SetRetention(policy RetentionPolicy) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.retention = policy
+}
+
+// 文件提交时调用：同一用户同一路径上当前的文件变为旧版本，新文件的版本号为已有的最大版本号加一
+func (mm *MetadataManager) supersede(fm *FileMetadata, now time.Time) error {
+	versions := mm.pathVersions(fm.Owner, fm.Dir, fm.FileName, fm.FileID)
+
+	// 旧版本上传的同名文件没有版本号，按创建时间补上
+	next := 1
+	for _, v := range versions {
+		if v.Version >= next {
+			next = v.Version + 1
+		}
+	}
+	for _, v := range versions {
+		changed := false
+		if v.Version == 0 {
+			updated := *v
+			updated.Version = next
+			next++
+			v, changed = &updated, true
+		}
+		if v.IsCurrent() {
+			if !changed {
+				updated := *v
+				v = &updated
+			}
+			v.SupersededAt = now
+			changed = true
+		}
+		if changed {
+			if err := mm.SaveFileMetadata(v); err != nil {
+				return err
+			}
+		}
+	}
+	fm.Version = next
+	return nil
+}
+
+// owner的路径上已提交和在回收站中的所有版本（不含exclude），按版本号从旧到新排列。
+// 回收站中的版本也占用版本号，恢复后不会与新上传的版本重复。不同用户的同名路径是不同的文件
+func (mm *MetadataManager) pathVersions(owner, dir, name, exclude string) []*FileMetadata {
+	dir = normalizeDir(dir)
+
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	var versions []*FileMetadata
+	for _, id := range mm.nameIndex[name] {
+		fm := mm.file(id)
+		if id != exclude && fm.Owner == owner && fm.Dir == dir && (fm.IsCommitted() || fm.IsTrashed()) {
+			versions = append(versions, fm)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		a, b := versions[i], versions[j]
+		if (a.Version == 0) != (b.Version == 0) {
+			// 没有版本号的文件在启用版本管理之前上传，排在前面
+			return a.Version == 0
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return versions
+}
+
+// 路径上的所有版本（不包括回收站中的），最新的在前，当前版本IsCurrent为true。
+// 只包括不属于任何用户的文件，用户的文件使用Namespace中的同名方法
+func (mm *MetadataManager) ListVersions(filePath string) ([]*FileMetadata, error) {
+	return mm.listVersions("", filePath)
+}
+
+func (mm *MetadataManager) listVersions(owner, filePath string) ([]*FileMetadata, error) {
+	dir, name := splitPath(filePath)
+	var versions []*FileMetadata
+	for _, v := range mm.pathVersions(owner, dir, name, "") {
+		if !v.IsTrashed() {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, CleanPath(filePath))
+	}
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+	return versions, nil
+}
+
+// 路径上指定版本的文件
+func (mm *MetadataManager) GetVersion(filePath string, version int) (*FileMetadata, error) {
+	return mm.getVersion("", filePath, version)
+}
+
+func (mm *MetadataManager) getVersion(owner, filePath string, version int) (*FileMetadata, error) {
+	versions, err := mm.listVersions(owner, filePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s 的版本%d", ErrNotFound, CleanPath(filePath), version)
+}
+
+// 将指定版本恢复为路径上的当前文件，原来的当前版本变为旧版本。版本号不变，不复制数据
+func (mm *MetadataManager) RestoreVersion(filePath string, version int) (*FileMetadata, error) {
+	return mm.restoreVersion("", filePath, version)
+}
+
+func (mm *MetadataManager) restoreVersion(owner, filePath string, version int) (*FileMetadata, error) {
+	target, err := mm.getVersion(owner, filePath, version)
+	if err != nil {
+		return nil, err
+	}
+	if target.IsCurrent() {
+		return target, nil
+	}
+
+	now := time.Now()
+	versions, _ := mm.listVersions(owner, filePath)
+	for _, v := range versions {
+		if v.IsCurrent() {
+			old := *v
+			old.SupersededAt = now
+			if err := mm.SaveFileMetadata(&old); err != nil {
+				return nil, err
+			}
+		}
+	}
+	restored := *target
+	restored.SupersededAt = time.Time{}
+	if err := mm.SaveFileMetadata(&restored); err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
+
+// 按保留策略已过期的旧版本。条带块需由调用方删除后再删除元数据
+func (mm *MetadataManager) ExpiredVersions(now time.Time) []*FileMetadata {
+	mm.mu.RLock()
+	policy := mm.retention
+	mm.mu.RUnlock()
+
+	type pathKey struct{ dir, name string }
+	old := make(map[pathKey][]*FileMetadata)
+	mm.eachFile(func(fm *FileMetadata) {
+		if fm.IsCommitted() && !fm.SupersededAt.IsZero() {
+			key := pathKey{fm.Dir, fm.FileName}
+			old[key] = append(old[key], fm)
+		}
+	})
+
+	if policy.KeepLast == 0 && policy.KeepDays == 0 {
+		return nil
+	}
+	cutoff := now.AddDate(0, 0, -policy.KeepDays)
+
+	var expired []*FileMetadata
+	for _, versions := range old {
+		// 最近被替换的在前
+		sort.Slice(versions, func(i, j int) bool { return versions[i].SupersededAt.After(versions[j].SupersededAt) })
+		for i, v := range versions {
+			beyondCount := policy.KeepLast == 0 || i >= policy.KeepLast
+			beyondAge := policy.KeepDays == 0 || v.SupersededAt.Before(cutoff)
+			if beyondCount && beyondAge {
+				expired = append(expired, v)
+			}
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].FileID < expired[j].FileID })
+	return expired
+}
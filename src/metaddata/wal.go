@@ -0,0 +1,442 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCheckpointInterval = 30 * time.Second
+	defaultCheckpointRecords  = 1000
+)
+
+// 预写日志配置（metadata段的wal）。上传进度和块的更新先追加到日志，
+// 定期检查点时才写回存储后端，大文件上传时不必在每个条带后重写整个文档
+//
+//	metadata:
+//	  wal:
+//	    enabled: true             # 默认启用
+//	    checkpoint_interval: 30s  # 检查点间隔
+//	    checkpoint_records: 1000  # 日志记录数达到该值时提前检查点
+type WALConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"`
+	CheckpointRecords  int           `yaml:"checkpoint_records"`
+}
+
+// 读取metadata段中的wal配置，未配置的字段使用默认值
+func (c StoreConfig) WAL() (WALConfig, error) {
+	section := struct {
+		WAL WALConfig `yaml:"wal"`
+	}{WAL: WALConfig{Enabled: true}}
+	if err := c.Decode(&section); err != nil {
+		return WALConfig{}, err
+	}
+	if section.WAL.CheckpointInterval <= 0 {
+		section.WAL.CheckpointInterval = defaultCheckpointInterval
+	}
+	if section.WAL.CheckpointRecords <= 0 {
+		section.WAL.CheckpointRecords = defaultCheckpointRecords
+	}
+	return section.WAL, nil
+}
+
+// 日志记录类型
+const (
+	walOpStrip          = "strip"           // 替换一个块，对应UpdateStrip
+	walOpStripe         = "stripe"          // 上传中写入完成的条带，对应RecordStripe
+	walOpDeleteFile     = "file_delete"     // 删除文件元数据，重放时不再恢复之前记录的条带
+	walOpProgress       = "progress"        // 上传进度，只记录新提交的条带
+	walOpDeleteProgress = "progress_delete" // 删除上传进度
+)
+
+// 日志中的一条记录，每行一个JSON对象
+type walRecord struct {
+	Op string `json:"op"`
+
+	FileID       string          `json:"file_id,omitempty"`
+	StripeIndex  int             `json:"stripe_index,omitempty"`
+	OldStorageID string          `json:"old_storage_id,omitempty"`
+	Strip        *StripMetadata  `json:"strip,omitempty"`
+	Stripe       *StripeMetadata `json:"stripe,omitempty"`
+
+	// Progress不含条带，Stripes为从第From个开始新增的条带
+	ContentHash string           `json:"content_hash,omitempty"`
+	Progress    *UploadProgress  `json:"progress,omitempty"`
+	From        int              `json:"from,omitempty"`
+	Stripes     []StripeMetadata `json:"stripes,omitempty"`
+}
+
+// 元数据预写日志。日志中的修改已经反映在MetadataManager的内存状态中，
+// 检查点将它们写回存储后端后清空日志；启动时重放上次未检查点的记录
+type metadataWAL struct {
+	mm   *MetadataManager
+	cfg  WALConfig
+	path string
+
+	// 以下字段由mu保护。锁顺序见locks.go：持有mu时不能再加文件锁
+	mu       sync.Mutex
+	file     *os.File
+	records  int
+	dirty    map[string]map[int]bool    // 有块更新、尚未写回存储后端的文件及其变化的条带
+	progress map[string]*UploadProgress // 尚未写回的上传进度
+	logged   map[string]int             // 每个上传进度已写入日志的条带数
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (mm *MetadataManager) walPath() string {
+	return walFile(mm.basePath)
+}
+
+func walFile(basePath string) string {
+	return filepath.Join(basePath, "metadata.wal")
+}
+
+// 重放上次遗留的日志并写回存储后端；cfg.Enabled时打开日志，之后的更新先写入日志
+func (mm *MetadataManager) openWAL(cfg WALConfig) error {
+	w := &metadataWAL{
+		mm:       mm,
+		cfg:      cfg,
+		path:     mm.walPath(),
+		dirty:    make(map[string]map[int]bool),
+		progress: make(map[string]*UploadProgress),
+		logged:   make(map[string]int),
+	}
+
+	replayed, err := w.replay()
+	if err != nil {
+		return err
+	}
+	if replayed > 0 {
+		if err := w.checkpoint(); err != nil {
+			return fmt.Errorf("写回预写日志失败: %v", err)
+		}
+		fmt.Printf("已重放元数据预写日志中的 %d 条记录\n", replayed)
+	}
+
+	if !cfg.Enabled {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除预写日志失败: %v", err)
+		}
+		return nil
+	}
+
+	w.file, err = os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("打开预写日志失败: %v", err)
+	}
+	w.kick = make(chan struct{}, 1)
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.loop()
+
+	mm.mu.Lock()
+	mm.wal = w
+	mm.mu.Unlock()
+	return nil
+}
+
+// 读取日志文件并应用到内存状态，返回记录数。末尾写了一半的记录（崩溃所致）忽略
+func (w *metadataWAL) replay() (int, error) {
+	f, err := os.Open(w.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("打开预写日志失败: %v", err)
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			fmt.Printf("警告: 预写日志第 %d 条记录不完整，忽略之后的内容: %v\n", n+1, err)
+			break
+		}
+		w.apply(&rec)
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("读取预写日志失败: %v", err)
+	}
+	return n, nil
+}
+
+// 启动时重放，此时没有并发的读写
+func (w *metadataWAL) apply(rec *walRecord) {
+	switch rec.Op {
+	case walOpStrip:
+		fm, _, ok := w.mm.cached(rec.FileID)
+		if !ok {
+			return
+		}
+		fm, err := w.mm.loadDetail(fm)
+		if err != nil || rec.Strip == nil {
+			return
+		}
+		// 块已被之后整体保存的元数据替换，或文件已删除时忽略
+		updated := cloneForUpdate(fm)
+		if replaceStrip(updated, rec.StripeIndex, rec.OldStorageID, *rec.Strip) == nil {
+			w.mm.publish(updated, false)
+			w.markDirty(rec.FileID, rec.StripeIndex)
+		}
+	case walOpStripe:
+		if rec.Stripe == nil {
+			return
+		}
+		var updated *FileMetadata
+		if fm, _, ok := w.mm.cached(rec.FileID); !ok {
+			updated = &FileMetadata{FileID: rec.FileID, State: FileStatePending, CreatedAt: time.Now()}
+		} else if fm.State != FileStatePending {
+			return
+		} else if fm, err := w.mm.loadDetail(fm); err != nil {
+			return
+		} else {
+			updated = cloneForUpdate(fm)
+		}
+		setStripe(updated, *rec.Stripe)
+		w.mm.publish(updated, false)
+		w.markDirty(rec.FileID, rec.Stripe.StripeIndex)
+	case walOpDeleteFile:
+		w.mm.mu.Lock()
+		if fm := w.mm.file(rec.FileID); fm != nil {
+			w.mm.unindexFile(fm)
+			w.mm.unpublish(rec.FileID)
+		}
+		w.mm.mu.Unlock()
+		delete(w.dirty, rec.FileID)
+	case walOpProgress:
+		p := w.progress[rec.ContentHash]
+		if rec.Progress != nil {
+			updated := *rec.Progress
+			if p != nil && rec.From <= len(p.Stripes) {
+				updated.Stripes = append(p.Stripes[:rec.From:rec.From], rec.Stripes...)
+			} else {
+				updated.Stripes = rec.Stripes
+			}
+			w.progress[rec.ContentHash] = &updated
+		}
+	case walOpDeleteProgress:
+		delete(w.progress, rec.ContentHash)
+	}
+}
+
+// 追加一条记录并fsync，调用方持有w.mu
+func (w *metadataWAL) appendLocked(rec *walRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("序列化预写日志记录失败: %v", err)
+	}
+	if _, err := w.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入预写日志失败: %v", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("写入预写日志失败: %v", err)
+	}
+
+	w.records++
+	if w.records >= w.cfg.CheckpointRecords {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// 记录块的替换，调用方持有该文件的文件锁并已发布修改后的元数据
+func (w *metadataWAL) logStrip(fileID string, stripeIndex int, oldStorageID string, strip StripMetadata) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.appendLocked(&walRecord{Op: walOpStrip, FileID: fileID, StripeIndex: stripeIndex,
+		OldStorageID: oldStorageID, Strip: &strip}); err != nil {
+		return err
+	}
+	w.markDirty(fileID, stripeIndex)
+	return nil
+}
+
+// 记录上传中写入完成的条带，调用方持有该文件的文件锁并已发布修改后的元数据
+func (w *metadataWAL) logStripe(fileID string, stripe StripeMetadata) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.appendLocked(&walRecord{Op: walOpStripe, FileID: fileID, Stripe: &stripe}); err != nil {
+		return err
+	}
+	w.markDirty(fileID, stripe.StripeIndex)
+	return nil
+}
+
+// 记录文件中有变化的条带，调用方持有w.mu（重放时没有并发）
+func (w *metadataWAL) markDirty(fileID string, stripeIndex int) {
+	stripes := w.dirty[fileID]
+	if stripes == nil {
+		stripes = make(map[int]bool)
+		w.dirty[fileID] = stripes
+	}
+	stripes[stripeIndex] = true
+}
+
+// 记录文件元数据的删除，调用方持有该文件的文件锁
+func (w *metadataWAL) logDelete(fileID string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.dirty, fileID)
+	return w.appendLocked(&walRecord{Op: walOpDeleteFile, FileID: fileID})
+}
+
+// 记录上传进度，只写入上次记录之后新增的条带
+func (w *metadataWAL) logProgress(p *UploadProgress) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	from := w.logged[p.ContentHash]
+	if _, ok := w.progress[p.ContentHash]; !ok || from > len(p.Stripes) {
+		from = 0
+	}
+	header := *p
+	header.Stripes = nil
+	if err := w.appendLocked(&walRecord{Op: walOpProgress, ContentHash: p.ContentHash, Progress: &header,
+		From: from, Stripes: p.Stripes[from:]}); err != nil {
+		return err
+	}
+
+	saved := *p
+	w.progress[p.ContentHash] = &saved
+	w.logged[p.ContentHash] = len(p.Stripes)
+	return nil
+}
+
+// 尚未写回的上传进度
+func (w *metadataWAL) pendingProgress(contentHash string) (*UploadProgress, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p, ok := w.progress[contentHash]
+	if !ok {
+		return nil, false
+	}
+	copied := *p
+	return &copied, true
+}
+
+// 所有尚未写回的上传进度
+func (w *metadataWAL) allProgress() []*UploadProgress {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	progress := make([]*UploadProgress, 0, len(w.progress))
+	for _, p := range w.progress {
+		copied := *p
+		progress = append(progress, &copied)
+	}
+	return progress
+}
+
+// 记录上传进度的删除，之后重放时不再恢复之前的进度
+func (w *metadataWAL) logDeleteProgress(contentHash string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.progress[contentHash]; !ok {
+		return nil
+	}
+	if err := w.appendLocked(&walRecord{Op: walOpDeleteProgress, ContentHash: contentHash}); err != nil {
+		return err
+	}
+	delete(w.progress, contentHash)
+	delete(w.logged, contentHash)
+	return nil
+}
+
+// 文件的元数据即将整体写回，不需要在检查点时再写，调用方持有该文件的文件锁
+func (w *metadataWAL) forget(fileID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.dirty, fileID)
+}
+
+// 将日志中的修改写回存储后端并清空日志。所有文件的修改作为一批写入，后端支持时只写入变化的条带并在一个事务中完成。
+// 分片中的元数据不会原地修改，不需要文件锁；检查点期间追加日志的修改等待检查点完成
+func (w *metadataWAL) checkpoint() error {
+	mm := w.mm
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	writes := make([]FileWrite, 0, len(w.dirty))
+	for fileID, stripes := range w.dirty {
+		if fm := mm.file(fileID); fm != nil {
+			writes = append(writes, FileWrite{File: fm, Stripes: sortedStripes(stripes)})
+		}
+	}
+	if err := writeBatch(mm.store, writes); err != nil {
+		return err
+	}
+	clear(w.dirty)
+	for contentHash, p := range w.progress {
+		if err := mm.writeUploadProgress(p); err != nil {
+			return err
+		}
+		delete(w.progress, contentHash)
+		delete(w.logged, contentHash)
+	}
+
+	if w.file == nil {
+		// 启动时重放，日志文件由openWAL处理
+		return os.Truncate(w.path, 0)
+	}
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("清空预写日志失败: %v", err)
+	}
+	if _, err := w.file.Seek(0, 0); err != nil {
+		return fmt.Errorf("清空预写日志失败: %v", err)
+	}
+	w.records = 0
+	return w.file.Sync()
+}
+
+// 后台按间隔或记录数检查点
+func (w *metadataWAL) loop() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.cfg.CheckpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		case <-w.kick:
+		}
+		if err := w.checkpoint(); err != nil {
+			fmt.Printf("警告: 元数据检查点失败: %v\n", err)
+		}
+	}
+}
+
+// 停止后台检查点，写回所有修改后关闭日志
+func (w *metadataWAL) close() error {
+	close(w.stop)
+	<-w.done
+
+	err := w.checkpoint()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// 模拟崩溃：不关闭管理器直接复制元数据目录，日志中的记录尚未写回存储后端
+func crashCopy(t *testing.T, mm *MetadataManager) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS(mm.basePath)); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func openTestManager(t *testing.T, dir string) *MetadataManager {
+	t.Helper()
+	mm, err := NewMetadataManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mm.Close() })
+	return mm
+}
+
+func testStripe(index int, storageID string) StripeMetadata {
+	return StripeMetadata{StripeIndex: index, Strips: []StripMetadata{
+		{StripIndex: 0, DriverName: "d0", StorageID: storageID, StripSize: 100},
+	}}
+}
+
+func TestWALReplay(t *testing.T) {
+	tests := []struct {
+		name string
+		tail string // 崩溃时日志末尾的内容
+	}{
+		{"完整的日志", ""},
+		{"末尾写了一半的记录", `{"op":"stripe","file_id":"f3","stripe":{"stri`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := newTestManager(t)
+			if mm.currentWAL() == nil {
+				t.Fatal("预写日志未启用")
+			}
+			for i := 0; i < 2; i++ {
+				if err := mm.RecordStripe("f1", testStripe(i, fmt.Sprintf("s%d", i))); err != nil {
+					t.Fatal(err)
+				}
+				p := &UploadProgress{ContentHash: "h1", FileID: "f1", CompletedStripes: i + 1}
+				for j := 0; j <= i; j++ {
+					p.Stripes = append(p.Stripes, testStripe(j, fmt.Sprintf("s%d", j)))
+				}
+				if err := mm.SaveUploadProgress(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			moved := StripMetadata{StripIndex: 0, DriverName: "d1", StorageID: "s0-moved", StripSize: 100}
+			if err := mm.UpdateStrip("f1", 0, "s0", moved); err != nil {
+				t.Fatal(err)
+			}
+			// 删除之后不应再从之前的记录恢复
+			if err := mm.RecordStripe("f2", testStripe(0, "x0")); err != nil {
+				t.Fatal(err)
+			}
+			if err := mm.DeleteFileMetadata("f2"); err != nil {
+				t.Fatal(err)
+			}
+
+			dir := crashCopy(t, mm)
+			if tt.tail != "" {
+				f, err := os.OpenFile(walFile(dir), os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteString(tt.tail)
+				f.Close()
+			}
+
+			replayed := openTestManager(t, dir)
+			checkReplayed(t, replayed)
+			if info, err := os.Stat(walFile(dir)); err != nil || info.Size() != 0 {
+				t.Fatalf("重放后日志未清空: %v", err)
+			}
+
+			// 重放的记录已写回存储后端，不依赖日志
+			if err := replayed.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(walFile(dir)); err != nil {
+				t.Fatal(err)
+			}
+			checkReplayed(t, openTestManager(t, dir))
+		})
+	}
+}
+
+func checkReplayed(t *testing.T, mm *MetadataManager) {
+	t.Helper()
+	fm, err := mm.GetFileMetadataIncludingPending("f1")
+	if err != nil {
+		t.Fatalf("重放后找不到f1: %v", err)
+	}
+	if fm.State != FileStatePending || len(fm.Stripes) != 2 {
+		t.Fatalf("f1状态 %q，%d个条带", fm.State, len(fm.Stripes))
+	}
+	if strip := fm.Stripes[0].Strips[0]; strip.StorageID != "s0-moved" || strip.DriverName != "d1" {
+		t.Errorf("条带0的块为 %s/%s，期望替换后的 d1/s0-moved", strip.DriverName, strip.StorageID)
+	}
+	if _, err := mm.GetFileMetadataIncludingPending("f2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("已删除的f2被恢复: %v", err)
+	}
+	if _, err := mm.GetFileMetadataIncludingPending("f3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("不完整的记录被应用: %v", err)
+	}
+
+	p, err := mm.GetUploadProgress("h1")
+	if err != nil {
+		t.Fatalf("重放后找不到上传进度: %v", err)
+	}
+	if p.CompletedStripes != 2 || len(p.Stripes) != 2 || p.Stripes[1].Strips[0].StorageID != "s1" {
+		t.Errorf("上传进度 %d个已完成条带，记录了%d个", p.CompletedStripes, len(p.Stripes))
+	}
+}
+
+func TestWALDeleteProgress(t *testing.T) {
+	mm := newTestManager(t)
+	if err := mm.SaveUploadProgress(&UploadProgress{ContentHash: "h1", FileID: "f1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mm.DeleteUploadProgress("h1"); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := openTestManager(t, crashCopy(t, mm))
+	if _, err := replayed.GetUploadProgress("h1"); err == nil {
+		t.Fatal("已删除的上传进度被恢复")
+	}
+}
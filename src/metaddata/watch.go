@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// 多个实例共用的存储后端（如Redis）实现的接口：其他实例修改或删除文件元数据后通知，
+// MetadataManager据此更新内存中的副本，各实例看到同一份元数据
+type ChangeNotifier interface {
+	// 其他实例修改或删除的文件ID，ctx取消后关闭
+	WatchChanges(ctx context.Context) (<-chan string, error)
+}
+
+// 存储后端（或被包装的后端）实现的ChangeNotifier
+func (mm *MetadataManager) changeNotifier() (ChangeNotifier, bool) {
+	st := mm.store
+	for {
+		if cn, ok := st.(ChangeNotifier); ok {
+			return cn, true
+		}
+		wrapper, ok := st.(interface{ Unwrap() MetadataStore })
+		if !ok {
+			return nil, false
+		}
+		st = wrapper.Unwrap()
+	}
+}
+
+// 存储后端支持变更通知时在后台更新其他实例修改的文件，Close时停止
+func (mm *MetadataManager) watchChanges() error {
+	cn, ok := mm.changeNotifier()
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	changes, err := cn.WatchChanges(ctx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("订阅元数据变更失败: %v", err)
+	}
+	mm.stopWatch = cancel
+
+	go func() {
+		for fileID := range changes {
+			if err := mm.refreshFile(fileID); err != nil {
+				fmt.Printf("警告: 更新其他实例修改的元数据失败: %v\n", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// 从存储后端重新读取文件的元数据替换内存中的副本，已删除时从内存中删除
+func (mm *MetadataManager) refreshFile(fileID string) error {
+	unlock := mm.fileLocks.lock(fileID)
+	defer unlock()
+
+	fm, err := mm.store.GetFile(fileID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("读取 %s 的元数据失败: %v", fileID, err)
+	}
+
+	mm.mu.Lock()
+	old := mm.file(fileID)
+	if old != nil {
+		mm.unindexFile(old)
+		mm.unpublish(fileID)
+	}
+	if fm != nil {
+		mm.publish(fm, false)
+		mm.indexFile(fm)
+	}
+	mm.mu.Unlock()
+
+	mm.publishFileChange(old, fm)
+	return nil
+}
@@ -0,0 +1,687 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"panmatrix/metadata"
+	"panmatrix/scheduler"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+const (
+	// 元数据可能被其他命令或接口修改，内核只短暂缓存目录项和属性
+	mountCacheTimeout = time.Second
+	// renameat2的RENAME_NOREPLACE，fs包只定义了RENAME_EXCHANGE
+	renameNoReplace = 0x1
+)
+
+// 将命名空间挂载到dir，直到ctx取消或被卸载（fusermount -u / umount）
+func (e *env) mount(ctx context.Context, dir string, opts mountOptions) error {
+	if opts.SpoolDir == "" {
+		opts.SpoolDir = os.TempDir()
+	}
+	m := &mountFS{
+		e:       e,
+		g:       &gateway{e: e, ns: e.ns, raidLevel: opts.RAIDLevel},
+		opts:    opts,
+		mounted: time.Now(),
+		spools:  make(map[string]*mountSpool),
+	}
+
+	mountOpts := fuse.MountOptions{
+		FsName:     "panmatrix",
+		Name:       "panmatrix",
+		AllowOther: opts.AllowOther,
+		// 以root运行时（如容器中）直接调用mount(2)，不需要安装fusermount
+		DirectMount: true,
+	}
+	if opts.ReadOnly {
+		mountOpts.Options = append(mountOpts.Options, "ro")
+	}
+
+	timeout := mountCacheTimeout
+	server, err := fs.Mount(dir, &mountNode{m: m}, &fs.Options{
+		MountOptions:    mountOpts,
+		EntryTimeout:    &timeout,
+		AttrTimeout:     &timeout,
+		NegativeTimeout: &timeout,
+		UID:             uint32(os.Getuid()),
+		GID:             uint32(os.Getgid()),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", dir, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Unmount(); err != nil {
+			log.Printf("警告: 卸载失败（挂载点仍在使用中？）: %v", err)
+		}
+	}()
+	fmt.Printf("已挂载到 %s，按 Ctrl+C 或用 umount 卸载\n", dir)
+	server.Wait()
+	return nil
+}
+
+type mountFS struct {
+	e       *env
+	g       *gateway
+	opts    mountOptions
+	mounted time.Time // 目录没有记录修改时间，使用挂载的时间
+
+	// 写入中的文件，按虚拟路径。新建的文件提交之前也要能查找和列出
+	mu     sync.Mutex
+	spools map[string]*mountSpool
+}
+
+// 写入中的文件先保存在本地的临时文件中，flush（关闭）或fsync时整个写入阵列。
+// 同一路径的多个可写句柄共用一个临时文件
+type mountSpool struct {
+	file *os.File
+	mode os.FileMode
+	refs int // 打开的句柄数，由mountFS.mu保护
+
+	mu      sync.Mutex
+	path    string // 提交到的虚拟路径，重命名时更新
+	dirty   bool   // 有尚未提交的修改
+	removed bool   // 文件已被删除，不再提交
+}
+
+// 虚拟目录或文件，位置由在树中的路径决定
+type mountNode struct {
+	fs.Inode
+	m *mountFS
+}
+
+var (
+	_ fs.NodeLookuper  = (*mountNode)(nil)
+	_ fs.NodeReaddirer = (*mountNode)(nil)
+	_ fs.NodeGetattrer = (*mountNode)(nil)
+	_ fs.NodeSetattrer = (*mountNode)(nil)
+	_ fs.NodeOpener    = (*mountNode)(nil)
+	_ fs.NodeCreater   = (*mountNode)(nil)
+	_ fs.NodeMkdirer   = (*mountNode)(nil)
+	_ fs.NodeUnlinker  = (*mountNode)(nil)
+	_ fs.NodeRmdirer   = (*mountNode)(nil)
+	_ fs.NodeRenamer   = (*mountNode)(nil)
+	_ fs.NodeStatfser  = (*mountNode)(nil)
+)
+
+func (n *mountNode) path() string {
+	return "/" + n.Path(nil)
+}
+
+func (n *mountNode) child(name string) string {
+	return path.Join(n.path(), name)
+}
+
+func (n *mountNode) newChild(ctx context.Context, isDir bool) *fs.Inode {
+	mode := uint32(fuse.S_IFREG)
+	if isDir {
+		mode = fuse.S_IFDIR
+	}
+	return n.NewInode(ctx, &mountNode{m: n.m}, fs.StableAttr{Mode: mode})
+}
+
+func (n *mountNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	p := n.child(name)
+	if sp := n.m.spool(p); sp != nil {
+		n.m.spoolAttr(sp, &out.Attr)
+		return n.newChild(ctx, false), 0
+	}
+	if meta, err := n.m.e.ns.GetByName(p); err == nil {
+		n.m.fileAttr(meta, &out.Attr)
+		return n.newChild(ctx, false), 0
+	}
+	if n.m.e.ns.DirExists(p) {
+		n.m.dirAttr(&out.Attr)
+		return n.newChild(ctx, true), 0
+	}
+	return nil, syscall.ENOENT
+}
+
+func (n *mountNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	dir := n.path()
+	entries, err := n.m.e.ns.ListDir(dir)
+	if err != nil {
+		return nil, mountErrno("列出目录", err)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	list := make([]fuse.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		// 同名文件只列出最新的一个
+		if seen[entry.Name] {
+			continue
+		}
+		seen[entry.Name] = true
+		mode := uint32(fuse.S_IFREG)
+		if entry.IsDir {
+			mode = fuse.S_IFDIR
+		}
+		list = append(list, fuse.DirEntry{Name: entry.Name, Mode: mode})
+	}
+	n.m.mu.Lock()
+	for p := range n.m.spools {
+		if name := path.Base(p); path.Dir(p) == dir && !seen[name] {
+			seen[name] = true
+			list = append(list, fuse.DirEntry{Name: name, Mode: fuse.S_IFREG})
+		}
+	}
+	n.m.mu.Unlock()
+	return fs.NewListDirStream(list), 0
+}
+
+func (n *mountNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if n.IsRoot() || n.IsDir() {
+		n.m.dirAttr(&out.Attr)
+		return 0
+	}
+	p := n.path()
+	if sp := n.m.spool(p); sp != nil {
+		n.m.spoolAttr(sp, &out.Attr)
+		return 0
+	}
+	meta, err := n.m.e.ns.GetByName(p)
+	if err != nil {
+		return mountErrno("读取文件信息", err)
+	}
+	n.m.fileAttr(meta, &out.Attr)
+	return 0
+}
+
+// 只支持改变大小，权限和时间的修改被忽略
+func (n *mountNode) Setattr(ctx context.Context, f fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	size, ok := in.GetSize()
+	if !ok || n.IsDir() {
+		return n.Getattr(ctx, f, out)
+	}
+	if n.m.opts.ReadOnly {
+		return syscall.EROFS
+	}
+
+	w, opened := f.(*mountWriter)
+	var sp *mountSpool
+	if opened {
+		sp = w.sp
+	} else {
+		// truncate(2)没有打开的句柄，改变大小后立即提交
+		var errno syscall.Errno
+		if sp, errno = n.m.openSpool(ctx, n.path(), false, 0); errno != 0 {
+			return errno
+		}
+		defer n.m.releaseSpool(sp)
+	}
+	if err := sp.file.Truncate(int64(size)); err != nil {
+		return mountErrno("改变文件大小", err)
+	}
+	sp.mu.Lock()
+	sp.dirty = true
+	sp.mu.Unlock()
+	if !opened {
+		if errno := n.m.commit(ctx, sp); errno != 0 {
+			return errno
+		}
+	}
+	return n.Getattr(ctx, f, out)
+}
+
+func (n *mountNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	p := n.path()
+	if flags&syscall.O_ACCMODE == syscall.O_RDONLY {
+		// 写入中的文件从临时文件读取
+		if sp := n.m.spool(p); sp == nil {
+			meta, err := n.m.e.ns.GetByName(p)
+			if err != nil {
+				return nil, 0, mountErrno("打开文件", err)
+			}
+			return &mountReader{r: n.m.g.openReader(meta)}, 0, 0
+		}
+	}
+	if n.m.opts.ReadOnly && flags&syscall.O_ACCMODE != syscall.O_RDONLY {
+		return nil, 0, syscall.EROFS
+	}
+
+	sp, errno := n.m.openSpool(ctx, p, flags&syscall.O_TRUNC != 0, 0)
+	if errno != 0 {
+		return nil, 0, errno
+	}
+	return &mountWriter{m: n.m, sp: sp}, 0, 0
+}
+
+func (n *mountNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	if n.m.opts.ReadOnly {
+		return nil, nil, 0, syscall.EROFS
+	}
+	sp, errno := n.m.openSpool(ctx, n.child(name), true, os.FileMode(mode).Perm())
+	if errno != 0 {
+		return nil, nil, 0, errno
+	}
+	n.m.spoolAttr(sp, &out.Attr)
+	return n.newChild(ctx, false), &mountWriter{m: n.m, sp: sp}, 0, 0
+}
+
+func (n *mountNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if n.m.opts.ReadOnly {
+		return nil, syscall.EROFS
+	}
+	if err := n.m.e.ns.Mkdir(n.child(name), false); err != nil {
+		return nil, mountErrno("创建目录", err)
+	}
+	n.m.dirAttr(&out.Attr)
+	return n.newChild(ctx, true), 0
+}
+
+func (n *mountNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	if n.m.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	p := n.child(name)
+	hadSpool := n.m.dropSpool(p)
+	meta, err := n.m.e.ns.GetByName(p)
+	if err != nil {
+		if hadSpool {
+			return 0
+		}
+		return mountErrno("删除文件", err)
+	}
+	if err := n.m.g.remove(ctx, meta); err != nil {
+		return mountErrno("删除文件", err)
+	}
+	return 0
+}
+
+func (n *mountNode) Rmdir(ctx context.Context, name string) syscall.Errno {
+	if n.m.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	if err := n.m.e.ns.Rmdir(n.child(name)); err != nil {
+		return mountErrno("删除目录", err)
+	}
+	return 0
+}
+
+// 元数据不支持移动文件，重命名文件时写入新路径后删除原文件。
+// 非空目录返回EXDEV，mv等程序会改为逐个复制后删除
+func (n *mountNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if n.m.opts.ReadOnly {
+		return syscall.EROFS
+	}
+	if flags&fs.RENAME_EXCHANGE != 0 {
+		return syscall.ENOTSUP
+	}
+	parent, ok := newParent.(*mountNode)
+	if !ok {
+		return syscall.EXDEV
+	}
+	from, to := n.child(name), parent.child(newName)
+	if from == to {
+		return 0
+	}
+	if flags&renameNoReplace != 0 {
+		if _, err := n.m.e.ns.GetByName(to); err == nil || n.m.spool(to) != nil || n.m.e.ns.DirExists(to) {
+			return syscall.EEXIST
+		}
+	}
+
+	sp := n.m.spool(from)
+	meta, err := n.m.e.ns.GetByName(from)
+	if sp == nil && err != nil {
+		if n.m.e.ns.DirExists(from) {
+			return n.m.renameDir(from, to)
+		}
+		return mountErrno("重命名", err)
+	}
+	if n.m.e.ns.DirExists(to) {
+		return syscall.EISDIR
+	}
+
+	if sp != nil {
+		// 写入中的文件改为提交到新路径
+		n.m.moveSpool(sp, to)
+		if errno := n.m.commit(ctx, sp); errno != 0 {
+			return errno
+		}
+	} else if err := n.m.g.copyFile(ctx, meta, to); err != nil {
+		return mountErrno("复制文件", err)
+	}
+	if meta != nil {
+		if err := n.m.g.remove(ctx, meta); err != nil {
+			return mountErrno("删除重命名前的文件", err)
+		}
+	}
+	return 0
+}
+
+// 上报命名空间的配额，没有配额时为各驱动器容量之和
+func (n *mountNode) Statfs(ctx context.Context, out *fuse.StatfsOut) syscall.Errno {
+	const blockSize = 4096
+	// 驱动器都没有容量上限时上报的总量，为0时一些程序会认为磁盘已满
+	const unlimited = 1 << 50
+	used, total := n.m.e.ns.Usage(), n.m.e.ns.Quota()
+	if total <= 0 {
+		used, total = 0, 0
+		for _, d := range n.m.e.storageDrivers {
+			u, t, err := d.GetUsage()
+			if err != nil || t <= 0 {
+				continue
+			}
+			used, total = used+u, total+t
+		}
+	}
+	if total <= 0 {
+		total = used + unlimited
+	}
+	free := max(total-used, 0)
+	out.Bsize, out.Frsize, out.NameLen = blockSize, blockSize, 255
+	out.Blocks, out.Bfree, out.Bavail = uint64(total/blockSize), uint64(free/blockSize), uint64(free/blockSize)
+	return 0
+}
+
+func (m *mountFS) dirAttr(out *fuse.Attr) {
+	out.Mode = fuse.S_IFDIR | 0755
+	out.Nlink = 2
+	out.SetTimes(nil, &m.mounted, &m.mounted)
+}
+
+func (m *mountFS) fileAttr(meta *metadata.FileMetadata, out *fuse.Attr) {
+	mode := meta.Mode.Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	mtime := meta.ModTime
+	if mtime.IsZero() {
+		mtime = meta.UpdatedAt
+	}
+	out.Mode = fuse.S_IFREG | uint32(mode)
+	out.Nlink = 1
+	out.Size = uint64(meta.FileSize)
+	out.Blocks = (out.Size + 511) / 512
+	out.SetTimes(nil, &mtime, &meta.UpdatedAt)
+}
+
+func (m *mountFS) spoolAttr(sp *mountSpool, out *fuse.Attr) {
+	out.Mode = fuse.S_IFREG | uint32(sp.mode)
+	out.Nlink = 1
+	if info, err := sp.file.Stat(); err == nil {
+		mtime := info.ModTime()
+		out.Size = uint64(info.Size())
+		out.Blocks = (out.Size + 511) / 512
+		out.SetTimes(nil, &mtime, &mtime)
+	}
+}
+
+func (m *mountFS) spool(p string) *mountSpool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.spools[p]
+}
+
+// 打开路径上的临时文件，没有时创建。truncate为false时先读取已有文件的内容，
+// mode为新建文件的权限（0表示沿用已有文件的权限）
+func (m *mountFS) openSpool(ctx context.Context, p string, truncate bool, mode os.FileMode) (*mountSpool, syscall.Errno) {
+	if sp := m.acquireSpool(p, truncate); sp != nil {
+		return sp, 0
+	}
+
+	var data []byte
+	meta, err := m.e.ns.GetByName(p)
+	exists := err == nil
+	if exists {
+		if mode == 0 {
+			mode = meta.Mode.Perm()
+		}
+		if !truncate {
+			if data, err = m.g.readFile(ctx, meta); err != nil {
+				return nil, mountErrno("读取文件", err)
+			}
+		}
+	} else if !errors.Is(err, metadata.ErrNotFound) {
+		return nil, mountErrno("读取文件信息", err)
+	}
+	if mode == 0 {
+		mode = 0644
+	}
+
+	file, err := os.CreateTemp(m.opts.SpoolDir, "panmatrix-mount-*")
+	if err != nil {
+		return nil, mountErrno("创建临时文件", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, mountErrno("写入临时文件", err)
+	}
+	// 新建或截断的文件即使没有写入内容也要提交
+	sp := &mountSpool{file: file, mode: mode, path: p, dirty: truncate || !exists, refs: 1}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 读取已有内容期间其他句柄可能已经打开了同一个文件
+	if existing := m.spools[p]; existing != nil {
+		file.Close()
+		os.Remove(file.Name())
+		existing.refs++
+		return existing, 0
+	}
+	m.spools[p] = sp
+	return sp, 0
+}
+
+func (m *mountFS) acquireSpool(p string, truncate bool) *mountSpool {
+	m.mu.Lock()
+	sp := m.spools[p]
+	if sp != nil {
+		sp.refs++
+	}
+	m.mu.Unlock()
+
+	if sp != nil && truncate {
+		sp.file.Truncate(0)
+		sp.mu.Lock()
+		sp.dirty = true
+		sp.mu.Unlock()
+	}
+	return sp
+}
+
+// 最后一个句柄关闭后删除临时文件
+func (m *mountFS) releaseSpool(sp *mountSpool) {
+	m.mu.Lock()
+	sp.refs--
+	last := sp.refs == 0
+	if last {
+		sp.mu.Lock()
+		if m.spools[sp.path] == sp {
+			delete(m.spools, sp.path)
+		}
+		sp.mu.Unlock()
+	}
+	m.mu.Unlock()
+
+	if last {
+		sp.file.Close()
+		os.Remove(sp.file.Name())
+	}
+}
+
+// 文件被删除，打开的句柄仍然可以读写，但不再提交。返回路径上是否有写入中的文件
+func (m *mountFS) dropSpool(p string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sp := m.spools[p]
+	if sp == nil {
+		return false
+	}
+	sp.mu.Lock()
+	sp.removed = true
+	sp.mu.Unlock()
+	delete(m.spools, p)
+	return true
+}
+
+func (m *mountFS) moveSpool(sp *mountSpool, to string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old := m.spools[to]; old != nil && old != sp {
+		old.mu.Lock()
+		old.removed = true
+		old.mu.Unlock()
+	}
+	sp.mu.Lock()
+	delete(m.spools, sp.path)
+	sp.path, sp.dirty = to, true
+	sp.mu.Unlock()
+	m.spools[to] = sp
+}
+
+// 将临时文件的内容写入阵列并提交，没有修改时不做任何事
+func (m *mountFS) commit(ctx context.Context, sp *mountSpool) (errno syscall.Errno) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if !sp.dirty || sp.removed {
+		return 0
+	}
+
+	info, err := sp.file.Stat()
+	if err != nil {
+		return mountErrno("读取临时文件", err)
+	}
+	data := make([]byte, info.Size())
+	if _, err := sp.file.ReadAt(data, 0); err != nil && err != io.EOF {
+		return mountErrno("读取临时文件", err)
+	}
+	if _, err := m.g.store(ctx, sp.path, data, sp.mode); err != nil {
+		return mountErrno("写入文件", err)
+	}
+	sp.dirty = false
+	return 0
+}
+
+// 只能重命名空目录（元数据中的目录没有移动操作）
+func (m *mountFS) renameDir(from, to string) syscall.Errno {
+	entries, err := m.e.ns.ListDir(from)
+	if err != nil {
+		return mountErrno("重命名目录", err)
+	}
+	if len(entries) > 0 {
+		return syscall.EXDEV
+	}
+	if err := m.e.ns.Mkdir(to, true); err != nil {
+		return mountErrno("重命名目录", err)
+	}
+	if err := m.e.ns.Rmdir(from); err != nil {
+		return mountErrno("重命名目录", err)
+	}
+	return 0
+}
+
+// 只读打开的已提交文件
+type mountReader struct {
+	r *fileReader
+}
+
+var (
+	_ fs.FileReader   = (*mountReader)(nil)
+	_ fs.FileReleaser = (*mountReader)(nil)
+)
+
+func (r *mountReader) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := r.r.readAt(ctx, dest, off)
+	if err != nil && err != io.EOF {
+		return nil, mountErrno("读取文件", err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (r *mountReader) Release(ctx context.Context) syscall.Errno {
+	r.r.close()
+	return 0
+}
+
+// 可写打开的文件，读写都在临时文件上进行
+type mountWriter struct {
+	m  *mountFS
+	sp *mountSpool
+}
+
+var (
+	_ fs.FileReader   = (*mountWriter)(nil)
+	_ fs.FileWriter   = (*mountWriter)(nil)
+	_ fs.FileFlusher  = (*mountWriter)(nil)
+	_ fs.FileFsyncer  = (*mountWriter)(nil)
+	_ fs.FileReleaser = (*mountWriter)(nil)
+)
+
+func (w *mountWriter) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := w.sp.file.ReadAt(dest, off)
+	if err != nil && err != io.EOF {
+		return nil, mountErrno("读取临时文件", err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (w *mountWriter) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	n, err := w.sp.file.WriteAt(data, off)
+	w.sp.mu.Lock()
+	w.sp.dirty = true
+	w.sp.mu.Unlock()
+	if err != nil {
+		return uint32(n), mountErrno("写入临时文件", err)
+	}
+	return uint32(n), 0
+}
+
+// 每次close都会调用，提交的错误（如超出配额）由close返回给应用
+func (w *mountWriter) Flush(ctx context.Context) syscall.Errno {
+	return w.m.commit(ctx, w.sp)
+}
+
+func (w *mountWriter) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return w.m.commit(ctx, w.sp)
+}
+
+func (w *mountWriter) Release(ctx context.Context) syscall.Errno {
+	w.m.releaseSpool(w.sp)
+	return 0
+}
+
+// 将错误转换为errno，errno无法携带错误信息，同时记录到日志
+func mountErrno(op string, err error) syscall.Errno {
+	var errno syscall.Errno
+	switch {
+	case errors.As(err, &errno):
+		return errno
+	case errors.Is(err, metadata.ErrNotFound), errors.Is(err, metadata.ErrFileNotCommitted):
+		return syscall.ENOENT
+	case errors.Is(err, metadata.ErrDirNotEmpty):
+		return syscall.ENOTEMPTY
+	case errors.Is(err, metadata.ErrDirShared):
+		return syscall.EACCES
+	case errors.Is(err, metadata.ErrQuotaExceeded):
+		errno = syscall.EDQUOT
+	case errors.Is(err, metadata.ErrDirsUnsupported):
+		errno = syscall.ENOTSUP
+	case errors.Is(err, scheduler.ErrBusy):
+		errno = syscall.EAGAIN
+	case errors.Is(err, context.Canceled):
+		return syscall.EINTR
+	default:
+		errno = syscall.EIO
+	}
+	log.Printf("警告: %s失败: %v", op, err)
+	return errno
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin)
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+func (e *env) mount(ctx context.Context, dir string, opts mountOptions) error {
+	return errors.New("当前平台不支持FUSE挂载，可以使用WebDAV服务、Web界面或gRPC接口")
+}
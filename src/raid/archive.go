@@ -0,0 +1,109 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+)
+
+// 归档恢复请求的提交结果
+type RestoreReport struct {
+	Requested int      // 已提交恢复请求的条带块数
+	Failed    []string // 提交失败的条带块
+}
+
+// 归档驱动器读取前需要数小时的恢复，只能作为RAID5的固定校验盘，正常读取不会访问它
+func validateArchiveMembers(level RAIDLevel, members map[string]drivers.StorageDriver) error {
+	var archives []string
+	for name, driver := range members {
+		if drivers.CapabilitiesOf(driver).Archive {
+			archives = append(archives, name)
+		}
+	}
+	sort.Strings(archives)
+
+	switch {
+	case len(archives) == 0:
+		return nil
+	case level != RAID5:
+		return fmt.Errorf("归档驱动器只能作为RAID5的校验盘: %v", archives)
+	case len(archives) > 1:
+		return fmt.Errorf("RAID5最多只能有一个归档驱动器: %v", archives)
+	}
+	return nil
+}
+
+// 阵列中的归档驱动器，没有时返回空
+func (rc *RAIDController) archiveDriver() string {
+	for _, name := range rc.driverNames {
+		if drivers.CapabilitiesOf(rc.drivers[name]).Archive {
+			return name
+		}
+	}
+	return ""
+}
+
+// RAID5条带中校验块的位置：有归档驱动器时固定放在归档驱动器上，否则轮转分布
+func (rc *RAIDController) parityIndex(stripeIndex int) int {
+	if name := rc.archiveDriver(); name != "" {
+		return sort.SearchStrings(rc.driverNames, name)
+	}
+	return stripeIndex % rc.stripeWidth
+}
+
+// 提交文件所有归档条带块的恢复请求，恢复完成后即可进行降级读取
+func (rc *RAIDController) RestoreFile(ctx context.Context, fileID string) (*RestoreReport, error) {
+	layout := rc.StripeLayout(fileID)
+	if len(layout) == 0 {
+		return nil, fmt.Errorf("文件没有条带分布记录: %s", fileID)
+	}
+
+	report := &RestoreReport{}
+	for _, strip := range layoutStrips(layout) {
+		driver, ok := rc.drivers[strip.DriverName]
+		if !ok || !drivers.CapabilitiesOf(driver).Archive {
+			continue
+		}
+		if err := rc.restoreStrip(ctx, strip); err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %v", strip.StorageID, err))
+			continue
+		}
+		report.Requested++
+	}
+	return report, nil
+}
+
+// 提交一个条带块及其所有子块的恢复请求
+func (rc *RAIDController) restoreStrip(ctx context.Context, strip metadata.StripMetadata) error {
+	driver, ok := rc.drivers[strip.DriverName]
+	if !ok {
+		return fmt.Errorf("驱动器不存在: %s", strip.DriverName)
+	}
+
+	if len(strip.Parts) == 0 {
+		return drivers.RestoreChunk(ctx, driver, strip.RemoteKey())
+	}
+	for _, part := range strip.Parts {
+		if err := drivers.RestoreChunk(ctx, driver, part.RemoteKey()); err != nil {
+			return fmt.Errorf("恢复子块%d失败: %w", part.PartIndex, err)
+		}
+	}
+	return nil
+}
+
+// 降级读取需要的校验块已归档时自动提交恢复请求，返回的错误仍可用
+// errors.Is(err, drivers.ErrRestoreInProgress)识别，调用方稍后重试即可
+func (rc *RAIDController) requestRestore(ctx context.Context, strip metadata.StripMetadata, readErr error) error {
+	var restoreErr *drivers.RestoreError
+	if errors.As(readErr, &restoreErr) && restoreErr.Ongoing {
+		return readErr
+	}
+	if err := rc.restoreStrip(ctx, strip); err != nil {
+		return fmt.Errorf("%w（提交恢复请求失败: %v）", readErr, err)
+	}
+	return fmt.Errorf("%w（已提交恢复请求）", readErr)
+}
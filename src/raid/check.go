@@ -0,0 +1,258 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+)
+
+// 元数据一致性检查选项
+type CheckOptions struct {
+	// 下载每个块，校验大小和SHA-256。否则按驱动器的列举结果检查块是否存在、大小是否足够，
+	// 不支持列举的驱动器仍然下载检查
+	Deep bool
+
+	// 利用同一条带的冗余数据重写缺失或损坏的块，丢弃缺失的本地副本记录
+	Heal bool
+}
+
+// 一个与元数据不符的条带块
+type StripIssue struct {
+	FileID      string
+	StripeIndex int
+	Strip       metadata.StripMetadata
+	Reason      string
+	Healed      bool
+	HealError   string // 修复失败的原因
+}
+
+// 元数据一致性检查结果
+type ConsistencyReport struct {
+	Files      int
+	Strips     int          // 检查的条带块数
+	Missing    []StripIssue // 驱动器上已不存在的块（记录已过期）
+	Damaged    []StripIssue // 大小或校验和与记录不符的块
+	Unreadable []StripIssue // 读取失败、无法判断的块，不修复
+	Listed     []string     // 按列举结果检查的驱动器
+	Downloaded []string     // 逐块下载检查的驱动器（深度检查或不支持列举）
+
+	Healed        int      // 重写的块数
+	DroppedCopies int      // 丢弃的本地副本记录数
+	AffectedFiles []string // 条带记录发生变化的文件ID，调用方需保存
+}
+
+// 驱动器上的块，按下载ID和storageID索引
+type chunkListing map[string]int64
+
+func (l chunkListing) size(storageID, remoteKey string) (int64, bool) {
+	if size, ok := l[remoteKey]; ok {
+		return size, true
+	}
+	size, ok := l[storageID]
+	return size, ok
+}
+
+// 检查元数据中记录的每个条带块是否确实存在于驱动器上。文件的条带分布需已通过LoadLayout加载，
+// 修复后的分布通过StripeLayout读取，AffectedFiles中的文件需由调用方保存
+func (rc *RAIDController) CheckConsistency(ctx context.Context, fileIDs []string, opts CheckOptions) (*ConsistencyReport, error) {
+	report := &ConsistencyReport{Files: len(fileIDs)}
+
+	used := make(map[string]bool)
+	for _, fileID := range fileIDs {
+		for _, strip := range layoutStrips(rc.StripeLayout(fileID)) {
+			used[strip.DriverName] = true
+		}
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	listings := make(map[string]chunkListing)
+	for _, name := range names {
+		if !opts.Deep {
+			if listing, err := rc.listDriverChunks(ctx, name); err == nil {
+				listings[name] = listing
+				report.Listed = append(report.Listed, name)
+				continue
+			} else if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+		}
+		report.Downloaded = append(report.Downloaded, name)
+	}
+
+	for _, fileID := range fileIDs {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if rc.checkFile(ctx, fileID, listings, opts, report) {
+			report.AffectedFiles = append(report.AffectedFiles, fileID)
+		}
+	}
+	return report, nil
+}
+
+// 列举驱动器上的所有条带块
+func (rc *RAIDController) listDriverChunks(ctx context.Context, name string) (chunkListing, error) {
+	driver, ok := rc.drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("驱动器不存在: %s", name)
+	}
+	chunks, err := drivers.ListChunks(ctx, driver, "file_")
+	if err != nil {
+		return nil, err
+	}
+	listing := make(chunkListing, len(chunks))
+	for _, chunk := range chunks {
+		listing[chunk.StorageID] = chunk.Size
+		listing[chunk.Key()] = chunk.Size
+	}
+	return listing, nil
+}
+
+// 检查并按需修复一个文件的所有条带块，返回条带记录是否发生变化
+func (rc *RAIDController) checkFile(ctx context.Context, fileID string, listings map[string]chunkListing, opts CheckOptions, report *ConsistencyReport) bool {
+	stripes := rc.StripeLayout(fileID)
+	changed := false
+
+	for i := range stripes {
+		stripe := &stripes[i]
+
+		if stripe.LocalCopy != nil {
+			report.Strips++
+			if issue, missing := rc.checkStrip(ctx, fileID, *stripe, *stripe.LocalCopy, listings, opts, report); issue != nil && missing && opts.Heal {
+				// 本地副本不是权威数据，丢弃记录即可
+				stripe.LocalCopy = nil
+				report.DroppedCopies++
+				changed = true
+			}
+		}
+
+		for j := range stripe.Strips {
+			report.Strips++
+			if issue, _ := rc.checkStrip(ctx, fileID, *stripe, stripe.Strips[j], listings, opts, report); issue != nil && opts.Heal {
+				if healed, ok := rc.healStrip(ctx, *stripe, stripe.Strips[j], issue); ok {
+					stripe.Strips[j] = healed
+					report.Healed++
+					changed = true
+				}
+			}
+		}
+
+		if stripe.ParityStrip != nil {
+			report.Strips++
+			if issue, _ := rc.checkStrip(ctx, fileID, *stripe, *stripe.ParityStrip, listings, opts, report); issue != nil && opts.Heal {
+				if healed, ok := rc.healStrip(ctx, *stripe, *stripe.ParityStrip, issue); ok {
+					stripe.ParityStrip = &healed
+					report.Healed++
+					changed = true
+				}
+			}
+		}
+	}
+
+	if changed {
+		rc.LoadLayout(fileID, stripes)
+	}
+	return changed
+}
+
+// 检查一个条带块，与记录不符时记入报告并返回该问题（missing表示块已不存在）。
+// 读取失败无法判断时同样记入报告，但返回nil，不做修复
+func (rc *RAIDController) checkStrip(ctx context.Context, fileID string, stripe metadata.StripeMetadata, strip metadata.StripMetadata,
+	listings map[string]chunkListing, opts CheckOptions, report *ConsistencyReport) (*StripIssue, bool) {
+
+	issue := StripIssue{FileID: fileID, StripeIndex: stripe.StripeIndex, Strip: strip}
+
+	if listing, ok := listings[strip.DriverName]; ok {
+		missing, reason := checkListed(listing, strip)
+		if reason == "" {
+			return nil, false
+		}
+		issue.Reason = reason
+		if missing {
+			report.Missing = append(report.Missing, issue)
+			return &report.Missing[len(report.Missing)-1], true
+		}
+		report.Damaged = append(report.Damaged, issue)
+		return &report.Damaged[len(report.Damaged)-1], false
+	}
+
+	data, err := rc.downloadStrip(ctx, strip)
+	switch {
+	case errors.Is(err, drivers.ErrChunkNotFound):
+		issue.Reason = "驱动器上不存在"
+		report.Missing = append(report.Missing, issue)
+		return &report.Missing[len(report.Missing)-1], true
+	case err != nil:
+		issue.Reason = fmt.Sprintf("读取失败: %v", err)
+		report.Unreadable = append(report.Unreadable, issue)
+		return nil, false
+	}
+
+	if int64(len(data)) != strip.StripSize {
+		issue.Reason = fmt.Sprintf("大小不符: 记录%d, 实际%d", strip.StripSize, len(data))
+	} else if strip.Checksum != "" && ContentHash(data) != strip.Checksum {
+		issue.Reason = "校验和不符"
+	} else {
+		return nil, false
+	}
+	report.Damaged = append(report.Damaged, issue)
+	return &report.Damaged[len(report.Damaged)-1], false
+}
+
+// 按列举结果检查一个条带块。驱动器上的大小包括加密和混淆的开销，只能判断是否被截断
+func checkListed(listing chunkListing, strip metadata.StripMetadata) (bool, string) {
+	if len(strip.Parts) == 0 {
+		size, ok := listing.size(strip.StorageID, strip.RemoteKey())
+		if !ok {
+			return true, "驱动器上不存在"
+		}
+		if size < strip.StripSize {
+			return false, fmt.Sprintf("大小不符: 记录%d, 驱动器上%d", strip.StripSize, size)
+		}
+		return false, ""
+	}
+
+	for _, part := range strip.Parts {
+		size, ok := listing.size(part.StorageID, part.RemoteKey())
+		if !ok {
+			return true, fmt.Sprintf("子块%d在驱动器上不存在", part.PartIndex)
+		}
+		if size < part.Size {
+			return false, fmt.Sprintf("子块%d大小不符: 记录%d, 驱动器上%d", part.PartIndex, part.Size, size)
+		}
+	}
+	return false, ""
+}
+
+// 利用同一条带的冗余数据重建条带块，写回原驱动器的原位置。
+// 重建的数据与记录的校验和不符时不写入，避免用错误的数据覆盖
+func (rc *RAIDController) healStrip(ctx context.Context, stripe metadata.StripeMetadata, strip metadata.StripMetadata, issue *StripIssue) (metadata.StripMetadata, bool) {
+	data, err := rc.rebuildStrip(ctx, stripe, strip)
+	if err != nil {
+		issue.HealError = fmt.Sprintf("无法重建: %v", err)
+		return strip, false
+	}
+	if strip.Checksum != "" && ContentHash(data) != strip.Checksum {
+		issue.HealError = "重建的数据与记录的校验和不符"
+		return strip, false
+	}
+
+	// 损坏的块仍占用原位置，先删除；已不存在的子块跳过
+	rc.deleteStrip(ctx, strip)
+
+	loc, err := rc.uploadStrip(ctx, strip.DriverName, strip.StorageID, data)
+	if err != nil {
+		issue.HealError = fmt.Sprintf("写入驱动器%s失败: %v", strip.DriverName, err)
+		return strip, false
+	}
+	issue.Healed = true
+	return newStripRecord(strip.StripIndex, strip.DriverName, strip.StorageID, data, strip.IsParity, loc), true
+}
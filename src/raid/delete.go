@@ -0,0 +1,67 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"panmatrix/drivers"
+)
+
+// 文件删除结果
+type DeleteReport struct {
+	DeletedStrips int
+	MissingStrips int      // 远程已不存在的块
+	Unsupported   []string // 驱动器不支持删除而遗留的块
+	SharedStrips  int      // 仍被其他文件引用而保留的块
+}
+
+// 删除文件在所有驱动器上的条带块，调用前需通过LoadLayout加载条带分布
+//
+// 远程已不存在的块视为删除成功；驱动器不支持删除时记录在报告中并继续。
+// 其他失败保留条带分布并返回错误，以便修复后重试。
+// 设置了引用计数时，仍被其他文件引用的块保留不删。
+func (rc *RAIDController) DeleteFile(ctx context.Context, fileID string) (*DeleteReport, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.layoutMu.Lock()
+	stripes, ok := rc.layouts[fileID]
+	rc.layoutMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("文件条带分布未加载: %s", fileID)
+	}
+
+	report := &DeleteReport{}
+	var failed []error
+	for _, strip := range layoutStrips(stripes) {
+		if err := ctx.Err(); err != nil {
+			return report, fmt.Errorf("删除已取消: %v", err)
+		}
+		if rc.sharedStrip(fileID, strip) {
+			report.SharedStrips++
+			continue
+		}
+
+		err := rc.deleteStrip(ctx, strip)
+		switch {
+		case err == nil:
+			report.DeletedStrips++
+		case errors.Is(err, drivers.ErrChunkNotFound):
+			report.MissingStrips++
+		case errors.Is(err, drivers.ErrDeleteUnsupported):
+			report.Unsupported = append(report.Unsupported, strip.DriverName+"/"+strip.StorageID)
+		default:
+			failed = append(failed, fmt.Errorf("%s/%s: %v", strip.DriverName, strip.StorageID, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return report, fmt.Errorf("删除%d个条带块失败: %v", len(failed), errors.Join(failed...))
+	}
+
+	rc.layoutMu.Lock()
+	delete(rc.layouts, fileID)
+	rc.layoutMu.Unlock()
+	return report, nil
+}
@@ -0,0 +1,59 @@
+package raid
+
+import (
+	"fmt"
+	"slices"
+)
+
+// 排空驱动器：不再在该驱动器上放置新数据，已有的条带块仍按记录读取，之后可以用Evacuate迁空。
+// 驱动器已排空时不做任何操作
+func (rc *RAIDController) Drain(driverName string) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if _, ok := rc.drivers[driverName]; !ok {
+		return fmt.Errorf("驱动器不存在: %s", driverName)
+	}
+	if !slices.Contains(rc.driverNames, driverName) {
+		return nil
+	}
+	if err := validateDriverCount(rc.level, len(rc.driverNames)-1); err != nil {
+		return fmt.Errorf("移除%s后阵列不满足要求: %v", driverName, err)
+	}
+
+	remaining := make([]string, 0, len(rc.driverNames)-1)
+	for _, name := range rc.driverNames {
+		if name != driverName {
+			remaining = append(remaining, name)
+		}
+	}
+	rc.driverNames = remaining
+	rc.stripeWidth = len(remaining)
+	return nil
+}
+
+// 取消排空，驱动器重新参与新数据的放置
+func (rc *RAIDController) Undrain(driverName string) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if _, ok := rc.drivers[driverName]; !ok {
+		return fmt.Errorf("驱动器不存在: %s", driverName)
+	}
+	if slices.Contains(rc.driverNames, driverName) {
+		return nil
+	}
+	rc.driverNames = append(rc.driverNames, driverName)
+	slices.Sort(rc.driverNames)
+	rc.stripeWidth = len(rc.driverNames)
+	delete(rc.removable, driverName)
+	return nil
+}
+
+// 驱动器是否已排空（包括迁空中和已迁空的驱动器）
+func (rc *RAIDController) IsDraining(driverName string) bool {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	_, ok := rc.drivers[driverName]
+	return ok && !slices.Contains(rc.driverNames, driverName)
+}
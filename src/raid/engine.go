@@ -2,12 +2,18 @@ package raid
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
+	"time"
 
-	"drivers"
+	"panmatrix/drivers"
+	"panmatrix/metadata"
 )
 
 // RAID级别定义
@@ -46,92 +52,252 @@ type StripLocation struct {
 type RAIDController struct {
 	level       RAIDLevel
 	drivers     map[string]drivers.StorageDriver
-	stripeSize  int64  // 条带大小（字节）
+	driverNames []string // 排序后的驱动器名称，保证条带分布稳定
+	StripeSize  int64  // 条带大小（字节）
 	stripeWidth int    // 条带宽度（驱动器数量）
 	
+	// 稀疏文件支持：全零条带记录为空洞而不上传
+	sparse bool
+	
+	// 上传进度存储，用于中断后续传
+	progressStore UploadProgressStore
+	progressMu    sync.Mutex // 保护写入中的上传进度，条带块的分片会话并发保存
+	
+	// 条带位置的持久化，每个条带写入完成时记录
+	stripRecorder StripRecorder
+	
+	// 块的引用计数，设置后删除时保留仍被其他文件引用的块
+	chunkRefs ChunkRefCounter
+	
+	// 传输调度，设置后按优先级分配驱动器的传输槽位
+	transfers TransferScheduler
+	
+	// 重新放置条带时的驱动器选择，设置后重新条带化和迁空驱动器遵守调度器的规则
+	planner PlacementPlanner
+	
 	// 对于RAID5，需要记录奇偶校验分布
 	parityRotation int  // 奇偶校验轮转
 	
+	// 每个文件的条带块实际存储位置
+	layouts  map[string][]metadata.StripeMetadata
+	layoutMu sync.Mutex
+	
+	// 混合模式：本地副本策略及其访问时间（用于LRU淘汰）
+	hybrid      *HybridPolicy
+	localAccess map[string]time.Time
+	localPinned map[string]bool // 本地副本不被淘汰的文件
+	localEvicted map[string]bool // 写入时为腾出空间被淘汰了本地副本、条带分布待保存的文件
+	
+	// 已迁空、可以安全移除的驱动器
+	removable map[string]bool
+	
 	mu sync.RWMutex
 }
 
 func NewRAIDController(level RAIDLevel, drivers map[string]drivers.StorageDriver, stripeSize int64) (*RAIDController, error) {
 	driverCount := len(drivers)
 	
-	// 验证RAID级别与驱动器数量的兼容性
+	if err := validateDriverCount(level, driverCount); err != nil {
+		return nil, err
+	}
+	if err := validateArchiveMembers(level, drivers); err != nil {
+		return nil, err
+	}
+	
+	driverNames := make([]string, 0, driverCount)
+	for name := range drivers {
+		driverNames = append(driverNames, name)
+	}
+	sort.Strings(driverNames)
+	
+	return &RAIDController{
+		level:       level,
+		drivers:     drivers,
+		driverNames: driverNames,
+		StripeSize:  stripeSize,
+		stripeWidth: driverCount,
+		layouts:     make(map[string][]metadata.StripeMetadata),
+		removable:   make(map[string]bool),
+	}, nil
+}
+
+// 阵列的RAID级别，初始化后不再改变
+func (rc *RAIDController) Level() RAIDLevel {
+	return rc.level
+}
+
+// 验证RAID级别与驱动器数量的兼容性
+func validateDriverCount(level RAIDLevel, driverCount int) error {
 	switch level {
 	case RAID0:
 		if driverCount < 2 {
-			return nil, errors.New("RAID0需要至少2个驱动器")
+			return errors.New("RAID0需要至少2个驱动器")
 		}
 	case RAID1:
 		if driverCount < 2 {
-			return nil, errors.New("RAID1需要至少2个驱动器")
+			return errors.New("RAID1需要至少2个驱动器")
 		}
 	case RAID5:
 		if driverCount < 3 {
-			return nil, errors.New("RAID5需要至少3个驱动器")
+			return errors.New("RAID5需要至少3个驱动器")
 		}
 	case RAID10:
 		if driverCount < 4 || driverCount%2 != 0 {
-			return nil, errors.New("RAID10需要至少4个且为偶数的驱动器")
+			return errors.New("RAID10需要至少4个且为偶数的驱动器")
 		}
 	default:
-		return nil, errors.New("不支持的RAID级别")
+		return errors.New("不支持的RAID级别")
 	}
 	
-	return &RAIDController{
-		level:       level,
-		drivers:     drivers,
-		stripeSize:  stripeSize,
-		stripeWidth: driverCount,
-	}, nil
+	return nil
+}
+
+// 写入文件时的可选参数
+type WriteOptions struct {
+	// 调度器规划的条带放置，为nil时按阵列的驱动器顺序放置
+	Placement Placement
+	
+	// 从中断的上传续传时调用，startStripe为第一个需要重新写入的条带
+	OnResume func(fileID string, startStripe int)
 }
 
 // 写入文件，应用RAID策略
-func (rc *RAIDController) WriteFile(ctx context.Context, fileName string, data []byte) (string, error) {
+func (rc *RAIDController) WriteFile(ctx context.Context, data []byte, opts WriteOptions) (string, error) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 	
-	fileID := generateFileID(fileName)
+	sum := sha256.Sum256(data)
+	contentHash := hex.EncodeToString(sum[:])
+	
+	// 同一文件存在未完成的上传时，从最后提交的条带继续
+	startStripe := 0
+	progress := rc.findResumableUpload(contentHash, int64(len(data)))
+	if progress != nil {
+		startStripe = progress.CompletedStripes
+		rc.LoadLayout(progress.FileID, append([]metadata.StripeMetadata(nil), progress.Stripes[:startStripe]...))
+		if opts.OnResume != nil {
+			opts.OnResume(progress.FileID, startStripe)
+		}
+	} else {
+		fileID, err := generateFileID(sum)
+		if err != nil {
+			return "", err
+		}
+		progress = &metadata.UploadProgress{
+			ContentHash: contentHash,
+			FileID:      fileID,
+			FileSize:    int64(len(data)),
+			RAIDLevel:   int(rc.level),
+			StripeSize:  rc.StripeSize,
+			StripeWidth: rc.stripeWidth,
+		}
+		rc.beginUpload(progress)
+	}
+	fileID := progress.FileID
+	if rc.progressStore != nil {
+		ctx = withUploadProgress(ctx, progress)
+	}
+	
+	commit := func(stripeIndex int) error {
+		if err := rc.persistStripe(fileID, stripeIndex); err != nil {
+			return err
+		}
+		rc.commitStripe(progress, stripeIndex)
+		return nil
+	}
+	if err := rc.writeStripes(ctx, fileID, data, startStripe, opts.Placement, commit); err != nil {
+		// 主动取消或无法续传时清理已上传的条带块，避免在网盘上留下孤儿数据；
+		// 其他失败保留已提交的条带，重新上传同一文件时续传
+		if ctx.Err() != nil || rc.progressStore == nil {
+			rc.abortSessions(progress)
+			rc.discardLayout(fileID)
+			rc.discardPending(fileID)
+			rc.clearProgress(contentHash)
+		}
+		return "", err
+	}
+	
+	// 空文件没有条带块，同样记录（空的）条带分布
+	rc.ensureLayout(fileID)
+	// 上传进度在元数据中提交文件时删除，提交前中断仍可续传
+	return fileID, nil
+}
+
+// 按放置计划（为nil时按当前阵列布局）从startStripe开始写入条带，每个条带完成后调用onCommit，onCommit失败时中止写入
+func (rc *RAIDController) writeStripes(ctx context.Context, fileID string, data []byte, startStripe int, placement Placement, onCommit func(stripeIndex int) error) error {
 	fileSize := int64(len(data))
 	
 	// 计算需要的条带数
-	stripeCount := int(math.Ceil(float64(fileSize) / float64(rc.stripeSize)))
+	stripeCount := int(math.Ceil(float64(fileSize) / float64(rc.StripeSize)))
 	
 	// 为每个条带创建存储任务
-	for stripeIndex := 0; stripeIndex < stripeCount; stripeIndex++ {
+	for stripeIndex := startStripe; stripeIndex < stripeCount; stripeIndex++ {
+		// 上传被取消时不再调度新的条带
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("上传已取消: %v", err)
+		}
+		
 		// 计算当前条带的数据范围
-		start := int64(stripeIndex) * rc.stripeSize
-		end := start + rc.stripeSize
+		start := int64(stripeIndex) * rc.StripeSize
+		end := start + rc.StripeSize
 		if end > fileSize {
 			end = fileSize
 		}
 		
 		stripeData := data[start:end]
 		
+		if rc.sparse && isAllZero(stripeData) {
+			rc.recordHole(fileID, stripeIndex, int64(len(stripeData)))
+			if onCommit != nil && ctx.Err() == nil {
+				if err := onCommit(stripeIndex); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		
+		names, err := rc.stripeDrivers(stripeIndex, placement)
+		if err != nil {
+			return err
+		}
+		
 		// 根据RAID级别处理条带
 		switch rc.level {
 		case RAID0:
-			if err := rc.writeRAID0Stripe(ctx, stripeIndex, stripeData, fileID); err != nil {
-				return "", fmt.Errorf("写入RAID0条带失败: %v", err)
+			if err := rc.writeRAID0Stripe(ctx, stripeIndex, stripeData, fileID, names); err != nil {
+				return fmt.Errorf("写入RAID0条带失败: %v", err)
 			}
 		case RAID1:
-			if err := rc.writeRAID1Stripe(ctx, stripeIndex, stripeData, fileID); err != nil {
-				return "", fmt.Errorf("写入RAID1条带失败: %v", err)
+			if err := rc.writeRAID1Stripe(ctx, stripeIndex, stripeData, fileID, names); err != nil {
+				return fmt.Errorf("写入RAID1条带失败: %v", err)
 			}
 		case RAID5:
-			if err := rc.writeRAID5Stripe(ctx, stripeIndex, stripeData, fileID); err != nil {
-				return "", fmt.Errorf("写入RAID5条带失败: %v", err)
+			if err := rc.writeRAID5Stripe(ctx, stripeIndex, stripeData, fileID, names); err != nil {
+				return fmt.Errorf("写入RAID5条带失败: %v", err)
 			}
 		case RAID10:
-			if err := rc.writeRAID10Stripe(ctx, stripeIndex, stripeData, fileID); err != nil {
-				return "", fmt.Errorf("写入RAID10条带失败: %v", err)
+			if err := rc.writeRAID10Stripe(ctx, stripeIndex, stripeData, fileID, names); err != nil {
+				return fmt.Errorf("写入RAID10条带失败: %v", err)
+			}
+		}
+		
+		if rc.hybrid != nil {
+			rc.writeLocalCopy(ctx, stripeIndex, stripeData, fileID)
+		}
+		
+		if onCommit != nil && ctx.Err() == nil {
+			if err := onCommit(stripeIndex); err != nil {
+				return err
 			}
 		}
 	}
 	
-	return fileID, nil
+	// 容错级别下部分块可能在取消后仍"成功"返回，统一按取消处理
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("上传已取消: %v", err)
+	}
+	
+	return nil
 }
 
 // 读取文件，根据RAID策略重建数据
@@ -139,30 +305,17 @@ func (rc *RAIDController) ReadFile(ctx context.Context, fileID string) ([]byte,
 	rc.mu.RLock()
 	defer rc.mu.RUnlock()
 	
-	// 首先尝试从元数据中获取文件信息（这里简化处理）
-	// 在实际实现中，需要从元数据库查询条带分布
-	
-	// 模拟：假设我们知道文件由2个条带组成
-	stripeCount := 2
-	var fullData []byte
+	// 条带数取自写入时记录或从元数据加载的条带分布，空文件没有条带
+	layout, ok := rc.loadedLayout(fileID)
+	if !ok {
+		return nil, fmt.Errorf("没有文件%s的条带分布，读取前需用LoadLayout加载元数据中的条带", fileID)
+	}
+	fullData := make([]byte, 0)
 	
-	for stripeIndex := 0; stripeIndex < stripeCount; stripeIndex++ {
-		var stripeData []byte
-		var err error
-		
-		switch rc.level {
-		case RAID0:
-			stripeData, err = rc.readRAID0Stripe(ctx, stripeIndex, fileID)
-		case RAID1:
-			stripeData, err = rc.readRAID1Stripe(ctx, stripeIndex, fileID)
-		case RAID5:
-			stripeData, err = rc.readRAID5Stripe(ctx, stripeIndex, fileID)
-		case RAID10:
-			stripeData, err = rc.readRAID10Stripe(ctx, stripeIndex, fileID)
-		}
-		
+	for stripeIndex := range layout {
+		stripeData, err := rc.readStripe(ctx, fileID, stripeIndex, layout)
 		if err != nil {
-			return nil, fmt.Errorf("读取条带%d失败: %v", stripeIndex, err)
+			return nil, fmt.Errorf("读取条带%d失败: %w", stripeIndex, err)
 		}
 		
 		fullData = append(fullData, stripeData...)
@@ -171,17 +324,53 @@ func (rc *RAIDController) ReadFile(ctx context.Context, fileID string) ([]byte,
 	return fullData, nil
 }
 
-// RAID0: 条带化写入
-func (rc *RAIDController) writeRAID0Stripe(ctx context.Context, stripeIndex int, data []byte, fileID string) error {
+// 读取一个条带的数据，layout为文件的条带分布（可以为空）
+func (rc *RAIDController) readStripe(ctx context.Context, fileID string, stripeIndex int, layout []metadata.StripeMetadata) ([]byte, error) {
+	// 空洞条带直接还原为零
+	if stripeIndex < len(layout) && layout[stripeIndex].Hole {
+		return make([]byte, layout[stripeIndex].HoleSize), nil
+	}
+	
+	// 混合模式优先读取本地副本
+	if rc.hybrid != nil {
+		if data, ok := rc.readLocalCopy(ctx, stripeIndex, fileID); ok {
+			return data, nil
+		}
+	}
+	
+	if stripeIndex < len(layout) && len(layout[stripeIndex].Strips) > 0 {
+		return rc.readStripeFromLayout(ctx, layout[stripeIndex])
+	}
+	return rc.readStripeByConvention(ctx, stripeIndex, fileID)
+}
+
+// 没有条带分布记录时，按当前阵列布局推算存储位置读取
+func (rc *RAIDController) readStripeByConvention(ctx context.Context, stripeIndex int, fileID string) ([]byte, error) {
+	switch rc.level {
+	case RAID0:
+		return rc.readRAID0Stripe(ctx, stripeIndex, fileID)
+	case RAID1:
+		return rc.readRAID1Stripe(ctx, stripeIndex, fileID)
+	case RAID5:
+		return rc.readRAID5Stripe(ctx, stripeIndex, fileID)
+	case RAID10:
+		return rc.readRAID10Stripe(ctx, stripeIndex, fileID)
+	default:
+		return nil, errors.New("不支持的RAID级别")
+	}
+}
+
+// RAID0: 条带化写入，数据按names的顺序切分
+func (rc *RAIDController) writeRAID0Stripe(ctx context.Context, stripeIndex int, data []byte, fileID string, names []string) error {
 	dataLen := len(data)
-	stripSize := int(math.Ceil(float64(dataLen) / float64(rc.stripeWidth)))
+	stripSize := int(math.Ceil(float64(dataLen) / float64(len(names))))
 	
 	var wg sync.WaitGroup
-	errCh := make(chan error, rc.stripeWidth)
+	errCh := make(chan error, len(names))
 	
-	for i := 0; i < rc.stripeWidth; i++ {
+	for i, driverName := range names {
 		wg.Add(1)
-		go func(stripIndex int) {
+		go func(stripIndex int, driverName string) {
 			defer wg.Done()
 			
 			start := stripIndex * stripSize
@@ -195,22 +384,19 @@ func (rc *RAIDController) writeRAID0Stripe(ctx context.Context, stripeIndex int,
 			
 			stripData := data[start:end]
 			
-			// 选择驱动器
-			driverName := rc.selectDriverForStrip(stripeIndex, stripIndex)
-			driver := rc.drivers[driverName]
-			
 			// 构建唯一的存储ID
 			storageID := fmt.Sprintf("%s_s%d_st%d", fileID, stripeIndex, stripIndex)
 			
-			_, err := driver.UploadChunk(ctx, stripData, storageID)
+			loc, err := rc.uploadStrip(ctx, driverName, storageID, stripData)
 			if err != nil {
 				errCh <- fmt.Errorf("驱动器%s写入失败: %v", driverName, err)
 				return
 			}
 			
 			// 记录元数据：fileID -> [条带1:[驱动器A,块1], [驱动器B,块2], ...]
-			rc.recordMetadata(fileID, stripeIndex, stripIndex, driverName, storageID)
-		}(i)
+			rc.recordMetadata(fileID, stripeIndex,
+				newStripRecord(stripIndex, driverName, storageID, stripData, false, loc))
+		}(i, driverName)
 	}
 	
 	wg.Wait()
@@ -225,30 +411,32 @@ func (rc *RAIDController) writeRAID0Stripe(ctx context.Context, stripeIndex int,
 	return nil
 }
 
-// RAID1: 镜像写入
-func (rc *RAIDController) writeRAID1Stripe(ctx context.Context, stripeIndex int, data []byte, fileID string) error {
-	// 将相同数据写入所有驱动器
+// RAID1: 镜像写入，names中的每个驱动器保存一份副本
+func (rc *RAIDController) writeRAID1Stripe(ctx context.Context, stripeIndex int, data []byte, fileID string, names []string) error {
 	var wg sync.WaitGroup
-	errCh := make(chan error, len(rc.drivers))
+	errCh := make(chan error, len(names))
 	
-	for driverName, driver := range rc.drivers {
+	for copyIndex, driverName := range names {
 		wg.Add(1)
-		go func(name string, drv drivers.StorageDriver) {
+		go func(copyIndex int, name string) {
 			defer wg.Done()
 			
 			storageID := fmt.Sprintf("%s_s%d_%s", fileID, stripeIndex, name)
-			_, err := drv.UploadChunk(ctx, data, storageID)
+			loc, err := rc.uploadStrip(ctx, name, storageID, data)
 			if err != nil {
 				errCh <- fmt.Errorf("驱动器%s镜像写入失败: %v", name, err)
+				return
 			}
-		}(driverName, driver)
+			rc.recordMetadata(fileID, stripeIndex,
+				newStripRecord(copyIndex, name, storageID, data, false, loc))
+		}(copyIndex, driverName)
 	}
 	
 	wg.Wait()
 	close(errCh)
 	
 	// 只要有一个驱动器写入成功，就认为是成功的
-	successCount := len(rc.drivers) - len(errCh)
+	successCount := len(names) - len(errCh)
 	if successCount == 0 {
 		return errors.New("所有驱动器写入失败")
 	}
@@ -256,57 +444,49 @@ func (rc *RAIDController) writeRAID1Stripe(ctx context.Context, stripeIndex int,
 	return nil
 }
 
-// RAID5: 带分布式奇偶校验的条带化
-func (rc *RAIDController) writeRAID5Stripe(ctx context.Context, stripeIndex int, data []byte, fileID string) error {
-	// 将数据分成N-1块（N为驱动器数量）
-	dataStrips := rc.splitDataForRAID5(data)
+// RAID5: 带分布式奇偶校验的条带化，names的最后一个驱动器保存校验块
+func (rc *RAIDController) writeRAID5Stripe(ctx context.Context, stripeIndex int, data []byte, fileID string, names []string) error {
+	// 将数据分成N-1块（N为本条带使用的驱动器数量）
+	dataStrips := rc.splitDataForRAID5(data, len(names)-1)
 	
 	// 计算奇偶校验
 	parityStrip := rc.calculateParity(dataStrips)
 	
-	// 确定本轮奇偶校验存储的位置
-	parityDriverIndex := stripeIndex % rc.stripeWidth
-	
 	var wg sync.WaitGroup
-	errCh := make(chan error, rc.stripeWidth)
+	errCh := make(chan error, len(names))
 	
-	for i := 0; i < rc.stripeWidth; i++ {
+	for i, driverName := range names {
+		var stripData []byte
+		var stripType string
+		
+		if i == len(names)-1 {
+			// 存储奇偶校验
+			stripData = parityStrip
+			stripType = "parity"
+		} else {
+			// 存储数据
+			stripData = dataStrips[i]
+			stripType = "data"
+		}
+		
+		if len(stripData) == 0 {
+			continue // 空数据块
+		}
+		
 		wg.Add(1)
-		go func(stripIndex int) {
+		go func(driverName string, stripData []byte, stripType string) {
 			defer wg.Done()
 			
-			var stripData []byte
-			var stripType string
-			
-			if stripIndex == parityDriverIndex {
-				// 存储奇偶校验
-				stripData = parityStrip
-				stripType = "parity"
-			} else {
-				// 存储数据
-				dataIndex := stripIndex
-				if stripIndex > parityDriverIndex {
-					dataIndex--
-				}
-				if dataIndex < len(dataStrips) {
-					stripData = dataStrips[dataIndex]
-				}
-				stripType = "data"
-			}
-			
-			if len(stripData) == 0 {
-				return // 空数据块
-			}
-			
-			driverName := rc.selectDriverByIndex(stripIndex)
-			driver := rc.drivers[driverName]
-			
 			storageID := fmt.Sprintf("%s_s%d_%s_%s", fileID, stripeIndex, stripType, driverName)
-			_, err := driver.UploadChunk(ctx, stripData, storageID)
+			loc, err := rc.uploadStrip(ctx, driverName, storageID, stripData)
 			if err != nil {
 				errCh <- fmt.Errorf("RAID5写入失败[%s]: %v", driverName, err)
+				return
 			}
-		}(i)
+			// 块的序号为驱动器在阵列中的序号，数据块按序号排列即为数据的顺序
+			rc.recordMetadata(fileID, stripeIndex,
+				newStripRecord(rc.driverIndex(driverName), driverName, storageID, stripData, stripType == "parity", loc))
+		}(driverName, stripData, stripType)
 	}
 	
 	wg.Wait()
@@ -320,10 +500,13 @@ func (rc *RAIDController) writeRAID5Stripe(ctx context.Context, stripeIndex int,
 	return nil
 }
 
-// RAID10: 先镜像再条带化
-func (rc *RAIDController) writeRAID10Stripe(ctx context.Context, stripeIndex int, data []byte, fileID string) error {
+// RAID10: 先镜像再条带化，names中相邻的两个驱动器组成镜像对
+func (rc *RAIDController) writeRAID10Stripe(ctx context.Context, stripeIndex int, data []byte, fileID string, names []string) error {
 	// 将驱动器分成镜像对
-	mirrorPairs := rc.createMirrorPairs()
+	mirrorPairs := make([][]string, 0, len(names)/2)
+	for i := 0; i+1 < len(names); i += 2 {
+		mirrorPairs = append(mirrorPairs, names[i:i+2])
+	}
 	
 	// 将数据条带化到每个镜像对
 	stripsPerPair := len(mirrorPairs)
@@ -345,17 +528,19 @@ func (rc *RAIDController) writeRAID10Stripe(ctx context.Context, stripeIndex int
 		
 		// 写入镜像对的两个驱动器
 		for _, driverName := range pair {
-			go func(name string, data []byte) {
+			go func(pairIndex int, name string, data []byte) {
 				defer wg.Done()
 				
-				driver := rc.drivers[name]
 				storageID := fmt.Sprintf("%s_s%d_pair%d_%s", fileID, stripeIndex, pairIndex, name)
 				
-				_, err := driver.UploadChunk(ctx, data, storageID)
+				loc, err := rc.uploadStrip(ctx, name, storageID, data)
 				if err != nil {
 					errCh <- fmt.Errorf("RAID10镜像对写入失败[%s]: %v", name, err)
+					return
 				}
-			}(driverName, pairData)
+				rc.recordMetadata(fileID, stripeIndex,
+					newStripRecord(pairIndex, name, storageID, data, false, loc))
+			}(pairIndex, driverName, pairData)
 		}
 	}
 	
@@ -386,10 +571,9 @@ func (rc *RAIDController) readRAID0Stripe(ctx context.Context, stripeIndex int,
 			
 			// 模拟：从元数据获取驱动器信息
 			driverName := rc.selectDriverForStrip(stripeIndex, stripIndex)
-			driver := rc.drivers[driverName]
 			
 			storageID := fmt.Sprintf("%s_s%d_st%d", fileID, stripeIndex, stripIndex)
-			data, err := driver.DownloadChunk(ctx, storageID)
+			data, err := rc.downloadStrip(ctx, rc.stripRecord(fileID, stripeIndex, driverName, storageID))
 			if err != nil {
 				errCh <- fmt.Errorf("读取条带块失败: %v", err)
 				return
@@ -418,6 +602,40 @@ func (rc *RAIDController) readRAID0Stripe(ctx context.Context, stripeIndex int,
 	return result, nil
 }
 
+// 读取RAID1条带：任一镜像可用即可
+func (rc *RAIDController) readRAID1Stripe(ctx context.Context, stripeIndex int, fileID string) ([]byte, error) {
+	copies := make([]metadata.StripMetadata, 0, len(rc.driverNames))
+	for _, driverName := range rc.driverNames {
+		storageID := fmt.Sprintf("%s_s%d_%s", fileID, stripeIndex, driverName)
+		copies = append(copies, rc.stripRecord(fileID, stripeIndex, driverName, storageID))
+	}
+	
+	data, err := rc.readAnyCopy(ctx, copies)
+	if err != nil {
+		return nil, fmt.Errorf("所有镜像读取失败: %v", err)
+	}
+	return data, nil
+}
+
+// 读取RAID10条带：每个镜像对读取任一副本后按顺序合并
+func (rc *RAIDController) readRAID10Stripe(ctx context.Context, stripeIndex int, fileID string) ([]byte, error) {
+	var result []byte
+	for pairIndex, pair := range rc.createMirrorPairs() {
+		copies := make([]metadata.StripMetadata, 0, len(pair))
+		for _, driverName := range pair {
+			storageID := fmt.Sprintf("%s_s%d_pair%d_%s", fileID, stripeIndex, pairIndex, driverName)
+			copies = append(copies, rc.stripRecord(fileID, stripeIndex, driverName, storageID))
+		}
+		pairData, err := rc.readAnyCopy(ctx, copies)
+		if err != nil {
+			return nil, fmt.Errorf("镜像对%d读取失败: %v", pairIndex, err)
+		}
+		result = append(result, pairData...)
+	}
+	
+	return result, nil
+}
+
 // 读取RAID5条带（带错误恢复）
 func (rc *RAIDController) readRAID5Stripe(ctx context.Context, stripeIndex int, fileID string) ([]byte, error) {
 	// 尝试读取所有块
@@ -433,10 +651,9 @@ func (rc *RAIDController) readRAID5Stripe(ctx context.Context, stripeIndex int,
 			defer wg.Done()
 			
 			driverName := rc.selectDriverByIndex(stripIndex)
-			driver := rc.drivers[driverName]
 			
 			// 尝试判断是数据块还是校验块
-			parityDriverIndex := stripeIndex % rc.stripeWidth
+			parityDriverIndex := rc.parityIndex(stripeIndex)
 			var stripType string
 			if stripIndex == parityDriverIndex {
 				stripType = "parity"
@@ -444,8 +661,13 @@ func (rc *RAIDController) readRAID5Stripe(ctx context.Context, stripeIndex int,
 				stripType = "data"
 			}
 			
+			// 这里无法用校验块恢复数据，不必读取归档驱动器上的校验块
+			if stripType == "parity" && driverName == rc.archiveDriver() {
+				return
+			}
+			
 			storageID := fmt.Sprintf("%s_s%d_%s_%s", fileID, stripeIndex, stripType, driverName)
-			data, err := driver.DownloadChunk(ctx, storageID)
+			data, err := rc.downloadStrip(ctx, rc.stripRecord(fileID, stripeIndex, driverName, storageID))
 			
 			mu.Lock()
 			defer mu.Unlock()
@@ -474,15 +696,15 @@ func (rc *RAIDController) readRAID5Stripe(ctx context.Context, stripeIndex int,
 }
 
 // 辅助方法
-func (rc *RAIDController) splitDataForRAID5(data []byte) [][]byte {
-	// 将数据分成N-1块（N为驱动器数量）
-	strips := make([][]byte, rc.stripeWidth-1)
-	stripSize := len(data) / (rc.stripeWidth - 1)
+func (rc *RAIDController) splitDataForRAID5(data []byte, dataCount int) [][]byte {
+	// 将数据分成dataCount块，最后一块获取除不尽的部分
+	strips := make([][]byte, dataCount)
+	stripSize := len(data) / dataCount
 	
-	for i := 0; i < rc.stripeWidth-1; i++ {
+	for i := 0; i < dataCount; i++ {
 		start := i * stripSize
 		end := start + stripSize
-		if i == rc.stripeWidth-2 {
+		if i == dataCount-1 {
 			end = len(data) // 最后一个获取剩余所有
 		}
 		strips[i] = data[start:end]
@@ -518,20 +740,14 @@ func (rc *RAIDController) calculateParity(strips [][]byte) []byte {
 
 func (rc *RAIDController) selectDriverForStrip(stripeIndex, stripIndex int) string {
 	// 简单的轮询选择
-	driverNames := make([]string, 0, len(rc.drivers))
-	for name := range rc.drivers {
-		driverNames = append(driverNames, name)
-	}
+	driverNames := rc.driverNames
 	
 	totalIndex := stripeIndex*rc.stripeWidth + stripIndex
 	return driverNames[totalIndex%len(driverNames)]
 }
 
 func (rc *RAIDController) selectDriverByIndex(index int) string {
-	driverNames := make([]string, 0, len(rc.drivers))
-	for name := range rc.drivers {
-		driverNames = append(driverNames, name)
-	}
+	driverNames := rc.driverNames
 	
 	if index >= len(driverNames) {
 		index = index % len(driverNames)
@@ -540,11 +756,10 @@ func (rc *RAIDController) selectDriverByIndex(index int) string {
 	return driverNames[index]
 }
 
+// 没有放置计划时的镜像对：按驱动器名的顺序两两组成，不考虑故障域。上传和重新条带化按调度器的计划
+// 组成跨故障域的镜像对，这里只用于推算没有条带分布记录的旧文件的位置和未设置调度器时的写入
 func (rc *RAIDController) createMirrorPairs() [][]string {
-	driverNames := make([]string, 0, len(rc.drivers))
-	for name := range rc.drivers {
-		driverNames = append(driverNames, name)
-	}
+	driverNames := rc.driverNames
 	
 	pairs := make([][]string, 0)
 	for i := 0; i < len(driverNames); i += 2 {
@@ -557,7 +772,7 @@ func (rc *RAIDController) createMirrorPairs() [][]string {
 }
 
 func (rc *RAIDController) mergeRAID5Strips(strips [][]byte, stripeIndex int) []byte {
-	parityIndex := stripeIndex % len(strips)
+	parityIndex := rc.parityIndex(stripeIndex)
 	var result []byte
 	
 	for i, strip := range strips {
@@ -571,26 +786,46 @@ func (rc *RAIDController) mergeRAID5Strips(strips [][]byte, stripeIndex int) []b
 
 func (rc *RAIDController) recoverRAID5Stripe(strips [][]byte, failedIndex int, stripeIndex int) ([]byte, error) {
 	// 使用奇偶校验和其他数据块恢复失败的数据块
-	parityIndex := stripeIndex % len(strips)
+	parityIndex := rc.parityIndex(stripeIndex)
 	
 	if failedIndex == parityIndex {
 		// 奇偶校验块丢失，不影响数据读取
 		return rc.mergeRAID5Strips(strips, stripeIndex), nil
 	}
 	
-	// 数据块丢失，需要恢复
-	// 这里简化处理，实际需要重新计算
-	return nil, errors.New("数据块恢复功能待实现")
-}
-
-func (rc *RAIDController) recordMetadata(fileID string, stripeIndex, stripIndex int, driverName, storageID string) {
-	// 在实际实现中，这里应该将元数据保存到数据库
-	// 简化处理：打印日志
-	fmt.Printf("记录元数据: 文件%s, 条带%d, 块%d -> %s:%s\n", 
-		fileID, stripeIndex, stripIndex, driverName, storageID)
+	// 数据块丢失，用校验块和其余数据块异或还原
+	parity := strips[parityIndex]
+	if parity == nil {
+		return nil, errors.New("数据块丢失且校验块不可用，无法恢复")
+	}
+	var data [][]byte
+	missing := -1
+	for i, strip := range strips {
+		if i == parityIndex {
+			continue
+		}
+		if i == failedIndex {
+			missing = len(data)
+		}
+		data = append(data, strip)
+	}
+	
+	// 没有条带分布记录时不知道是否为末尾条带，按末尾条带推断缺失块的长度
+	data[missing] = recoverFromParity(parity, data, missing, 0)
+	var result []byte
+	for _, strip := range data {
+		result = append(result, strip...)
+	}
+	return result, nil
 }
 
-func generateFileID(fileName string) string {
-	// 简单的文件ID生成
-	return fmt.Sprintf("file_%s_%d", fileName, time.Now().UnixNano())
+// 基于内容哈希生成文件ID，附加随机后缀避免相同内容的多次上传冲突
+// ID只包含[0-9a-f_]，可直接用作存储键和元数据文件名
+func generateFileID(sum [sha256.Size]byte) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("生成文件ID失败: %v", err)
+	}
+	
+	return fmt.Sprintf("file_%s_%s", hex.EncodeToString(sum[:16]), hex.EncodeToString(suffix)), nil
 }
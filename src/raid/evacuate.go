@@ -0,0 +1,256 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"panmatrix/metadata"
+)
+
+// 驱动器迁空结果
+type EvacuationReport struct {
+	MovedStrips   int      // 直接复制的条带块数
+	RebuiltStrips int      // 源驱动器不可读、通过冗余重建的条带块数
+	DroppedCopies int      // 直接丢弃的本地副本数
+	BytesMoved    int64    // 写入其他驱动器的字节数
+	AffectedFiles []string // 条带分布发生变化的文件ID
+}
+
+// 将驱动器上的所有条带块迁移到其他驱动器，完成后该驱动器可以安全移除。驱动器未排空时先排空
+func (rc *RAIDController) Evacuate(ctx context.Context, driverName string) (*EvacuationReport, error) {
+	// 先停止在该驱动器上放置新数据
+	if err := rc.Drain(driverName); err != nil {
+		return nil, err
+	}
+
+	report := &EvacuationReport{}
+
+	rc.layoutMu.Lock()
+	fileIDs := make([]string, 0, len(rc.layouts))
+	for fileID := range rc.layouts {
+		fileIDs = append(fileIDs, fileID)
+	}
+	rc.layoutMu.Unlock()
+
+	for _, fileID := range fileIDs {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		changed, err := rc.evacuateFile(ctx, fileID, driverName, report)
+		if err != nil {
+			return report, fmt.Errorf("迁移文件%s失败: %v", fileID, err)
+		}
+		if changed {
+			report.AffectedFiles = append(report.AffectedFiles, fileID)
+		}
+	}
+
+	rc.mu.Lock()
+	rc.removable[driverName] = true
+	rc.mu.Unlock()
+
+	return report, nil
+}
+
+// 驱动器是否已迁空
+func (rc *RAIDController) IsRemovable(driverName string) bool {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.removable[driverName]
+}
+
+func (rc *RAIDController) evacuateFile(ctx context.Context, fileID, driverName string, report *EvacuationReport) (bool, error) {
+	stripes := rc.StripeLayout(fileID)
+	changed := false
+
+	for i := range stripes {
+		stripe := &stripes[i]
+
+		if stripe.LocalCopy != nil && stripe.LocalCopy.DriverName == driverName {
+			// 本地副本不是权威数据，直接丢弃
+			rc.deleteStrip(ctx, *stripe.LocalCopy)
+			stripe.LocalCopy = nil
+			report.DroppedCopies++
+			changed = true
+		}
+
+		for j := range stripe.Strips {
+			if stripe.Strips[j].DriverName != driverName {
+				continue
+			}
+			moved, err := rc.moveStrip(ctx, fileID, *stripe, stripe.Strips[j], report)
+			if err != nil {
+				return changed, fmt.Errorf("条带%d块%d: %v", stripe.StripeIndex, stripe.Strips[j].StripIndex, err)
+			}
+			stripe.Strips[j] = moved
+			changed = true
+		}
+
+		if stripe.ParityStrip != nil && stripe.ParityStrip.DriverName == driverName {
+			moved, err := rc.moveStrip(ctx, fileID, *stripe, *stripe.ParityStrip, report)
+			if err != nil {
+				return changed, fmt.Errorf("条带%d校验块: %v", stripe.StripeIndex, err)
+			}
+			stripe.ParityStrip = &moved
+			changed = true
+		}
+	}
+
+	if changed {
+		rc.LoadLayout(fileID, stripes)
+	}
+
+	return changed, nil
+}
+
+// 将一个条带块复制到同一条带尚未使用的驱动器上
+func (rc *RAIDController) moveStrip(ctx context.Context, fileID string, stripe metadata.StripeMetadata, strip metadata.StripMetadata, report *EvacuationReport) (metadata.StripMetadata, error) {
+	data, err := rc.downloadStrip(ctx, strip)
+	if err == nil {
+		report.MovedStrips++
+	} else {
+		data, err = rc.rebuildStrip(ctx, stripe, strip)
+		if err != nil {
+			return strip, fmt.Errorf("源驱动器不可读且无法重建: %v", err)
+		}
+		report.RebuiltStrips++
+	}
+
+	target, err := rc.evacuationTarget(fileID, stripe, strip, int64(len(data)))
+	if err != nil {
+		return strip, err
+	}
+
+	storageID := fmt.Sprintf("%s_evac_%s", strip.StorageID, target)
+	loc, err := rc.uploadStrip(ctx, target, storageID, data)
+	if err != nil {
+		return strip, fmt.Errorf("写入驱动器%s失败: %v", target, err)
+	}
+	report.BytesMoved += int64(len(data))
+
+	// 源驱动器可能已不可用，删除失败不影响迁移结果。仍被其他文件引用的源块保留，
+	// 引用它的文件各自迁移出副本
+	if !rc.sharedStrip(fileID, strip) {
+		rc.deleteStrip(ctx, strip)
+	}
+
+	return newStripRecord(strip.StripIndex, target, storageID, data, strip.IsParity, loc), nil
+}
+
+// 为迁出的条带块选择同一条带尚未使用的驱动器。设置了PlacementPlanner时由调度器选择，
+// 目标不与保存冗余数据的块（RAID1和RAID5为条带的其他块，RAID10为同一镜像对的副本）属于同一故障域
+func (rc *RAIDController) evacuationTarget(fileID string, stripe metadata.StripeMetadata, moving metadata.StripMetadata, size int64) (string, error) {
+	used := make(map[string]int)
+	var exclude, peers []string
+	add := func(strip metadata.StripMetadata) {
+		if used[strip.DriverName] == 0 {
+			exclude = append(exclude, strip.DriverName)
+		}
+		used[strip.DriverName]++
+		if strip.StorageID == moving.StorageID || strip.DriverName == moving.DriverName {
+			return
+		}
+		if rc.level != RAID10 || strip.StripIndex == moving.StripIndex {
+			peers = append(peers, strip.DriverName)
+		}
+	}
+	for _, strip := range stripe.Strips {
+		add(strip)
+	}
+	if stripe.ParityStrip != nil {
+		add(*stripe.ParityStrip)
+	}
+
+	rc.mu.RLock()
+	planner := rc.planner
+	rc.mu.RUnlock()
+	if planner != nil {
+		target, err := planner.SelectReplacement(fileID, size, peers, exclude)
+		switch {
+		case err == nil && rc.driverIndex(target) < 0:
+			return "", fmt.Errorf("调度器选择的驱动器不在阵列中: %s", target)
+		case err == nil:
+			return target, nil
+		case rc.level != RAID0:
+			return "", fmt.Errorf("没有可用的目标驱动器: %v", err)
+		}
+		// RAID0没有冗余，没有空闲的驱动器时可以与其他块共用驱动器
+	}
+
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	best := ""
+	for _, name := range rc.driverNames {
+		if used[name] == 0 {
+			return name, nil
+		}
+		if best == "" || used[name] < used[best] {
+			best = name
+		}
+	}
+
+	// 冗余级别下同一条带的两个块落在同一驱动器上会失去容错能力
+	if rc.level == RAID0 && best != "" {
+		return best, nil
+	}
+	return "", errors.New("没有可用的目标驱动器，迁移会破坏冗余")
+}
+
+// 源条带块不可读时，利用同一条带的冗余数据重建
+func (rc *RAIDController) rebuildStrip(ctx context.Context, stripe metadata.StripeMetadata, target metadata.StripMetadata) ([]byte, error) {
+	switch rc.level {
+	case RAID1, RAID10:
+		var copies []metadata.StripMetadata
+		for _, strip := range stripe.Strips {
+			if strip.StorageID == target.StorageID {
+				continue
+			}
+			if rc.level == RAID1 || strip.StripIndex == target.StripIndex {
+				copies = append(copies, strip)
+			}
+		}
+		return rc.readAnyCopy(ctx, copies)
+	case RAID5:
+		if target.IsParity {
+			results, errs := rc.downloadStrips(ctx, stripe.Strips)
+			for _, err := range errs {
+				if err != nil {
+					return nil, err
+				}
+			}
+			return rc.calculateParity(results), nil
+		}
+
+		if stripe.ParityStrip == nil {
+			return nil, errors.New("缺少校验块")
+		}
+		recovered, err := rc.downloadStrip(ctx, *stripe.ParityStrip)
+		if err != nil {
+			return nil, fmt.Errorf("读取校验块失败: %v", err)
+		}
+
+		var others []metadata.StripMetadata
+		for _, strip := range stripe.Strips {
+			if strip.StorageID != target.StorageID {
+				others = append(others, strip)
+			}
+		}
+		results, errs := rc.downloadStrips(ctx, others)
+		for i, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+			xorInto(recovered, results[i])
+		}
+
+		if target.StripSize > int64(len(recovered)) {
+			return nil, errors.New("校验块长度不足")
+		}
+		return recovered[:target.StripSize], nil
+	default:
+		return nil, errors.New("RAID0没有冗余")
+	}
+}
@@ -0,0 +1,257 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+)
+
+// 在线扩容：加入新驱动器并扩大条带宽度，之后写入的文件立即使用新宽度
+func (rc *RAIDController) AddDrivers(newDrivers map[string]drivers.StorageDriver) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for name := range newDrivers {
+		if _, exists := rc.drivers[name]; exists {
+			return fmt.Errorf("驱动器已存在: %s", name)
+		}
+	}
+
+	members := make(map[string]drivers.StorageDriver, len(rc.drivers)+len(newDrivers))
+	for name, driver := range rc.drivers {
+		members[name] = driver
+	}
+	for name, driver := range newDrivers {
+		members[name] = driver
+	}
+	if err := validateArchiveMembers(rc.level, members); err != nil {
+		return err
+	}
+
+	width := rc.stripeWidth + len(newDrivers)
+	if rc.level == RAID10 && width%2 != 0 {
+		return errors.New("RAID10扩容需要成对添加驱动器")
+	}
+
+	for name, driver := range newDrivers {
+		rc.drivers[name] = driver
+		rc.driverNames = append(rc.driverNames, name)
+	}
+	sort.Strings(rc.driverNames)
+	rc.stripeWidth = width
+
+	return nil
+}
+
+// 文件是否仍使用扩容前的条带宽度
+func (rc *RAIDController) NeedsRestripe(fileID string) bool {
+	layout := rc.StripeLayout(fileID)
+	if len(layout) == 0 {
+		return false
+	}
+
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return layout[0].StripeWidth < rc.stripeWidth
+}
+
+// 按当前条带宽度重写一个文件，并删除旧布局中不再使用的条带块。设置了PlacementPlanner时按其计划放置
+func (rc *RAIDController) RestripeFile(ctx context.Context, fileID string) (int64, error) {
+	oldLayout := rc.StripeLayout(fileID)
+	if len(oldLayout) == 0 {
+		return 0, fmt.Errorf("文件没有条带分布记录: %s", fileID)
+	}
+
+	data, err := rc.ReadFile(ctx, fileID)
+	if err != nil {
+		return 0, fmt.Errorf("读取旧布局失败: %v", err)
+	}
+
+	rc.mu.RLock()
+	planner := rc.planner
+	rc.mu.RUnlock()
+	var placement Placement
+	if planner != nil {
+		plan, release, err := planner.PlanFile(fileID, int64(len(data)))
+		if err != nil {
+			return 0, fmt.Errorf("规划新布局失败: %v", err)
+		}
+		defer release()
+		placement = plan
+	}
+
+	rc.mu.Lock()
+	// 使用新的存储前缀，避免覆盖旧布局中同名的条带块
+	storagePrefix := fmt.Sprintf("%s_w%d", fileID, rc.stripeWidth)
+	err = rc.writeStripes(ctx, storagePrefix, data, 0, placement, nil)
+	rc.mu.Unlock()
+	if err != nil {
+		rc.discardLayout(storagePrefix)
+		return 0, fmt.Errorf("写入新布局失败: %v", err)
+	}
+
+	rc.layoutMu.Lock()
+	rc.layouts[fileID] = rc.layouts[storagePrefix]
+	delete(rc.layouts, storagePrefix)
+	rc.layoutMu.Unlock()
+
+	for _, strip := range layoutStrips(oldLayout) {
+		if rc.sharedStrip(fileID, strip) {
+			continue
+		}
+		if err := rc.deleteStrip(ctx, strip); err != nil && !errors.Is(err, drivers.ErrDeleteUnsupported) {
+			fmt.Printf("警告: 删除旧条带块失败 %s: %v\n", strip.StorageID, err)
+		}
+	}
+
+	return int64(len(data)), nil
+}
+
+// 列出布局中的所有条带块（数据块、校验块和本地副本）
+func layoutStrips(stripes []metadata.StripeMetadata) []metadata.StripMetadata {
+	var strips []metadata.StripMetadata
+	for _, stripe := range stripes {
+		strips = append(strips, stripe.Strips...)
+		if stripe.ParityStrip != nil {
+			strips = append(strips, *stripe.ParityStrip)
+		}
+		if stripe.LocalCopy != nil {
+			strips = append(strips, *stripe.LocalCopy)
+		}
+	}
+	return strips
+}
+
+// 后台重新条带化选项
+type RestripeOptions struct {
+	MaxBytesPerSecond int64         // 迁移限速，<=0 表示不限速
+	PauseBetweenFiles time.Duration // 每个文件迁移后的等待时间
+
+	// 每个文件迁移完成后回调，用于持久化新的条带分布
+	OnFileDone func(fileID string, stripes []metadata.StripeMetadata)
+}
+
+// 后台重新条带化进度
+type RestripeProgress struct {
+	TotalFiles  int
+	DoneFiles   int
+	FailedFiles int
+	BytesMoved  int64
+	CurrentFile string
+}
+
+// 后台重新条带化任务
+type RestripeJob struct {
+	progress RestripeProgress
+	errs     []error
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+}
+
+// 启动后台任务，将使用旧条带宽度的文件迁移到新布局
+func (rc *RAIDController) StartRestripe(ctx context.Context, fileIDs []string, opts RestripeOptions) *RestripeJob {
+	ctx, cancel := context.WithCancel(ctx)
+	job := &RestripeJob{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	var pending []string
+	for _, fileID := range fileIDs {
+		if rc.NeedsRestripe(fileID) {
+			pending = append(pending, fileID)
+		}
+	}
+	job.progress.TotalFiles = len(pending)
+
+	go job.run(ctx, rc, pending, opts)
+
+	return job
+}
+
+func (job *RestripeJob) run(ctx context.Context, rc *RAIDController, fileIDs []string, opts RestripeOptions) {
+	defer close(job.done)
+	defer job.cancel()
+
+	startTime := time.Now()
+	for _, fileID := range fileIDs {
+		if ctx.Err() != nil {
+			job.addError(ctx.Err())
+			return
+		}
+
+		job.mu.Lock()
+		job.progress.CurrentFile = fileID
+		job.mu.Unlock()
+
+		moved, err := rc.RestripeFile(ctx, fileID)
+
+		job.mu.Lock()
+		if err != nil {
+			job.progress.FailedFiles++
+			job.errs = append(job.errs, fmt.Errorf("文件%s: %v", fileID, err))
+		} else {
+			job.progress.DoneFiles++
+			job.progress.BytesMoved += moved
+		}
+		bytesMoved := job.progress.BytesMoved
+		job.mu.Unlock()
+
+		if err == nil && opts.OnFileDone != nil {
+			opts.OnFileDone(fileID, rc.StripeLayout(fileID))
+		}
+
+		// 限速：按已迁移字节数计算应耗费的时间
+		wait := opts.PauseBetweenFiles
+		if opts.MaxBytesPerSecond > 0 {
+			expected := time.Duration(float64(bytesMoved) / float64(opts.MaxBytesPerSecond) * float64(time.Second))
+			if ahead := expected - time.Since(startTime); ahead > wait {
+				wait = ahead
+			}
+		}
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	job.mu.Lock()
+	job.progress.CurrentFile = ""
+	job.mu.Unlock()
+}
+
+func (job *RestripeJob) addError(err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.errs = append(job.errs, err)
+}
+
+// 获取当前进度
+func (job *RestripeJob) Progress() RestripeProgress {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.progress
+}
+
+// 取消任务，已迁移的文件保持新布局
+func (job *RestripeJob) Cancel() {
+	job.cancel()
+}
+
+// 等待任务结束，返回迁移过程中的错误
+func (job *RestripeJob) Wait() error {
+	<-job.done
+
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return errors.Join(job.errs...)
+}
@@ -0,0 +1,55 @@
+package raid
+
+import (
+	"context"
+	"time"
+)
+
+// 对冲读取的策略，由调度器实现（可选，TransferScheduler同时实现该接口时生效）。
+// 从冗余副本读取时，一个来源超过HedgeDelay仍未完成就同时从另一个来源读取，使用先完成的结果并取消另一个。
+// 返回0表示不对冲，只在前一个来源失败时才读取下一个
+type ReadHedger interface {
+	HedgeDelay(ctx context.Context, driverName string) time.Duration
+}
+
+// 从驱动器读取时发起对冲读取前的等待时间，0表示不对冲
+func (rc *RAIDController) hedgeDelay(ctx context.Context, driverName string) time.Duration {
+	hedger, ok := rc.transfers.(ReadHedger)
+	if !ok {
+		return 0
+	}
+	return max(hedger.HedgeDelay(ctx, driverName), 0)
+}
+
+// 单次下载的结果，index为条带块在输入中的位置
+type downloadResult struct {
+	index int
+	data  []byte
+	err   error
+}
+
+// 对冲计时器，未启动时channel为nil，select时不会触发
+type hedgeTimer struct {
+	timer *time.Timer
+}
+
+func (t *hedgeTimer) start(delay time.Duration) {
+	t.stop()
+	if delay > 0 {
+		t.timer = time.NewTimer(delay)
+	}
+}
+
+func (t *hedgeTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+func (t *hedgeTimer) C() <-chan time.Time {
+	if t.timer == nil {
+		return nil
+	}
+	return t.timer.C
+}
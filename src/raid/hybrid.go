@@ -0,0 +1,255 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+)
+
+// 混合冗余策略：每个条带在本地驱动器保留一份完整副本用于快速读取，
+// 云端驱动器按RAID级别提供冗余，云端副本始终是权威数据
+//
+// 只有云端写入成功后才创建的本地副本会被淘汰；本地驱动器上的其他数据
+// （元数据、日志等）不参与淘汰，只占用空间
+type HybridPolicy struct {
+	LocalDriver   string   // 本地驱动器名称
+	MinFreeRatio  float64  // 本地可用空间低于总空间的该比例时淘汰本地副本
+	MaxLocalBytes int64    // 本地副本总大小上限，写入前按LRU淘汰；0表示不限
+	PinnedFiles   []string // 本地副本常驻、不被淘汰的文件ID
+}
+
+// 本地副本淘汰候选
+type localCopyEntry struct {
+	fileID      string
+	stripeIndex int
+	strip       metadata.StripMetadata
+	lastAccess  time.Time
+	pinned      bool
+}
+
+func NewHybridRAIDController(level RAIDLevel, allDrivers map[string]drivers.StorageDriver, stripeSize int64, policy HybridPolicy) (*RAIDController, error) {
+	// 云端必须提供镜像或奇偶校验冗余
+	if level != RAID1 && level != RAID5 && level != RAID10 {
+		return nil, errors.New("混合模式仅支持RAID1、RAID5和RAID10")
+	}
+
+	localDriver, ok := allDrivers[policy.LocalDriver]
+	if !ok {
+		return nil, fmt.Errorf("本地驱动器不存在: %s", policy.LocalDriver)
+	}
+
+	cloudDrivers := make(map[string]drivers.StorageDriver)
+	for name, driver := range allDrivers {
+		if name != policy.LocalDriver {
+			cloudDrivers[name] = driver
+		}
+	}
+
+	rc, err := NewRAIDController(level, cloudDrivers, stripeSize)
+	if err != nil {
+		return nil, fmt.Errorf("云端驱动器不满足要求: %v", err)
+	}
+
+	// 本地驱动器只用于副本，不参与条带分布
+	rc.drivers[policy.LocalDriver] = localDriver
+	rc.hybrid = &policy
+	rc.localAccess = make(map[string]time.Time)
+	rc.localPinned = make(map[string]bool)
+	rc.localEvicted = make(map[string]bool)
+	for _, fileID := range policy.PinnedFiles {
+		rc.localPinned[fileID] = true
+	}
+
+	return rc, nil
+}
+
+// 固定或取消固定文件的本地副本，固定的副本不被淘汰
+func (rc *RAIDController) PinLocalCopies(fileID string, pinned bool) {
+	if rc.hybrid == nil {
+		return
+	}
+
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+	if pinned {
+		rc.localPinned[fileID] = true
+	} else {
+		delete(rc.localPinned, fileID)
+	}
+}
+
+// 写入条带的本地副本，失败不影响云端写入结果
+func (rc *RAIDController) writeLocalCopy(ctx context.Context, stripeIndex int, data []byte, fileID string) {
+	storageID := fmt.Sprintf("%s_s%d_local", fileID, stripeIndex)
+	if rc.hybrid.MaxLocalBytes > 0 {
+		// 腾不出空间（副本过大或其余副本都已固定）时不保留本地副本
+		ok, err := rc.evictLocal(ctx, int64(len(data)), false)
+		if err != nil {
+			fmt.Printf("警告: 淘汰本地副本失败: %v\n", err)
+		}
+		if !ok {
+			return
+		}
+	}
+
+	loc, err := rc.uploadStrip(ctx, rc.hybrid.LocalDriver, storageID, data)
+	if err != nil {
+		fmt.Printf("警告: 写入本地副本失败 %s: %v\n", storageID, err)
+		return
+	}
+
+	strip := newStripRecord(0, rc.hybrid.LocalDriver, storageID, data, false, loc)
+
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	stripes := rc.layouts[fileID]
+	for len(stripes) <= stripeIndex {
+		stripes = append(stripes, metadata.StripeMetadata{
+			StripeIndex: len(stripes),
+			Strips:      make([]metadata.StripMetadata, 0),
+		})
+	}
+	stripes[stripeIndex].LocalCopy = &strip
+	rc.layouts[fileID] = stripes
+	rc.localAccess[storageID] = time.Now()
+}
+
+// 优先从本地副本读取条带
+func (rc *RAIDController) readLocalCopy(ctx context.Context, stripeIndex int, fileID string) ([]byte, bool) {
+	rc.layoutMu.Lock()
+	var localCopy *metadata.StripMetadata
+	if stripes := rc.layouts[fileID]; stripeIndex < len(stripes) {
+		localCopy = stripes[stripeIndex].LocalCopy
+	}
+	rc.layoutMu.Unlock()
+
+	if localCopy == nil {
+		return nil, false
+	}
+
+	data, err := rc.downloadStrip(ctx, *localCopy)
+	if err != nil {
+		return nil, false
+	}
+
+	rc.layoutMu.Lock()
+	rc.localAccess[localCopy.StorageID] = time.Now()
+	rc.layoutMu.Unlock()
+
+	return data, true
+}
+
+// 本地空间不足或本地副本超过大小上限时按最近最少使用顺序淘汰本地副本，
+// 返回条带分布发生变化的文件ID（包括此前写入时淘汰的）
+func (rc *RAIDController) EvictLocalCopies(ctx context.Context) ([]string, error) {
+	if rc.hybrid == nil {
+		return nil, nil
+	}
+
+	_, err := rc.evictLocal(ctx, 0, true)
+
+	rc.layoutMu.Lock()
+	affected := make([]string, 0, len(rc.localEvicted))
+	for fileID := range rc.localEvicted {
+		affected = append(affected, fileID)
+	}
+	rc.localEvicted = make(map[string]bool)
+	rc.layoutMu.Unlock()
+
+	sort.Strings(affected)
+	return affected, err
+}
+
+// 按LRU淘汰未固定的本地副本，直到再写入incoming字节后不超过大小上限，
+// checkDisk时还要求本地可用空间不低于MinFreeRatio。返回是否满足要求
+func (rc *RAIDController) evictLocal(ctx context.Context, incoming int64, checkDisk bool) (bool, error) {
+	entries := rc.localCopiesByAccess()
+	var copyBytes int64
+	for _, entry := range entries {
+		copyBytes += entry.strip.StripSize
+	}
+
+	free, target := int64(0), int64(0)
+	if checkDisk {
+		used, total, err := rc.drivers[rc.hybrid.LocalDriver].GetUsage()
+		if err != nil {
+			return false, fmt.Errorf("获取本地空间失败: %v", err)
+		}
+		if total > 0 {
+			free = total - used
+			target = int64(float64(total) * rc.hybrid.MinFreeRatio)
+		}
+	}
+
+	limit := rc.hybrid.MaxLocalBytes
+	satisfied := func() bool {
+		return free >= target && (limit <= 0 || copyBytes+incoming <= limit)
+	}
+	for _, entry := range entries {
+		if satisfied() {
+			break
+		}
+		if entry.pinned {
+			continue
+		}
+
+		if err := rc.deleteStrip(ctx, entry.strip); err != nil {
+			return false, fmt.Errorf("淘汰本地副本%s失败: %v", entry.strip.StorageID, err)
+		}
+		rc.dropLocalCopy(entry.fileID, entry.stripeIndex)
+		free += entry.strip.StripSize
+		copyBytes -= entry.strip.StripSize
+	}
+
+	return satisfied(), nil
+}
+
+// 按最后访问时间升序列出所有本地副本
+func (rc *RAIDController) localCopiesByAccess() []localCopyEntry {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	var entries []localCopyEntry
+	for fileID, stripes := range rc.layouts {
+		for i, stripe := range stripes {
+			if stripe.LocalCopy == nil {
+				continue
+			}
+			lastAccess, ok := rc.localAccess[stripe.LocalCopy.StorageID]
+			if !ok {
+				lastAccess = stripe.LocalCopy.CreatedAt
+			}
+			entries = append(entries, localCopyEntry{
+				fileID:      fileID,
+				stripeIndex: i,
+				strip:       *stripe.LocalCopy,
+				lastAccess:  lastAccess,
+				pinned:      rc.localPinned[fileID],
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastAccess.Before(entries[j].lastAccess)
+	})
+
+	return entries
+}
+
+func (rc *RAIDController) dropLocalCopy(fileID string, stripeIndex int) {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	stripes := rc.layouts[fileID]
+	if stripeIndex < len(stripes) && stripes[stripeIndex].LocalCopy != nil {
+		delete(rc.localAccess, stripes[stripeIndex].LocalCopy.StorageID)
+		stripes[stripeIndex].LocalCopy = nil
+		rc.localEvicted[fileID] = true
+	}
+}
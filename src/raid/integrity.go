@@ -0,0 +1,42 @@
+package raid
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 读取的文件内容与上传时记录的哈希不符
+var ErrIntegrity = errors.New("文件完整性校验失败")
+
+// 整个文件内容的SHA-256（十六进制），上传时记录在FileMetadata.Hash中
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 校验文件内容与记录的哈希是否一致，hash为空（旧版本没有记录）时不校验
+func VerifyContent(data []byte, hash string) error {
+	if hash == "" {
+		return nil
+	}
+	if actual := ContentHash(data); actual != strings.ToLower(hash) {
+		return fmt.Errorf("%w: 记录的SHA-256为%s, 读取的内容为%s", ErrIntegrity, hash, actual)
+	}
+	return nil
+}
+
+// 读取文件并与上传时记录的整个文件的哈希比较，不符时返回ErrIntegrity
+func (rc *RAIDController) ReadFileVerified(ctx context.Context, fileID, hash string) ([]byte, error) {
+	data, err := rc.ReadFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyContent(data, hash); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
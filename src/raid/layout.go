@@ -0,0 +1,448 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+)
+
+// 记录条带块的实际存储位置
+func (rc *RAIDController) recordMetadata(fileID string, stripeIndex int, strip metadata.StripMetadata) {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	stripes := rc.layouts[fileID]
+	for len(stripes) <= stripeIndex {
+		stripes = append(stripes, metadata.StripeMetadata{
+			StripeIndex: len(stripes),
+			Strips:      make([]metadata.StripMetadata, 0),
+		})
+	}
+
+	stripe := &stripes[stripeIndex]
+	stripe.StripeWidth = rc.stripeWidth
+	if strip.IsParity {
+		stripe.ParityStrip = &strip
+	} else {
+		stripe.Strips = append(stripe.Strips, strip)
+	}
+	rc.layouts[fileID] = stripes
+}
+
+// 获取文件的条带分布，供调用方持久化到元数据
+func (rc *RAIDController) StripeLayout(fileID string) []metadata.StripeMetadata {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	stripes := rc.layouts[fileID]
+	result := make([]metadata.StripeMetadata, len(stripes))
+	copy(result, stripes)
+	return result
+}
+
+// 文件的条带分布，没有记录（未写入也未加载）时ok为false
+func (rc *RAIDController) loadedLayout(fileID string) (stripes []metadata.StripeMetadata, ok bool) {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	stripes, ok = rc.layouts[fileID]
+	return append([]metadata.StripeMetadata(nil), stripes...), ok
+}
+
+// 没有写入任何条带的文件（空文件）也记录条带分布
+func (rc *RAIDController) ensureLayout(fileID string) {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	if _, ok := rc.layouts[fileID]; !ok {
+		rc.layouts[fileID] = []metadata.StripeMetadata{}
+	}
+}
+
+// 将全零条带记录为空洞
+func (rc *RAIDController) recordHole(fileID string, stripeIndex int, size int64) {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	stripes := rc.layouts[fileID]
+	for len(stripes) <= stripeIndex {
+		stripes = append(stripes, metadata.StripeMetadata{
+			StripeIndex: len(stripes),
+			Strips:      make([]metadata.StripMetadata, 0),
+		})
+	}
+
+	stripes[stripeIndex].StripeWidth = rc.stripeWidth
+	stripes[stripeIndex].Hole = true
+	stripes[stripeIndex].HoleSize = size
+	rc.layouts[fileID] = stripes
+}
+
+// 启用或关闭稀疏文件支持
+func (rc *RAIDController) SetSparse(enabled bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.sparse = enabled
+}
+
+func isAllZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 从已保存的元数据加载文件的条带分布，读取前调用
+func (rc *RAIDController) LoadLayout(fileID string, stripes []metadata.StripeMetadata) {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	rc.layouts[fileID] = stripes
+}
+
+// 查找条带块的记录，没有记录时按约定的位置构造
+func (rc *RAIDController) stripRecord(fileID string, stripeIndex int, driverName, storageID string) metadata.StripMetadata {
+	rc.layoutMu.Lock()
+	defer rc.layoutMu.Unlock()
+
+	stripes := rc.layouts[fileID]
+	if stripeIndex < len(stripes) {
+		stripe := stripes[stripeIndex]
+		for _, strip := range stripe.Strips {
+			if strip.StorageID == storageID {
+				return strip
+			}
+		}
+		if stripe.ParityStrip != nil && stripe.ParityStrip.StorageID == storageID {
+			return *stripe.ParityStrip
+		}
+	}
+
+	// 没有记录时假定按驱动器当前的混淆方式上传
+	return metadata.StripMetadata{
+		DriverName:  driverName,
+		StorageID:   storageID,
+		Obfuscation: drivers.ObfuscationOf(rc.drivers[driverName]),
+	}
+}
+
+// 校验文件的条带分布是否完整覆盖了全部数据，提交文件前调用
+func (rc *RAIDController) VerifyLayout(fileID string, fileSize int64) error {
+	layout := rc.StripeLayout(fileID)
+
+	stripeCount := int((fileSize + rc.StripeSize - 1) / rc.StripeSize)
+	if len(layout) != stripeCount {
+		return fmt.Errorf("条带数不匹配: 期望%d, 实际%d", stripeCount, len(layout))
+	}
+
+	for i, stripe := range layout {
+		expected := rc.StripeSize
+		if remaining := fileSize - int64(i)*rc.StripeSize; remaining < expected {
+			expected = remaining
+		}
+
+		if size := stripeDataSize(rc.level, stripe); size != expected {
+			return fmt.Errorf("条带%d数据不完整: 期望%d字节, 实际%d字节", i, expected, size)
+		}
+	}
+
+	return nil
+}
+
+// 计算条带记录中可读取到的数据字节数
+func stripeDataSize(level RAIDLevel, stripe metadata.StripeMetadata) int64 {
+	if stripe.Hole {
+		return stripe.HoleSize
+	}
+
+	strips := make([]metadata.StripMetadata, len(stripe.Strips))
+	copy(strips, stripe.Strips)
+	sort.Slice(strips, func(i, j int) bool {
+		return strips[i].StripIndex < strips[j].StripIndex
+	})
+
+	var size int64
+	switch level {
+	case RAID1:
+		// 任一完整副本即可
+		for _, strip := range strips {
+			if strip.StripSize > size {
+				size = strip.StripSize
+			}
+		}
+	case RAID10:
+		for _, group := range groupByStripIndex(strips) {
+			size += group[0].StripSize
+		}
+	default:
+		for _, strip := range strips {
+			size += strip.StripSize
+		}
+	}
+	return size
+}
+
+// 按记录的条带分布读取条带，不依赖阵列当前的驱动器组成
+func (rc *RAIDController) readStripeFromLayout(ctx context.Context, stripe metadata.StripeMetadata) ([]byte, error) {
+	strips := make([]metadata.StripMetadata, len(stripe.Strips))
+	copy(strips, stripe.Strips)
+	sort.Slice(strips, func(i, j int) bool {
+		return strips[i].StripIndex < strips[j].StripIndex
+	})
+
+	switch rc.level {
+	case RAID1:
+		return rc.readAnyCopy(ctx, strips)
+	case RAID10:
+		var result []byte
+		for _, group := range groupByStripIndex(strips) {
+			data, err := rc.readAnyCopy(ctx, group)
+			if err != nil {
+				return nil, fmt.Errorf("镜像对%d读取失败: %v", group[0].StripIndex, err)
+			}
+			result = append(result, data...)
+		}
+		return result, nil
+	case RAID5:
+		return rc.readParityStripe(ctx, strips, stripe.ParityStrip)
+	default:
+		results, errs := rc.downloadStrips(ctx, strips)
+		var result []byte
+		for i, data := range results {
+			if errs[i] != nil {
+				return nil, fmt.Errorf("读取条带块%d失败: %v", strips[i].StripIndex, errs[i])
+			}
+			result = append(result, data...)
+		}
+		return result, nil
+	}
+}
+
+// 读取带奇偶校验的条带，单个数据块丢失时用校验块恢复。启用对冲读取时，数据块超过对冲等待时间仍未全部完成
+// 就同时读取校验块，校验块和其余数据块到齐后直接恢复最慢的数据块并取消它的读取。
+// 按planParityRead的选择跳过预计最慢的数据块时一开始就读取校验块，校验块或其他数据块失败（或超过对冲等待时间）时再读取跳过的块
+func (rc *RAIDController) readParityStripe(ctx context.Context, dataStrips []metadata.StripMetadata, parity *metadata.StripMetadata) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan downloadResult, len(dataStrips)+1)
+	download := func(index int, strip metadata.StripMetadata) {
+		go func() {
+			data, err := rc.downloadStrip(ctx, strip)
+			results <- downloadResult{index: index, data: data, err: err}
+		}()
+	}
+	skipped := rc.planParityRead(dataStrips, parity)
+	pending := 0
+	for i, strip := range dataStrips {
+		if i != skipped {
+			download(i, strip)
+			pending++
+		}
+	}
+
+	// 归档驱动器上的校验块需要先恢复，不用于对冲
+	var hedge hedgeTimer
+	defer hedge.stop()
+	if parity != nil && parity.DriverName != rc.archiveDriver() {
+		var delay time.Duration
+		for _, strip := range dataStrips {
+			delay = max(delay, rc.hedgeDelay(ctx, strip.DriverName))
+		}
+		hedge.start(delay)
+	}
+
+	const parityIndex = -1
+	data := make([][]byte, len(dataStrips))
+	ok := make([]bool, len(dataStrips))
+	readOK := 0
+	failed := -1
+	var parityData []byte
+	var parityErr error
+	parityStarted, parityDone := false, false
+	startParity := func() {
+		hedge.stop()
+		if parity == nil || parityStarted {
+			return
+		}
+		parityStarted = true
+		pending++
+		download(parityIndex, *parity)
+	}
+	readSkipped := func() {
+		if skipped < 0 {
+			return
+		}
+		hedge.stop()
+		pending++
+		download(skipped, dataStrips[skipped])
+		skipped = -1
+	}
+	if skipped >= 0 {
+		startParity()
+		hedge.start(rc.hedgeDelay(ctx, parity.DriverName))
+	}
+
+	// 数据块全部读到，或校验块和其余数据块都已读到时即可合并
+	complete := func() bool {
+		return readOK == len(dataStrips) || parityDone && parityErr == nil && readOK == len(dataStrips)-1
+	}
+	for pending > 0 && !complete() {
+		select {
+		case r := <-results:
+			pending--
+			switch {
+			case r.index == parityIndex:
+				parityData, parityErr, parityDone = r.data, r.err, true
+				if parityErr != nil {
+					readSkipped()
+				}
+			case r.err != nil:
+				if failed >= 0 || parity == nil {
+					return nil, errors.New("多个数据块丢失，无法恢复")
+				}
+				failed = r.index
+				startParity()
+				readSkipped()
+			default:
+				data[r.index], ok[r.index] = r.data, true
+				readOK++
+			}
+		case <-hedge.C():
+			startParity()
+			readSkipped()
+		}
+	}
+
+	if readOK < len(dataStrips) {
+		// 对冲读取时最慢的数据块还未完成，也按丢失处理
+		missing := failed
+		for i := range ok {
+			if missing < 0 && !ok[i] {
+				missing = i
+			}
+		}
+		if errors.Is(parityErr, drivers.ErrRestoreInProgress) {
+			return nil, fmt.Errorf("数据块%d丢失，需要从归档恢复校验块: %w",
+				dataStrips[missing].StripIndex, rc.requestRestore(ctx, *parity, parityErr))
+		}
+		if parityErr != nil {
+			return nil, fmt.Errorf("数据块和校验块同时丢失，无法恢复: %v", parityErr)
+		}
+
+		recovered := make([]byte, len(parityData))
+		copy(recovered, parityData)
+		for i, strip := range data {
+			if i != missing {
+				xorInto(recovered, strip)
+			}
+		}
+
+		size := dataStrips[missing].StripSize
+		if size > int64(len(recovered)) {
+			return nil, errors.New("校验块长度不足，无法恢复")
+		}
+		data[missing] = recovered[:size]
+	}
+
+	var result []byte
+	for _, strip := range data {
+		result = append(result, strip...)
+	}
+	return result, nil
+}
+
+// 依次尝试各个副本，任一成功即返回。启用对冲读取时，当前副本超过对冲等待时间仍未完成就同时读取下一个副本，
+// 使用先完成的结果并取消其余的读取
+func (rc *RAIDController) readAnyCopy(ctx context.Context, copies []metadata.StripMetadata) ([]byte, error) {
+	if len(copies) == 0 {
+		return nil, errors.New("没有可用的副本")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan downloadResult, len(copies))
+	var hedge hedgeTimer
+	defer hedge.stop()
+	next, pending := 0, 0
+	launch := func() {
+		strip := copies[next]
+		index := next
+		next++
+		pending++
+		go func() {
+			data, err := rc.downloadStrip(ctx, strip)
+			results <- downloadResult{index: index, data: data, err: err}
+		}()
+		hedge.stop()
+		if next < len(copies) {
+			hedge.start(rc.hedgeDelay(ctx, strip.DriverName))
+		}
+	}
+
+	launch()
+	var lastErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.data, nil
+			}
+			lastErr = r.err
+			if next < len(copies) && ctx.Err() == nil {
+				launch()
+			}
+		case <-hedge.C():
+			launch()
+		}
+	}
+	return nil, lastErr
+}
+
+// 并行下载多个条带块，结果与输入顺序一致
+func (rc *RAIDController) downloadStrips(ctx context.Context, strips []metadata.StripMetadata) ([][]byte, []error) {
+	results := make([][]byte, len(strips))
+	errs := make([]error, len(strips))
+
+	var wg sync.WaitGroup
+	for i, strip := range strips {
+		wg.Add(1)
+		go func(i int, strip metadata.StripMetadata) {
+			defer wg.Done()
+			results[i], errs[i] = rc.downloadStrip(ctx, strip)
+		}(i, strip)
+	}
+	wg.Wait()
+
+	return results, errs
+}
+
+// 将已排序的条带块按StripIndex分组（RAID10中同一镜像对的副本）
+func groupByStripIndex(strips []metadata.StripMetadata) [][]metadata.StripMetadata {
+	var groups [][]metadata.StripMetadata
+	for _, strip := range strips {
+		n := len(groups)
+		if n > 0 && groups[n-1][0].StripIndex == strip.StripIndex {
+			groups[n-1] = append(groups[n-1], strip)
+		} else {
+			groups = append(groups, []metadata.StripMetadata{strip})
+		}
+	}
+	return groups
+}
+
+// 将src按字节异或到dst
+func xorInto(dst, src []byte) {
+	for i := 0; i < len(src) && i < len(dst); i++ {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,175 @@
+package raid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+)
+
+func TestReadParityStripeRecoversSingleStrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int // 文件大小，条带大小为1000
+		stripe int // 删除块的条带
+		lost   int // 删除的数据块在条带中的位置，-1表示校验块
+		plan   fixedPlacement
+	}{
+		{"第一个数据块", 2500, 0, 0, nil},
+		{"中间的数据块", 2500, 1, 1, nil},
+		{"最后一个数据块", 2500, 0, 2, nil},
+		{"校验块", 2500, 1, -1, nil},
+		{"末尾条带的第一个数据块", 2500, 2, 0, nil},
+		{"末尾条带的最后一个数据块", 2500, 2, 2, nil},
+		{"不足一个数据块的末尾条带", 2002, 2, 0, nil},
+		{"按计划放置的条带", 1000, 0, 1, fixedPlacement{0: {"d3", "d0", "d1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, mem := newTestController(t, RAID5, 4, 1000)
+			data := testData(tt.size)
+			opts := WriteOptions{}
+			if tt.plan != nil {
+				opts.Placement = tt.plan
+			}
+			fileID, err := rc.WriteFile(context.Background(), data, opts)
+			if err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+
+			stripe := rc.StripeLayout(fileID)[tt.stripe]
+			lost := stripe.ParityStrip
+			if tt.lost >= 0 {
+				// 块的记录按上传完成的顺序追加，按序号排列后才是数据的顺序
+				strips := slices.Clone(stripe.Strips)
+				slices.SortFunc(strips, func(a, b metadata.StripMetadata) int { return a.StripIndex - b.StripIndex })
+				lost = &strips[tt.lost]
+			}
+			if err := mem[lost.DriverName].DeleteChunk(context.Background(), lost.StorageID); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := rc.ReadFile(context.Background(), fileID)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("恢复的内容与写入的不一致")
+			}
+		})
+	}
+}
+
+func TestReadParityStripeFailsWithTwoLostStrips(t *testing.T) {
+	rc, mem := newTestController(t, RAID5, 4, 1000)
+	fileID, err := rc.WriteFile(context.Background(), testData(1000), WriteOptions{})
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	stripe := rc.StripeLayout(fileID)[0]
+	for _, strip := range stripe.Strips[:2] {
+		if err := mem[strip.DriverName].DeleteChunk(context.Background(), strip.StorageID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := rc.ReadFile(context.Background(), fileID); err == nil {
+		t.Fatal("期望两个数据块丢失时读取失败")
+	}
+}
+
+func TestReadRAID5StripeByConventionRecoversDataStrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		stripe int
+		lost   int // 丢失的块所在驱动器的序号
+	}{
+		// 校验块按条带序号轮转：条带0在d0，条带1在d1
+		{"第一个数据块", 0, 1},
+		{"最后一个数据块", 0, 3},
+		{"末尾条带的数据块", 1, 0},
+		{"校验块", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, mem := newTestController(t, RAID5, 4, 1000)
+			data := testData(1800)
+			fileID, err := rc.WriteFile(context.Background(), data, WriteOptions{})
+			if err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			stripe := rc.StripeLayout(fileID)[tt.stripe]
+			lostDriver := rc.driverNames[tt.lost]
+			for _, strip := range append(stripe.Strips, *stripe.ParityStrip) {
+				if strip.DriverName == lostDriver {
+					if err := mem[lostDriver].DeleteChunk(context.Background(), strip.StorageID); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			// 元数据只记录了条带数，没有块的位置时按约定的位置读取
+			rc.LoadLayout(fileID, []metadata.StripeMetadata{{StripeIndex: 0}, {StripeIndex: 1}})
+
+			got, err := rc.ReadFile(context.Background(), fileID)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("恢复的内容与写入的不一致")
+			}
+		})
+	}
+}
+
+func TestEmptyFileRoundTrip(t *testing.T) {
+	for _, level := range []RAIDLevel{RAID0, RAID1, RAID5, RAID10} {
+		t.Run(fmt.Sprintf("RAID%d", level), func(t *testing.T) {
+			rc, mem := newTestController(t, level, 4, 1000)
+			fileID, err := rc.WriteFile(context.Background(), nil, WriteOptions{})
+			if err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if err := rc.VerifyLayout(fileID, 0); err != nil {
+				t.Fatalf("VerifyLayout: %v", err)
+			}
+
+			// 提交：条带分布随文件元数据保存，另一个进程加载后读取
+			encoded, err := json.Marshal(metadata.FileMetadata{FileID: fileID, Stripes: rc.StripeLayout(fileID)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fm metadata.FileMetadata
+			if err := json.Unmarshal(encoded, &fm); err != nil {
+				t.Fatal(err)
+			}
+			all := make(map[string]drivers.StorageDriver, len(mem))
+			for name, d := range mem {
+				all[name] = d
+			}
+			reader, err := NewRAIDController(level, all, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader.LoadLayout(fm.FileID, fm.Stripes)
+
+			got, err := reader.ReadFileVerified(context.Background(), fm.FileID, ContentHash(nil))
+			if err != nil {
+				t.Fatalf("ReadFileVerified: %v", err)
+			}
+			if got == nil || len(got) != 0 {
+				t.Fatalf("读取的内容为%v，期望空切片", got)
+			}
+		})
+	}
+}
+
+func TestReadFileRequiresLayout(t *testing.T) {
+	rc, _ := newTestController(t, RAID5, 4, 1000)
+	if _, err := rc.ReadFile(context.Background(), "unknown"); err == nil {
+		t.Fatal("没有条带分布时应返回错误而不是猜测条带数")
+	}
+}
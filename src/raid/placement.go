@@ -0,0 +1,91 @@
+package raid
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 文件的条带放置计划，由调度器规划。写入时每个条带的块上传到计划中的驱动器，
+// 写入前规划的空间、费用、亲和性和故障域因此与实际放置一致
+type Placement interface {
+	// 条带使用的驱动器，按块的顺序排列：RAID5的校验块驱动器在末尾，RAID10的镜像对相邻。
+	// 计划中没有该条带时返回nil
+	StripeDrivers(stripeIndex int) []string
+}
+
+// 重新放置已有文件的条带时选择驱动器，由调度器实现。设置后重新条带化和迁空驱动器与上传一样
+// 遵守空间规划、文件固定规则和故障域，未设置时按阵列的驱动器顺序放置
+type PlacementPlanner interface {
+	// 为按当前阵列重写的文件规划放置并预留空间，返回计划和释放预留的函数
+	PlanFile(fileID string, size int64) (Placement, func(), error)
+	// 为迁空驱动器时移出的条带块选择目标驱动器。peers为同一条带中保存冗余数据的驱动器，
+	// 目标不能与它们属于同一故障域；exclude中的驱动器不能使用
+	SelectReplacement(fileID string, size int64, peers, exclude []string) (string, error)
+}
+
+// 设置重新放置条带时的驱动器选择
+func (rc *RAIDController) SetPlacementPlanner(planner PlacementPlanner) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.planner = planner
+}
+
+// 确定条带写入的驱动器，placement为nil时按阵列的驱动器顺序放置。
+// 计划中的驱动器必须属于阵列、互不重复且数量满足RAID级别的要求
+func (rc *RAIDController) stripeDrivers(stripeIndex int, placement Placement) ([]string, error) {
+	if placement == nil {
+		return rc.conventionDrivers(stripeIndex), nil
+	}
+
+	names := placement.StripeDrivers(stripeIndex)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("放置计划中没有条带%d", stripeIndex)
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if rc.driverIndex(name) < 0 {
+			return nil, fmt.Errorf("放置计划中的驱动器不在阵列中: %s", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("放置计划在条带%d中重复使用驱动器: %s", stripeIndex, name)
+		}
+		seen[name] = true
+	}
+	// RAID0的小条带可能只放置一个块
+	if rc.level != RAID0 {
+		if err := validateDriverCount(rc.level, len(names)); err != nil {
+			return nil, fmt.Errorf("条带%d的放置计划无效: %v", stripeIndex, err)
+		}
+	}
+
+	names = append([]string(nil), names...)
+	if rc.level == RAID5 {
+		// 数据块按驱动器在阵列中的顺序排列，与从驱动器列举重建元数据时推断的顺序一致
+		data := names[:len(names)-1]
+		sort.Slice(data, func(i, j int) bool { return rc.driverIndex(data[i]) < rc.driverIndex(data[j]) })
+	}
+	return names, nil
+}
+
+// 没有放置计划时的驱动器顺序：RAID5的校验块按条带序号轮转，RAID10按名称顺序两两组成镜像对
+func (rc *RAIDController) conventionDrivers(stripeIndex int) []string {
+	switch rc.level {
+	case RAID5:
+		parity := rc.parityIndex(stripeIndex)
+		names := make([]string, 0, len(rc.driverNames))
+		for i, name := range rc.driverNames {
+			if i != parity {
+				names = append(names, name)
+			}
+		}
+		return append(names, rc.driverNames[parity])
+	case RAID10:
+		var names []string
+		for _, pair := range rc.createMirrorPairs() {
+			names = append(names, pair...)
+		}
+		return names
+	default:
+		return append([]string(nil), rc.driverNames...)
+	}
+}
@@ -0,0 +1,226 @@
+package raid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"panmatrix/drivers"
+)
+
+// 测试用的放置计划，键为条带序号
+type fixedPlacement map[int][]string
+
+func (p fixedPlacement) StripeDrivers(stripeIndex int) []string { return p[stripeIndex] }
+
+func newTestController(t *testing.T, level RAIDLevel, driverCount int, stripeSize int64) (*RAIDController, map[string]*drivers.MemoryDriver) {
+	t.Helper()
+	mem := make(map[string]*drivers.MemoryDriver, driverCount)
+	all := make(map[string]drivers.StorageDriver, driverCount)
+	for i := 0; i < driverCount; i++ {
+		d, err := drivers.NewMemoryDriver(drivers.MemoryConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("d%d", i)
+		mem[name], all[name] = d, d
+	}
+	rc, err := NewRAIDController(level, all, stripeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rc, mem
+}
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+func TestWriteFileFollowsPlacement(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     RAIDLevel
+		placement fixedPlacement
+	}{
+		{"RAID0", RAID0, fixedPlacement{0: {"d4", "d1"}, 1: {"d2", "d5", "d0"}, 2: {"d3"}}},
+		{"RAID1", RAID1, fixedPlacement{0: {"d5", "d0"}, 1: {"d1", "d2"}, 2: {"d3", "d4"}}},
+		{"RAID5", RAID5, fixedPlacement{0: {"d5", "d1", "d3"}, 1: {"d0", "d4", "d2", "d1"}, 2: {"d2", "d3", "d0"}}},
+		{"RAID10", RAID10, fixedPlacement{0: {"d0", "d5", "d1", "d4"}, 1: {"d2", "d3", "d4", "d5"}, 2: {"d1", "d0", "d3", "d2", "d5", "d4"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, mem := newTestController(t, tt.level, 6, 1000)
+			data := testData(2500)
+
+			fileID, err := rc.WriteFile(context.Background(), data, WriteOptions{Placement: tt.placement})
+			if err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if err := rc.VerifyLayout(fileID, int64(len(data))); err != nil {
+				t.Fatalf("VerifyLayout: %v", err)
+			}
+
+			for _, stripe := range rc.StripeLayout(fileID) {
+				planned := tt.placement[stripe.StripeIndex]
+				strips := stripe.Strips
+				if stripe.ParityStrip != nil {
+					strips = append(strips, *stripe.ParityStrip)
+					if want := planned[len(planned)-1]; stripe.ParityStrip.DriverName != want {
+						t.Errorf("条带%d的校验块在%s，计划为%s", stripe.StripeIndex, stripe.ParityStrip.DriverName, want)
+					}
+				}
+				var used []string
+				for _, strip := range strips {
+					used = append(used, strip.DriverName)
+					if _, err := mem[strip.DriverName].DownloadChunk(context.Background(), strip.StorageID); err != nil {
+						t.Errorf("条带%d的块%s不在%s上: %v", stripe.StripeIndex, strip.StorageID, strip.DriverName, err)
+					}
+				}
+				slices.Sort(used)
+				want := slices.Clone(planned)
+				slices.Sort(want)
+				if !slices.Equal(used, want) {
+					t.Errorf("条带%d写入了%v，计划为%v", stripe.StripeIndex, used, want)
+				}
+			}
+
+			got, err := rc.ReadFile(context.Background(), fileID)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("读取的内容与写入的不一致")
+			}
+		})
+	}
+}
+
+func TestWriteFileRejectsInvalidPlacement(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     RAIDLevel
+		placement fixedPlacement
+	}{
+		{"未知驱动器", RAID1, fixedPlacement{0: {"d0", "x"}}},
+		{"重复驱动器", RAID1, fixedPlacement{0: {"d0", "d0"}}},
+		{"RAID5驱动器不足", RAID5, fixedPlacement{0: {"d0", "d1"}}},
+		{"RAID10驱动器为奇数", RAID10, fixedPlacement{0: {"d0", "d1", "d2", "d3", "d4"}}},
+		{"计划中没有条带", RAID0, fixedPlacement{1: {"d0"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, mem := newTestController(t, tt.level, 6, 1000)
+			if _, err := rc.WriteFile(context.Background(), testData(800), WriteOptions{Placement: tt.placement}); err == nil {
+				t.Fatal("期望计划无效时写入失败")
+			}
+			for name, d := range mem {
+				if chunks, _ := d.ListChunks(context.Background(), ""); len(chunks) > 0 {
+					t.Errorf("计划无效时%s上仍写入了%d个块", name, len(chunks))
+				}
+			}
+		})
+	}
+}
+
+// 测试用的调度器，记录迁空时传入的参数
+type fakePlanner struct {
+	plan    fixedPlacement
+	target  string
+	peers   []string
+	exclude []string
+}
+
+func (p *fakePlanner) PlanFile(fileID string, size int64) (Placement, func(), error) {
+	return p.plan, func() {}, nil
+}
+
+func (p *fakePlanner) SelectReplacement(fileID string, size int64, peers, exclude []string) (string, error) {
+	p.peers, p.exclude = peers, exclude
+	return p.target, nil
+}
+
+func TestRestripeFileFollowsPlanner(t *testing.T) {
+	rc, mem := newTestController(t, RAID1, 3, 1000)
+	data := testData(800)
+	fileID, err := rc.WriteFile(context.Background(), data, WriteOptions{})
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	added, err := drivers.NewMemoryDriver(drivers.MemoryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem["d3"] = added
+	if err := rc.AddDrivers(map[string]drivers.StorageDriver{"d3": added}); err != nil {
+		t.Fatal(err)
+	}
+	rc.SetPlacementPlanner(&fakePlanner{plan: fixedPlacement{0: {"d1", "d3"}}})
+	if _, err := rc.RestripeFile(context.Background(), fileID); err != nil {
+		t.Fatalf("RestripeFile: %v", err)
+	}
+
+	var used []string
+	for _, strip := range rc.StripeLayout(fileID)[0].Strips {
+		used = append(used, strip.DriverName)
+	}
+	slices.Sort(used)
+	if want := []string{"d1", "d3"}; !slices.Equal(used, want) {
+		t.Fatalf("重新条带化后写入了%v，计划为%v", used, want)
+	}
+	got, err := rc.ReadFile(context.Background(), fileID)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("读取的内容与写入的不一致")
+	}
+}
+
+func TestEvacuateAsksPlannerForTarget(t *testing.T) {
+	rc, mem := newTestController(t, RAID5, 5, 1000)
+	data := testData(800)
+	fileID, err := rc.WriteFile(context.Background(), data, WriteOptions{Placement: fixedPlacement{0: {"d0", "d1", "d2", "d3"}}})
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	planner := &fakePlanner{target: "d4"}
+	rc.SetPlacementPlanner(planner)
+
+	if _, err := rc.Evacuate(context.Background(), "d0"); err != nil {
+		t.Fatalf("Evacuate: %v", err)
+	}
+	// 与条带的其他数据块和校验块分开
+	peers := slices.Clone(planner.peers)
+	slices.Sort(peers)
+	if want := []string{"d1", "d2", "d3"}; !slices.Equal(peers, want) {
+		t.Errorf("故障域要分开的驱动器为%v，期望%v", peers, want)
+	}
+	exclude := slices.Clone(planner.exclude)
+	slices.Sort(exclude)
+	if want := []string{"d0", "d1", "d2", "d3"}; !slices.Equal(exclude, want) {
+		t.Errorf("排除的驱动器为%v，期望%v", exclude, want)
+	}
+
+	stripe := rc.StripeLayout(fileID)[0]
+	for _, strip := range append(stripe.Strips, *stripe.ParityStrip) {
+		if strip.DriverName == "d0" {
+			t.Fatal("迁空后仍有块在d0上")
+		}
+		if _, err := mem[strip.DriverName].DownloadChunk(context.Background(), strip.StorageID); err != nil {
+			t.Errorf("块%s不在%s上: %v", strip.StorageID, strip.DriverName, err)
+		}
+	}
+	got, err := rc.ReadFile(context.Background(), fileID)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("读取的内容与写入的不一致")
+	}
+}
@@ -0,0 +1,48 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+)
+
+// 读取文件中从offset开始的length字节，只下载涉及的条带，超出文件末尾的部分不返回。
+// 需要文件的条带分布（LoadLayout）来确定各条带的位置。
+// 只读取了部分内容，无法与整个文件的哈希比较，需要校验时使用ReadFileVerified
+func (rc *RAIDController) ReadRange(ctx context.Context, fileID string, offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("无效的读取范围: offset=%d length=%d", offset, length)
+	}
+
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	layout := rc.StripeLayout(fileID)
+	if len(layout) == 0 {
+		return nil, fmt.Errorf("没有文件%s的条带分布", fileID)
+	}
+
+	end := offset + length
+	var result []byte
+	var start int64 // 当前条带在文件中的起始位置
+	for stripeIndex, stripe := range layout {
+		if start >= end {
+			break
+		}
+		size := stripeDataSize(rc.level, stripe)
+		if start+size <= offset {
+			start += size
+			continue
+		}
+
+		data, err := rc.readStripe(ctx, fileID, stripeIndex, layout)
+		if err != nil {
+			return nil, fmt.Errorf("读取条带%d失败: %w", stripeIndex, err)
+		}
+		lo, hi := max(offset-start, 0), min(end-start, int64(len(data)))
+		if lo < hi {
+			result = append(result, data[lo:hi]...)
+		}
+		start += size
+	}
+	return result, nil
+}
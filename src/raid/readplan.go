@@ -0,0 +1,51 @@
+package raid
+
+import (
+	"time"
+
+	"panmatrix/metadata"
+)
+
+// 重建读取比直接读取预计至少快这么多倍时才改为读取校验块，
+// 避免按估计误差来回切换，也抵消异或恢复的开销
+const reconstructAdvantage = 1.25
+
+// 读取耗时的估计，由调度器实现（可选，TransferScheduler同时实现该接口时生效），用于选择RAID5条带的读取来源。
+// 无法估计时ok为false
+type ReadEstimator interface {
+	EstimateRead(driverName string, bytes int64) (estimate time.Duration, ok bool)
+}
+
+// 选择RAID5条带的读取来源。数据块都可读时默认只读数据块，不下载校验块；
+// 若用校验块代替最慢的数据块（与其余数据块异或恢复）预计明显更快，返回该数据块的位置，读取时跳过它。
+// 返回-1表示直接读取全部数据块
+func (rc *RAIDController) planParityRead(dataStrips []metadata.StripMetadata, parity *metadata.StripMetadata) int {
+	estimator, ok := rc.transfers.(ReadEstimator)
+	if !ok || parity == nil || parity.DriverName == rc.archiveDriver() || len(dataStrips) < 2 {
+		return -1
+	}
+	parityTime, ok := estimator.EstimateRead(parity.DriverName, parity.StripSize)
+	if !ok {
+		return -1
+	}
+
+	// 直接读取的耗时取决于最慢的数据块，重建读取取决于次慢的数据块和校验块中较慢的一个
+	slowest := -1
+	var slowestTime, secondTime time.Duration
+	for i, strip := range dataStrips {
+		t, ok := estimator.EstimateRead(strip.DriverName, strip.StripSize)
+		if !ok {
+			return -1
+		}
+		if slowest < 0 || t > slowestTime {
+			slowest, slowestTime, secondTime = i, t, slowestTime
+		} else if t > secondTime {
+			secondTime = t
+		}
+	}
+	reconstructTime := max(secondTime, parityTime)
+	if float64(reconstructTime)*reconstructAdvantage >= float64(slowestTime) {
+		return -1
+	}
+	return slowest
+}
@@ -0,0 +1,53 @@
+package raid
+
+import (
+	"fmt"
+
+	"panmatrix/metadata"
+)
+
+// 条带块位置的持久化接口，由元数据管理器实现。每个条带的所有块写入成功后作为一个整体记录，
+// 已记录的条带不会缺块；上传中断时可以据此找到已写入的条带块
+type StripRecorder interface {
+	RecordStripe(fileID string, stripe metadata.StripeMetadata) error
+	// 上传被放弃、已写入的条带块已删除后调用，删除未提交的记录
+	DiscardPending(fileID string) error
+}
+
+// 设置条带位置的持久化，设置后写入过程中每个条带完成时立即记录
+func (rc *RAIDController) SetStripRecorder(recorder StripRecorder) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.stripRecorder = recorder
+}
+
+// 持久化已写入完成的条带，失败时上传应中止
+func (rc *RAIDController) persistStripe(fileID string, stripeIndex int) error {
+	if rc.stripRecorder == nil {
+		return nil
+	}
+
+	rc.layoutMu.Lock()
+	stripes := rc.layouts[fileID]
+	if stripeIndex >= len(stripes) {
+		rc.layoutMu.Unlock()
+		return fmt.Errorf("条带%d没有记录任何块", stripeIndex)
+	}
+	stripe := stripes[stripeIndex]
+	stripe.Strips = append([]metadata.StripMetadata(nil), stripe.Strips...)
+	rc.layoutMu.Unlock()
+
+	if err := rc.stripRecorder.RecordStripe(fileID, stripe); err != nil {
+		return fmt.Errorf("记录条带%d的位置失败: %v", stripeIndex, err)
+	}
+	return nil
+}
+
+func (rc *RAIDController) discardPending(fileID string) {
+	if rc.stripRecorder == nil {
+		return
+	}
+	if err := rc.stripRecorder.DiscardPending(fileID); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+}
@@ -0,0 +1,24 @@
+package raid
+
+import (
+	"panmatrix/metadata"
+)
+
+// 块的引用计数，由元数据管理器实现。去重或快照使多个文件引用同一个条带块时，
+// 删除、迁移或重新条带化一个文件只删除不再被其他文件引用的块
+type ChunkRefCounter interface {
+	// 除fileID之外引用该块的文件数
+	OtherChunkRefs(fileID string, strip metadata.StripMetadata) int
+}
+
+// 设置块的引用计数，未设置时每个块只属于一个文件
+func (rc *RAIDController) SetChunkRefCounter(refs ChunkRefCounter) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.chunkRefs = refs
+}
+
+// 块是否仍被其他文件引用，引用的块不能删除
+func (rc *RAIDController) sharedStrip(fileID string, strip metadata.StripMetadata) bool {
+	return rc.chunkRefs != nil && rc.chunkRefs.OtherChunkRefs(fileID, strip) > 0
+}
@@ -0,0 +1,196 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+)
+
+// 上传进度持久化接口，由元数据管理器实现
+type UploadProgressStore interface {
+	SaveUploadProgress(p *metadata.UploadProgress) error
+	GetUploadProgress(contentHash string) (*metadata.UploadProgress, error)
+	DeleteUploadProgress(contentHash string) error
+}
+
+// 设置上传进度存储，设置后中断的上传可以从最后提交的条带继续
+func (rc *RAIDController) SetProgressStore(store UploadProgressStore) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.progressStore = store
+}
+
+// 查找同一文件未完成的上传，阵列布局变化后的进度不可续传
+func (rc *RAIDController) findResumableUpload(contentHash string, fileSize int64) *metadata.UploadProgress {
+	if rc.progressStore == nil {
+		return nil
+	}
+
+	p, err := rc.progressStore.GetUploadProgress(contentHash)
+	if err != nil {
+		return nil
+	}
+
+	if p.FileSize != fileSize || p.StripeSize != rc.StripeSize ||
+		p.RAIDLevel != int(rc.level) || p.StripeWidth != rc.stripeWidth ||
+		p.CompletedStripes > len(p.Stripes) {
+		fmt.Printf("警告: 上传进度与当前阵列不匹配，重新上传: %s\n", p.FileID)
+		rc.abortSessions(p)
+		rc.clearProgress(contentHash)
+		return nil
+	}
+
+	return p
+}
+
+// 开始写入时创建上传进度，上传从此出现在进行中的上传列表里
+func (rc *RAIDController) beginUpload(p *metadata.UploadProgress) {
+	if rc.progressStore == nil {
+		return
+	}
+
+	p.StartedAt = time.Now()
+	if err := rc.progressStore.SaveUploadProgress(p); err != nil {
+		fmt.Printf("警告: 保存上传进度失败: %v\n", err)
+	}
+}
+
+// 条带提交后持久化进度
+func (rc *RAIDController) commitStripe(p *metadata.UploadProgress, stripeIndex int) {
+	if rc.progressStore == nil {
+		return
+	}
+
+	layout := rc.StripeLayout(p.FileID)
+	if len(layout) > stripeIndex+1 {
+		layout = layout[:stripeIndex+1]
+	}
+	rc.progressMu.Lock()
+	defer rc.progressMu.Unlock()
+	p.Stripes = layout
+	p.CompletedStripes = stripeIndex + 1
+	p.BytesCommitted = int64(p.CompletedStripes) * p.StripeSize
+	if p.BytesCommitted > p.FileSize {
+		p.BytesCommitted = p.FileSize
+	}
+
+	if err := rc.progressStore.SaveUploadProgress(p); err != nil {
+		fmt.Printf("警告: 保存上传进度失败: %v\n", err)
+	}
+}
+
+func (rc *RAIDController) clearProgress(contentHash string) {
+	if rc.progressStore == nil {
+		return
+	}
+	if err := rc.progressStore.DeleteUploadProgress(contentHash); err != nil {
+		fmt.Printf("警告: %v\n", err)
+	}
+}
+
+type uploadProgressKey struct{}
+
+// 写入中的文件的上传进度随ctx传给条带块的上传，分片会话随进度一起保存
+func withUploadProgress(ctx context.Context, p *metadata.UploadProgress) context.Context {
+	return context.WithValue(ctx, uploadProgressKey{}, p)
+}
+
+func uploadProgressFrom(ctx context.Context) *metadata.UploadProgress {
+	p, _ := ctx.Value(uploadProgressKey{}).(*metadata.UploadProgress)
+	return p
+}
+
+// 上传失败后是否可以续传，可以续传时保留进行中的分片会话
+func (rc *RAIDController) resumable(ctx context.Context) bool {
+	return rc.progressStore != nil && uploadProgressFrom(ctx) != nil && ctx.Err() == nil
+}
+
+func sessionKey(driverName, storageID string) string {
+	return driverName + "/" + storageID
+}
+
+// 续传时之前保存的分片会话，大小不符时不使用
+func (rc *RAIDController) savedSession(ctx context.Context, driverName, storageID string, size int64) *drivers.UploadSession {
+	p := uploadProgressFrom(ctx)
+	if p == nil {
+		return nil
+	}
+
+	rc.progressMu.Lock()
+	defer rc.progressMu.Unlock()
+	saved := p.Sessions[sessionKey(driverName, storageID)]
+	if saved == nil || saved.Size != size || saved.PartSize <= 0 {
+		return nil
+	}
+	return saved.Snapshot()
+}
+
+// 每确认一个分片保存一次会话，在UploadWithSession的onPart中调用
+func (rc *RAIDController) saveSession(ctx context.Context, driverName string, session *drivers.UploadSession) {
+	p := uploadProgressFrom(ctx)
+	if p == nil || rc.progressStore == nil {
+		return
+	}
+
+	snapshot := session.Snapshot()
+	rc.progressMu.Lock()
+	defer rc.progressMu.Unlock()
+	p.Sessions = replaceSession(p.Sessions, sessionKey(driverName, session.StorageID), snapshot)
+	if err := rc.progressStore.SaveUploadProgress(p); err != nil {
+		fmt.Printf("警告: 保存上传进度失败: %v\n", err)
+	}
+}
+
+// 条带块上传完成或放弃会话后不再保存，条带提交时随进度一起写入
+func (rc *RAIDController) forgetSession(ctx context.Context, driverName, storageID string) {
+	p := uploadProgressFrom(ctx)
+	if p == nil {
+		return
+	}
+
+	rc.progressMu.Lock()
+	defer rc.progressMu.Unlock()
+	p.Sessions = replaceSession(p.Sessions, sessionKey(driverName, storageID), nil)
+}
+
+// 放弃上传时释放保存的分片会话
+func (rc *RAIDController) abortSessions(p *metadata.UploadProgress) {
+	rc.progressMu.Lock()
+	sessions := p.Sessions
+	p.Sessions = nil
+	rc.progressMu.Unlock()
+
+	for key, session := range sessions {
+		driverName, _, _ := strings.Cut(key, "/")
+		aborter, ok := rc.drivers[driverName].(drivers.SessionAborter)
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		if err := aborter.AbortSession(ctx, session); err != nil {
+			fmt.Printf("警告: 放弃分片上传会话失败 %s: %v\n", key, err)
+		}
+		cancel()
+	}
+}
+
+// 已保存的进度可能仍在被读取，更新会话时复制整个map而不是原地修改
+func replaceSession(sessions map[string]*drivers.UploadSession, key string, session *drivers.UploadSession) map[string]*drivers.UploadSession {
+	updated := make(map[string]*drivers.UploadSession, len(sessions)+1)
+	for k, v := range sessions {
+		if k != key {
+			updated[k] = v
+		}
+	}
+	if session != nil {
+		updated[key] = session
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+	return updated
+}
@@ -0,0 +1,117 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"panmatrix/drivers"
+	"panmatrix/scheduler"
+)
+
+// 按调度器的放置计划写入文件，返回写入了条带块的驱动器（已排序）
+func writeScheduled(t *testing.T, level RAIDLevel, cfgs []drivers.MemoryConfig, configure func(rs *scheduler.RAIDScheduler)) []string {
+	t.Helper()
+	all := make(map[string]drivers.StorageDriver, len(cfgs))
+	for i, cfg := range cfgs {
+		d, err := drivers.NewMemoryDriver(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all[fmt.Sprintf("d%d", i)] = d
+	}
+	rc, err := NewRAIDController(level, all, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := scheduler.NewRAIDScheduler(all)
+	rs.SetStripeSize(1000)
+	if configure != nil {
+		configure(rs)
+	}
+
+	data := testData(3500)
+	plan, err := rs.ReserveFile("/a.bin", int64(len(data)), int(level))
+	if err != nil {
+		t.Fatalf("ReserveFile: %v", err)
+	}
+	defer rs.ReleaseReservation(plan)
+	fileID, err := rc.WriteFile(context.Background(), data, WriteOptions{Placement: plan})
+	if err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var used []string
+	for _, stripe := range rc.StripeLayout(fileID) {
+		strips := stripe.Strips
+		if stripe.ParityStrip != nil {
+			strips = append(strips, *stripe.ParityStrip)
+		}
+		for _, strip := range strips {
+			if !slices.Contains(used, strip.DriverName) {
+				used = append(used, strip.DriverName)
+			}
+		}
+	}
+	slices.Sort(used)
+	return used
+}
+
+func TestScheduledWriteSkipsFullDrivers(t *testing.T) {
+	// d0的容量放下条带块后剩余不到默认的64MB安全余量
+	cfgs := []drivers.MemoryConfig{{CapacityBytes: 32 << 20}, {}, {}, {}}
+	for _, level := range []RAIDLevel{RAID1, RAID5} {
+		used := writeScheduled(t, level, cfgs, nil)
+		if slices.Contains(used, "d0") {
+			t.Errorf("RAID%d写入了剩余空间不足的d0: %v", level, used)
+		}
+	}
+}
+
+func TestScheduledWriteFollowsCostMode(t *testing.T) {
+	cfgs := []drivers.MemoryConfig{{}, {}, {}, {}}
+	used := writeScheduled(t, RAID1, cfgs, func(rs *scheduler.RAIDScheduler) {
+		costs := map[string]scheduler.DriverCost{
+			"d0": {StoragePerGB: 0.05}, "d1": {StoragePerGB: 0.03}, "d2": {StoragePerGB: 0.002}, "d3": {StoragePerGB: 0.004},
+		}
+		if err := rs.SetCostModel(scheduler.SchedulerConfig{Mode: scheduler.ModeCost, Costs: costs}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := []string{"d2", "d3"}; !slices.Equal(used, want) {
+		t.Errorf("写入了%v，期望最便宜的%v", used, want)
+	}
+}
+
+func TestScheduledWriteFollowsStrategy(t *testing.T) {
+	cfgs := []drivers.MemoryConfig{{}, {}, {}, {}}
+	used := writeScheduled(t, RAID1, cfgs, func(rs *scheduler.RAIDScheduler) {
+		// 性能优先模式下费用只通过cost策略的评分影响选择
+		costs := map[string]scheduler.DriverCost{"d0": {StoragePerGB: 0.05}, "d1": {StoragePerGB: 0.05}}
+		if err := rs.SetCostModel(scheduler.SchedulerConfig{Costs: costs}); err != nil {
+			t.Fatal(err)
+		}
+		if err := rs.SetStrategy(scheduler.StrategyCost, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := []string{"d2", "d3"}; !slices.Equal(used, want) {
+		t.Errorf("写入了%v，期望cost策略评分最高的%v", used, want)
+	}
+}
+
+func TestScheduledWriteAvoidsClosedWindows(t *testing.T) {
+	cfgs := []drivers.MemoryConfig{{}, {}, {}, {}}
+	for _, level := range []RAIDLevel{RAID1, RAID5} {
+		used := writeScheduled(t, level, cfgs, func(rs *scheduler.RAIDScheduler) {
+			windows := map[string]scheduler.WindowPolicy{"d0": {Deny: []string{"00:00-24:00"}, Operations: scheduler.WindowOpsUpload}}
+			if err := rs.SetTimeWindows(windows); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if slices.Contains(used, "d0") {
+			t.Errorf("RAID%d写入了不在上传时间段内的d0: %v", level, used)
+		}
+	}
+}
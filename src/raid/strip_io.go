@@ -0,0 +1,259 @@
+package raid
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"panmatrix/drivers"
+	"panmatrix/metadata"
+)
+
+// 条带块上传后的远程位置
+type stripLocation struct {
+	remoteID    string // 驱动器返回的ID，与storageID相同时为空
+	parts       []metadata.StripPart
+	obfuscation string // 上传时使用的混淆方式，未混淆时为空
+}
+
+// 上传一个条带块，超过驱动器单文件上限时拆分为多个子块
+func (rc *RAIDController) uploadStrip(ctx context.Context, driverName, storageID string, data []byte) (_ stripLocation, err error) {
+	driver, ok := rc.drivers[driverName]
+	if !ok {
+		return stripLocation{}, fmt.Errorf("驱动器不存在: %s", driverName)
+	}
+	if err := ctx.Err(); err != nil {
+		return stripLocation{}, err
+	}
+	release, err := rc.acquireTransfer(ctx, driverName, true)
+	if err != nil {
+		return stripLocation{}, err
+	}
+	defer release()
+	if err := rc.meterTransfer(ctx, driverName, true, int64(len(data))); err != nil {
+		return stripLocation{}, err
+	}
+	start := time.Now()
+	defer func() { rc.recordOperation(ctx, driverName, start, err) }()
+
+	obfuscation := drivers.ObfuscationOf(driver)
+	limit := drivers.CapabilitiesOf(driver).MaxChunkSize
+	if limit <= 0 || int64(len(data)) <= limit {
+		remoteID, err := rc.uploadObject(ctx, driverName, driver, data, storageID)
+		if err != nil {
+			return stripLocation{}, err
+		}
+		return stripLocation{remoteID: remoteID, obfuscation: obfuscation}, nil
+	}
+
+	partCount := int((int64(len(data)) + limit - 1) / limit)
+	parts := make([]metadata.StripPart, 0, partCount)
+	for i := 0; i < partCount; i++ {
+		start := int64(i) * limit
+		end := start + limit
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+
+		partID := partStorageID(storageID, i)
+		var remoteID string
+		err := ctx.Err()
+		if err == nil {
+			remoteID, err = rc.uploadObject(ctx, driverName, driver, data[start:end], partID)
+		}
+		if err != nil {
+			// 已上传的子块没有记录到任何条带中，需要立即清理
+			rc.cleanupParts(driverName, parts)
+			return stripLocation{}, fmt.Errorf("上传子块%d失败: %v", i, err)
+		}
+		parts = append(parts, metadata.StripPart{
+			PartIndex: i,
+			StorageID: partID,
+			RemoteID:  remoteID,
+			Size:      end - start,
+		})
+	}
+
+	return stripLocation{parts: parts, obfuscation: obfuscation}, nil
+}
+
+// 超过该大小且驱动器支持分片会话时使用会话上传，连接中断只需重传当前分片
+const sessionUploadThreshold = 8 * 1024 * 1024
+
+// 上传单个远程对象，返回驱动器分配的远程ID（与storageID相同时返回空）
+func (rc *RAIDController) uploadObject(ctx context.Context, driverName string, driver drivers.StorageDriver, data []byte, storageID string) (string, error) {
+	var remoteID string
+	var err error
+
+	uploader, ok := driver.(drivers.SessionUploader)
+	useSession := ok && len(data) > sessionUploadThreshold && drivers.CapabilitiesOf(driver).Multipart
+	if useSession {
+		remoteID, err = rc.uploadWithSession(ctx, driverName, driver, uploader, data, storageID)
+	}
+	if !useSession || errors.Is(err, drivers.ErrSessionUnsupported) {
+		remoteID, err = driver.UploadChunk(ctx, data, storageID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if remoteID == storageID {
+		return "", nil
+	}
+	return remoteID, nil
+}
+
+// 按分片会话上传。会话随上传进度保存，上传失败但可以续传时保留会话，
+// 重新上传同一文件时从已确认的分片继续；保存的会话在驱动器侧已失效时重新开始
+func (rc *RAIDController) uploadWithSession(ctx context.Context, driverName string, driver drivers.StorageDriver, uploader drivers.SessionUploader, data []byte, storageID string) (string, error) {
+	session := rc.savedSession(ctx, driverName, storageID, int64(len(data)))
+	resumed := session != nil
+	if !resumed {
+		var err error
+		if session, err = uploader.StartUploadSession(ctx, storageID, int64(len(data))); err != nil {
+			return "", err
+		}
+	}
+
+	remoteID, err := drivers.UploadWithSession(ctx, uploader, session, data, func(s *drivers.UploadSession) {
+		rc.saveSession(ctx, driverName, s)
+	})
+	if err == nil {
+		rc.forgetSession(ctx, driverName, storageID)
+		return remoteID, nil
+	}
+	if resumed && ctx.Err() == nil {
+		rc.forgetSession(ctx, driverName, storageID)
+		return rc.uploadWithSession(ctx, driverName, driver, uploader, data, storageID)
+	}
+	if rc.resumable(ctx) {
+		return "", err
+	}
+
+	rc.forgetSession(ctx, driverName, storageID)
+	if aborter, ok := driver.(drivers.SessionAborter); ok {
+		abortCtx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		aborter.AbortSession(abortCtx, session)
+		cancel()
+	}
+	return "", err
+}
+
+// 下载一个条带块，存在子块时按顺序拼接。按元数据中记录的混淆方式还原，
+// 驱动器的混淆配置修改后仍能读取之前上传的数据块
+func (rc *RAIDController) downloadStrip(ctx context.Context, strip metadata.StripMetadata) (_ []byte, err error) {
+	driver, ok := rc.drivers[strip.DriverName]
+	if !ok {
+		return nil, fmt.Errorf("驱动器不存在: %s", strip.DriverName)
+	}
+	ctx = drivers.WithObfuscation(ctx, strip.Obfuscation)
+	release, err := rc.acquireTransfer(ctx, strip.DriverName, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := rc.meterTransfer(ctx, strip.DriverName, false, strip.StripSize); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	defer func() { rc.recordOperation(ctx, strip.DriverName, start, err) }()
+
+	// 加密的数据块绑定了上传时的存储ID，按远程ID下载时需要另外提供
+	if len(strip.Parts) == 0 {
+		return driver.DownloadChunk(drivers.WithStorageID(ctx, strip.StorageID), strip.RemoteKey())
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(int(strip.StripSize))
+	for _, part := range strip.Parts {
+		data, err := driver.DownloadChunk(drivers.WithStorageID(ctx, part.StorageID), part.RemoteKey())
+		if err != nil {
+			return nil, fmt.Errorf("下载子块%d失败: %v", part.PartIndex, err)
+		}
+		if int64(len(data)) != part.Size {
+			return nil, fmt.Errorf("子块%d大小不匹配: 期望%d, 实际%d", part.PartIndex, part.Size, len(data))
+		}
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// 删除一个条带块及其所有子块
+func (rc *RAIDController) deleteStrip(ctx context.Context, strip metadata.StripMetadata) error {
+	driver, ok := rc.drivers[strip.DriverName]
+	if !ok {
+		return fmt.Errorf("驱动器不存在: %s", strip.DriverName)
+	}
+
+	if len(strip.Parts) == 0 {
+		return drivers.DeleteChunk(ctx, driver, strip.RemoteKey())
+	}
+
+	// 已不存在的子块跳过，其余子块继续删除
+	for _, part := range strip.Parts {
+		err := drivers.DeleteChunk(ctx, driver, part.RemoteKey())
+		if err != nil && !errors.Is(err, drivers.ErrChunkNotFound) {
+			return fmt.Errorf("删除子块%d失败: %w", part.PartIndex, err)
+		}
+	}
+	return nil
+}
+
+// 清理未记录的子块
+func (rc *RAIDController) cleanupParts(driverName string, parts []metadata.StripPart) {
+	if len(parts) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	rc.deleteStrip(ctx, metadata.StripMetadata{DriverName: driverName, Parts: parts})
+}
+
+// 删除上传失败或被取消的文件已写入的所有条带块
+func (rc *RAIDController) discardLayout(layoutKey string) {
+	rc.layoutMu.Lock()
+	stripes := rc.layouts[layoutKey]
+	delete(rc.layouts, layoutKey)
+	rc.layoutMu.Unlock()
+
+	// 原上下文可能已被取消，清理使用独立的超时上下文
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	for _, strip := range layoutStrips(stripes) {
+		if err := rc.deleteStrip(ctx, strip); err != nil {
+			fmt.Printf("警告: 清理条带块失败 %s: %v\n", strip.StorageID, err)
+		}
+	}
+}
+
+// 构建条带块的元数据记录，校验和为块数据（混淆前）的SHA-256
+func newStripRecord(stripIndex int, driverName, storageID string, data []byte, isParity bool, loc stripLocation) metadata.StripMetadata {
+	sum := sha256.Sum256(data)
+	return metadata.StripMetadata{
+		StripIndex:  stripIndex,
+		DriverName:  driverName,
+		StorageID:   storageID,
+		RemoteID:    loc.remoteID,
+		StripSize:   int64(len(data)),
+		IsParity:    isParity,
+		Checksum:    hex.EncodeToString(sum[:]),
+		CreatedAt:   time.Now(),
+		Parts:       loc.parts,
+		Obfuscation: loc.obfuscation,
+	}
+}
+
+// 清理孤儿数据的最长等待时间
+const cleanupTimeout = 2 * time.Minute
+
+func partStorageID(storageID string, partIndex int) string {
+	return fmt.Sprintf("%s_p%d", storageID, partIndex)
+}
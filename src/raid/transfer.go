@@ -0,0 +1,60 @@
+package raid
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"panmatrix/drivers"
+)
+
+// 驱动器传输的调度，由调度器实现。每次上传或下载一个条带块前取得该驱动器的传输槽位，
+// 按ctx中标记的优先级排队，用户的下载优先于后台的重建和巡检；驱动器不在可用时间段内时等待
+type TransferScheduler interface {
+	AcquireTransfer(ctx context.Context, driverName string, upload bool) (release func(), err error)
+}
+
+// 传输结果的统计，由调度器实现（可选，TransferScheduler同时实现该接口时生效），
+// 每个条带块上传或下载完成后记录成功与否和耗时
+type OperationRecorder interface {
+	RecordOperation(driverName string, success bool, latency time.Duration)
+}
+
+// 按数据量的传输限制，由调度器实现（可选，TransferScheduler同时实现该接口时生效）。
+// 取得传输槽位后、开始传输前调用，bytes为条带块的大小，等待期间ctx取消时返回ctx的错误
+type TransferMeter interface {
+	MeterTransfer(ctx context.Context, driverName string, upload bool, bytes int64) error
+}
+
+// 设置传输调度，未设置时所有传输立即进行
+func (rc *RAIDController) SetTransferScheduler(ts TransferScheduler) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.transfers = ts
+}
+
+// 取得驱动器的传输槽位，传输完成后调用返回的release
+func (rc *RAIDController) acquireTransfer(ctx context.Context, driverName string, upload bool) (func(), error) {
+	if rc.transfers == nil {
+		return func() {}, nil
+	}
+	return rc.transfers.AcquireTransfer(ctx, driverName, upload)
+}
+
+// 按条带块的大小等待传输限制，大小未知时不等待
+func (rc *RAIDController) meterTransfer(ctx context.Context, driverName string, upload bool, bytes int64) error {
+	meter, ok := rc.transfers.(TransferMeter)
+	if !ok || bytes <= 0 {
+		return nil
+	}
+	return meter.MeterTransfer(ctx, driverName, upload, bytes)
+}
+
+// 记录一次条带块传输的结果。操作被取消和数据块不存在（或在归档中）不是驱动器的故障，不计入统计
+func (rc *RAIDController) recordOperation(ctx context.Context, driverName string, start time.Time, err error) {
+	recorder, ok := rc.transfers.(OperationRecorder)
+	if !ok || ctx.Err() != nil || errors.Is(err, drivers.ErrChunkNotFound) || errors.Is(err, drivers.ErrRestoreInProgress) {
+		return
+	}
+	recorder.RecordOperation(driverName, err == nil, time.Since(start))
+}
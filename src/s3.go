@@ -0,0 +1,669 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"panmatrix/api"
+	"panmatrix/metadata"
+	"panmatrix/scheduler"
+)
+
+const (
+	s3TimeLayout = "2006-01-02T15:04:05.000Z"
+	// PutObject的上限，与S3相同。对象先接收到临时文件，完整收到并校验后读入内存写入阵列
+	s3MaxObjectSize = 5 << 30
+	// DeleteObjects等请求的XML请求体和目录对象的请求体的上限
+	s3MaxXMLBody = 2 << 20
+	// ListObjects每页最多返回的键数
+	s3MaxKeys = 1000
+)
+
+// 不支持的子资源（如ACL、分段上传），请求带有这些查询参数时返回NotImplemented
+var s3Subresources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "encryption", "intelligent-tiering",
+	"inventory", "legal-hold", "lifecycle", "logging", "metrics", "notification", "object-lock",
+	"ownershipControls", "policy", "publicAccessBlock", "replication", "requestPayment", "restore",
+	"retention", "select", "tagging", "uploadId", "uploads", "versioning", "versions", "website",
+}
+
+// 配置了api.s3_listen时在后台提供S3兼容服务，返回的函数停止服务
+func (e *env) serveS3(cfg api.Config, raidLevel int) (func(), error) {
+	s := &s3Service{e: e, raidLevel: raidLevel, started: time.Now()}
+	server, listener, err := api.ListenS3(cfg, s)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return func() {}, nil
+	}
+	return serveHTTP("S3兼容服务", cfg.S3Listen, server, listener), nil
+}
+
+// S3兼容服务：存储桶为命名空间的顶层目录，对象的键为目录下的相对路径（如 photos/2024/img.jpg
+// 对应 /photos/2024/img.jpg）。只支持路径形式的请求（http://host/bucket/key），不支持分段上传
+type s3Service struct {
+	e         *env
+	raidLevel int       // 写入的RAID级别，与阵列初始化时一致
+	started   time.Time // 目录没有记录创建时间，使用服务启动的时间
+}
+
+func (s *s3Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.serve(w, r); err != nil {
+		api.WriteS3Error(w, r, s3Error(err))
+	}
+}
+
+func (s *s3Service) serve(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	for _, name := range s3Subresources {
+		if query.Has(name) {
+			return &api.S3Error{Status: http.StatusNotImplemented, Code: "NotImplemented", Message: "不支持的操作: " + name}
+		}
+	}
+	g := s.gateway(r)
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	switch {
+	case bucket == "":
+		if r.Method == http.MethodGet {
+			return s.listBuckets(w, g)
+		}
+	case key == "":
+		switch {
+		case r.Method == http.MethodGet && query.Has("location"):
+			return s.bucketLocation(w, g, bucket)
+		case r.Method == http.MethodGet:
+			return s.listObjects(w, r, g, bucket)
+		case r.Method == http.MethodHead:
+			_, err := s.bucketDir(g, bucket)
+			return err
+		case r.Method == http.MethodPut:
+			return s.createBucket(w, g, bucket)
+		case r.Method == http.MethodDelete:
+			return s.deleteBucket(w, g, bucket)
+		case r.Method == http.MethodPost && query.Has("delete"):
+			return s.deleteObjects(w, r, g, bucket)
+		}
+	default:
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			return s.getObject(w, r, g, bucket, key)
+		case http.MethodPut:
+			if r.Header.Get("X-Amz-Copy-Source") != "" {
+				return &api.S3Error{Status: http.StatusNotImplemented, Code: "NotImplemented", Message: "不支持CopyObject"}
+			}
+			return s.putObject(w, r, g, bucket, key)
+		case http.MethodDelete:
+			return s.deleteObject(w, r, g, bucket, key)
+		}
+	}
+	return &api.S3Error{Status: http.StatusMethodNotAllowed, Code: "MethodNotAllowed", Message: "不支持的请求: " + r.Method + " " + r.URL.Path}
+}
+
+// 请求使用的密钥指定了用户时只操作该用户的文件，否则使用启动时的用户
+func (s *s3Service) gateway(r *http.Request) *gateway {
+	ns := s.e.ns
+	if user, ok := api.S3User(r.Context()); ok {
+		ns = s.e.mm.Namespace(user)
+	}
+	return &gateway{e: s.e, ns: ns, raidLevel: s.raidLevel}
+}
+
+// 存储桶对应的目录，不存在时返回NoSuchBucket
+func (s *s3Service) bucketDir(g *gateway, bucket string) (string, error) {
+	dir, err := s3Path(bucket, "")
+	if err != nil {
+		return "", err
+	}
+	if _, err := g.ns.GetByName(dir); err == nil || !g.ns.DirExists(dir) {
+		return "", &api.S3Error{Status: http.StatusNotFound, Code: "NoSuchBucket", Message: "存储桶不存在: " + bucket}
+	}
+	return dir, nil
+}
+
+// 对象的虚拟路径。键中的.、..和连续的/会改变路径，不能使用
+func s3Path(bucket, key string) (string, error) {
+	p := "/" + bucket
+	if key != "" {
+		p += "/" + strings.TrimSuffix(key, "/")
+	}
+	if metadata.CleanPath(p) != p || p == "/" {
+		return "", &api.S3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "不支持的存储桶或键: " + bucket + "/" + key}
+	}
+	return p, nil
+}
+
+type s3Owner struct {
+	ID          string
+	DisplayName string
+}
+
+type s3Bucket struct {
+	Name         string
+	CreationDate string
+}
+
+func (s *s3Service) listBuckets(w http.ResponseWriter, g *gateway) error {
+	entries, err := g.ns.ListDir("/")
+	if err != nil {
+		return err
+	}
+	result := struct {
+		XMLName xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+		Owner   s3Owner    `xml:"Owner"`
+		Buckets []s3Bucket `xml:"Buckets>Bucket"`
+	}{Owner: s3Owner{ID: g.ns.Owner(), DisplayName: g.ns.Owner()}}
+	for _, entry := range entries {
+		if entry.IsDir {
+			result.Buckets = append(result.Buckets, s3Bucket{Name: entry.Name, CreationDate: s.started.UTC().Format(s3TimeLayout)})
+		}
+	}
+	return s3Reply(w, result)
+}
+
+// 区域为空即us-east-1，客户端可以使用任意区域签名
+func (s *s3Service) bucketLocation(w http.ResponseWriter, g *gateway, bucket string) error {
+	if _, err := s.bucketDir(g, bucket); err != nil {
+		return err
+	}
+	return s3Reply(w, struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	}{})
+}
+
+func (s *s3Service) createBucket(w http.ResponseWriter, g *gateway, bucket string) error {
+	dir, err := s3Path(bucket, "")
+	if err != nil {
+		return err
+	}
+	if g.ns.DirExists(dir) {
+		return &api.S3Error{Status: http.StatusConflict, Code: "BucketAlreadyOwnedByYou", Message: "存储桶已存在: " + bucket}
+	}
+	if _, err := g.ns.GetByName(dir); err == nil {
+		return &api.S3Error{Status: http.StatusConflict, Code: "BucketAlreadyExists", Message: "已存在同名文件: " + dir}
+	}
+	if err := g.ns.Mkdir(dir, false); err != nil {
+		return err
+	}
+	w.Header().Set("Location", dir)
+	return nil
+}
+
+func (s *s3Service) deleteBucket(w http.ResponseWriter, g *gateway, bucket string) error {
+	dir, err := s.bucketDir(g, bucket)
+	if err != nil {
+		return err
+	}
+	if err := g.ns.Rmdir(dir); err != nil {
+		if errors.Is(err, metadata.ErrDirNotEmpty) {
+			return &api.S3Error{Status: http.StatusConflict, Code: "BucketNotEmpty", Message: "存储桶不为空: " + bucket}
+		}
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type s3Object struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type s3Prefix struct {
+	Prefix string
+}
+
+// ListObjects和ListObjectsV2的结果，V1使用Marker，V2使用ContinuationToken
+type s3ListResult struct {
+	XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Marker                string `xml:",omitempty"`
+	NextMarker            string `xml:",omitempty"`
+	StartAfter            string `xml:",omitempty"`
+	ContinuationToken     string `xml:",omitempty"`
+	NextContinuationToken string `xml:",omitempty"`
+	KeyCount              *int   `xml:",omitempty"`
+	MaxKeys               int
+	Delimiter             string `xml:",omitempty"`
+	EncodingType          string `xml:",omitempty"`
+	IsTruncated           bool
+	Contents              []s3Object
+	CommonPrefixes        []s3Prefix
+}
+
+// 列表中的一项，meta为nil时为公共前缀
+type s3ListItem struct {
+	key  string
+	meta *metadata.FileMetadata
+}
+
+func (s *s3Service) listObjects(w http.ResponseWriter, r *http.Request, g *gateway, bucket string) error {
+	dir, err := s.bucketDir(g, bucket)
+	if err != nil {
+		return err
+	}
+	q := r.URL.Query()
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	maxKeys := s3MaxKeys
+	if value := q.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return &api.S3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "max-keys无效: " + value}
+		}
+		maxKeys = min(n, s3MaxKeys)
+	}
+	v2 := q.Get("list-type") == "2"
+	after := q.Get("marker")
+	if v2 {
+		after = q.Get("start-after")
+		if token := q.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				return &api.S3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "continuation-token无效"}
+			}
+			after = string(decoded)
+		}
+	}
+
+	// encoding-type=url时键按URL编码返回，客户端（如aws cli）会解码
+	encode := func(s string) string { return s }
+	if q.Get("encoding-type") == "url" {
+		escaper := strings.NewReplacer("+", "%20", "%2F", "/")
+		encode = func(s string) string { return escaper.Replace(url.QueryEscape(s)) }
+	}
+
+	items, err := s.listItems(g, dir, prefix, delimiter)
+	if err != nil {
+		return err
+	}
+	items = items[sort.Search(len(items), func(i int) bool { return items[i].key > after }):]
+	truncated := maxKeys > 0 && len(items) > maxKeys
+	page := items[:min(maxKeys, len(items))]
+
+	result := s3ListResult{
+		Name:         bucket,
+		Prefix:       encode(prefix),
+		Delimiter:    encode(delimiter),
+		MaxKeys:      maxKeys,
+		EncodingType: q.Get("encoding-type"),
+		IsTruncated:  truncated,
+	}
+	for _, item := range page {
+		if item.meta == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, s3Prefix{Prefix: encode(item.key)})
+			continue
+		}
+		result.Contents = append(result.Contents, s3Object{
+			Key:          encode(item.key),
+			LastModified: item.meta.UpdatedAt.UTC().Format(s3TimeLayout),
+			ETag:         s3ETag(item.meta),
+			Size:         item.meta.FileSize,
+			StorageClass: "STANDARD",
+		})
+	}
+	if v2 {
+		count := len(page)
+		result.KeyCount = &count
+		result.ContinuationToken = q.Get("continuation-token")
+		result.StartAfter = encode(q.Get("start-after"))
+		if truncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(page[len(page)-1].key))
+		}
+	} else {
+		result.Marker = encode(after)
+		if truncated {
+			result.NextMarker = encode(page[len(page)-1].key)
+		}
+	}
+	return s3Reply(w, result)
+}
+
+// 存储桶中键以prefix开头的对象，按键排序。delimiter不为空时，键在prefix之后含有delimiter的对象
+// 合并为一个公共前缀（到delimiter为止）
+func (s *s3Service) listItems(g *gateway, bucketDir, prefix, delimiter string) ([]s3ListItem, error) {
+	files := make(map[string]*metadata.FileMetadata)
+	prefixes := make(map[string]bool)
+
+	// 从前缀所在的目录开始遍历，分隔符为/时子目录直接作为公共前缀，不必进入
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := g.ns.ListDir(path.Join(bucketDir, rel))
+		if errors.Is(err, metadata.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			key := rel + entry.Name
+			if !entry.IsDir {
+				// 同名文件按上传时间排列，后面的是最新的
+				if strings.HasPrefix(key, prefix) {
+					files[key] = entry.File
+				}
+				continue
+			}
+			key += "/"
+			switch {
+			case delimiter == "/" && strings.HasPrefix(key, prefix):
+				prefixes[key] = true
+			case strings.HasPrefix(key, prefix) || strings.HasPrefix(prefix, key):
+				if err := walk(key); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(prefix[:strings.LastIndex(prefix, "/")+1]); err != nil {
+		return nil, err
+	}
+
+	items := make([]s3ListItem, 0, len(files)+len(prefixes))
+	for key, meta := range files {
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				prefixes[key[:len(prefix)+i+len(delimiter)]] = true
+				continue
+			}
+		}
+		items = append(items, s3ListItem{key: key, meta: meta})
+	}
+	for key := range prefixes {
+		items = append(items, s3ListItem{key: key})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].key < items[j].key })
+	return items, nil
+}
+
+func (s *s3Service) getObject(w http.ResponseWriter, r *http.Request, g *gateway, bucket, key string) error {
+	p, err := s3Path(bucket, key)
+	if err != nil {
+		return err
+	}
+	meta, err := g.ns.GetByName(p)
+	if err != nil {
+		// 以/结尾的键是一些工具用来表示目录的空对象
+		if strings.HasSuffix(key, "/") && g.ns.DirExists(p) {
+			w.Header().Set("Content-Length", "0")
+			return nil
+		}
+		if errors.Is(err, metadata.ErrNotFound) {
+			return &api.S3Error{Status: http.StatusNotFound, Code: "NoSuchKey", Message: "对象不存在: " + key}
+		}
+		return err
+	}
+
+	mimeType := meta.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("ETag", s3ETag(meta))
+	reader := g.openReader(meta)
+	defer reader.close()
+	// 处理Range和条件请求，只下载涉及的条带
+	http.ServeContent(w, r, "", meta.UpdatedAt, io.NewSectionReader(reader.readerAt(r.Context()), 0, meta.FileSize))
+	return nil
+}
+
+func (s *s3Service) putObject(w http.ResponseWriter, r *http.Request, g *gateway, bucket, key string) error {
+	if _, err := s.bucketDir(g, bucket); err != nil {
+		return err
+	}
+	p, err := s3Path(bucket, key)
+	if err != nil {
+		return err
+	}
+	switch {
+	case r.ContentLength < 0:
+		return &api.S3Error{Status: http.StatusLengthRequired, Code: "MissingContentLength", Message: "需要Content-Length"}
+	case r.ContentLength > s3MaxObjectSize:
+		return &api.S3Error{Status: http.StatusBadRequest, Code: "EntityTooLarge", Message: "对象超过5GB，不支持分段上传"}
+	}
+
+	if strings.HasSuffix(key, "/") {
+		data, err := s3ReadBody(r)
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			return &api.S3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: "以/结尾的键只能是空对象（目录）"}
+		}
+		return g.ns.Mkdir(p, true)
+	}
+	if g.ns.DirExists(p) {
+		return &api.S3Error{Status: http.StatusConflict, Code: "InvalidArgument", Message: "已存在同名目录: " + key}
+	}
+
+	// 先按Content-Length排队再接收内容，同时上传的对象占用的内存不超过调度器的max_queued_mb
+	admitted, err := g.admitUpload(r.Context(), p, r.ContentLength)
+	if err != nil {
+		return err
+	}
+	defer admitted()
+	data, err := s3SpoolBody(r, r.ContentLength)
+	if err != nil {
+		return err
+	}
+
+	// 覆盖已有文件时保留它的权限，新建的文件不记录权限
+	var mode os.FileMode
+	if meta, err := g.ns.GetByName(p); err == nil {
+		mode = meta.Mode.Perm()
+	}
+	fm, err := g.storeAdmitted(r.Context(), p, data, mode)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", s3ETag(fm))
+	return nil
+}
+
+func (s *s3Service) deleteObject(w http.ResponseWriter, r *http.Request, g *gateway, bucket, key string) error {
+	if _, err := s.bucketDir(g, bucket); err != nil {
+		return err
+	}
+	if err := s.removeKey(r.Context(), g, bucket, key); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// 删除对象，对象不存在时不是错误
+func (s *s3Service) removeKey(ctx context.Context, g *gateway, bucket, key string) error {
+	p, err := s3Path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if meta, err := g.ns.GetByName(p); err == nil {
+		return g.remove(ctx, meta)
+	}
+	if strings.HasSuffix(key, "/") {
+		// 目录中还有文件时目录照常存在
+		if err := g.ns.Rmdir(p); err != nil && !errors.Is(err, metadata.ErrNotFound) && !errors.Is(err, metadata.ErrDirNotEmpty) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteObjects一次最多删除1000个对象
+func (s *s3Service) deleteObjects(w http.ResponseWriter, r *http.Request, g *gateway, bucket string) error {
+	if _, err := s.bucketDir(g, bucket); err != nil {
+		return err
+	}
+	body, err := s3ReadBody(r)
+	if err != nil {
+		return err
+	}
+	var request struct {
+		Quiet   bool
+		Objects []struct {
+			Key string
+		} `xml:"Object"`
+	}
+	if err := xml.Unmarshal(body, &request); err != nil || len(request.Objects) > s3MaxKeys {
+		return &api.S3Error{Status: http.StatusBadRequest, Code: "MalformedXML", Message: "请求体不是有效的Delete"}
+	}
+
+	type deleted struct {
+		Key string
+	}
+	type deleteError struct {
+		Key     string
+		Code    string
+		Message string
+	}
+	result := struct {
+		XMLName xml.Name      `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+		Deleted []deleted     `xml:"Deleted"`
+		Errors  []deleteError `xml:"Error"`
+	}{}
+	for _, object := range request.Objects {
+		if err := s.removeKey(r.Context(), g, bucket, object.Key); err != nil {
+			var s3err *api.S3Error
+			if !errors.As(s3Error(err), &s3err) {
+				s3err = &api.S3Error{Code: "InternalError", Message: err.Error()}
+			}
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: s3err.Code, Message: s3err.Message})
+		} else if !request.Quiet {
+			result.Deleted = append(result.Deleted, deleted{Key: object.Key})
+		}
+	}
+	return s3Reply(w, result)
+}
+
+// 对象的ETag为内容的SHA-256（不是S3使用的MD5）
+func s3ETag(meta *metadata.FileMetadata) string {
+	return `"` + meta.Hash + `"`
+}
+
+// 读取XML等较小的请求体，超过s3MaxXMLBody时返回错误。
+// 读到末尾时才会校验签名中的哈希或最后一块的签名，设置了Content-MD5时同时校验
+func s3ReadBody(r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, s3MaxXMLBody+1))
+	if err != nil {
+		return nil, s3BodyError(err)
+	}
+	if len(data) > s3MaxXMLBody {
+		return nil, &api.S3Error{Status: http.StatusBadRequest, Code: "MaxMessageLengthExceeded", Message: "请求体超过2MB"}
+	}
+	if err := s3CheckMD5(r, md5.Sum(data)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// 将size字节（Content-Length）的对象内容接收到临时文件，完整收到并校验后读入内存。
+// 客户端发送得慢或中途断开时不占用对象大小的内存
+func s3SpoolBody(r *http.Request, size int64) ([]byte, error) {
+	file, err := os.CreateTemp("", "panmatrix-s3-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	// 多读一个字节确认没有多余的内容
+	sum := md5.New()
+	n, err := io.Copy(io.MultiWriter(file, sum), io.LimitReader(r.Body, size+1))
+	switch {
+	case err != nil:
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return nil, fmt.Errorf("写入临时文件失败: %v", err)
+		}
+		return nil, s3BodyError(err)
+	case n < size:
+		return nil, s3BodyError(io.ErrUnexpectedEOF)
+	case n > size:
+		return nil, s3BodyError(errors.New("请求体比Content-Length长"))
+	}
+	if err := s3CheckMD5(r, [md5.Size]byte(sum.Sum(nil))); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("读取临时文件失败: %v", err)
+	}
+	return data, nil
+}
+
+// 读取请求体的错误，签名或哈希校验失败时原样返回
+func s3BodyError(err error) error {
+	var s3err *api.S3Error
+	if errors.As(err, &s3err) {
+		return err
+	}
+	return &api.S3Error{Status: http.StatusBadRequest, Code: "IncompleteBody", Message: "读取请求体失败: " + err.Error()}
+}
+
+// 设置了Content-MD5时与请求体的MD5比较
+func s3CheckMD5(r *http.Request, sum [md5.Size]byte) error {
+	value := r.Header.Get("Content-Md5")
+	if value == "" {
+		return nil
+	}
+	want, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(want) != md5.Size {
+		return &api.S3Error{Status: http.StatusBadRequest, Code: "InvalidDigest", Message: "Content-MD5无效"}
+	}
+	if !bytes.Equal(sum[:], want) {
+		return &api.S3Error{Status: http.StatusBadRequest, Code: "BadDigest", Message: "请求体与Content-MD5不符"}
+	}
+	return nil
+}
+
+func s3Reply(w http.ResponseWriter, v any) error {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// 将命名空间和调度器的错误转换为S3的错误码，其他错误为InternalError
+func s3Error(err error) error {
+	var s3err *api.S3Error
+	switch {
+	case errors.As(err, &s3err):
+		return err
+	case errors.Is(err, metadata.ErrNotFound), errors.Is(err, metadata.ErrFileNotCommitted):
+		return &api.S3Error{Status: http.StatusNotFound, Code: "NoSuchKey", Message: err.Error()}
+	case errors.Is(err, metadata.ErrQuotaExceeded):
+		return &api.S3Error{Status: http.StatusForbidden, Code: "QuotaExceeded", Message: err.Error()}
+	case errors.Is(err, metadata.ErrDirShared):
+		return &api.S3Error{Status: http.StatusForbidden, Code: "AccessDenied", Message: err.Error()}
+	case errors.Is(err, metadata.ErrDirsUnsupported):
+		return &api.S3Error{Status: http.StatusNotImplemented, Code: "NotImplemented", Message: err.Error()}
+	case errors.Is(err, scheduler.ErrBusy):
+		// 客户端收到SlowDown后会等待并重试
+		return &api.S3Error{Status: http.StatusServiceUnavailable, Code: "SlowDown", Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"panmatrix/drivers"
+)
+
+// 设置按账号共用的传输限制（scheduler段的account_limits）。键为"服务商/账号"，与故障域中的
+// provider和account一致（未配置时服务商为驱动器类型、账号为驱动器名），同一账号下的驱动器
+// 共用一组并发名额和令牌桶，合计的条带块传输不超过网盘对整个账号的限制。需要在SetFailureDomains之后调用
+//
+//	scheduler:
+//	  domains:
+//	    drivers:
+//	      baidu-photos: {account: alice}
+//	      baidu-backup: {account: alice}
+//	  account_limits:
+//	    baidu/alice: {max_concurrent: 2, requests_per_sec: 5, upload_mbps: 10}
+func (rs *RAIDScheduler) SetAccountLimits(limits map[string]drivers.RateLimitConfig) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if len(limits) == 0 {
+		rs.accountLimits = nil
+		return nil
+	}
+	if rs.domains == nil {
+		return fmt.Errorf("设置账号限制前需要先设置故障域")
+	}
+	accounts := make(map[string][]string)
+	for name := range rs.metrics {
+		domain := rs.domains.domains[name]
+		key := domain.Provider + "/" + domain.Account
+		accounts[key] = append(accounts[key], name)
+	}
+
+	shared := make(map[string]*drivers.Limiter)
+	keys := make([]string, 0, len(limits))
+	for key := range limits {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cfg := limits[key]
+		if !cfg.Enabled() {
+			return fmt.Errorf("账号 %s 没有配置任何限制", key)
+		}
+		names := accounts[key]
+		if len(names) == 0 {
+			return fmt.Errorf("账号限制 %s 没有匹配的驱动器（键为\"服务商/账号\"）", key)
+		}
+		limiter := drivers.NewLimiter(cfg)
+		for _, name := range names {
+			shared[name] = limiter
+		}
+	}
+	rs.accountLimits = shared
+	return nil
+}
+
+// 取得驱动器所属账号的并发名额和请求令牌，没有账号限制时立即返回
+func (rs *RAIDScheduler) acquireAccount(ctx context.Context, driverName string) (func(), error) {
+	rs.mu.RLock()
+	limiter := rs.accountLimits[driverName]
+	rs.mu.RUnlock()
+	if limiter == nil {
+		return func() {}, nil
+	}
+	return limiter.Acquire(ctx)
+}
+
+// 按条带块的大小等待所属账号的带宽令牌（实现raid.TransferMeter）
+func (rs *RAIDScheduler) MeterTransfer(ctx context.Context, driverName string, upload bool, bytes int64) error {
+	rs.mu.RLock()
+	limiter := rs.accountLimits[driverName]
+	rs.mu.RUnlock()
+	if limiter == nil {
+		return nil
+	}
+	if upload {
+		return limiter.WaitUpload(ctx, bytes)
+	}
+	return limiter.WaitDownload(ctx, bytes)
+}
@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAdaptiveMin      = 1
+	defaultAdaptiveMax      = 32
+	defaultAdaptiveInterval = 30 * time.Second
+
+	// 一个调整周期内失败的比例超过该值时并发数减半
+	adaptiveErrorRate = 0.05
+
+	// 增加并发后吞吐下降超过该比例时撤回这次增加，说明网盘开始限速
+	adaptiveThroughputDrop = 0.1
+)
+
+// 自适应并发（scheduler段的adaptive_concurrency）。开启后按AIMD调整每个驱动器同时进行的传输数：
+// 周期内失败较多时减半；槽位用满且增加并发后吞吐仍在上升时加1；增加后吞吐反而下降时撤回。
+// 初始值为transfer_slots或concurrency中配置的并发数
+//
+//	scheduler:
+//	  adaptive_concurrency:
+//	    enabled: true
+//	    min: 1
+//	    max: 16
+//	    interval: 30s
+type AdaptiveConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Min      int           `yaml:"min"`      // 并发数下限，默认1
+	Max      int           `yaml:"max"`      // 并发数上限，默认32
+	Interval time.Duration `yaml:"interval"` // 调整周期，默认30秒
+}
+
+// 一个驱动器在当前周期内的传输结果
+type tuneWindow struct {
+	successes int
+	failures  int
+
+	lastThroughput float64 // 上一周期每秒完成的传输数
+	increased      bool    // 上一周期增加了并发数
+}
+
+type adaptiveTuner struct {
+	cfg     AdaptiveConfig
+	mu      sync.Mutex
+	windows map[string]*tuneWindow
+}
+
+func (t *adaptiveTuner) observe(driverName string, success bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w := t.windows[driverName]
+	if w == nil {
+		w = &tuneWindow{}
+		t.windows[driverName] = w
+	}
+	if success {
+		w.successes++
+	} else {
+		w.failures++
+	}
+}
+
+// 开启自适应并发，按cfg.Interval在后台调整。未开启时不做任何操作
+func (rs *RAIDScheduler) SetAdaptiveConcurrency(cfg AdaptiveConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Min < 0 || cfg.Max < 0 || cfg.Interval < 0 {
+		return fmt.Errorf("自适应并发的参数不能为负数")
+	}
+	if cfg.Min == 0 {
+		cfg.Min = defaultAdaptiveMin
+	}
+	if cfg.Max == 0 {
+		cfg.Max = defaultAdaptiveMax
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultAdaptiveInterval
+	}
+	if cfg.Min > cfg.Max {
+		return fmt.Errorf("自适应并发的下限 %d 大于上限 %d", cfg.Min, cfg.Max)
+	}
+
+	tuner := &adaptiveTuner{cfg: cfg, windows: make(map[string]*tuneWindow)}
+	s := rs.slots
+	s.mu.Lock()
+	s.tuned = make(map[string]int)
+	for name := range rs.drivers {
+		s.tuned[name] = min(max(s.configuredLimit(name), cfg.Min), cfg.Max)
+	}
+	s.saturated = make(map[string]bool)
+	s.dispatchLocked()
+	s.mu.Unlock()
+
+	rs.mu.Lock()
+	rs.tuner = tuner
+	rs.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		last := time.Now()
+		for now := range ticker.C {
+			rs.tuneConcurrency(tuner, now.Sub(last))
+			last = now
+		}
+	}()
+	return nil
+}
+
+// 按上一周期的结果调整各驱动器的并发数
+func (rs *RAIDScheduler) tuneConcurrency(t *adaptiveTuner, elapsed time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := rs.slots
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, w := range t.windows {
+		total := w.successes + w.failures
+		saturated := s.saturated[name]
+		if total == 0 {
+			continue
+		}
+		limit := s.tuned[name]
+		throughput := float64(w.successes) / elapsed.Seconds()
+		switch {
+		case float64(w.failures)/float64(total) > adaptiveErrorRate:
+			limit = max(limit/2, t.cfg.Min)
+			w.increased = false
+		case w.increased && throughput < w.lastThroughput*(1-adaptiveThroughputDrop):
+			limit = max(limit-1, t.cfg.Min)
+			w.increased = false
+		case saturated && limit < t.cfg.Max:
+			limit++
+			w.increased = true
+		default:
+			w.increased = false
+		}
+		s.tuned[name] = limit
+		w.lastThroughput = throughput
+		w.successes, w.failures = 0, 0
+	}
+	clear(s.saturated)
+	s.dispatchLocked()
+}
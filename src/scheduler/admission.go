@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// 默认允许同时排队和传输的数据量
+	defaultAdmissionLimit = 1024 * 1024 * 1024
+
+	// 无法按带宽估算时建议的重试间隔
+	defaultRetryAfter = 5 * time.Second
+)
+
+// 系统繁忙，用errors.Is判断；errors.As可以取得*BusyError中的重试间隔
+var ErrBusy = errors.New("系统繁忙")
+
+// 排队的数据量已达上限时TryAdmit返回的错误
+type BusyError struct {
+	Queued     int64         // 已接纳、尚未完成的数据量（字节）
+	Limit      int64         // 允许同时排队的数据量
+	RetryAfter time.Duration // 建议的重试间隔
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("系统繁忙: 排队中的数据 %d/%d 字节，请在%v后重试", e.Queued, e.Limit, e.RetryAfter)
+}
+
+func (e *BusyError) Is(target error) bool {
+	return target == ErrBusy
+}
+
+type admissionWaiter struct {
+	bytes int64
+	ready chan struct{}
+}
+
+// 按数据量的准入控制：已接纳、尚未完成的数据量不超过limit，超出的请求先到先得地排队。
+// 超过limit的单个请求在没有其他请求时接纳，不会一直等待
+type admission struct {
+	mu      sync.Mutex
+	limit   int64
+	queued  int64
+	waiters []*admissionWaiter
+}
+
+func newAdmission() *admission {
+	return &admission{limit: defaultAdmissionLimit}
+}
+
+// 调用方需持有a.mu
+func (a *admission) fitsLocked(bytes int64) bool {
+	return a.queued == 0 || a.queued+bytes <= a.limit
+}
+
+// 按顺序接纳排队的请求，调用方需持有a.mu
+func (a *admission) dispatchLocked() {
+	for len(a.waiters) > 0 && a.fitsLocked(a.waiters[0].bytes) {
+		w := a.waiters[0]
+		a.waiters[0] = nil
+		a.waiters = a.waiters[1:]
+		a.queued += w.bytes
+		close(w.ready)
+	}
+}
+
+func (a *admission) release(bytes int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.queued -= bytes
+	a.dispatchLocked()
+}
+
+func (a *admission) remove(w *admissionWaiter) bool {
+	for i, other := range a.waiters {
+		if other == w {
+			a.waiters = append(a.waiters[:i], a.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// 设置允许同时排队和传输的数据量，0表示使用默认值（1GB）
+func (rs *RAIDScheduler) SetAdmissionLimit(bytes int64) error {
+	if bytes < 0 {
+		return fmt.Errorf("排队数据量上限不能为负数: %d", bytes)
+	}
+	if bytes == 0 {
+		bytes = defaultAdmissionLimit
+	}
+	a := rs.admission
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limit = bytes
+	a.dispatchLocked()
+	return nil
+}
+
+// 接纳一个bytes字节的上传或下载，已排队的数据量达到上限时等待，ctx取消时放弃排队并返回ctx的错误。
+// 传输完成（或放弃）后调用返回的release
+func (rs *RAIDScheduler) Admit(ctx context.Context, bytes int64) (func(), error) {
+	a := rs.admission
+	bytes = max(bytes, 0)
+	a.mu.Lock()
+	if len(a.waiters) == 0 && a.fitsLocked(bytes) {
+		a.queued += bytes
+		a.mu.Unlock()
+		return sync.OnceFunc(func() { a.release(bytes) }), nil
+	}
+	w := &admissionWaiter{bytes: bytes, ready: make(chan struct{})}
+	a.waiters = append(a.waiters, w)
+	a.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return sync.OnceFunc(func() { a.release(bytes) }), nil
+	case <-ctx.Done():
+		a.mu.Lock()
+		queued := a.remove(w)
+		if queued {
+			// 排在后面的较小请求可能已经可以接纳
+			a.dispatchLocked()
+		}
+		a.mu.Unlock()
+		if !queued {
+			// 取消的同时已被接纳
+			a.release(bytes)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// 与Admit相同，但不等待：排队的数据量已达上限时立即返回*BusyError，
+// 适合需要向客户端返回"稍后重试"的服务端
+func (rs *RAIDScheduler) TryAdmit(bytes int64) (func(), error) {
+	a := rs.admission
+	bytes = max(bytes, 0)
+	a.mu.Lock()
+	if len(a.waiters) == 0 && a.fitsLocked(bytes) {
+		a.queued += bytes
+		a.mu.Unlock()
+		return sync.OnceFunc(func() { a.release(bytes) }), nil
+	}
+	queued, limit := a.queued, a.limit
+	waiting := int64(0)
+	for _, w := range a.waiters {
+		waiting += w.bytes
+	}
+	a.mu.Unlock()
+
+	return nil, &BusyError{Queued: queued, Limit: limit, RetryAfter: rs.retryAfter(queued + waiting + bytes - limit)}
+}
+
+// 按探测测得的总上传带宽估算腾出excess字节所需的时间，没有带宽数据时使用默认值
+func (rs *RAIDScheduler) retryAfter(excess int64) time.Duration {
+	rs.mu.RLock()
+	bandwidth := 0.0
+	for _, metric := range rs.metrics {
+		bandwidth += metric.UploadBandwidth
+	}
+	rs.mu.RUnlock()
+
+	if bandwidth <= 0 || excess <= 0 {
+		return defaultRetryAfter
+	}
+	return max(time.Duration(float64(excess)/bandwidth*float64(time.Second)).Round(time.Second), time.Second)
+}
+
+// 已接纳、尚未完成的数据量和上限
+func (rs *RAIDScheduler) AdmissionLoad() (queued, limit int64) {
+	a := rs.admission
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.queued, a.limit
+}
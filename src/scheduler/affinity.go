@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// 驱动器亲和性规则（scheduler段的affinity）
+//
+//	scheduler:
+//	  affinity:
+//	    parity_prefer: [local]        # RAID5的校验块优先放在这些驱动器上
+//	    pins:                         # 匹配的文件只放在这些驱动器上
+//	      - pattern: "*.mkv"
+//	        drivers: [aliyun, onedrive]
+//
+// 同一条带的块不放在同一服务商的多个驱动器上由故障域配置（DomainConfig）控制
+type AffinityConfig struct {
+	ParityPrefer []string  `yaml:"parity_prefer"`
+	Pins         []PinRule `yaml:"pins"`
+}
+
+// 文件固定规则。pattern不含"/"时匹配文件名，否则匹配完整路径；按配置顺序使用第一条匹配的规则
+type PinRule struct {
+	Pattern string   `yaml:"pattern"`
+	Drivers []string `yaml:"drivers"`
+}
+
+type affinityRules struct {
+	parityPrefer []string
+	pins         []PinRule
+}
+
+// 设置亲和性规则
+func (rs *RAIDScheduler) SetAffinity(cfg AffinityConfig) error {
+	rules := &affinityRules{parityPrefer: cfg.ParityPrefer}
+	for i, pin := range cfg.Pins {
+		if _, err := path.Match(pin.Pattern, ""); err != nil || pin.Pattern == "" {
+			return fmt.Errorf("第%d条固定规则的pattern无效: %q", i+1, pin.Pattern)
+		}
+		if len(pin.Drivers) == 0 {
+			return fmt.Errorf("固定规则 %s 没有指定驱动器", pin.Pattern)
+		}
+		rules.pins = append(rules.pins, pin)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for _, name := range append(append([]string(nil), cfg.ParityPrefer...), pinnedDrivers(rules.pins)...) {
+		if _, ok := rs.metrics[name]; !ok {
+			return fmt.Errorf("亲和性规则中的驱动器 %s 不存在", name)
+		}
+	}
+	rs.affinity = rules
+	return nil
+}
+
+func pinnedDrivers(pins []PinRule) []string {
+	var names []string
+	for _, pin := range pins {
+		names = append(names, pin.Drivers...)
+	}
+	return names
+}
+
+// 文件匹配的固定规则，没有时返回nil
+func (r *affinityRules) pinFor(fileName string) *PinRule {
+	if r == nil || fileName == "" {
+		return nil
+	}
+	for i, pin := range r.pins {
+		target := path.Base(fileName)
+		if strings.Contains(pin.Pattern, "/") {
+			target = strings.TrimPrefix(fileName, "/")
+		}
+		if ok, _ := path.Match(strings.TrimPrefix(pin.Pattern, "/"), target); ok {
+			return &r.pins[i]
+		}
+	}
+	return nil
+}
+
+// 按文件固定规则筛选候选驱动器，调用方需持有rs.mu
+func (rs *RAIDScheduler) applyAffinityLocked(fileName string, candidates []string) []string {
+	pin := rs.affinity.pinFor(fileName)
+	if pin == nil {
+		return candidates
+	}
+	allowed := make(map[string]bool, len(pin.Drivers))
+	for _, name := range pin.Drivers {
+		allowed[name] = true
+	}
+	pinned := candidates[:0:0]
+	for _, name := range candidates {
+		if allowed[name] {
+			pinned = append(pinned, name)
+		}
+	}
+	return pinned
+}
+
+// RAID5的校验块优先放在parity_prefer中的驱动器上：已选中时移到列表末尾，
+// 未选中但可用时替换原来的校验块驱动器。调用方需持有rs.mu
+func (rs *RAIDScheduler) preferParityLocked(selected, candidates []string) []string {
+	if rs.affinity == nil || len(rs.affinity.parityPrefer) == 0 || len(selected) < 3 {
+		return selected
+	}
+	index := make(map[string]int, len(selected))
+	for i, name := range selected {
+		index[name] = i
+	}
+	available := make(map[string]bool, len(candidates))
+	for _, name := range candidates {
+		available[name] = true
+	}
+
+	last := len(selected) - 1
+	for _, name := range rs.affinity.parityPrefer {
+		if i, ok := index[name]; ok {
+			selected[i], selected[last] = selected[last], selected[i]
+			return selected
+		}
+		if available[name] && !rs.conflictsLocked(name, selected[:last]) {
+			selected[last] = name
+			return selected
+		}
+	}
+	return selected
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"panmatrix/drivers"
+)
+
+func newTestScheduler(t *testing.T, count int) *RAIDScheduler {
+	t.Helper()
+	all := make(map[string]drivers.StorageDriver, count)
+	for i := 0; i < count; i++ {
+		d, err := drivers.NewMemoryDriver(drivers.MemoryConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		all[fmt.Sprintf("d%d", i)] = d
+	}
+	rs := NewRAIDScheduler(all)
+	rs.SetStripeSize(1000)
+	return rs
+}
+
+func TestPinnedFilesStayOnPinnedDrivers(t *testing.T) {
+	rs := newTestScheduler(t, 6)
+	pinned := []string{"d1", "d3", "d4"}
+	if err := rs.SetAffinity(AffinityConfig{Pins: []PinRule{{Pattern: "/photos/*", Drivers: pinned}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []int{1, 5} {
+		plan, err := rs.ReserveFile("/photos/a.jpg", 5500, level)
+		if err != nil {
+			t.Fatalf("RAID%d ReserveFile: %v", level, err)
+		}
+		for i := 0; plan.StripeDrivers(i) != nil; i++ {
+			for _, name := range plan.StripeDrivers(i) {
+				if !slices.Contains(pinned, name) {
+					t.Errorf("RAID%d条带%d放在固定规则之外的%s上", level, i, name)
+				}
+			}
+		}
+		rs.ReleaseReservation(plan)
+	}
+
+	target, err := rs.SelectReplacement("/photos/a.jpg", 1, 1000, []string{"d1"}, []string{"d1", "d3"})
+	if err != nil {
+		t.Fatalf("SelectReplacement: %v", err)
+	}
+	if target != "d4" {
+		t.Errorf("迁出的块放在%s上，期望唯一允许的d4", target)
+	}
+	if _, err := rs.SelectReplacement("/photos/a.jpg", 1, 1000, nil, pinned); err == nil {
+		t.Error("固定规则允许的驱动器都已使用时应失败")
+	}
+}
+
+func TestSelectReplacementSpreadsDomains(t *testing.T) {
+	rs := newTestScheduler(t, 4)
+	cfg := DomainConfig{Spread: []string{DomainProvider}, Drivers: map[string]FailureDomain{
+		"d0": {Provider: "a"}, "d1": {Provider: "b"}, "d2": {Provider: "a"}, "d3": {Provider: "c"},
+	}}
+	if err := rs.SetFailureDomains(cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 迁空d0，另一份副本在d1（服务商b），d3已被使用
+	target, err := rs.SelectReplacement("", 1, 1000, []string{"d1"}, []string{"d0", "d1", "d3"})
+	if err != nil {
+		t.Fatalf("SelectReplacement: %v", err)
+	}
+	if target != "d2" {
+		t.Errorf("目标为%s，期望d2", target)
+	}
+	// 迁空d1，条带的其他块在d0和d3：剩下的d2与d0同属服务商a
+	if _, err := rs.SelectReplacement("", 5, 1000, []string{"d0", "d3"}, []string{"d0", "d1", "d3"}); err == nil {
+		t.Error("剩下的驱动器与条带的其他块属于同一故障域时应失败")
+	}
+}
@@ -0,0 +1,326 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"panmatrix/drivers"
+)
+
+// 调度模式
+const (
+	ModePerformance = "performance" // 按延迟、成功率和负载选择驱动器（默认）
+	ModeCost        = "cost"        // 在满足RAID冗余要求的前提下使预计的每月费用最低
+)
+
+// 未配置monthly_reads时，假定每月下载的数据量为存储量的10%
+const defaultMonthlyReads = 0.1
+
+// 驱动器的费用参数，未配置的驱动器按免费计算
+type DriverCost struct {
+	StoragePerGB float64 `yaml:"storage_per_gb"` // 每GB每月的存储费用
+	EgressPerGB  float64 `yaml:"egress_per_gb"`  // 每下载1GB的流量费用
+	PerRequest   float64 `yaml:"per_request"`    // 每次请求（上传或下载一个块）的费用
+}
+
+// 调度配置（config.yaml顶层的scheduler段）。费用只用于估算，货币单位由配置决定
+//
+//	scheduler:
+//	  mode: cost               # performance（默认）或 cost
+//	  monthly_reads: 0.2       # 每月下载的数据量占存储量的比例
+//	  transfer_slots: 4        # 每个驱动器同时进行的传输数
+//	  concurrency:             # 单独设置驱动器同时进行的传输数
+//	    baidu: 2
+//	  adaptive_concurrency:    # 按吞吐和错误率自动调整各驱动器的并发数，见AdaptiveConfig
+//	    enabled: true
+//	  max_queued_mb: 1024      # 同时排队和传输的数据量上限，超出时新的上传下载等待或返回ErrBusy
+//	  deadline_escalation: 30s # 后台任务距离截止时间不足该时长时按最高优先级调度
+//	  space_margin_mb: 64      # 驱动器放下条带块后至少还要剩余的空间，不足时不选择该驱动器
+//	  probe_interval: 15m      # 定期上传下载探测对象测量实际延迟和带宽，默认不探测
+//	  probe_size_kb: 1024
+//	  hedge_delay: 2s          # 读取冗余数据超过该时间未完成时同时从另一个来源读取，默认不对冲
+//	  strategy: balanced       # 评分策略：performance、reliability、cost、balanced或注册的自定义策略
+//	  weights:                 # 覆盖策略的默认权重，见ScoreWeights
+//	    latency: 0.5
+//	  windows:                 # 驱动器的可用时间段，见WindowPolicy
+//	    baidu-free:
+//	      allow: ["23:00-07:00"]
+//	  domains:                 # 冗余数据分散到不同的服务商、账号或地域，见DomainConfig
+//	    spread: [provider]
+//	  affinity:                # 校验块和文件固定规则，见AffinityConfig
+//	    parity_prefer: [local]
+//	  account_limits:          # 同一账号下的驱动器共用的请求限制，见SetAccountLimits
+//	    baidu/alice: {requests_per_sec: 5}
+//	  costs:
+//	    s3-main:
+//	      storage_per_gb: 0.023
+//	      egress_per_gb: 0.09
+//	      per_request: 0.0000004
+//	    baidu:
+//	      storage_per_gb: 0.002
+type SchedulerConfig struct {
+	Mode         string                `yaml:"mode"`
+	MonthlyReads float64               `yaml:"monthly_reads"`
+	Costs        map[string]DriverCost `yaml:"costs"`
+
+	TransferSlots      int            `yaml:"transfer_slots"`
+	Concurrency        map[string]int `yaml:"concurrency"`
+	Adaptive           AdaptiveConfig `yaml:"adaptive_concurrency"`
+	MaxQueuedMB        int64          `yaml:"max_queued_mb"`
+	DeadlineEscalation time.Duration  `yaml:"deadline_escalation"`
+	SpaceMarginMB      int64          `yaml:"space_margin_mb"`
+	ProbeInterval      time.Duration  `yaml:"probe_interval"`
+	ProbeSizeKB        int64          `yaml:"probe_size_kb"`
+	HedgeDelay         time.Duration  `yaml:"hedge_delay"`
+
+	Strategy string             `yaml:"strategy"`
+	Weights  map[string]float64 `yaml:"weights"`
+
+	Windows       map[string]WindowPolicy            `yaml:"windows"`
+	Domains       DomainConfig                       `yaml:"domains"`
+	Affinity      AffinityConfig                     `yaml:"affinity"`
+	AccountLimits map[string]drivers.RateLimitConfig `yaml:"account_limits"`
+}
+
+// 读取配置文件中的scheduler段，没有该段时使用默认的性能优先模式
+func LoadSchedulerConfig(path string) (SchedulerConfig, error) {
+	var file struct {
+		Scheduler SchedulerConfig `yaml:"scheduler"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file.Scheduler, err
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file.Scheduler, fmt.Errorf("解析scheduler配置失败: %v", err)
+	}
+	return file.Scheduler, nil
+}
+
+// 设置调度模式和各驱动器的费用参数
+func (rs *RAIDScheduler) SetCostModel(cfg SchedulerConfig) error {
+	mode := cfg.Mode
+	if mode == "" {
+		mode = ModePerformance
+	}
+	if mode != ModePerformance && mode != ModeCost {
+		return fmt.Errorf("未知的调度模式: %s", cfg.Mode)
+	}
+	reads := cfg.MonthlyReads
+	if reads == 0 {
+		reads = defaultMonthlyReads
+	}
+	if reads < 0 {
+		return fmt.Errorf("monthly_reads不能为负数: %v", cfg.MonthlyReads)
+	}
+	for name, cost := range cfg.Costs {
+		if cost.StoragePerGB < 0 || cost.EgressPerGB < 0 || cost.PerRequest < 0 {
+			return fmt.Errorf("驱动器 %s 的费用不能为负数: %+v", name, cost)
+		}
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.mode = mode
+	rs.monthlyReads = reads
+	for name, metric := range rs.metrics {
+		metric.Cost = cfg.Costs[name]
+	}
+	return nil
+}
+
+// 当前的调度模式
+func (rs *RAIDScheduler) Mode() string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	if rs.mode == "" {
+		return ModePerformance
+	}
+	return rs.mode
+}
+
+// 一批块在一个驱动器上每月的费用：存储费用，加上按monthly_reads估算的下载流量和请求费用。
+// share为读取时实际读到这些块的比例（镜像的多份副本平均分担读取，校验块正常情况下不读取）
+func (rs *RAIDScheduler) monthlyCost(driverName string, bytes int64, strips, share float64) (storage, egress, requests float64) {
+	metric := rs.metrics[driverName]
+	if metric == nil {
+		return 0, 0, 0
+	}
+	gb := float64(bytes) / (1 << 30)
+	reads := rs.monthlyReads * share
+	return gb * metric.Cost.StoragePerGB, gb * reads * metric.Cost.EgressPerGB, strips * reads * metric.Cost.PerRequest
+}
+
+// 放置计划中的块每月的费用，调用方需持有rs.mu
+func (rs *RAIDScheduler) placementsCostLocked(placements []StripPlacement) float64 {
+	// 同一条带同一序号的块是镜像副本
+	copies := make(map[[2]int]int)
+	for _, p := range placements {
+		copies[[2]int{p.StripeIndex, p.StripIndex}]++
+	}
+
+	total := 0.0
+	for _, p := range placements {
+		share := 1 / float64(copies[[2]int{p.StripeIndex, p.StripIndex}])
+		if p.IsParity {
+			share = 0
+		}
+		storage, egress, requests := rs.monthlyCost(p.DriverName, p.Size, 1, share)
+		total += storage + egress + requests
+	}
+	return total
+}
+
+// 放置计划中的块每月的费用
+func (rs *RAIDScheduler) EstimateCost(placements []StripPlacement) float64 {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.placementsCostLocked(placements)
+}
+
+// 费用优先的驱动器选择：在每种RAID级别允许的驱动器数中，逐一计算最便宜的组合的费用，取最低者。
+// 驱动器不足以满足冗余要求时与性能优先模式一样返回所有可用的驱动器，由调用方报错
+func (rs *RAIDScheduler) selectByCost(raidLevel, stripeIndex int, available, archives []string) []string {
+	// 按每GB每月的费用排序，费用相同时按综合评分
+	unit := func(name string) float64 {
+		return unitCost(rs.metrics[name].Cost, rs.monthlyReads)
+	}
+	byCost := func(names []string) []string {
+		sorted := append([]string(nil), names...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			ui, uj := unit(sorted[i]), unit(sorted[j])
+			if ui != uj {
+				return ui < uj
+			}
+			return rs.calculateScore(sorted[i]) > rs.calculateScore(sorted[j])
+		})
+		return sorted
+	}
+	sorted := byCost(available)
+
+	var candidates [][]string
+	switch raidLevel {
+	case 1:
+		// 多于2份副本只会增加费用
+		candidates = append(candidates, sorted[:min(2, len(sorted))])
+	case 5:
+		if len(archives) > 0 {
+			archive := byCost(archives)[0]
+			for n := 2; n <= min(4, len(sorted)); n++ {
+				candidates = append(candidates, append(append([]string(nil), sorted[:n]...), archive))
+			}
+		}
+		for n := 3; n <= min(5, len(sorted)); n++ {
+			candidates = append(candidates, rs.costParityLast(sorted[:n]))
+		}
+	case 10:
+		for n := 4; n <= min(8, len(sorted)); n += 2 {
+			candidates = append(candidates, sorted[:n])
+		}
+	default:
+		for n := 1; n <= min(4, len(sorted)); n++ {
+			candidates = append(candidates, sorted[:n])
+		}
+	}
+	if len(candidates) == 0 {
+		return available
+	}
+
+	// 按完整条带估算，请求费用随块数变化
+	stripeLen := rs.stripeSize
+	if stripeLen <= 0 {
+		stripeLen = 4 << 20
+	}
+	best, bestCost := candidates[0], math.Inf(1)
+	for _, candidate := range candidates {
+		placements, err := stripPlacements(raidLevel, stripeIndex, stripeLen, candidate)
+		if err != nil {
+			continue
+		}
+		if cost := rs.placementsCostLocked(placements); cost < bestCost {
+			best, bestCost = candidate, cost
+		}
+	}
+	return append([]string(nil), best...)
+}
+
+// 校验块正常情况下不读取，放在下载流量最贵的驱动器上（列表末尾）
+func (rs *RAIDScheduler) costParityLast(names []string) []string {
+	selected := append([]string(nil), names...)
+	parity := len(selected) - 1
+	for i, name := range selected {
+		if rs.metrics[name].Cost.EgressPerGB > rs.metrics[selected[parity]].Cost.EgressPerGB {
+			parity = i
+		}
+	}
+	selected[parity], selected[len(selected)-1] = selected[len(selected)-1], selected[parity]
+	return selected
+}
+
+// 一个驱动器每月的预计费用
+type DriverCostEstimate struct {
+	Driver     string
+	Placed     int64 // PanMatrix放置在该驱动器上的字节数
+	Configured bool  // 是否配置了费用参数
+	Storage    float64
+	Egress     float64
+	Requests   float64
+}
+
+func (e DriverCostEstimate) Total() float64 {
+	return e.Storage + e.Egress + e.Requests
+}
+
+// 按已放置的数据量估算的每月费用
+type CostReport struct {
+	Mode         string
+	MonthlyReads float64
+	Drivers      []DriverCostEstimate // 按驱动器名排列
+}
+
+func (r CostReport) Total() float64 {
+	total := 0.0
+	for _, d := range r.Drivers {
+		total += d.Total()
+	}
+	return total
+}
+
+// 按各驱动器上已放置的字节数估算每月费用。块数按条带大小推算，放置的数据中的校验块和镜像副本
+// 无法区分，下载流量按全部数据估算，是费用的上限
+func (rs *RAIDScheduler) CostReport(placed map[string]int64) CostReport {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	report := CostReport{Mode: rs.mode, MonthlyReads: rs.monthlyReads}
+	if report.Mode == "" {
+		report.Mode = ModePerformance
+	}
+
+	names := make(map[string]bool)
+	for name := range placed {
+		names[name] = true
+	}
+	for name := range rs.metrics {
+		names[name] = true
+	}
+	for name := range names {
+		estimate := DriverCostEstimate{Driver: name, Placed: placed[name]}
+		if metric := rs.metrics[name]; metric != nil {
+			estimate.Configured = metric.Cost != DriverCost{}
+		}
+		strips := 0.0
+		if rs.stripeSize > 0 {
+			strips = math.Ceil(float64(estimate.Placed) / float64(rs.stripeSize))
+		}
+		estimate.Storage, estimate.Egress, estimate.Requests = rs.monthlyCost(name, estimate.Placed, strips, 1)
+		report.Drivers = append(report.Drivers, estimate)
+	}
+	sort.Slice(report.Drivers, func(i, j int) bool { return report.Drivers[i].Driver < report.Drivers[j].Driver })
+	return report
+}
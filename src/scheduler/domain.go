@@ -0,0 +1,206 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// 故障域级别
+const (
+	DomainAccount  = "account"  // 同一账号被封禁或欠费时，其下的驱动器同时不可用
+	DomainProvider = "provider" // 同一服务商故障或封禁时，其下的账号同时不可用
+	DomainRegion   = "region"   // 同一地域的机房或网络故障
+)
+
+// 驱动器所属的故障域，未配置的项按默认值：服务商为驱动器类型，账号为驱动器名，地域未知
+type FailureDomain struct {
+	Provider string `yaml:"provider"`
+	Account  string `yaml:"account"`
+	Region   string `yaml:"region"`
+}
+
+// 故障域配置（scheduler段的domains）。spread中的每个级别，同一条带的冗余数据都必须落在不同的故障域：
+// RAID1的副本、RAID5的各个块、RAID10同一镜像对的两个副本。RAID0没有冗余，不受限制
+//
+//	scheduler:
+//	  domains:
+//	    spread: [provider]
+//	    drivers:
+//	      baidu-1: {provider: baidu, account: alice}
+//	      baidu-2: {provider: baidu, account: bob}
+//	      s3-main: {region: us-east-1}
+type DomainConfig struct {
+	Spread  []string                 `yaml:"spread"`
+	Drivers map[string]FailureDomain `yaml:"drivers"`
+}
+
+type failureDomains struct {
+	spread  []string
+	domains map[string]FailureDomain
+}
+
+// 设置驱动器的故障域。types为各驱动器的类型，用作未配置provider的驱动器的服务商
+func (rs *RAIDScheduler) SetFailureDomains(cfg DomainConfig, types map[string]string) error {
+	for _, level := range cfg.Spread {
+		switch level {
+		case DomainAccount, DomainProvider, DomainRegion:
+		default:
+			return fmt.Errorf("未知的故障域级别: %s（可用: account、provider、region）", level)
+		}
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for name := range cfg.Drivers {
+		if _, ok := rs.metrics[name]; !ok {
+			return fmt.Errorf("故障域配置中的驱动器 %s 不存在", name)
+		}
+	}
+	fd := &failureDomains{spread: cfg.Spread, domains: make(map[string]FailureDomain, len(rs.metrics))}
+	for name := range rs.metrics {
+		domain := cfg.Drivers[name]
+		if domain.Provider == "" {
+			domain.Provider = types[name]
+		}
+		if domain.Account == "" {
+			domain.Account = name
+		}
+		fd.domains[name] = domain
+	}
+	rs.domains = fd
+	return nil
+}
+
+// 两个驱动器是否在spread的某一级上属于同一故障域，未知的服务商和地域不视为相同
+func (fd *failureDomains) shared(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if fd == nil {
+		return false
+	}
+	da, db := fd.domains[a], fd.domains[b]
+	for _, level := range fd.spread {
+		var va, vb string
+		switch level {
+		case DomainAccount:
+			va, vb = da.Provider+"/"+da.Account, db.Provider+"/"+db.Account
+		case DomainProvider:
+			va, vb = da.Provider, db.Provider
+		case DomainRegion:
+			va, vb = da.Region, db.Region
+		}
+		if va != "" && va == vb {
+			return true
+		}
+	}
+	return false
+}
+
+// 驱动器是否与selected中的某个驱动器属于同一故障域，调用方需持有rs.mu
+func (rs *RAIDScheduler) conflictsLocked(name string, selected []string) bool {
+	for _, other := range selected {
+		if other != name && rs.domains.shared(name, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// RAID1和RAID5的每个块都必须在不同的故障域：每组同一故障域的驱动器只保留评分最高的一个。
+// RAID10只要求镜像对的两个副本分开，选出驱动器后由pairByDomainLocked组成镜像对。调用方需持有rs.mu
+func (rs *RAIDScheduler) spreadCandidatesLocked(raidLevel int, candidates []string) []string {
+	if rs.domains == nil || len(rs.domains.spread) == 0 || (raidLevel != 1 && raidLevel != 5) {
+		return candidates
+	}
+	// 评分相同时按驱动器名选择，使各条带的选择一致
+	ranked := append([]string(nil), candidates...)
+	sort.Strings(ranked)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return rs.calculateScore(ranked[i]) > rs.calculateScore(ranked[j])
+	})
+	var kept []string
+	for _, name := range ranked {
+		if !rs.conflictsLocked(name, kept) {
+			kept = append(kept, name)
+		}
+	}
+
+	// 保持原来的顺序
+	keep := make(map[string]bool, len(kept))
+	for _, name := range kept {
+		keep[name] = true
+	}
+	spread := candidates[:0:0]
+	for _, name := range candidates {
+		if keep[name] {
+			spread = append(spread, name)
+		}
+	}
+	return spread
+}
+
+// 将RAID10选出的驱动器按顺序两两组成镜像对，同一镜像对的两个驱动器不在同一故障域；
+// 找不到搭档的驱动器被去掉。ranked为按优先顺序排列的全部候选驱动器，用于补足去掉的驱动器。调用方需持有rs.mu
+func (rs *RAIDScheduler) pairByDomainLocked(selected, ranked []string) []string {
+	if rs.domains == nil || len(rs.domains.spread) == 0 {
+		return selected
+	}
+	pool := append([]string(nil), selected...)
+	for _, name := range ranked {
+		if !slices.Contains(pool, name) {
+			pool = append(pool, name)
+		}
+	}
+
+	used := make(map[string]bool, len(pool))
+	var paired []string
+	for i, first := range pool {
+		if used[first] || len(paired) >= len(selected) {
+			continue
+		}
+		for _, second := range pool[i+1:] {
+			if !used[second] && !rs.domains.shared(first, second) {
+				used[first], used[second] = true, true
+				paired = append(paired, first, second)
+				break
+			}
+		}
+	}
+	return paired
+}
+
+// 为迁空驱动器时迁出的条带块选择目标驱动器：遵守文件固定规则和上传时间段，剩余空间要放得下size字节，
+// 且不与peers（同一条带中保存冗余数据的驱动器）属于同一故障域。exclude中的驱动器不参与选择，
+// 归档驱动器只用于RAID5的校验块，不作为目标
+func (rs *RAIDScheduler) SelectReplacement(fileName string, raidLevel int, size int64, peers, exclude []string) (string, error) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	exclude = append(rs.closedForUploadLocked(time.Now()), exclude...)
+	rs.reservations.mu.Lock()
+	space := rs.spaceLocked()
+	rs.reservations.mu.Unlock()
+
+	candidates := rs.getAvailableDrivers(exclude, size, space.remaining)
+	candidates = rs.applyAffinityLocked(fileName, candidates)
+	available, _ := rs.splitArchives(candidates)
+	// 评分相同时按驱动器名选择，结果稳定
+	sort.Strings(available)
+	sort.SliceStable(available, func(i, j int) bool {
+		return rs.calculateScore(available[i]) > rs.calculateScore(available[j])
+	})
+	for _, name := range available {
+		if raidLevel == 0 || !rs.conflictsLocked(name, peers) {
+			return name, nil
+		}
+	}
+	if len(available) == 0 {
+		return "", errors.New("没有满足空间、上传时间段和文件固定规则的驱动器")
+	}
+	return "", errors.New("可用的驱动器都与条带的其他块属于同一故障域")
+}
@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 将驱动器设为排空状态或取消排空。排空中的驱动器不再被选来放置新数据，
+// 读取和迁空（Evacuate）不受影响，适合在网盘会员到期或账号注销之前逐步停用
+func (rs *RAIDScheduler) SetDraining(driverName string, draining bool) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if _, ok := rs.metrics[driverName]; !ok {
+		return fmt.Errorf("驱动器不存在: %s", driverName)
+	}
+	if draining {
+		rs.draining[driverName] = true
+	} else {
+		delete(rs.draining, driverName)
+	}
+	return nil
+}
+
+// 将驱动器设为只保存副本：仍参与传输调度和健康监控，但不被选来放置条带块。
+// 混合模式的本地驱动器由RAID引擎直接写入完整副本，不属于阵列
+func (rs *RAIDScheduler) SetReplicaOnly(driverName string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if _, ok := rs.metrics[driverName]; !ok {
+		return fmt.Errorf("驱动器不存在: %s", driverName)
+	}
+	rs.replicaOnly[driverName] = true
+	return nil
+}
+
+// 排空中的驱动器，按名称排序
+func (rs *RAIDScheduler) Draining() []string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	names := make([]string, 0, len(rs.draining))
+	for name := range rs.draining {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
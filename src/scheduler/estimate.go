@@ -0,0 +1,30 @@
+package scheduler
+
+import "time"
+
+// 估算从驱动器下载bytes字节所需的时间，用于选择读取来源（实现raid.ReadEstimator）。
+// 探测过带宽的驱动器按延迟加传输时间估算，否则按最近操作的平均耗时；
+// 已占满传输槽位的驱动器需要排队，成功率低的驱动器可能需要重试，都按比例放大。驱动器不存在时ok为false
+func (rs *RAIDScheduler) EstimateRead(driverName string, bytes int64) (estimate time.Duration, ok bool) {
+	rs.mu.RLock()
+	metric := rs.metrics[driverName]
+	if metric == nil {
+		rs.mu.RUnlock()
+		return 0, false
+	}
+	estimate = metric.AvgLatency
+	if metric.DownloadBandwidth > 0 {
+		estimate += time.Duration(float64(bytes) / metric.DownloadBandwidth * float64(time.Second))
+	}
+	successRate := metric.SuccessRate
+	rs.mu.RUnlock()
+
+	active, limit := rs.slots.load(driverName)
+	if limit > 0 {
+		estimate += estimate * time.Duration(active) / time.Duration(limit)
+	}
+	if successRate < 1 {
+		estimate = time.Duration(float64(estimate) / max(successRate, 0.01))
+	}
+	return estimate, true
+}
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 一个驱动器在调度决策中的情况
+type DriverExplanation struct {
+	Driver    string
+	Score     float64 // 按当前评分策略的评分
+	Metrics   DriverMetrics
+	Remaining int64    // 可用于规划的剩余空间（扣除预留和容量预算），-1表示未知
+	Reasons   []string // 不被选择或降低优先级的原因
+	Bytes     int64    // 按计划放置在该驱动器上的字节数
+}
+
+// 调度决策的说明，由Explain给出
+type Explanation struct {
+	FileName  string
+	FileSize  int64
+	RAIDLevel int
+	Mode      string
+	Strategy  string              // 评分策略，未设置时为空
+	Drivers   []DriverExplanation // 按评分从高到低排列
+	Plan      *PlacementPlan      // 规划失败时为nil
+	Err       error               // 规划失败的原因
+}
+
+// 不上传也不预留空间，说明写入一个size字节的文件时会选择哪些驱动器以及其余驱动器不被选择的原因。
+// 原因按第一个条带的选择过程给出（RAID5的校验块等在各条带间轮转，放置的字节数以完整的计划为准）
+func (rs *RAIDScheduler) Explain(fileName string, size int64, raidLevel int) *Explanation {
+	rs.refreshUsage()
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	rs.reservations.mu.Lock()
+	defer rs.reservations.mu.Unlock()
+
+	ex := &Explanation{FileName: fileName, FileSize: size, RAIDLevel: raidLevel, Mode: rs.mode, Strategy: rs.strategyName}
+	if ex.Mode == "" {
+		ex.Mode = ModePerformance
+	}
+	ex.Plan, ex.Err = rs.planFileLocked(fileName, size, raidLevel)
+
+	space := rs.spaceLocked()
+	reasons := make(map[string][]string, len(rs.metrics))
+	note := func(name, format string, args ...interface{}) {
+		reasons[name] = append(reasons[name], fmt.Sprintf(format, args...))
+	}
+
+	stripeLen := size
+	if rs.stripeSize > 0 && rs.stripeSize < size {
+		stripeLen = rs.stripeSize
+	}
+	need := maxStripShare(raidLevel, stripeLen)
+
+	// 与selectLocked的筛选顺序一致
+	now := time.Now()
+	closed := rs.closedForUploadLocked(now)
+	for _, name := range closed {
+		if _, next := rs.windowOpenLocked(name, true, now); !next.IsZero() {
+			note(name, "不在上传时间段内（%s开始），其他驱动器不够时才使用", next.Format("01-02 15:04"))
+		} else {
+			note(name, "没有可用的上传时间段")
+		}
+	}
+	for _, name := range space.exhausted {
+		note(name, "已用完容量预算")
+	}
+	for name := range rs.metrics {
+		if reason := rs.unavailableReason(name, need, space.remaining); reason != "" {
+			note(name, "%s", reason)
+		}
+	}
+
+	candidates := rs.getAvailableDrivers(closed, need, space.remaining)
+	if pin := rs.affinity.pinFor(fileName); pin != nil {
+		pinned := rs.applyAffinityLocked(fileName, candidates)
+		for _, name := range missing(candidates, pinned) {
+			note(name, "文件匹配固定规则 %q，只使用 %s", pin.Pattern, strings.Join(pin.Drivers, ", "))
+		}
+		candidates = pinned
+	}
+	spread := rs.spreadCandidatesLocked(raidLevel, candidates)
+	for _, name := range missing(candidates, spread) {
+		for _, kept := range spread {
+			if rs.domains.shared(name, kept) {
+				note(name, "与 %s 属于同一故障域，同一故障域只使用评分最高的驱动器", kept)
+				break
+			}
+		}
+	}
+	regular, archives := rs.splitArchives(spread)
+	if raidLevel != 5 {
+		for _, name := range archives {
+			note(name, "归档驱动器只存放RAID5的校验块")
+		}
+	}
+	if rs.mode != ModeCost {
+		roomy := rs.preferRoomy(append([]string(nil), regular...), space.remaining, minDriversFor(raidLevel))
+		for _, name := range missing(regular, roomy) {
+			note(name, "即将写满（剩余空间不到最空的驱动器的%.0f%%），其他驱动器不够时才使用", fillingRatio*100)
+		}
+	}
+
+	for name, metric := range rs.metrics {
+		d := DriverExplanation{
+			Driver:    name,
+			Score:     rs.calculateScore(name),
+			Metrics:   rs.metricLocked(metric),
+			Remaining: space.remaining[name],
+			Reasons:   reasons[name],
+		}
+		if ex.Plan != nil {
+			d.Bytes = ex.Plan.DriverBytes[name]
+		}
+		if d.Bytes == 0 && len(d.Reasons) == 0 && ex.Plan != nil {
+			d.Reasons = []string{"可用，但评分或费用排在选中的驱动器之后"}
+		}
+		ex.Drivers = append(ex.Drivers, d)
+	}
+	sort.Slice(ex.Drivers, func(i, j int) bool {
+		if ex.Drivers[i].Score != ex.Drivers[j].Score {
+			return ex.Drivers[i].Score > ex.Drivers[j].Score
+		}
+		return ex.Drivers[i].Driver < ex.Drivers[j].Driver
+	})
+	return ex
+}
+
+// 在before中但不在after中的驱动器
+func missing(before, after []string) []string {
+	kept := make(map[string]bool, len(after))
+	for _, name := range after {
+		kept[name] = true
+	}
+	var removed []string
+	for _, name := range before {
+		if !kept[name] {
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
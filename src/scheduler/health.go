@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+)
+
+// 健康监控给出的驱动器状态，与元数据中DriverInfo.Health的取值一致
+const (
+	HealthHealthy  = "healthy"  // 正常参与调度
+	HealthDegraded = "degraded" // 可以访问，但最近出错或成功率过低，暂不用于放置新数据
+	HealthFailed   = "failed"   // 最近一次健康探测失败
+)
+
+// 驱动器健康状态的接收方，由元数据层实现（MetadataManager.UpdateDriverHealth），
+// 用于持久化驱动器状态并发布状态变化事件
+type HealthReporter interface {
+	UpdateDriverHealth(driverName, health string, usedSpace, totalSpace int64)
+}
+
+// 设置健康状态的接收方。之后的第一次健康检查报告所有驱动器的状态，此后只报告状态的变化
+func (rs *RAIDScheduler) SetHealthReporter(reporter HealthReporter) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.healthReporter = reporter
+	for _, metric := range rs.metrics {
+		metric.Health = ""
+	}
+}
+
+// 驱动器不够健康、暂不用于放置新数据的原因：成功率不高于80%，或5分钟内出错。健康时返回空
+func degradedReason(metric *DriverMetrics) string {
+	if metric.SuccessRate <= 0.8 {
+		return fmt.Sprintf("成功率 %.0f%% 不高于80%%", metric.SuccessRate*100)
+	}
+	if since := time.Since(metric.LastErrorTime); since <= 5*time.Minute {
+		return fmt.Sprintf("%v前出错，5分钟内不使用", since.Round(time.Second))
+	}
+	return ""
+}
+
+// 按本次健康探测的结果更新驱动器的状态，状态变化时返回true。调用方需持有rs.mu
+func (rs *RAIDScheduler) updateHealthLocked(metric *DriverMetrics, pingErr error) bool {
+	health := HealthHealthy
+	switch {
+	case pingErr != nil:
+		health = HealthFailed
+	case degradedReason(metric) != "":
+		health = HealthDegraded
+	}
+	changed := metric.Health != health
+	metric.Health = health
+	return changed
+}
@@ -0,0 +1,30 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 设置对冲读取的等待时间：从RAID1/RAID10的副本或RAID5的数据块读取超过该时间仍未完成时，
+// 同时从另一个副本读取或读取校验块用于恢复，使用先完成的结果。0表示不对冲
+func (rs *RAIDScheduler) SetHedgeDelay(delay time.Duration) error {
+	if delay < 0 {
+		return fmt.Errorf("对冲读取的等待时间不能为负数: %v", delay)
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.hedgeDelay = delay
+	return nil
+}
+
+// 从驱动器读取时发起对冲读取前的等待时间。对冲读取会多占用传输槽位和下载流量，
+// 只用于用户发起的读取，重建和巡检等后台任务不对冲
+func (rs *RAIDScheduler) HedgeDelay(ctx context.Context, driverName string) time.Duration {
+	if PriorityFrom(ctx) < PriorityInteractive {
+		return 0
+	}
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.hedgeDelay
+}
@@ -0,0 +1,312 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"panmatrix/jobs"
+)
+
+// 操作的优先级，数值越大越优先
+type Priority int
+
+const (
+	PriorityScrub       Priority = iota // 一致性检查等巡检任务
+	PriorityRebuild                     // 重建、迁移、重新条带化等后台任务
+	PriorityInteractive                 // 用户发起的上传和下载（未标记优先级时的默认值）
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityScrub:
+		return "scrub"
+	case PriorityRebuild:
+		return "rebuild"
+	case PriorityInteractive:
+		return "interactive"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+const (
+	// 每个驱动器同时进行的传输数
+	defaultTransferSlots = 4
+
+	// 距离截止时间不足该时长时按最高优先级调度
+	defaultDeadlineEscalation = 30 * time.Second
+)
+
+type (
+	priorityKey struct{}
+	deadlineKey struct{}
+)
+
+// 为ctx中的操作标记优先级
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// 为ctx中的操作设置希望完成的时间，临近该时间时按最高优先级调度。与context.WithDeadline不同，
+// 超过该时间后操作不会被取消。未设置时使用ctx本身的截止时间
+func WithDeadline(ctx context.Context, deadline time.Time) context.Context {
+	return context.WithValue(ctx, deadlineKey{}, deadline)
+}
+
+func deadlineFrom(ctx context.Context) (time.Time, bool) {
+	if deadline, ok := ctx.Value(deadlineKey{}).(time.Time); ok {
+		return deadline, true
+	}
+	return ctx.Deadline()
+}
+
+// ctx中标记的优先级，未标记时为PriorityInteractive
+func PriorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityInteractive
+}
+
+// 等待传输槽位的操作
+type slotWaiter struct {
+	driver   string
+	base     Priority
+	deadline time.Time // 零值表示没有截止时间
+	seq      uint64
+	granted  Priority // 取得槽位时的优先级
+	ready    chan struct{}
+}
+
+// 各驱动器的传输槽位。高优先级的操作在排队或进行中时，低优先级的操作不取得新的槽位，
+// 把驱动器和带宽让给高优先级的操作；已经开始的传输不会被中断
+type transferSlots struct {
+	mu         sync.Mutex
+	limit      int
+	limits     map[string]int  // 单独配置了并发数的驱动器
+	tuned      map[string]int  // 自适应并发调整后的并发数，开启时优先于配置
+	saturated  map[string]bool // 调整周期内因槽位用满而有操作排队的驱动器
+	escalation time.Duration
+	active     map[string]int // 驱动器 -> 进行中的传输数
+	running    map[Priority]int
+	waiters    []*slotWaiter
+	seq        uint64
+}
+
+func newTransferSlots() *transferSlots {
+	return &transferSlots{
+		limit:      defaultTransferSlots,
+		escalation: defaultDeadlineEscalation,
+		active:     make(map[string]int),
+		running:    make(map[Priority]int),
+	}
+}
+
+// 操作当前的优先级：临近截止时间时提升为最高优先级
+func (s *transferSlots) effective(w *slotWaiter, now time.Time) Priority {
+	if !w.deadline.IsZero() && w.deadline.Sub(now) <= s.escalation {
+		return PriorityInteractive
+	}
+	return w.base
+}
+
+// 驱动器同时进行的传输数上限，调用方需持有s.mu
+func (s *transferSlots) limitFor(driver string) int {
+	if limit, ok := s.tuned[driver]; ok {
+		return limit
+	}
+	return s.configuredLimit(driver)
+}
+
+// 配置的并发数，不考虑自适应调整。调用方需持有s.mu
+func (s *transferSlots) configuredLimit(driver string) int {
+	if limit, ok := s.limits[driver]; ok {
+		return limit
+	}
+	return s.limit
+}
+
+// 按当前优先级为排队的操作分配槽位，同一优先级先到先得。调用方需持有s.mu
+func (s *transferSlots) dispatchLocked() {
+	now := time.Now()
+	sort.SliceStable(s.waiters, func(i, j int) bool {
+		pi, pj := s.effective(s.waiters[i], now), s.effective(s.waiters[j], now)
+		if pi != pj {
+			return pi > pj
+		}
+		return s.waiters[i].seq < s.waiters[j].seq
+	})
+
+	top := PriorityScrub
+	for p, n := range s.running {
+		if n > 0 && p > top {
+			top = p
+		}
+	}
+	if len(s.waiters) > 0 {
+		top = max(top, s.effective(s.waiters[0], now))
+	}
+
+	waiting := s.waiters[:0]
+	for _, w := range s.waiters {
+		p := s.effective(w, now)
+		if p < top || s.active[w.driver] >= s.limitFor(w.driver) {
+			if p >= top && s.saturated != nil {
+				s.saturated[w.driver] = true
+			}
+			waiting = append(waiting, w)
+			continue
+		}
+		s.active[w.driver]++
+		s.running[p]++
+		w.granted = p
+		close(w.ready)
+	}
+	clear(s.waiters[len(waiting):])
+	s.waiters = waiting
+}
+
+// 驱动器进行中的传输数和并发上限
+func (s *transferSlots) load(driver string) (active, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active[driver], s.limitFor(driver)
+}
+
+func (s *transferSlots) remove(w *slotWaiter) bool {
+	for i, other := range s.waiters {
+		if other == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *transferSlots) release(w *slotWaiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[w.driver]--
+	s.running[w.granted]--
+	s.dispatchLocked()
+}
+
+// 设置每个驱动器同时进行的传输数和截止时间提升优先级的提前量，0表示使用默认值
+func (rs *RAIDScheduler) SetTransferLimits(slotsPerDriver int, escalation time.Duration) error {
+	if slotsPerDriver < 0 || escalation < 0 {
+		return fmt.Errorf("传输槽位数和截止时间提前量不能为负数: %d, %v", slotsPerDriver, escalation)
+	}
+	if slotsPerDriver == 0 {
+		slotsPerDriver = defaultTransferSlots
+	}
+	if escalation == 0 {
+		escalation = defaultDeadlineEscalation
+	}
+
+	s := rs.slots
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = slotsPerDriver
+	s.escalation = escalation
+	s.dispatchLocked()
+	return nil
+}
+
+// 为驱动器单独设置同时进行的传输数，替换之前的配置；未配置的驱动器使用SetTransferLimits的槽位数。
+// 慢的驱动器配置较小的并发数，使传输排队等待而不是同时挂起大量请求
+func (rs *RAIDScheduler) SetDriverConcurrency(limits map[string]int) error {
+	rs.mu.RLock()
+	for name, limit := range limits {
+		if _, ok := rs.metrics[name]; !ok {
+			rs.mu.RUnlock()
+			return fmt.Errorf("并发配置中的驱动器 %s 不存在", name)
+		}
+		if limit <= 0 {
+			rs.mu.RUnlock()
+			return fmt.Errorf("驱动器 %s 的并发数必须大于0: %d", name, limit)
+		}
+	}
+	rs.mu.RUnlock()
+
+	s := rs.slots
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = make(map[string]int, len(limits))
+	for name, limit := range limits {
+		s.limits[name] = limit
+	}
+	s.dispatchLocked()
+	return nil
+}
+
+// 取得驱动器的一个传输槽位，按ctx中的优先级（WithPriority）和截止时间（WithDeadline）排队。
+// 驱动器不在upload对应操作的可用时间段内时先等待到时间段开始，设置了账号限制时再取得账号的并发名额和请求令牌。
+// ctx属于已暂停的后台任务时先等待任务继续。传输完成后调用返回的release；ctx取消时放弃排队并返回ctx的错误
+func (rs *RAIDScheduler) AcquireTransfer(ctx context.Context, driverName string, upload bool) (func(), error) {
+	if err := jobs.Checkpoint(ctx); err != nil {
+		return nil, err
+	}
+	if err := rs.waitForWindow(ctx, driverName, upload); err != nil {
+		return nil, err
+	}
+
+	s := rs.slots
+	w := &slotWaiter{driver: driverName, base: PriorityFrom(ctx), ready: make(chan struct{})}
+	if deadline, ok := deadlineFrom(ctx); ok {
+		w.deadline = deadline
+	}
+
+	s.mu.Lock()
+	s.seq++
+	w.seq = s.seq
+	s.waiters = append(s.waiters, w)
+	s.dispatchLocked()
+	escalation := s.escalation
+	s.mu.Unlock()
+
+	// 临近截止时间时重新分配，使提升后的优先级生效
+	var escalate <-chan time.Time
+	if !w.deadline.IsZero() && w.base < PriorityInteractive {
+		timer := time.NewTimer(time.Until(w.deadline) - escalation)
+		defer timer.Stop()
+		escalate = timer.C
+	}
+
+	release := func() { s.release(w) }
+	for {
+		select {
+		case <-w.ready:
+			// 同一账号的其他驱动器也在传输时，取得槽位后还要等待账号的限制
+			releaseAccount, err := rs.acquireAccount(ctx, driverName)
+			if err != nil {
+				release()
+				return nil, err
+			}
+			return sync.OnceFunc(func() {
+				releaseAccount()
+				release()
+			}), nil
+		case <-escalate:
+			escalate = nil
+			s.mu.Lock()
+			s.dispatchLocked()
+			s.mu.Unlock()
+		case <-ctx.Done():
+			s.mu.Lock()
+			queued := s.remove(w)
+			if queued {
+				// 排在后面的操作可能因为该操作而等待
+				s.dispatchLocked()
+			}
+			s.mu.Unlock()
+			if !queued {
+				// 取消的同时已经取得槽位
+				release()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"panmatrix/drivers"
+)
+
+const (
+	// 默认的探测对象大小
+	defaultProbeSize = 1024 * 1024
+
+	// 单个驱动器一次探测的超时
+	probeTimeout = 2 * time.Minute
+
+	// 带宽的平滑因子，探测间隔较长，比延迟更看重新的测量值
+	bandwidthAlpha = 0.3
+)
+
+// 一个驱动器的探测结果
+type ProbeReport struct {
+	Driver  string
+	Result  drivers.ProbeResult
+	Err     error
+	Skipped string // 跳过探测的原因（如不在可用时间段内）
+}
+
+// 按interval定期探测所有驱动器的实际延迟和带宽，interval<=0时不探测。
+// 探测按巡检优先级取得传输槽位，不影响用户的上传和下载
+func (rs *RAIDScheduler) StartProbing(interval time.Duration, size int64) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			rs.ProbeDrivers(context.Background(), size)
+		}
+	}()
+}
+
+// 逐个探测所有驱动器，结果计入驱动器指标。size<=0时使用默认大小
+func (rs *RAIDScheduler) ProbeDrivers(ctx context.Context, size int64) []ProbeReport {
+	if size <= 0 {
+		size = defaultProbeSize
+	}
+	ctx = WithPriority(ctx, PriorityScrub)
+
+	rs.mu.RLock()
+	names := make([]string, 0, len(rs.drivers))
+	for name := range rs.drivers {
+		names = append(names, name)
+	}
+	rs.mu.RUnlock()
+	sort.Strings(names)
+
+	reports := make([]ProbeReport, 0, len(names))
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		reports = append(reports, rs.probeDriver(ctx, name, size))
+	}
+	return reports
+}
+
+func (rs *RAIDScheduler) probeDriver(ctx context.Context, name string, size int64) ProbeReport {
+	report := ProbeReport{Driver: name}
+
+	// 探测会上传和下载，不在任一方向的时间段内时跳过，而不是等待
+	now := time.Now()
+	rs.mu.RLock()
+	driver := rs.drivers[name]
+	uploadOpen, _ := rs.windowOpenLocked(name, true, now)
+	downloadOpen, _ := rs.windowOpenLocked(name, false, now)
+	rs.mu.RUnlock()
+	if !uploadOpen || !downloadOpen {
+		report.Skipped = "不在可用时间段内"
+		return report
+	}
+	if drivers.CapabilitiesOf(driver).Archive {
+		report.Skipped = "归档驱动器"
+		return report
+	}
+
+	release, err := rs.AcquireTransfer(ctx, name, true)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	report.Result, report.Err = drivers.Probe(probeCtx, driver, size)
+	cancel()
+	release()
+
+	if ctx.Err() == nil {
+		rs.recordProbe(name, report.Result, report.Err)
+	}
+	return report
+}
+
+// 将探测结果计入驱动器指标：延迟和带宽按指数加权移动平均更新，成功和失败计入操作统计
+func (rs *RAIDScheduler) recordProbe(name string, result drivers.ProbeResult, err error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	metric := rs.metrics[name]
+	if metric == nil {
+		return
+	}
+	metric.LastProbe = time.Now()
+	if err != nil {
+		rs.recordLocked(metric, false, 0)
+		return
+	}
+	updateLatency(metric, result.Latency)
+	rs.recordLocked(metric, true, result.Latency)
+	metric.UploadBandwidth = smoothBandwidth(metric.UploadBandwidth, result.UploadBandwidth)
+	metric.DownloadBandwidth = smoothBandwidth(metric.DownloadBandwidth, result.DownloadBandwidth)
+}
+
+func smoothBandwidth(old, sample float64) float64 {
+	if old == 0 {
+		return sample
+	}
+	return old*(1-bandwidthAlpha) + sample*bandwidthAlpha
+}
+
+// 所有驱动器当前指标的副本，按驱动器名排列
+func (rs *RAIDScheduler) Metrics() []DriverMetrics {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	metrics := make([]DriverMetrics, 0, len(rs.metrics))
+	for _, metric := range rs.metrics {
+		metrics = append(metrics, rs.metricLocked(metric))
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"sort"
 	"sync"
 	"time"
@@ -13,10 +14,22 @@ import (
 type DriverMetrics struct {
 	Name          string
 	AvgLatency    time.Duration // 平均延迟
-	SuccessRate   float64       // 成功率
-	CurrentLoad   int           // 当前负载
+	SuccessRate   float64       // 最近一段时间内的成功率
+	CurrentLoad   int           // 进行中的传输数
+	MaxLoad       int           // 同时进行的传输数上限，开启自适应并发时为调整后的值
 	AvailableSpace int64        // 可用空间
 	LastErrorTime time.Time     // 上次错误时间
+	Health        string        // 最近一次健康检查得出的状态（HealthHealthy等），尚未检查时为空
+	Capabilities  drivers.Capabilities
+	Cost          DriverCost // 费用参数，用于费用优先的调度
+	
+	// 主动探测测得的带宽（字节/秒，指数加权移动平均），0表示尚未探测
+	UploadBandwidth   float64
+	DownloadBandwidth float64
+	LastProbe         time.Time
+	
+	// 最近一段时间内的操作次数和耗时分位数
+	Operations OperationStats
 }
 
 // 智能RAID调度器
@@ -28,14 +41,74 @@ type RAIDScheduler struct {
 	// 调度策略
 	preferLowLatency bool
 	balanceLoad      bool
+	
+	// 空间预留
+	stripeSize   int64
+	reservations reservations
+	
+	// 容量预算，未设置时只按驱动器报告的可用空间规划
+	budget          CapacityBudget
+	budgetWarnRatio float64
+	
+	// 调度模式和费用估算参数
+	mode         string
+	monthlyReads float64
+	
+	// 按优先级分配的驱动器传输槽位
+	slots *transferSlots
+	
+	// 选择驱动器时在条带块之外至少保留的剩余空间
+	spaceMargin int64
+	
+	// 驱动器的可用时间段
+	windows map[string]*windowPolicy
+	
+	// 评分策略，未设置时按RAID级别使用原有的排序方式
+	strategy     Strategy
+	strategyName string
+	
+	// 亲和性规则和故障域
+	affinity *affinityRules
+	domains  *failureDomains
+	
+	// 对冲读取的等待时间，0表示不对冲
+	hedgeDelay time.Duration
+	
+	// 按数据量的准入控制
+	admission *admission
+	
+	// 各驱动器最近的操作统计
+	stats map[string]*opStats
+	
+	// 健康状态变化的接收方，未设置时只在调度器内部使用
+	healthReporter HealthReporter
+	
+	// 按账号共用的传输限制，键为驱动器名，同一账号的驱动器指向同一个Limiter
+	accountLimits map[string]*drivers.Limiter
+	
+	// 排空中的驱动器，不放置新数据
+	draining map[string]bool
+	
+	// 只保存副本、不参与条带放置的驱动器（混合模式的本地驱动器）
+	replicaOnly map[string]bool
+	
+	// 自适应并发，未开启时为nil
+	tuner *adaptiveTuner
 }
 
 func NewRAIDScheduler(drivers map[string]drivers.StorageDriver) *RAIDScheduler {
 	scheduler := &RAIDScheduler{
 		drivers: drivers,
 		metrics: make(map[string]*DriverMetrics),
+		stats:   make(map[string]*opStats),
+		draining: make(map[string]bool),
+		replicaOnly: make(map[string]bool),
 		preferLowLatency: true,
 		balanceLoad:      true,
+		reservations:     reservations{reserved: make(map[string]int64)},
+		slots:            newTransferSlots(),
+		spaceMargin:      defaultSpaceMargin,
+		admission:        newAdmission(),
 	}
 	
 	// 初始化指标
@@ -47,6 +120,7 @@ func NewRAIDScheduler(drivers map[string]drivers.StorageDriver) *RAIDScheduler {
 			CurrentLoad: 0,
 		}
 	}
+	scheduler.loadCapabilities()
 	
 	// 启动后台监控
 	go scheduler.monitorDrivers()
@@ -54,23 +128,56 @@ func NewRAIDScheduler(drivers map[string]drivers.StorageDriver) *RAIDScheduler {
 	return scheduler
 }
 
-// 为RAID条带选择最优的驱动器组合
-func (rs *RAIDScheduler) SelectDriversForStripe(raidLevel int, stripeIndex int, excludeDrivers []string) []string {
+// 为文件的RAID条带选择最优的驱动器组合，剩余空间放不下一个条带块（加上安全余量）和当前不在上传时间段内的
+// 驱动器不参与选择，并遵守故障域和亲和性规则（fileName为空时不使用文件固定规则）
+func (rs *RAIDScheduler) SelectDriversForStripe(fileName string, raidLevel int, stripeIndex int, excludeDrivers []string) []string {
 	rs.mu.RLock()
 	defer rs.mu.RUnlock()
 	
-	// 获取所有可用的驱动器
-	availableDrivers := rs.getAvailableDrivers(excludeDrivers)
+	excludeDrivers = append(rs.closedForUploadLocked(time.Now()), excludeDrivers...)
+	
+	rs.reservations.mu.Lock()
+	space := rs.spaceLocked()
+	rs.reservations.mu.Unlock()
+	
+	return rs.selectLocked(fileName, raidLevel, stripeIndex, rs.stripeSize, excludeDrivers, space.remaining)
+}
+
+// 调用方需持有rs.mu。remaining为各驱动器可用于规划的剩余空间，<0 表示未知（不限制）
+func (rs *RAIDScheduler) selectLocked(fileName string, raidLevel int, stripeIndex int, stripeLen int64, excludeDrivers []string, remaining map[string]int64) []string {
+	// 获取所有可用的驱动器，归档驱动器只用于存放RAID5的校验块
+	candidates := rs.getAvailableDrivers(excludeDrivers, maxStripShare(raidLevel, stripeLen), remaining)
+	candidates = rs.applyAffinityLocked(fileName, candidates)
+	candidates = rs.spreadCandidatesLocked(raidLevel, candidates)
+	availableDrivers, archives := rs.splitArchives(candidates)
+	
+	if rs.mode == ModeCost {
+		selected := rs.selectByCost(raidLevel, stripeIndex, availableDrivers, archives)
+		switch {
+		case raidLevel == 5 && len(archives) == 0:
+			selected = rs.preferParityLocked(selected, availableDrivers)
+		case raidLevel == 10:
+			selected = rs.pairByDomainLocked(selected, rs.sortDriversByScore(availableDrivers))
+		}
+		return selected
+	}
+	
+	// 剩余空间明显少于其他驱动器的驱动器只在其余驱动器不够时使用
+	availableDrivers = rs.preferRoomy(availableDrivers, remaining, minDriversFor(raidLevel))
 	
 	switch raidLevel {
 	case 0: // RAID0
 		return rs.selectForRAID0(availableDrivers)
 	case 1: // RAID1
 		return rs.selectForRAID1(availableDrivers)
-	case 5: // RAID5
-		return rs.selectForRAID5(availableDrivers, stripeIndex)
-	case 10: // RAID10
-		return rs.selectForRAID10(availableDrivers)
+	case 5: // RAID5，有归档驱动器时校验块固定放在归档驱动器上
+		selected := rs.selectForRAID5(availableDrivers, archives, stripeIndex)
+		if len(archives) == 0 {
+			selected = rs.preferParityLocked(selected, availableDrivers)
+		}
+		return selected
+	case 10: // RAID10，镜像对的两个副本不在同一故障域
+		return rs.pairByDomainLocked(rs.selectForRAID10(availableDrivers), availableDrivers)
 	default:
 		return availableDrivers[:min(4, len(availableDrivers))] // 默认选择前4个
 	}
@@ -97,7 +204,14 @@ func (rs *RAIDScheduler) selectForRAID1(availableDrivers []string) []string {
 }
 
 // RAID5选择：考虑奇偶校验轮转
-func (rs *RAIDScheduler) selectForRAID5(availableDrivers []string, stripeIndex int) []string {
+func (rs *RAIDScheduler) selectForRAID5(availableDrivers, archives []string, stripeIndex int) []string {
+	// 有归档驱动器时校验块固定放在归档驱动器上（列表末尾）
+	if len(archives) > 0 {
+		sorted := rs.sortDriversByScore(availableDrivers)
+		selected := append([]string(nil), sorted[:min(4, len(sorted))]...)
+		return append(selected, archives[0])
+	}
+	
 	// 需要至少3个驱动器
 	if len(availableDrivers) < 3 {
 		return availableDrivers
@@ -140,8 +254,12 @@ func (rs *RAIDScheduler) selectForRAID10(availableDrivers []string) []string {
 	return sorted[:count]
 }
 
-// 根据性能排序
+// 根据性能排序，设置了评分策略时按策略评分
 func (rs *RAIDScheduler) sortDriversByPerformance(drivers []string) []string {
+	if rs.strategy != nil {
+		return rs.sortDriversByScore(drivers)
+	}
+	
 	sort.Slice(drivers, func(i, j int) bool {
 		mi := rs.metrics[drivers[i]]
 		mj := rs.metrics[drivers[j]]
@@ -158,8 +276,12 @@ func (rs *RAIDScheduler) sortDriversByPerformance(drivers []string) []string {
 	return drivers
 }
 
-// 根据可靠性排序
+// 根据可靠性排序，设置了评分策略时按策略评分
 func (rs *RAIDScheduler) sortDriversByReliability(drivers []string) []string {
+	if rs.strategy != nil {
+		return rs.sortDriversByScore(drivers)
+	}
+	
 	sort.Slice(drivers, func(i, j int) bool {
 		mi := rs.metrics[drivers[i]]
 		mj := rs.metrics[drivers[j]]
@@ -185,51 +307,33 @@ func (rs *RAIDScheduler) sortDriversByScore(drivers []string) []string {
 	return drivers
 }
 
-// 计算驱动器综合评分
+// 计算驱动器综合评分，未设置评分策略时按balanced策略
 func (rs *RAIDScheduler) calculateScore(driverName string) float64 {
 	metric := rs.metrics[driverName]
 	if metric == nil {
 		return 0
 	}
 	
-	score := 0.0
-	
-	// 延迟评分（越低越好）
-	latencyScore := 1.0 / (float64(metric.AvgLatency.Milliseconds()) + 1)
-	score += latencyScore * 0.3
-	
-	// 成功率评分
-	score += metric.SuccessRate * 0.4
-	
-	// 负载评分（负载越低越好）
-	loadScore := 1.0 / (float64(metric.CurrentLoad) + 1)
-	score += loadScore * 0.2
-	
-	// 空间评分（可用空间越多越好）
-	if metric.AvailableSpace > 0 {
-		spaceScore := float64(min(metric.AvailableSpace, 10*1024*1024*1024)) / (10 * 1024 * 1024 * 1024)
-		score += spaceScore * 0.1
+	strategy := rs.strategy
+	if strategy == nil {
+		strategy = weightedStrategy(strategyWeights[StrategyBalanced])
 	}
-	
-	return score
+	return strategy.Score(rs.metricLocked(metric), rs.scoreEnvLocked())
 }
 
-// 获取可用的驱动器列表（排除不健康的）
-func (rs *RAIDScheduler) getAvailableDrivers(excludeDrivers []string) []string {
+// 获取可用的驱动器列表（排除不健康的和剩余空间放不下need字节加安全余量的）
+func (rs *RAIDScheduler) getAvailableDrivers(excludeDrivers []string, need int64, remaining map[string]int64) []string {
 	excludeMap := make(map[string]bool)
 	for _, name := range excludeDrivers {
 		excludeMap[name] = true
 	}
 	
 	var available []string
-	for name, metric := range rs.metrics {
+	for name := range rs.metrics {
 		if excludeMap[name] {
 			continue
 		}
-		
-		// 检查驱动器健康状态
-		if metric.SuccessRate > 0.8 && // 成功率高于80%
-			time.Since(metric.LastErrorTime) > 5*time.Minute { // 5分钟内无错误
+		if rs.unavailableReason(name, need, remaining) == "" {
 			available = append(available, name)
 		}
 	}
@@ -237,6 +341,27 @@ func (rs *RAIDScheduler) getAvailableDrivers(excludeDrivers []string) []string {
 	return available
 }
 
+// 驱动器不能放置need字节的条带块的原因，可以放置时返回空
+func (rs *RAIDScheduler) unavailableReason(name string, need int64, remaining map[string]int64) string {
+	metric := rs.metrics[name]
+	
+	if rs.draining[name] {
+		return "正在排空，不放置新数据"
+	}
+	if rs.replicaOnly[name] {
+		return "只保存本地副本，不参与条带放置"
+	}
+	
+	// 检查剩余空间
+	if left, ok := remaining[name]; ok && left >= 0 && left < need+rs.spaceMargin {
+		return fmt.Sprintf("剩余空间 %.2f MB 放不下条带块和安全余量（%.2f MB）",
+			float64(left)/(1024*1024), float64(need+rs.spaceMargin)/(1024*1024))
+	}
+	
+	// 检查驱动器健康状态：成功率高于80%，5分钟内无错误
+	return degradedReason(metric)
+}
+
 // 记录操作结果，更新指标
 func (rs *RAIDScheduler) RecordOperation(driverName string, success bool, latency time.Duration) {
 	rs.mu.Lock()
@@ -248,31 +373,13 @@ func (rs *RAIDScheduler) RecordOperation(driverName string, success bool, latenc
 	}
 	
 	// 更新延迟（指数加权移动平均）
-	if metric.AvgLatency == 0 {
-		metric.AvgLatency = latency
-	} else {
-		alpha := 0.1 // 平滑因子
-		metric.AvgLatency = time.Duration(
-			float64(metric.AvgLatency)*(1-alpha) + float64(latency)*alpha,
-		)
-	}
+	updateLatency(metric, latency)
 	
-	// 更新成功率
-	totalOps := 100 // 假设跟踪最近100次操作
-	successCount := int(metric.SuccessRate * float64(totalOps))
-	if success {
-		successCount = min(successCount+1, totalOps)
-	} else {
-		successCount = max(successCount-1, 0)
-		metric.LastErrorTime = time.Now()
-	}
-	metric.SuccessRate = float64(successCount) / float64(totalOps)
+	// 按时间窗口内的操作更新成功率和延迟分位数
+	rs.recordLocked(metric, success, latency)
 	
-	// 更新负载
-	if success {
-		metric.CurrentLoad = max(metric.CurrentLoad-1, 0)
-	} else {
-		metric.CurrentLoad = min(metric.CurrentLoad+1, 100)
+	if rs.tuner != nil {
+		rs.tuner.observe(driverName, success)
 	}
 }
 
@@ -286,49 +393,112 @@ func (rs *RAIDScheduler) monitorDrivers() {
 	}
 }
 
+// 将驱动器分为普通驱动器和归档驱动器
+func (rs *RAIDScheduler) splitArchives(names []string) ([]string, []string) {
+	var regular, archives []string
+	for _, name := range names {
+		if metric, ok := rs.metrics[name]; ok && metric.Capabilities.Archive {
+			archives = append(archives, name)
+		} else {
+			regular = append(regular, name)
+		}
+	}
+	return regular, archives
+}
+
+// 记录各驱动器的能力，驱动器能力在运行期间不变
+func (rs *RAIDScheduler) loadCapabilities() {
+	for name, driver := range rs.drivers {
+		rs.metrics[name].Capabilities = drivers.CapabilitiesOf(driver)
+	}
+}
+
+// 驱动器能力，驱动器不存在时返回false
+func (rs *RAIDScheduler) Capabilities(driverName string) (drivers.Capabilities, bool) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	metric, ok := rs.metrics[driverName]
+	if !ok {
+		return drivers.Capabilities{}, false
+	}
+	return metric.Capabilities, true
+}
+
+// 单个驱动器健康探测的超时
+const healthProbeTimeout = 10 * time.Second
+
+// 立即对所有驱动器做一次健康探测并刷新空间信息，后台监控每30秒自动进行
+func (rs *RAIDScheduler) CheckHealth() {
+	rs.checkDriverHealth()
+}
+
 func (rs *RAIDScheduler) checkDriverHealth() {
-	rs.mu.Lock()
-	defer rs.mu.Unlock()
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	
+	rs.mu.RLock()
+	snapshot := make(map[string]drivers.StorageDriver, len(rs.drivers))
 	for name, driver := range rs.drivers {
-		start := time.Now()
-		
-		// 检查驱动器是否可用
-		available := driver.IsAvailable()
-		latency := time.Since(start)
-		
+		snapshot[name] = driver
+	}
+	rs.mu.RUnlock()
+
+	// 远程探测不持有锁，避免慢速驱动器阻塞调度
+	type healthChange struct {
+		name, health string
+		used, total  int64
+	}
+	var changes []healthChange
+	for name, driver := range snapshot {
+		ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+		latency, err := drivers.Ping(ctx, driver)
+		cancel()
+
+		// 获取空间信息
+		used, total, usageErr := driver.GetUsage()
+
+		rs.mu.Lock()
 		metric := rs.metrics[name]
 		if metric == nil {
 			metric = &DriverMetrics{Name: name}
 			rs.metrics[name] = metric
 		}
-		
-		if !available {
-			metric.SuccessRate = max(metric.SuccessRate-0.1, 0)
-			metric.LastErrorTime = time.Now()
+
+		if err != nil {
+			rs.recordLocked(metric, false, 0)
+		} else {
+			updateLatency(metric, latency)
+			rs.recordLocked(metric, true, latency)
 		}
-		
-		// 获取空间信息
-		used, total, err := driver.GetUsage()
-		if err == nil {
+
+		if usageErr == nil {
 			metric.AvailableSpace = total - used
 		}
+		if rs.updateHealthLocked(metric, err) {
+			changes = append(changes, healthChange{name: name, health: metric.Health, used: used, total: total})
+		}
+		rs.mu.Unlock()
 	}
-}
 
-func min(a, b int) int {
-	if a < b {
-		return a
+	// 状态变化在探测完所有驱动器后统一报告，不在持有锁时调用元数据层
+	rs.mu.RLock()
+	reporter := rs.healthReporter
+	rs.mu.RUnlock()
+	if reporter == nil {
+		return
+	}
+	for _, c := range changes {
+		reporter.UpdateDriverHealth(c.name, c.health, c.used, c.total)
 	}
-	return b
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
+// 指数加权移动平均更新延迟
+func updateLatency(metric *DriverMetrics, latency time.Duration) {
+	if metric.AvgLatency == 0 {
+		metric.AvgLatency = latency
+		return
 	}
-	return b
+	
+	alpha := 0.1 // 平滑因子
+	metric.AvgLatency = time.Duration(
+		float64(metric.AvgLatency)*(1-alpha) + float64(latency)*alpha,
+	)
 }
@@ -0,0 +1,413 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 单个条带块的放置计划
+type StripPlacement struct {
+	StripeIndex int
+	StripIndex  int
+	DriverName  string
+	Size        int64
+	IsParity    bool
+}
+
+// 文件放置计划，在上传任何数据前根据驱动器剩余空间预先规划
+type PlacementPlan struct {
+	ID          int64
+	FileSize    int64
+	RAIDLevel   int
+	StripeSize  int64
+	Placements  []StripPlacement
+	DriverBytes map[string]int64 // 每个驱动器需要占用的空间
+	Warnings    []string         // 上传后接近容量预算的驱动器
+	MonthlyCost float64          // 按驱动器的费用参数估算的每月费用
+
+	released bool
+}
+
+// 条带使用的驱动器，按计划中块的顺序排列（RAID5的校验块在末尾，RAID10的镜像对相邻），
+// 供RAID引擎按计划写入。计划中没有该条带时返回nil
+func (p *PlacementPlan) StripeDrivers(stripeIndex int) []string {
+	// 放置按条带序号排列
+	i := sort.Search(len(p.Placements), func(i int) bool { return p.Placements[i].StripeIndex >= stripeIndex })
+	var names []string
+	for ; i < len(p.Placements) && p.Placements[i].StripeIndex == stripeIndex; i++ {
+		names = append(names, p.Placements[i].DriverName)
+	}
+	return names
+}
+
+// 空间预留状态
+type reservations struct {
+	nextID   int64
+	reserved map[string]int64 // 驱动器 -> 已预留但尚未写入的字节数
+	mu       sync.Mutex
+}
+
+// 驱动器容量预算，由元数据层按PanMatrix已放置的字节数提供
+type CapacityBudget interface {
+	// 驱动器的预算和已放置的字节数，未设置预算时ok为false
+	DriverBudget(driverName string) (budget, placed int64, ok bool)
+}
+
+// 设置容量预算：超出预算的驱动器不参与规划，上传后超过预算的warnRatio时在计划中给出警告
+func (rs *RAIDScheduler) SetCapacityBudget(budget CapacityBudget, warnRatio float64) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.budget = budget
+	rs.budgetWarnRatio = warnRatio
+}
+
+// 设置规划时使用的条带大小
+func (rs *RAIDScheduler) SetStripeSize(stripeSize int64) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.stripeSize = stripeSize
+}
+
+// 为即将上传的文件规划条带放置并预留空间，没有满足配额和亲和性规则的放置方案时返回错误
+func (rs *RAIDScheduler) ReserveFile(fileName string, size int64, raidLevel int) (*PlacementPlan, error) {
+	if size < 0 {
+		return nil, errors.New("文件大小不能为负数")
+	}
+
+	// 先刷新驱动器空间信息，避免使用过期的配额
+	rs.refreshUsage()
+
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	rs.reservations.mu.Lock()
+	defer rs.reservations.mu.Unlock()
+
+	plan, err := rs.planFileLocked(fileName, size, raidLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	rs.reservations.nextID++
+	plan.ID = rs.reservations.nextID
+	for name, bytes := range plan.DriverBytes {
+		rs.reservations.reserved[name] += bytes
+	}
+
+	return plan, nil
+}
+
+// 按当前的空间和预留规划文件的条带放置，不预留空间。调用方需持有rs.mu和rs.reservations.mu
+func (rs *RAIDScheduler) planFileLocked(fileName string, size int64, raidLevel int) (*PlacementPlan, error) {
+	if rs.stripeSize <= 0 {
+		return nil, errors.New("未设置条带大小")
+	}
+
+	space := rs.spaceLocked()
+	remaining := space.remaining
+
+	// 不在上传时间段内的驱动器先不使用；其余驱动器不够用时仍然规划到这些驱动器上，上传时等待时间段开始
+	now := time.Now()
+	closed := rs.closedForUploadLocked(now)
+
+	plan := &PlacementPlan{
+		FileSize:    size,
+		RAIDLevel:   raidLevel,
+		StripeSize:  rs.stripeSize,
+		DriverBytes: make(map[string]int64),
+	}
+
+	stripeCount := int((size + rs.stripeSize - 1) / rs.stripeSize)
+	for stripeIndex := 0; stripeIndex < stripeCount; stripeIndex++ {
+		stripeLen := rs.stripeSize
+		if left := size - int64(stripeIndex)*rs.stripeSize; left < stripeLen {
+			stripeLen = left
+		}
+
+		placements, err := rs.planStripe(fileName, raidLevel, stripeIndex, stripeLen, remaining, closed)
+		if err != nil && len(closed) > 0 {
+			placements, err = rs.planStripe(fileName, raidLevel, stripeIndex, stripeLen, remaining, nil)
+		}
+		if err != nil {
+			if len(space.exhausted) > 0 {
+				sort.Strings(space.exhausted)
+				return nil, fmt.Errorf("条带%d无法放置: %v（已用完容量预算的驱动器: %s）", stripeIndex, err, strings.Join(space.exhausted, ", "))
+			}
+			return nil, fmt.Errorf("条带%d无法放置: %v", stripeIndex, err)
+		}
+
+		for _, p := range placements {
+			if remaining[p.DriverName] >= 0 {
+				remaining[p.DriverName] -= p.Size
+			}
+			plan.DriverBytes[p.DriverName] += p.Size
+		}
+		plan.Placements = append(plan.Placements, placements...)
+	}
+
+	for name, bytes := range plan.DriverBytes {
+		state, ok := space.budgets[name]
+		if !ok {
+			continue
+		}
+		if after := state.used + bytes; float64(after) > rs.budgetWarnRatio*float64(state.budget) {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("驱动器 %s 上传后将使用容量预算的 %.0f%%（%.2f/%.2f GB）",
+				name, float64(after)*100/float64(state.budget), float64(after)/(1<<30), float64(state.budget)/(1<<30)))
+		}
+	}
+	for _, name := range closed {
+		if plan.DriverBytes[name] == 0 {
+			continue
+		}
+		warning := fmt.Sprintf("驱动器 %s 当前不在上传时间段内", name)
+		if _, next := rs.windowOpenLocked(name, true, now); !next.IsZero() {
+			warning += fmt.Sprintf("，写入该驱动器的块将等到 %s 再上传", next.Format("01-02 15:04"))
+		}
+		plan.Warnings = append(plan.Warnings, warning)
+	}
+	sort.Strings(plan.Warnings)
+	plan.MonthlyCost = rs.placementsCostLocked(plan.Placements)
+	return plan, nil
+}
+
+type budgetState struct{ budget, used int64 }
+
+// 规划时各驱动器的空间状态
+type driverSpace struct {
+	remaining map[string]int64 // 可用于规划的剩余空间，<0 表示未知（不限制）
+	budgets   map[string]budgetState
+	exhausted []string // 已用完容量预算的驱动器
+}
+
+// 计算每个驱动器扣除已有预留后的可用空间；设置了容量预算时不超过预算内的剩余空间。
+// 调用方需持有rs.mu和rs.reservations.mu
+func (rs *RAIDScheduler) spaceLocked() driverSpace {
+	space := driverSpace{remaining: make(map[string]int64), budgets: make(map[string]budgetState)}
+	for name, metric := range rs.metrics {
+		if metric.AvailableSpace > 0 {
+			space.remaining[name] = metric.AvailableSpace - rs.reservations.reserved[name]
+		} else {
+			space.remaining[name] = -1
+		}
+		if rs.budget == nil {
+			continue
+		}
+		budget, placed, ok := rs.budget.DriverBudget(name)
+		if !ok {
+			continue
+		}
+		used := placed + rs.reservations.reserved[name]
+		space.budgets[name] = budgetState{budget, used}
+		left := max(budget-used, 0)
+		if left == 0 {
+			space.exhausted = append(space.exhausted, name)
+		}
+		if space.remaining[name] < 0 || left < space.remaining[name] {
+			space.remaining[name] = left
+		}
+	}
+	return space
+}
+
+const (
+	// 默认的安全余量：剩余空间放下条带块后至少还要留出的字节数，避免驱动器的用量统计滞后时写满
+	defaultSpaceMargin = 64 * 1024 * 1024
+
+	// 剩余空间不到最空的驱动器的该比例时，视为即将写满
+	fillingRatio = 0.1
+)
+
+// 设置选择驱动器时的安全余量（字节），0表示使用默认值
+func (rs *RAIDScheduler) SetSpaceMargin(margin int64) error {
+	if margin < 0 {
+		return fmt.Errorf("安全余量不能为负数: %d", margin)
+	}
+	if margin == 0 {
+		margin = defaultSpaceMargin
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.spaceMargin = margin
+	return nil
+}
+
+// 条带中单个驱动器最多承担的字节数（按RAID级别允许的最少驱动器数估算）
+func maxStripShare(raidLevel int, stripeLen int64) int64 {
+	switch raidLevel {
+	case 5, 10:
+		return (stripeLen + 1) / 2
+	default:
+		return stripeLen
+	}
+}
+
+// RAID级别需要的最少驱动器数
+func minDriversFor(raidLevel int) int {
+	switch raidLevel {
+	case 0, 1:
+		return 2
+	case 5:
+		return 3
+	case 10:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// 即将写满的驱动器（剩余空间已知且不到剩余空间最多的驱动器的fillingRatio）只在其余驱动器不足count个时
+// 按剩余空间从多到少补足，数据逐渐流向较空的驱动器
+func (rs *RAIDScheduler) preferRoomy(names []string, remaining map[string]int64, count int) []string {
+	var most int64
+	for _, name := range names {
+		most = max(most, remaining[name])
+	}
+	roomy := make([]string, 0, len(names))
+	var filling []string
+	for _, name := range names {
+		if left, ok := remaining[name]; ok && left >= 0 && float64(left) < fillingRatio*float64(most) {
+			filling = append(filling, name)
+			continue
+		}
+		roomy = append(roomy, name)
+	}
+	sort.Slice(filling, func(i, j int) bool { return remaining[filling[i]] > remaining[filling[j]] })
+	for _, name := range filling {
+		if len(roomy) >= count {
+			break
+		}
+		roomy = append(roomy, name)
+	}
+	return roomy
+}
+
+// 释放计划预留的空间（上传完成或放弃后调用）
+func (rs *RAIDScheduler) ReleaseReservation(plan *PlacementPlan) {
+	rs.reservations.mu.Lock()
+	defer rs.reservations.mu.Unlock()
+
+	if plan == nil || plan.released {
+		return
+	}
+	plan.released = true
+
+	for name, bytes := range plan.DriverBytes {
+		rs.reservations.reserved[name] -= bytes
+		if rs.reservations.reserved[name] <= 0 {
+			delete(rs.reservations.reserved, name)
+		}
+	}
+}
+
+// 为一个条带选择驱动器（不使用skip中的驱动器），空间不足的驱动器被排除后重新选择
+func (rs *RAIDScheduler) planStripe(fileName string, raidLevel, stripeIndex int, stripeLen int64, remaining map[string]int64, skip []string) ([]StripPlacement, error) {
+	exclude := append([]string(nil), skip...)
+	for {
+		selected := rs.selectLocked(fileName, raidLevel, stripeIndex, stripeLen, exclude, remaining)
+		placements, err := stripPlacements(raidLevel, stripeIndex, stripeLen, selected)
+		if err != nil {
+			return nil, err
+		}
+
+		// 同一驱动器在一个条带中可能放置多个块，按累计大小检查
+		need := make(map[string]int64)
+		for _, p := range placements {
+			need[p.DriverName] += p.Size
+		}
+
+		full := ""
+		for name, bytes := range need {
+			if remaining[name] >= 0 && remaining[name] < bytes {
+				full = name
+				break
+			}
+		}
+		if full == "" {
+			return placements, nil
+		}
+		exclude = append(exclude, full)
+	}
+}
+
+// 按RAID级别计算条带中每个块的大小，块的划分方式与RAID引擎一致
+func stripPlacements(raidLevel, stripeIndex int, stripeLen int64, selected []string) ([]StripPlacement, error) {
+	n := len(selected)
+	var placements []StripPlacement
+
+	switch raidLevel {
+	case 1:
+		if n < 2 {
+			return nil, errors.New("RAID1需要至少2个可用驱动器")
+		}
+		for i, name := range selected {
+			placements = append(placements, StripPlacement{stripeIndex, i, name, stripeLen, false})
+		}
+	case 5:
+		if n < 3 {
+			return nil, errors.New("RAID5需要至少3个可用驱动器")
+		}
+		// 调度器将校验块驱动器放在列表末尾
+		dataCount := int64(n - 1)
+		stripSize := stripeLen / dataCount
+		for i, name := range selected[:n-1] {
+			size := stripSize
+			if int64(i) == dataCount-1 {
+				size = stripeLen - stripSize*(dataCount-1)
+			}
+			placements = append(placements, StripPlacement{stripeIndex, i, name, size, false})
+		}
+		// 校验块与最大的数据块等长
+		paritySize := stripeLen - stripSize*(dataCount-1)
+		placements = append(placements, StripPlacement{stripeIndex, n - 1, selected[n-1], paritySize, true})
+	case 10:
+		if n < 4 {
+			return nil, errors.New("RAID10需要至少4个可用驱动器")
+		}
+		pairs := int64(n / 2)
+		stripSize := stripeLen / pairs
+		for i := int64(0); i < pairs; i++ {
+			size := stripSize
+			if i == pairs-1 {
+				size = stripeLen - stripSize*(pairs-1)
+			}
+			placements = append(placements,
+				StripPlacement{stripeIndex, int(i), selected[2*i], size, false},
+				StripPlacement{stripeIndex, int(i), selected[2*i+1], size, false})
+		}
+	default:
+		if n < 1 {
+			return nil, errors.New("没有可用的驱动器")
+		}
+		stripSize := (stripeLen + int64(n) - 1) / int64(n)
+		for i, name := range selected {
+			start := int64(i) * stripSize
+			if start >= stripeLen {
+				break
+			}
+			size := min(stripSize, stripeLen-start)
+			placements = append(placements, StripPlacement{stripeIndex, i, name, size, false})
+		}
+	}
+
+	return placements, nil
+}
+
+// 同步刷新所有驱动器的空间信息
+func (rs *RAIDScheduler) refreshUsage() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for name, driver := range rs.drivers {
+		used, total, err := driver.GetUsage()
+		if err != nil {
+			continue
+		}
+		if metric := rs.metrics[name]; metric != nil {
+			metric.AvailableSpace = total - used
+		}
+	}
+}
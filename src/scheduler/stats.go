@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// 成功率和延迟分位数统计的时间窗口，按statsBuckets个时间片滚动
+	StatsWindow  = 10 * time.Minute
+	statsBuckets = 20
+
+	// 计算成功率时预先计入的成功次数，窗口内样本很少时一两次失败不会使成功率骤降
+	successPrior = 5
+)
+
+// 延迟直方图的桶上限，最后一个桶收纳更慢的操作
+var latencyBounds = [...]time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+	30 * time.Second, time.Minute, 5 * time.Minute,
+}
+
+// 一个时间片内的计数
+type statsBucket struct {
+	start     time.Time
+	successes int64
+	failures  int64
+	latency   [len(latencyBounds) + 1]int64 // 成功操作的耗时分布
+}
+
+// 驱动器最近StatsWindow内的操作计数和延迟直方图
+type opStats struct {
+	buckets [statsBuckets]statsBucket
+}
+
+func (s *opStats) bucket(now time.Time) *statsBucket {
+	span := StatsWindow / statsBuckets
+	start := now.Truncate(span)
+	b := &s.buckets[int(start.UnixNano()/int64(span))%statsBuckets]
+	if !b.start.Equal(start) {
+		// 时间片已过期，重新开始计数
+		*b = statsBucket{start: start}
+	}
+	return b
+}
+
+func (s *opStats) record(now time.Time, success bool, latency time.Duration) {
+	b := s.bucket(now)
+	if !success {
+		b.failures++
+		return
+	}
+	b.successes++
+	b.latency[sort.Search(len(latencyBounds), func(i int) bool { return latency <= latencyBounds[i] })]++
+}
+
+// 最近一段时间内的操作统计
+type OperationStats struct {
+	Window      time.Duration
+	Successes   int64
+	Failures    int64
+	SuccessRate float64       // 窗口内没有操作时为1
+	P50         time.Duration // 成功操作耗时的分位数，没有成功的操作时为0
+	P95         time.Duration
+	P99         time.Duration
+}
+
+func (s *opStats) snapshot(now time.Time) OperationStats {
+	stats := OperationStats{Window: StatsWindow}
+	var latency [len(latencyBounds) + 1]int64
+	for i := range s.buckets {
+		b := &s.buckets[i]
+		if b.start.IsZero() || now.Sub(b.start) >= StatsWindow {
+			continue
+		}
+		stats.Successes += b.successes
+		stats.Failures += b.failures
+		for j, n := range b.latency {
+			latency[j] += n
+		}
+	}
+	stats.SuccessRate = float64(stats.Successes+successPrior) / float64(stats.Successes+stats.Failures+successPrior)
+	stats.P50 = percentile(latency[:], stats.Successes, 0.50)
+	stats.P95 = percentile(latency[:], stats.Successes, 0.95)
+	stats.P99 = percentile(latency[:], stats.Successes, 0.99)
+	return stats
+}
+
+// 按直方图估算分位数，在所在的桶内线性插值
+func percentile(counts []int64, total int64, q float64) time.Duration {
+	if total == 0 {
+		return 0
+	}
+	rank := q * float64(total)
+	var seen int64
+	for i, n := range counts {
+		if n == 0 || float64(seen+n) < rank {
+			seen += n
+			continue
+		}
+		var lower, upper time.Duration
+		if i > 0 {
+			lower = latencyBounds[i-1]
+		}
+		if i < len(latencyBounds) {
+			upper = latencyBounds[i]
+		} else {
+			// 超过最后一个上限的操作按上限的2倍估计
+			upper = 2 * latencyBounds[len(latencyBounds)-1]
+		}
+		frac := (rank - float64(seen)) / float64(n)
+		return lower + time.Duration(frac*float64(upper-lower))
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+// 记录一次操作并据此更新驱动器的成功率，调用方需持有rs.mu
+func (rs *RAIDScheduler) recordLocked(metric *DriverMetrics, success bool, latency time.Duration) {
+	now := time.Now()
+	stats := rs.stats[metric.Name]
+	if stats == nil {
+		stats = &opStats{}
+		rs.stats[metric.Name] = stats
+	}
+	stats.record(now, success, latency)
+	if !success {
+		metric.LastErrorTime = now
+	}
+	metric.Operations = stats.snapshot(now)
+	metric.SuccessRate = metric.Operations.SuccessRate
+}
+
+// 驱动器指标的副本，进行中的传输数、并发上限和操作统计取当前值。调用方需持有rs.mu
+func (rs *RAIDScheduler) metricLocked(metric *DriverMetrics) DriverMetrics {
+	m := *metric
+	m.CurrentLoad, m.MaxLoad = rs.slots.load(metric.Name)
+	if stats := rs.stats[metric.Name]; stats != nil {
+		m.Operations = stats.snapshot(time.Now())
+	} else {
+		m.Operations = OperationStats{Window: StatsWindow, SuccessRate: 1}
+	}
+	return m
+}
@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 内置的评分策略
+const (
+	StrategyPerformance = "performance" // 延迟低、带宽高优先
+	StrategyReliability = "reliability" // 成功率高优先
+	StrategyCost        = "cost"        // 费用低优先
+	StrategyBalanced    = "balanced"    // 原有的综合评分
+)
+
+// 驱动器评分策略，评分越高越优先选择
+type Strategy interface {
+	Score(m DriverMetrics, env ScoreEnv) float64
+}
+
+// 评分时所有驱动器的整体情况，用于把带宽和费用换算为0~1的分数
+type ScoreEnv struct {
+	MaxUploadBandwidth float64 // 所有驱动器中测得的最高上传带宽（字节/秒），0表示未探测
+	MaxUnitCost        float64 // 所有驱动器中每GB每月的最高费用（存储加估算的下载流量）
+	MonthlyReads       float64
+}
+
+// 各项评分的权重。每项先换算为0~1的分数：延迟1/(毫秒+1)、成功率、负载1/(负载+1)、
+// 可用空间（10GB封顶）、上传带宽（相对最快的驱动器）、费用（相对最贵的驱动器，越便宜越高）
+type ScoreWeights struct {
+	Latency     float64
+	SuccessRate float64
+	Load        float64
+	Space       float64
+	Bandwidth   float64
+	Cost        float64
+}
+
+// 内置策略的默认权重
+var strategyWeights = map[string]ScoreWeights{
+	StrategyPerformance: {Latency: 0.4, SuccessRate: 0.2, Load: 0.2, Bandwidth: 0.2},
+	StrategyReliability: {Latency: 0.1, SuccessRate: 0.7, Load: 0.1, Space: 0.1},
+	StrategyCost:        {SuccessRate: 0.3, Space: 0.1, Cost: 0.6},
+	StrategyBalanced:    {Latency: 0.3, SuccessRate: 0.4, Load: 0.2, Space: 0.1},
+}
+
+// 策略工厂，weights为内置策略的默认权重叠加配置中的weights（自定义策略没有默认权重）
+type StrategyFactory func(weights ScoreWeights) (Strategy, error)
+
+var (
+	strategies   = make(map[string]StrategyFactory)
+	strategiesMu sync.RWMutex
+)
+
+// 注册评分策略，通常在init中调用；重复注册同名策略会panic
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	if _, exists := strategies[name]; exists {
+		panic(fmt.Sprintf("调度策略 %s 重复注册", name))
+	}
+	strategies[name] = factory
+}
+
+// 已注册的策略（排序后）
+func RegisteredStrategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	for name := range strategyWeights {
+		RegisterStrategy(name, func(w ScoreWeights) (Strategy, error) { return weightedStrategy(w), nil })
+	}
+}
+
+// 按权重加权的评分
+type weightedStrategy ScoreWeights
+
+func (w weightedStrategy) Score(m DriverMetrics, env ScoreEnv) float64 {
+	return WeightedScore(ScoreWeights(w), m, env)
+}
+
+// 按权重计算驱动器的评分，自定义策略可以在此基础上调整
+func WeightedScore(w ScoreWeights, m DriverMetrics, env ScoreEnv) float64 {
+	score := 0.0
+
+	// 延迟评分（越低越好）
+	score += w.Latency / (float64(m.AvgLatency.Milliseconds()) + 1)
+
+	// 成功率评分
+	score += w.SuccessRate * m.SuccessRate
+
+	// 负载评分（负载越低越好）
+	score += w.Load / (float64(m.CurrentLoad) + 1)
+
+	// 空间评分（可用空间越多越好）
+	if m.AvailableSpace > 0 {
+		score += w.Space * float64(min(m.AvailableSpace, 10*1024*1024*1024)) / (10 * 1024 * 1024 * 1024)
+	}
+
+	// 带宽评分（相对最快的驱动器）
+	if env.MaxUploadBandwidth > 0 {
+		score += w.Bandwidth * m.UploadBandwidth / env.MaxUploadBandwidth
+	}
+
+	// 费用评分（越便宜越好），都未配置费用时所有驱动器得满分
+	if env.MaxUnitCost > 0 {
+		score += w.Cost * (1 - unitCost(m.Cost, env.MonthlyReads)/env.MaxUnitCost)
+	} else {
+		score += w.Cost
+	}
+
+	return score
+}
+
+// 每GB每月的费用：存储费用加上按monthly_reads估算的下载流量费用
+func unitCost(c DriverCost, monthlyReads float64) float64 {
+	return c.StoragePerGB + monthlyReads*c.EgressPerGB
+}
+
+// 评分权重的配置项
+var weightKeys = map[string]func(w *ScoreWeights) *float64{
+	"latency":      func(w *ScoreWeights) *float64 { return &w.Latency },
+	"success_rate": func(w *ScoreWeights) *float64 { return &w.SuccessRate },
+	"load":         func(w *ScoreWeights) *float64 { return &w.Load },
+	"space":        func(w *ScoreWeights) *float64 { return &w.Space },
+	"bandwidth":    func(w *ScoreWeights) *float64 { return &w.Bandwidth },
+	"cost":         func(w *ScoreWeights) *float64 { return &w.Cost },
+}
+
+// 设置评分策略，overrides覆盖策略默认权重中的对应项（如 latency: 0.5）。
+// name为空时恢复原有的选择方式：RAID0按性能、RAID1和RAID10按可靠性排序，RAID5按balanced评分
+func (rs *RAIDScheduler) SetStrategy(name string, overrides map[string]float64) error {
+	if name == "" {
+		if len(overrides) > 0 {
+			return fmt.Errorf("设置weights时需要同时指定strategy")
+		}
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		rs.strategy, rs.strategyName = nil, ""
+		return nil
+	}
+
+	strategiesMu.RLock()
+	factory, ok := strategies[name]
+	strategiesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("未知的调度策略: %s（可用: %v）", name, RegisteredStrategies())
+	}
+
+	weights := strategyWeights[name]
+	for key, value := range overrides {
+		field, ok := weightKeys[key]
+		if !ok {
+			return fmt.Errorf("未知的评分权重: %s", key)
+		}
+		if value < 0 {
+			return fmt.Errorf("评分权重 %s 不能为负数: %v", key, value)
+		}
+		*field(&weights) = value
+	}
+	strategy, err := factory(weights)
+	if err != nil {
+		return fmt.Errorf("创建调度策略 %s 失败: %v", name, err)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.strategy, rs.strategyName = strategy, name
+	return nil
+}
+
+// 当前的评分策略名，未设置时为空
+func (rs *RAIDScheduler) StrategyName() string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.strategyName
+}
+
+// 评分用的整体情况，调用方需持有rs.mu
+func (rs *RAIDScheduler) scoreEnvLocked() ScoreEnv {
+	env := ScoreEnv{MonthlyReads: rs.monthlyReads}
+	for _, metric := range rs.metrics {
+		env.MaxUploadBandwidth = max(env.MaxUploadBandwidth, metric.UploadBandwidth)
+		env.MaxUnitCost = max(env.MaxUnitCost, unitCost(metric.Cost, rs.monthlyReads))
+	}
+	return env
+}
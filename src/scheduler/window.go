@@ -0,0 +1,289 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 驱动器的可用时间段（scheduler段的windows），按本地时间计算。驱动器名为"*"的配置作用于
+// 没有单独配置的驱动器。时间段写作"23:00-07:00"，可以在前面加星期，如"mon-fri 09:00-18:00"、"sat,sun 00:00-24:00"
+//
+//	scheduler:
+//	  windows:
+//	    baidu-free:
+//	      allow: ["23:00-07:00"]          # 只在这些时间段使用，不配置表示全天可用
+//	    "*":
+//	      deny: ["mon-fri 09:00-18:00"]   # 这些时间段不使用
+//	      operations: upload              # 只限制上传，默认上传和下载都限制
+type WindowPolicy struct {
+	Allow      []string `yaml:"allow"`
+	Deny       []string `yaml:"deny"`
+	Operations string   `yaml:"operations"` // upload、download或all（默认）
+}
+
+// 时间段限制的操作
+const (
+	WindowOpsAll      = "all"
+	WindowOpsUpload   = "upload"
+	WindowOpsDownload = "download"
+)
+
+// 所有驱动器的默认时间段配置
+const windowDefaultDriver = "*"
+
+// 一个时间段，跨午夜的时间段属于开始的那一天
+type timeWindow struct {
+	days     [7]bool // 按time.Weekday索引
+	from, to int     // 一天中的分钟数，to可以为24:00
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func parseTimeWindow(s string) (timeWindow, error) {
+	var w timeWindow
+	fields := strings.Fields(strings.ToLower(s))
+	var spec string
+	switch len(fields) {
+	case 1:
+		for i := range w.days {
+			w.days[i] = true
+		}
+		spec = fields[0]
+	case 2:
+		if err := parseWeekdays(fields[0], &w.days); err != nil {
+			return w, fmt.Errorf("时间段 %q 无效: %v", s, err)
+		}
+		spec = fields[1]
+	default:
+		return w, fmt.Errorf("时间段 %q 无效", s)
+	}
+
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return w, fmt.Errorf("时间段 %q 无效: 应为 开始-结束", s)
+	}
+	var err error
+	if w.from, err = parseClock(from); err != nil {
+		return w, fmt.Errorf("时间段 %q 无效: %v", s, err)
+	}
+	if w.to, err = parseClock(to); err != nil {
+		return w, fmt.Errorf("时间段 %q 无效: %v", s, err)
+	}
+	if w.from == w.to || w.from == 24*60 {
+		return w, fmt.Errorf("时间段 %q 无效: 开始和结束时间相同", s)
+	}
+	return w, nil
+}
+
+// 解析"mon-fri"、"sat,sun"这样的星期列表
+func parseWeekdays(s string, days *[7]bool) error {
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, ok := weekdays[first]
+		if !ok {
+			return fmt.Errorf("未知的星期: %s", first)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdays[last]; !ok {
+				return fmt.Errorf("未知的星期: %s", last)
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// 解析"07:30"，返回一天中的分钟数
+func parseClock(s string) (int, error) {
+	hour, minute, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("时间 %q 应为 HH:MM", s)
+	}
+	h, err1 := strconv.Atoi(hour)
+	m, err2 := strconv.Atoi(minute)
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("时间 %q 无效", s)
+	}
+	return h*60 + m, nil
+}
+
+func (w timeWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.from < w.to {
+		return w.days[t.Weekday()] && minute >= w.from && minute < w.to
+	}
+	// 跨午夜：当天开始之后，或前一天开始、尚未结束
+	return (w.days[t.Weekday()] && minute >= w.from) || (w.days[(t.Weekday()+6)%7] && minute < w.to)
+}
+
+// 解析后的驱动器时间段配置
+type windowPolicy struct {
+	allow, deny []timeWindow
+	upload      bool // 是否限制上传
+	download    bool // 是否限制下载
+}
+
+func parseWindowPolicy(p WindowPolicy) (*windowPolicy, error) {
+	policy := &windowPolicy{}
+	switch p.Operations {
+	case "", WindowOpsAll:
+		policy.upload, policy.download = true, true
+	case WindowOpsUpload:
+		policy.upload = true
+	case WindowOpsDownload:
+		policy.download = true
+	default:
+		return nil, fmt.Errorf("未知的operations: %s", p.Operations)
+	}
+	for _, s := range p.Allow {
+		w, err := parseTimeWindow(s)
+		if err != nil {
+			return nil, err
+		}
+		policy.allow = append(policy.allow, w)
+	}
+	for _, s := range p.Deny {
+		w, err := parseTimeWindow(s)
+		if err != nil {
+			return nil, err
+		}
+		policy.deny = append(policy.deny, w)
+	}
+	return policy, nil
+}
+
+func (p *windowPolicy) applies(upload bool) bool {
+	return p != nil && (upload && p.upload || !upload && p.download)
+}
+
+// t时刻是否可用
+func (p *windowPolicy) open(t time.Time) bool {
+	allowed := len(p.allow) == 0
+	for _, w := range p.allow {
+		if w.contains(t) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+	for _, w := range p.deny {
+		if w.contains(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// t时刻不可用时，之后第一个可用的时刻。可用状态只在时间段的边界变化，逐一检查之后8天内的边界；
+// 没有可用时刻时返回false（如所有星期都被禁止）
+func (p *windowPolicy) nextOpen(t time.Time) (time.Time, bool) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	var boundaries []time.Time
+	for d := 0; d <= 8; d++ {
+		base := day.AddDate(0, 0, d)
+		for _, windows := range [][]timeWindow{p.allow, p.deny} {
+			for _, w := range windows {
+				for _, minute := range []int{w.from, w.to} {
+					if at := base.Add(time.Duration(minute) * time.Minute); at.After(t) {
+						boundaries = append(boundaries, at)
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+	for _, at := range boundaries {
+		if p.open(at) {
+			return at, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// 设置驱动器的可用时间段，替换之前的配置
+func (rs *RAIDScheduler) SetTimeWindows(windows map[string]WindowPolicy) error {
+	policies := make(map[string]*windowPolicy, len(windows))
+	for name, p := range windows {
+		policy, err := parseWindowPolicy(p)
+		if err != nil {
+			return fmt.Errorf("驱动器 %s 的时间段配置无效: %v", name, err)
+		}
+		policies[name] = policy
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.windows = policies
+	return nil
+}
+
+// 驱动器的时间段配置，调用方需持有rs.mu
+func (rs *RAIDScheduler) windowPolicyLocked(driverName string) *windowPolicy {
+	if policy, ok := rs.windows[driverName]; ok {
+		return policy
+	}
+	return rs.windows[windowDefaultDriver]
+}
+
+// 驱动器当前是否在可用时间段内，不可用时同时返回下一次可用的时刻（没有时为零值）
+func (rs *RAIDScheduler) DriverWindowOpen(driverName string, upload bool, now time.Time) (bool, time.Time) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.windowOpenLocked(driverName, upload, now)
+}
+
+func (rs *RAIDScheduler) windowOpenLocked(driverName string, upload bool, now time.Time) (bool, time.Time) {
+	policy := rs.windowPolicyLocked(driverName)
+	if !policy.applies(upload) || policy.open(now) {
+		return true, time.Time{}
+	}
+	next, _ := policy.nextOpen(now)
+	return false, next
+}
+
+// 当前不在上传时间段内的驱动器，调用方需持有rs.mu
+func (rs *RAIDScheduler) closedForUploadLocked(now time.Time) []string {
+	var closed []string
+	for name := range rs.metrics {
+		if open, _ := rs.windowOpenLocked(name, true, now); !open {
+			closed = append(closed, name)
+		}
+	}
+	sort.Strings(closed)
+	return closed
+}
+
+// 驱动器不在可用时间段内时等待到下一次可用，ctx取消时返回ctx的错误
+func (rs *RAIDScheduler) waitForWindow(ctx context.Context, driverName string, upload bool) error {
+	for {
+		open, next := rs.DriverWindowOpen(driverName, upload, time.Now())
+		if open {
+			return nil
+		}
+		if next.IsZero() {
+			return fmt.Errorf("驱动器 %s 没有可用的时间段", driverName)
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
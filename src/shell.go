@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"panmatrix/drivers"
+	"panmatrix/jobs"
+	"panmatrix/metadata"
+	"panmatrix/raid"
+	"panmatrix/scheduler"
+)
+
+// 保存的命令历史条数
+const shellHistoryLimit = 1000
+
+// 交互式命令行，不带操作参数运行时启动
+type shell struct {
+	rc             *raid.RAIDController
+	mm             *metadata.MetadataManager
+	ns             *metadata.Namespace
+	rs             *scheduler.RAIDScheduler
+	jm             *jobs.Manager
+	storageDrivers map[string]drivers.StorageDriver
+	driverTypes    map[string]string
+
+	historyPath string // 命令历史文件，为空时不保存
+	raidLevel   int    // upload未指定RAID级别时使用
+	outputPath  string // download未指定输出路径时使用
+
+	term *term.Terminal // 标准输入不是终端时为nil
+}
+
+// 参数的补全方式
+type shellArg int
+
+const (
+	argNone       shellArg = iota
+	argLocalPath           // 本地文件或目录
+	argRemotePath          // 虚拟路径（文件和目录）
+	argRemoteDir           // 虚拟目录
+	argJob                 // 任务ID或任务操作
+	argJobID               // 任务ID
+)
+
+type shellCommand struct {
+	name  string
+	usage string
+	help  string
+	args  []shellArg // 各位置参数的补全方式
+
+	// help和exit为nil，由命令循环处理
+	run func(s *shell, ctx context.Context, args []string) error
+}
+
+var shellCommands = []*shellCommand{
+	{name: "upload", usage: "upload <本地路径> [虚拟目录] [RAID级别]", help: "上传文件或目录（目录按原有结构上传）",
+		args: []shellArg{argLocalPath, argRemoteDir}, run: (*shell).upload},
+	{name: "download", usage: "download <文件ID|路径> [输出路径]", help: "下载文件",
+		args: []shellArg{argRemotePath, argLocalPath}, run: (*shell).download},
+	{name: "ls", usage: "ls [虚拟目录]", help: "列出目录中的子目录和文件",
+		args: []shellArg{argRemoteDir}, run: (*shell).list},
+	{name: "rm", usage: "rm <文件ID|路径>", help: "删除文件（移入回收站）",
+		args: []shellArg{argRemotePath}, run: (*shell).remove},
+	{name: "status", usage: "status", help: "驱动器的成功率、耗时和进行中的传输", run: (*shell).status},
+	{name: "drivers", usage: "drivers", help: "驱动器的类型、状态和容量", run: (*shell).listDrivers},
+	{name: "jobs", usage: "jobs [任务ID | cancel|pause|resume <任务ID>]", help: "列出、查看或控制后台任务",
+		args: []shellArg{argJob, argJobID}, run: (*shell).jobs},
+	{name: "help", usage: "help", help: "显示命令列表"},
+	{name: "exit", usage: "exit", help: "退出（也可按Ctrl+D）"},
+}
+
+func findShellCommand(name string) *shellCommand {
+	if name == "quit" {
+		name = "exit"
+	}
+	for _, cmd := range shellCommands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// 用已初始化的阵列创建交互式命令行
+func (e *env) shell(raidLevel int, outputPath string) *shell {
+	return &shell{
+		rc:             e.rc,
+		mm:             e.mm,
+		ns:             e.ns,
+		rs:             e.rs,
+		jm:             e.jobs,
+		storageDrivers: e.storageDrivers,
+		driverTypes:    e.driverTypes,
+		historyPath:    filepath.Join(e.cfg.Core.MetadataPath, "shell_history"),
+		raidLevel:      raidLevel,
+		outputPath:     outputPath,
+	}
+}
+
+// 读取并执行命令，直到exit、输入结束或ctx取消。命令执行中按Ctrl+C只取消该命令
+func (s *shell) run(ctx context.Context) error {
+	fmt.Println("=== PanMatrix RAID-over-Cloud 系统 ===")
+	fmt.Println("输入 help 查看命令，Tab 补全命令和路径，上下键翻阅历史")
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	readLine, restore, err := s.openInput()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	for ctx.Err() == nil {
+		line, err := readLine()
+		if err == io.EOF {
+			fmt.Fprintln(s.output())
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取命令失败: %v", err)
+		}
+		args := splitShellArgs(line)
+		if len(args) == 0 {
+			continue
+		}
+		cmd := findShellCommand(args[0])
+		if cmd == nil {
+			fmt.Fprintf(s.output(), "未知的命令: %s，输入 help 查看命令\n", args[0])
+			continue
+		}
+		switch cmd.name {
+		case "exit":
+			return nil
+		case "help":
+			for _, cmd := range shellCommands {
+				fmt.Fprintf(s.output(), "  %-46s %s\n", cmd.usage, cmd.help)
+			}
+			continue
+		}
+
+		// 命令执行期间恢复终端的正常模式，Ctrl+C 发送中断信号
+		restore()
+		select {
+		case <-interrupts:
+		default:
+		}
+		cmdCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-interrupts:
+				cancel()
+			case <-done:
+			}
+		}()
+		switch err := cmd.run(s, cmdCtx, args[1:]); {
+		case errors.Is(err, errUsage):
+			fmt.Printf("用法: %s\n", cmd.usage)
+		case err != nil:
+			fmt.Printf("错误: %v\n", err)
+		}
+		close(done)
+		cancel()
+		if restore, err = s.enterRaw(); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// 标准输入是终端时使用行编辑（补全、历史），否则逐行读取
+func (s *shell) openInput() (func() (string, error), func(), error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		readLine := func() (string, error) {
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+		return readLine, func() {}, nil
+	}
+
+	s.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "panmatrix> ")
+	s.term.AutoCompleteCallback = s.complete
+	s.term.History = loadShellHistory(s.historyPath)
+	if width, height, err := term.GetSize(fd); err == nil {
+		s.term.SetSize(width, height)
+	}
+	restore, err := s.enterRaw()
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.term.ReadLine, restore, nil
+}
+
+// 切换终端到原始模式，返回恢复函数（可重复调用）
+func (s *shell) enterRaw() (func(), error) {
+	if s.term == nil {
+		return func() {}, nil
+	}
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("设置终端失败: %v", err)
+	}
+	restored := false
+	return func() {
+		if !restored {
+			restored = true
+			term.Restore(fd, state)
+		}
+	}, nil
+}
+
+func (s *shell) upload(ctx context.Context, args []string) error {
+	if len(args) == 0 || len(args) > 3 {
+		return errUsage
+	}
+	dir, raidLevel := "/", s.raidLevel
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	if len(args) > 2 {
+		level, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("无效的RAID级别: %s", args[2])
+		}
+		raidLevel = level
+	}
+	if err := handleUploadPath(ctx, s.rc, s.mm, s.ns, s.rs, args[0], dir, nil, raidLevel); err != nil {
+		return err
+	}
+	purgeExpired(ctx, s.rc, s.mm)
+	return nil
+}
+
+func (s *shell) download(ctx context.Context, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errUsage
+	}
+	outputPath := s.outputPath
+	if len(args) > 1 {
+		outputPath = args[1]
+	}
+	return handleDownload(ctx, s.rc, s.mm, s.ns, s.rs, args[0], outputPath)
+}
+
+func (s *shell) list(ctx context.Context, args []string) error {
+	dir := "/"
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	return handleList(s.ns, metadata.ListOptions{Dir: dir, SortBy: "name"}, "", 1, "")
+}
+
+func (s *shell) remove(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if err := handleDelete(ctx, s.rc, s.mm, s.ns, args[0]); err != nil {
+		return err
+	}
+	purgeExpired(ctx, s.rc, s.mm)
+	return nil
+}
+
+func (s *shell) status(ctx context.Context, args []string) error {
+	handleStatus(s.rs)
+	return nil
+}
+
+func (s *shell) listDrivers(ctx context.Context, args []string) error {
+	handleDrivers(s.rs, s.storageDrivers, s.driverTypes)
+	return nil
+}
+
+func (s *shell) jobs(ctx context.Context, args []string) error {
+	switch {
+	case len(args) == 0:
+		return handleJobs(s.jm, true, "", "", "", "")
+	case len(args) == 1:
+		return handleJobs(s.jm, false, args[0], "", "", "")
+	case len(args) == 2 && args[0] == "cancel":
+		return handleJobs(s.jm, false, "", args[1], "", "")
+	case len(args) == 2 && args[0] == "pause":
+		return handleJobs(s.jm, false, "", "", args[1], "")
+	case len(args) == 2 && args[0] == "resume":
+		return handleJobs(s.jm, false, "", "", "", args[1])
+	}
+	return errUsage
+}
+
+// 原始模式下输出需要经过Terminal转换换行
+func (s *shell) output() io.Writer {
+	if s.term != nil {
+		return s.term
+	}
+	return os.Stdout
+}
+
+// 按空白分割命令行，单引号或双引号中的空白不分割
+func splitShellArgs(line string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// Tab补全：第一个词补全命令名，之后按命令的参数类型补全本地路径、虚拟路径或任务ID。
+// 有多个候选时补全到共同前缀，没有可补全的部分时列出候选
+func (s *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	before := line[:pos]
+	start := 0
+	var quote rune
+	for i, r := range before {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+		case r == '"' || r == '\'':
+			quote, start = r, i+1
+		case r == ' ' || r == '\t':
+			start = i + 1
+		}
+	}
+	word := before[start:]
+	args := splitShellArgs(before[:start])
+	if quote != 0 && len(args) > 0 {
+		// 引号中的词从引号之后开始，不是已输入完的参数
+		args = args[:len(args)-1]
+	}
+
+	var candidates []string
+	if len(args) == 0 {
+		for _, cmd := range shellCommands {
+			candidates = append(candidates, cmd.name+" ")
+		}
+	} else if cmd := findShellCommand(args[0]); cmd != nil && len(args)-1 < len(cmd.args) {
+		candidates = s.completeArg(cmd.args[len(args)-1], word)
+	}
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if common == word && len(matches) > 1 {
+		sort.Strings(matches)
+		fmt.Fprintln(s.output(), strings.Join(matches, "  "))
+		return "", 0, false
+	}
+	if quote == 0 && strings.Contains(strings.TrimSuffix(common, " "), " ") {
+		// 带空格的路径加引号
+		common = `"` + common
+	}
+	newLine := line[:start] + common + line[pos:]
+	return newLine, start + len(common), true
+}
+
+func (s *shell) completeArg(kind shellArg, word string) []string {
+	switch kind {
+	case argLocalPath:
+		paths, _ := filepath.Glob(globEscape(word) + "*")
+		for i, p := range paths {
+			if info, err := os.Stat(p); err == nil && info.IsDir() {
+				paths[i] = p + string(filepath.Separator)
+			}
+		}
+		return paths
+	case argRemotePath, argRemoteDir:
+		var dir string
+		if i := strings.LastIndex(word, "/"); i >= 0 {
+			dir = word[:i+1]
+		}
+		entries, err := s.ns.ListDir(metadata.CleanPath(dir))
+		if err != nil {
+			return nil
+		}
+		var paths []string
+		for _, entry := range entries {
+			switch {
+			case entry.IsDir:
+				paths = append(paths, dir+entry.Name+"/")
+			case kind == argRemotePath:
+				paths = append(paths, dir+entry.Name)
+			}
+		}
+		return paths
+	case argJob, argJobID:
+		var ids []string
+		if kind == argJob {
+			ids = append(ids, "cancel ", "pause ", "resume ")
+		}
+		for _, job := range s.jm.List() {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+	return nil
+}
+
+// 转义通配符，本地路径按字面匹配
+func globEscape(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// 命令历史，保存在元数据目录中，重启后继续使用
+type shellHistory struct {
+	path    string
+	entries []string // 最早的在前
+}
+
+func loadShellHistory(historyPath string) *shellHistory {
+	h := &shellHistory{path: historyPath}
+	if historyPath == "" {
+		return h
+	}
+	data, err := os.ReadFile(historyPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("警告: 读取命令历史失败: %v\n", err)
+		}
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if len(h.entries) > shellHistoryLimit {
+		// 只保留最近的记录
+		h.entries = h.entries[len(h.entries)-shellHistoryLimit:]
+		os.WriteFile(historyPath, []byte(strings.Join(h.entries, "\n")+"\n"), 0600)
+	}
+	return h
+}
+
+func (h *shellHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > shellHistoryLimit {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, entry)
+}
+
+func (h *shellHistory) Len() int {
+	return len(h.entries)
+}
+
+func (h *shellHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"panmatrix/api"
+	"panmatrix/metadata"
+
+	"golang.org/x/net/webdav"
+)
+
+// 配置了api.webdav_listen时在后台提供WebDAV服务，返回的函数停止服务
+func (e *env) serveWebDAV(cfg api.Config, raidLevel int) (func(), error) {
+	d := &davFS{g: &gateway{e: e, ns: e.ns, raidLevel: raidLevel}, started: time.Now()}
+	handler := &webdav.Handler{
+		FileSystem: d,
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			// 客户端经常探测不存在的文件（如desktop.ini、.DS_Store），目标已存在和被锁定也是正常的应答
+			if err != nil && !os.IsNotExist(err) && !os.IsExist(err) && err != webdav.ErrLocked {
+				log.Printf("警告: WebDAV %s %s失败: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	server, listener, err := api.ListenWebDAV(cfg, handler)
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return func() {}, nil
+	}
+	return serveHTTP("WebDAV服务", cfg.WebDAVListen, server, listener), nil
+}
+
+// WebDAV的文件系统，目录结构即虚拟目录。读取时按需下载涉及的条带，可以拖动进度条播放；
+// 写入的内容先保存在本地的临时文件中，关闭时整个写入阵列
+type davFS struct {
+	g       *gateway
+	started time.Time // 目录没有记录修改时间，使用服务启动的时间
+}
+
+var _ webdav.FileSystem = (*davFS)(nil)
+
+func (d *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	p := metadata.CleanPath(name)
+	if _, err := d.g.ns.GetByName(p); err == nil || d.g.ns.DirExists(p) {
+		return &os.PathError{Op: "mkdir", Path: p, Err: os.ErrExist}
+	}
+	// 上级目录不存在时返回409
+	if err := d.g.ns.Mkdir(p, false); err != nil {
+		return davError("mkdir", p, err)
+	}
+	return nil
+}
+
+func (d *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	p := metadata.CleanPath(name)
+	writing := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	meta, err := d.g.ns.GetByName(p)
+	if err != nil && !errors.Is(err, metadata.ErrNotFound) {
+		return nil, davError("open", p, err)
+	}
+
+	if meta == nil && d.g.ns.DirExists(p) {
+		if writing {
+			return nil, &os.PathError{Op: "open", Path: p, Err: errors.New("是目录")}
+		}
+		return &davDir{d: d, p: p}, nil
+	}
+	if !writing {
+		if meta == nil {
+			return nil, &os.PathError{Op: "open", Path: p, Err: os.ErrNotExist}
+		}
+		return &davReader{ctx: ctx, r: d.g.openReader(meta), info: d.fileInfo(meta)}, nil
+	}
+
+	if meta == nil && (flag&os.O_CREATE == 0 || !d.g.ns.DirExists(path.Dir(p))) {
+		return nil, &os.PathError{Op: "open", Path: p, Err: os.ErrNotExist}
+	}
+	if meta != nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &os.PathError{Op: "open", Path: p, Err: os.ErrExist}
+	}
+	return d.openWriter(ctx, p, meta, flag&os.O_TRUNC != 0)
+}
+
+func (d *davFS) openWriter(ctx context.Context, p string, meta *metadata.FileMetadata, truncate bool) (*davWriter, error) {
+	var data []byte
+	var mode os.FileMode // 新建的文件不记录权限
+	if meta != nil {
+		mode = meta.Mode.Perm()
+		if !truncate {
+			var err error
+			if data, err = d.g.readFile(ctx, meta); err != nil {
+				return nil, davError("open", p, err)
+			}
+		}
+	}
+
+	file, err := os.CreateTemp("", "panmatrix-webdav-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	return &davWriter{ctx: ctx, d: d, p: p, file: file, mode: mode}, nil
+}
+
+// 删除文件，或删除目录及其中的所有文件
+func (d *davFS) RemoveAll(ctx context.Context, name string) error {
+	p := metadata.CleanPath(name)
+	if p == "/" {
+		return &os.PathError{Op: "remove", Path: p, Err: os.ErrPermission}
+	}
+	if meta, err := d.g.ns.GetByName(p); err == nil {
+		if err := d.g.remove(ctx, meta); err != nil {
+			return davError("remove", p, err)
+		}
+		return nil
+	}
+	if !d.g.ns.DirExists(p) {
+		return &os.PathError{Op: "remove", Path: p, Err: os.ErrNotExist}
+	}
+
+	entries, err := d.g.ns.ListDir(p)
+	if err != nil {
+		return davError("remove", p, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir {
+			err = d.RemoveAll(ctx, entry.Path)
+		} else {
+			err = d.g.remove(ctx, entry.File)
+		}
+		if err != nil {
+			return davError("remove", entry.Path, err)
+		}
+	}
+	return d.rmdir(p)
+}
+
+// 元数据没有移动操作，文件复制到新路径后删除原文件，目录逐个移动其中的文件
+func (d *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	from, to := metadata.CleanPath(oldName), metadata.CleanPath(newName)
+	if from == "/" || to == "/" {
+		return &os.PathError{Op: "rename", Path: from, Err: os.ErrPermission}
+	}
+	if !d.g.ns.DirExists(path.Dir(to)) {
+		return &os.PathError{Op: "rename", Path: to, Err: os.ErrNotExist}
+	}
+	if meta, err := d.g.ns.GetByName(from); err == nil {
+		return d.moveFile(ctx, meta, to)
+	}
+	if !d.g.ns.DirExists(from) {
+		return &os.PathError{Op: "rename", Path: from, Err: os.ErrNotExist}
+	}
+	if strings.HasPrefix(to, from+"/") {
+		return &os.PathError{Op: "rename", Path: to, Err: errors.New("不能移动到自身的子目录")}
+	}
+	return d.moveDir(ctx, from, to)
+}
+
+func (d *davFS) moveFile(ctx context.Context, meta *metadata.FileMetadata, to string) error {
+	if err := d.g.copyFile(ctx, meta, to); err != nil {
+		return davError("rename", to, err)
+	}
+	if err := d.g.remove(ctx, meta); err != nil {
+		return davError("rename", meta.Path(), err)
+	}
+	return nil
+}
+
+func (d *davFS) moveDir(ctx context.Context, from, to string) error {
+	entries, err := d.g.ns.ListDir(from)
+	if err != nil {
+		return davError("rename", from, err)
+	}
+	if err := d.g.ns.Mkdir(to, true); err != nil {
+		return davError("rename", to, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir {
+			err = d.moveDir(ctx, entry.Path, path.Join(to, entry.Name))
+		} else {
+			err = d.moveFile(ctx, entry.File, path.Join(to, entry.Name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.rmdir(from)
+}
+
+// 删除已清空的目录。只因包含文件而存在的目录在文件删除后自动消失
+func (d *davFS) rmdir(p string) error {
+	if err := d.g.ns.Rmdir(p); err != nil && !errors.Is(err, metadata.ErrNotFound) {
+		return davError("remove", p, err)
+	}
+	return nil
+}
+
+func (d *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	p := metadata.CleanPath(name)
+	meta, err := d.g.ns.GetByName(p)
+	if err == nil {
+		return d.fileInfo(meta), nil
+	}
+	if !errors.Is(err, metadata.ErrNotFound) {
+		return nil, davError("stat", p, err)
+	}
+	if d.g.ns.DirExists(p) {
+		return d.dirInfo(p), nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: p, Err: os.ErrNotExist}
+}
+
+func (d *davFS) fileInfo(meta *metadata.FileMetadata) *davInfo {
+	mode := meta.Mode.Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	mtime := meta.ModTime
+	if mtime.IsZero() {
+		mtime = meta.UpdatedAt
+	}
+	return &davInfo{name: meta.FileName, size: meta.FileSize, mode: mode, modTime: mtime, meta: meta}
+}
+
+func (d *davFS) dirInfo(p string) *davInfo {
+	return &davInfo{name: path.Base(p), mode: os.ModeDir | 0755, modTime: d.started}
+}
+
+// webdav包用os.IsNotExist等判断错误，不识别用%w包装的错误
+func davError(op, p string, err error) error {
+	if errors.Is(err, metadata.ErrNotFound) || errors.Is(err, metadata.ErrFileNotCommitted) {
+		return &os.PathError{Op: op, Path: p, Err: os.ErrNotExist}
+	}
+	if errors.Is(err, metadata.ErrDirShared) {
+		return &os.PathError{Op: op, Path: p, Err: os.ErrPermission}
+	}
+	return err
+}
+
+type davInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	meta    *metadata.FileMetadata // 已提交的文件，目录和写入中的文件为nil
+}
+
+var (
+	_ webdav.ETager       = (*davInfo)(nil)
+	_ webdav.ContentTyper = (*davInfo)(nil)
+)
+
+func (i *davInfo) Name() string       { return i.name }
+func (i *davInfo) Size() int64        { return i.size }
+func (i *davInfo) Mode() os.FileMode  { return i.mode }
+func (i *davInfo) ModTime() time.Time { return i.modTime }
+func (i *davInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *davInfo) Sys() any           { return nil }
+
+// 内容不变时ETag不变，客户端可以继续使用缓存
+func (i *davInfo) ETag(ctx context.Context) (string, error) {
+	if i.meta == nil || i.meta.Hash == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + i.meta.Hash + `"`, nil
+}
+
+// 使用上传时记录的类型，不必读取文件开头来判断
+func (i *davInfo) ContentType(ctx context.Context) (string, error) {
+	if i.meta == nil || i.meta.MimeType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return i.meta.MimeType, nil
+}
+
+// 打开的目录，只能列出其中的子目录和文件
+type davDir struct {
+	d       *davFS
+	p       string
+	entries []os.FileInfo // 第一次Readdir时读取
+	listed  bool
+}
+
+func (f *davDir) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.listed {
+		entries, err := f.d.g.ns.ListDir(f.p)
+		if err != nil {
+			return nil, davError("readdir", f.p, err)
+		}
+		// 同名文件按上传时间排列，只列出最新的一个（与按路径查找时一致）
+		index := make(map[string]int, len(entries))
+		for _, entry := range entries {
+			info := f.d.dirInfo(entry.Path)
+			if !entry.IsDir {
+				info = f.d.fileInfo(entry.File)
+			}
+			if i, ok := index[entry.Name]; ok && !entry.IsDir {
+				f.entries[i] = info
+				continue
+			}
+			index[entry.Name] = len(f.entries)
+			f.entries = append(f.entries, info)
+		}
+		f.listed = true
+	}
+
+	if count <= 0 {
+		list := f.entries
+		f.entries = nil
+		return list, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(f.entries))
+	list := f.entries[:n]
+	f.entries = f.entries[n:]
+	return list, nil
+}
+
+func (f *davDir) Stat() (os.FileInfo, error) { return f.d.dirInfo(f.p), nil }
+func (f *davDir) Close() error               { return nil }
+
+func (f *davDir) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: f.p, Err: errors.New("是目录")}
+}
+
+func (f *davDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
+
+func (f *davDir) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.p, Err: os.ErrPermission}
+}
+
+// 只读打开的已提交文件
+type davReader struct {
+	ctx  context.Context // 打开文件的请求
+	r    *fileReader
+	info *davInfo
+	pos  int64
+}
+
+func (f *davReader) Read(p []byte) (int, error) {
+	n, err := f.r.readAt(f.ctx, p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+func (f *davReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.info.size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.info.meta.Path(), Err: os.ErrInvalid}
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *davReader) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.info.meta.Path(), Err: errors.New("不是目录")}
+}
+
+func (f *davReader) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.info.meta.Path(), Err: os.ErrPermission}
+}
+
+func (f *davReader) Stat() (os.FileInfo, error) { return f.info, nil }
+
+func (f *davReader) Close() error {
+	f.r.close()
+	return nil
+}
+
+// 可写打开的文件，读写都在临时文件上进行，关闭时写入阵列并提交为新版本
+type davWriter struct {
+	ctx  context.Context // 打开文件的请求
+	d    *davFS
+	p    string
+	file *os.File
+	mode os.FileMode
+}
+
+func (f *davWriter) Read(p []byte) (int, error)  { return f.file.Read(p) }
+func (f *davWriter) Write(p []byte) (int, error) { return f.file.Write(p) }
+
+func (f *davWriter) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+func (f *davWriter) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.p, Err: errors.New("不是目录")}
+}
+
+func (f *davWriter) Stat() (os.FileInfo, error) {
+	info, err := f.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	mode := f.mode
+	if mode == 0 {
+		mode = 0644
+	}
+	return &davInfo{name: path.Base(f.p), size: info.Size(), mode: mode, modTime: info.ModTime()}, nil
+}
+
+// 提交的错误（如超出配额）作为PUT请求的错误返回
+func (f *davWriter) Close() error {
+	defer os.Remove(f.file.Name())
+	defer f.file.Close()
+
+	info, err := f.file.Stat()
+	if err != nil {
+		return fmt.Errorf("读取临时文件失败: %v", err)
+	}
+	data := make([]byte, info.Size())
+	if _, err := f.file.ReadAt(data, 0); err != nil && err != io.EOF {
+		return fmt.Errorf("读取临时文件失败: %v", err)
+	}
+	if _, err := f.d.g.store(f.ctx, f.p, data, f.mode); err != nil {
+		return davError("close", f.p, err)
+	}
+	return nil
+}
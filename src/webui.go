@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"panmatrix/api"
+	"panmatrix/api/apipb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// 与gRPC接口的消息相同，字段名使用proto中的写法
+var webJSON = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// 配置了api.web_listen时在后台提供Web界面，返回的函数停止服务
+func (e *env) serveWeb(cfg api.Config, service *grpcService) (func(), error) {
+	server, listener, err := api.ListenWeb(cfg, newWebHandler(service))
+	if err != nil {
+		return nil, err
+	}
+	if server == nil {
+		return func() {}, nil
+	}
+	return serveHTTP("Web界面", cfg.WebListen, server, listener), nil
+}
+
+// 在后台运行HTTP服务，返回的函数停止服务并断开所有连接
+func serveHTTP(name, address string, server *http.Server, listener net.Listener) func() {
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("警告: %s已停止: %v", name, err)
+		}
+	}()
+	fmt.Printf("%s监听 %s\n", name, address)
+	return func() { server.Close() }
+}
+
+// Web界面的JSON接口，每个请求对应一个gRPC方法：
+//
+//	GET    /api/files?dir=&pattern=&sort=&desc=&offset=&limit=   List
+//	GET    /api/search?q=&sort=&desc=&offset=&limit=             Search
+//	GET    /api/stat?file=                                       Stat
+//	GET    /api/download?file=                                   Download，直接返回文件内容
+//	PUT    /api/upload?dir=&name=&mtime=&tag=                    Upload，请求体为文件内容，mtime为Unix毫秒
+//	DELETE /api/files?file=                                      Delete
+//	POST   /api/mkdir?path=                                      Mkdir
+//	GET    /api/drivers                                          Drivers
+//	GET    /api/jobs                                             ListJobs
+//	POST   /api/jobs/{id}/{cancel|pause|resume}                  CancelJob等
+//
+// 出错时返回 {"error": "..."}，HTTP状态码由gRPC状态码换算
+func newWebHandler(s *grpcService) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/files", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		offset, limit, desc, err := webPage(r)
+		if err != nil {
+			webError(w, err)
+			return
+		}
+		resp, err := s.List(r.Context(), &apipb.ListRequest{
+			Dir:     q.Get("dir"),
+			Pattern: q.Get("pattern"),
+			SortBy:  q.Get("sort"),
+			Desc:    desc,
+			Offset:  offset,
+			Limit:   limit,
+		})
+		webReply(w, resp, err)
+	})
+	mux.HandleFunc("GET /api/search", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		offset, limit, desc, err := webPage(r)
+		if err != nil {
+			webError(w, err)
+			return
+		}
+		resp, err := s.Search(r.Context(), &apipb.SearchRequest{
+			Query:  q.Get("q"),
+			SortBy: q.Get("sort"),
+			Desc:   desc,
+			Offset: offset,
+			Limit:  limit,
+		})
+		webReply(w, resp, err)
+	})
+	mux.HandleFunc("GET /api/stat", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Stat(r.Context(), &apipb.StatRequest{File: r.URL.Query().Get("file")})
+		webReply(w, resp, err)
+	})
+	mux.HandleFunc("GET /api/download", func(w http.ResponseWriter, r *http.Request) {
+		meta, data, err := s.download(r.Context(), r.URL.Query().Get("file"))
+		if err != nil {
+			webError(w, err)
+			return
+		}
+		if meta.MimeType != "" {
+			w.Header().Set("Content-Type", meta.MimeType)
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": meta.FileName}))
+		w.Header().Set("ETag", strconv.Quote(meta.Hash))
+		http.ServeContent(w, r, meta.FileName, meta.ModTime, bytes.NewReader(data))
+	})
+	mux.HandleFunc("PUT /api/upload", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.ContentLength < 0 {
+			webError(w, status.Error(codes.InvalidArgument, "上传需要Content-Length"))
+			return
+		}
+		header := &apipb.UploadHeader{Name: q.Get("name"), Dir: q.Get("dir"), Tags: q["tag"], Size: r.ContentLength}
+		if v := q.Get("mtime"); v != "" {
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				webError(w, status.Errorf(codes.InvalidArgument, "无效的mtime: %q", v))
+				return
+			}
+			header.ModTime = time.UnixMilli(ms).UnixNano()
+		}
+		resp, err := s.upload(r.Context(), header, r.Body)
+		webReply(w, resp, err)
+	})
+	mux.HandleFunc("DELETE /api/files", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Delete(r.Context(), &apipb.DeleteRequest{File: r.URL.Query().Get("file")})
+		webReply(w, resp, err)
+	})
+	mux.HandleFunc("POST /api/mkdir", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Mkdir(r.Context(), &apipb.MkdirRequest{Path: r.URL.Query().Get("path")})
+		webReply(w, resp, err)
+	})
+	mux.HandleFunc("GET /api/drivers", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.Drivers(r.Context(), &apipb.DriversRequest{})
+		webReply(w, resp, err)
+	})
+	mux.HandleFunc("GET /api/jobs", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.ListJobs(r.Context(), &apipb.ListJobsRequest{})
+		webReply(w, resp, err)
+	})
+	mux.HandleFunc("POST /api/jobs/{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		req := &apipb.JobControlRequest{Id: r.PathValue("id")}
+		var resp *apipb.Job
+		var err error
+		switch action := r.PathValue("action"); action {
+		case "cancel":
+			resp, err = s.CancelJob(r.Context(), req)
+		case "pause":
+			resp, err = s.PauseJob(r.Context(), req)
+		case "resume":
+			resp, err = s.ResumeJob(r.Context(), req)
+		default:
+			err = status.Errorf(codes.NotFound, "未知的任务操作: %s", action)
+		}
+		webReply(w, resp, err)
+	})
+	return mux
+}
+
+// 分页和排序方向参数
+func webPage(r *http.Request) (offset, limit int32, desc bool, err error) {
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name  string
+		value *int32
+	}{{"offset", &offset}, {"limit", &limit}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 0 {
+				return 0, 0, false, status.Errorf(codes.InvalidArgument, "无效的%s: %q", p.name, v)
+			}
+			*p.value = int32(n)
+		}
+	}
+	desc = q.Get("desc") == "1" || q.Get("desc") == "true"
+	return offset, limit, desc, nil
+}
+
+func webReply(w http.ResponseWriter, resp proto.Message, err error) {
+	if err != nil {
+		webError(w, err)
+		return
+	}
+	data, err := webJSON.Marshal(resp)
+	if err != nil {
+		webError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(data)
+}
+
+func webError(w http.ResponseWriter, err error) {
+	st := status.Convert(grpcError(err))
+	code := http.StatusInternalServerError
+	switch st.Code() {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.AlreadyExists, codes.FailedPrecondition:
+		code = http.StatusConflict
+	case codes.ResourceExhausted:
+		code = http.StatusInsufficientStorage
+	case codes.Unimplemented:
+		code = http.StatusNotImplemented
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	case codes.Canceled, codes.DeadlineExceeded:
+		code = http.StatusRequestTimeout
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": st.Message()})
+}